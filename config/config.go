@@ -32,6 +32,31 @@ type Config struct {
 	FacebookRedirectURL          string `envconfig:"facebook_redirect_url"`
 	GoogleMapsApiKey             string `envconfig:"google_maps_api_key"`
 	AccessControlAllowOrigin     string `envconfig:"accessc_control_allow_origin"`
+	AWSRegion                    string `envconfig:"aws_region"`
+	AWSBucket                    string `envconfig:"aws_bucket"`
+	UploadPartSizeMB             int64  `envconfig:"upload_part_size_mb" default:"16"`
+	UploadConcurrency            int    `envconfig:"upload_concurrency" default:"4"`
+	TusUploadExpiryHours         int    `envconfig:"tus_upload_expiry_hours" default:"24"`
+	PresignExpiryMinutes         int    `envconfig:"presign_expiry_minutes" default:"15"`
+	ProgressStore                string `envconfig:"progress_store" default:"postgres"` // "postgres" or "memory"
+	PosterMinWidth               int    `envconfig:"poster_min_width" default:"800"`
+	PosterMinHeight              int    `envconfig:"poster_min_height" default:"1200"`
+	OrphanGraceHours             int    `envconfig:"orphan_grace_hours" default:"24"`
+	DeleteOrphanedObjects        bool   `envconfig:"delete_orphaned_objects" default:"false"`
+	TrashRetentionDays           int    `envconfig:"trash_retention_days" default:"30"`
+	RightsExpiryAlertDays        int    `envconfig:"rights_expiry_alert_days" default:"60"`
+	RightsAlertEmail             string `envconfig:"rights_alert_email"`
+	PressKitLinkExpiryHours      int    `envconfig:"press_kit_link_expiry_hours" default:"168"` // at most 168, S3 refuses longer presigned links
+	DefaultCallingCode           string `envconfig:"default_calling_code" default:"234"` // for phone numbers written without one, e.g. 0803 123 4567
+	ReportingCurrency            string `envconfig:"reporting_currency" default:"NGN"`
+	InvoicePrefix                string `envconfig:"invoice_prefix" default:"INV"`
+	InvoicePaymentDays           int    `envconfig:"invoice_payment_days" default:"30"`
+	CompanyName                  string `envconfig:"company_name"` // who invoices are from
+	CompanyAddress               string `envconfig:"company_address"`
+	CompanyTaxID                 string `envconfig:"company_tax_id"` // the TIN printed on invoices
+	PurchaseOrderPrefix          string `envconfig:"purchase_order_prefix" default:"PO"`
+	PurchasePriceTolerance       int    `envconfig:"purchase_price_tolerance" default:"2"`    // percent a vendor bill may charge above the ordered price
+	PurchaseQuantityTolerance    int    `envconfig:"purchase_quantity_tolerance" default:"0"` // percent a vendor bill may bill beyond what was received
 }
 
 func Load() (*Config, error) {
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+type ActivityRepository interface {
+	CreateActivity(activity *models.Activity) error
+	UpdateActivity(activity *models.Activity) error
+	DeleteActivity(id uint) error
+	FindActivity(id uint) (*models.Activity, error)
+	ListActivities(filter *models.ActivityFilter) ([]models.Activity, int64, error)
+	FindCompletedTasks(subjectType string, subjectID uint, limit int) ([]models.Activity, error)
+	FindOpenTasks(assigneeID uint, dueBy time.Time) ([]models.Activity, error)
+}
+
+type activityRepo struct {
+	DB *gorm.DB
+}
+
+func NewActivityRepo(db *GormDB) ActivityRepository {
+	return &activityRepo{db.DB}
+}
+
+func (r *activityRepo) CreateActivity(activity *models.Activity) error {
+	if err := r.DB.Create(activity).Error; err != nil {
+		return fmt.Errorf("failed to create activity: %w", err)
+	}
+	return nil
+}
+
+func (r *activityRepo) UpdateActivity(activity *models.Activity) error {
+	if err := r.DB.Save(activity).Error; err != nil {
+		return fmt.Errorf("failed to update activity %d: %w", activity.ID, err)
+	}
+	return nil
+}
+
+func (r *activityRepo) DeleteActivity(id uint) error {
+	result := r.DB.Where("id = ?", id).Delete(&models.Activity{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func (r *activityRepo) FindActivity(id uint) (*models.Activity, error) {
+	var activity models.Activity
+	if err := r.DB.Where("id = ?", id).First(&activity).Error; err != nil {
+		return nil, err
+	}
+	return &activity, nil
+}
+
+// ListActivities returns a page of the activities of a subject, most recent first, and the total count
+func (r *activityRepo) ListActivities(filter *models.ActivityFilter) ([]models.Activity, int64, error) {
+	db := r.DB.Model(&models.Activity{}).
+		Where("subject_type = ? AND subject_id = ?", filter.SubjectType, filter.SubjectID)
+	if filter.Kind != "" {
+		db = db.Where("kind = ?", filter.Kind)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var activities []models.Activity
+	err := db.Order("occurred_at DESC, id DESC").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Find(&activities).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return activities, total, nil
+}
+
+// FindCompletedTasks returns the tasks about a subject completed last, most recent first
+func (r *activityRepo) FindCompletedTasks(subjectType string, subjectID uint, limit int) ([]models.Activity, error) {
+	var tasks []models.Activity
+	err := r.DB.Where("subject_type = ? AND subject_id = ? AND kind = ? AND completed_at IS NOT NULL", subjectType, subjectID, models.ActivityTask).
+		Order("completed_at DESC, id DESC").
+		Limit(limit).
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// FindOpenTasks returns the tasks assigned to someone that are not completed and due by a date, those due
+// first first
+func (r *activityRepo) FindOpenTasks(assigneeID uint, dueBy time.Time) ([]models.Activity, error) {
+	var tasks []models.Activity
+	err := r.DB.Where("kind = ? AND assignee_id = ? AND completed_at IS NULL AND due_date <= ?", models.ActivityTask, assigneeID, dueBy.Format(models.DateLayout)).
+		Order("due_date, id").
+		Find(&tasks).Error
+	return tasks, err
+}
@@ -11,6 +11,7 @@ import (
 	ew "github.com/pkg/errors"
 	"github.com/techagentng/telair-erp/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AuthRepository interface {
@@ -27,7 +28,7 @@ type AuthRepository interface {
 	IsTokenInBlacklist(token string) bool
 	UpdatePassword(password string, email string) error
 	FindUserByID(id uint) (*models.User, error)
-	EditUserProfile(userID uint, userDetails *models.EditProfileResponse) error
+	EditUserProfile(userID uint, version int, userDetails *models.EditProfileResponse) (int, error)
 	FindUserByMacAddress(macAddress string) (*models.LoginRequestMacAddress, error)
 	ResetPassword(userID, NewPassword string) error
 	SetUserOffline(user *models.User) error
@@ -83,7 +84,8 @@ func (a *authRepo) FindUserByUsername(username string) (*models.User, error) {
 
 func (a *authRepo) IsEmailExist(email string) error {
 	var count int64
-	err := a.DB.Model(&models.User{}).Where("email = ?", email).Count(&count).Error
+	// Deleted accounts keep their email until they are purged, so that they can be restored
+	err := a.DB.Unscoped().Model(&models.User{}).Where("email = ?", email).Count(&count).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			// No user found with this email, return nil
@@ -101,7 +103,7 @@ func (a *authRepo) IsEmailExist(email string) error {
 
 func (a *authRepo) IsPhoneExist(phone string) error {
 	var count int64
-	err := a.DB.Model(&models.User{}).Where("telephone = ?", phone).Count(&count).Error
+	err := a.DB.Unscoped().Model(&models.User{}).Where("telephone = ?", phone).Count(&count).Error
 	if err != nil {
 		return ew.Wrap(err, "gorm.count error")
 	}
@@ -217,24 +219,35 @@ func (a *authRepo) CreateUserImage(user *models.User) error {
 }
 
 
-func (a *authRepo) EditUserProfile(userID uint, userDetails *models.EditProfileResponse) error {
-	// Fetch the user from the database
-	user := models.User{}
-	if err := a.DB.First(&user, userID).Error; err != nil {
-		return err // return error if user not found
+// EditUserProfile updates the profile of a user at the given version and returns the version it is at
+// then, it returns a *ConflictError when the profile has been changed since. Empty fields keep their value.
+func (a *authRepo) EditUserProfile(userID uint, version int, userDetails *models.EditProfileResponse) (int, error) {
+	updates := map[string]interface{}{"version": gorm.Expr("version + 1")}
+	if userDetails.Fullname != "" {
+		updates["fullname"] = userDetails.Fullname
+	}
+	if userDetails.Username != "" {
+		updates["username"] = userDetails.Username
+	}
+	if userDetails.PhoneNumber != "" {
+		updates["telephone"] = userDetails.PhoneNumber
 	}
-
-	// Update user details based on userDetails
-	// user.Fullname = userDetails.Fullname
-	// user.Username = userDetails.Username
-	// Update other fields as needed
 
 	// Perform the update operation
-	if err := a.DB.Save(&user).Error; err != nil {
-		return err
+	var user models.User
+	query := a.DB.Model(&user).Clauses(clause.Returning{Columns: []clause.Column{{Name: "version"}}}).
+		Where("id = ?", userID)
+	if version != models.AnyVersion {
+		query = query.Where("version = ?", version)
 	}
-
-	return nil
+	result := query.Updates(updates)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, versionConflict(a.DB.Model(&models.User{}), "user", userID, version)
+	}
+	return user.Version, nil
 }
 
 func (a *authRepo) ResetPassword(userID, NewPassword string) error {
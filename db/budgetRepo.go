@@ -0,0 +1,234 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BudgetRepository interface {
+	CreateBudget(budget *models.Budget) error
+	UpdateBudget(budget *models.Budget, removedLines []uint) error
+	FindBudget(id uint) (*models.Budget, error)
+	FindTitleBudget(titleType models.TitleType, titleID uint) (*models.Budget, error)
+	ApproveBudget(id, userID uint, at time.Time) error
+	ReopenBudget(id uint) error
+	CountLineCosts(lineIDs []uint) (int64, error)
+	CreateCost(cost *models.CostEntry, entry *models.JournalEntry) error
+	FindCost(id uint) (*models.CostEntry, error)
+	ListCosts(filter *models.CostFilter) ([]models.CostEntry, int64, error)
+	ReviewCost(id uint, status models.CostStatus, userID uint, note string, at time.Time, entry *models.JournalEntry) error
+	SetCostReceipt(id uint, key string) error
+	DeleteCost(id, userID uint) error
+	SumLineCosts(budgetID uint) ([]models.LineCost, error)
+}
+
+type budgetRepo struct {
+	DB *gorm.DB
+}
+
+func NewBudgetRepo(db *GormDB) BudgetRepository {
+	return &budgetRepo{db.DB}
+}
+
+func (r *budgetRepo) CreateBudget(budget *models.Budget) error {
+	if err := r.DB.Create(budget).Error; err != nil {
+		return fmt.Errorf("failed to create budget: %w", err)
+	}
+	return nil
+}
+
+// UpdateBudget saves a draft budget and its line items, deleting the removed ones. Lines keep their IDs,
+// the costs booked against them stay attached.
+func (r *budgetRepo) UpdateBudget(budget *models.Budget, removedLines []uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if len(removedLines) > 0 {
+			if err := tx.Where("budget_id = ? AND id IN ?", budget.ID, removedLines).Delete(&models.BudgetLine{}).Error; err != nil {
+				return err
+			}
+		}
+		for i := range budget.Lines {
+			budget.Lines[i].BudgetID = budget.ID
+			if err := tx.Save(&budget.Lines[i]).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Omit(clause.Associations).Save(budget).Error
+	})
+}
+
+func (r *budgetRepo) FindBudget(id uint) (*models.Budget, error) {
+	var budget models.Budget
+	err := r.DB.Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Where("id = ?", id).First(&budget).Error
+	if err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+func (r *budgetRepo) FindTitleBudget(titleType models.TitleType, titleID uint) (*models.Budget, error) {
+	var budget models.Budget
+	err := r.DB.Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Where("title_type = ? AND title_id = ?", titleType, titleID).First(&budget).Error
+	if err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+func (r *budgetRepo) ApproveBudget(id, userID uint, at time.Time) error {
+	return r.DB.Model(&models.Budget{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":      models.BudgetApproved,
+		"approved_by": userID,
+		"approved_at": at,
+	}).Error
+}
+
+func (r *budgetRepo) ReopenBudget(id uint) error {
+	return r.DB.Model(&models.Budget{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":      models.BudgetDraft,
+		"approved_by": nil,
+		"approved_at": nil,
+	}).Error
+}
+
+// CountLineCosts counts the costs booked against any of the given budget lines
+func (r *budgetRepo) CountLineCosts(lineIDs []uint) (int64, error) {
+	var count int64
+	if len(lineIDs) == 0 {
+		return 0, nil
+	}
+	err := r.DB.Model(&models.CostEntry{}).Where("budget_line_id IN ?", lineIDs).Count(&count).Error
+	return count, err
+}
+
+// CreateCost records a cost and posts the entry booking it, unless there is none yet. Its budget line is
+// locked while the cost is checked against it: an approved cost taking the line over budget, counting the
+// costs approved meanwhile, is recorded as pending instead and not booked.
+func (r *budgetRepo) CreateCost(cost *models.CostEntry, entry *models.JournalEntry) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var line models.BudgetLine
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", cost.BudgetLineID).First(&line).Error
+		if err != nil {
+			return err
+		}
+		if cost.Status == models.CostApproved {
+			var spent decimal.Decimal
+			err := tx.Model(&models.CostEntry{}).
+				Select("COALESCE(SUM(base_amount), 0)").
+				Where("budget_line_id = ? AND status = ?", line.ID, models.CostApproved).
+				Scan(&spent).Error
+			if err != nil {
+				return err
+			}
+			if spent.Add(cost.BaseAmount).GreaterThan(line.Amount) {
+				cost.Status = models.CostPending
+				entry = nil
+			}
+		}
+
+		if err := tx.Omit("Vendor").Create(cost).Error; err != nil {
+			return fmt.Errorf("failed to create cost entry: %w", err)
+		}
+		if entry == nil {
+			return nil
+		}
+		entry.SourceID = cost.ID
+		return postEntry(tx, entry)
+	})
+}
+
+func (r *budgetRepo) FindCost(id uint) (*models.CostEntry, error) {
+	var cost models.CostEntry
+	if err := r.DB.Preload("Vendor").Where("id = ?", id).First(&cost).Error; err != nil {
+		return nil, err
+	}
+	return &cost, nil
+}
+
+// ListCosts returns a page of the costs of a budget, latest first, and the total count
+func (r *budgetRepo) ListCosts(filter *models.CostFilter) ([]models.CostEntry, int64, error) {
+	db := r.DB.Model(&models.CostEntry{}).Where("cost_entries.budget_id = ?", filter.BudgetID)
+	if filter.Status != "" {
+		db = db.Where("cost_entries.status = ?", filter.Status)
+	}
+	if filter.Category != "" {
+		db = db.Joins("JOIN budget_lines ON budget_lines.id = cost_entries.budget_line_id").
+			Where("budget_lines.category = ?", filter.Category)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var costs []models.CostEntry
+	err := db.Preload("Vendor").
+		Order("cost_entries.incurred_on DESC, cost_entries.id DESC").
+		Limit(filter.Limit).Offset((filter.Page - 1) * filter.Limit).
+		Find(&costs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return costs, total, nil
+}
+
+// ReviewCost approves or rejects a pending cost
+// ReviewCost approves or rejects a pending cost and posts the entry booking it when there is one
+func (r *budgetRepo) ReviewCost(id uint, status models.CostStatus, userID uint, note string, at time.Time, entry *models.JournalEntry) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.CostEntry{}).
+			Where("id = ? AND status = ?", id, models.CostPending).
+			Updates(map[string]interface{}{
+				"status":      status,
+				"reviewed_by": userID,
+				"reviewed_at": at,
+				"review_note": note,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if entry == nil {
+			return nil
+		}
+		return postEntry(tx, entry)
+	})
+}
+
+func (r *budgetRepo) SetCostReceipt(id uint, key string) error {
+	return r.DB.Model(&models.CostEntry{}).Where("id = ?", id).Update("receipt_key", key).Error
+}
+
+// DeleteCost removes a cost and reverses the entries booking it, on the day it is deleted
+func (r *budgetRepo) DeleteCost(id, userID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Delete(&models.CostEntry{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return reverseEntries(tx, models.SourceCost, id, time.Now().UTC(), userID)
+	})
+}
+
+// SumLineCosts adds up the costs of every line of a budget by status, in the budget currency
+func (r *budgetRepo) SumLineCosts(budgetID uint) ([]models.LineCost, error) {
+	var totals []models.LineCost
+	err := r.DB.Model(&models.CostEntry{}).
+		Select("budget_line_id, status, SUM(base_amount) AS total").
+		Where("budget_id = ?", budgetID).
+		Group("budget_line_id, status").
+		Scan(&totals).Error
+	return totals, err
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+type ContractRepository interface {
+	CreateContract(contract *models.Contract) error
+	UpdateContract(contract *models.Contract) error
+	FindContract(id uint) (*models.Contract, error)
+	FindContractByReference(reference string) (*models.Contract, error)
+	ListContracts(filter *models.ContractFilter) ([]models.Contract, int64, error)
+	UpdateContractStatus(id uint, status models.ContractStatus) error
+	MarkPaymentPaid(contractID, paymentID uint, at time.Time) error
+}
+
+type contractRepo struct {
+	DB *gorm.DB
+}
+
+func NewContractRepo(db *GormDB) ContractRepository {
+	return &contractRepo{db.DB}
+}
+
+func (r *contractRepo) CreateContract(contract *models.Contract) error {
+	if err := r.DB.Omit("Licensee").Create(contract).Error; err != nil {
+		return fmt.Errorf("failed to create contract: %w", err)
+	}
+	return nil
+}
+
+// UpdateContract saves new terms for a contract, replacing its titles and payment schedule
+func (r *contractRepo) UpdateContract(contract *models.Contract) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("contract_id = ?", contract.ID).Delete(&models.ContractTitle{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("contract_id = ?", contract.ID).Delete(&models.ContractPayment{}).Error; err != nil {
+			return err
+		}
+		for i := range contract.Titles {
+			contract.Titles[i].ID = 0
+		}
+		for i := range contract.Payments {
+			contract.Payments[i].ID = 0
+		}
+		if err := tx.Omit("Licensee").Session(&gorm.Session{FullSaveAssociations: true}).Save(contract).Error; err != nil {
+			return fmt.Errorf("failed to update contract %d: %w", contract.ID, err)
+		}
+		return nil
+	})
+}
+
+func (r *contractRepo) FindContract(id uint) (*models.Contract, error) {
+	var contract models.Contract
+	err := r.DB.Preload("Licensee").Preload("Titles").
+		Preload("Payments", func(db *gorm.DB) *gorm.DB {
+			return db.Order("due_date, id")
+		}).
+		Where("id = ?", id).First(&contract).Error
+	if err != nil {
+		return nil, err
+	}
+	return &contract, nil
+}
+
+func (r *contractRepo) FindContractByReference(reference string) (*models.Contract, error) {
+	var contract models.Contract
+	if err := r.DB.Where("reference = ?", reference).First(&contract).Error; err != nil {
+		return nil, err
+	}
+	return &contract, nil
+}
+
+// ListContracts returns a page of the contracts matching the filter, latest first, and the total count
+func (r *contractRepo) ListContracts(filter *models.ContractFilter) ([]models.Contract, int64, error) {
+	db := r.DB.Model(&models.Contract{})
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+	if filter.LicenseeID != 0 {
+		db = db.Where("licensee_id = ?", filter.LicenseeID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var contracts []models.Contract
+	err := db.Preload("Licensee").
+		Order("start_date DESC, id DESC").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Find(&contracts).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return contracts, total, nil
+}
+
+func (r *contractRepo) UpdateContractStatus(id uint, status models.ContractStatus) error {
+	return r.DB.Model(&models.Contract{}).Where("id = ?", id).Update("status", status).Error
+}
+
+// MarkPaymentPaid records an unpaid instalment of a contract as paid
+func (r *contractRepo) MarkPaymentPaid(contractID, paymentID uint, at time.Time) error {
+	result := r.DB.Model(&models.ContractPayment{}).
+		Where("id = ? AND contract_id = ? AND paid_at IS NULL", paymentID, contractID).
+		Update("paid_at", at)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
@@ -0,0 +1,442 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CRMRepository interface {
+	CreateContact(contact *models.Contact) error
+	UpdateContact(contact *models.Contact) error
+	DeleteContact(id uint) error
+	FindContact(id uint) (*models.Contact, error)
+	FindContacts(ids []uint) ([]models.Contact, error)
+	ListContacts(filter *models.ContactFilter) ([]models.Contact, int64, error)
+	MergeContacts(target *models.Contact, sourceID uint) error
+	LinkContact(link *models.ContactOrganization) error
+	UnlinkContact(contactID, organizationID uint) error
+	CreateOrganization(organization *models.Organization) error
+	UpdateOrganization(organization *models.Organization) error
+	DeleteOrganization(id uint) error
+	FindOrganization(id uint) (*models.Organization, error)
+	FindOrganizations(ids []uint) ([]models.Organization, error)
+	FindOrganizationByName(name string) (*models.Organization, error)
+	ListOrganizations(filter *models.OrganizationFilter) ([]models.Organization, int64, error)
+	MergeOrganizations(target *models.Organization, sourceID uint) error
+	FindMatches(partyType string, emails, phones []string, excludeID uint) ([]models.DuplicateMatch, error)
+	FindDuplicates(partyType string) ([]models.DuplicateMatch, error)
+}
+
+type crmRepo struct {
+	DB *gorm.DB
+}
+
+func NewCRMRepo(db *GormDB) CRMRepository {
+	return &crmRepo{db.DB}
+}
+
+// channels are the tables holding the emails, phones and addresses of contacts and organizations
+var channels = []interface{}{&models.EmailAddress{}, &models.PhoneNumber{}, &models.PostalAddress{}}
+
+// matchedChannels are the tables duplicates are found by, along with the field they report
+var matchedChannels = []struct{ field, table string }{
+	{"email", "email_addresses"},
+	{"phone", "phone_numbers"},
+}
+
+func deleteChannels(tx *gorm.DB, partyType string, partyID uint) error {
+	for _, channel := range channels {
+		if err := tx.Where("party_type = ? AND party_id = ?", partyType, partyID).Delete(channel).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveChannels hands the emails, phones and addresses of a party over to another one, dropping those
+// the other one has already. What is moved over is never primary, the target keeps its own.
+func moveChannels(tx *gorm.DB, partyType string, sourceID, targetID uint) error {
+	for _, channel := range matchedChannels {
+		table := channel.table
+		err := tx.Exec(`UPDATE `+table+` SET party_id = @target, is_primary = false
+			WHERE party_type = @type AND party_id = @source AND normalized NOT IN
+			(SELECT normalized FROM `+table+` WHERE party_type = @type AND party_id = @target)`,
+			map[string]interface{}{"type": partyType, "source": sourceID, "target": targetID}).Error
+		if err != nil {
+			return fmt.Errorf("failed to move %s to %d: %w", table, targetID, err)
+		}
+	}
+	err := tx.Model(&models.PostalAddress{}).
+		Where("party_type = ? AND party_id = ?", partyType, sourceID).
+		Updates(map[string]interface{}{"party_id": targetID, "is_primary": false}).Error
+	if err != nil {
+		return err
+	}
+	return deleteChannels(tx, partyType, sourceID)
+}
+
+// saveParty saves a contact or organization along with its emails, phones and addresses, which replace
+// the ones it had. Links between contacts and organizations are left alone.
+func saveParty(db *gorm.DB, partyType string, partyID uint, party interface{}, links string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := deleteChannels(tx, partyType, partyID); err != nil {
+			return err
+		}
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Omit(links).Save(party).Error
+	})
+}
+
+func (r *crmRepo) CreateContact(contact *models.Contact) error {
+	if err := r.DB.Omit("Organizations").Create(contact).Error; err != nil {
+		return fmt.Errorf("failed to create contact: %w", err)
+	}
+	return nil
+}
+
+func (r *crmRepo) UpdateContact(contact *models.Contact) error {
+	err := saveParty(r.DB, models.PartyContact, contact.ID, contact, "Organizations")
+	if err != nil {
+		return fmt.Errorf("failed to update contact %d: %w", contact.ID, err)
+	}
+	return nil
+}
+
+// DeleteContact archives a contact and takes them off the organizations they were linked to
+func (r *crmRepo) DeleteContact(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Delete(&models.Contact{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("contact_id = ?", id).Delete(&models.ContactOrganization{}).Error
+	})
+}
+
+func (r *crmRepo) FindContact(id uint) (*models.Contact, error) {
+	var contact models.Contact
+	err := r.DB.Preload("Emails").Preload("Phones").Preload("Addresses").
+		Preload("Organizations.Organization").
+		Where("id = ?", id).First(&contact).Error
+	if err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
+
+func (r *crmRepo) FindContacts(ids []uint) ([]models.Contact, error) {
+	var contacts []models.Contact
+	err := r.DB.Preload("Emails").Preload("Phones").Where("id IN ?", ids).Order("id").Find(&contacts).Error
+	return contacts, err
+}
+
+// phoneQuery is the part of a search that can match phone numbers, the digits without trunk prefix
+func phoneQuery(query string) string {
+	var digits strings.Builder
+	for _, r := range query {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	if strings.TrimLeft(digits.String(), "0") == "" || digits.Len() < 4 {
+		return ""
+	}
+	return "%" + strings.TrimLeft(digits.String(), "0") + "%"
+}
+
+// searchChannels matches a search against the emails and phone numbers of the parties of a table
+func searchChannels(partyType, query string) (string, map[string]interface{}) {
+	args := map[string]interface{}{
+		"type":    partyType,
+		"pattern": "%" + query + "%",
+		"email":   "%" + strings.ToLower(query) + "%",
+		"phone":   phoneQuery(query),
+	}
+	sql := `EXISTS (SELECT 1 FROM email_addresses e WHERE e.party_type = @type AND e.party_id = ` + partyType + `.id AND e.normalized LIKE @email)`
+	if args["phone"] != "" {
+		sql += ` OR EXISTS (SELECT 1 FROM phone_numbers p WHERE p.party_type = @type AND p.party_id = ` + partyType + `.id AND p.normalized LIKE @phone)`
+	}
+	return sql, args
+}
+
+// ListContacts returns a page of the contacts matching filter by name, and the total count
+func (r *crmRepo) ListContacts(filter *models.ContactFilter) ([]models.Contact, int64, error) {
+	db := r.DB.Model(&models.Contact{})
+	if query := strings.TrimSpace(filter.Query); query != "" {
+		channels, args := searchChannels(models.PartyContact, query)
+		db = db.Where(`(first_name || ' ' || last_name) ILIKE @pattern OR job_title ILIKE @pattern OR `+channels, args)
+	}
+	if filter.OwnerID != 0 {
+		db = db.Where("owner_id = ?", filter.OwnerID)
+	}
+	if filter.OrganizationID != 0 {
+		db = db.Where("id IN (SELECT contact_id FROM contact_organizations WHERE organization_id = ?)", filter.OrganizationID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var contacts []models.Contact
+	err := db.Preload("Emails").Preload("Phones").
+		Order("last_name, first_name, id").
+		Limit(filter.Limit).Offset((filter.Page - 1) * filter.Limit).
+		Find(&contacts).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return contacts, total, nil
+}
+
+// MergeContacts folds a contact into target, which is saved as given: their emails, phones, addresses,
+// organizations, deals, invoices and activities move over to target and they are archived
+func (r *crmRepo) MergeContacts(target *models.Contact, sourceID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := moveChannels(tx, models.PartyContact, sourceID, target.ID); err != nil {
+			return err
+		}
+		// Organizations both were linked to keep the link of target
+		err := tx.Exec(`INSERT INTO contact_organizations (contact_id, organization_id, role, created_at)
+			SELECT ?, organization_id, role, created_at FROM contact_organizations WHERE contact_id = ?
+			ON CONFLICT DO NOTHING`, target.ID, sourceID).Error
+		if err != nil {
+			return fmt.Errorf("failed to move organizations to contact %d: %w", target.ID, err)
+		}
+		if err := tx.Where("contact_id = ?", sourceID).Delete(&models.ContactOrganization{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Deal{}).Where("contact_id = ?", sourceID).Update("contact_id", target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Invoice{}).Where("contact_id = ?", sourceID).Update("contact_id", target.ID).Error; err != nil {
+			return err
+		}
+		err = tx.Model(&models.Activity{}).Where("subject_type = ? AND subject_id = ?", models.PartyContact, sourceID).
+			Update("subject_id", target.ID).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Save(target).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", sourceID).Delete(&models.Contact{}).Error
+	})
+}
+
+// LinkContact links a contact to an organization, or changes their role there when they are linked already
+func (r *crmRepo) LinkContact(link *models.ContactOrganization) error {
+	return r.DB.Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "contact_id"}, {Name: "organization_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role"}),
+	}).Create(link).Error
+}
+
+func (r *crmRepo) UnlinkContact(contactID, organizationID uint) error {
+	result := r.DB.Where("contact_id = ? AND organization_id = ?", contactID, organizationID).Delete(&models.ContactOrganization{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func (r *crmRepo) CreateOrganization(organization *models.Organization) error {
+	if err := r.DB.Omit("Contacts").Create(organization).Error; err != nil {
+		return fmt.Errorf("failed to create organization: %w", err)
+	}
+	return nil
+}
+
+func (r *crmRepo) UpdateOrganization(organization *models.Organization) error {
+	err := saveParty(r.DB, models.PartyOrganization, organization.ID, organization, "Contacts")
+	if err != nil {
+		return fmt.Errorf("failed to update organization %d: %w", organization.ID, err)
+	}
+	return nil
+}
+
+// DeleteOrganization archives an organization and unlinks its contacts
+func (r *crmRepo) DeleteOrganization(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Delete(&models.Organization{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("organization_id = ?", id).Delete(&models.ContactOrganization{}).Error
+	})
+}
+
+func (r *crmRepo) FindOrganization(id uint) (*models.Organization, error) {
+	var organization models.Organization
+	err := r.DB.Preload("Emails").Preload("Phones").Preload("Addresses").
+		Preload("Contacts", func(db *gorm.DB) *gorm.DB {
+			return db.Joins("Contact").Where(`"Contact".id IS NOT NULL`).Order(`"Contact".last_name, "Contact".first_name`)
+		}).
+		Where("id = ?", id).First(&organization).Error
+	if err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+func (r *crmRepo) FindOrganizations(ids []uint) ([]models.Organization, error) {
+	var organizations []models.Organization
+	err := r.DB.Preload("Emails").Preload("Phones").Where("id IN ?", ids).Order("id").Find(&organizations).Error
+	return organizations, err
+}
+
+func (r *crmRepo) FindOrganizationByName(name string) (*models.Organization, error) {
+	var organization models.Organization
+	if err := r.DB.Where("LOWER(name) = LOWER(?)", name).First(&organization).Error; err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+// ListOrganizations returns a page of the organizations matching filter by name, and the total count
+func (r *crmRepo) ListOrganizations(filter *models.OrganizationFilter) ([]models.Organization, int64, error) {
+	db := r.DB.Model(&models.Organization{})
+	if query := strings.TrimSpace(filter.Query); query != "" {
+		channels, args := searchChannels(models.PartyOrganization, query)
+		db = db.Where(`name ILIKE @pattern OR `+channels, args)
+	}
+	if filter.Kind != "" {
+		db = db.Where("kind = ?", filter.Kind)
+	}
+	if filter.OwnerID != 0 {
+		db = db.Where("owner_id = ?", filter.OwnerID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var organizations []models.Organization
+	err := db.Preload("Emails").Preload("Phones").
+		Order("name, id").
+		Limit(filter.Limit).Offset((filter.Page - 1) * filter.Limit).
+		Find(&organizations).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return organizations, total, nil
+}
+
+// MergeOrganizations folds an organization into target, which is saved as given: its emails, phones,
+// addresses, contacts, deals, invoices and activities move over to target and it is archived
+func (r *crmRepo) MergeOrganizations(target *models.Organization, sourceID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := moveChannels(tx, models.PartyOrganization, sourceID, target.ID); err != nil {
+			return err
+		}
+		err := tx.Exec(`INSERT INTO contact_organizations (contact_id, organization_id, role, created_at)
+			SELECT contact_id, ?, role, created_at FROM contact_organizations WHERE organization_id = ?
+			ON CONFLICT DO NOTHING`, target.ID, sourceID).Error
+		if err != nil {
+			return fmt.Errorf("failed to move contacts to organization %d: %w", target.ID, err)
+		}
+		if err := tx.Where("organization_id = ?", sourceID).Delete(&models.ContactOrganization{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Deal{}).Where("organization_id = ?", sourceID).Update("organization_id", target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Invoice{}).Where("organization_id = ?", sourceID).Update("organization_id", target.ID).Error; err != nil {
+			return err
+		}
+		err = tx.Model(&models.Activity{}).Where("subject_type = ? AND subject_id = ?", models.PartyOrganization, sourceID).
+			Update("subject_id", target.ID).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Save(target).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", sourceID).Delete(&models.Organization{}).Error
+	})
+}
+
+// duplicateRow is an email, phone or name a party shares with another one
+type duplicateRow struct {
+	Field   string
+	Value   string
+	PartyID uint
+}
+
+// groupDuplicates gathers the parties sharing each value, rows come sorted by field and value
+func groupDuplicates(rows []duplicateRow) []models.DuplicateMatch {
+	var matches []models.DuplicateMatch
+	for _, row := range rows {
+		last := len(matches) - 1
+		if last < 0 || matches[last].Field != row.Field || matches[last].Value != row.Value {
+			matches = append(matches, models.DuplicateMatch{Field: row.Field, Value: row.Value})
+			last++
+		}
+		matches[last].PartyIDs = append(matches[last].PartyIDs, row.PartyID)
+	}
+	return matches
+}
+
+// FindMatches returns the live parties other than excludeID having any of the normalized emails or phones
+func (r *crmRepo) FindMatches(partyType string, emails, phones []string, excludeID uint) ([]models.DuplicateMatch, error) {
+	var rows []duplicateRow
+	for i, values := range [][]string{emails, phones} {
+		if len(values) == 0 {
+			continue
+		}
+		var found []duplicateRow
+		err := r.DB.Table(matchedChannels[i].table+" c").
+			Select("? AS field, c.normalized AS value, c.party_id", matchedChannels[i].field).
+			Joins("JOIN "+partyType+" p ON p.id = c.party_id AND p.deleted_at IS NULL").
+			Where("c.party_type = ? AND c.normalized IN ? AND c.party_id <> ?", partyType, values, excludeID).
+			Order("value, c.party_id").
+			Scan(&found).Error
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, found...)
+	}
+	return groupDuplicates(rows), nil
+}
+
+// FindDuplicates returns every email and phone shared by several live parties of a table, and for
+// organizations the names they share
+func (r *crmRepo) FindDuplicates(partyType string) ([]models.DuplicateMatch, error) {
+	var rows []duplicateRow
+	for _, channel := range matchedChannels {
+		table := channel.table
+		var found []duplicateRow
+		err := r.DB.Raw(`SELECT @field AS field, c.normalized AS value, c.party_id
+			FROM `+table+` c JOIN `+partyType+` p ON p.id = c.party_id AND p.deleted_at IS NULL
+			WHERE c.party_type = @type AND c.normalized IN
+				(SELECT d.normalized FROM `+table+` d JOIN `+partyType+` q ON q.id = d.party_id AND q.deleted_at IS NULL
+				WHERE d.party_type = @type GROUP BY d.normalized HAVING COUNT(DISTINCT d.party_id) > 1)
+			GROUP BY c.normalized, c.party_id
+			ORDER BY value, c.party_id`, map[string]interface{}{"type": partyType, "field": channel.field}).Scan(&found).Error
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, found...)
+	}
+	if partyType == models.PartyOrganization {
+		var found []duplicateRow
+		err := r.DB.Raw(`SELECT 'name' AS field, LOWER(TRIM(name)) AS value, id AS party_id FROM organizations
+			WHERE deleted_at IS NULL AND LOWER(TRIM(name)) IN
+				(SELECT LOWER(TRIM(name)) FROM organizations WHERE deleted_at IS NULL
+				GROUP BY LOWER(TRIM(name)) HAVING COUNT(*) > 1)
+			ORDER BY value, id`).Scan(&found).Error
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, found...)
+	}
+	return groupDuplicates(rows), nil
+}
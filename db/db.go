@@ -4,9 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"github.com/techagentng/telair-erp/config"
+	apiError "github.com/techagentng/telair-erp/errors"
 	"github.com/techagentng/telair-erp/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -26,7 +29,7 @@ func GetDB(c *config.Config) *GormDB {
 func (g *GormDB) Init(c *config.Config) {
 	g.DB = getPostgresDB(c)
 
-	if err := migrate(g.DB); err != nil {
+	if err := Migrate(g.DB); err != nil {
 		log.Fatalf("unable to run migrations: %v", err)
 	}
 }
@@ -66,19 +69,324 @@ func SeedRoles(db *gorm.DB) error {
     return nil
 }
 
-func migrate(db *gorm.DB) error {
+// SeedTaxRates adds Nigerian VAT to the tax rates invoices can charge, unless it was added, or deleted, before
+func SeedTaxRates(db *gorm.DB) error {
+	vat := models.TaxRate{Name: "VAT", Percent: decimal.RequireFromString("7.5"), Description: "Nigerian value added tax"}
+	return db.Unscoped().Where("name = ?", vat.Name).Attrs(vat).FirstOrCreate(&models.TaxRate{}).Error
+}
+
+// chartOfAccounts is the chart of accounts the ledger starts with, including the accounts it posts to
+var chartOfAccounts = []models.Account{
+	{Code: "1000", Name: "Cash at bank", Type: models.AccountAsset, Purpose: models.PurposeBank},
+	{Code: "1100", Name: "Accounts receivable", Type: models.AccountAsset, Purpose: models.PurposeReceivable},
+	{Code: "2000", Name: "Accounts payable", Type: models.AccountLiability, Purpose: models.PurposePayable},
+	{Code: "2100", Name: "VAT payable", Type: models.AccountLiability, Purpose: models.PurposeTaxPayable},
+	{Code: "3000", Name: "Owner's equity", Type: models.AccountEquity},
+	{Code: "4000", Name: "Licensing and sales revenue", Type: models.AccountIncome, Purpose: models.PurposeRevenue},
+	{Code: "4900", Name: "Exchange gains and losses", Type: models.AccountIncome, Purpose: models.PurposeExchange},
+	{Code: "5100", Name: "Above-the-line costs", Type: models.AccountExpense, Purpose: models.CostPurpose(models.AboveTheLine)},
+	{Code: "5200", Name: "Production costs", Type: models.AccountExpense, Purpose: models.CostPurpose(models.Production)},
+	{Code: "5300", Name: "Post-production costs", Type: models.AccountExpense, Purpose: models.CostPurpose(models.PostProduction)},
+	{Code: "5400", Name: "Marketing costs", Type: models.AccountExpense, Purpose: models.CostPurpose(models.Marketing)},
+}
+
+// SeedAccounts adds the accounts of the starting chart of accounts missing by code
+func SeedAccounts(db *gorm.DB) error {
+	for _, account := range chartOfAccounts {
+		err := db.Where("code = ?", account.Code).Attrs(account).FirstOrCreate(&models.Account{}).Error
+		if err != nil {
+			return fmt.Errorf("failed to seed account %s: %w", account.Code, err)
+		}
+	}
+	return nil
+}
+
+// approvalLevels are the levels of approval purchase orders start with, in the reporting currency: any
+// colleague approves an order, an admin those over 500,000 and a second admin those over 5,000,000
+var approvalLevels = []models.PurchaseApprovalLevel{
+	{Level: 1, MinAmount: decimal.Zero, Role: models.RoleUser},
+	{Level: 2, MinAmount: decimal.NewFromInt(500000), Role: models.RoleAdmin},
+	{Level: 3, MinAmount: decimal.NewFromInt(5000000), Role: models.RoleAdmin},
+}
+
+// SeedApprovalLevels sets the starting levels of approval of purchase orders unless some were set before
+func SeedApprovalLevels(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&models.PurchaseApprovalLevel{}).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+	levels := append([]models.PurchaseApprovalLevel(nil), approvalLevels...)
+	return db.Create(&levels).Error
+}
+
+// Migrate brings the schema up to date, it is safe to run on every start
+func Migrate(db *gorm.DB) error {
+	if err := migrateSoftDelete(db); err != nil {
+		return fmt.Errorf("migrations error: %v", err)
+	}
+
 	// AutoMigrate all the models
 	err := db.AutoMigrate(
 		&models.User{},
 		&models.Trailer{},
 		&models.Role{}, 
+		&models.FullLength{},
+		&models.MediaAsset{},
+		&models.TusUpload{},
+		&models.TusUploadPart{},
+		&models.DirectUpload{},
+		&models.DirectUploadPart{},
+		&models.UploadProgress{},
+		&models.Person{},
+		&models.Credit{},
+		&models.TaxonomyTerm{},
+		&models.TitleTerm{},
+		&models.Revision{},
+		&models.Subtitle{},
+		&models.Licensee{},
+		&models.RightsGrant{},
+		&models.RightsWindow{},
+		&models.Contract{},
+		&models.ContractTitle{},
+		&models.ContractPayment{},
+		&models.RevenueReport{},
+		&models.RevenueLine{},
+		&models.RoyaltyStatement{},
+		&models.RoyaltyStatementLine{},
+		&models.Vendor{},
+		&models.ExchangeRate{},
+		&models.Budget{},
+		&models.BudgetLine{},
+		&models.CostEntry{},
+		&models.ReleasePlan{},
+		&models.ReleaseMilestone{},
+		&models.Festival{},
+		&models.FestivalSubmission{},
+		&models.CalendarFeed{},
+		&models.PressKitAsset{},
+		&models.Contact{},
+		&models.Organization{},
+		&models.ContactOrganization{},
+		&models.EmailAddress{},
+		&models.PhoneNumber{},
+		&models.PostalAddress{},
+		&models.Pipeline{},
+		&models.PipelineStage{},
+		&models.Deal{},
+		&models.DealTitle{},
+		&models.DealStageChange{},
+		&models.Activity{},
+		&models.Sequence{},
+		&models.TaxRate{},
+		&models.Invoice{},
+		&models.InvoiceLine{},
+		&models.InvoicePayment{},
+		&models.Account{},
+		&models.JournalEntry{},
+		&models.JournalLine{},
+		&models.AccountingPeriod{},
+		&models.PurchaseOrder{},
+		&models.PurchaseOrderLine{},
+		&models.PurchaseOrderApproval{},
+		&models.PurchaseApprovalLevel{},
+		&models.GoodsReceipt{},
+		&models.GoodsReceiptLine{},
+		&models.VendorBill{},
+		&models.VendorBillLine{},
 	)
 	if err != nil {
 		return fmt.Errorf("migrations error: %v", err)
 	}
 
 	// Add any additional migrations here if needed
+	if err := migrateStarCredits(db); err != nil {
+		return fmt.Errorf("migrations error: %v", err)
+	}
+	if err := migrateSearch(db); err != nil {
+		return fmt.Errorf("migrations error: %v", err)
+	}
+	if err := migrateLedger(db); err != nil {
+		return fmt.Errorf("migrations error: %v", err)
+	}
+
+	return nil
+}
+
+// migrateSoftDelete turns the old deleted_at unix timestamps into the nullable timestamps GORM soft
+// deletes with, zero meaning not deleted. It runs before AutoMigrate, which cannot cast the column.
+func migrateSoftDelete(db *gorm.DB) error {
+	var tables []string
+	err := db.Raw(`SELECT table_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND column_name = 'deleted_at' AND data_type = 'bigint'`).Scan(&tables).Error
+	if err != nil {
+		return err
+	}
 
+	for _, table := range tables {
+		err := db.Exec(fmt.Sprintf(`ALTER TABLE %q ALTER COLUMN deleted_at TYPE timestamptz
+			USING CASE WHEN deleted_at > 0 THEN to_timestamp(deleted_at) END`, table)).Error
+		if err != nil {
+			return fmt.Errorf("failed to convert %s.deleted_at: %w", table, err)
+		}
+		log.Printf("Converted %s.deleted_at to a timestamp", table)
+	}
+	return nil
+}
+
+// migrateStarCredits turns the old star1, star2 and star3 columns of trailers into actor credits
+// and drops them. It does nothing once the columns are gone.
+func migrateStarCredits(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Trailer{}, "star1") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var rows []struct {
+			ID    uint
+			Star1 string
+			Star2 string
+			Star3 string
+		}
+		if err := tx.Table("trailers").Select("id, star1, star2, star3").Scan(&rows).Error; err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			for i, name := range []string{row.Star1, row.Star2, row.Star3} {
+				name = strings.TrimSpace(name)
+				if name == "" {
+					continue
+				}
+				person, err := findOrCreatePerson(tx, name)
+				if err != nil {
+					return err
+				}
+				credit := models.Credit{
+					PersonID:     person.ID,
+					TitleType:    models.TitleTrailer,
+					TitleID:      row.ID,
+					Role:         models.CreditActor,
+					BillingOrder: i + 1,
+				}
+				if err := tx.Create(&credit).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, column := range []string{"star1", "star2", "star3"} {
+			if err := tx.Migrator().DropColumn(&models.Trailer{}, column); err != nil {
+				return err
+			}
+		}
+		log.Printf("Migrated stars of %d trailers to credits", len(rows))
+		return nil
+	})
+}
+
+// searchDocuments describes the search vector of each searchable table. The document is a format
+// string taking the row reference, "NEW." in the trigger and nothing when backfilling.
+var searchDocuments = []struct {
+	table    string
+	name     string // column matched by trigram similarity
+	document string
+}{
+	{"trailers", "title", "setweight(to_tsvector('english', coalesce(%[1]stitle, '')), 'A') || " +
+		"setweight(to_tsvector('english', coalesce(%[1]slog_line, '')), 'B') || " +
+		"setweight(to_tsvector('english', coalesce(%[1]sdescription, '')), 'C')"},
+	{"full_lengths", "title", "setweight(to_tsvector('english', coalesce(%[1]stitle, '')), 'A') || " +
+		"setweight(to_tsvector('english', coalesce(%[1]sdescription, '')), 'C')"},
+	{"people", "name", "setweight(to_tsvector('english', coalesce(%[1]sname, '')), 'A') || " +
+		"setweight(to_tsvector('english', coalesce(%[1]saliases, '')), 'B') || " +
+		"setweight(to_tsvector('english', coalesce(%[1]sbio, '')), 'C')"},
+}
+
+// migrateSearch adds the weighted search vectors, the triggers keeping them in sync and the
+// full-text and trigram indexes. It is safe to run on every start.
+func migrateSearch(db *gorm.DB) error {
+	statements := []string{
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+		"CREATE INDEX IF NOT EXISTS idx_taxonomy_terms_name_trgm ON taxonomy_terms USING GIN (name gin_trgm_ops)",
+	}
+	for _, doc := range searchDocuments {
+		statements = append(statements,
+			fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS search_vector tsvector", doc.table),
+			fmt.Sprintf(`CREATE OR REPLACE FUNCTION %s_search_vector() RETURNS trigger AS $$
+BEGIN
+	NEW.search_vector := %s;
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql`, doc.table, fmt.Sprintf(doc.document, "NEW.")),
+			fmt.Sprintf("DROP TRIGGER IF EXISTS %[1]s_search_vector ON %[1]s", doc.table),
+			fmt.Sprintf("CREATE TRIGGER %[1]s_search_vector BEFORE INSERT OR UPDATE ON %[1]s FOR EACH ROW EXECUTE FUNCTION %[1]s_search_vector()", doc.table),
+			fmt.Sprintf("UPDATE %s SET search_vector = %s WHERE search_vector IS NULL", doc.table, fmt.Sprintf(doc.document, "")),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%[1]s_search_vector ON %[1]s USING GIN (search_vector)", doc.table),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%[1]s_%[2]s_trgm ON %[1]s USING GIN (%[2]s gin_trgm_ops)", doc.table, doc.name),
+		)
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to set up search: %w", err)
+		}
+	}
+	return nil
+}
+
+// migrateLedger adds the triggers keeping the books straight whatever writes to them: journal entries are
+// created as drafts, a draft is only posted when its lines balance, and posted entries and their lines
+// never change. It is safe to run on every start.
+func migrateLedger(db *gorm.DB) error {
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION journal_entries_guard() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'INSERT' THEN
+		IF NEW.status = 'posted' THEN
+			RAISE EXCEPTION 'journal entries are created as drafts and posted afterwards';
+		END IF;
+		RETURN NEW;
+	END IF;
+	IF OLD.status = 'posted' THEN
+		RAISE EXCEPTION 'journal entry % is posted and cannot change', OLD.number;
+	END IF;
+	IF TG_OP = 'DELETE' THEN
+		RETURN OLD;
+	END IF;
+	IF NEW.status = 'posted' AND NOT EXISTS (
+		SELECT 1 FROM journal_lines WHERE entry_id = NEW.id
+		HAVING SUM(debit) = SUM(credit) AND SUM(debit) > 0 AND bool_and(debit >= 0 AND credit >= 0)) THEN
+		RAISE EXCEPTION 'journal entry % does not balance', NEW.id;
+	END IF;
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql`,
+		"DROP TRIGGER IF EXISTS journal_entries_guard ON journal_entries",
+		"CREATE TRIGGER journal_entries_guard BEFORE INSERT OR UPDATE OR DELETE ON journal_entries FOR EACH ROW EXECUTE FUNCTION journal_entries_guard()",
+		`CREATE OR REPLACE FUNCTION journal_lines_guard() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP <> 'INSERT' THEN
+		IF EXISTS (SELECT 1 FROM journal_entries WHERE id = OLD.entry_id AND status = 'posted') THEN
+			RAISE EXCEPTION 'journal entry % is posted, its lines cannot change', OLD.entry_id;
+		END IF;
+	END IF;
+	IF TG_OP = 'DELETE' THEN
+		RETURN OLD;
+	END IF;
+	IF EXISTS (SELECT 1 FROM journal_entries WHERE id = NEW.entry_id AND status = 'posted') THEN
+		RAISE EXCEPTION 'journal entry % is posted, its lines cannot change', NEW.entry_id;
+	END IF;
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql`,
+		"DROP TRIGGER IF EXISTS journal_lines_guard ON journal_lines",
+		"CREATE TRIGGER journal_lines_guard BEFORE INSERT OR UPDATE OR DELETE ON journal_lines FOR EACH ROW EXECUTE FUNCTION journal_lines_guard()",
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to set up the ledger: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -101,3 +409,16 @@ func seedRoles(db *gorm.DB) error {
     }
     return nil
 }
+
+// versionConflict works out why a versioned write touched no row: it returns gorm.ErrRecordNotFound
+// when the row is gone and a *ConflictError when it is at another version
+func versionConflict(model *gorm.DB, resource string, id uint, version int) error {
+	var count int64
+	if err := model.Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return &apiError.ConflictError{Resource: resource, ID: id, Version: version}
+}
@@ -0,0 +1,314 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DealRepository interface {
+	CreatePipeline(pipeline *models.Pipeline) error
+	UpdatePipeline(pipeline *models.Pipeline, removedStageIDs []uint) error
+	DeletePipeline(id uint) error
+	FindPipeline(id uint) (*models.Pipeline, error)
+	FindPipelineByName(name string) (*models.Pipeline, error)
+	ListPipelines() ([]models.Pipeline, error)
+	FindStage(id uint) (*models.PipelineStage, error)
+	CountDeals(pipelineID, stageID uint, status models.DealStatus) (int64, error)
+	CreateDeal(deal *models.Deal, change *models.DealStageChange) error
+	UpdateDeal(deal *models.Deal) error
+	SaveDealChange(deal *models.Deal, change *models.DealStageChange) error
+	DeleteDeal(id uint) error
+	FindDeal(id uint) (*models.Deal, error)
+	ListDeals(filter *models.DealFilter) ([]models.Deal, int64, error)
+	FindStageTotals(filter *models.DealReportFilter) ([]models.StageTotal, error)
+	FindDealProgress(filter *models.DealReportFilter) ([]models.DealProgress, error)
+	FindStageChanges(filter *models.DealReportFilter) ([]models.DealStageChange, error)
+	FindDealEvents(subjectType string, subjectID uint, limit int) ([]models.DealEvent, error)
+}
+
+type dealRepo struct {
+	DB *gorm.DB
+}
+
+func NewDealRepo(db *GormDB) DealRepository {
+	return &dealRepo{db.DB}
+}
+
+func (r *dealRepo) CreatePipeline(pipeline *models.Pipeline) error {
+	if err := r.DB.Create(pipeline).Error; err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+	return nil
+}
+
+// UpdatePipeline saves a pipeline and its stages, stages removed from it are archived so that the
+// history of the deals that went through them stays readable
+func (r *dealRepo) UpdatePipeline(pipeline *models.Pipeline, removedStageIDs []uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if len(removedStageIDs) > 0 {
+			if err := tx.Where("id IN ?", removedStageIDs).Delete(&models.PipelineStage{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(pipeline).Error; err != nil {
+			return fmt.Errorf("failed to update pipeline %d: %w", pipeline.ID, err)
+		}
+		return nil
+	})
+}
+
+// DeletePipeline removes a pipeline without deals for good, freeing its name
+func (r *dealRepo) DeletePipeline(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("pipeline_id = ?", id).Delete(&models.PipelineStage{}).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("id = ?", id).Delete(&models.Pipeline{})
+		if result.Error == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return result.Error
+	})
+}
+
+func orderedStages(db *gorm.DB) *gorm.DB {
+	return db.Order("position, id")
+}
+
+func (r *dealRepo) FindPipeline(id uint) (*models.Pipeline, error) {
+	var pipeline models.Pipeline
+	if err := r.DB.Preload("Stages", orderedStages).Where("id = ?", id).First(&pipeline).Error; err != nil {
+		return nil, err
+	}
+	return &pipeline, nil
+}
+
+func (r *dealRepo) FindPipelineByName(name string) (*models.Pipeline, error) {
+	var pipeline models.Pipeline
+	if err := r.DB.Where("LOWER(name) = LOWER(?)", name).First(&pipeline).Error; err != nil {
+		return nil, err
+	}
+	return &pipeline, nil
+}
+
+func (r *dealRepo) ListPipelines() ([]models.Pipeline, error) {
+	var pipelines []models.Pipeline
+	err := r.DB.Preload("Stages", orderedStages).Order("name").Find(&pipelines).Error
+	return pipelines, err
+}
+
+func (r *dealRepo) FindStage(id uint) (*models.PipelineStage, error) {
+	var stage models.PipelineStage
+	if err := r.DB.Where("id = ?", id).First(&stage).Error; err != nil {
+		return nil, err
+	}
+	return &stage, nil
+}
+
+// CountDeals counts the deals of a pipeline, or of one of its stages, in a status when one is given
+func (r *dealRepo) CountDeals(pipelineID, stageID uint, status models.DealStatus) (int64, error) {
+	db := r.DB.Model(&models.Deal{})
+	if pipelineID != 0 {
+		db = db.Where("pipeline_id = ?", pipelineID)
+	}
+	if stageID != 0 {
+		db = db.Where("stage_id = ?", stageID)
+	}
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	var count int64
+	err := db.Count(&count).Error
+	return count, err
+}
+
+// dealAssociations are left alone when saving a deal, titles are saved with it
+var dealAssociations = []string{"Stage", "Contact", "Organization", "History"}
+
+// CreateDeal saves a new deal along with the change recording it entering its first stage
+func (r *dealRepo) CreateDeal(deal *models.Deal, change *models.DealStageChange) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(dealAssociations...).Create(deal).Error; err != nil {
+			return fmt.Errorf("failed to create deal: %w", err)
+		}
+		change.DealID = deal.ID
+		return tx.Create(change).Error
+	})
+}
+
+// UpdateDeal saves the details of a deal, replacing its titles
+func (r *dealRepo) UpdateDeal(deal *models.Deal) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("deal_id = ?", deal.ID).Delete(&models.DealTitle{}).Error; err != nil {
+			return err
+		}
+		for i := range deal.Titles {
+			deal.Titles[i].ID = 0
+		}
+		if err := tx.Omit(dealAssociations...).Session(&gorm.Session{FullSaveAssociations: true}).Save(deal).Error; err != nil {
+			return fmt.Errorf("failed to update deal %d: %w", deal.ID, err)
+		}
+		return nil
+	})
+}
+
+// SaveDealChange saves a deal that changed stage or status along with the change
+func (r *dealRepo) SaveDealChange(deal *models.Deal, change *models.DealStageChange) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(deal).Error; err != nil {
+			return fmt.Errorf("failed to update deal %d: %w", deal.ID, err)
+		}
+		change.DealID = deal.ID
+		return tx.Create(change).Error
+	})
+}
+
+func (r *dealRepo) DeleteDeal(id uint) error {
+	result := r.DB.Where("id = ?", id).Delete(&models.Deal{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func (r *dealRepo) FindDeal(id uint) (*models.Deal, error) {
+	var deal models.Deal
+	err := r.DB.Preload("Titles").Preload("Stage", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).Preload("Contact").Preload("Organization").
+		Preload("History", func(db *gorm.DB) *gorm.DB {
+			return db.Order("changed_at, id")
+		}).
+		Where("id = ?", id).First(&deal).Error
+	if err != nil {
+		return nil, err
+	}
+	return &deal, nil
+}
+
+// ListDeals returns a page of the deals matching the filter, those expected to close first first, and the total count
+func (r *dealRepo) ListDeals(filter *models.DealFilter) ([]models.Deal, int64, error) {
+	db := r.DB.Model(&models.Deal{})
+	if query := strings.TrimSpace(filter.Query); query != "" {
+		db = db.Where("name ILIKE ?", "%"+query+"%")
+	}
+	if filter.PipelineID != 0 {
+		db = db.Where("pipeline_id = ?", filter.PipelineID)
+	}
+	if filter.StageID != 0 {
+		db = db.Where("stage_id = ?", filter.StageID)
+	}
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+	if filter.OwnerID != 0 {
+		db = db.Where("owner_id = ?", filter.OwnerID)
+	}
+	if filter.ContactID != 0 {
+		db = db.Where("contact_id = ?", filter.ContactID)
+	}
+	if filter.OrganizationID != 0 {
+		db = db.Where("organization_id = ?", filter.OrganizationID)
+	}
+	if filter.TitleType != "" {
+		db = db.Where("id IN (SELECT deal_id FROM deal_titles WHERE title_type = ? AND title_id = ?)", filter.TitleType, filter.TitleID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var deals []models.Deal
+	err := db.Preload("Titles").Preload("Stage", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).
+		Order("expected_close_date NULLS LAST, id DESC").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Find(&deals).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return deals, total, nil
+}
+
+// reportDeals narrows deals d down to those of a report
+func reportDeals(db *gorm.DB, filter *models.DealReportFilter) *gorm.DB {
+	db = db.Where("d.pipeline_id = ? AND d.deleted_at IS NULL", filter.PipelineID)
+	if filter.OwnerID != 0 {
+		db = db.Where("d.owner_id = ?", filter.OwnerID)
+	}
+	if filter.From != nil {
+		db = db.Where("d.created_at >= ?", filter.From.Unix())
+	}
+	if filter.To != nil {
+		db = db.Where("d.created_at < ?", filter.To.AddDate(0, 0, 1).Unix())
+	}
+	return db
+}
+
+// FindStageTotals adds up the open deals of a pipeline by stage and currency
+func (r *dealRepo) FindStageTotals(filter *models.DealReportFilter) ([]models.StageTotal, error) {
+	var totals []models.StageTotal
+	err := reportDeals(r.DB.Table("deals d"), filter).
+		Select("d.stage_id, d.currency, COUNT(*) AS deals, SUM(d.value) AS total").
+		Where("d.status = ?", models.DealOpen).
+		Group("d.stage_id, d.currency").
+		Scan(&totals).Error
+	return totals, err
+}
+
+// FindDealProgress returns how far every deal of a pipeline got, going by the stages it entered
+func (r *dealRepo) FindDealProgress(filter *models.DealReportFilter) ([]models.DealProgress, error) {
+	var progress []models.DealProgress
+	err := reportDeals(r.DB.Table("deals d"), filter).
+		Select("d.id AS deal_id, d.status, MAX(s.position) AS position").
+		Joins("JOIN deal_stage_changes h ON h.deal_id = d.id").
+		Joins("JOIN pipeline_stages s ON s.id = h.to_stage_id AND s.pipeline_id = d.pipeline_id AND s.deleted_at IS NULL").
+		Group("d.id, d.status").
+		Scan(&progress).Error
+	return progress, err
+}
+
+// FindStageChanges returns the stage changes of the deals of a pipeline, deal by deal in the order they happened
+func (r *dealRepo) FindStageChanges(filter *models.DealReportFilter) ([]models.DealStageChange, error) {
+	var changes []models.DealStageChange
+	err := reportDeals(r.DB.Table("deal_stage_changes h"), filter).
+		Select("h.*").
+		Joins("JOIN deals d ON d.id = h.deal_id").
+		Order("h.deal_id, h.changed_at, h.id").
+		Scan(&changes).Error
+	return changes, err
+}
+
+// FindDealEvents returns the latest stage changes of a deal, or of the deals of a contact or organization,
+// most recent first
+func (r *dealRepo) FindDealEvents(subjectType string, subjectID uint, limit int) ([]models.DealEvent, error) {
+	var column string
+	switch subjectType {
+	case models.SubjectDeal:
+		column = "d.id"
+	case models.PartyContact:
+		column = "d.contact_id"
+	case models.PartyOrganization:
+		column = "d.organization_id"
+	default:
+		return nil, fmt.Errorf("deals have no %s", subjectType)
+	}
+
+	var events []models.DealEvent
+	err := r.DB.Table("deal_stage_changes h").
+		Select("h.*, d.name AS deal_name, f.name AS from_stage, t.name AS to_stage").
+		Joins("JOIN deals d ON d.id = h.deal_id AND d.deleted_at IS NULL").
+		Joins("LEFT JOIN pipeline_stages f ON f.id = h.from_stage_id").
+		Joins("JOIN pipeline_stages t ON t.id = h.to_stage_id").
+		Where(column+" = ?", subjectID).
+		Order("h.changed_at DESC, h.id DESC").
+		Limit(limit).
+		Scan(&events).Error
+	return events, err
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"time"
+
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ExchangeRateRepository interface {
+	SaveRate(rate *models.ExchangeRate) error
+	ListRates(base, quote string, limit, offset int) ([]models.ExchangeRate, int64, error)
+	FindRate(from, to string, on time.Time) (*models.ExchangeRate, error)
+}
+
+type exchangeRateRepo struct {
+	DB *gorm.DB
+}
+
+func NewExchangeRateRepo(db *GormDB) ExchangeRateRepository {
+	return &exchangeRateRepo{db.DB}
+}
+
+// SaveRate stores a rate, replacing the one of the same pair and day
+func (r *exchangeRateRepo) SaveRate(rate *models.ExchangeRate) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "base"}, {Name: "quote"}, {Name: "effective_on"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "created_by", "created_at"}),
+	}).Create(rate).Error
+}
+
+// ListRates returns a page of the stored rates, latest first, optionally of a single currency on either side
+func (r *exchangeRateRepo) ListRates(base, quote string, limit, offset int) ([]models.ExchangeRate, int64, error) {
+	db := r.DB.Model(&models.ExchangeRate{})
+	if base != "" {
+		db = db.Where("base = ?", base)
+	}
+	if quote != "" {
+		db = db.Where("quote = ?", quote)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rates []models.ExchangeRate
+	if err := db.Order("effective_on DESC, base, quote").Limit(limit).Offset(offset).Find(&rates).Error; err != nil {
+		return nil, 0, err
+	}
+	return rates, total, nil
+}
+
+// FindRate returns the latest rate between two currencies effective on a day, stored either way round
+func (r *exchangeRateRepo) FindRate(from, to string, on time.Time) (*models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	err := r.DB.Where("((base = ? AND quote = ?) OR (base = ? AND quote = ?)) AND effective_on <= ?", from, to, to, from, on).
+		Order("effective_on DESC").
+		First(&rate).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
@@ -0,0 +1,336 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type InvoiceRepository interface {
+	CreateTaxRate(rate *models.TaxRate) error
+	UpdateTaxRate(rate *models.TaxRate) error
+	DeleteTaxRate(id uint) error
+	FindTaxRate(id uint) (*models.TaxRate, error)
+	FindTaxRateByName(name string) (*models.TaxRate, error)
+	ListTaxRates() ([]models.TaxRate, error)
+	CreateInvoice(invoice *models.Invoice) error
+	UpdateInvoice(invoice *models.Invoice) error
+	MarkInvoiceSent(id uint, to string, at time.Time) error
+	DeleteInvoice(id uint) error
+	FindInvoice(id uint) (*models.Invoice, error)
+	ListInvoices(filter *models.InvoiceFilter) ([]models.Invoice, int64, error)
+	IssueInvoice(invoice *models.Invoice, prefix string, entry *models.JournalEntry) error
+	VoidInvoice(id uint, reason string, at time.Time, userID uint) error
+	RecordPayment(payment *models.InvoicePayment, entry *models.JournalEntry) (*models.Invoice, error)
+	DeletePayment(invoiceID, paymentID, userID uint) error
+	FindInvoiceBalances(asOf time.Time) ([]models.InvoiceBalance, error)
+}
+
+type invoiceRepo struct {
+	DB *gorm.DB
+}
+
+func NewInvoiceRepo(db *GormDB) InvoiceRepository {
+	return &invoiceRepo{db.DB}
+}
+
+// invoiceAssociations are loaded with an invoice but never saved through it
+var invoiceAssociations = []string{"Organization", "Contact"}
+
+func (r *invoiceRepo) CreateTaxRate(rate *models.TaxRate) error {
+	if err := r.DB.Create(rate).Error; err != nil {
+		return fmt.Errorf("failed to create tax rate: %w", err)
+	}
+	return nil
+}
+
+func (r *invoiceRepo) UpdateTaxRate(rate *models.TaxRate) error {
+	if err := r.DB.Save(rate).Error; err != nil {
+		return fmt.Errorf("failed to update tax rate %d: %w", rate.ID, err)
+	}
+	return nil
+}
+
+func (r *invoiceRepo) DeleteTaxRate(id uint) error {
+	result := r.DB.Where("id = ?", id).Delete(&models.TaxRate{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func (r *invoiceRepo) FindTaxRate(id uint) (*models.TaxRate, error) {
+	var rate models.TaxRate
+	if err := r.DB.Where("id = ?", id).First(&rate).Error; err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+func (r *invoiceRepo) FindTaxRateByName(name string) (*models.TaxRate, error) {
+	var rate models.TaxRate
+	if err := r.DB.Where("LOWER(name) = LOWER(?)", name).First(&rate).Error; err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+func (r *invoiceRepo) ListTaxRates() ([]models.TaxRate, error) {
+	var rates []models.TaxRate
+	err := r.DB.Order("name").Find(&rates).Error
+	return rates, err
+}
+
+func (r *invoiceRepo) CreateInvoice(invoice *models.Invoice) error {
+	if err := r.DB.Omit(invoiceAssociations...).Create(invoice).Error; err != nil {
+		return fmt.Errorf("failed to create invoice: %w", err)
+	}
+	return nil
+}
+
+// UpdateInvoice saves a draft invoice, replacing its lines
+func (r *invoiceRepo) UpdateInvoice(invoice *models.Invoice) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("invoice_id = ?", invoice.ID).Delete(&models.InvoiceLine{}).Error; err != nil {
+			return err
+		}
+		for i := range invoice.Lines {
+			invoice.Lines[i].ID = 0
+		}
+		err := tx.Omit("Organization", "Contact", "Payments").Session(&gorm.Session{FullSaveAssociations: true}).Save(invoice).Error
+		if err != nil {
+			return fmt.Errorf("failed to update invoice %d: %w", invoice.ID, err)
+		}
+		return nil
+	})
+}
+
+// MarkInvoiceSent records when an issued invoice was emailed and to whom, it returns gorm.ErrRecordNotFound
+// when the invoice was voided meanwhile
+func (r *invoiceRepo) MarkInvoiceSent(id uint, to string, at time.Time) error {
+	result := r.DB.Model(&models.Invoice{}).
+		Where("id = ? AND status NOT IN ?", id, []models.InvoiceStatus{models.InvoiceDraft, models.InvoiceVoid}).
+		Updates(map[string]interface{}{
+			"sent_at": at,
+			"sent_to": to,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update invoice %d: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *invoiceRepo) DeleteInvoice(id uint) error {
+	result := r.DB.Where("id = ?", id).Delete(&models.Invoice{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func (r *invoiceRepo) FindInvoice(id uint) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := r.DB.Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).
+		Preload("Payments", func(db *gorm.DB) *gorm.DB {
+			return db.Order("paid_on, id")
+		}).
+		Preload("Organization", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		}).
+		Preload("Organization.Emails").Preload("Organization.Addresses").
+		Preload("Contact", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		}).
+		Preload("Contact.Emails").
+		Where("id = ?", id).First(&invoice).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// ListInvoices returns a page of the invoices matching the filter, latest first, and the total count
+func (r *invoiceRepo) ListInvoices(filter *models.InvoiceFilter) ([]models.Invoice, int64, error) {
+	db := r.DB.Model(&models.Invoice{})
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+	if filter.OrganizationID != 0 {
+		db = db.Where("organization_id = ?", filter.OrganizationID)
+	}
+	if filter.DealID != 0 {
+		db = db.Where("deal_id = ?", filter.DealID)
+	}
+	if filter.ContractID != 0 {
+		db = db.Where("contract_id = ?", filter.ContractID)
+	}
+	if filter.Overdue {
+		db = db.Where("status IN ? AND due_date < ?", []models.InvoiceStatus{models.InvoiceSent, models.InvoicePartiallyPaid},
+			time.Now().UTC().Format(models.DateLayout))
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var invoices []models.Invoice
+	err := db.Preload("Organization", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).
+		Order("issue_date DESC NULLS FIRST, id DESC").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Find(&invoices).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return invoices, total, nil
+}
+
+// IssueInvoice saves a draft as issued, numbering it after the last invoice issued in the same year,
+// e.g. INV-2025-0042 with prefix INV, and posts the entry booking it unless there is none. An invoice
+// numbered already keeps its number. It returns gorm.ErrRecordNotFound when the invoice is no longer a draft.
+func (r *invoiceRepo) IssueInvoice(invoice *models.Invoice, prefix string, entry *models.JournalEntry) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.Invoice
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "number").
+			Where("id = ? AND status = ?", invoice.ID, models.InvoiceDraft).First(&current).Error
+		if err != nil {
+			return err
+		}
+		invoice.Number = current.Number
+		if invoice.Number == "" {
+			year := invoice.IssueDate.Year()
+			number, err := nextNumber(tx, fmt.Sprintf("%s-%d", prefix, year))
+			if err != nil {
+				return fmt.Errorf("failed to number invoice %d: %w", invoice.ID, err)
+			}
+			invoice.Number = fmt.Sprintf("%s-%d-%04d", prefix, year, number)
+		}
+		err = tx.Model(&models.Invoice{}).Where("id = ?", invoice.ID).Updates(map[string]interface{}{
+			"number":        invoice.Number,
+			"status":        invoice.Status,
+			"issue_date":    invoice.IssueDate,
+			"due_date":      invoice.DueDate,
+			"exchange_rate": invoice.ExchangeRate,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to issue invoice %d: %w", invoice.ID, err)
+		}
+		if entry == nil {
+			return nil
+		}
+		entry.Reference = invoice.Number
+		return postEntry(tx, entry)
+	})
+}
+
+// VoidInvoice marks an invoice nothing was paid on as void and reverses the entries booking it, on the day
+// it is voided. The invoice is locked while it is voided, one void already or paid meanwhile is not found.
+func (r *invoiceRepo) VoidInvoice(id uint, reason string, at time.Time, userID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var invoice models.Invoice
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+			Where("id = ? AND status <> ? AND amount_paid = 0", id, models.InvoiceVoid).First(&invoice).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&models.Invoice{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"status":      models.InvoiceVoid,
+			"voided_at":   at,
+			"void_reason": reason,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to void invoice %d: %w", id, err)
+		}
+		return reverseEntries(tx, models.SourceInvoice, id, at, userID)
+	})
+}
+
+// RecordPayment records a payment against an issued invoice, settles its status and posts the entry booking
+// the payment. The invoice is locked while the payment is checked against it, an invoice that is not open
+// or owes less than the payment is returned as it is and the payment is not recorded, leaving its ID zero.
+func (r *invoiceRepo) RecordPayment(payment *models.InvoicePayment, entry *models.JournalEntry) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", payment.InvoiceID).First(&invoice).Error
+		if err != nil {
+			return err
+		}
+		if !invoice.Status.IsOpen() || payment.Amount.GreaterThan(invoice.Balance()) {
+			return nil
+		}
+		if err := tx.Create(payment).Error; err != nil {
+			return fmt.Errorf("failed to record payment on invoice %d: %w", invoice.ID, err)
+		}
+		invoice.AmountPaid = invoice.AmountPaid.Add(payment.Amount)
+		invoice.Settle()
+		if err := tx.Omit(clause.Associations).Save(&invoice).Error; err != nil {
+			return err
+		}
+		entry.SourceID = payment.ID
+		return postEntry(tx, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// DeletePayment removes a payment recorded by mistake, settles the status of its invoice again and reverses
+// the entry booking the payment, on the day it is deleted
+func (r *invoiceRepo) DeletePayment(invoiceID, paymentID, userID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var invoice models.Invoice
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", invoiceID).First(&invoice).Error
+		if err != nil {
+			return err
+		}
+		var payment models.InvoicePayment
+		if err := tx.Where("id = ? AND invoice_id = ?", paymentID, invoiceID).First(&payment).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&payment).Error; err != nil {
+			return err
+		}
+		invoice.AmountPaid = invoice.AmountPaid.Sub(payment.Amount)
+		invoice.Settle()
+		if err := tx.Omit(clause.Associations).Save(&invoice).Error; err != nil {
+			return err
+		}
+		return reverseEntries(tx, models.SourceInvoicePayment, payment.ID, time.Now().UTC(), userID)
+	})
+}
+
+// FindInvoiceBalances returns what was left to pay on a date on the invoices issued by then, counting the
+// payments received by then. Invoices paid off by then are left out.
+func (r *invoiceRepo) FindInvoiceBalances(asOf time.Time) ([]models.InvoiceBalance, error) {
+	date := asOf.Format(models.DateLayout)
+	issued := []models.InvoiceStatus{models.InvoiceSent, models.InvoicePartiallyPaid, models.InvoicePaid}
+	var balances []models.InvoiceBalance
+	err := r.DB.Table("invoices i").
+		Select(`i.id AS invoice_id, i.number, i.organization_id, i.currency, i.due_date,
+			i.total - COALESCE((SELECT SUM(p.amount) FROM invoice_payments p WHERE p.invoice_id = i.id AND p.paid_on <= ?), 0) AS balance`, date).
+		Where("i.deleted_at IS NULL AND i.status IN ? AND i.issue_date <= ?", issued, date).
+		Order("i.organization_id, i.due_date, i.id").
+		Scan(&balances).Error
+	if err != nil {
+		return nil, err
+	}
+
+	owed := balances[:0]
+	for _, balance := range balances {
+		if balance.Balance.IsPositive() {
+			owed = append(owed, balance)
+		}
+	}
+	return owed, nil
+}
@@ -0,0 +1,438 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// entryPrefix starts the numbers of journal entries, which are numbered by year like invoices
+const entryPrefix = "JE"
+
+type LedgerRepository interface {
+	CreateAccount(account *models.Account) error
+	UpdateAccount(account *models.Account) error
+	DeleteAccount(id uint) error
+	FindAccount(id uint) (*models.Account, error)
+	FindAccountByCode(code string) (*models.Account, error)
+	FindAccountByPurpose(purpose models.AccountPurpose) (*models.Account, error)
+	FindAccounts(ids []uint) ([]models.Account, error)
+	ListAccounts() ([]models.Account, error)
+	CountAccountLines(id uint) (int64, error)
+	CreateEntry(entry *models.JournalEntry) error
+	UpdateEntry(entry *models.JournalEntry) error
+	DeleteEntry(id uint) error
+	FindEntry(id uint) (*models.JournalEntry, error)
+	ListEntries(filter *models.JournalFilter) ([]models.JournalEntry, int64, error)
+	PostEntry(id, userID uint) error
+	ReverseEntry(id uint, on time.Time, memo string, userID uint) (*models.JournalEntry, error)
+	CreatePeriod(period *models.AccountingPeriod) error
+	FindPeriod(id uint) (*models.AccountingPeriod, error)
+	ListPeriods() ([]models.AccountingPeriod, error)
+	CountOverlappingPeriods(start, end time.Time) (int64, error)
+	CountDraftEntries(start, end time.Time) (int64, error)
+	SetPeriodStatus(id uint, from []models.PeriodStatus, to models.PeriodStatus, userID uint, at time.Time) error
+	SumAccounts(from *time.Time, to time.Time) ([]models.AccountTotal, error)
+}
+
+type ledgerRepo struct {
+	DB *gorm.DB
+}
+
+func NewLedgerRepo(db *GormDB) LedgerRepository {
+	return &ledgerRepo{db.DB}
+}
+
+func (r *ledgerRepo) CreateAccount(account *models.Account) error {
+	if err := r.DB.Create(account).Error; err != nil {
+		return fmt.Errorf("failed to create account: %w", err)
+	}
+	return nil
+}
+
+func (r *ledgerRepo) UpdateAccount(account *models.Account) error {
+	if err := r.DB.Save(account).Error; err != nil {
+		return fmt.Errorf("failed to update account %d: %w", account.ID, err)
+	}
+	return nil
+}
+
+// DeleteAccount removes an account nothing was ever entered in, for good so its code can be used again
+func (r *ledgerRepo) DeleteAccount(id uint) error {
+	result := r.DB.Unscoped().Where("id = ?", id).Delete(&models.Account{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func (r *ledgerRepo) FindAccount(id uint) (*models.Account, error) {
+	var account models.Account
+	if err := r.DB.Where("id = ?", id).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *ledgerRepo) FindAccountByCode(code string) (*models.Account, error) {
+	var account models.Account
+	if err := r.DB.Where("code = ?", code).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *ledgerRepo) FindAccountByPurpose(purpose models.AccountPurpose) (*models.Account, error) {
+	var account models.Account
+	if err := r.DB.Where("purpose = ?", purpose).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *ledgerRepo) FindAccounts(ids []uint) ([]models.Account, error) {
+	var accounts []models.Account
+	err := r.DB.Where("id IN ?", ids).Find(&accounts).Error
+	return accounts, err
+}
+
+// ListAccounts returns the chart of accounts in code order
+func (r *ledgerRepo) ListAccounts() ([]models.Account, error) {
+	var accounts []models.Account
+	err := r.DB.Order("code").Find(&accounts).Error
+	return accounts, err
+}
+
+// CountAccountLines counts the lines of draft and posted entries entered in an account
+func (r *ledgerRepo) CountAccountLines(id uint) (int64, error) {
+	var count int64
+	err := r.DB.Model(&models.JournalLine{}).Where("account_id = ?", id).Count(&count).Error
+	return count, err
+}
+
+func (r *ledgerRepo) CreateEntry(entry *models.JournalEntry) error {
+	if err := r.DB.Omit("Lines.Account").Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to create journal entry: %w", err)
+	}
+	return nil
+}
+
+// UpdateEntry saves a draft entry, replacing its lines. It returns gorm.ErrRecordNotFound once the entry
+// is posted.
+func (r *ledgerRepo) UpdateEntry(entry *models.JournalEntry) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.JournalEntry{}).Where("id = ? AND status = ?", entry.ID, models.EntryDraft).
+			Updates(map[string]interface{}{
+				"date":      entry.Date,
+				"memo":      entry.Memo,
+				"reference": entry.Reference,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update journal entry %d: %w", entry.ID, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("entry_id = ?", entry.ID).Delete(&models.JournalLine{}).Error; err != nil {
+			return err
+		}
+		for i := range entry.Lines {
+			entry.Lines[i].ID = 0
+			entry.Lines[i].EntryID = entry.ID
+		}
+		return tx.Omit("Account").Create(&entry.Lines).Error
+	})
+}
+
+// DeleteEntry removes a draft entry and its lines, it returns gorm.ErrRecordNotFound once the entry is posted
+func (r *ledgerRepo) DeleteEntry(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var entry models.JournalEntry
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ?", id, models.EntryDraft).First(&entry).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("entry_id = ?", id).Delete(&models.JournalLine{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&entry).Error
+	})
+}
+
+func (r *ledgerRepo) FindEntry(id uint) (*models.JournalEntry, error) {
+	var entry models.JournalEntry
+	err := r.DB.Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).
+		Preload("Lines.Account", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		}).
+		Where("id = ?", id).First(&entry).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var reversals []uint
+	if err := r.DB.Model(&models.JournalEntry{}).Where("reversal_of_id = ?", id).Pluck("id", &reversals).Error; err != nil {
+		return nil, err
+	}
+	if len(reversals) > 0 {
+		entry.ReversedByID = &reversals[0]
+	}
+	return &entry, nil
+}
+
+// ListEntries returns a page of the entries matching the filter, latest first, and the total count
+func (r *ledgerRepo) ListEntries(filter *models.JournalFilter) ([]models.JournalEntry, int64, error) {
+	db := r.DB.Model(&models.JournalEntry{})
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+	if filter.Source != "" {
+		db = db.Where("source = ?", filter.Source)
+	}
+	if filter.AccountID != 0 {
+		db = db.Where("id IN (?)", r.DB.Model(&models.JournalLine{}).Select("entry_id").Where("account_id = ?", filter.AccountID))
+	}
+	if filter.From != nil {
+		db = db.Where("date >= ?", filter.From.Format(models.DateLayout))
+	}
+	if filter.To != nil {
+		db = db.Where("date <= ?", filter.To.Format(models.DateLayout))
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var entries []models.JournalEntry
+	err := db.Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).
+		Order("date DESC, id DESC").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Find(&entries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// PostEntry posts a draft entry, it returns gorm.ErrRecordNotFound once the entry is posted
+func (r *ledgerRepo) PostEntry(id, userID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var entry models.JournalEntry
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Lines").
+			Where("id = ? AND status = ?", id, models.EntryDraft).First(&entry).Error
+		if err != nil {
+			return err
+		}
+		return post(tx, &entry, userID)
+	})
+}
+
+// ReverseEntry posts the reversal of a posted entry
+func (r *ledgerRepo) ReverseEntry(id uint, on time.Time, memo string, userID uint) (*models.JournalEntry, error) {
+	var reversal *models.JournalEntry
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var entry models.JournalEntry
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Lines").
+			Where("id = ? AND status = ?", id, models.EntryPosted).First(&entry).Error
+		if err != nil {
+			return err
+		}
+		reversal, err = reverse(tx, &entry, on, memo, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reversal, nil
+}
+
+func (r *ledgerRepo) CreatePeriod(period *models.AccountingPeriod) error {
+	if err := r.DB.Create(period).Error; err != nil {
+		return fmt.Errorf("failed to create accounting period: %w", err)
+	}
+	return nil
+}
+
+func (r *ledgerRepo) FindPeriod(id uint) (*models.AccountingPeriod, error) {
+	var period models.AccountingPeriod
+	if err := r.DB.Where("id = ?", id).First(&period).Error; err != nil {
+		return nil, err
+	}
+	return &period, nil
+}
+
+func (r *ledgerRepo) ListPeriods() ([]models.AccountingPeriod, error) {
+	var periods []models.AccountingPeriod
+	err := r.DB.Order("start_date DESC").Find(&periods).Error
+	return periods, err
+}
+
+// CountOverlappingPeriods counts the periods sharing a day with the days from start to end
+func (r *ledgerRepo) CountOverlappingPeriods(start, end time.Time) (int64, error) {
+	var count int64
+	err := r.DB.Model(&models.AccountingPeriod{}).
+		Where("start_date <= ? AND end_date >= ?", end.Format(models.DateLayout), start.Format(models.DateLayout)).
+		Count(&count).Error
+	return count, err
+}
+
+// CountDraftEntries counts the draft entries dated from start to end
+func (r *ledgerRepo) CountDraftEntries(start, end time.Time) (int64, error) {
+	var count int64
+	err := r.DB.Model(&models.JournalEntry{}).
+		Where("status = ? AND date BETWEEN ? AND ?", models.EntryDraft, start.Format(models.DateLayout), end.Format(models.DateLayout)).
+		Count(&count).Error
+	return count, err
+}
+
+// SetPeriodStatus moves a period in one of the from statuses to another, it returns gorm.ErrRecordNotFound
+// when the period is in none of them. The period is locked for the change, so that no entry is posted in it
+// at the same time.
+func (r *ledgerRepo) SetPeriodStatus(id uint, from []models.PeriodStatus, to models.PeriodStatus, userID uint, at time.Time) error {
+	updates := map[string]interface{}{"status": to, "closed_at": nil, "closed_by": nil}
+	if to != models.PeriodOpen {
+		updates["closed_at"] = at
+		updates["closed_by"] = userID
+	}
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var period models.AccountingPeriod
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status IN ?", id, from).First(&period).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&period).Updates(updates).Error
+	})
+}
+
+// SumAccounts adds up the posted lines of every account used on the days from from, or the first entry,
+// to to
+func (r *ledgerRepo) SumAccounts(from *time.Time, to time.Time) ([]models.AccountTotal, error) {
+	db := r.DB.Table("journal_lines l").
+		Select("a.id AS account_id, a.code, a.name, a.type, SUM(l.debit) AS debit, SUM(l.credit) AS credit").
+		Joins("JOIN journal_entries e ON e.id = l.entry_id").
+		Joins("JOIN accounts a ON a.id = l.account_id").
+		Where("e.status = ? AND e.date <= ?", models.EntryPosted, to.Format(models.DateLayout))
+	if from != nil {
+		db = db.Where("e.date >= ?", from.Format(models.DateLayout))
+	}
+
+	var totals []models.AccountTotal
+	err := db.Group("a.id, a.code, a.name, a.type").Order("a.code").Scan(&totals).Error
+	return totals, err
+}
+
+// postEntry records an entry built for a change, e.g. an invoice issued, and posts it within the transaction
+// of the change
+func postEntry(tx *gorm.DB, entry *models.JournalEntry) error {
+	entry.Status = models.EntryDraft
+	if err := tx.Omit("Lines.Account").Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to create journal entry: %w", err)
+	}
+	return post(tx, entry, entry.CreatedBy)
+}
+
+// post numbers a draft entry after the last one posted in its year and marks it posted. An entry that does
+// not balance is refused, the database refuses it too, and so is one dated in a closed period.
+func post(tx *gorm.DB, entry *models.JournalEntry, userID uint) error {
+	debit, credit := decimal.Zero, decimal.Zero
+	for _, line := range entry.Lines {
+		debit = debit.Add(line.Debit)
+		credit = credit.Add(line.Credit)
+	}
+	if !debit.IsPositive() || !debit.Equal(credit) {
+		return fmt.Errorf("journal entry %d does not balance: debits %s, credits %s", entry.ID, debit, credit)
+	}
+
+	date := entry.Date.Format(models.DateLayout)
+	var periods []models.AccountingPeriod
+	err := tx.Clauses(clause.Locking{Strength: "SHARE"}).
+		Where("start_date <= ? AND end_date >= ?", date, date).Find(&periods).Error
+	if err != nil {
+		return err
+	}
+	for _, period := range periods {
+		if period.Status != models.PeriodOpen {
+			return &apiError.PeriodClosedError{Period: period.Name, Date: entry.Date}
+		}
+	}
+
+	year := entry.Date.Year()
+	number, err := nextNumber(tx, fmt.Sprintf("%s-%d", entryPrefix, year))
+	if err != nil {
+		return fmt.Errorf("failed to number journal entry %d: %w", entry.ID, err)
+	}
+	now := time.Now()
+	entry.Number = fmt.Sprintf("%s-%d-%05d", entryPrefix, year, number)
+	entry.Status = models.EntryPosted
+	entry.PostedAt = &now
+	entry.PostedBy = &userID
+	err = tx.Model(&models.JournalEntry{}).Where("id = ?", entry.ID).Updates(map[string]interface{}{
+		"number":    entry.Number,
+		"status":    entry.Status,
+		"posted_at": entry.PostedAt,
+		"posted_by": entry.PostedBy,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to post journal entry %d: %w", entry.ID, err)
+	}
+	return nil
+}
+
+// reverse posts an entry swapping the debits and credits of a posted one, booking the same source
+func reverse(tx *gorm.DB, entry *models.JournalEntry, on time.Time, memo string, userID uint) (*models.JournalEntry, error) {
+	if memo == "" {
+		memo = "Reversal of " + entry.Number
+	}
+	reversal := &models.JournalEntry{
+		Date:         on,
+		Memo:         memo,
+		Reference:    entry.Reference,
+		Source:       entry.Source,
+		SourceID:     entry.SourceID,
+		ReversalOfID: &entry.ID,
+		CreatedBy:    userID,
+	}
+	for _, line := range entry.Lines {
+		reversal.Lines = append(reversal.Lines, models.JournalLine{
+			AccountID:   line.AccountID,
+			Debit:       line.Credit,
+			Credit:      line.Debit,
+			Description: line.Description,
+		})
+	}
+	if err := postEntry(tx, reversal); err != nil {
+		return nil, err
+	}
+	return reversal, nil
+}
+
+// reverseEntries reverses the posted entries booking a record that are not reversed yet, e.g. when an
+// invoice is voided
+func reverseEntries(tx *gorm.DB, source models.EntrySource, sourceID uint, on time.Time, userID uint) error {
+	var entries []models.JournalEntry
+	err := tx.Preload("Lines").
+		Where("source = ? AND source_id = ? AND status = ? AND reversal_of_id IS NULL", source, sourceID, models.EntryPosted).
+		Where("NOT EXISTS (SELECT 1 FROM journal_entries r WHERE r.reversal_of_id = journal_entries.id)").
+		Order("id").Find(&entries).Error
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		if _, err := reverse(tx, &entries[i], on, "", userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
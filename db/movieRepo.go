@@ -10,7 +10,13 @@ import (
 
 type MovieRepository interface {
 	CreateTrailer(trailer *models.Trailer) error
-	UpdateTrailerMedia(trailerID uint, videoURLs, pictureURLs string) error
+	PublishTrailer(trailer *models.Trailer, assets []*models.MediaAsset, subtitles []*models.Subtitle, credits []*models.Credit) error
+	DeleteTrailer(id uint) error
+	FindTitle(titleType models.TitleType, id uint) (*models.MovieBase, error)
+	FindTitleOwner(titleType models.TitleType, id uint) (uint, error)
+	CreateMediaAsset(asset *models.MediaAsset) error
+	FindMediaAsset(id uint) (*models.MediaAsset, error)
+	FindMediaAssetByChecksum(sha256 string, size int64) (*models.MediaAsset, error)
 }
 
 type movieRepo struct {
@@ -34,17 +40,106 @@ func (r *movieRepo) CreateTrailer(trailer *models.Trailer) error {
 	return nil
 }
 
-// func (r *movieRepo) UpdateTrailerMedia(trailerID, videoURLs, pictureURLs string) error {
-//     // Update the trailer with the provided media URLs
-//     return r.DB.Model(&models.Trailer{}).Where("id = ?", trailerID).Updates(map[string]interface{}{
-//         "video_urls":   videoURLs,
-//         "picture_urls": pictureURLs,
-//     }).Error
-// }
-func (r *movieRepo) UpdateTrailerMedia(trailerID uint, videoURLs, pictureURLs string) error {
-    // Update the trailer with the provided media URLs
-    return r.DB.Model(&models.Trailer{}).Where("id = ?", trailerID).Updates(map[string]interface{}{
-        "video_urls":   videoURLs,
-        "picture_urls": pictureURLs,
-    }).Error
+// PublishTrailer saves the final state of a draft trailer along with its media, subtitles and credits, in one
+// transaction. Subtitles point at one of the assets. Credits naming a person without an ID are matched to the
+// directory by name.
+func (r *movieRepo) PublishTrailer(trailer *models.Trailer, assets []*models.MediaAsset, subtitles []*models.Subtitle, credits []*models.Credit) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Trailer{}).Where("id = ?", trailer.ID).Updates(map[string]interface{}{
+			"video_urls":   trailer.VideoURLs,
+			"picture_urls": trailer.PictureURLs,
+			"duration":     trailer.Duration,
+			"status":       trailer.Status,
+			"version":      gorm.Expr("version + 1"),
+		}).Error; err != nil {
+			return fmt.Errorf("failed to publish trailer: %w", err)
+		}
+		for _, asset := range assets {
+			if err := tx.Create(asset).Error; err != nil {
+				return fmt.Errorf("failed to create media asset: %w", err)
+			}
+		}
+		for _, subtitle := range subtitles {
+			subtitle.TitleType = models.TitleTrailer
+			subtitle.TitleID = trailer.ID
+			if err := saveSubtitle(tx, subtitle); err != nil {
+				return err
+			}
+		}
+		for _, credit := range credits {
+			if credit.PersonID == 0 && credit.Person != nil {
+				person, err := findOrCreatePerson(tx, credit.Person.Name)
+				if err != nil {
+					return err
+				}
+				credit.PersonID = person.ID
+				credit.Person = person
+			}
+			credit.TitleType = models.TitleTrailer
+			credit.TitleID = trailer.ID
+			if err := tx.Omit("Person").Create(credit).Error; err != nil {
+				return fmt.Errorf("failed to create credit: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteTrailer removes a trailer for good, it is used to throw away drafts
+func (r *movieRepo) DeleteTrailer(id uint) error {
+	return r.DB.Unscoped().Where("id = ?", id).Delete(&models.Trailer{}).Error
+}
+
+// FindTitle returns the catalogue fields shared by trailers and full-length films
+func (r *movieRepo) FindTitle(titleType models.TitleType, id uint) (*models.MovieBase, error) {
+	if !titleType.IsValid() {
+		return nil, fmt.Errorf("unknown title type: %s", titleType)
+	}
+
+	var title models.MovieBase
+	if err := r.DB.Table(titleType.TableName()).Where("id = ?", id).First(&title).Error; err != nil {
+		return nil, err
+	}
+	return &title, nil
+}
+
+// FindTitleOwner returns the user who uploaded a title, full-length films have no owner and give 0
+func (r *movieRepo) FindTitleOwner(titleType models.TitleType, id uint) (uint, error) {
+	if titleType != models.TitleTrailer {
+		return 0, nil
+	}
+	var owner uint
+	if err := r.DB.Model(&models.Trailer{}).Where("id = ?", id).Select("user_id").Scan(&owner).Error; err != nil {
+		return 0, err
+	}
+	return owner, nil
+}
+
+func (r *movieRepo) CreateMediaAsset(asset *models.MediaAsset) error {
+	if asset == nil {
+		return errors.New("media asset cannot be nil")
+	}
+
+	if err := r.DB.Create(asset).Error; err != nil {
+		return fmt.Errorf("failed to create media asset: %w", err)
+	}
+
+	return nil
+}
+
+func (r *movieRepo) FindMediaAsset(id uint) (*models.MediaAsset, error) {
+	var asset models.MediaAsset
+	if err := r.DB.Where("id = ?", id).First(&asset).Error; err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// FindMediaAssetByChecksum returns the oldest asset holding exactly these bytes
+func (r *movieRepo) FindMediaAssetByChecksum(sha256 string, size int64) (*models.MediaAsset, error) {
+	var asset models.MediaAsset
+	if err := r.DB.Where("sha256 = ? AND size = ?", sha256, size).Order("id").First(&asset).Error; err != nil {
+		return nil, err
+	}
+	return &asset, nil
 }
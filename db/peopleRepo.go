@@ -0,0 +1,126 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+type PeopleRepository interface {
+	CreatePerson(person *models.Person) error
+	UpdatePerson(person *models.Person) error
+	FindPerson(id uint) (*models.Person, error)
+	SearchPeople(query string, limit, offset int) ([]models.Person, int64, error)
+	CreateCredit(credit *models.Credit) error
+	FindCredit(id uint) (*models.Credit, error)
+	DeleteCredit(id uint) error
+	FindTitleCredits(titleType models.TitleType, titleID uint) ([]models.Credit, error)
+	FindFilmography(personID uint) ([]models.FilmographyEntry, error)
+}
+
+type peopleRepo struct {
+	DB *gorm.DB
+}
+
+func NewPeopleRepo(db *GormDB) PeopleRepository {
+	return &peopleRepo{db.DB}
+}
+
+func (r *peopleRepo) CreatePerson(person *models.Person) error {
+	if err := r.DB.Create(person).Error; err != nil {
+		return fmt.Errorf("failed to create person: %w", err)
+	}
+	return nil
+}
+
+func (r *peopleRepo) UpdatePerson(person *models.Person) error {
+	return r.DB.Save(person).Error
+}
+
+func (r *peopleRepo) FindPerson(id uint) (*models.Person, error) {
+	var person models.Person
+	if err := r.DB.Where("id = ?", id).First(&person).Error; err != nil {
+		return nil, err
+	}
+	return &person, nil
+}
+
+// SearchPeople matches query against names and aliases, it returns a page of people and the total count
+func (r *peopleRepo) SearchPeople(query string, limit, offset int) ([]models.Person, int64, error) {
+	db := r.DB.Model(&models.Person{})
+	if query = strings.TrimSpace(query); query != "" {
+		pattern := "%" + query + "%"
+		db = db.Where("name ILIKE ? OR aliases ILIKE ?", pattern, pattern)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var people []models.Person
+	if err := db.Order("name").Limit(limit).Offset(offset).Find(&people).Error; err != nil {
+		return nil, 0, err
+	}
+	return people, total, nil
+}
+
+func (r *peopleRepo) CreateCredit(credit *models.Credit) error {
+	if err := r.DB.Create(credit).Error; err != nil {
+		return fmt.Errorf("failed to create credit: %w", err)
+	}
+	return nil
+}
+
+func (r *peopleRepo) FindCredit(id uint) (*models.Credit, error) {
+	var credit models.Credit
+	if err := r.DB.Where("id = ?", id).First(&credit).Error; err != nil {
+		return nil, err
+	}
+	return &credit, nil
+}
+
+func (r *peopleRepo) DeleteCredit(id uint) error {
+	return r.DB.Where("id = ?", id).Delete(&models.Credit{}).Error
+}
+
+// FindTitleCredits returns the cast and crew of a title in billing order
+func (r *peopleRepo) FindTitleCredits(titleType models.TitleType, titleID uint) ([]models.Credit, error) {
+	var credits []models.Credit
+	err := r.DB.Preload("Person").
+		Where("title_type = ? AND title_id = ?", titleType, titleID).
+		Order("billing_order, id").
+		Find(&credits).Error
+	return credits, err
+}
+
+// FindFilmography lists every credit of a person together with the title it belongs to
+func (r *peopleRepo) FindFilmography(personID uint) ([]models.FilmographyEntry, error) {
+	var entries []models.FilmographyEntry
+	for _, titleType := range []models.TitleType{models.TitleTrailer, models.TitleFullLength} {
+		var found []models.FilmographyEntry
+		err := r.DB.Table("credits").
+			Select("credits.title_type, credits.title_id, titles.title, titles.status, credits.role, credits.character_name, credits.billing_order").
+			Joins(fmt.Sprintf("JOIN %s titles ON titles.id = credits.title_id", titleType.TableName())).
+			Where("credits.person_id = ? AND credits.title_type = ?", personID, titleType).
+			Where("credits.deleted_at IS NULL AND titles.deleted_at IS NULL").
+			Order("titles.uploaded_at DESC").
+			Scan(&found).Error
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, found...)
+	}
+	return entries, nil
+}
+
+// findOrCreatePerson returns the person going by name, creating them when there is nobody yet
+func findOrCreatePerson(tx *gorm.DB, name string) (*models.Person, error) {
+	var person models.Person
+	err := tx.Where("LOWER(name) = LOWER(?)", name).Attrs(models.Person{Name: name}).FirstOrCreate(&person).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find or create person %s: %w", name, err)
+	}
+	return &person, nil
+}
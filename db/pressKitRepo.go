@@ -0,0 +1,78 @@
+package db
+
+import (
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+type PressKitRepository interface {
+	FindTitleAssets(titleType models.TitleType, titleID uint, kinds []models.MediaKind) ([]models.MediaAsset, error)
+	FindAssets(ids []uint) ([]models.MediaAsset, error)
+	FindPressKitAssets(titleType models.TitleType, titleID uint) ([]models.PressKitAsset, error)
+	SetPressKitAssets(titleType models.TitleType, titleID uint, assetIDs []uint) error
+	FindTrailer(id uint) (*models.Trailer, error)
+}
+
+type pressKitRepo struct {
+	DB *gorm.DB
+}
+
+func NewPressKitRepo(db *GormDB) PressKitRepository {
+	return &pressKitRepo{db.DB}
+}
+
+// FindTitleAssets returns the media assets of a title of the given kinds, oldest first
+func (r *pressKitRepo) FindTitleAssets(titleType models.TitleType, titleID uint, kinds []models.MediaKind) ([]models.MediaAsset, error) {
+	var assets []models.MediaAsset
+	err := r.DB.Where("title_type = ? AND title_id = ? AND kind IN ?", titleType, titleID, kinds).
+		Order("id").
+		Find(&assets).Error
+	return assets, err
+}
+
+func (r *pressKitRepo) FindAssets(ids []uint) ([]models.MediaAsset, error) {
+	var assets []models.MediaAsset
+	if len(ids) == 0 {
+		return assets, nil
+	}
+	err := r.DB.Where("id IN ?", ids).Find(&assets).Error
+	return assets, err
+}
+
+// FindPressKitAssets returns the assets picked for the press kit of a title in their order, leaving out
+// the ones deleted since
+func (r *pressKitRepo) FindPressKitAssets(titleType models.TitleType, titleID uint) ([]models.PressKitAsset, error) {
+	var picked []models.PressKitAsset
+	err := r.DB.Joins("Asset").
+		Where(`press_kit_assets.title_type = ? AND press_kit_assets.title_id = ? AND "Asset".id IS NOT NULL`, titleType, titleID).
+		Order("press_kit_assets.position").
+		Find(&picked).Error
+	return picked, err
+}
+
+// SetPressKitAssets replaces the assets picked for the press kit of a title, in the given order
+func (r *pressKitRepo) SetPressKitAssets(titleType models.TitleType, titleID uint, assetIDs []uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("title_type = ? AND title_id = ?", titleType, titleID).Delete(&models.PressKitAsset{}).Error
+		if err != nil {
+			return err
+		}
+		if len(assetIDs) == 0 {
+			return nil
+		}
+		picked := make([]models.PressKitAsset, len(assetIDs))
+		for i, id := range assetIDs {
+			picked[i] = models.PressKitAsset{TitleType: titleType, TitleID: titleID, AssetID: id, Position: i}
+		}
+		return tx.Omit("Asset").Create(&picked).Error
+	})
+}
+
+// FindTrailer returns a trailer with the fields full-length films do not have, such as its log line
+func (r *pressKitRepo) FindTrailer(id uint) (*models.Trailer, error) {
+	var trailer models.Trailer
+	if err := r.DB.Omit("User").Where("id = ?", id).First(&trailer).Error; err != nil {
+		return nil, err
+	}
+	return &trailer, nil
+}
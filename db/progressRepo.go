@@ -0,0 +1,32 @@
+package db
+
+import (
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+// ProgressRepository keeps upload progress in Postgres so every instance sees the same numbers
+type ProgressRepository interface {
+	SaveProgress(progress *models.UploadProgress) error
+	GetProgress(sessionID string) (*models.UploadProgress, error)
+}
+
+type progressRepo struct {
+	DB *gorm.DB
+}
+
+func NewProgressRepo(db *GormDB) ProgressRepository {
+	return &progressRepo{db.DB}
+}
+
+func (r *progressRepo) SaveProgress(progress *models.UploadProgress) error {
+	return r.DB.Save(progress).Error
+}
+
+func (r *progressRepo) GetProgress(sessionID string) (*models.UploadProgress, error) {
+	var progress models.UploadProgress
+	if err := r.DB.Where("session_id = ?", sessionID).First(&progress).Error; err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
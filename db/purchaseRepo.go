@@ -0,0 +1,495 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// receiptPrefix starts the numbers of goods received notes, e.g. GRN-2025-0042
+const receiptPrefix = "GRN"
+
+type PurchaseRepository interface {
+	ListApprovalLevels() ([]models.PurchaseApprovalLevel, error)
+	ReplaceApprovalLevels(levels []models.PurchaseApprovalLevel) error
+	CreateOrder(order *models.PurchaseOrder) error
+	UpdateOrder(order *models.PurchaseOrder) error
+	DeleteOrder(id uint) error
+	FindOrder(id uint) (*models.PurchaseOrder, error)
+	ListOrders(filter *models.PurchaseOrderFilter) ([]models.PurchaseOrder, int64, error)
+	ListAwaitingApproval(userID uint) ([]models.PurchaseOrder, error)
+	SubmitOrder(order *models.PurchaseOrder, prefix string) error
+	WithdrawOrder(id uint) error
+	PlaceOrder(id uint, at time.Time) error
+	CloseOrder(id uint, from models.PurchaseOrderStatus, userID uint, reason string, at time.Time) error
+	DecideApproval(orderID uint, level int, decision models.ApprovalDecision, userID uint, note string, at time.Time) error
+	ReceiveGoods(receipt *models.GoodsReceipt) (*models.PurchaseOrder, error)
+	ListReceipts(orderID uint) ([]models.GoodsReceipt, error)
+	CreateBill(bill *models.VendorBill, tolerance models.MatchTolerance) (*models.PurchaseOrder, error)
+	FindBill(id uint) (*models.VendorBill, error)
+	FindBillByNumber(vendorID uint, number string) (*models.VendorBill, error)
+	ListBills(orderID uint) ([]models.VendorBill, error)
+	ReviewBill(id uint, status models.VendorBillStatus, userID uint, note string, at time.Time) error
+}
+
+type purchaseRepo struct {
+	DB *gorm.DB
+}
+
+func NewPurchaseRepo(db *GormDB) PurchaseRepository {
+	return &purchaseRepo{db.DB}
+}
+
+func (r *purchaseRepo) ListApprovalLevels() ([]models.PurchaseApprovalLevel, error) {
+	var levels []models.PurchaseApprovalLevel
+	err := r.DB.Order("level").Find(&levels).Error
+	return levels, err
+}
+
+// ReplaceApprovalLevels sets the levels of approval purchase orders submitted from now on need, orders
+// submitted already keep theirs
+func (r *purchaseRepo) ReplaceApprovalLevels(levels []models.PurchaseApprovalLevel) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id > 0").Delete(&models.PurchaseApprovalLevel{}).Error; err != nil {
+			return err
+		}
+		if len(levels) == 0 {
+			return nil
+		}
+		if err := tx.Create(&levels).Error; err != nil {
+			return fmt.Errorf("failed to save approval levels: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *purchaseRepo) CreateOrder(order *models.PurchaseOrder) error {
+	if err := r.DB.Omit("Vendor", "Approvals").Create(order).Error; err != nil {
+		return fmt.Errorf("failed to create purchase order: %w", err)
+	}
+	return nil
+}
+
+// UpdateOrder saves a draft purchase order, replacing its lines
+func (r *purchaseRepo) UpdateOrder(order *models.PurchaseOrder) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("purchase_order_id = ?", order.ID).Delete(&models.PurchaseOrderLine{}).Error; err != nil {
+			return err
+		}
+		for i := range order.Lines {
+			order.Lines[i].ID = 0
+		}
+		err := tx.Omit("Vendor", "Approvals").Session(&gorm.Session{FullSaveAssociations: true}).Save(order).Error
+		if err != nil {
+			return fmt.Errorf("failed to update purchase order %d: %w", order.ID, err)
+		}
+		return nil
+	})
+}
+
+func (r *purchaseRepo) DeleteOrder(id uint) error {
+	result := r.DB.Where("id = ?", id).Delete(&models.PurchaseOrder{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func (r *purchaseRepo) FindOrder(id uint) (*models.PurchaseOrder, error) {
+	var order models.PurchaseOrder
+	err := r.DB.Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, id")
+	}).
+		Preload("Approvals", func(db *gorm.DB) *gorm.DB {
+			return db.Order("level")
+		}).
+		Preload("Vendor", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		}).
+		Where("id = ?", id).First(&order).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// ListOrders returns a page of the purchase orders matching the filter, latest first, and the total count
+func (r *purchaseRepo) ListOrders(filter *models.PurchaseOrderFilter) ([]models.PurchaseOrder, int64, error) {
+	db := r.DB.Model(&models.PurchaseOrder{})
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+	if filter.VendorID != 0 {
+		db = db.Where("vendor_id = ?", filter.VendorID)
+	}
+	if filter.BudgetID != 0 {
+		db = db.Where("budget_id = ?", filter.BudgetID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var orders []models.PurchaseOrder
+	err := db.Preload("Vendor", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).
+		Order("id DESC").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Find(&orders).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
+}
+
+// ListAwaitingApproval returns the submitted purchase orders a user could decide on: raised and submitted by
+// someone else and with no level decided by the user yet. Whether the role of the user fits the pending level
+// is left to the caller.
+func (r *purchaseRepo) ListAwaitingApproval(userID uint) ([]models.PurchaseOrder, error) {
+	var orders []models.PurchaseOrder
+	err := r.DB.Preload("Approvals", func(db *gorm.DB) *gorm.DB {
+		return db.Order("level")
+	}).
+		Preload("Vendor", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		}).
+		Where("status = ? AND created_by <> ? AND submitted_by <> ?", models.PurchaseOrderSubmitted, userID, userID).
+		Where("NOT EXISTS (SELECT 1 FROM purchase_order_approvals a WHERE a.purchase_order_id = purchase_orders.id AND a.decided_by = ?)", userID).
+		Order("submitted_at, id").
+		Find(&orders).Error
+	return orders, err
+}
+
+// SubmitOrder saves a draft as submitted with the approvals it needs, replacing those of an earlier
+// submission. It is numbered after the last purchase order of the year on its first submission, e.g.
+// PO-2025-0042 with prefix PO, and keeps its number when submitted again. It returns gorm.ErrRecordNotFound
+// when the order is no longer a draft.
+func (r *purchaseRepo) SubmitOrder(order *models.PurchaseOrder, prefix string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.PurchaseOrder
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "number").
+			Where("id = ? AND status = ?", order.ID, models.PurchaseOrderDraft).First(&current).Error
+		if err != nil {
+			return err
+		}
+		order.Number = current.Number
+		if order.Number == "" {
+			year := order.SubmittedAt.Year()
+			number, err := nextNumber(tx, fmt.Sprintf("%s-%d", prefix, year))
+			if err != nil {
+				return fmt.Errorf("failed to number purchase order %d: %w", order.ID, err)
+			}
+			order.Number = fmt.Sprintf("%s-%d-%04d", prefix, year, number)
+		}
+		err = tx.Model(&models.PurchaseOrder{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
+			"number":         order.Number,
+			"status":         order.Status,
+			"exchange_rate":  order.ExchangeRate,
+			"base_total":     order.BaseTotal,
+			"submitted_by":   order.SubmittedBy,
+			"submitted_at":   order.SubmittedAt,
+			"approved_at":    order.ApprovedAt,
+			"rejection_note": order.RejectionNote,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to submit purchase order %d: %w", order.ID, err)
+		}
+		if err := tx.Where("purchase_order_id = ?", order.ID).Delete(&models.PurchaseOrderApproval{}).Error; err != nil {
+			return err
+		}
+		if len(order.Approvals) == 0 {
+			return nil
+		}
+		for i := range order.Approvals {
+			order.Approvals[i].PurchaseOrderID = order.ID
+		}
+		return tx.Create(&order.Approvals).Error
+	})
+}
+
+// WithdrawOrder takes a submitted purchase order back to draft, dropping its approvals. It returns
+// gorm.ErrRecordNotFound when the order is no longer submitted.
+func (r *purchaseRepo) WithdrawOrder(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PurchaseOrder{}).
+			Where("id = ? AND status = ?", id, models.PurchaseOrderSubmitted).
+			Update("status", models.PurchaseOrderDraft)
+		if result.Error != nil {
+			return fmt.Errorf("failed to withdraw purchase order %d: %w", id, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("purchase_order_id = ?", id).Delete(&models.PurchaseOrderApproval{}).Error
+	})
+}
+
+// PlaceOrder marks an approved purchase order as ordered, it returns gorm.ErrRecordNotFound when the order
+// is no longer approved
+func (r *purchaseRepo) PlaceOrder(id uint, at time.Time) error {
+	result := r.DB.Model(&models.PurchaseOrder{}).
+		Where("id = ? AND status = ?", id, models.PurchaseOrderApproved).
+		Updates(map[string]interface{}{
+			"status":     models.PurchaseOrderOrdered,
+			"ordered_at": at,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to order purchase order %d: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CloseOrder closes a purchase order still in the from status, it returns gorm.ErrRecordNotFound when the
+// order moved on meanwhile
+func (r *purchaseRepo) CloseOrder(id uint, from models.PurchaseOrderStatus, userID uint, reason string, at time.Time) error {
+	result := r.DB.Model(&models.PurchaseOrder{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(map[string]interface{}{
+			"status":       models.PurchaseOrderClosed,
+			"closed_at":    at,
+			"closed_by":    userID,
+			"close_reason": reason,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to close purchase order %d: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DecideApproval approves or rejects a pending level of approval of a submitted purchase order. A rejection
+// sends the order back to draft, the last approval approves it. The order is locked while it is decided, a
+// level no longer pending or an order no longer submitted is not found.
+func (r *purchaseRepo) DecideApproval(orderID uint, level int, decision models.ApprovalDecision, userID uint, note string, at time.Time) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var order models.PurchaseOrder
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ?", orderID, models.PurchaseOrderSubmitted).First(&order).Error
+		if err != nil {
+			return err
+		}
+		result := tx.Model(&models.PurchaseOrderApproval{}).
+			Where("purchase_order_id = ? AND level = ? AND decision = ?", orderID, level, models.ApprovalPending).
+			Updates(map[string]interface{}{
+				"decision":   decision,
+				"decided_by": userID,
+				"decided_at": at,
+				"note":       note,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if decision == models.ApprovalRejected {
+			order.Status = models.PurchaseOrderDraft
+			order.RejectionNote = note
+			return tx.Omit(clause.Associations).Save(&order).Error
+		}
+		var pending int64
+		err = tx.Model(&models.PurchaseOrderApproval{}).
+			Where("purchase_order_id = ? AND decision = ?", orderID, models.ApprovalPending).Count(&pending).Error
+		if err != nil || pending > 0 {
+			return err
+		}
+		order.Status = models.PurchaseOrderApproved
+		order.ApprovedAt = &at
+		return tx.Omit(clause.Associations).Save(&order).Error
+	})
+}
+
+// ReceiveGoods records a goods received note against an ordered purchase order, numbering it after the last
+// one of the year, and adds what it received to the lines of the order, which is received once every line is.
+// The order is locked while the note is checked against it, an order that is not ordered or has less left
+// to receive on a line than the note is returned as it is and the note is not recorded, leaving its ID zero.
+func (r *purchaseRepo) ReceiveGoods(receipt *models.GoodsReceipt) (*models.PurchaseOrder, error) {
+	var order models.PurchaseOrder
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", receipt.PurchaseOrderID).First(&order).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("purchase_order_id = ?", order.ID).Order("position, id").Find(&order.Lines).Error; err != nil {
+			return err
+		}
+		if order.Status != models.PurchaseOrderOrdered {
+			return nil
+		}
+		lines := map[uint]*models.PurchaseOrderLine{}
+		for i := range order.Lines {
+			lines[order.Lines[i].ID] = &order.Lines[i]
+		}
+		for _, received := range receipt.Lines {
+			line, ok := lines[received.PurchaseOrderLineID]
+			if !ok || received.Quantity.GreaterThan(line.Outstanding()) {
+				return nil
+			}
+			line.QuantityReceived = line.QuantityReceived.Add(received.Quantity)
+		}
+
+		year := receipt.ReceivedOn.Year()
+		number, err := nextNumber(tx, fmt.Sprintf("%s-%d", receiptPrefix, year))
+		if err != nil {
+			return fmt.Errorf("failed to number goods receipt: %w", err)
+		}
+		receipt.Number = fmt.Sprintf("%s-%d-%04d", receiptPrefix, year, number)
+		if err := tx.Create(receipt).Error; err != nil {
+			return fmt.Errorf("failed to record goods receipt on purchase order %d: %w", order.ID, err)
+		}
+		for _, received := range receipt.Lines {
+			line := lines[received.PurchaseOrderLineID]
+			if err := tx.Model(line).Update("quantity_received", line.QuantityReceived).Error; err != nil {
+				return err
+			}
+		}
+		if !order.FullyReceived() {
+			return nil
+		}
+		now := time.Now().UTC()
+		order.Status = models.PurchaseOrderReceived
+		order.ReceivedAt = &now
+		return tx.Omit(clause.Associations).Save(&order).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *purchaseRepo) ListReceipts(orderID uint) ([]models.GoodsReceipt, error) {
+	var receipts []models.GoodsReceipt
+	err := r.DB.Preload("Lines").Where("purchase_order_id = ?", orderID).Order("received_on, id").Find(&receipts).Error
+	return receipts, err
+}
+
+// CreateBill matches a vendor bill against its purchase order and what was received on it and records it.
+// A matched bill adds what it bills to the lines of the order. The order is locked while the bill is matched
+// against it, an order that is neither ordered nor received is returned as it is and the bill is not
+// recorded, leaving its ID zero.
+func (r *purchaseRepo) CreateBill(bill *models.VendorBill, tolerance models.MatchTolerance) (*models.PurchaseOrder, error) {
+	var order models.PurchaseOrder
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", bill.PurchaseOrderID).First(&order).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("purchase_order_id = ?", order.ID).Order("position, id").Find(&order.Lines).Error; err != nil {
+			return err
+		}
+		if order.Status != models.PurchaseOrderOrdered && order.Status != models.PurchaseOrderReceived {
+			return nil
+		}
+		bill.Match(order.Lines, tolerance)
+		if err := tx.Create(bill).Error; err != nil {
+			return fmt.Errorf("failed to record bill on purchase order %d: %w", order.ID, err)
+		}
+		if !bill.Status.Counts() {
+			return nil
+		}
+		return addBilled(tx, &order, bill, bill.CreatedBy)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *purchaseRepo) FindBill(id uint) (*models.VendorBill, error) {
+	var bill models.VendorBill
+	if err := r.DB.Preload("Lines").Where("id = ?", id).First(&bill).Error; err != nil {
+		return nil, err
+	}
+	return &bill, nil
+}
+
+// FindBillByNumber finds the bill of a vendor with a number, rejected bills left out
+func (r *purchaseRepo) FindBillByNumber(vendorID uint, number string) (*models.VendorBill, error) {
+	var bill models.VendorBill
+	err := r.DB.Where("vendor_id = ? AND number = ? AND status <> ?", vendorID, number, models.BillRejected).First(&bill).Error
+	if err != nil {
+		return nil, err
+	}
+	return &bill, nil
+}
+
+func (r *purchaseRepo) ListBills(orderID uint) ([]models.VendorBill, error) {
+	var bills []models.VendorBill
+	err := r.DB.Preload("Lines").Where("purchase_order_id = ?", orderID).Order("bill_date, id").Find(&bills).Error
+	return bills, err
+}
+
+// ReviewBill approves or rejects a bill held as an exception, an approved bill then adds what it bills to the
+// lines of its purchase order. A bill that is no longer an exception is not found.
+func (r *purchaseRepo) ReviewBill(id uint, status models.VendorBillStatus, userID uint, note string, at time.Time) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var bill models.VendorBill
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ?", id, models.BillException).First(&bill).Error
+		if err != nil {
+			return err
+		}
+		bill.Status = status
+		bill.ReviewedBy = &userID
+		bill.ReviewedAt = &at
+		bill.ReviewNote = note
+		if err := tx.Omit(clause.Associations).Save(&bill).Error; err != nil {
+			return fmt.Errorf("failed to review bill %d: %w", id, err)
+		}
+		if !status.Counts() {
+			return nil
+		}
+
+		var order models.PurchaseOrder
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", bill.PurchaseOrderID).First(&order).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("purchase_order_id = ?", order.ID).Find(&order.Lines).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("vendor_bill_id = ?", bill.ID).Find(&bill.Lines).Error; err != nil {
+			return err
+		}
+		return addBilled(tx, &order, &bill, userID)
+	})
+}
+
+// addBilled adds what a bill bills to the lines of its purchase order, closing the order once it is received
+// and billed in full
+func addBilled(tx *gorm.DB, order *models.PurchaseOrder, bill *models.VendorBill, userID uint) error {
+	lines := map[uint]*models.PurchaseOrderLine{}
+	for i := range order.Lines {
+		lines[order.Lines[i].ID] = &order.Lines[i]
+	}
+	for _, billed := range bill.Lines {
+		line, ok := lines[billed.PurchaseOrderLineID]
+		if !ok {
+			continue
+		}
+		line.QuantityBilled = line.QuantityBilled.Add(billed.Quantity)
+		if err := tx.Model(line).Update("quantity_billed", line.QuantityBilled).Error; err != nil {
+			return err
+		}
+	}
+	if order.Status != models.PurchaseOrderReceived || !order.FullyBilled() {
+		return nil
+	}
+	now := time.Now().UTC()
+	order.Status = models.PurchaseOrderClosed
+	order.ClosedAt = &now
+	order.ClosedBy = &userID
+	order.CloseReason = "received and billed in full"
+	return tx.Omit(clause.Associations).Save(order).Error
+}
@@ -0,0 +1,270 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+type ReleaseRepository interface {
+	CreatePlan(plan *models.ReleasePlan) error
+	UpdatePlan(plan *models.ReleasePlan) error
+	DeletePlan(id uint) error
+	FindPlan(id uint) (*models.ReleasePlan, error)
+	FindTitlePlans(titleType models.TitleType, titleID uint) ([]models.ReleasePlan, error)
+	CreateFestival(festival *models.Festival) error
+	UpdateFestival(festival *models.Festival) error
+	FindFestival(id uint) (*models.Festival, error)
+	FindFestivalEdition(name string, edition int) (*models.Festival, error)
+	ListFestivals(query string, edition, limit, offset int) ([]models.Festival, int64, error)
+	CreateSubmission(submission *models.FestivalSubmission) error
+	UpdateSubmission(submission *models.FestivalSubmission) error
+	FindSubmission(id uint) (*models.FestivalSubmission, error)
+	FindSubmissionEntry(festivalID uint, titleType models.TitleType, titleID uint, category string) (*models.FestivalSubmission, error)
+	ListSubmissions(filter *models.SubmissionFilter) ([]models.FestivalSubmission, int64, error)
+	FindCalendarMilestones(filter *models.CalendarFilter) ([]models.ReleaseMilestone, error)
+	FindCalendarSubmissions(filter *models.CalendarFilter) ([]models.FestivalSubmission, error)
+	CreateFeed(feed *models.CalendarFeed) error
+	FindFeedByToken(token string) (*models.CalendarFeed, error)
+	FindUserFeeds(userID uint) ([]models.CalendarFeed, error)
+	DeleteFeed(id, userID uint) error
+}
+
+type releaseRepo struct {
+	DB *gorm.DB
+}
+
+func NewReleaseRepo(db *GormDB) ReleaseRepository {
+	return &releaseRepo{db.DB}
+}
+
+func (r *releaseRepo) CreatePlan(plan *models.ReleasePlan) error {
+	if err := r.DB.Create(plan).Error; err != nil {
+		return fmt.Errorf("failed to create release plan: %w", err)
+	}
+	return nil
+}
+
+// UpdatePlan saves a release plan, replacing its milestones
+func (r *releaseRepo) UpdatePlan(plan *models.ReleasePlan) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("plan_id = ?", plan.ID).Delete(&models.ReleaseMilestone{}).Error; err != nil {
+			return err
+		}
+		for i := range plan.Milestones {
+			plan.Milestones[i].ID = 0
+		}
+		if err := tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(plan).Error; err != nil {
+			return fmt.Errorf("failed to update release plan %d: %w", plan.ID, err)
+		}
+		return nil
+	})
+}
+
+func (r *releaseRepo) DeletePlan(id uint) error {
+	result := r.DB.Where("id = ?", id).Delete(&models.ReleasePlan{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func (r *releaseRepo) FindPlan(id uint) (*models.ReleasePlan, error) {
+	var plan models.ReleasePlan
+	err := r.DB.Preload("Milestones", func(db *gorm.DB) *gorm.DB {
+		return db.Order("date, id")
+	}).Where("id = ?", id).First(&plan).Error
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (r *releaseRepo) FindTitlePlans(titleType models.TitleType, titleID uint) ([]models.ReleasePlan, error) {
+	var plans []models.ReleasePlan
+	err := r.DB.Preload("Milestones", func(db *gorm.DB) *gorm.DB {
+		return db.Order("date, id")
+	}).Where("title_type = ? AND title_id = ?", titleType, titleID).Order("id").Find(&plans).Error
+	return plans, err
+}
+
+func (r *releaseRepo) CreateFestival(festival *models.Festival) error {
+	if err := r.DB.Create(festival).Error; err != nil {
+		return fmt.Errorf("failed to create festival: %w", err)
+	}
+	return nil
+}
+
+func (r *releaseRepo) UpdateFestival(festival *models.Festival) error {
+	return r.DB.Save(festival).Error
+}
+
+func (r *releaseRepo) FindFestival(id uint) (*models.Festival, error) {
+	var festival models.Festival
+	if err := r.DB.Where("id = ?", id).First(&festival).Error; err != nil {
+		return nil, err
+	}
+	return &festival, nil
+}
+
+func (r *releaseRepo) FindFestivalEdition(name string, edition int) (*models.Festival, error) {
+	var festival models.Festival
+	if err := r.DB.Where("LOWER(name) = LOWER(?) AND edition = ?", name, edition).First(&festival).Error; err != nil {
+		return nil, err
+	}
+	return &festival, nil
+}
+
+// ListFestivals returns a page of the festivals whose name matches query, soonest submission deadline first
+func (r *releaseRepo) ListFestivals(query string, edition, limit, offset int) ([]models.Festival, int64, error) {
+	db := r.DB.Model(&models.Festival{})
+	if query != "" {
+		db = db.Where("name ILIKE ?", "%"+query+"%")
+	}
+	if edition != 0 {
+		db = db.Where("edition = ?", edition)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var festivals []models.Festival
+	if err := db.Order("submission_deadline, name").Limit(limit).Offset(offset).Find(&festivals).Error; err != nil {
+		return nil, 0, err
+	}
+	return festivals, total, nil
+}
+
+func (r *releaseRepo) CreateSubmission(submission *models.FestivalSubmission) error {
+	if err := r.DB.Omit("Festival").Create(submission).Error; err != nil {
+		return fmt.Errorf("failed to create festival submission: %w", err)
+	}
+	return nil
+}
+
+func (r *releaseRepo) UpdateSubmission(submission *models.FestivalSubmission) error {
+	return r.DB.Omit("Festival").Save(submission).Error
+}
+
+func (r *releaseRepo) FindSubmission(id uint) (*models.FestivalSubmission, error) {
+	var submission models.FestivalSubmission
+	if err := r.DB.Preload("Festival").Where("id = ?", id).First(&submission).Error; err != nil {
+		return nil, err
+	}
+	return &submission, nil
+}
+
+func (r *releaseRepo) FindSubmissionEntry(festivalID uint, titleType models.TitleType, titleID uint, category string) (*models.FestivalSubmission, error) {
+	var submission models.FestivalSubmission
+	err := r.DB.Where("festival_id = ? AND title_type = ? AND title_id = ? AND LOWER(category) = LOWER(?)", festivalID, titleType, titleID, category).
+		First(&submission).Error
+	if err != nil {
+		return nil, err
+	}
+	return &submission, nil
+}
+
+// ListSubmissions returns a page of festival submissions, latest first, and the total count
+func (r *releaseRepo) ListSubmissions(filter *models.SubmissionFilter) ([]models.FestivalSubmission, int64, error) {
+	db := r.DB.Model(&models.FestivalSubmission{})
+	if filter.FestivalID != 0 {
+		db = db.Where("festival_id = ?", filter.FestivalID)
+	}
+	if filter.TitleType != "" {
+		db = db.Where("title_type = ? AND title_id = ?", filter.TitleType, filter.TitleID)
+	}
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var submissions []models.FestivalSubmission
+	err := db.Preload("Festival").Order("id DESC").Limit(filter.Limit).Offset((filter.Page - 1) * filter.Limit).Find(&submissions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return submissions, total, nil
+}
+
+// FindCalendarMilestones returns the milestones falling within the range of the filter, with their plan
+func (r *releaseRepo) FindCalendarMilestones(filter *models.CalendarFilter) ([]models.ReleaseMilestone, error) {
+	// the join leaves out deleted plans, not their milestones
+	db := r.DB.Joins("Plan").Where(`"Plan".id IS NOT NULL AND release_milestones.date BETWEEN ? AND ?`, filter.From, filter.To)
+	if filter.OwnerID != 0 {
+		db = db.Where(`"Plan".owner_id = ?`, filter.OwnerID)
+	}
+	if filter.Team != "" {
+		db = db.Where(`"Plan".team = ?`, filter.Team)
+	}
+	if filter.TitleType != "" {
+		db = db.Where(`"Plan".title_type = ? AND "Plan".title_id = ?`, filter.TitleType, filter.TitleID)
+	}
+
+	var milestones []models.ReleaseMilestone
+	err := db.Order("release_milestones.date, release_milestones.id").Find(&milestones).Error
+	return milestones, err
+}
+
+// FindCalendarSubmissions returns the festival submissions with something on within the range of the filter:
+// the deadlines of the planned ones and the festival days of the accepted ones
+func (r *releaseRepo) FindCalendarSubmissions(filter *models.CalendarFilter) ([]models.FestivalSubmission, error) {
+	db := r.DB.Joins("Festival").
+		Where(`(festival_submissions.status = @planned AND ("Festival".submission_deadline BETWEEN @from AND @to
+			OR "Festival".late_deadline BETWEEN @from AND @to))
+			OR (festival_submissions.status = @accepted AND "Festival".starts_on <= @to AND "Festival".ends_on >= @from)`,
+			map[string]interface{}{
+				"planned":  models.SubmissionPlanned,
+				"accepted": models.SubmissionAccepted,
+				"from":     filter.From,
+				"to":       filter.To,
+			}).
+		Where(`"Festival".id IS NOT NULL`)
+	if filter.OwnerID != 0 {
+		db = db.Where("festival_submissions.owner_id = ?", filter.OwnerID)
+	}
+	if filter.Team != "" {
+		db = db.Where("festival_submissions.team = ?", filter.Team)
+	}
+	if filter.TitleType != "" {
+		db = db.Where("festival_submissions.title_type = ? AND festival_submissions.title_id = ?", filter.TitleType, filter.TitleID)
+	}
+
+	var submissions []models.FestivalSubmission
+	err := db.Order("festival_submissions.id").Find(&submissions).Error
+	return submissions, err
+}
+
+func (r *releaseRepo) CreateFeed(feed *models.CalendarFeed) error {
+	if err := r.DB.Create(feed).Error; err != nil {
+		return fmt.Errorf("failed to create calendar feed: %w", err)
+	}
+	return nil
+}
+
+func (r *releaseRepo) FindFeedByToken(token string) (*models.CalendarFeed, error) {
+	var feed models.CalendarFeed
+	if err := r.DB.Where("token = ?", token).First(&feed).Error; err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
+
+func (r *releaseRepo) FindUserFeeds(userID uint) ([]models.CalendarFeed, error) {
+	var feeds []models.CalendarFeed
+	err := r.DB.Where("user_id = ?", userID).Order("id").Find(&feeds).Error
+	return feeds, err
+}
+
+// DeleteFeed revokes a feed of a user, its URL stops working
+func (r *releaseRepo) DeleteFeed(id, userID uint) error {
+	result := r.DB.Unscoped().Where("id = ? AND user_id = ?", id, userID).Delete(&models.CalendarFeed{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RevisionRepository interface {
+	FindTitleRecord(titleType models.TitleType, id uint) (*models.TitleRecord, error)
+	CreateRevision(revision *models.Revision, version int) error
+	ApplyRevision(revision *models.Revision, reviewerID uint) error
+	RejectRevision(revision *models.Revision, reviewerID uint) error
+	FindRevisions(titleType models.TitleType, titleID uint) ([]models.Revision, error)
+	FindRevision(titleType models.TitleType, titleID uint, number int) (*models.Revision, error)
+}
+
+type revisionRepo struct {
+	DB *gorm.DB
+}
+
+func NewRevisionRepo(db *GormDB) RevisionRepository {
+	return &revisionRepo{db.DB}
+}
+
+// metadataColumns are the columns of a title's table making up its metadata
+func metadataColumns(titleType models.TitleType) string {
+	if titleType == models.TitleTrailer {
+		return "id, title, log_line, description, duration, product_year, status, version"
+	}
+	return "id, title, description, duration, status, version"
+}
+
+// findRecord reads the metadata, status and version of a title that is not in the trash
+func findRecord(tx *gorm.DB, titleType models.TitleType, id uint) (*models.TitleRecord, error) {
+	var record models.TitleRecord
+	result := tx.Table(titleType.TableName()).
+		Select(metadataColumns(titleType)).
+		Where("id = ? AND deleted_at IS NULL", id).
+		Limit(1).
+		Scan(&record)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	record.TitleType = titleType
+	return &record, nil
+}
+
+func (r *revisionRepo) FindTitleRecord(titleType models.TitleType, id uint) (*models.TitleRecord, error) {
+	return findRecord(r.DB, titleType, id)
+}
+
+// CreateRevision numbers and saves a revision made on top of the given version of the title, diffing
+// it against the title's current metadata. It returns a *ConflictError when the title has moved on
+// since. An applied revision is written to the title in the same transaction. The first revision of
+// a title is preceded by one recording the metadata as it was.
+func (r *revisionRepo) CreateRevision(revision *models.Revision, version int) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		record, err := findRecord(tx.Clauses(clause.Locking{Strength: "UPDATE"}), revision.TitleType, revision.TitleID)
+		if err != nil {
+			return err
+		}
+		if record.Version != version {
+			return &apiError.ConflictError{Resource: string(revision.TitleType), ID: revision.TitleID, Version: version}
+		}
+		current := &record.TitleMetadata
+
+		var last int
+		err = tx.Model(&models.Revision{}).
+			Where("title_type = ? AND title_id = ?", revision.TitleType, revision.TitleID).
+			Select("COALESCE(MAX(number), 0)").
+			Scan(&last).Error
+		if err != nil {
+			return err
+		}
+		if last == 0 {
+			last++
+			baseline := &models.Revision{
+				TitleType: revision.TitleType,
+				TitleID:   revision.TitleID,
+				Number:    last,
+				Metadata:  *current,
+				Changes:   []models.FieldChange{},
+				Status:    models.RevisionApplied,
+			}
+			if err := tx.Create(baseline).Error; err != nil {
+				return fmt.Errorf("failed to record the original metadata: %w", err)
+			}
+		}
+
+		revision.Number = last + 1
+		revision.BaseVersion = version
+		revision.Changes = models.DiffMetadata(*current, revision.Metadata)
+		if revision.Status == models.RevisionApplied {
+			if err := updateMetadata(tx, revision); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(revision).Error; err != nil {
+			return fmt.Errorf("failed to create revision: %w", err)
+		}
+		return nil
+	})
+}
+
+// ApplyRevision writes a pending revision to its title. It returns a *ConflictError when the title
+// has been edited since the revision was requested, and gorm.ErrRecordNotFound when the revision
+// has been reviewed meanwhile.
+func (r *revisionRepo) ApplyRevision(revision *models.Revision, reviewerID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		record, err := findRecord(tx.Clauses(clause.Locking{Strength: "UPDATE"}), revision.TitleType, revision.TitleID)
+		if err != nil {
+			return err
+		}
+		if record.Version != revision.BaseVersion {
+			return &apiError.ConflictError{Resource: string(revision.TitleType), ID: revision.TitleID, Version: revision.BaseVersion}
+		}
+
+		now := time.Now()
+		result := tx.Model(&models.Revision{}).
+			Where("id = ? AND status = ?", revision.ID, models.RevisionPending).
+			Updates(map[string]interface{}{
+				"status":      models.RevisionApplied,
+				"reviewed_by": reviewerID,
+				"reviewed_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := updateMetadata(tx, revision); err != nil {
+			return err
+		}
+		revision.Status = models.RevisionApplied
+		revision.ReviewedBy = &reviewerID
+		revision.ReviewedAt = &now
+		return nil
+	})
+}
+
+func (r *revisionRepo) RejectRevision(revision *models.Revision, reviewerID uint) error {
+	now := time.Now()
+	revision.Status = models.RevisionRejected
+	revision.ReviewedBy = &reviewerID
+	revision.ReviewedAt = &now
+	return r.DB.Save(revision).Error
+}
+
+// updateMetadata writes the metadata of a revision to its title and bumps the title's version
+func updateMetadata(tx *gorm.DB, revision *models.Revision) error {
+	columns := revision.Metadata.Columns(revision.TitleType)
+	columns["version"] = gorm.Expr("version + 1")
+	err := tx.Table(revision.TitleType.TableName()).
+		Where("id = ?", revision.TitleID).
+		Updates(columns).Error
+	if err != nil {
+		return fmt.Errorf("failed to update %s %d: %w", revision.TitleType, revision.TitleID, err)
+	}
+	return nil
+}
+
+// FindRevisions returns the revisions of a title, latest first
+func (r *revisionRepo) FindRevisions(titleType models.TitleType, titleID uint) ([]models.Revision, error) {
+	var revisions []models.Revision
+	err := r.DB.Where("title_type = ? AND title_id = ?", titleType, titleID).Order("number DESC").Find(&revisions).Error
+	return revisions, err
+}
+
+func (r *revisionRepo) FindRevision(titleType models.TitleType, titleID uint, number int) (*models.Revision, error) {
+	var revision models.Revision
+	err := r.DB.Where("title_type = ? AND title_id = ? AND number = ?", titleType, titleID, number).First(&revision).Error
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
@@ -0,0 +1,259 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RightsRepository interface {
+	CreateLicensee(licensee *models.Licensee) error
+	UpdateLicensee(licensee *models.Licensee) error
+	FindLicensee(id uint) (*models.Licensee, error)
+	FindLicenseeByName(name string) (*models.Licensee, error)
+	ListLicensees(query string, limit, offset int) ([]models.Licensee, int64, error)
+	CreateGrant(grant *models.RightsGrant) ([]models.RightsConflict, error)
+	UpdateGrant(grant *models.RightsGrant) ([]models.RightsConflict, error)
+	DeleteGrant(id uint) error
+	FindGrant(id uint) (*models.RightsGrant, error)
+	FindTitleGrants(titleType models.TitleType, titleID uint) ([]models.RightsGrant, error)
+	FindAvailability(query *models.AvailabilityQuery) ([]models.TitleAvailability, int64, error)
+	FindExpiringGrants(before time.Time, unalertedOnly bool) ([]models.RightsGrant, error)
+	MarkExpiryAlerted(teamIDs, licenseeIDs []uint, at time.Time) error
+}
+
+type rightsRepo struct {
+	DB *gorm.DB
+}
+
+func NewRightsRepo(db *GormDB) RightsRepository {
+	return &rightsRepo{db.DB}
+}
+
+func (r *rightsRepo) CreateLicensee(licensee *models.Licensee) error {
+	if err := r.DB.Create(licensee).Error; err != nil {
+		return fmt.Errorf("failed to create licensee: %w", err)
+	}
+	return nil
+}
+
+func (r *rightsRepo) UpdateLicensee(licensee *models.Licensee) error {
+	return r.DB.Save(licensee).Error
+}
+
+func (r *rightsRepo) FindLicensee(id uint) (*models.Licensee, error) {
+	var licensee models.Licensee
+	if err := r.DB.Where("id = ?", id).First(&licensee).Error; err != nil {
+		return nil, err
+	}
+	return &licensee, nil
+}
+
+func (r *rightsRepo) FindLicenseeByName(name string) (*models.Licensee, error) {
+	var licensee models.Licensee
+	if err := r.DB.Where("LOWER(name) = LOWER(?)", name).First(&licensee).Error; err != nil {
+		return nil, err
+	}
+	return &licensee, nil
+}
+
+// ListLicensees returns a page of the licensees whose name matches query, and the total count
+func (r *rightsRepo) ListLicensees(query string, limit, offset int) ([]models.Licensee, int64, error) {
+	db := r.DB.Model(&models.Licensee{})
+	if query != "" {
+		db = db.Where("name ILIKE ?", "%"+query+"%")
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var licensees []models.Licensee
+	if err := db.Order("name").Limit(limit).Offset(offset).Find(&licensees).Error; err != nil {
+		return nil, 0, err
+	}
+	return licensees, total, nil
+}
+
+// CreateGrant saves a grant unless it clashes with the grants already made for the title, in which case
+// the clashes are returned and nothing is saved. The title row is locked so that two grants cannot slip
+// past each other.
+func (r *rightsRepo) CreateGrant(grant *models.RightsGrant) ([]models.RightsConflict, error) {
+	var conflicts []models.RightsConflict
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if conflicts, err = lockAndFindConflicts(tx, grant); err != nil || len(conflicts) > 0 {
+			return err
+		}
+		if err := tx.Omit("Licensee").Create(grant).Error; err != nil {
+			return fmt.Errorf("failed to create rights grant: %w", err)
+		}
+		return nil
+	})
+	return conflicts, err
+}
+
+// UpdateGrant saves the new terms and windows of a grant, checking them like CreateGrant does
+func (r *rightsRepo) UpdateGrant(grant *models.RightsGrant) ([]models.RightsConflict, error) {
+	var conflicts []models.RightsConflict
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if conflicts, err = lockAndFindConflicts(tx, grant); err != nil || len(conflicts) > 0 {
+			return err
+		}
+		if err := tx.Where("grant_id = ?", grant.ID).Delete(&models.RightsWindow{}).Error; err != nil {
+			return err
+		}
+		for i := range grant.Windows {
+			grant.Windows[i].ID = 0
+			grant.Windows[i].GrantID = grant.ID
+		}
+		if err := tx.Omit("Licensee").Session(&gorm.Session{FullSaveAssociations: true}).Save(grant).Error; err != nil {
+			return fmt.Errorf("failed to update rights grant %d: %w", grant.ID, err)
+		}
+		return nil
+	})
+	return conflicts, err
+}
+
+// lockAndFindConflicts locks the title of a grant and returns the windows of the other grants of the title
+// overlapping it in time, territory and medium where either side is exclusive
+func lockAndFindConflicts(tx *gorm.DB, grant *models.RightsGrant) ([]models.RightsConflict, error) {
+	if _, err := findRecord(tx.Clauses(clause.Locking{Strength: "UPDATE"}), grant.TitleType, grant.TitleID); err != nil {
+		return nil, err
+	}
+
+	var territories []string
+	var media []models.MediaRight
+	for _, window := range grant.Windows {
+		territories = append(territories, window.Territory)
+		media = append(media, window.Media)
+	}
+	db := tx.Table("rights_grants g").
+		Select("g.id AS grant_id, g.licensee_id, licensees.name AS licensee_name, w.territory, w.media, g.exclusive, g.start_date, g.end_date").
+		Joins("JOIN rights_windows w ON w.grant_id = g.id").
+		Joins("LEFT JOIN licensees ON licensees.id = g.licensee_id").
+		Where("g.deleted_at IS NULL AND g.title_type = ? AND g.title_id = ? AND g.id <> ?", grant.TitleType, grant.TitleID, grant.ID).
+		Where("w.territory IN ? AND w.media IN ?", territories, media).
+		Where("(g.end_date IS NULL OR g.end_date >= ?)", grant.StartDate)
+	if grant.EndDate != nil {
+		db = db.Where("g.start_date <= ?", *grant.EndDate)
+	}
+	if !grant.Exclusive {
+		db = db.Where("g.exclusive")
+	}
+
+	var conflicts []models.RightsConflict
+	err := db.Order("w.territory, w.media, g.start_date").Scan(&conflicts).Error
+	return conflicts, err
+}
+
+// DeleteGrant revokes a grant, its windows stop counting once the grant is in the trash
+func (r *rightsRepo) DeleteGrant(id uint) error {
+	result := r.DB.Where("id = ?", id).Delete(&models.RightsGrant{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func (r *rightsRepo) FindGrant(id uint) (*models.RightsGrant, error) {
+	var grant models.RightsGrant
+	err := r.DB.Preload("Licensee").Preload("Windows", func(db *gorm.DB) *gorm.DB {
+		return db.Order("territory, media")
+	}).Where("id = ?", id).First(&grant).Error
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// FindTitleGrants returns the grants of a title, latest first
+func (r *rightsRepo) FindTitleGrants(titleType models.TitleType, titleID uint) ([]models.RightsGrant, error) {
+	var grants []models.RightsGrant
+	err := r.DB.Preload("Licensee").Preload("Windows", func(db *gorm.DB) *gorm.DB {
+		return db.Order("territory, media")
+	}).Where("title_type = ? AND title_id = ?", titleType, titleID).
+		Order("start_date DESC, id DESC").
+		Find(&grants).Error
+	return grants, err
+}
+
+// availableTitles selects the published titles with no exclusive grant for @territory and @media
+// overlapping @from to @to, and whether they are free of any grant at all
+const availableTitles = `WITH titles AS (
+	SELECT 'trailer' AS title_type, id, title, status FROM trailers WHERE deleted_at IS NULL AND status <> 'Draft'
+	UNION ALL
+	SELECT 'full_length', id, title, status FROM full_lengths WHERE deleted_at IS NULL AND status <> 'Draft'
+), taken AS (
+	SELECT g.title_type, g.title_id, bool_or(g.exclusive) AS exclusive
+	FROM rights_grants g JOIN rights_windows w ON w.grant_id = g.id
+	WHERE g.deleted_at IS NULL AND w.territory = @territory AND w.media = @media
+		AND g.start_date <= @to AND (g.end_date IS NULL OR g.end_date >= @from )
+	GROUP BY g.title_type, g.title_id
+), available AS (
+	SELECT titles.*, taken.title_id IS NULL AS exclusive_available
+	FROM titles LEFT JOIN taken ON taken.title_type = titles.title_type AND taken.title_id = titles.id
+	WHERE taken.exclusive IS NOT TRUE
+)
+`
+
+// FindAvailability returns a page of the titles that can still be licensed for the query, and the total count
+func (r *rightsRepo) FindAvailability(query *models.AvailabilityQuery) ([]models.TitleAvailability, int64, error) {
+	args := map[string]interface{}{
+		"territory": query.Territory,
+		"media":     query.Media,
+		"from":      query.From,
+		"to":        query.To,
+		"limit":     query.Limit,
+		"offset":    (query.Page - 1) * query.Limit,
+	}
+
+	var total int64
+	if err := r.DB.Raw(availableTitles+"SELECT count(*) FROM available", args).Scan(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count available titles: %w", err)
+	}
+	var titles []models.TitleAvailability
+	err := r.DB.Raw(availableTitles+`SELECT * FROM available
+		ORDER BY title, title_type, id
+		LIMIT @limit OFFSET @offset `, args).Scan(&titles).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to find available titles: %w", err)
+	}
+	return titles, total, nil
+}
+
+// FindExpiringGrants returns the grants ending from today up to before, soonest first. With unalertedOnly
+// the grants the expiry alert went out for are left out.
+func (r *rightsRepo) FindExpiringGrants(before time.Time, unalertedOnly bool) ([]models.RightsGrant, error) {
+	db := r.DB.Preload("Licensee").Preload("Windows", func(db *gorm.DB) *gorm.DB {
+		return db.Order("territory, media")
+	}).Where("end_date >= CURRENT_DATE AND end_date <= ?", before)
+	if unalertedOnly {
+		db = db.Where("expiry_alerted_at IS NULL OR licensee_alerted_at IS NULL")
+	}
+
+	var grants []models.RightsGrant
+	err := db.Order("end_date, id").Find(&grants).Error
+	return grants, err
+}
+
+// MarkExpiryAlerted records the grants whose rights team alert and whose licensee alert went out
+func (r *rightsRepo) MarkExpiryAlerted(teamIDs, licenseeIDs []uint, at time.Time) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if len(teamIDs) > 0 {
+			if err := tx.Model(&models.RightsGrant{}).Where("id IN ?", teamIDs).Update("expiry_alerted_at", at).Error; err != nil {
+				return err
+			}
+		}
+		if len(licenseeIDs) > 0 {
+			if err := tx.Model(&models.RightsGrant{}).Where("id IN ?", licenseeIDs).Update("licensee_alerted_at", at).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
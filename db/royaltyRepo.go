@@ -0,0 +1,175 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+type RoyaltyRepository interface {
+	CreateRevenueReport(report *models.RevenueReport) error
+	FindRevenueReport(id uint) (*models.RevenueReport, error)
+	ListRevenueReports(limit, offset int) ([]models.RevenueReport, int64, error)
+	SumTitleRevenue(licenseeID uint, titles []models.ContractTitle, from, to time.Time) ([]models.TitleRevenue, error)
+	FindStraddlingReports(licenseeID uint, titles []models.ContractTitle, from, to time.Time) ([]models.RevenueReport, error)
+	SumShareBefore(contractID uint, before time.Time) (decimal.Decimal, error)
+	FindOverlappingStatements(contractID uint, from, to time.Time) ([]models.RoyaltyStatement, error)
+	CountStatementsAfter(contractID uint, after time.Time) (int64, error)
+	SaveStatement(statement *models.RoyaltyStatement) error
+	FindStatement(id uint) (*models.RoyaltyStatement, error)
+	FindContractStatements(contractID uint) ([]models.RoyaltyStatement, error)
+}
+
+type royaltyRepo struct {
+	DB *gorm.DB
+}
+
+func NewRoyaltyRepo(db *GormDB) RoyaltyRepository {
+	return &royaltyRepo{db.DB}
+}
+
+// CreateRevenueReport saves a report and its lines, in batches since reports can run to thousands of lines
+func (r *royaltyRepo) CreateRevenueReport(report *models.RevenueReport) error {
+	if err := r.DB.Session(&gorm.Session{CreateBatchSize: 500}).Create(report).Error; err != nil {
+		return fmt.Errorf("failed to create revenue report: %w", err)
+	}
+	return nil
+}
+
+func (r *royaltyRepo) FindRevenueReport(id uint) (*models.RevenueReport, error) {
+	var report models.RevenueReport
+	err := r.DB.Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Where("id = ?", id).First(&report).Error
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ListRevenueReports returns a page of the reports without their lines, latest period first, and the total count
+func (r *royaltyRepo) ListRevenueReports(limit, offset int) ([]models.RevenueReport, int64, error) {
+	var total int64
+	if err := r.DB.Model(&models.RevenueReport{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var reports []models.RevenueReport
+	err := r.DB.Order("period_end DESC, id DESC").Limit(limit).Offset(offset).Find(&reports).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return reports, total, nil
+}
+
+// SumTitleRevenue adds up the revenue of the given titles by currency, over the reports of a licensee falling within
+// from and to
+func (r *royaltyRepo) SumTitleRevenue(licenseeID uint, titles []models.ContractTitle, from, to time.Time) ([]models.TitleRevenue, error) {
+	var revenues []models.TitleRevenue
+	if len(titles) == 0 {
+		return revenues, nil
+	}
+
+	err := r.DB.Table("revenue_lines l").
+		Select("l.title_type, l.title_id, l.currency, revenue_reports.period_end AS reported_on, SUM(l.gross_revenue) AS gross_revenue, COUNT(*) AS lines").
+		Joins("JOIN revenue_reports ON revenue_reports.id = l.report_id").
+		Where("revenue_reports.deleted_at IS NULL AND revenue_reports.licensee_id = ?", licenseeID).
+		Where("revenue_reports.period_start >= ? AND revenue_reports.period_end <= ?", from, to).
+		Where("(l.title_type, l.title_id) IN ?", titlePairs(titles)).
+		Group("l.title_type, l.title_id, l.currency, revenue_reports.period_end").
+		Scan(&revenues).Error
+	return revenues, err
+}
+
+// FindStraddlingReports returns the reports of a licensee with revenue for the given titles whose period
+// overlaps from and to without falling within them
+func (r *royaltyRepo) FindStraddlingReports(licenseeID uint, titles []models.ContractTitle, from, to time.Time) ([]models.RevenueReport, error) {
+	var reports []models.RevenueReport
+	if len(titles) == 0 {
+		return reports, nil
+	}
+	err := r.DB.Where("licensee_id = ? AND period_start <= ? AND period_end >= ?", licenseeID, to, from).
+		Where("(period_start < ? OR period_end > ?)", from, to).
+		Where("EXISTS (SELECT 1 FROM revenue_lines l WHERE l.report_id = revenue_reports.id AND (l.title_type, l.title_id) IN ?)", titlePairs(titles)).
+		Order("period_start, id").
+		Find(&reports).Error
+	return reports, err
+}
+
+// titlePairs turns titles into the (title_type, title_id) rows of an IN condition
+func titlePairs(titles []models.ContractTitle) [][]interface{} {
+	pairs := make([][]interface{}, 0, len(titles))
+	for _, title := range titles {
+		pairs = append(pairs, []interface{}{title.TitleType, title.TitleID})
+	}
+	return pairs
+}
+
+// SumShareBefore adds up the revenue share of the statements of a contract for the periods ending before a date
+func (r *royaltyRepo) SumShareBefore(contractID uint, before time.Time) (decimal.Decimal, error) {
+	var total decimal.Decimal
+	err := r.DB.Model(&models.RoyaltyStatement{}).
+		Select("COALESCE(SUM(revenue_share), 0)").
+		Where("contract_id = ? AND period_end < ?", contractID, before).
+		Scan(&total).Error
+	return total, err
+}
+
+func (r *royaltyRepo) FindOverlappingStatements(contractID uint, from, to time.Time) ([]models.RoyaltyStatement, error) {
+	var statements []models.RoyaltyStatement
+	err := r.DB.Where("contract_id = ? AND period_start <= ? AND period_end >= ?", contractID, to, from).
+		Order("period_start").
+		Find(&statements).Error
+	return statements, err
+}
+
+func (r *royaltyRepo) CountStatementsAfter(contractID uint, after time.Time) (int64, error) {
+	var count int64
+	err := r.DB.Model(&models.RoyaltyStatement{}).Where("contract_id = ? AND period_start > ?", contractID, after).Count(&count).Error
+	return count, err
+}
+
+// SaveStatement stores a statement, replacing the one of the same contract and period
+func (r *royaltyRepo) SaveStatement(statement *models.RoyaltyStatement) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var previous []uint
+		err := tx.Model(&models.RoyaltyStatement{}).
+			Where("contract_id = ? AND period_start = ? AND period_end = ?", statement.ContractID, statement.PeriodStart, statement.PeriodEnd).
+			Pluck("id", &previous).Error
+		if err != nil {
+			return err
+		}
+		if len(previous) > 0 {
+			if err := tx.Where("statement_id IN ?", previous).Delete(&models.RoyaltyStatementLine{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("id IN ?", previous).Delete(&models.RoyaltyStatement{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Omit("Contract").Create(statement).Error; err != nil {
+			return fmt.Errorf("failed to create royalty statement: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *royaltyRepo) FindStatement(id uint) (*models.RoyaltyStatement, error) {
+	var statement models.RoyaltyStatement
+	err := r.DB.Preload("Contract.Licensee").Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Where("id = ?", id).First(&statement).Error
+	if err != nil {
+		return nil, err
+	}
+	return &statement, nil
+}
+
+// FindContractStatements returns the statements of a contract without their lines, in period order
+func (r *royaltyRepo) FindContractStatements(contractID uint) ([]models.RoyaltyStatement, error) {
+	var statements []models.RoyaltyStatement
+	err := r.DB.Where("contract_id = ?", contractID).Order("period_start").Find(&statements).Error
+	return statements, err
+}
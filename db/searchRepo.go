@@ -0,0 +1,150 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+type SearchRepository interface {
+	SearchTitles(query *models.SearchQuery) ([]models.TitleHit, int64, error)
+	TitleFacets(query *models.SearchQuery) (*models.SearchFacets, error)
+	SearchPeople(text string, limit int) ([]models.PersonHit, error)
+	SearchTerms(text string, limit int) ([]models.TermHit, error)
+}
+
+type searchRepo struct {
+	DB *gorm.DB
+}
+
+func NewSearchRepo(db *GormDB) SearchRepository {
+	return &searchRepo{db.DB}
+}
+
+// searchHighlight is how ts_headline marks the matched words in snippets
+const searchHighlight = "StartSel=<mark>, StopSel=</mark>"
+
+// titleMatches selects the trailers and full-length films matching @text, through the search vector or,
+// for misspelled titles, through trigram similarity. Full-length films have no log line nor production
+// year so the year of upload stands in.
+const titleMatches = `WITH matches AS (
+	SELECT 'trailer' AS title_type, t.id, t.title, t.status, t.product_year AS year,
+		concat_ws(' ', t.log_line, t.description) AS body,
+		ts_rank(t.search_vector, websearch_to_tsquery('english', @text)) + similarity(t.title, @text) AS rank
+	FROM trailers t
+	WHERE t.deleted_at IS NULL AND t.status <> 'Draft' AND (t.search_vector @@ websearch_to_tsquery('english', @text) OR t.title % @text )
+	UNION ALL
+	SELECT 'full_length', f.id, f.title, f.status, to_char(f.uploaded_at, 'YYYY'),
+		f.description,
+		ts_rank(f.search_vector, websearch_to_tsquery('english', @text)) + similarity(f.title, @text)
+	FROM full_lengths f
+	WHERE f.deleted_at IS NULL AND f.status <> 'Draft' AND (f.search_vector @@ websearch_to_tsquery('english', @text) OR f.title % @text )
+)
+`
+
+// titleFilter narrows the matches down to the facet values picked in the query. The facet named by
+// skip is left out, so that its counts still show the alternatives to the value picked.
+func titleFilter(skip string) string {
+	conditions := []string{"TRUE"}
+	if skip != "status" {
+		conditions = append(conditions, "(@status = '' OR matches.status = @status )")
+	}
+	if skip != "year" {
+		conditions = append(conditions, "(@year = '' OR matches.year = @year )")
+	}
+	if skip != "genre" {
+		conditions = append(conditions, `(@genre = '' OR EXISTS (SELECT 1 FROM title_terms
+			JOIN taxonomy_terms ON taxonomy_terms.id = title_terms.term_id
+			WHERE title_terms.title_type = matches.title_type AND title_terms.title_id = matches.id
+			AND taxonomy_terms.kind = 'genre' AND taxonomy_terms.slug = @genre ))`)
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+func searchArgs(query *models.SearchQuery) map[string]interface{} {
+	return map[string]interface{}{
+		"text":      query.Text,
+		"status":    string(query.Status),
+		"year":      query.Year,
+		"genre":     query.Genre,
+		"highlight": searchHighlight,
+		"limit":     query.Limit,
+		"offset":    (query.Page - 1) * query.Limit,
+	}
+}
+
+// SearchTitles returns a page of the titles matching the query, best matches first, and the total count
+func (r *searchRepo) SearchTitles(query *models.SearchQuery) ([]models.TitleHit, int64, error) {
+	args := searchArgs(query)
+
+	var total int64
+	if err := r.DB.Raw(titleMatches+"SELECT count(*) FROM matches"+titleFilter(""), args).Scan(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count search results: %w", err)
+	}
+
+	var hits []models.TitleHit
+	err := r.DB.Raw(titleMatches+`SELECT title_type, id, title, status, year, rank,
+		ts_headline('english', title, websearch_to_tsquery('english', @text), @highlight ) AS title_snippet,
+		ts_headline('english', body, websearch_to_tsquery('english', @text), @highlight ) AS snippet
+		FROM matches`+titleFilter("")+`
+		ORDER BY rank DESC, id
+		LIMIT @limit OFFSET @offset `, args).Scan(&hits).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search titles: %w", err)
+	}
+	return hits, total, nil
+}
+
+// TitleFacets counts the titles matching the query by status, genre and year
+func (r *searchRepo) TitleFacets(query *models.SearchQuery) (*models.SearchFacets, error) {
+	args := searchArgs(query)
+	facets := &models.SearchFacets{}
+
+	err := r.DB.Raw(titleMatches+"SELECT status AS value, count(*) AS count FROM matches"+titleFilter("status")+
+		" GROUP BY status ORDER BY count DESC", args).Scan(&facets.Status).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count statuses: %w", err)
+	}
+
+	err = r.DB.Raw(titleMatches+"SELECT year AS value, count(*) AS count FROM matches"+titleFilter("year")+
+		" AND matches.year <> '' GROUP BY year ORDER BY year DESC", args).Scan(&facets.Year).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count years: %w", err)
+	}
+
+	err = r.DB.Raw(titleMatches+`SELECT genres.slug AS value, genres.name AS label, count(*) AS count FROM matches
+		JOIN title_terms ON title_terms.title_type = matches.title_type AND title_terms.title_id = matches.id
+		JOIN taxonomy_terms genres ON genres.id = title_terms.term_id AND genres.kind = 'genre'`+titleFilter("genre")+
+		" GROUP BY genres.slug, genres.name ORDER BY count DESC, genres.name", args).Scan(&facets.Genre).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count genres: %w", err)
+	}
+	return facets, nil
+}
+
+// SearchPeople matches names, aliases and biographies, tolerating misspelled names
+func (r *searchRepo) SearchPeople(text string, limit int) ([]models.PersonHit, error) {
+	var hits []models.PersonHit
+	err := r.DB.Raw(`SELECT id, name, aliases, photo_url,
+		ts_rank(search_vector, websearch_to_tsquery('english', @text)) + similarity(name, @text) AS rank,
+		ts_headline('english', bio, websearch_to_tsquery('english', @text), @highlight ) AS snippet
+		FROM people
+		WHERE deleted_at IS NULL
+		AND (search_vector @@ websearch_to_tsquery('english', @text) OR name % @text OR aliases ILIKE '%' || @text || '%')
+		ORDER BY rank DESC, name
+		LIMIT @limit `, map[string]interface{}{"text": text, "highlight": searchHighlight, "limit": limit}).Scan(&hits).Error
+	return hits, err
+}
+
+// SearchTerms matches the names of genres, tags and the other taxonomy terms
+func (r *searchRepo) SearchTerms(text string, limit int) ([]models.TermHit, error) {
+	var hits []models.TermHit
+	err := r.DB.Raw(`SELECT id, kind, name, slug, similarity(name, @text) AS rank
+		FROM taxonomy_terms
+		WHERE deleted_at IS NULL AND (name % @text OR name ILIKE '%' || @text || '%')
+		ORDER BY rank DESC, name
+		LIMIT @limit `, map[string]interface{}{"text": text, "limit": limit}).Scan(&hits).Error
+	return hits, err
+}
@@ -0,0 +1,167 @@
+package db_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/techagentng/telair-erp/db"
+	"github.com/techagentng/telair-erp/db/dbtest"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+func createFilm(t *testing.T, conn *gorm.DB, title, description string, status models.MovieStatus, year int) *models.FullLength {
+	t.Helper()
+	film := &models.FullLength{MovieBase: models.MovieBase{
+		Title:       title,
+		Description: description,
+		Status:      status,
+		UploadedAt:  time.Date(year, time.June, 15, 12, 0, 0, 0, time.UTC),
+	}}
+	if err := conn.Create(film).Error; err != nil {
+		t.Fatalf("unable to create %q: %v", title, err)
+	}
+	return film
+}
+
+func searchTitles(t *testing.T, repo db.SearchRepository, query models.SearchQuery) []models.TitleHit {
+	t.Helper()
+	query.Page, query.Limit = 1, 20
+	hits, total, err := repo.SearchTitles(&query)
+	if err != nil {
+		t.Fatalf("SearchTitles(%q) error: %v", query.Text, err)
+	}
+	if total != int64(len(hits)) {
+		t.Fatalf("SearchTitles(%q) total = %d, want %d", query.Text, total, len(hits))
+	}
+	return hits
+}
+
+func hitIDs(hits []models.TitleHit) []uint {
+	ids := make([]uint, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func counts(facets []models.FacetCount) map[string]int64 {
+	byValue := map[string]int64{}
+	for _, facet := range facets {
+		byValue[facet.Value] = facet.Count
+	}
+	return byValue
+}
+
+func TestSearchTitles(t *testing.T) {
+	gdb := dbtest.Open(t)
+	repo := db.NewSearchRepo(gdb)
+
+	wedding := createFilm(t, gdb.DB, "The Lagos Wedding", "A comedy about a wedding that goes wrong", models.Approved, 2023)
+	roadTrip := createFilm(t, gdb.DB, "Lost in Kano", "A road trip that ends at a wedding", models.Pending, 2024)
+	harmattan := createFilm(t, gdb.DB, "Harmattan", "Dust settles over a northern town", models.Approved, 2024)
+	createFilm(t, gdb.DB, "Wedding Bells", "Still being uploaded", models.Draft, 2024)
+
+	comedy := &models.TaxonomyTerm{Kind: models.TaxonomyGenre, Name: "Comedy", Slug: "comedy"}
+	if err := gdb.DB.Create(comedy).Error; err != nil {
+		t.Fatalf("unable to create genre: %v", err)
+	}
+	if err := gdb.DB.Create(&models.TitleTerm{TitleType: models.TitleFullLength, TitleID: wedding.ID, TermID: comedy.ID}).Error; err != nil {
+		t.Fatalf("unable to tag title: %v", err)
+	}
+
+	t.Run("title matches rank above body matches", func(t *testing.T) {
+		hits := searchTitles(t, repo, models.SearchQuery{Text: "wedding"})
+		ids := hitIDs(hits)
+		if len(ids) != 2 || ids[0] != wedding.ID || ids[1] != roadTrip.ID {
+			t.Fatalf("hits = %v, want [%d %d] with the draft left out", ids, wedding.ID, roadTrip.ID)
+		}
+		if hits[0].Rank <= hits[1].Rank {
+			t.Errorf("rank of the title match %v is not above the body match %v", hits[0].Rank, hits[1].Rank)
+		}
+	})
+
+	t.Run("misspelled titles match by trigrams", func(t *testing.T) {
+		ids := hitIDs(searchTitles(t, repo, models.SearchQuery{Text: "Harmatan"}))
+		if len(ids) != 1 || ids[0] != harmattan.ID {
+			t.Fatalf("hits = %v, want [%d]", ids, harmattan.ID)
+		}
+	})
+
+	t.Run("snippets highlight the matched words", func(t *testing.T) {
+		hits := searchTitles(t, repo, models.SearchQuery{Text: "comedy"})
+		if len(hits) != 1 {
+			t.Fatalf("got %d hits, want 1", len(hits))
+		}
+		if !strings.Contains(hits[0].Snippet, "<mark>comedy</mark>") {
+			t.Errorf("snippet = %q, want comedy highlighted", hits[0].Snippet)
+		}
+
+		hits = searchTitles(t, repo, models.SearchQuery{Text: "wedding"})
+		if !strings.Contains(hits[0].TitleSnippet, "<mark>Wedding</mark>") {
+			t.Errorf("title snippet = %q, want Wedding highlighted", hits[0].TitleSnippet)
+		}
+	})
+
+	t.Run("facets count the matches", func(t *testing.T) {
+		facets, err := repo.TitleFacets(&models.SearchQuery{Text: "wedding", Page: 1, Limit: 20})
+		if err != nil {
+			t.Fatalf("TitleFacets error: %v", err)
+		}
+		status := counts(facets.Status)
+		if len(status) != 2 || status[string(models.Approved)] != 1 || status[string(models.Pending)] != 1 {
+			t.Errorf("status facet = %v, want one approved and one pending", status)
+		}
+		year := counts(facets.Year)
+		if len(year) != 2 || year["2023"] != 1 || year["2024"] != 1 {
+			t.Errorf("year facet = %v, want one in 2023 and one in 2024", year)
+		}
+		genre := counts(facets.Genre)
+		if len(genre) != 1 || genre["comedy"] != 1 {
+			t.Errorf("genre facet = %v, want one comedy", genre)
+		}
+	})
+
+	t.Run("facets apply the other picked facets", func(t *testing.T) {
+		facets, err := repo.TitleFacets(&models.SearchQuery{Text: "wedding", Genre: "comedy", Page: 1, Limit: 20})
+		if err != nil {
+			t.Fatalf("TitleFacets error: %v", err)
+		}
+		status := counts(facets.Status)
+		if len(status) != 1 || status[string(models.Approved)] != 1 {
+			t.Errorf("status facet = %v, want only the approved comedy", status)
+		}
+		genre := counts(facets.Genre)
+		if genre["comedy"] != 1 {
+			t.Errorf("genre facet = %v, want the picked genre still counted", genre)
+		}
+	})
+}
+
+func TestSearchTriggersRefreshTheIndex(t *testing.T) {
+	gdb := dbtest.Open(t)
+	repo := db.NewSearchRepo(gdb)
+
+	film := createFilm(t, gdb.DB, "Midnight Ferry", "Crossing the lagoon", models.Approved, 2024)
+	if ids := hitIDs(searchTitles(t, repo, models.SearchQuery{Text: "ferry"})); len(ids) != 1 || ids[0] != film.ID {
+		t.Fatalf("after insert hits = %v, want [%d]", ids, film.ID)
+	}
+
+	if err := gdb.DB.Model(film).Update("title", "Morning Boat").Error; err != nil {
+		t.Fatalf("unable to rename the title: %v", err)
+	}
+	if ids := hitIDs(searchTitles(t, repo, models.SearchQuery{Text: "ferry"})); len(ids) != 0 {
+		t.Errorf("after update the old title still matches: %v", ids)
+	}
+	if ids := hitIDs(searchTitles(t, repo, models.SearchQuery{Text: "boat"})); len(ids) != 1 || ids[0] != film.ID {
+		t.Errorf("after update hits = %v, want [%d]", ids, film.ID)
+	}
+
+	if err := gdb.DB.Delete(film).Error; err != nil {
+		t.Fatalf("unable to delete the title: %v", err)
+	}
+	if ids := hitIDs(searchTitles(t, repo, models.SearchQuery{Text: "boat"})); len(ids) != 0 {
+		t.Errorf("after soft delete hits = %v, want none", ids)
+	}
+}
@@ -0,0 +1,12 @@
+package db
+
+import "gorm.io/gorm"
+
+// nextNumber hands out the next number of a sequence, starting at 1. The sequence stays locked until tx
+// ends, so numbers come out in order and without gaps as long as the transactions using them commit.
+func nextNumber(tx *gorm.DB, name string) (int, error) {
+	var last int
+	err := tx.Raw(`INSERT INTO sequences (name, last) VALUES (?, 1)
+		ON CONFLICT (name) DO UPDATE SET last = sequences.last + 1 RETURNING last`, name).Scan(&last).Error
+	return last, err
+}
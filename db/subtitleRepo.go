@@ -0,0 +1,115 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+type SubtitleRepository interface {
+	SaveSubtitle(subtitle *models.Subtitle) error
+	FindSubtitle(id uint) (*models.Subtitle, error)
+	FindTitleSubtitles(titleType models.TitleType, titleID uint) ([]models.Subtitle, error)
+	ReplaceSubtitleAsset(subtitle *models.Subtitle, asset *models.MediaAsset) error
+	DeleteSubtitle(id uint) error
+}
+
+type subtitleRepo struct {
+	DB *gorm.DB
+}
+
+func NewSubtitleRepo(db *GormDB) SubtitleRepository {
+	return &subtitleRepo{db.DB}
+}
+
+// SaveSubtitle stores the subtitle of a title in a language, replacing the one already there
+func (r *subtitleRepo) SaveSubtitle(subtitle *models.Subtitle) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		return saveSubtitle(tx, subtitle)
+	})
+}
+
+// saveSubtitle does the work of SaveSubtitle inside tx. The replaced subtitle and its asset are removed
+// for good, the object stays in storage until the orphan reconciliation finds nothing uses it.
+func saveSubtitle(tx *gorm.DB, subtitle *models.Subtitle) error {
+	if subtitle.Asset != nil {
+		subtitle.AssetID = subtitle.Asset.ID
+	}
+
+	var existing models.Subtitle
+	err := tx.Where("title_type = ? AND title_id = ? AND language = ?", subtitle.TitleType, subtitle.TitleID, subtitle.Language).
+		First(&existing).Error
+	if err == nil {
+		if err := deleteSubtitle(tx, &existing, existing.AssetID != subtitle.AssetID); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if err := tx.Omit("Asset").Create(subtitle).Error; err != nil {
+		return fmt.Errorf("failed to create subtitle: %w", err)
+	}
+	return nil
+}
+
+func (r *subtitleRepo) FindSubtitle(id uint) (*models.Subtitle, error) {
+	var subtitle models.Subtitle
+	if err := r.DB.Preload("Asset").Where("id = ?", id).First(&subtitle).Error; err != nil {
+		return nil, err
+	}
+	return &subtitle, nil
+}
+
+func (r *subtitleRepo) FindTitleSubtitles(titleType models.TitleType, titleID uint) ([]models.Subtitle, error) {
+	var subtitles []models.Subtitle
+	err := r.DB.Preload("Asset").
+		Where("title_type = ? AND title_id = ?", titleType, titleID).
+		Order("language").
+		Find(&subtitles).Error
+	return subtitles, err
+}
+
+// ReplaceSubtitleAsset points a subtitle at a new file, the asset of the old one is removed
+func (r *subtitleRepo) ReplaceSubtitleAsset(subtitle *models.Subtitle, asset *models.MediaAsset) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if asset.ID == 0 {
+			if err := tx.Create(asset).Error; err != nil {
+				return fmt.Errorf("failed to create media asset: %w", err)
+			}
+		}
+		if subtitle.AssetID != asset.ID {
+			if err := tx.Unscoped().Where("id = ?", subtitle.AssetID).Delete(&models.MediaAsset{}).Error; err != nil {
+				return err
+			}
+		}
+		subtitle.AssetID = asset.ID
+		subtitle.Asset = asset
+		return tx.Model(&models.Subtitle{}).Where("id = ?", subtitle.ID).Updates(map[string]interface{}{
+			"asset_id": subtitle.AssetID,
+			"cues":     subtitle.Cues,
+		}).Error
+	})
+}
+
+// DeleteSubtitle removes a subtitle and its asset for good, freeing the language for a new upload
+func (r *subtitleRepo) DeleteSubtitle(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var subtitle models.Subtitle
+		if err := tx.Where("id = ?", id).First(&subtitle).Error; err != nil {
+			return err
+		}
+		return deleteSubtitle(tx, &subtitle, true)
+	})
+}
+
+func deleteSubtitle(tx *gorm.DB, subtitle *models.Subtitle, withAsset bool) error {
+	if withAsset {
+		if err := tx.Unscoped().Where("id = ?", subtitle.AssetID).Delete(&models.MediaAsset{}).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Unscoped().Where("id = ?", subtitle.ID).Delete(&models.Subtitle{}).Error
+}
@@ -0,0 +1,161 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TaxonomyRepository interface {
+	CreateTerm(term *models.TaxonomyTerm) error
+	UpdateTerm(term *models.TaxonomyTerm) error
+	DeleteTerm(id uint) error
+	FindTerm(id uint) (*models.TaxonomyTerm, error)
+	FindTermBySlug(kind models.TaxonomyKind, slug string) (*models.TaxonomyTerm, error)
+	FindTerms(ids []uint) ([]models.TaxonomyTerm, error)
+	ListTerms(kind models.TaxonomyKind) ([]models.TaxonomyTerm, error)
+	MergeTerms(sourceID, targetID uint) error
+	SetTitleTerms(titleType models.TitleType, titleID uint, termIDs []uint) error
+	FindTitleTerms(titleType models.TitleType, titleID uint) ([]models.TaxonomyTerm, error)
+	ListTitles(titleType models.TitleType, filter *models.TitleFilter) ([]models.TitleSummary, int64, error)
+}
+
+type taxonomyRepo struct {
+	DB *gorm.DB
+}
+
+func NewTaxonomyRepo(db *GormDB) TaxonomyRepository {
+	return &taxonomyRepo{db.DB}
+}
+
+func (r *taxonomyRepo) CreateTerm(term *models.TaxonomyTerm) error {
+	if err := r.DB.Create(term).Error; err != nil {
+		return fmt.Errorf("failed to create taxonomy term: %w", err)
+	}
+	return nil
+}
+
+func (r *taxonomyRepo) UpdateTerm(term *models.TaxonomyTerm) error {
+	return r.DB.Save(term).Error
+}
+
+// DeleteTerm removes a term for good, freeing its slug, and unlinks it from every title
+func (r *taxonomyRepo) DeleteTerm(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("term_id = ?", id).Delete(&models.TitleTerm{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TaxonomyTerm{}).Where("parent_id = ?", id).Update("parent_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("id = ?", id).Delete(&models.TaxonomyTerm{}).Error
+	})
+}
+
+func (r *taxonomyRepo) FindTerm(id uint) (*models.TaxonomyTerm, error) {
+	var term models.TaxonomyTerm
+	if err := r.DB.Where("id = ?", id).First(&term).Error; err != nil {
+		return nil, err
+	}
+	return &term, nil
+}
+
+func (r *taxonomyRepo) FindTermBySlug(kind models.TaxonomyKind, slug string) (*models.TaxonomyTerm, error) {
+	var term models.TaxonomyTerm
+	if err := r.DB.Where("kind = ? AND slug = ?", kind, slug).First(&term).Error; err != nil {
+		return nil, err
+	}
+	return &term, nil
+}
+
+func (r *taxonomyRepo) FindTerms(ids []uint) ([]models.TaxonomyTerm, error) {
+	var terms []models.TaxonomyTerm
+	if len(ids) == 0 {
+		return terms, nil
+	}
+	err := r.DB.Where("id IN ?", ids).Find(&terms).Error
+	return terms, err
+}
+
+func (r *taxonomyRepo) ListTerms(kind models.TaxonomyKind) ([]models.TaxonomyTerm, error) {
+	var terms []models.TaxonomyTerm
+	err := r.DB.Where("kind = ?", kind).Order("name").Find(&terms).Error
+	return terms, err
+}
+
+// MergeTerms moves every title and sub-genre of the source term over to the target and removes the source
+func (r *taxonomyRepo) MergeTerms(sourceID, targetID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		// Titles carrying both terms keep a single link
+		err := tx.Exec(`INSERT INTO title_terms (title_type, title_id, term_id)
+			SELECT title_type, title_id, ? FROM title_terms WHERE term_id = ?
+			ON CONFLICT DO NOTHING`, targetID, sourceID).Error
+		if err != nil {
+			return fmt.Errorf("failed to move titles to term %d: %w", targetID, err)
+		}
+		if err := tx.Where("term_id = ?", sourceID).Delete(&models.TitleTerm{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.TaxonomyTerm{}).Where("parent_id = ?", sourceID).Update("parent_id", targetID).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("id = ?", sourceID).Delete(&models.TaxonomyTerm{}).Error
+	})
+}
+
+// SetTitleTerms replaces the terms of a title
+func (r *taxonomyRepo) SetTitleTerms(titleType models.TitleType, titleID uint, termIDs []uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("title_type = ? AND title_id = ?", titleType, titleID).Delete(&models.TitleTerm{}).Error; err != nil {
+			return err
+		}
+		if len(termIDs) == 0 {
+			return nil
+		}
+		links := make([]models.TitleTerm, 0, len(termIDs))
+		for _, termID := range termIDs {
+			links = append(links, models.TitleTerm{TitleType: titleType, TitleID: titleID, TermID: termID})
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error
+	})
+}
+
+func (r *taxonomyRepo) FindTitleTerms(titleType models.TitleType, titleID uint) ([]models.TaxonomyTerm, error) {
+	var terms []models.TaxonomyTerm
+	err := r.DB.Joins("JOIN title_terms ON title_terms.term_id = taxonomy_terms.id").
+		Where("title_terms.title_type = ? AND title_terms.title_id = ?", titleType, titleID).
+		Order("taxonomy_terms.kind, taxonomy_terms.name").
+		Find(&terms).Error
+	return terms, err
+}
+
+// ListTitles returns a page of the published titles of a type matching the filter, and the total count
+func (r *taxonomyRepo) ListTitles(titleType models.TitleType, filter *models.TitleFilter) ([]models.TitleSummary, int64, error) {
+	db := r.DB.Table(titleType.TableName()+" AS titles").Where("titles.deleted_at IS NULL AND titles.status <> ?", models.Draft)
+	for kind, slugs := range filter.Terms {
+		if len(slugs) == 0 {
+			continue
+		}
+		db = db.Where(`EXISTS (SELECT 1 FROM title_terms JOIN taxonomy_terms ON taxonomy_terms.id = title_terms.term_id
+			WHERE title_terms.title_type = ? AND title_terms.title_id = titles.id
+			AND taxonomy_terms.kind = ? AND taxonomy_terms.slug IN ?)`, titleType, kind, slugs)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var titles []models.TitleSummary
+	err := db.Select("titles.*, ? AS title_type", titleType).
+		Order("titles.uploaded_at DESC").
+		Limit(filter.Limit).
+		Offset((filter.Page - 1) * filter.Limit).
+		Scan(&titles).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return titles, total, nil
+}
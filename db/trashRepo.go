@@ -0,0 +1,251 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+type TrashRepository interface {
+	DeleteTitle(titleType models.TitleType, id uint) error
+	RestoreTitle(titleType models.TitleType, id uint) error
+	DeleteUser(id uint) error
+	RestoreUser(id uint) error
+	FindTrash(trashType string, limit, offset int) ([]models.TrashItem, int64, error)
+	FindDeletedTitles(titleType models.TitleType, before time.Time) ([]uint, error)
+	PurgeTitle(titleType models.TitleType, id uint) ([]string, error)
+	FindDeletedUsers(before time.Time) ([]models.User, error)
+	PurgeUser(id uint) error
+}
+
+type trashRepo struct {
+	DB *gorm.DB
+}
+
+func NewTrashRepo(db *GormDB) TrashRepository {
+	return &trashRepo{db.DB}
+}
+
+// titleModel returns the model stored in the table of a title type
+func titleModel(titleType models.TitleType) (interface{}, error) {
+	switch titleType {
+	case models.TitleTrailer:
+		return &models.Trailer{}, nil
+	case models.TitleFullLength:
+		return &models.FullLength{}, nil
+	}
+	return nil, fmt.Errorf("unknown title type: %s", titleType)
+}
+
+// DeleteTitle moves a title to the trash
+func (r *trashRepo) DeleteTitle(titleType models.TitleType, id uint) error {
+	model, err := titleModel(titleType)
+	if err != nil {
+		return err
+	}
+	result := r.DB.Where("id = ?", id).Delete(model)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// RestoreTitle takes a title back out of the trash
+func (r *trashRepo) RestoreTitle(titleType models.TitleType, id uint) error {
+	model, err := titleModel(titleType)
+	if err != nil {
+		return err
+	}
+	return restore(r.DB.Model(model), id)
+}
+
+func (r *trashRepo) DeleteUser(id uint) error {
+	result := r.DB.Where("id = ?", id).Delete(&models.User{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func (r *trashRepo) RestoreUser(id uint) error {
+	return restore(r.DB.Model(&models.User{}), id)
+}
+
+// restore clears deleted_at on a row in the trash, it returns gorm.ErrRecordNotFound when there is none
+func restore(db *gorm.DB, id uint) error {
+	result := db.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).Update("deleted_at", nil)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// FindTrash returns a page of the deleted records of a type, most recently deleted first, and the total count
+func (r *trashRepo) FindTrash(trashType string, limit, offset int) ([]models.TrashItem, int64, error) {
+	table, name := "users", "fullname"
+	if trashType != models.TrashUser {
+		table, name = models.TitleType(trashType).TableName(), "title"
+	}
+	db := r.DB.Table(table).Where("deleted_at IS NOT NULL")
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var items []models.TrashItem
+	err := db.Select("? AS type, id, "+name+" AS name, deleted_at", trashType).
+		Order("deleted_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Scan(&items).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// titleKeepers are the tables of financial records that must outlive the titles they name, a title
+// they reference stays in the trash until they are dealt with
+var titleKeepers = []string{"contract_titles", "revenue_lines", "royalty_statement_lines", "budgets"}
+
+// FindDeletedTitles returns the IDs of the titles of a type put in the trash before the given time. Titles
+// still referenced by financial records are left out, purging them would orphan those records.
+func (r *trashRepo) FindDeletedTitles(titleType models.TitleType, before time.Time) ([]uint, error) {
+	var ids []uint
+	query := r.DB.Table(titleType.TableName()).Where("deleted_at < ?", before)
+	for _, table := range titleKeepers {
+		query = query.Where(fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %[1]s WHERE %[1]s.title_type = ? AND %[1]s.title_id = %[2]s.id)",
+			table, titleType.TableName()), titleType)
+	}
+	err := query.Pluck("id", &ids).Error
+	return ids, err
+}
+
+// PurgeTitle removes a title for good along with its media assets, subtitles, credits, taxonomy links, revisions,
+// rights grants, release plans, festival submissions, deal links and activities. It returns the storage keys of
+// the removed assets, which may still be shared with other titles. A title still referenced by financial records
+// is refused.
+func (r *trashRepo) PurgeTitle(titleType models.TitleType, id uint) ([]string, error) {
+	model, err := titleModel(titleType)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	err = r.DB.Transaction(func(tx *gorm.DB) error {
+		for _, table := range titleKeepers {
+			var count int64
+			if err := tx.Table(table).Where("title_type = ? AND title_id = ?", titleType, id).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("%s %d is still referenced by %s", titleType, id, table)
+			}
+		}
+
+		err := tx.Model(&models.MediaAsset{}).Unscoped().
+			Where("title_type = ? AND title_id = ?", titleType, id).
+			Pluck("storage_key", &keys).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec(`DELETE FROM rights_windows WHERE grant_id IN
+			(SELECT id FROM rights_grants WHERE title_type = ? AND title_id = ?)`, titleType, id).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec(`DELETE FROM release_milestones WHERE plan_id IN
+			(SELECT id FROM release_plans WHERE title_type = ? AND title_id = ?)`, titleType, id).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Exec(`DELETE FROM press_kit_assets WHERE asset_id IN
+			(SELECT id FROM media_assets WHERE title_type = ? AND title_id = ?)`, titleType, id).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Unscoped().Where("subject_type = ? AND subject_id = ?", titleType.TableName(), id).Delete(&models.Activity{}).Error
+		if err != nil {
+			return err
+		}
+		for _, link := range []interface{}{&models.PressKitAsset{}, &models.MediaAsset{}, &models.Credit{}, &models.TitleTerm{}, &models.Revision{}, &models.Subtitle{}, &models.RightsGrant{}, &models.ReleasePlan{}, &models.FestivalSubmission{}, &models.DealTitle{}} {
+			if err := tx.Unscoped().Where("title_type = ? AND title_id = ?", titleType, id).Delete(link).Error; err != nil {
+				return fmt.Errorf("failed to purge %s %d: %w", titleType, id, err)
+			}
+		}
+		return tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).Delete(model).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// userKeepers are the columns naming a user on records that must outlive the user, e.g. who raised an
+// invoice or posted a journal entry. A user they reference stays in the trash until the records are
+// handed to someone else.
+var userKeepers = []struct{ table, column string }{
+	{"trailers", "user_id"},
+	{"contacts", "owner_id"},
+	{"organizations", "owner_id"},
+	{"deals", "owner_id"},
+	{"deal_stage_changes", "changed_by"},
+	{"activities", "author_id"},
+	{"activities", "assignee_id"},
+	{"activities", "completed_by"},
+	{"release_plans", "owner_id"},
+	{"festival_submissions", "owner_id"},
+	{"revenue_reports", "uploaded_by"},
+	{"royalty_statements", "generated_by"},
+	{"budgets", "created_by"},
+	{"budgets", "approved_by"},
+	{"cost_entries", "created_by"},
+	{"cost_entries", "reviewed_by"},
+	{"exchange_rates", "created_by"},
+	{"invoices", "created_by"},
+	{"invoice_payments", "recorded_by"},
+	{"journal_entries", "created_by"},
+	{"journal_entries", "posted_by"},
+	{"accounting_periods", "closed_by"},
+	{"purchase_orders", "created_by"},
+	{"purchase_orders", "submitted_by"},
+	{"purchase_orders", "closed_by"},
+	{"purchase_order_approvals", "decided_by"},
+	{"goods_receipts", "received_by"},
+	{"vendor_bills", "created_by"},
+	{"vendor_bills", "reviewed_by"},
+}
+
+// FindDeletedUsers returns the users put in the trash before the given time. Users still referenced by
+// trailers or by business records are left out, purging them would leave those records pointing nowhere.
+func (r *trashRepo) FindDeletedUsers(before time.Time) ([]models.User, error) {
+	var users []models.User
+	query := r.DB.Unscoped().Where("deleted_at < ?", before)
+	for _, keeper := range userKeepers {
+		query = query.Where(fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %[1]s WHERE %[1]s.%[2]s = users.id)", keeper.table, keeper.column))
+	}
+	err := query.Find(&users).Error
+	return users, err
+}
+
+// PurgeUser removes a user for good along with their calendar feeds, a user still referenced by trailers or
+// business records is refused
+func (r *trashRepo) PurgeUser(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		for _, keeper := range userKeepers {
+			var count int64
+			if err := tx.Table(keeper.table).Where(keeper.column+" = ?", id).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("user %d is still referenced by %s.%s", id, keeper.table, keeper.column)
+			}
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&models.CalendarFeed{}).Error; err != nil {
+			return fmt.Errorf("failed to purge user %d: %w", id, err)
+		}
+		return tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).Delete(&models.User{}).Error
+	})
+}
@@ -0,0 +1,238 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UploadRepository interface {
+	CreateTusUpload(upload *models.TusUpload) error
+	FindTusUpload(id string) (*models.TusUpload, error)
+	AcquireTusUpload(id, token string, until time.Time) (*models.TusUpload, error)
+	RenewTusUpload(id, token string, until time.Time) error
+	SaveTusProgress(upload *models.TusUpload, token string, part *models.TusUploadPart) error
+	ReleaseTusUpload(id, token string) error
+	DeleteTusUpload(id string) error
+	FindTusUploadParts(uploadID string) ([]models.TusUploadPart, error)
+	FindExpiredTusUploads(before time.Time) ([]models.TusUpload, error)
+	CreateDirectUpload(upload *models.DirectUpload) error
+	FindDirectUpload(id string) (*models.DirectUpload, error)
+	UpdateDirectUpload(upload *models.DirectUpload) error
+	DeleteDirectUpload(id string) error
+	SaveDirectUploadPart(part *models.DirectUploadPart) error
+	FindDirectUploadParts(uploadID string) ([]models.DirectUploadPart, error)
+	FindExpiredDirectUploads(before time.Time) ([]models.DirectUpload, error)
+	FindReferencedKeys(keys []string) (map[string]bool, error)
+}
+
+// ErrUploadLocked is returned when another request holds the lease on an upload
+var ErrUploadLocked = errors.New("upload is locked by another request")
+
+type uploadRepo struct {
+	DB *gorm.DB
+}
+
+func NewUploadRepo(db *GormDB) UploadRepository {
+	return &uploadRepo{db.DB}
+}
+
+func (r *uploadRepo) CreateTusUpload(upload *models.TusUpload) error {
+	if upload == nil {
+		return errors.New("upload cannot be nil")
+	}
+	if err := r.DB.Create(upload).Error; err != nil {
+		return fmt.Errorf("failed to create upload: %w", err)
+	}
+	return nil
+}
+
+func (r *uploadRepo) FindTusUpload(id string) (*models.TusUpload, error) {
+	var upload models.TusUpload
+	if err := r.DB.Where("id = ?", id).First(&upload).Error; err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// AcquireTusUpload takes the lease on an upload under token until the given time and returns the upload.
+// An upload leased by another request gives ErrUploadLocked rather than a wait, a lease that ran out, e.g.
+// when the server holding it went down, is taken over.
+func (r *uploadRepo) AcquireTusUpload(id, token string, until time.Time) (*models.TusUpload, error) {
+	result := r.DB.Model(&models.TusUpload{}).
+		Where("id = ? AND (leased_until IS NULL OR leased_until < ?)", id, time.Now()).
+		Updates(map[string]interface{}{"lease_token": token, "leased_until": until})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to lease upload %s: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := r.DB.Model(&models.TusUpload{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, ErrUploadLocked
+		}
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.FindTusUpload(id)
+}
+
+// RenewTusUpload extends the lease held under token, it returns ErrUploadLocked once the lease is lost
+func (r *uploadRepo) RenewTusUpload(id, token string, until time.Time) error {
+	result := r.DB.Model(&models.TusUpload{}).
+		Where("id = ? AND lease_token = ?", id, token).
+		Update("leased_until", until)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUploadLocked
+	}
+	return nil
+}
+
+// SaveTusProgress records the part an upload just sent to storage, if any, together with its new offset,
+// so the parts on file never run ahead of the offset a client resumes from. It returns ErrUploadLocked
+// when the lease held under token is lost and records nothing then.
+func (r *uploadRepo) SaveTusProgress(upload *models.TusUpload, token string, part *models.TusUploadPart) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if part != nil {
+			if err := tx.Create(part).Error; err != nil {
+				return fmt.Errorf("failed to record upload part: %w", err)
+			}
+		}
+		result := tx.Model(&models.TusUpload{}).
+			Where("id = ? AND lease_token = ?", upload.ID, token).
+			Updates(map[string]interface{}{
+				"offset":         upload.Offset,
+				"content_type":   upload.ContentType,
+				"pending_key":    upload.PendingKey,
+				"pending_size":   upload.PendingSize,
+				"hash_state":     upload.HashState,
+				"completed":      upload.Completed,
+				"media_asset_id": upload.MediaAssetID,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to record progress of upload %s: %w", upload.ID, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrUploadLocked
+		}
+		return nil
+	})
+}
+
+// ReleaseTusUpload gives up the lease held under token
+func (r *uploadRepo) ReleaseTusUpload(id, token string) error {
+	return r.DB.Model(&models.TusUpload{}).
+		Where("id = ? AND lease_token = ?", id, token).
+		Updates(map[string]interface{}{"lease_token": "", "leased_until": nil}).Error
+}
+
+// DeleteTusUpload removes the upload together with its recorded parts
+func (r *uploadRepo) DeleteTusUpload(id string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("upload_id = ?", id).Delete(&models.TusUploadPart{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&models.TusUpload{}).Error
+	})
+}
+
+func (r *uploadRepo) FindTusUploadParts(uploadID string) ([]models.TusUploadPart, error) {
+	var parts []models.TusUploadPart
+	err := r.DB.Where("upload_id = ?", uploadID).Order("part_number").Find(&parts).Error
+	return parts, err
+}
+
+// FindExpiredTusUploads returns unfinished uploads that expired before the given time
+func (r *uploadRepo) FindExpiredTusUploads(before time.Time) ([]models.TusUpload, error) {
+	var uploads []models.TusUpload
+	err := r.DB.Where("completed = ? AND expires_at < ?", false, before).Find(&uploads).Error
+	return uploads, err
+}
+
+func (r *uploadRepo) CreateDirectUpload(upload *models.DirectUpload) error {
+	if upload == nil {
+		return errors.New("upload cannot be nil")
+	}
+	if err := r.DB.Create(upload).Error; err != nil {
+		return fmt.Errorf("failed to create upload: %w", err)
+	}
+	return nil
+}
+
+func (r *uploadRepo) FindDirectUpload(id string) (*models.DirectUpload, error) {
+	var upload models.DirectUpload
+	if err := r.DB.Where("id = ?", id).First(&upload).Error; err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+func (r *uploadRepo) UpdateDirectUpload(upload *models.DirectUpload) error {
+	return r.DB.Save(upload).Error
+}
+
+// DeleteDirectUpload removes the upload together with its signed parts
+func (r *uploadRepo) DeleteDirectUpload(id string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("upload_id = ?", id).Delete(&models.DirectUploadPart{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&models.DirectUpload{}).Error
+	})
+}
+
+// SaveDirectUploadPart records a part, signing the same part again replaces its size and checksum
+func (r *uploadRepo) SaveDirectUploadPart(part *models.DirectUploadPart) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "upload_id"}, {Name: "part_number"}},
+		DoUpdates: clause.AssignmentColumns([]string{"size", "checksum_sha256"}),
+	}).Create(part).Error
+}
+
+func (r *uploadRepo) FindDirectUploadParts(uploadID string) ([]models.DirectUploadPart, error) {
+	var parts []models.DirectUploadPart
+	err := r.DB.Where("upload_id = ?", uploadID).Order("part_number").Find(&parts).Error
+	return parts, err
+}
+
+// FindExpiredDirectUploads returns unfinished direct uploads that expired before the given time
+func (r *uploadRepo) FindExpiredDirectUploads(before time.Time) ([]models.DirectUpload, error) {
+	var uploads []models.DirectUpload
+	err := r.DB.Where("completed = ? AND expires_at < ?", false, before).Find(&uploads).Error
+	return uploads, err
+}
+
+// FindReferencedKeys returns which of keys are used by a media asset or an upload in progress
+func (r *uploadRepo) FindReferencedKeys(keys []string) (map[string]bool, error) {
+	referenced := map[string]bool{}
+	if len(keys) == 0 {
+		return referenced, nil
+	}
+	lookups := []struct {
+		model  interface{}
+		column string
+	}{
+		{&models.MediaAsset{}, "storage_key"},
+		{&models.TusUpload{}, "storage_key"},
+		{&models.TusUpload{}, "pending_key"},
+		{&models.DirectUpload{}, "storage_key"},
+	}
+	for _, lookup := range lookups {
+		var found []string
+		if err := r.DB.Model(lookup.model).Where(lookup.column+" IN ?", keys).Pluck(lookup.column, &found).Error; err != nil {
+			return nil, fmt.Errorf("failed to look up storage keys: %w", err)
+		}
+		for _, key := range found {
+			referenced[key] = true
+		}
+	}
+	return referenced, nil
+}
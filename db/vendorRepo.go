@@ -0,0 +1,69 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+type VendorRepository interface {
+	CreateVendor(vendor *models.Vendor) error
+	UpdateVendor(vendor *models.Vendor) error
+	FindVendor(id uint) (*models.Vendor, error)
+	FindVendorByName(name string) (*models.Vendor, error)
+	ListVendors(query string, limit, offset int) ([]models.Vendor, int64, error)
+}
+
+type vendorRepo struct {
+	DB *gorm.DB
+}
+
+func NewVendorRepo(db *GormDB) VendorRepository {
+	return &vendorRepo{db.DB}
+}
+
+func (r *vendorRepo) CreateVendor(vendor *models.Vendor) error {
+	if err := r.DB.Create(vendor).Error; err != nil {
+		return fmt.Errorf("failed to create vendor: %w", err)
+	}
+	return nil
+}
+
+func (r *vendorRepo) UpdateVendor(vendor *models.Vendor) error {
+	return r.DB.Save(vendor).Error
+}
+
+func (r *vendorRepo) FindVendor(id uint) (*models.Vendor, error) {
+	var vendor models.Vendor
+	if err := r.DB.Where("id = ?", id).First(&vendor).Error; err != nil {
+		return nil, err
+	}
+	return &vendor, nil
+}
+
+func (r *vendorRepo) FindVendorByName(name string) (*models.Vendor, error) {
+	var vendor models.Vendor
+	if err := r.DB.Where("LOWER(name) = LOWER(?)", name).First(&vendor).Error; err != nil {
+		return nil, err
+	}
+	return &vendor, nil
+}
+
+// ListVendors returns a page of the vendors whose name matches query, and the total count
+func (r *vendorRepo) ListVendors(query string, limit, offset int) ([]models.Vendor, int64, error) {
+	db := r.DB.Model(&models.Vendor{})
+	if query != "" {
+		db = db.Where("name ILIKE ?", "%"+query+"%")
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var vendors []models.Vendor
+	if err := db.Order("name").Limit(limit).Offset(offset).Find(&vendors).Error; err != nil {
+		return nil, 0, err
+	}
+	return vendors, total, nil
+}
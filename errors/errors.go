@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 	"github.com/gin-gonic/gin"
 )
 
@@ -35,6 +36,27 @@ func New(message string, status int) *Error {
 	}
 }
 
+// ConflictError is returned by repositories when a row changed since the version a write was based on
+type ConflictError struct {
+	Resource string
+	ID       uint
+	Version  int // the version the write expected
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s %d was changed by someone else since version %d", e.Resource, e.ID, e.Version)
+}
+
+// PeriodClosedError is returned by repositories when a journal entry falls in a closed accounting period
+type PeriodClosedError struct {
+	Period string
+	Date   time.Time
+}
+
+func (e *PeriodClosedError) Error() string {
+	return fmt.Sprintf("%s falls in the accounting period %s, which is closed", e.Date.Format("2006-01-02"), e.Period)
+}
+
 // InActiveUserError defines an inactive user error
 var InActiveUserError = errors.New("user is inactive")
 var ErrNotFound = New("not found", http.StatusNotFound)
@@ -2,6 +2,7 @@ package errors
 
 import (
 	"fmt"
+	"strings"
 
 	validator "github.com/go-playground/validator/v10"
 )
@@ -48,3 +49,34 @@ type ValidationError struct {
 func (v ValidationError) Error() string {
 	return fmt.Sprintf("%s: %s", v.Field, v.Message)
 }
+
+// Codes of the per-file errors returned when an upload is rejected
+const (
+	FileUnsupportedType  = "unsupported_type"
+	FileTooLarge         = "too_large"
+	FileInvalidMedia     = "invalid_media"
+	FileResolutionTooLow = "resolution_too_low"
+)
+
+// FileError explains why one of the files of an upload was rejected
+type FileError struct {
+	Filename string `json:"filename"`
+	Field    string `json:"field"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+func (f *FileError) Error() string {
+	return fmt.Sprintf("%s: %s", f.Filename, f.Message)
+}
+
+// FileErrors collects the rejected files of an upload
+type FileErrors []*FileError
+
+func (f FileErrors) Error() string {
+	messages := make([]string, 0, len(f))
+	for _, err := range f {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,109 @@
+// Package ical writes iCalendar (RFC 5545) feeds of all-day events, the format calendar apps subscribe to.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ContentType is the media type of iCalendar data
+const ContentType = "text/calendar; charset=utf-8"
+
+// maxLineOctets is the longest a content line can be before it has to be folded
+const maxLineOctets = 75
+
+// Event is an all-day event, from Start to End included
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	Categories  []string
+}
+
+// Calendar is a named list of events
+type Calendar struct {
+	Name    string
+	ProdID  string
+	Events  []Event
+	Updated time.Time // the DTSTAMP of every event
+}
+
+// Write encodes the calendar to w
+func (c *Calendar) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		fold(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", escape(c.ProdID))
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME", escape(c.Name))
+	}
+	stamp := c.Updated.UTC().Format("20060102T150405Z")
+	for _, event := range c.Events {
+		end := event.End
+		if end.Before(event.Start) {
+			end = event.Start
+		}
+		line("BEGIN", "VEVENT")
+		line("UID", escape(event.UID))
+		line("DTSTAMP", stamp)
+		// DTEND of an all-day event is the day after it ends
+		line("DTSTART;VALUE=DATE", event.Start.Format("20060102"))
+		line("DTEND;VALUE=DATE", end.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY", escape(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION", escape(event.Description))
+		}
+		if len(event.Categories) > 0 {
+			categories := make([]string, len(event.Categories))
+			for i, category := range event.Categories {
+				categories[i] = escape(category)
+			}
+			line("CATEGORIES", strings.Join(categories, ","))
+		}
+		line("TRANSP", "TRANSPARENT")
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// escape escapes a TEXT value
+func escape(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(value)
+}
+
+// fold writes a content line ending in CRLF, breaking it into lines of at most 75 octets that continue
+// with a space. It never splits a UTF-8 sequence.
+func fold(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		limit = maxLineOctets - 1 // the leading space counts
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
@@ -13,6 +13,7 @@ type Mailgun struct {
 
 type Mailer interface {
 	SendSimpleMessage(UserEmail, EmailSubject, EmailBody string) (string, error)
+	SendMessageWithAttachment(userEmail, subject, body, filename string, attachment []byte) (string, error)
 	SendVerifyAccount(userEmail, link string) (string, error)
 	SendResetPassword(userEmail, link string) (string, error)
 }
@@ -38,6 +39,22 @@ func (mail Mailgun) SendSimpleMessage(UserEmail, EmailSubject, EmailBody string)
 	return res, nil
 }
 
+func (mail Mailgun) SendMessageWithAttachment(userEmail, subject, body, filename string, attachment []byte) (string, error) {
+	EmailFrom := os.Getenv("MG_EMAIL_FROM")
+
+	m := mail.Client.NewMessage(EmailFrom, subject, body, userEmail)
+	m.AddBufferAttachment(filename, attachment)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	res, _, err := mail.Client.Send(ctx, m)
+	if err != nil {
+		return "", err
+	}
+	return res, nil
+}
+
 func (mail *Mailgun) SendVerifyAccount(userEmail, link string) (string, error) {
 	EmailFrom := os.Getenv("MG_EMAIL_FROM")
 
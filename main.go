@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"github.com/techagentng/telair-erp/config"
 	"github.com/techagentng/telair-erp/db"
 	"github.com/techagentng/telair-erp/mailingservice"
 	"github.com/techagentng/telair-erp/server"
 	"github.com/techagentng/telair-erp/services"
+	"github.com/techagentng/telair-erp/storage"
 	"log"
 	_ "net/url"
+	"time"
 )
 
 func main() {
@@ -26,14 +29,79 @@ func main() {
 	if err := db.SeedRoles(gormDB.DB); err != nil {
 		log.Fatalf("error seeding roles: %v", err)
 	}
+	if err := db.SeedTaxRates(gormDB.DB); err != nil {
+		log.Fatalf("error seeding tax rates: %v", err)
+	}
+	if err := db.SeedAccounts(gormDB.DB); err != nil {
+		log.Fatalf("error seeding accounts: %v", err)
+	}
+	if err := db.SeedApprovalLevels(gormDB.DB); err != nil {
+		log.Fatalf("error seeding approval levels: %v", err)
+	}
 	authRepo := db.NewAuthRepo(gormDB)
 	movieRepo := db.NewMovieRepo(gormDB)
+	uploadRepo := db.NewUploadRepo(gormDB)
+	peopleRepo := db.NewPeopleRepo(gormDB)
+	taxonomyRepo := db.NewTaxonomyRepo(gormDB)
+	searchRepo := db.NewSearchRepo(gormDB)
+	trashRepo := db.NewTrashRepo(gormDB)
+	revisionRepo := db.NewRevisionRepo(gormDB)
+	subtitleRepo := db.NewSubtitleRepo(gormDB)
+	rightsRepo := db.NewRightsRepo(gormDB)
+	contractRepo := db.NewContractRepo(gormDB)
+	royaltyRepo := db.NewRoyaltyRepo(gormDB)
+	vendorRepo := db.NewVendorRepo(gormDB)
+	exchangeRateRepo := db.NewExchangeRateRepo(gormDB)
+	budgetRepo := db.NewBudgetRepo(gormDB)
+	releaseRepo := db.NewReleaseRepo(gormDB)
+	pressKitRepo := db.NewPressKitRepo(gormDB)
+	crmRepo := db.NewCRMRepo(gormDB)
+	dealRepo := db.NewDealRepo(gormDB)
+	activityRepo := db.NewActivityRepo(gormDB)
+	invoiceRepo := db.NewInvoiceRepo(gormDB)
+	ledgerRepo := db.NewLedgerRepo(gormDB)
+	purchaseRepo := db.NewPurchaseRepo(gormDB)
 	// incidentReportRepo := db.NewIncidentReportRepo(gormDB)
 	// rewardRepo := db.NewRewardRepo(gormDB)
 	// likeRepo := db.NewLikeRepo(gormDB)
 
+	fileStorage, err := storage.NewS3Storage(conf)
+	if err != nil {
+		log.Fatalf("error creating storage: %v", err)
+	}
+
 	authService := services.NewAuthService(authRepo, conf)
-	// mediaService := services.NewMediaService(mediaRepo, rewardRepo, incidentReportRepo, conf)
+	mediaService := services.NewMediaService(movieRepo, uploadRepo, fileStorage, conf)
+	tusService := services.NewTusService(uploadRepo, movieRepo, mediaService, fileStorage, conf)
+	directUploadService := services.NewDirectUploadService(uploadRepo, movieRepo, mediaService, fileStorage, conf)
+
+	// Postgres shares upload progress between instances, memory is enough for a single one
+	var progressStore services.ProgressStore = db.NewProgressRepo(gormDB)
+	if conf.ProgressStore == "memory" {
+		progressStore = services.NewMemoryProgressStore()
+	}
+	progressService := services.NewProgressService(progressStore)
+	peopleService := services.NewPeopleService(peopleRepo, movieRepo)
+	taxonomyService := services.NewTaxonomyService(taxonomyRepo, movieRepo)
+	searchService := services.NewSearchService(searchRepo)
+	trashService := services.NewTrashService(trashRepo, uploadRepo, fileStorage, conf)
+	revisionService := services.NewRevisionService(revisionRepo)
+	subtitleService := services.NewSubtitleService(subtitleRepo, movieRepo, mediaService, fileStorage)
+	rightsService := services.NewRightsService(rightsRepo, movieRepo, mailgunClient, conf)
+	contractService := services.NewContractService(contractRepo, rightsRepo, movieRepo)
+	exchangeRateService := services.NewExchangeRateService(exchangeRateRepo)
+	royaltyService := services.NewRoyaltyService(royaltyRepo, contractRepo, rightsRepo, movieRepo, exchangeRateService)
+	vendorService := services.NewVendorService(vendorRepo)
+	postingService := services.NewPostingService(ledgerRepo, exchangeRateService, conf)
+	budgetService := services.NewBudgetService(budgetRepo, vendorRepo, movieRepo, exchangeRateService, postingService, fileStorage, conf)
+	releaseService := services.NewReleaseService(releaseRepo, movieRepo, authRepo)
+	pressKitService := services.NewPressKitService(pressKitRepo, movieRepo, peopleRepo, taxonomyRepo, fileStorage, conf)
+	crmService := services.NewCRMService(crmRepo, authRepo, conf)
+	dealService := services.NewDealService(dealRepo, crmRepo, movieRepo, authRepo, exchangeRateService, conf)
+	activityService := services.NewActivityService(activityRepo, crmRepo, dealRepo, movieRepo, revisionRepo, authRepo)
+	invoiceService := services.NewInvoiceService(invoiceRepo, crmRepo, dealRepo, contractRepo, exchangeRateService, postingService, mailgunClient, conf)
+	ledgerService := services.NewLedgerService(ledgerRepo, conf)
+	purchaseService := services.NewPurchaseService(purchaseRepo, vendorRepo, budgetRepo, exchangeRateService, conf)
 	// incidentReportService := services.NewIncidentReportService(incidentReportRepo, rewardRepo, mediaRepo, conf)
 	// rewardService := services.NewRewardService(rewardRepo, incidentReportRepo, conf)
 	// likeService := services.NewLikeService(likeRepo, conf)
@@ -43,8 +111,32 @@ func main() {
 		Config:                   conf,
 		AuthRepository:           authRepo,
 		AuthService:              authService,
+		TusService:               tusService,
+		DirectUploadService:      directUploadService,
+		ProgressService:          progressService,
+		MediaService:             mediaService,
+		PeopleService:            peopleService,
+		TaxonomyService:          taxonomyService,
+		SearchService:            searchService,
+		TrashService:             trashService,
+		RevisionService:          revisionService,
+		SubtitleService:          subtitleService,
+		RightsService:            rightsService,
+		ContractService:          contractService,
+		RoyaltyService:           royaltyService,
+		BudgetService:            budgetService,
+		VendorService:            vendorService,
+		ExchangeRateService:      exchangeRateService,
+		ReleaseService:           releaseService,
+		PressKitService:          pressKitService,
+		CRMService:               crmService,
+		DealService:              dealService,
+		ActivityService:          activityService,
+		InvoiceService:           invoiceService,
+		LedgerService:            ledgerService,
+		PurchaseService:          purchaseService,
 		MovieRepository:          movieRepo,
-		// MediaService:             mediaService,
+		Storage:                  fileStorage,
 		// IncidentReportService:    incidentReportService,
 		// IncidentReportRepository: incidentReportRepo,
 		// RewardService:            rewardService,
@@ -52,6 +144,15 @@ func main() {
 		DB:                       db.GormDB{},
 	}
 
+	// Discard resumable uploads that were abandoned
+	go services.RunPeriodically(context.Background(), "tus-expiry", time.Hour, tusService.CleanupExpired)
+	go services.RunPeriodically(context.Background(), "direct-upload-expiry", time.Hour, directUploadService.CleanupExpired)
+	// Look for objects in storage that nothing points to anymore
+	go services.RunPeriodically(context.Background(), "orphan-reconciliation", 24*time.Hour, mediaService.ReconcileOrphans)
+	go services.RunPeriodically(context.Background(), "trash-purge", 24*time.Hour, trashService.PurgeExpired)
+	// Warn about licences running out
+	go services.RunPeriodically(context.Background(), "rights-expiry-alerts", 24*time.Hour, rightsService.SendExpiryAlerts)
+
 	// r := gin.Default()
 	// r.Use(cors.Default())
 	// r.ForwardedByClientIP = true
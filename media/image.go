@@ -0,0 +1,22 @@
+package media
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+)
+
+// inspectImage decodes the whole image so truncated or corrupted files are caught
+func inspectImage(r io.Reader) (*Info, error) {
+	img, format, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %v", err)
+	}
+	bounds := img.Bounds()
+	if format == "" || bounds.Empty() {
+		return nil, fmt.Errorf("invalid image: empty picture")
+	}
+	return &Info{Width: bounds.Dx(), Height: bounds.Dy()}, nil
+}
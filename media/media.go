@@ -0,0 +1,132 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/techagentng/telair-erp/subtitle"
+)
+
+// Content types we know how to recognise from the first bytes of a file
+const (
+	TypeJPEG = "image/jpeg"
+	TypePNG  = "image/png"
+	TypeMP4  = "video/mp4"
+	TypeMOV  = "video/quicktime"
+	TypeAVI  = "video/x-msvideo"
+	TypeSRT  = "application/x-subrip"
+	TypeVTT  = "text/vtt"
+)
+
+// SniffLen is how many bytes Sniff needs to see
+const SniffLen = 512
+
+// ErrUnknownType is returned when the magic bytes match none of the supported formats
+var ErrUnknownType = errors.New("unrecognised file format")
+
+var extensionTypes = map[string]string{
+	".jpg":  TypeJPEG,
+	".jpeg": TypeJPEG,
+	".png":  TypePNG,
+	".mp4":  TypeMP4,
+	".m4v":  TypeMP4,
+	".mov":  TypeMOV,
+	".avi":  TypeAVI,
+	".srt":  TypeSRT,
+	".vtt":  TypeVTT,
+}
+
+// Info is what inspecting a file tells us about it
+type Info struct {
+	ContentType     string  `json:"content_type"`
+	Width           int     `json:"width,omitempty"`
+	Height          int     `json:"height,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	VideoCodec      string  `json:"video_codec,omitempty"`
+	AudioCodec      string  `json:"audio_codec,omitempty"`
+	Cues            int     `json:"cues,omitempty"`
+}
+
+// TypeByExtension guesses the content type from a file name, it is only good for
+// labelling files we produced ourselves, uploads are identified with Sniff
+func TypeByExtension(filename string) string {
+	if contentType, ok := extensionTypes[strings.ToLower(filepath.Ext(filename))]; ok {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// IsVideo reports whether the content type is one of the video formats
+func IsVideo(contentType string) bool {
+	return strings.HasPrefix(contentType, "video/")
+}
+
+// IsImage reports whether the content type is one of the image formats
+func IsImage(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/")
+}
+
+// IsSubtitle reports whether the content type is one of the subtitle formats
+func IsSubtitle(contentType string) bool {
+	return contentType == TypeSRT || contentType == TypeVTT
+}
+
+// Sniff identifies a file from its magic bytes, header should hold the first SniffLen bytes
+func Sniff(header []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return TypeJPEG, nil
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return TypePNG, nil
+	case len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "AVI ":
+		return TypeAVI, nil
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		if string(header[8:12]) == "qt  " {
+			return TypeMOV, nil
+		}
+		return TypeMP4, nil
+	case len(header) >= 8 && isQuickTimeAtom(string(header[4:8])):
+		// Older QuickTime files start straight with a moov, mdat or wide atom
+		return TypeMOV, nil
+	}
+	switch subtitle.Sniff(header) {
+	case subtitle.SRT:
+		return TypeSRT, nil
+	case subtitle.VTT:
+		return TypeVTT, nil
+	}
+	return "", ErrUnknownType
+}
+
+func isQuickTimeAtom(name string) bool {
+	return name == "moov" || name == "mdat" || name == "wide" || name == "free" || name == "skip"
+}
+
+// Inspect reads r to the end, validating that it really is a file of contentType and
+// collecting its dimensions, duration and codecs on the way
+func Inspect(contentType string, r io.Reader) (*Info, error) {
+	var info *Info
+	var err error
+	switch contentType {
+	case TypeJPEG, TypePNG:
+		info, err = inspectImage(r)
+	case TypeMP4, TypeMOV:
+		info, err = inspectMP4(r)
+	case TypeAVI:
+		// There is nothing we extract from AVI files, Sniff already checked the header
+		info = &Info{}
+	case TypeSRT, TypeVTT:
+		info, err = inspectSubtitle(r)
+	default:
+		return nil, fmt.Errorf("cannot inspect %s files", contentType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	info.ContentType = contentType
+	return info, nil
+}
@@ -0,0 +1,255 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxMoovSize caps how much of a movie header we are willing to hold in memory
+const maxMoovSize = 64 << 20
+
+// inspectMP4 walks the top level boxes of an MP4/MOV file. Media data is skipped and
+// only the moov box is read, wherever it sits in the file.
+func inspectMP4(r io.Reader) (*Info, error) {
+	var info *Info
+	for {
+		boxType, size, err := readBoxHeader(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid video container: %v", err)
+		}
+
+		if boxType != "moov" {
+			if size < 0 {
+				// The box runs to the end of the file
+				if _, err := io.Copy(io.Discard, r); err != nil {
+					return nil, err
+				}
+				break
+			}
+			if _, err := io.CopyN(io.Discard, r, size); err != nil {
+				return nil, fmt.Errorf("invalid video container: truncated %s box", boxType)
+			}
+			continue
+		}
+
+		if size < 0 || size > maxMoovSize {
+			return nil, fmt.Errorf("invalid video container: unsupported moov size")
+		}
+		moov := make([]byte, size)
+		if _, err := io.ReadFull(r, moov); err != nil {
+			return nil, fmt.Errorf("invalid video container: truncated moov box")
+		}
+		if info, err = parseMoov(moov); err != nil {
+			return nil, fmt.Errorf("invalid video container: %v", err)
+		}
+	}
+
+	if info == nil {
+		return nil, errors.New("invalid video container: no movie header found")
+	}
+	return info, nil
+}
+
+// readBoxHeader returns the type of the next box and the size of its payload, -1 when it runs to the end of the file
+func readBoxHeader(r io.Reader) (string, int64, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return "", 0, errors.New("truncated box header")
+		}
+		return "", 0, err
+	}
+	size := int64(binary.BigEndian.Uint32(header[0:4]))
+	boxType := string(header[4:8])
+
+	switch size {
+	case 0:
+		return boxType, -1, nil
+	case 1:
+		large := make([]byte, 8)
+		if _, err := io.ReadFull(r, large); err != nil {
+			return "", 0, errors.New("truncated box header")
+		}
+		size = int64(binary.BigEndian.Uint64(large)) - 16
+	default:
+		size -= 8
+	}
+	if size < 0 {
+		return "", 0, fmt.Errorf("invalid size for %s box", boxType)
+	}
+	return boxType, size, nil
+}
+
+// box is a box held in memory
+type box struct {
+	kind    string
+	payload []byte
+}
+
+// children splits a payload into the boxes it contains
+func children(payload []byte) ([]box, error) {
+	var boxes []box
+	for len(payload) > 0 {
+		if len(payload) < 8 {
+			return nil, errors.New("truncated box")
+		}
+		size := uint64(binary.BigEndian.Uint32(payload[0:4]))
+		kind := string(payload[4:8])
+		headerSize := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(payload))
+		case 1:
+			if len(payload) < 16 {
+				return nil, errors.New("truncated box")
+			}
+			size = binary.BigEndian.Uint64(payload[8:16])
+			headerSize = 16
+		}
+		if size < headerSize || size > uint64(len(payload)) {
+			return nil, fmt.Errorf("invalid size for %s box", kind)
+		}
+		boxes = append(boxes, box{kind: kind, payload: payload[headerSize:size]})
+		payload = payload[size:]
+	}
+	return boxes, nil
+}
+
+func find(boxes []box, kind string) *box {
+	for i := range boxes {
+		if boxes[i].kind == kind {
+			return &boxes[i]
+		}
+	}
+	return nil
+}
+
+// findPath descends through nested boxes, e.g. findPath(trak, "mdia", "minf", "stbl")
+func findPath(payload []byte, path ...string) (*box, error) {
+	var current *box
+	for _, kind := range path {
+		boxes, err := children(payload)
+		if err != nil {
+			return nil, err
+		}
+		if current = find(boxes, kind); current == nil {
+			return nil, nil
+		}
+		payload = current.payload
+	}
+	return current, nil
+}
+
+func parseMoov(moov []byte) (*Info, error) {
+	boxes, err := children(moov)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{}
+	mvhd := find(boxes, "mvhd")
+	if mvhd == nil {
+		return nil, errors.New("missing mvhd box")
+	}
+	if info.DurationSeconds, err = parseMvhd(mvhd.payload); err != nil {
+		return nil, err
+	}
+
+	for _, trak := range boxes {
+		if trak.kind != "trak" {
+			continue
+		}
+		handler, codec, err := parseTrack(trak.payload)
+		if err != nil {
+			return nil, err
+		}
+		switch handler {
+		case "vide":
+			if info.VideoCodec != "" {
+				continue
+			}
+			info.VideoCodec = codec
+			tkhd, err := findPath(trak.payload, "tkhd")
+			if err != nil || tkhd == nil {
+				return nil, errors.New("missing tkhd box")
+			}
+			if info.Width, info.Height, err = parseTkhd(tkhd.payload); err != nil {
+				return nil, err
+			}
+		case "soun":
+			if info.AudioCodec == "" {
+				info.AudioCodec = codec
+			}
+		}
+	}
+	return info, nil
+}
+
+// parseMvhd returns the movie duration in seconds
+func parseMvhd(p []byte) (float64, error) {
+	if len(p) < 4 {
+		return 0, errors.New("truncated mvhd box")
+	}
+	var timescale uint32
+	var duration uint64
+	if p[0] == 1 {
+		if len(p) < 32 {
+			return 0, errors.New("truncated mvhd box")
+		}
+		timescale = binary.BigEndian.Uint32(p[20:24])
+		duration = binary.BigEndian.Uint64(p[24:32])
+	} else {
+		if len(p) < 20 {
+			return 0, errors.New("truncated mvhd box")
+		}
+		timescale = binary.BigEndian.Uint32(p[12:16])
+		duration = uint64(binary.BigEndian.Uint32(p[16:20]))
+	}
+	if timescale == 0 {
+		return 0, errors.New("invalid mvhd timescale")
+	}
+	return float64(duration) / float64(timescale), nil
+}
+
+// parseTkhd returns the presentation width and height of a track
+func parseTkhd(p []byte) (int, int, error) {
+	offset := 76
+	if len(p) > 0 && p[0] == 1 {
+		offset = 88
+	}
+	if len(p) < offset+8 {
+		return 0, 0, errors.New("truncated tkhd box")
+	}
+	// Both are 16.16 fixed point numbers
+	width := binary.BigEndian.Uint32(p[offset:offset+4]) >> 16
+	height := binary.BigEndian.Uint32(p[offset+4:offset+8]) >> 16
+	return int(width), int(height), nil
+}
+
+// parseTrack returns the handler type of a track ("vide", "soun", ...) and the format of its first sample description
+func parseTrack(trak []byte) (string, string, error) {
+	hdlr, err := findPath(trak, "mdia", "hdlr")
+	if err != nil {
+		return "", "", err
+	}
+	if hdlr == nil || len(hdlr.payload) < 12 {
+		return "", "", nil
+	}
+	handler := string(hdlr.payload[8:12])
+
+	stsd, err := findPath(trak, "mdia", "minf", "stbl", "stsd")
+	if err != nil {
+		return "", "", err
+	}
+	codec := ""
+	if stsd != nil && len(stsd.payload) >= 16 && binary.BigEndian.Uint32(stsd.payload[4:8]) > 0 {
+		codec = strings.TrimSpace(string(stsd.payload[12:16]))
+	}
+	return handler, codec, nil
+}
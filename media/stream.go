@@ -0,0 +1,42 @@
+package media
+
+import "io"
+
+// Inspection runs Inspect over the bytes written to it, so a file can be checked
+// while it is being copied to storage instead of being read twice
+type Inspection struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+	info *Info
+	err  error
+}
+
+// NewInspection starts inspecting a file of contentType, write the file to it and call Close
+func NewInspection(contentType string) *Inspection {
+	pr, pw := io.Pipe()
+	i := &Inspection{pw: pw, done: make(chan struct{})}
+	go func() {
+		defer close(i.done)
+		i.info, i.err = Inspect(contentType, pr)
+		// Keep accepting writes once the inspector has seen what it needs
+		io.Copy(io.Discard, pr)
+	}()
+	return i
+}
+
+func (i *Inspection) Write(p []byte) (int, error) {
+	return i.pw.Write(p)
+}
+
+// Close marks the end of the file and returns the result of the inspection
+func (i *Inspection) Close() (*Info, error) {
+	i.pw.Close()
+	<-i.done
+	return i.info, i.err
+}
+
+// Abort stops the inspection when the file could not be read to the end
+func (i *Inspection) Abort(err error) {
+	i.pw.CloseWithError(err)
+	<-i.done
+}
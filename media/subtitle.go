@@ -0,0 +1,27 @@
+package media
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/techagentng/telair-erp/subtitle"
+)
+
+// maxSubtitleSize keeps a runaway text file from being read into memory
+const maxSubtitleSize = 16 << 20
+
+// inspectSubtitle parses the whole file so bad timings, overlapping cues and wrong encodings are caught
+func inspectSubtitle(r io.Reader) (*Info, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxSubtitleSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSubtitleSize {
+		return nil, fmt.Errorf("invalid subtitles: file is larger than %d MB", maxSubtitleSize>>20)
+	}
+	doc, err := subtitle.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subtitles: %v", err)
+	}
+	return &Info{DurationSeconds: doc.Duration().Seconds(), Cues: len(doc.Cues)}, nil
+}
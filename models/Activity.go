@@ -0,0 +1,104 @@
+package models
+
+import "time"
+
+type ActivityKind string
+
+const (
+	ActivityNote    ActivityKind = "note"
+	ActivityCall    ActivityKind = "call"
+	ActivityMeeting ActivityKind = "meeting"
+	ActivityEmail   ActivityKind = "email"
+	ActivityTask    ActivityKind = "task"
+)
+
+// IsValid reports whether k is a known activity kind
+func (k ActivityKind) IsValid() bool {
+	switch k {
+	case ActivityNote, ActivityCall, ActivityMeeting, ActivityEmail, ActivityTask:
+		return true
+	}
+	return false
+}
+
+// SubjectDeal is the subject type of activities about a deal. Activities about a contact or an
+// organization have the party type as subject type, those about a title the table of the title.
+const SubjectDeal = "deals"
+
+// Activity is a note, call, meeting or email about a contact, an organization, a deal or a title, or a task
+// to do about one. Tasks are due on a date, assigned to someone and completed, the other kinds record
+// something that happened at OccurredAt.
+type Activity struct {
+	Model
+	Kind            ActivityKind `gorm:"size:10;index" json:"kind"`
+	SubjectType     string       `gorm:"size:20;index:idx_activities_subject" json:"subject_type"`
+	SubjectID       uint         `gorm:"index:idx_activities_subject" json:"subject_id"`
+	Summary         string       `gorm:"size:255" json:"summary"` // e.g. the topic of a call or the subject of an email
+	Body            string       `gorm:"type:text" json:"body,omitempty"`
+	OccurredAt      time.Time    `gorm:"index" json:"occurred_at"`
+	DurationMinutes int          `json:"duration_minutes,omitempty"`
+	AuthorID        uint         `gorm:"index" json:"author_id"`
+	DueDate         *time.Time   `gorm:"type:date;index" json:"due_date,omitempty"`
+	AssigneeID      *uint        `gorm:"index" json:"assignee_id,omitempty"`
+	CompletedAt     *time.Time   `json:"completed_at,omitempty"`
+	CompletedBy     *uint        `json:"completed_by,omitempty"`
+}
+
+// ActivityRequest creates or replaces an activity. OccurredAt is an RFC 3339 time, now when left out.
+// DueDate and AssigneeID are only read for tasks, which are assigned to their author unless someone
+// else is named.
+type ActivityRequest struct {
+	Kind            ActivityKind `json:"kind" binding:"required"`
+	Summary         string       `json:"summary" binding:"required"`
+	Body            string       `json:"body"`
+	OccurredAt      string       `json:"occurred_at"`
+	DurationMinutes int          `json:"duration_minutes"`
+	DueDate         string       `json:"due_date"`
+	AssigneeID      uint         `json:"assignee_id"`
+}
+
+// ActivityFilter narrows down the activities of a subject
+type ActivityFilter struct {
+	SubjectType string
+	SubjectID   uint
+	Kind        ActivityKind
+	Page        int
+	Limit       int
+}
+
+type TimelineEntryKind string
+
+const (
+	TimelineActivity      TimelineEntryKind = "activity"
+	TimelineTaskCompleted TimelineEntryKind = "task_completed"
+	TimelineRevision      TimelineEntryKind = "revision"
+	TimelineStageChange   TimelineEntryKind = "stage_change"
+	TimelineCreated       TimelineEntryKind = "created"
+)
+
+// TimelineEntry is one thing that happened to a contact, an organization, a deal or a title: an activity,
+// a completed task, an edit of a title, a deal changing stage or status, or the record being created.
+// UserID is who did it, when known.
+type TimelineEntry struct {
+	Kind        TimelineEntryKind `json:"kind"`
+	At          time.Time         `json:"at"`
+	UserID      uint              `json:"user_id,omitempty"`
+	Summary     string            `json:"summary"`
+	Activity    *Activity         `json:"activity,omitempty"`
+	Revision    *Revision         `json:"revision,omitempty"`
+	StageChange *DealEvent        `json:"stage_change,omitempty"`
+}
+
+// DealEvent is a stage change of a deal along with the names a timeline shows
+type DealEvent struct {
+	DealStageChange
+	DealName  string `json:"deal_name"`
+	FromStage string `json:"from_stage,omitempty"`
+	ToStage   string `json:"to_stage"`
+}
+
+// MyTasks are the open tasks assigned to someone, those past their due date apart from those coming up
+type MyTasks struct {
+	Overdue []Activity `json:"overdue"`
+	Due     []Activity `json:"due"`
+}
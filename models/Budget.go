@@ -0,0 +1,184 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// BudgetCategory is the standard film budget section a line item belongs to
+type BudgetCategory string
+
+const (
+	AboveTheLine   BudgetCategory = "above_the_line" // story rights, producers, director, cast
+	Production     BudgetCategory = "production"     // crew, sets, equipment, locations
+	PostProduction BudgetCategory = "post_production"
+	Marketing      BudgetCategory = "marketing"
+)
+
+// BudgetCategories lists the categories in the order they appear on a budget
+var BudgetCategories = []BudgetCategory{AboveTheLine, Production, PostProduction, Marketing}
+
+// IsValid reports whether c is a known budget category
+func (c BudgetCategory) IsValid() bool {
+	switch c {
+	case AboveTheLine, Production, PostProduction, Marketing:
+		return true
+	}
+	return false
+}
+
+type BudgetStatus string
+
+const (
+	BudgetDraft    BudgetStatus = "draft"
+	BudgetApproved BudgetStatus = "approved"
+)
+
+// Budget is the production budget of a title, in a single currency. Its line items can only change while it
+// is a draft, costs are recorded once it is approved.
+type Budget struct {
+	Model
+	TitleType TitleType       `gorm:"size:20;uniqueIndex:idx_budgets_title" json:"title_type"`
+	TitleID   uint            `gorm:"uniqueIndex:idx_budgets_title" json:"title_id"`
+	Currency  string          `gorm:"size:3" json:"currency"`
+	Total     decimal.Decimal `gorm:"type:numeric(18,2);not null;default:0" json:"total"`
+	// ApprovalThreshold is the amount above which a cost waits for an admin, in the budget currency
+	ApprovalThreshold decimal.Decimal `gorm:"type:numeric(18,2);not null;default:0" json:"approval_threshold"`
+	Status            BudgetStatus    `gorm:"size:20;index" json:"status"`
+	ApprovedBy        *uint           `json:"approved_by"`
+	ApprovedAt        *time.Time      `json:"approved_at"`
+	Notes             string          `gorm:"type:text" json:"notes,omitempty"`
+	CreatedBy         uint            `json:"created_by"`
+	Lines             []BudgetLine    `gorm:"foreignKey:BudgetID" json:"lines"`
+}
+
+// BudgetLine is a line item of a budget
+type BudgetLine struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	BudgetID    uint            `gorm:"index" json:"budget_id"`
+	Category    BudgetCategory  `gorm:"size:20;index" json:"category"`
+	Code        string          `gorm:"size:20" json:"code,omitempty"` // account code from the chart of the production
+	Description string          `gorm:"size:255" json:"description"`
+	Amount      decimal.Decimal `gorm:"type:numeric(18,2)" json:"amount"`
+}
+
+type CostStatus string
+
+const (
+	CostPending  CostStatus = "pending"
+	CostApproved CostStatus = "approved"
+	CostRejected CostStatus = "rejected"
+)
+
+// IsValid reports whether s is a known cost status
+func (s CostStatus) IsValid() bool {
+	switch s {
+	case CostPending, CostApproved, CostRejected:
+		return true
+	}
+	return false
+}
+
+// CostEntry is an actual cost booked against a budget line. Amount is in the currency it was paid in,
+// BaseAmount is the same cost in the budget currency at the exchange rate of IncurredOn.
+type CostEntry struct {
+	Model
+	BudgetID     uint            `gorm:"index" json:"budget_id"`
+	BudgetLineID uint            `gorm:"index" json:"budget_line_id"`
+	VendorID     *uint           `gorm:"index" json:"vendor_id"`
+	Vendor       *Vendor         `gorm:"foreignKey:VendorID" json:"vendor,omitempty"`
+	Description  string          `gorm:"size:255" json:"description"`
+	Reference    string          `gorm:"size:100" json:"reference,omitempty"` // the vendor's invoice or receipt number
+	IncurredOn   time.Time       `gorm:"type:date" json:"incurred_on"`
+	Currency     string          `gorm:"size:3" json:"currency"`
+	Amount       decimal.Decimal `gorm:"type:numeric(18,2)" json:"amount"`
+	ExchangeRate decimal.Decimal `gorm:"type:numeric(20,8)" json:"exchange_rate"`
+	BaseAmount   decimal.Decimal `gorm:"type:numeric(18,2)" json:"base_amount"`
+	ReceiptKey   string          `gorm:"size:255" json:"-"`
+	HasReceipt   bool            `gorm:"-" json:"has_receipt"`
+	Status       CostStatus      `gorm:"size:20;index" json:"status"`
+	ReviewedBy   *uint           `json:"reviewed_by"`
+	ReviewedAt   *time.Time      `json:"reviewed_at"`
+	ReviewNote   string          `gorm:"size:500" json:"review_note,omitempty"`
+	CreatedBy    uint            `json:"created_by"`
+}
+
+type BudgetRequest struct {
+	Currency          string              `json:"currency" binding:"required,len=3"`
+	ApprovalThreshold decimal.Decimal     `json:"approval_threshold"`
+	Notes             string              `json:"notes"`
+	Lines             []BudgetLineRequest `json:"lines"`
+}
+
+// BudgetLineRequest adds a line item, or changes the existing one with ID
+type BudgetLineRequest struct {
+	ID          uint            `json:"id"`
+	Category    BudgetCategory  `json:"category" binding:"required"`
+	Code        string          `json:"code"`
+	Description string          `json:"description" binding:"required"`
+	Amount      decimal.Decimal `json:"amount"`
+}
+
+type CostRequest struct {
+	BudgetLineID uint            `json:"budget_line_id" binding:"required"`
+	VendorID     *uint           `json:"vendor_id"`
+	Description  string          `json:"description" binding:"required"`
+	Reference    string          `json:"reference"`
+	IncurredOn   string          `json:"incurred_on" binding:"required"`
+	Currency     string          `json:"currency" binding:"required,len=3"`
+	Amount       decimal.Decimal `json:"amount"`
+}
+
+// CostReviewRequest approves or rejects a pending cost
+type CostReviewRequest struct {
+	Note string `json:"note"`
+}
+
+// CostFilter narrows down the costs listed for a budget
+type CostFilter struct {
+	BudgetID uint
+	Status   CostStatus
+	Category BudgetCategory
+	Page     int
+	Limit    int
+}
+
+// LineCost is the total of the costs of a budget line in one status
+type LineCost struct {
+	BudgetLineID uint
+	Status       CostStatus
+	Total        decimal.Decimal
+}
+
+// Variance compares what was budgeted with what was spent. Variance is what is left, negative when over
+// budget, and VariancePercent is it as a share of Budgeted, nil when nothing was budgeted.
+type Variance struct {
+	Budgeted        decimal.Decimal  `json:"budgeted"`
+	Actual          decimal.Decimal  `json:"actual"`
+	Pending         decimal.Decimal  `json:"pending"`
+	Variance        decimal.Decimal  `json:"variance"`
+	VariancePercent *decimal.Decimal `json:"variance_percent"`
+	OverBudget      bool             `json:"over_budget"`
+}
+
+type LineVariance struct {
+	BudgetLine
+	Variance
+}
+
+type CategoryVariance struct {
+	Category BudgetCategory `json:"category"`
+	Variance
+	Lines []LineVariance `json:"lines"`
+}
+
+// BudgetVariance is the variance report of a budget, amounts are in the budget currency
+type BudgetVariance struct {
+	BudgetID   uint               `json:"budget_id"`
+	TitleType  TitleType          `json:"title_type"`
+	TitleID    uint               `json:"title_id"`
+	Currency   string             `json:"currency"`
+	Categories []CategoryVariance `json:"categories"`
+	Total      Variance           `json:"total"`
+}
@@ -0,0 +1,149 @@
+package models
+
+import "strings"
+
+// Parties are the contacts and organizations of the CRM. Emails, phones and addresses belong to either,
+// told apart by their party type.
+const (
+	PartyContact      = "contacts"
+	PartyOrganization = "organizations"
+)
+
+// Contact is someone outside the company we deal with, e.g. a buyer at a distributor or a talent agent.
+// OwnerID is the member of staff who looks after the relationship.
+type Contact struct {
+	Model
+	FirstName     string                `gorm:"size:100;index" json:"first_name"`
+	LastName      string                `gorm:"size:100;index" json:"last_name"`
+	JobTitle      string                `gorm:"size:255" json:"job_title,omitempty"`
+	Notes         string                `gorm:"type:text" json:"notes,omitempty"`
+	OwnerID       uint                  `gorm:"index" json:"owner_id"`
+	CustomFields  map[string]string     `gorm:"serializer:json;type:jsonb" json:"custom_fields"`
+	Emails        []EmailAddress        `gorm:"polymorphic:Party;polymorphicValue:contacts" json:"emails"`
+	Phones        []PhoneNumber         `gorm:"polymorphic:Party;polymorphicValue:contacts" json:"phones"`
+	Addresses     []PostalAddress       `gorm:"polymorphic:Party;polymorphicValue:contacts" json:"addresses"`
+	Organizations []ContactOrganization `gorm:"foreignKey:ContactID" json:"organizations,omitempty"`
+}
+
+// Name is how the contact is addressed, first name first
+func (c *Contact) Name() string {
+	return strings.TrimSpace(c.FirstName + " " + c.LastName)
+}
+
+// EmailAddress is one of the email addresses of a contact or organization. Normalized is the address in
+// lower case, which duplicates are found by.
+type EmailAddress struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	PartyType  string `gorm:"size:20;index:idx_email_addresses_party" json:"-"`
+	PartyID    uint   `gorm:"index:idx_email_addresses_party" json:"-"`
+	Label      string `gorm:"size:50" json:"label,omitempty"` // e.g. work or personal
+	Address    string `gorm:"size:255" json:"address"`
+	Normalized string `gorm:"size:255;index" json:"-"`
+	IsPrimary  bool   `json:"is_primary"`
+}
+
+// PhoneNumber is one of the phone numbers of a contact or organization. Normalized holds the digits
+// only, calling code included, so that 0803 123 4567 and +234 803 123 4567 are the same number.
+type PhoneNumber struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	PartyType  string `gorm:"size:20;index:idx_phone_numbers_party" json:"-"`
+	PartyID    uint   `gorm:"index:idx_phone_numbers_party" json:"-"`
+	Label      string `gorm:"size:50" json:"label,omitempty"` // e.g. mobile or office
+	Number     string `gorm:"size:50" json:"number"`
+	Normalized string `gorm:"size:50;index" json:"-"`
+	IsPrimary  bool   `json:"is_primary"`
+}
+
+// PostalAddress is one of the addresses of a contact or organization
+type PostalAddress struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	PartyType  string `gorm:"size:20;index:idx_postal_addresses_party" json:"-"`
+	PartyID    uint   `gorm:"index:idx_postal_addresses_party" json:"-"`
+	Label      string `gorm:"size:50" json:"label,omitempty"` // e.g. office or billing
+	Line1      string `gorm:"size:255" json:"line1"`
+	Line2      string `gorm:"size:255" json:"line2,omitempty"`
+	City       string `gorm:"size:100" json:"city,omitempty"`
+	State      string `gorm:"size:100" json:"state,omitempty"`
+	PostalCode string `gorm:"size:20" json:"postal_code,omitempty"`
+	Country    string `gorm:"size:2" json:"country,omitempty"` // ISO 3166-1 alpha-2
+	IsPrimary  bool   `json:"is_primary"`
+}
+
+// ContactOrganization links a contact to an organization they work for or represent
+type ContactOrganization struct {
+	ContactID      uint          `gorm:"primaryKey" json:"contact_id"`
+	OrganizationID uint          `gorm:"primaryKey;index" json:"organization_id"`
+	Role           string        `gorm:"size:255" json:"role,omitempty"` // e.g. head of acquisitions
+	CreatedAt      int64         `json:"created_at"`
+	Contact        *Contact      `json:"contact,omitempty"`
+	Organization   *Organization `json:"organization,omitempty"`
+}
+
+type EmailAddressRequest struct {
+	Label     string `json:"label"`
+	Address   string `json:"address" binding:"required,email"`
+	IsPrimary bool   `json:"is_primary"`
+}
+
+type PhoneNumberRequest struct {
+	Label     string `json:"label"`
+	Number    string `json:"number" binding:"required"`
+	IsPrimary bool   `json:"is_primary"`
+}
+
+type PostalAddressRequest struct {
+	Label      string `json:"label"`
+	Line1      string `json:"line1" binding:"required"`
+	Line2      string `json:"line2"`
+	City       string `json:"city"`
+	State      string `json:"state"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+	IsPrimary  bool   `json:"is_primary"`
+}
+
+// ContactRequest creates or replaces a contact. A contact sharing an email or phone with another one is
+// refused as a likely duplicate unless AllowDuplicate is set.
+type ContactRequest struct {
+	FirstName      string                 `json:"first_name" binding:"required"`
+	LastName       string                 `json:"last_name"`
+	JobTitle       string                 `json:"job_title"`
+	Notes          string                 `json:"notes"`
+	OwnerID        uint                   `json:"owner_id"`
+	CustomFields   map[string]string      `json:"custom_fields"`
+	Emails         []EmailAddressRequest  `json:"emails" binding:"dive"`
+	Phones         []PhoneNumberRequest   `json:"phones" binding:"dive"`
+	Addresses      []PostalAddressRequest `json:"addresses" binding:"dive"`
+	AllowDuplicate bool                   `json:"allow_duplicate"`
+}
+
+type ContactOrganizationRequest struct {
+	Role string `json:"role"`
+}
+
+// MergeRequest folds the contact or organization in the path into IntoID
+type MergeRequest struct {
+	IntoID uint `json:"into_id" binding:"required"`
+}
+
+// ContactFilter narrows down a contact search. Query matches names, job titles, emails and phone numbers.
+type ContactFilter struct {
+	Query          string
+	OwnerID        uint
+	OrganizationID uint
+	Page           int
+	Limit          int
+}
+
+// DuplicateMatch is a value several contacts or organizations share, which suggests they are one and the same
+type DuplicateMatch struct {
+	Field    string `json:"field"` // email, phone or, for organizations, name
+	Value    string `json:"value"`
+	PartyIDs []uint `json:"-"`
+}
+
+// ContactDuplicates is a group of contacts sharing an email or phone number
+type ContactDuplicates struct {
+	DuplicateMatch
+	Contacts []Contact `json:"contacts"`
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type ContractStatus string
+
+const (
+	ContractDraft      ContractStatus = "draft"
+	ContractActive     ContractStatus = "active"
+	ContractExpired    ContractStatus = "expired"
+	ContractTerminated ContractStatus = "terminated"
+)
+
+// IsValid reports whether s is a known contract status
+func (s ContractStatus) IsValid() bool {
+	switch s {
+	case ContractDraft, ContractActive, ContractExpired, ContractTerminated:
+		return true
+	}
+	return false
+}
+
+// CanBecome reports whether a contract in status s can be moved to next. Drafts get signed or dropped,
+// active contracts run out or get terminated, and that is the end of them.
+func (s ContractStatus) CanBecome(next ContractStatus) bool {
+	switch s {
+	case ContractDraft:
+		return next == ContractActive || next == ContractTerminated
+	case ContractActive:
+		return next == ContractExpired || next == ContractTerminated
+	}
+	return false
+}
+
+// Contract is a licensing deal with a licensee. Its fee structure combines a flat fee, a minimum guarantee
+// recouped against the revenue share, and the share of the reported revenue owed to us.
+type Contract struct {
+	Model
+	Reference           string            `gorm:"size:50;uniqueIndex" json:"reference"`
+	LicenseeID          uint              `gorm:"index" json:"licensee_id"`
+	Licensee            *Licensee         `gorm:"foreignKey:LicenseeID" json:"licensee,omitempty"`
+	Currency            string            `gorm:"size:3" json:"currency"`
+	FlatFee             decimal.Decimal   `gorm:"type:numeric(18,2);not null;default:0" json:"flat_fee"`
+	MinimumGuarantee    decimal.Decimal   `gorm:"type:numeric(18,2);not null;default:0" json:"minimum_guarantee"`
+	RevenueSharePercent decimal.Decimal   `gorm:"type:numeric(7,4);not null;default:0" json:"revenue_share_percent"`
+	StartDate           time.Time         `gorm:"type:date" json:"start_date"`
+	EndDate             *time.Time        `gorm:"type:date" json:"end_date"`
+	Status              ContractStatus    `gorm:"size:20;index" json:"status"`
+	Notes               string            `gorm:"type:text" json:"notes,omitempty"`
+	Titles              []ContractTitle   `gorm:"foreignKey:ContractID" json:"titles"`
+	Payments            []ContractPayment `gorm:"foreignKey:ContractID" json:"payments"`
+}
+
+// ContractTitle is a title licensed under a contract
+type ContractTitle struct {
+	ID         uint      `gorm:"primaryKey" json:"-"`
+	ContractID uint      `gorm:"uniqueIndex:idx_contract_titles_title" json:"-"`
+	TitleType  TitleType `gorm:"size:20;uniqueIndex:idx_contract_titles_title" json:"title_type"`
+	TitleID    uint      `gorm:"uniqueIndex:idx_contract_titles_title" json:"title_id"`
+}
+
+// ContractPayment is an instalment of the payment schedule of a contract
+type ContractPayment struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	ContractID  uint            `gorm:"index" json:"contract_id"`
+	DueDate     time.Time       `gorm:"type:date" json:"due_date"`
+	Amount      decimal.Decimal `gorm:"type:numeric(18,2)" json:"amount"`
+	Description string          `gorm:"size:255" json:"description,omitempty"`
+	PaidAt      *time.Time      `json:"paid_at"`
+}
+
+// ContractRequest creates or replaces the terms of a contract, amounts are decimals sent as strings or numbers
+type ContractRequest struct {
+	Reference           string                   `json:"reference" binding:"required"`
+	LicenseeID          uint                     `json:"licensee_id" binding:"required"`
+	Currency            string                   `json:"currency" binding:"required,len=3"`
+	FlatFee             decimal.Decimal          `json:"flat_fee"`
+	MinimumGuarantee    decimal.Decimal          `json:"minimum_guarantee"`
+	RevenueSharePercent decimal.Decimal          `json:"revenue_share_percent"`
+	StartDate           string                   `json:"start_date" binding:"required"`
+	EndDate             string                   `json:"end_date"`
+	Notes               string                   `json:"notes"`
+	Titles              []ContractTitle          `json:"titles"`
+	Payments            []ContractPaymentRequest `json:"payments"`
+}
+
+type ContractPaymentRequest struct {
+	DueDate     string          `json:"due_date" binding:"required"`
+	Amount      decimal.Decimal `json:"amount"`
+	Description string          `json:"description"`
+}
+
+type ContractStatusRequest struct {
+	Status ContractStatus `json:"status" binding:"required"`
+}
+
+// ContractFilter narrows down the contracts listed
+type ContractFilter struct {
+	Status     ContractStatus
+	LicenseeID uint
+	Page       int
+	Limit      int
+}
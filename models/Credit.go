@@ -0,0 +1,51 @@
+package models
+
+type CreditRole string
+
+const (
+	CreditActor    CreditRole = "actor"
+	CreditDirector CreditRole = "director"
+	CreditProducer CreditRole = "producer"
+	CreditDP       CreditRole = "dp" // director of photography
+	CreditEditor   CreditRole = "editor"
+	CreditComposer CreditRole = "composer"
+)
+
+// IsValid reports whether r is a known role
+func (r CreditRole) IsValid() bool {
+	switch r {
+	case CreditActor, CreditDirector, CreditProducer, CreditDP, CreditEditor, CreditComposer:
+		return true
+	}
+	return false
+}
+
+// Credit is the part a person played in a trailer or a full-length film
+type Credit struct {
+	Model
+	PersonID      uint       `gorm:"index" json:"person_id"`
+	Person        *Person    `gorm:"foreignKey:PersonID" json:"person,omitempty"`
+	TitleType     TitleType  `gorm:"size:20;index:idx_credits_title" json:"title_type"`
+	TitleID       uint       `gorm:"index:idx_credits_title" json:"title_id"`
+	Role          CreditRole `gorm:"size:20" json:"role"`
+	CharacterName string     `gorm:"size:255" json:"character_name,omitempty"`
+	BillingOrder  int        `json:"billing_order"`
+}
+
+type CreditRequest struct {
+	PersonID      uint       `json:"person_id" binding:"required"`
+	Role          CreditRole `json:"role" binding:"required"`
+	CharacterName string     `json:"character_name"`
+	BillingOrder  int        `json:"billing_order"`
+}
+
+// FilmographyEntry is a credit of a person along with the title it belongs to
+type FilmographyEntry struct {
+	TitleType     TitleType   `json:"title_type"`
+	TitleID       uint        `json:"title_id"`
+	Title         string      `json:"title"`
+	Status        MovieStatus `json:"status"`
+	Role          CreditRole  `json:"role"`
+	CharacterName string      `json:"character_name,omitempty"`
+	BillingOrder  int         `json:"billing_order"`
+}
@@ -0,0 +1,224 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type DealStatus string
+
+const (
+	DealOpen DealStatus = "open"
+	DealWon  DealStatus = "won"
+	DealLost DealStatus = "lost"
+)
+
+// IsValid reports whether s is a known deal status
+func (s DealStatus) IsValid() bool {
+	switch s {
+	case DealOpen, DealWon, DealLost:
+		return true
+	}
+	return false
+}
+
+// Pipeline is a sales process deals go through, e.g. international licensing, with stages in order
+type Pipeline struct {
+	Model
+	Name        string          `gorm:"size:255;uniqueIndex" json:"name"`
+	Description string          `gorm:"type:text" json:"description,omitempty"`
+	Stages      []PipelineStage `gorm:"foreignKey:PipelineID" json:"stages"`
+}
+
+// PipelineStage is a step of a pipeline. Probability is the chance in percent that a deal in the stage is
+// won, which weighs the value of the pipeline.
+type PipelineStage struct {
+	Model
+	PipelineID  uint   `gorm:"index" json:"pipeline_id"`
+	Name        string `gorm:"size:100" json:"name"`
+	Position    int    `json:"position"`
+	Probability int    `json:"probability"`
+}
+
+// Deal is a sale or licence of titles we are negotiating with a contact, an organization or both.
+// Deals move through the stages of their pipeline while open, and end up won or lost.
+type Deal struct {
+	Model
+	Name              string            `gorm:"size:255" json:"name"`
+	PipelineID        uint              `gorm:"index" json:"pipeline_id"`
+	StageID           uint              `gorm:"index" json:"stage_id"`
+	Status            DealStatus        `gorm:"size:10;index" json:"status"`
+	Value             decimal.Decimal   `gorm:"type:numeric(18,2);not null;default:0" json:"value"`
+	Currency          string            `gorm:"size:3" json:"currency"`
+	ExpectedCloseDate *time.Time        `gorm:"type:date" json:"expected_close_date"`
+	ContactID         *uint             `gorm:"index" json:"contact_id"`
+	OrganizationID    *uint             `gorm:"index" json:"organization_id"`
+	OwnerID           uint              `gorm:"index" json:"owner_id"`
+	CloseReason       string            `gorm:"type:text" json:"close_reason,omitempty"` // why the deal was won or lost
+	ClosedAt          *time.Time        `json:"closed_at"`
+	StageEnteredAt    time.Time         `json:"stage_entered_at"`
+	Notes             string            `gorm:"type:text" json:"notes,omitempty"`
+	Titles            []DealTitle       `gorm:"foreignKey:DealID" json:"titles"`
+	Stage             *PipelineStage    `gorm:"foreignKey:StageID" json:"stage,omitempty"`
+	Contact           *Contact          `gorm:"foreignKey:ContactID" json:"contact,omitempty"`
+	Organization      *Organization     `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+	History           []DealStageChange `gorm:"foreignKey:DealID" json:"history,omitempty"`
+}
+
+// DealTitle is a title a deal is about
+type DealTitle struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	DealID    uint      `gorm:"uniqueIndex:idx_deal_titles_title" json:"-"`
+	TitleType TitleType `gorm:"size:20;uniqueIndex:idx_deal_titles_title" json:"title_type"`
+	TitleID   uint      `gorm:"uniqueIndex:idx_deal_titles_title" json:"title_id"`
+}
+
+// DealStageChange records a deal entering a stage, or being won, lost or reopened in it. Status is the
+// status of the deal from then on.
+type DealStageChange struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	DealID      uint       `gorm:"index" json:"deal_id"`
+	FromStageID *uint      `json:"from_stage_id"`
+	ToStageID   uint       `gorm:"index" json:"to_stage_id"`
+	Status      DealStatus `gorm:"size:10" json:"status"`
+	ChangedBy   uint       `json:"changed_by"`
+	ChangedAt   time.Time  `gorm:"index" json:"changed_at"`
+}
+
+// PipelineRequest creates or replaces a pipeline. Stages come in order, those with an ID are existing
+// stages kept, existing stages left out are removed.
+type PipelineRequest struct {
+	Name        string                 `json:"name" binding:"required"`
+	Description string                 `json:"description"`
+	Stages      []PipelineStageRequest `json:"stages"`
+}
+
+type PipelineStageRequest struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Probability int    `json:"probability"`
+}
+
+// DealRequest creates or replaces a deal. PipelineID and StageID are only read when the deal is created,
+// the first stage of the pipeline when StageID is left out, deals then move with a DealStageRequest.
+type DealRequest struct {
+	Name              string          `json:"name" binding:"required"`
+	PipelineID        uint            `json:"pipeline_id"`
+	StageID           uint            `json:"stage_id"`
+	Value             decimal.Decimal `json:"value"`
+	Currency          string          `json:"currency" binding:"required,len=3"`
+	ExpectedCloseDate string          `json:"expected_close_date"`
+	ContactID         uint            `json:"contact_id"`
+	OrganizationID    uint            `json:"organization_id"`
+	OwnerID           uint            `json:"owner_id"`
+	Titles            []DealTitle     `json:"titles"`
+	Notes             string          `json:"notes"`
+}
+
+// DealStageRequest moves an open deal to another stage, possibly of another pipeline
+type DealStageRequest struct {
+	StageID uint `json:"stage_id" binding:"required"`
+}
+
+// DealCloseRequest marks a deal won or lost, lost deals need a reason
+type DealCloseRequest struct {
+	Status DealStatus `json:"status" binding:"required"`
+	Reason string     `json:"reason"`
+}
+
+// DealReopenRequest opens a closed deal again, in the stage it was closed in unless StageID is given
+type DealReopenRequest struct {
+	StageID uint `json:"stage_id"`
+}
+
+// DealFilter narrows down the deals listed. Query matches deal names.
+type DealFilter struct {
+	Query          string
+	PipelineID     uint
+	StageID        uint
+	Status         DealStatus
+	OwnerID        uint
+	ContactID      uint
+	OrganizationID uint
+	TitleType      TitleType
+	TitleID        uint
+	Page           int
+	Limit          int
+}
+
+// DealReportFilter narrows down the deals of a pipeline report to those created in a period or owned by someone
+type DealReportFilter struct {
+	PipelineID uint
+	OwnerID    uint
+	From       *time.Time
+	To         *time.Time
+}
+
+// StageTotal is the count and total value of the open deals of a stage in one currency
+type StageTotal struct {
+	StageID  uint
+	Currency string
+	Deals    int64
+	Total    decimal.Decimal
+}
+
+// StageValue is what the open deals of a stage are worth, WeightedValue accounting for the stage probability
+type StageValue struct {
+	StageID       uint            `json:"stage_id"`
+	Name          string          `json:"name"`
+	Probability   int             `json:"probability"`
+	Deals         int64           `json:"deals"`
+	Value         decimal.Decimal `json:"value"`
+	WeightedValue decimal.Decimal `json:"weighted_value"`
+}
+
+// PipelineValueReport is what the open deals of a pipeline are worth by stage, in one currency
+type PipelineValueReport struct {
+	PipelineID    uint            `json:"pipeline_id"`
+	Currency      string          `json:"currency"`
+	AsOf          string          `json:"as_of"`
+	Stages        []StageValue    `json:"stages"`
+	Deals         int64           `json:"deals"`
+	Value         decimal.Decimal `json:"value"`
+	WeightedValue decimal.Decimal `json:"weighted_value"`
+}
+
+// DealProgress is how far a deal got in its pipeline, the position of the furthest stage it entered
+type DealProgress struct {
+	DealID   uint
+	Status   DealStatus
+	Position int
+}
+
+// StageConversion is how many deals reached a stage and how many of them went further, Rate in percent
+type StageConversion struct {
+	StageID  uint            `json:"stage_id"`
+	Name     string          `json:"name"`
+	Reached  int64           `json:"reached"`
+	Advanced int64           `json:"advanced"`
+	Rate     decimal.Decimal `json:"rate"`
+}
+
+// ConversionReport follows the deals of a pipeline from stage to stage. The last stage advances to won.
+type ConversionReport struct {
+	PipelineID uint              `json:"pipeline_id"`
+	Stages     []StageConversion `json:"stages"`
+	Deals      int64             `json:"deals"`
+	Won        int64             `json:"won"`
+	Lost       int64             `json:"lost"`
+	WinRate    decimal.Decimal   `json:"win_rate"`
+}
+
+// StageDuration is how long deals stay in a stage on average, stays still going count up to now
+type StageDuration struct {
+	StageID     uint            `json:"stage_id"`
+	Name        string          `json:"name"`
+	Stays       int64           `json:"stays"`
+	AverageDays decimal.Decimal `json:"average_days"`
+}
+
+type StageTimeReport struct {
+	PipelineID uint            `json:"pipeline_id"`
+	Stages     []StageDuration `json:"stages"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// DirectUpload is a file the client sends straight to storage through presigned requests
+type DirectUpload struct {
+	ID             string    `gorm:"primaryKey;size:36" json:"id"`
+	UserID         uint      `json:"user_id"`
+	TitleType      TitleType `gorm:"size:20" json:"title_type"`
+	TitleID        uint      `json:"title_id"`
+	Kind           MediaKind `gorm:"size:20" json:"kind"`
+	Filename       string    `gorm:"size:255" json:"filename"`
+	ContentType    string    `gorm:"size:100" json:"content_type"`
+	StorageKey     string    `gorm:"type:text" json:"storage_key"`
+	Size           int64     `json:"size"`
+	ChecksumSHA256 string    `gorm:"size:64" json:"checksum_sha256"` // base64, only for single part uploads
+	MultipartID    string    `gorm:"type:text" json:"-"`
+	Completed      bool      `json:"completed"`
+	MediaAssetID   *uint     `json:"media_asset_id"`
+	ExpiresAt      time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// IsMultipart reports whether the client sends the file in several parts
+func (d *DirectUpload) IsMultipart() bool {
+	return d.MultipartID != ""
+}
+
+// DirectUploadPart is a part the client was allowed to send for a multipart direct upload
+type DirectUploadPart struct {
+	ID             uint   `gorm:"primaryKey"`
+	UploadID       string `gorm:"size:36;uniqueIndex:idx_direct_upload_parts_number"`
+	PartNumber     int32  `gorm:"uniqueIndex:idx_direct_upload_parts_number"`
+	Size           int64
+	ChecksumSHA256 string `gorm:"size:64"`
+}
+
+type DirectUploadRequest struct {
+	TitleType      TitleType `json:"title_type" binding:"required"`
+	TitleID        uint      `json:"title_id" binding:"required"`
+	Kind           MediaKind `json:"kind"`
+	Filename       string    `json:"filename" binding:"required"`
+	ContentType    string    `json:"content_type" binding:"required"`
+	Size           int64     `json:"size" binding:"required,gt=0"`
+	ChecksumSHA256 string    `json:"checksum_sha256"` // base64, required unless multipart
+	Multipart      bool      `json:"multipart"`
+}
+
+type PresignPartRequest struct {
+	PartNumber     int32  `json:"part_number" binding:"required,min=1,max=10000"`
+	Size           int64  `json:"size" binding:"required,gt=0"`
+	ChecksumSHA256 string `json:"checksum_sha256" binding:"required"`
+}
+
+type CompletedUploadPart struct {
+	PartNumber int32  `json:"part_number" binding:"required"`
+	ETag       string `json:"etag" binding:"required"`
+}
+
+type CompleteDirectUploadRequest struct {
+	Parts []CompletedUploadPart `json:"parts"`
+}
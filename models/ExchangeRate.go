@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeRate is what one unit of Base was worth in Quote from EffectiveOn until the next rate of the pair
+type ExchangeRate struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	Base        string          `gorm:"size:3;uniqueIndex:idx_exchange_rates_pair" json:"base"`
+	Quote       string          `gorm:"size:3;uniqueIndex:idx_exchange_rates_pair" json:"quote"`
+	EffectiveOn time.Time       `gorm:"type:date;uniqueIndex:idx_exchange_rates_pair" json:"effective_on"`
+	Rate        decimal.Decimal `gorm:"type:numeric(20,8)" json:"rate"`
+	CreatedBy   uint            `json:"created_by"`
+	CreatedAt   int64           `json:"created_at"`
+}
+
+type ExchangeRateRequest struct {
+	Base        string          `json:"base" binding:"required,len=3"`
+	Quote       string          `json:"quote" binding:"required,len=3"`
+	EffectiveOn string          `json:"effective_on" binding:"required"`
+	Rate        decimal.Decimal `json:"rate"`
+}
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type InvoiceStatus string
+
+const (
+	InvoiceDraft         InvoiceStatus = "draft"
+	InvoiceSent          InvoiceStatus = "sent"
+	InvoicePartiallyPaid InvoiceStatus = "partially_paid"
+	InvoicePaid          InvoiceStatus = "paid"
+	InvoiceVoid          InvoiceStatus = "void"
+)
+
+// IsValid reports whether s is a known invoice status
+func (s InvoiceStatus) IsValid() bool {
+	switch s {
+	case InvoiceDraft, InvoiceSent, InvoicePartiallyPaid, InvoicePaid, InvoiceVoid:
+		return true
+	}
+	return false
+}
+
+// IsOpen reports whether an invoice in status s has been issued and can still be paid
+func (s InvoiceStatus) IsOpen() bool {
+	return s == InvoiceSent || s == InvoicePartiallyPaid
+}
+
+// Invoice bills an organization for licence fees or services, possibly under a deal or a contract.
+// Drafts have no number, invoices get the next number of the year they are issued in when first sent.
+// Amounts are in Currency: Subtotal before discounts, Total after discounts and taxes.
+type Invoice struct {
+	Model
+	Number         string           `gorm:"size:50;uniqueIndex:idx_invoices_number,where:number <> ''" json:"number,omitempty"` // e.g. INV-2025-0042
+	OrganizationID uint             `gorm:"index" json:"organization_id"`
+	ContactID      *uint            `gorm:"index" json:"contact_id"` // who the invoice is for at the organization
+	DealID         *uint            `gorm:"index" json:"deal_id"`
+	ContractID     *uint            `gorm:"index" json:"contract_id"`
+	Status         InvoiceStatus    `gorm:"size:20;index" json:"status"`
+	Currency       string           `gorm:"size:3" json:"currency"`
+	ExchangeRate   decimal.Decimal  `gorm:"type:numeric(20,8);not null;default:0" json:"exchange_rate"` // to the reporting currency, booked at on issue
+	IssueDate      *time.Time       `gorm:"type:date" json:"issue_date"`
+	DueDate        *time.Time       `gorm:"type:date;index" json:"due_date"`
+	PaymentDays    int              `json:"payment_days"` // the due date is this many days after the issue date
+	Subtotal       decimal.Decimal  `gorm:"type:numeric(18,2);not null;default:0" json:"subtotal"`
+	DiscountTotal  decimal.Decimal  `gorm:"type:numeric(18,2);not null;default:0" json:"discount_total"`
+	TaxTotal       decimal.Decimal  `gorm:"type:numeric(18,2);not null;default:0" json:"tax_total"`
+	Total          decimal.Decimal  `gorm:"type:numeric(18,2);not null;default:0" json:"total"`
+	AmountPaid     decimal.Decimal  `gorm:"type:numeric(18,2);not null;default:0" json:"amount_paid"`
+	Notes          string           `gorm:"type:text" json:"notes,omitempty"` // printed on the invoice, e.g. bank details
+	SentAt         *time.Time       `json:"sent_at"`
+	SentTo         string           `gorm:"size:255" json:"sent_to,omitempty"`
+	VoidedAt       *time.Time       `json:"voided_at,omitempty"`
+	VoidReason     string           `gorm:"type:text" json:"void_reason,omitempty"`
+	CreatedBy      uint             `json:"created_by"`
+	Lines          []InvoiceLine    `gorm:"foreignKey:InvoiceID" json:"lines"`
+	Payments       []InvoicePayment `gorm:"foreignKey:InvoiceID" json:"payments"`
+	Organization   *Organization    `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+	Contact        *Contact         `gorm:"foreignKey:ContactID" json:"contact,omitempty"`
+}
+
+// Balance is what is left to pay on the invoice
+func (i *Invoice) Balance() decimal.Decimal {
+	return i.Total.Sub(i.AmountPaid)
+}
+
+// Settle sets the status of an issued invoice from what has been paid on it
+func (i *Invoice) Settle() {
+	switch {
+	case !i.AmountPaid.IsPositive():
+		i.Status = InvoiceSent
+	case i.AmountPaid.LessThan(i.Total):
+		i.Status = InvoicePartiallyPaid
+	default:
+		i.Status = InvoicePaid
+	}
+}
+
+// InvoiceLine is an item billed: Quantity times UnitPrice, less DiscountPercent, is Amount, on which
+// TaxPercent of the tax TaxName is charged
+type InvoiceLine struct {
+	ID              uint            `gorm:"primaryKey" json:"id"`
+	InvoiceID       uint            `gorm:"index" json:"-"`
+	Position        int             `json:"position"`
+	Description     string          `gorm:"size:500" json:"description"`
+	Quantity        decimal.Decimal `gorm:"type:numeric(18,4);not null;default:1" json:"quantity"`
+	UnitPrice       decimal.Decimal `gorm:"type:numeric(18,2);not null;default:0" json:"unit_price"`
+	DiscountPercent decimal.Decimal `gorm:"type:numeric(7,4);not null;default:0" json:"discount_percent"`
+	Discount        decimal.Decimal `gorm:"type:numeric(18,2);not null;default:0" json:"discount"`
+	Amount          decimal.Decimal `gorm:"type:numeric(18,2);not null;default:0" json:"amount"`
+	TaxRateID       *uint           `json:"tax_rate_id"`
+	TaxName         string          `gorm:"size:100" json:"tax_name,omitempty"`
+	TaxPercent      decimal.Decimal `gorm:"type:numeric(7,4);not null;default:0" json:"tax_percent"`
+	Tax             decimal.Decimal `gorm:"type:numeric(18,2);not null;default:0" json:"tax"`
+}
+
+// InvoicePayment is money received against an invoice
+type InvoicePayment struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	InvoiceID  uint            `gorm:"index" json:"invoice_id"`
+	Amount     decimal.Decimal `gorm:"type:numeric(18,2)" json:"amount"`
+	PaidOn     time.Time       `gorm:"type:date;index" json:"paid_on"`
+	Method     string          `gorm:"size:50" json:"method,omitempty"` // e.g. bank transfer or cheque
+	Reference  string          `gorm:"size:255" json:"reference,omitempty"`
+	RecordedBy uint            `json:"recorded_by"`
+	CreatedAt  int64           `json:"created_at"`
+}
+
+// InvoiceRequest creates or replaces a draft invoice. Without a due date the invoice is due PaymentDays,
+// or the configured default, after it is issued.
+type InvoiceRequest struct {
+	OrganizationID uint                 `json:"organization_id" binding:"required"`
+	ContactID      uint                 `json:"contact_id"`
+	DealID         uint                 `json:"deal_id"`
+	ContractID     uint                 `json:"contract_id"`
+	Currency       string               `json:"currency" binding:"required,len=3"`
+	DueDate        string               `json:"due_date"`
+	PaymentDays    int                  `json:"payment_days"`
+	Notes          string               `json:"notes"`
+	Lines          []InvoiceLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+// InvoiceLineRequest is an item billed, Quantity defaults to 1
+type InvoiceLineRequest struct {
+	Description     string          `json:"description" binding:"required"`
+	Quantity        decimal.Decimal `json:"quantity"`
+	UnitPrice       decimal.Decimal `json:"unit_price"`
+	DiscountPercent decimal.Decimal `json:"discount_percent"`
+	TaxRateID       uint            `json:"tax_rate_id"`
+}
+
+// InvoiceSendRequest emails an invoice, to the contact of the invoice or the organization unless an
+// address is given. Drafts are issued on the way out.
+type InvoiceSendRequest struct {
+	Email   string `json:"email" binding:"omitempty,email"`
+	Message string `json:"message"`
+}
+
+type InvoicePaymentRequest struct {
+	Amount    decimal.Decimal `json:"amount"`
+	PaidOn    string          `json:"paid_on" binding:"required"`
+	Method    string          `json:"method"`
+	Reference string          `json:"reference"`
+}
+
+type InvoiceVoidRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// InvoiceFilter narrows down the invoices listed. Overdue keeps open invoices past their due date.
+type InvoiceFilter struct {
+	Status         InvoiceStatus
+	OrganizationID uint
+	DealID         uint
+	ContractID     uint
+	Overdue        bool
+	Page           int
+	Limit          int
+}
+
+// InvoiceBalance is what was left to pay on an issued invoice on some date
+type InvoiceBalance struct {
+	InvoiceID      uint
+	Number         string
+	OrganizationID uint
+	Currency       string
+	DueDate        time.Time
+	Balance        decimal.Decimal
+}
+
+// AgingBuckets splits amounts owed by how long they are overdue
+type AgingBuckets struct {
+	Current    decimal.Decimal `json:"current"` // not due yet
+	Days1To30  decimal.Decimal `json:"days_1_30"`
+	Days31To60 decimal.Decimal `json:"days_31_60"`
+	Days61To90 decimal.Decimal `json:"days_61_90"`
+	Over90     decimal.Decimal `json:"over_90"`
+	Total      decimal.Decimal `json:"total"`
+}
+
+// OrganizationAging is what an organization owes, by how long it is overdue
+type OrganizationAging struct {
+	OrganizationID uint   `json:"organization_id"`
+	Name           string `json:"name"`
+	Invoices       int    `json:"invoices"`
+	AgingBuckets
+}
+
+// AgingReport is what is owed on issued invoices on a date, by organization and by how long it is
+// overdue, converted to one currency
+type AgingReport struct {
+	AsOf          string              `json:"as_of"`
+	Currency      string              `json:"currency"`
+	Organizations []OrganizationAging `json:"organizations"`
+	Totals        AgingBuckets        `json:"totals"`
+}
@@ -0,0 +1,236 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type AccountType string
+
+const (
+	AccountAsset     AccountType = "asset"
+	AccountLiability AccountType = "liability"
+	AccountEquity    AccountType = "equity"
+	AccountIncome    AccountType = "income"
+	AccountExpense   AccountType = "expense"
+)
+
+// IsValid reports whether t is a known account type
+func (t AccountType) IsValid() bool {
+	switch t {
+	case AccountAsset, AccountLiability, AccountEquity, AccountIncome, AccountExpense:
+		return true
+	}
+	return false
+}
+
+// DebitNormal reports whether debits increase the balance of accounts of type t
+func (t AccountType) DebitNormal() bool {
+	return t == AccountAsset || t == AccountExpense
+}
+
+// AccountPurpose marks the account invoices, payments and costs are posted to
+type AccountPurpose string
+
+const (
+	PurposeBank       AccountPurpose = "bank"
+	PurposeReceivable AccountPurpose = "receivable"
+	PurposePayable    AccountPurpose = "payable"
+	PurposeTaxPayable AccountPurpose = "tax_payable"
+	PurposeRevenue    AccountPurpose = "revenue"
+	PurposeExchange   AccountPurpose = "exchange_differences" // gains and losses on payments in other currencies
+)
+
+// CostPurpose is the purpose of the expense account the costs of a budget category are posted to
+func CostPurpose(category BudgetCategory) AccountPurpose {
+	return AccountPurpose("costs_" + string(category))
+}
+
+// Account is an account of the chart of accounts. Accounts with a purpose are the ones the ledger posts to
+// on its own, they cannot be deleted.
+type Account struct {
+	Model
+	Code        string         `gorm:"size:20;uniqueIndex" json:"code"` // e.g. 1100
+	Name        string         `gorm:"size:255" json:"name"`
+	Type        AccountType    `gorm:"size:20;index" json:"type"`
+	Purpose     AccountPurpose `gorm:"size:50;uniqueIndex:idx_accounts_purpose,where:purpose <> ''" json:"purpose,omitempty"`
+	Description string         `gorm:"type:text" json:"description,omitempty"`
+	Archived    bool           `json:"archived"` // archived accounts take no new entries
+}
+
+type AccountRequest struct {
+	Code        string      `json:"code" binding:"required"`
+	Name        string      `json:"name" binding:"required"`
+	Type        AccountType `json:"type" binding:"required"`
+	Description string      `json:"description"`
+	Archived    bool        `json:"archived"`
+}
+
+type EntryStatus string
+
+const (
+	EntryDraft  EntryStatus = "draft"
+	EntryPosted EntryStatus = "posted"
+)
+
+// EntrySource is what a journal entry books, the table of the record for entries posted on their own
+type EntrySource string
+
+const (
+	SourceManual         EntrySource = "manual"
+	SourceInvoice        EntrySource = "invoices"
+	SourceInvoicePayment EntrySource = "invoice_payments"
+	SourceCost           EntrySource = "cost_entries"
+)
+
+// JournalEntry is a transaction of the general ledger, its lines debit and credit accounts in the reporting
+// currency. Posted entries balance and never change, which the database enforces too; a mistake is undone by
+// posting a reversal, an entry swapping the debits and credits of the one it reverses.
+type JournalEntry struct {
+	ID           uint          `gorm:"primaryKey" json:"id"`
+	Number       string        `gorm:"size:50;uniqueIndex:idx_journal_entries_number,where:number <> ''" json:"number,omitempty"` // e.g. JE-2025-00042
+	Date         time.Time     `gorm:"type:date;index" json:"date"`
+	Memo         string        `gorm:"size:500" json:"memo"`
+	Reference    string        `gorm:"size:100" json:"reference,omitempty"` // e.g. the number of the invoice booked
+	Status       EntryStatus   `gorm:"size:20;index" json:"status"`
+	Source       EntrySource   `gorm:"size:30;uniqueIndex:idx_journal_entries_source,where:source <> 'manual' AND reversal_of_id IS NULL" json:"source"`
+	SourceID     uint          `gorm:"uniqueIndex:idx_journal_entries_source" json:"source_id,omitempty"`
+	ReversalOfID *uint         `gorm:"uniqueIndex" json:"reversal_of_id"`
+	ReversedByID *uint         `gorm:"-" json:"reversed_by_id"`
+	PostedAt     *time.Time    `json:"posted_at"`
+	PostedBy     *uint         `json:"posted_by"`
+	CreatedBy    uint          `json:"created_by"`
+	CreatedAt    int64         `json:"created_at"`
+	UpdatedAt    int64         `json:"updated_at"`
+	Lines        []JournalLine `gorm:"foreignKey:EntryID" json:"lines"`
+}
+
+// JournalLine debits or credits an account, never both
+type JournalLine struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	EntryID     uint            `gorm:"index" json:"-"`
+	AccountID   uint            `gorm:"index" json:"account_id"`
+	Debit       decimal.Decimal `gorm:"type:numeric(18,2);not null;default:0" json:"debit"`
+	Credit      decimal.Decimal `gorm:"type:numeric(18,2);not null;default:0" json:"credit"`
+	Description string          `gorm:"size:255" json:"description,omitempty"`
+	Account     *Account        `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+}
+
+// JournalEntryRequest creates or replaces a draft manual entry
+type JournalEntryRequest struct {
+	Date      string               `json:"date" binding:"required"`
+	Memo      string               `json:"memo" binding:"required"`
+	Reference string               `json:"reference"`
+	Lines     []JournalLineRequest `json:"lines" binding:"required,min=2,dive"`
+}
+
+type JournalLineRequest struct {
+	AccountID   uint            `json:"account_id" binding:"required"`
+	Debit       decimal.Decimal `json:"debit"`
+	Credit      decimal.Decimal `json:"credit"`
+	Description string          `json:"description"`
+}
+
+// JournalReverseRequest reverses a posted manual entry, on the day it is made unless a date is given
+type JournalReverseRequest struct {
+	Date string `json:"date"`
+	Memo string `json:"memo"`
+}
+
+// JournalFilter narrows down the entries listed, From and To bound their date
+type JournalFilter struct {
+	Status    EntryStatus
+	Source    EntrySource
+	AccountID uint
+	From      *time.Time
+	To        *time.Time
+	Page      int
+	Limit     int
+}
+
+type PeriodStatus string
+
+const (
+	PeriodOpen   PeriodStatus = "open"
+	PeriodClosed PeriodStatus = "closed" // takes no entries until it is reopened
+	PeriodLocked PeriodStatus = "locked" // closed for good
+)
+
+// AccountingPeriod is a span of days, usually a month, closed once its books are done. Entries cannot be
+// posted on the days of a closed or locked period, days outside any period are open.
+type AccountingPeriod struct {
+	ID        uint         `gorm:"primaryKey" json:"id"`
+	Name      string       `gorm:"size:50" json:"name"` // e.g. 2025-06
+	StartDate time.Time    `gorm:"type:date;index" json:"start_date"`
+	EndDate   time.Time    `gorm:"type:date;index" json:"end_date"`
+	Status    PeriodStatus `gorm:"size:20" json:"status"`
+	ClosedAt  *time.Time   `json:"closed_at"`
+	ClosedBy  *uint        `json:"closed_by"`
+	CreatedAt int64        `json:"created_at"`
+}
+
+// AccountingPeriodRequest opens a period, named after its first month unless a name is given
+type AccountingPeriodRequest struct {
+	Name      string `json:"name"`
+	StartDate string `json:"start_date" binding:"required"`
+	EndDate   string `json:"end_date" binding:"required"`
+}
+
+// AccountTotal adds up the posted lines of an account
+type AccountTotal struct {
+	AccountID uint
+	Code      string
+	Name      string
+	Type      AccountType
+	Debit     decimal.Decimal
+	Credit    decimal.Decimal
+}
+
+// AccountBalance is the balance of an account, positive on its normal side
+type AccountBalance struct {
+	AccountID uint            `json:"account_id"`
+	Code      string          `json:"code"`
+	Name      string          `json:"name"`
+	Type      AccountType     `json:"type"`
+	Debit     decimal.Decimal `json:"debit"`
+	Credit    decimal.Decimal `json:"credit"`
+	Balance   decimal.Decimal `json:"balance"`
+}
+
+// TrialBalance lists the debits and credits of every account up to a day, they add up to the same total
+type TrialBalance struct {
+	AsOf        string           `json:"as_of"`
+	Currency    string           `json:"currency"`
+	Accounts    []AccountBalance `json:"accounts"`
+	TotalDebit  decimal.Decimal  `json:"total_debit"`
+	TotalCredit decimal.Decimal  `json:"total_credit"`
+	Balanced    bool             `json:"balanced"`
+}
+
+// ProfitAndLoss is the income and expenses of the days from From to To
+type ProfitAndLoss struct {
+	From          string           `json:"from"`
+	To            string           `json:"to"`
+	Currency      string           `json:"currency"`
+	Income        []AccountBalance `json:"income"`
+	Expenses      []AccountBalance `json:"expenses"`
+	TotalIncome   decimal.Decimal  `json:"total_income"`
+	TotalExpenses decimal.Decimal  `json:"total_expenses"`
+	NetIncome     decimal.Decimal  `json:"net_income"`
+}
+
+// BalanceSheet is what is owned and owed on a day. The books are not closed into retained earnings, the
+// net income of every day up to then is shown as current earnings, part of equity.
+type BalanceSheet struct {
+	AsOf             string           `json:"as_of"`
+	Currency         string           `json:"currency"`
+	Assets           []AccountBalance `json:"assets"`
+	Liabilities      []AccountBalance `json:"liabilities"`
+	Equity           []AccountBalance `json:"equity"`
+	CurrentEarnings  decimal.Decimal  `json:"current_earnings"`
+	TotalAssets      decimal.Decimal  `json:"total_assets"`
+	TotalLiabilities decimal.Decimal  `json:"total_liabilities"`
+	TotalEquity      decimal.Decimal  `json:"total_equity"`
+	Balanced         bool             `json:"balanced"`
+}
@@ -0,0 +1,37 @@
+package models
+
+type MediaKind string
+
+const (
+	MediaVideo    MediaKind = "video"
+	MediaPicture  MediaKind = "picture"
+	MediaPoster   MediaKind = "poster"
+	MediaSubtitle MediaKind = "subtitle"
+)
+
+// StorageFolder is the folder files of this kind are kept in under their title's prefix
+func (k MediaKind) StorageFolder() string {
+	return string(k) + "s"
+}
+
+// MediaAsset is a file in storage attached to a trailer or a full-length film
+type MediaAsset struct {
+	Model
+	TitleType   TitleType `gorm:"size:20;index:idx_media_assets_title" json:"title_type"`
+	TitleID     uint      `gorm:"index:idx_media_assets_title" json:"title_id"`
+	Kind        MediaKind `gorm:"size:20" json:"kind"`
+	StorageKey  string    `gorm:"type:text" json:"storage_key"`
+	URL         string    `gorm:"type:text" json:"url"`
+	Filename    string    `gorm:"size:255" json:"filename"`
+	ContentType string    `gorm:"size:100" json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `gorm:"size:64;index" json:"sha256"`
+	UploadedBy  uint      `json:"uploaded_by"`
+
+	// Filled in from the file itself when it is inspected
+	Width           int     `json:"width,omitempty"`
+	Height          int     `json:"height,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	VideoCodec      string  `gorm:"size:20" json:"video_codec,omitempty"`
+	AudioCodec      string  `gorm:"size:20" json:"audio_codec,omitempty"`
+}
@@ -1,12 +1,16 @@
 package models
 
 import (
+	"fmt"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type MovieStatus string
 
 const (
+    Draft    MovieStatus = "Draft" // still being uploaded, not visible yet
     Pending  MovieStatus = "Pending"
     Approved MovieStatus = "Approved"
 )
@@ -18,5 +22,35 @@ type MovieBase struct {
 	Duration    int            `gorm:"not null"` // Duration in minutes
 	UploadedAt  time.Time      `gorm:"autoCreateTime"`
 	Status      MovieStatus    `gorm:"type:varchar(20);default:'Pending'"`
-	DeletedAt   int64 
-}
\ No newline at end of file
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+	Version     int            `gorm:"not null;default:1"` // bumped on every write, it is the ETag of the title
+}
+
+// TitleType tells which catalogue table a title ID points to
+type TitleType string
+
+const (
+	TitleTrailer    TitleType = "trailer"
+	TitleFullLength TitleType = "full_length"
+)
+
+// TableName returns the table holding titles of this type
+func (t TitleType) TableName() string {
+	switch t {
+	case TitleTrailer:
+		return "trailers"
+	case TitleFullLength:
+		return "full_lengths"
+	}
+	return ""
+}
+
+// StoragePrefix is where the media files of a title are stored, e.g. "trailers/42"
+func (t TitleType) StoragePrefix(id uint) string {
+	return fmt.Sprintf("%s/%d", t.TableName(), id)
+}
+
+// IsValid reports whether t is a known title type
+func (t TitleType) IsValid() bool {
+	return t.TableName() != ""
+}
@@ -0,0 +1,68 @@
+package models
+
+// OrganizationKind is the kind of business an organization is to us
+type OrganizationKind string
+
+const (
+	OrganizationDistributor  OrganizationKind = "distributor"
+	OrganizationFestival     OrganizationKind = "festival"
+	OrganizationVendor       OrganizationKind = "vendor"
+	OrganizationTalentAgency OrganizationKind = "talent_agency"
+	OrganizationBroadcaster  OrganizationKind = "broadcaster"
+	OrganizationOther        OrganizationKind = "other"
+)
+
+func (k OrganizationKind) IsValid() bool {
+	switch k {
+	case OrganizationDistributor, OrganizationFestival, OrganizationVendor, OrganizationTalentAgency, OrganizationBroadcaster, OrganizationOther:
+		return true
+	}
+	return false
+}
+
+// Organization is a company we deal with, e.g. a distributor, a festival or a talent agency
+type Organization struct {
+	Model
+	Name         string                `gorm:"size:255;index" json:"name"`
+	Kind         OrganizationKind      `gorm:"size:20;index" json:"kind"`
+	Website      string                `gorm:"size:255" json:"website,omitempty"`
+	TaxID        string                `gorm:"size:50" json:"tax_id,omitempty"`
+	Notes        string                `gorm:"type:text" json:"notes,omitempty"`
+	OwnerID      uint                  `gorm:"index" json:"owner_id"`
+	CustomFields map[string]string     `gorm:"serializer:json;type:jsonb" json:"custom_fields"`
+	Emails       []EmailAddress        `gorm:"polymorphic:Party;polymorphicValue:organizations" json:"emails"`
+	Phones       []PhoneNumber         `gorm:"polymorphic:Party;polymorphicValue:organizations" json:"phones"`
+	Addresses    []PostalAddress       `gorm:"polymorphic:Party;polymorphicValue:organizations" json:"addresses"`
+	Contacts     []ContactOrganization `gorm:"foreignKey:OrganizationID" json:"contacts,omitempty"`
+}
+
+// OrganizationRequest creates or replaces an organization. An organization sharing its name, an email or
+// a phone with another one is refused as a likely duplicate unless AllowDuplicate is set.
+type OrganizationRequest struct {
+	Name           string                 `json:"name" binding:"required"`
+	Kind           OrganizationKind       `json:"kind" binding:"required"`
+	Website        string                 `json:"website"`
+	TaxID          string                 `json:"tax_id"`
+	Notes          string                 `json:"notes"`
+	OwnerID        uint                   `json:"owner_id"`
+	CustomFields   map[string]string      `json:"custom_fields"`
+	Emails         []EmailAddressRequest  `json:"emails" binding:"dive"`
+	Phones         []PhoneNumberRequest   `json:"phones" binding:"dive"`
+	Addresses      []PostalAddressRequest `json:"addresses" binding:"dive"`
+	AllowDuplicate bool                   `json:"allow_duplicate"`
+}
+
+// OrganizationFilter narrows down an organization search. Query matches names, emails and phone numbers.
+type OrganizationFilter struct {
+	Query   string
+	Kind    OrganizationKind
+	OwnerID uint
+	Page    int
+	Limit   int
+}
+
+// OrganizationDuplicates is a group of organizations sharing a name, an email or a phone number
+type OrganizationDuplicates struct {
+	DuplicateMatch
+	Organizations []Organization `json:"organizations"`
+}
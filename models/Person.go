@@ -0,0 +1,21 @@
+package models
+
+// Person is someone who worked on our productions, in front of or behind the camera
+type Person struct {
+	Model
+	Name      string `gorm:"size:255;index" json:"name"`
+	Aliases   string `gorm:"type:text" json:"aliases"` // comma separated, e.g. stage names
+	PhotoURL  string `gorm:"type:text" json:"photo_url"`
+	Bio       string `gorm:"type:text" json:"bio"`
+	Email     string `gorm:"size:255" json:"email"`
+	Telephone string `gorm:"size:50" json:"telephone"`
+}
+
+type PersonRequest struct {
+	Name      string `json:"name" binding:"required"`
+	Aliases   string `json:"aliases"`
+	PhotoURL  string `json:"photo_url"`
+	Bio       string `json:"bio"`
+	Email     string `json:"email" binding:"omitempty,email"`
+	Telephone string `json:"telephone"`
+}
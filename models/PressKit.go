@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// PressKitAsset is a media asset picked for the press kit of a title. Stills and posters come from the
+// title itself, trailer videos from any trailer.
+type PressKitAsset struct {
+	TitleType TitleType   `gorm:"primaryKey;size:20" json:"title_type"`
+	TitleID   uint        `gorm:"primaryKey" json:"title_id"`
+	AssetID   uint        `gorm:"primaryKey;index" json:"asset_id"`
+	Asset     *MediaAsset `gorm:"foreignKey:AssetID" json:"asset,omitempty"`
+	Position  int         `json:"position"`
+}
+
+type PressKitAssetsRequest struct {
+	AssetIDs []uint `json:"asset_ids"`
+}
+
+// PressKit is the manifest of the electronic press kit of a title, it lists everything in the bundle
+type PressKit struct {
+	TitleType       TitleType           `json:"title_type"`
+	TitleID         uint                `json:"title_id"`
+	Title           string              `json:"title"`
+	LogLine         string              `json:"log_line,omitempty"`
+	Synopsis        string              `json:"synopsis,omitempty"`
+	Year            string              `json:"year,omitempty"`
+	DurationMinutes int                 `json:"duration_minutes,omitempty"`
+	Terms           map[string][]string `json:"terms,omitempty"` // taxonomy term names by kind, e.g. genre
+	Credits         []PressKitCredit    `json:"credits"`
+	Files           []PressKitFile      `json:"files"`
+	Trailers        []PressKitTrailer   `json:"trailers"`
+	// Fingerprint changes whenever anything in the bundle does, it is the ETag of the bundle
+	Fingerprint string    `json:"fingerprint"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Filename    string    `json:"-"` // of the ZIP bundle
+}
+
+type PressKitCredit struct {
+	Name          string     `json:"name"`
+	Role          CreditRole `json:"role"`
+	CharacterName string     `json:"character_name,omitempty"`
+}
+
+// PressKitFile is a still or poster in the bundle, Path is where it is in the ZIP
+type PressKitFile struct {
+	Path        string    `json:"path"`
+	Kind        MediaKind `json:"kind"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	Width       int       `json:"width,omitempty"`
+	Height      int       `json:"height,omitempty"`
+	StorageKey  string    `json:"-"`
+}
+
+// PressKitTrailer links to a trailer video, the link stops working at ExpiresAt
+type PressKitTrailer struct {
+	Title           string    `json:"title"`
+	URL             string    `json:"url"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	DurationSeconds float64   `json:"duration_seconds,omitempty"`
+	Width           int       `json:"width,omitempty"`
+	Height          int       `json:"height,omitempty"`
+	SHA256          string    `json:"sha256"`
+}
@@ -0,0 +1,336 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type PurchaseOrderStatus string
+
+const (
+	PurchaseOrderDraft     PurchaseOrderStatus = "draft"
+	PurchaseOrderSubmitted PurchaseOrderStatus = "submitted" // waiting for its approvals
+	PurchaseOrderApproved  PurchaseOrderStatus = "approved"
+	PurchaseOrderOrdered   PurchaseOrderStatus = "ordered" // sent to the vendor, goods are received against it
+	PurchaseOrderReceived  PurchaseOrderStatus = "received"
+	PurchaseOrderClosed    PurchaseOrderStatus = "closed"
+)
+
+// IsValid reports whether s is a known purchase order status
+func (s PurchaseOrderStatus) IsValid() bool {
+	switch s {
+	case PurchaseOrderDraft, PurchaseOrderSubmitted, PurchaseOrderApproved, PurchaseOrderOrdered, PurchaseOrderReceived, PurchaseOrderClosed:
+		return true
+	}
+	return false
+}
+
+// PurchaseOrder is an order of services or equipment from a vendor, possibly for the budget of a production.
+// Drafts are submitted for approval, getting the next number of the year on their first submission, and once
+// approved are ordered from the vendor. Goods are received against an ordered purchase order, which is
+// received when every line is, and bills of the vendor are matched against what was ordered and received.
+// Purchase orders end closed, on their own once fully received and billed or when closed short.
+type PurchaseOrder struct {
+	Model
+	Number          string                  `gorm:"size:50;uniqueIndex:idx_purchase_orders_number,where:number <> ''" json:"number,omitempty"` // e.g. PO-2025-0042
+	VendorID        uint                    `gorm:"index" json:"vendor_id"`
+	BudgetID        *uint                   `gorm:"index" json:"budget_id"` // the production budget the purchase is for
+	Status          PurchaseOrderStatus     `gorm:"size:20;index" json:"status"`
+	Currency        string                  `gorm:"size:3" json:"currency"`
+	Total           decimal.Decimal         `gorm:"type:numeric(18,2);not null;default:0" json:"total"`
+	ExchangeRate    decimal.Decimal         `gorm:"type:numeric(20,8);not null;default:0" json:"exchange_rate"` // to the reporting currency, on submission
+	BaseTotal       decimal.Decimal         `gorm:"type:numeric(18,2);not null;default:0" json:"base_total"`    // the total in the reporting currency, which decides the approvals needed
+	DeliveryDate    *time.Time              `gorm:"type:date" json:"delivery_date"`
+	DeliveryAddress string                  `gorm:"type:text" json:"delivery_address,omitempty"`
+	Notes           string                  `gorm:"type:text" json:"notes,omitempty"`
+	SubmittedBy     *uint                   `json:"submitted_by"`
+	SubmittedAt     *time.Time              `json:"submitted_at"`
+	RejectionNote   string                  `gorm:"size:500" json:"rejection_note,omitempty"` // why it was last sent back to draft
+	ApprovedAt      *time.Time              `json:"approved_at"`
+	OrderedAt       *time.Time              `json:"ordered_at"`
+	ReceivedAt      *time.Time              `json:"received_at"`
+	ClosedAt        *time.Time              `json:"closed_at"`
+	ClosedBy        *uint                   `json:"closed_by"`
+	CloseReason     string                  `gorm:"size:500" json:"close_reason,omitempty"`
+	CreatedBy       uint                    `json:"created_by"`
+	Lines           []PurchaseOrderLine     `gorm:"foreignKey:PurchaseOrderID" json:"lines"`
+	Approvals       []PurchaseOrderApproval `gorm:"foreignKey:PurchaseOrderID" json:"approvals"`
+	Vendor          *Vendor                 `gorm:"foreignKey:VendorID" json:"vendor,omitempty"`
+}
+
+// FullyReceived reports whether every line of the order has been received
+func (o *PurchaseOrder) FullyReceived() bool {
+	for _, line := range o.Lines {
+		if line.Outstanding().IsPositive() {
+			return false
+		}
+	}
+	return true
+}
+
+// FullyBilled reports whether every line of the order has been billed for all that was ordered
+func (o *PurchaseOrder) FullyBilled() bool {
+	for _, line := range o.Lines {
+		if line.QuantityBilled.LessThan(line.Quantity) {
+			return false
+		}
+	}
+	return true
+}
+
+// PendingApproval is the lowest level still to approve a submitted order, nil once every level has
+func (o *PurchaseOrder) PendingApproval() *PurchaseOrderApproval {
+	var pending *PurchaseOrderApproval
+	for i, approval := range o.Approvals {
+		if approval.Decision == ApprovalPending && (pending == nil || approval.Level < pending.Level) {
+			pending = &o.Approvals[i]
+		}
+	}
+	return pending
+}
+
+// PurchaseOrderLine is an item ordered: Quantity at UnitPrice is Amount. QuantityReceived and QuantityBilled
+// add up the goods received notes and the matched vendor bills of the line.
+type PurchaseOrderLine struct {
+	ID               uint            `gorm:"primaryKey" json:"id"`
+	PurchaseOrderID  uint            `gorm:"index" json:"-"`
+	Position         int             `json:"position"`
+	Description      string          `gorm:"size:500" json:"description"`
+	Quantity         decimal.Decimal `gorm:"type:numeric(18,4);not null;default:1" json:"quantity"`
+	UnitPrice        decimal.Decimal `gorm:"type:numeric(18,2);not null;default:0" json:"unit_price"`
+	Amount           decimal.Decimal `gorm:"type:numeric(18,2);not null;default:0" json:"amount"`
+	QuantityReceived decimal.Decimal `gorm:"type:numeric(18,4);not null;default:0" json:"quantity_received"`
+	QuantityBilled   decimal.Decimal `gorm:"type:numeric(18,4);not null;default:0" json:"quantity_billed"`
+}
+
+// Outstanding is what is left to receive on the line
+func (l *PurchaseOrderLine) Outstanding() decimal.Decimal {
+	return l.Quantity.Sub(l.QuantityReceived)
+}
+
+type ApprovalDecision string
+
+const (
+	ApprovalPending  ApprovalDecision = "pending"
+	ApprovalApproved ApprovalDecision = "approved"
+	ApprovalRejected ApprovalDecision = "rejected"
+)
+
+// PurchaseOrderApproval is a level of approval a submitted order needs, decided by a user with Role or an
+// admin. Levels are decided in order and by different users, none of them the one who raised the order.
+type PurchaseOrderApproval struct {
+	ID              uint             `gorm:"primaryKey" json:"id"`
+	PurchaseOrderID uint             `gorm:"uniqueIndex:idx_purchase_order_approvals_level;uniqueIndex:idx_purchase_order_approvals_decider,where:decided_by IS NOT NULL" json:"-"`
+	Level           int              `gorm:"uniqueIndex:idx_purchase_order_approvals_level" json:"level"`
+	Role            string           `gorm:"size:50" json:"role"`
+	Decision        ApprovalDecision `gorm:"size:20" json:"decision"`
+	DecidedBy       *uint            `gorm:"uniqueIndex:idx_purchase_order_approvals_decider" json:"decided_by"`
+	DecidedAt       *time.Time       `json:"decided_at"`
+	Note            string           `gorm:"size:500" json:"note,omitempty"`
+}
+
+// PurchaseApprovalLevel makes purchase orders worth at least MinAmount, in the reporting currency, need the
+// approval of a user with Role. An order needs every level its amount reaches.
+type PurchaseApprovalLevel struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	Level     int             `gorm:"uniqueIndex" json:"level"`
+	MinAmount decimal.Decimal `gorm:"type:numeric(18,2);not null;default:0" json:"min_amount"`
+	Role      string          `gorm:"size:50" json:"role"`
+	CreatedAt int64           `json:"created_at"`
+}
+
+// CanApprove reports whether a user with role may decide a level of approval needing levelRole, admins
+// may decide any
+func CanApprove(role, levelRole string) bool {
+	return role == levelRole || role == RoleAdmin
+}
+
+// PurchaseOrderRequest creates or replaces a draft purchase order, in the currency of the vendor unless
+// one is given
+type PurchaseOrderRequest struct {
+	VendorID        uint                       `json:"vendor_id" binding:"required"`
+	BudgetID        uint                       `json:"budget_id"`
+	Currency        string                     `json:"currency" binding:"omitempty,len=3"`
+	DeliveryDate    string                     `json:"delivery_date"`
+	DeliveryAddress string                     `json:"delivery_address"`
+	Notes           string                     `json:"notes"`
+	Lines           []PurchaseOrderLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+// PurchaseOrderLineRequest is an item ordered, Quantity defaults to 1
+type PurchaseOrderLineRequest struct {
+	Description string          `json:"description" binding:"required"`
+	Quantity    decimal.Decimal `json:"quantity"`
+	UnitPrice   decimal.Decimal `json:"unit_price"`
+}
+
+// PurchaseApprovalLevelRequest is a level of approval, levels are numbered in the order of their amounts
+type PurchaseApprovalLevelRequest struct {
+	MinAmount decimal.Decimal `json:"min_amount"`
+	Role      string          `json:"role" binding:"required"`
+}
+
+// PurchaseDecisionRequest approves or rejects a level of approval of a purchase order, a rejection needs a note
+type PurchaseDecisionRequest struct {
+	Note string `json:"note"`
+}
+
+type PurchaseOrderCloseRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// PurchaseOrderFilter narrows down the purchase orders listed
+type PurchaseOrderFilter struct {
+	Status   PurchaseOrderStatus
+	VendorID uint
+	BudgetID uint
+	Page     int
+	Limit    int
+}
+
+// GoodsReceipt is a goods received note, what was delivered against a purchase order on a day. Deliveries
+// can be partial, a purchase order takes as many receipts as it needs.
+type GoodsReceipt struct {
+	ID              uint               `gorm:"primaryKey" json:"id"`
+	Number          string             `gorm:"size:50;uniqueIndex" json:"number"` // e.g. GRN-2025-0042
+	PurchaseOrderID uint               `gorm:"index" json:"purchase_order_id"`
+	ReceivedOn      time.Time          `gorm:"type:date" json:"received_on"`
+	DeliveryNote    string             `gorm:"size:100" json:"delivery_note,omitempty"` // the vendor's delivery note number
+	Notes           string             `gorm:"type:text" json:"notes,omitempty"`
+	ReceivedBy      uint               `json:"received_by"`
+	CreatedAt       int64              `json:"created_at"`
+	Lines           []GoodsReceiptLine `gorm:"foreignKey:GoodsReceiptID" json:"lines"`
+}
+
+type GoodsReceiptLine struct {
+	ID                  uint            `gorm:"primaryKey" json:"id"`
+	GoodsReceiptID      uint            `gorm:"index" json:"-"`
+	PurchaseOrderLineID uint            `gorm:"index" json:"purchase_order_line_id"`
+	Quantity            decimal.Decimal `gorm:"type:numeric(18,4)" json:"quantity"`
+}
+
+// GoodsReceiptRequest receives goods on a purchase order, on the day it is made unless a date is given
+type GoodsReceiptRequest struct {
+	ReceivedOn   string                    `json:"received_on"`
+	DeliveryNote string                    `json:"delivery_note"`
+	Notes        string                    `json:"notes"`
+	Lines        []GoodsReceiptLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+type GoodsReceiptLineRequest struct {
+	PurchaseOrderLineID uint            `json:"purchase_order_line_id" binding:"required"`
+	Quantity            decimal.Decimal `json:"quantity"`
+}
+
+type VendorBillStatus string
+
+const (
+	BillMatched   VendorBillStatus = "matched"   // agrees with the order and what was received, it can be paid
+	BillException VendorBillStatus = "exception" // does not, it is held for an admin to approve or reject
+	BillApproved  VendorBillStatus = "approved"  // an exception an admin accepted
+	BillRejected  VendorBillStatus = "rejected"
+)
+
+// Counts reports whether a bill in status s counts towards what was billed on its purchase order
+func (s VendorBillStatus) Counts() bool {
+	return s == BillMatched || s == BillApproved
+}
+
+// VendorBill is an invoice of a vendor against a purchase order. It is matched line by line against the
+// order and the goods received: its prices cannot be above the ordered ones, nor its quantities above what was
+// received and not billed yet, by more than a tolerance. Amounts are in the currency of the order.
+type VendorBill struct {
+	Model
+	PurchaseOrderID uint             `gorm:"index" json:"purchase_order_id"`
+	VendorID        uint             `gorm:"uniqueIndex:idx_vendor_bills_number,where:status <> 'rejected'" json:"vendor_id"`
+	Number          string           `gorm:"size:100;uniqueIndex:idx_vendor_bills_number" json:"number"` // the vendor's invoice number
+	BillDate        time.Time        `gorm:"type:date" json:"bill_date"`
+	DueDate         *time.Time       `gorm:"type:date;index" json:"due_date"`
+	Currency        string           `gorm:"size:3" json:"currency"`
+	Subtotal        decimal.Decimal  `gorm:"type:numeric(18,2);not null;default:0" json:"subtotal"`
+	TaxTotal        decimal.Decimal  `gorm:"type:numeric(18,2);not null;default:0" json:"tax_total"`
+	Total           decimal.Decimal  `gorm:"type:numeric(18,2);not null;default:0" json:"total"`
+	Status          VendorBillStatus `gorm:"size:20;index" json:"status"`
+	ReviewedBy      *uint            `json:"reviewed_by"`
+	ReviewedAt      *time.Time       `json:"reviewed_at"`
+	ReviewNote      string           `gorm:"size:500" json:"review_note,omitempty"`
+	CreatedBy       uint             `json:"created_by"`
+	Lines           []VendorBillLine `gorm:"foreignKey:VendorBillID" json:"lines"`
+}
+
+// VendorBillLine bills a line of the purchase order, Issue says why it did not match
+type VendorBillLine struct {
+	ID                  uint            `gorm:"primaryKey" json:"id"`
+	VendorBillID        uint            `gorm:"index" json:"-"`
+	PurchaseOrderLineID uint            `gorm:"index" json:"purchase_order_line_id"`
+	Quantity            decimal.Decimal `gorm:"type:numeric(18,4)" json:"quantity"`
+	UnitPrice           decimal.Decimal `gorm:"type:numeric(18,2)" json:"unit_price"`
+	Amount              decimal.Decimal `gorm:"type:numeric(18,2)" json:"amount"`
+	Issue               string          `gorm:"size:500" json:"issue,omitempty"`
+}
+
+// MatchTolerance is how far a vendor bill may go beyond its purchase order and still match, in percent of
+// the ordered unit price and of the quantity received
+type MatchTolerance struct {
+	Price    decimal.Decimal
+	Quantity decimal.Decimal
+}
+
+// Match matches the lines of a bill against the lines of its purchase order, noting the issue of every line
+// that does not agree, and sets the status of the bill from them
+func (b *VendorBill) Match(lines []PurchaseOrderLine, tolerance MatchTolerance) {
+	hundred := decimal.NewFromInt(100)
+	ordered := map[uint]*PurchaseOrderLine{}
+	for i := range lines {
+		ordered[lines[i].ID] = &lines[i]
+	}
+	billed := map[uint]decimal.Decimal{}
+
+	b.Status = BillMatched
+	for i := range b.Lines {
+		line := &b.Lines[i]
+		order, ok := ordered[line.PurchaseOrderLineID]
+		if !ok {
+			line.Issue = "not a line of the purchase order"
+			b.Status = BillException
+			continue
+		}
+		var issues []string
+		if limit := order.UnitPrice.Mul(hundred.Add(tolerance.Price)).Div(hundred); line.UnitPrice.GreaterThan(limit) {
+			issues = append(issues, fmt.Sprintf("unit price %s is above the ordered %s", line.UnitPrice, order.UnitPrice))
+		}
+		billed[order.ID] = billed[order.ID].Add(line.Quantity)
+		unbilled := order.QuantityReceived.Sub(order.QuantityBilled)
+		if limit := order.QuantityReceived.Mul(tolerance.Quantity).Div(hundred).Add(unbilled); billed[order.ID].GreaterThan(limit) {
+			issues = append(issues, fmt.Sprintf("quantity %s is more than the %s received and not billed yet", billed[order.ID], unbilled))
+		}
+		line.Issue = strings.Join(issues, "; ")
+		if line.Issue != "" {
+			b.Status = BillException
+		}
+	}
+}
+
+// VendorBillRequest records a bill of the vendor of a purchase order. Without a due date the bill is due
+// after the payment days of the vendor.
+type VendorBillRequest struct {
+	Number   string                  `json:"number" binding:"required"`
+	BillDate string                  `json:"bill_date" binding:"required"`
+	DueDate  string                  `json:"due_date"`
+	TaxTotal decimal.Decimal         `json:"tax_total"`
+	Lines    []VendorBillLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+type VendorBillLineRequest struct {
+	PurchaseOrderLineID uint            `json:"purchase_order_line_id" binding:"required"`
+	Quantity            decimal.Decimal `json:"quantity"`
+	UnitPrice           decimal.Decimal `json:"unit_price"`
+}
+
+// VendorBillReviewRequest approves or rejects a bill held as an exception
+type VendorBillReviewRequest struct {
+	Note string `json:"note"`
+}
@@ -0,0 +1,260 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type MilestoneKind string
+
+const (
+	MilestoneTrailerDrop MilestoneKind = "trailer_drop"
+	MilestonePremiere    MilestoneKind = "premiere"
+	MilestoneTheatrical  MilestoneKind = "theatrical"
+	MilestoneStreaming   MilestoneKind = "streaming"
+	MilestoneOther       MilestoneKind = "other"
+)
+
+// IsValid reports whether k is a known milestone kind
+func (k MilestoneKind) IsValid() bool {
+	switch k {
+	case MilestoneTrailerDrop, MilestonePremiere, MilestoneTheatrical, MilestoneStreaming, MilestoneOther:
+		return true
+	}
+	return false
+}
+
+// Label is how the milestone reads on a calendar
+func (k MilestoneKind) Label() string {
+	switch k {
+	case MilestoneTrailerDrop:
+		return "Trailer drop"
+	case MilestonePremiere:
+		return "Premiere"
+	case MilestoneTheatrical:
+		return "Theatrical release"
+	case MilestoneStreaming:
+		return "Streaming release"
+	}
+	return "Milestone"
+}
+
+// ReleasePlan schedules the release of a title, a title can have a plan per territory. Team is the team
+// in charge of it, e.g. "marketing", whose calendar feeds show its milestones.
+type ReleasePlan struct {
+	Model
+	TitleType  TitleType          `gorm:"size:20;index:idx_release_plans_title" json:"title_type"`
+	TitleID    uint               `gorm:"index:idx_release_plans_title" json:"title_id"`
+	Name       string             `gorm:"size:255" json:"name"`
+	Territory  string             `gorm:"size:2" json:"territory,omitempty"`
+	Team       string             `gorm:"size:50;index" json:"team,omitempty"`
+	OwnerID    uint               `gorm:"index" json:"owner_id"`
+	Notes      string             `gorm:"type:text" json:"notes,omitempty"`
+	Milestones []ReleaseMilestone `gorm:"foreignKey:PlanID" json:"milestones"`
+}
+
+// ReleaseMilestone is a dated step of a release plan, it lasts the whole day
+type ReleaseMilestone struct {
+	ID     uint          `gorm:"primaryKey" json:"id"`
+	PlanID uint          `gorm:"index" json:"plan_id"`
+	Plan   *ReleasePlan  `gorm:"foreignKey:PlanID" json:"-"`
+	Kind   MilestoneKind `gorm:"size:20" json:"kind"`
+	Date   time.Time     `gorm:"type:date;index" json:"date"`
+	Label  string        `gorm:"size:255" json:"label,omitempty"`
+	Notes  string        `gorm:"type:text" json:"notes,omitempty"`
+	Done   bool          `gorm:"not null;default:false" json:"done"`
+}
+
+type ReleasePlanRequest struct {
+	Name       string                    `json:"name" binding:"required"`
+	Territory  string                    `json:"territory"`
+	Team       string                    `json:"team"`
+	OwnerID    uint                      `json:"owner_id"`
+	Notes      string                    `json:"notes"`
+	Milestones []ReleaseMilestoneRequest `json:"milestones"`
+}
+
+type ReleaseMilestoneRequest struct {
+	Kind  MilestoneKind `json:"kind" binding:"required"`
+	Date  string        `json:"date" binding:"required"`
+	Label string        `json:"label"`
+	Notes string        `json:"notes"`
+	Done  bool          `json:"done"`
+}
+
+// Festival is an edition of a film festival titles can be submitted to
+type Festival struct {
+	Model
+	Name               string          `gorm:"size:255;uniqueIndex:idx_festivals_edition" json:"name"`
+	Edition            int             `gorm:"uniqueIndex:idx_festivals_edition" json:"edition"` // the year
+	City               string          `gorm:"size:100" json:"city,omitempty"`
+	Country            string          `gorm:"size:2" json:"country,omitempty"`
+	Website            string          `gorm:"size:255" json:"website,omitempty"`
+	StartsOn           time.Time       `gorm:"type:date" json:"starts_on"`
+	EndsOn             time.Time       `gorm:"type:date" json:"ends_on"`
+	SubmissionDeadline time.Time       `gorm:"type:date" json:"submission_deadline"`
+	LateDeadline       *time.Time      `gorm:"type:date" json:"late_deadline"`
+	Currency           string          `gorm:"size:3" json:"currency,omitempty"`
+	Fee                decimal.Decimal `gorm:"type:numeric(18,2);not null;default:0" json:"fee"`
+	LateFee            decimal.Decimal `gorm:"type:numeric(18,2);not null;default:0" json:"late_fee"`
+}
+
+type FestivalRequest struct {
+	Name               string          `json:"name" binding:"required"`
+	Edition            int             `json:"edition" binding:"required"`
+	City               string          `json:"city"`
+	Country            string          `json:"country"`
+	Website            string          `json:"website"`
+	StartsOn           string          `json:"starts_on" binding:"required"`
+	EndsOn             string          `json:"ends_on" binding:"required"`
+	SubmissionDeadline string          `json:"submission_deadline" binding:"required"`
+	LateDeadline       string          `json:"late_deadline"`
+	Currency           string          `json:"currency"`
+	Fee                decimal.Decimal `json:"fee"`
+	LateFee            decimal.Decimal `json:"late_fee"`
+}
+
+type SubmissionStatus string
+
+const (
+	SubmissionPlanned   SubmissionStatus = "planned"
+	SubmissionSubmitted SubmissionStatus = "submitted"
+	SubmissionAccepted  SubmissionStatus = "accepted"
+	SubmissionRejected  SubmissionStatus = "rejected"
+	SubmissionWithdrawn SubmissionStatus = "withdrawn"
+)
+
+// IsValid reports whether s is a known submission status
+func (s SubmissionStatus) IsValid() bool {
+	switch s {
+	case SubmissionPlanned, SubmissionSubmitted, SubmissionAccepted, SubmissionRejected, SubmissionWithdrawn:
+		return true
+	}
+	return false
+}
+
+// CanBecome reports whether a submission in status s can be moved to next. A submission is sent and then
+// accepted or rejected by the festival, it can be withdrawn until the festival decided.
+func (s SubmissionStatus) CanBecome(next SubmissionStatus) bool {
+	switch s {
+	case SubmissionPlanned:
+		return next == SubmissionSubmitted || next == SubmissionWithdrawn
+	case SubmissionSubmitted:
+		return next == SubmissionAccepted || next == SubmissionRejected || next == SubmissionWithdrawn
+	}
+	return false
+}
+
+type FestivalOutcome string
+
+const (
+	OutcomeSelected  FestivalOutcome = "selected"
+	OutcomeNominated FestivalOutcome = "nominated"
+	OutcomeAwarded   FestivalOutcome = "awarded"
+)
+
+// IsValid reports whether o is a known outcome
+func (o FestivalOutcome) IsValid() bool {
+	switch o {
+	case OutcomeSelected, OutcomeNominated, OutcomeAwarded:
+		return true
+	}
+	return false
+}
+
+// FestivalSubmission is a title entered, or about to be, in a category of a festival
+type FestivalSubmission struct {
+	Model
+	FestivalID  uint             `gorm:"uniqueIndex:idx_festival_submissions_entry" json:"festival_id"`
+	Festival    *Festival        `gorm:"foreignKey:FestivalID" json:"festival,omitempty"`
+	TitleType   TitleType        `gorm:"size:20;uniqueIndex:idx_festival_submissions_entry;index:idx_festival_submissions_title" json:"title_type"`
+	TitleID     uint             `gorm:"uniqueIndex:idx_festival_submissions_entry;index:idx_festival_submissions_title" json:"title_id"`
+	Category    string           `gorm:"size:100;uniqueIndex:idx_festival_submissions_entry" json:"category,omitempty"`
+	Status      SubmissionStatus `gorm:"size:20;index" json:"status"`
+	SubmittedOn *time.Time       `gorm:"type:date" json:"submitted_on"`
+	FeePaid     decimal.Decimal  `gorm:"type:numeric(18,2);not null;default:0" json:"fee_paid"`
+	Outcome     FestivalOutcome  `gorm:"size:20" json:"outcome,omitempty"`
+	Award       string           `gorm:"size:255" json:"award,omitempty"`
+	Team        string           `gorm:"size:50;index" json:"team,omitempty"`
+	OwnerID     uint             `gorm:"index" json:"owner_id"`
+	Notes       string           `gorm:"type:text" json:"notes,omitempty"`
+}
+
+type FestivalSubmissionRequest struct {
+	FestivalID uint      `json:"festival_id" binding:"required"`
+	TitleType  TitleType `json:"title_type" binding:"required"`
+	TitleID    uint      `json:"title_id" binding:"required"`
+	Category   string    `json:"category"`
+	Team       string    `json:"team"`
+	OwnerID    uint      `json:"owner_id"`
+	Notes      string    `json:"notes"`
+}
+
+// SubmissionStatusRequest moves a submission along, the outcome and award only go with accepted ones
+type SubmissionStatusRequest struct {
+	Status      SubmissionStatus `json:"status" binding:"required"`
+	SubmittedOn string           `json:"submitted_on"`
+	FeePaid     decimal.Decimal  `json:"fee_paid"`
+	Outcome     FestivalOutcome  `json:"outcome"`
+	Award       string           `json:"award"`
+}
+
+// SubmissionFilter narrows down the festival submissions listed
+type SubmissionFilter struct {
+	FestivalID uint
+	TitleType  TitleType
+	TitleID    uint
+	Status     SubmissionStatus
+	Page       int
+	Limit      int
+}
+
+type CalendarEventKind string
+
+const (
+	EventMilestone        CalendarEventKind = "milestone"
+	EventFestivalDeadline CalendarEventKind = "festival_deadline"
+	EventFestival         CalendarEventKind = "festival"
+)
+
+// CalendarEvent is an entry of the release calendar. Events last whole days, from Date to EndDate included.
+type CalendarEvent struct {
+	UID       string            `json:"uid"`
+	Kind      CalendarEventKind `json:"kind"`
+	SourceID  uint              `json:"source_id"` // the milestone or festival submission
+	Date      time.Time         `json:"date"`
+	EndDate   time.Time         `json:"end_date"`
+	Summary   string            `json:"summary"`
+	Notes     string            `json:"notes,omitempty"`
+	TitleType TitleType         `json:"title_type"`
+	TitleID   uint              `json:"title_id"`
+	Team      string            `json:"team,omitempty"`
+	OwnerID   uint              `json:"owner_id"`
+}
+
+// CalendarFilter narrows down the calendar to a date range and, optionally, an owner, a team or a title
+type CalendarFilter struct {
+	From      time.Time
+	To        time.Time
+	OwnerID   uint
+	Team      string
+	TitleType TitleType
+	TitleID   uint
+}
+
+// CalendarFeed is a secret iCalendar feed URL of a user's or a team's schedule, for calendar apps that
+// cannot send our bearer tokens. Revoking a feed is deleting it.
+type CalendarFeed struct {
+	Model
+	Token  string `gorm:"size:64;uniqueIndex" json:"-"`
+	UserID uint   `gorm:"index" json:"user_id"`
+	Name   string `gorm:"size:255" json:"name"`
+	Team   string `gorm:"size:50" json:"team,omitempty"` // empty for the feed of the user's own items
+	URL    string `gorm:"-" json:"url,omitempty"`
+}
+
+type CalendarFeedRequest struct {
+	Name string `json:"name"`
+	Team string `json:"team"`
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+type RevisionStatus string
+
+const (
+	RevisionApplied  RevisionStatus = "applied"
+	RevisionPending  RevisionStatus = "pending" // a revert of an approved title waiting for an admin
+	RevisionRejected RevisionStatus = "rejected"
+)
+
+// TitleMetadata is the editable metadata of a title. Full-length films have no log line nor production year.
+type TitleMetadata struct {
+	Title       string `json:"title"`
+	LogLine     string `json:"log_line,omitempty"`
+	Description string `json:"description"`
+	Duration    int    `json:"duration"`
+	ProductYear string `json:"product_year,omitempty"`
+}
+
+// TitleRecord is the editable view of a title, Version is what If-Match has to name to edit it
+type TitleRecord struct {
+	TitleType TitleType `json:"title_type"`
+	ID        uint      `json:"id"`
+	TitleMetadata
+	Status  MovieStatus `json:"status"`
+	Version int         `json:"version"`
+}
+
+// Columns returns the metadata as the columns of the title's table
+func (m TitleMetadata) Columns(titleType TitleType) map[string]interface{} {
+	columns := map[string]interface{}{
+		"title":       m.Title,
+		"description": m.Description,
+		"duration":    m.Duration,
+	}
+	if titleType == TitleTrailer {
+		columns["log_line"] = m.LogLine
+		columns["product_year"] = m.ProductYear
+	}
+	return columns
+}
+
+// FieldChange is the old and new value of one metadata field
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// DiffMetadata lists the fields whose value differs between from and to
+func DiffMetadata(from, to TitleMetadata) []FieldChange {
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"title", from.Title, to.Title},
+		{"log_line", from.LogLine, to.LogLine},
+		{"description", from.Description, to.Description},
+		{"duration", strconv.Itoa(from.Duration), strconv.Itoa(to.Duration)},
+		{"product_year", from.ProductYear, to.ProductYear},
+	}
+
+	changes := []FieldChange{}
+	for _, field := range fields {
+		if field.from != field.to {
+			changes = append(changes, FieldChange{Field: field.name, From: field.from, To: field.to})
+		}
+	}
+	return changes
+}
+
+// Revision is the metadata of a title after one edit. Revision 1 holds the metadata as it was
+// before the first recorded edit, its author is unknown.
+type Revision struct {
+	Model
+	TitleType   TitleType      `gorm:"size:20;uniqueIndex:idx_revisions_number" json:"title_type"`
+	TitleID     uint           `gorm:"uniqueIndex:idx_revisions_number" json:"title_id"`
+	Number      int            `gorm:"uniqueIndex:idx_revisions_number" json:"number"`
+	AuthorID    uint           `json:"author_id"`
+	Metadata    TitleMetadata  `gorm:"serializer:json;type:jsonb" json:"metadata"`
+	Changes     []FieldChange  `gorm:"serializer:json;type:jsonb" json:"changes"` // against the title as it was before
+	Status      RevisionStatus `gorm:"size:20" json:"status"`
+	RevertOf    int            `json:"revert_of,omitempty"` // number of the revision reverted to
+	BaseVersion int            `json:"base_version"`        // version of the title the revision was made on
+	ReviewedBy  *uint          `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time     `json:"reviewed_at,omitempty"`
+}
+
+// TitleUpdateRequest edits the metadata of a title, fields left out keep their value
+type TitleUpdateRequest struct {
+	Title       *string `json:"title"`
+	LogLine     *string `json:"log_line"`
+	Description *string `json:"description"`
+	Duration    *int    `json:"duration"`
+	ProductYear *string `json:"product_year"`
+}
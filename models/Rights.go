@@ -0,0 +1,127 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// MediaRight is a way of exhibiting a title that can be licensed
+type MediaRight string
+
+const (
+	RightTheatrical MediaRight = "theatrical"
+	RightSVOD       MediaRight = "svod"
+	RightTV         MediaRight = "tv"
+	RightAirline    MediaRight = "airline"
+)
+
+// IsValid reports whether m is a known media right
+func (m MediaRight) IsValid() bool {
+	switch m {
+	case RightTheatrical, RightSVOD, RightTV, RightAirline:
+		return true
+	}
+	return false
+}
+
+// isoCountries are the officially assigned ISO 3166-1 alpha-2 country codes
+const isoCountries = "AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ BR BS BT BV BW BY BZ " +
+	"CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ DE DJ DK DM DO DZ EC EE EG EH ER ES ET FI FJ FK FM FO FR " +
+	"GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY HK HM HN HR HT HU ID IE IL IM IN IO IQ IR IS IT JE JM JO JP " +
+	"KE KG KH KI KM KN KP KR KW KY KZ LA LB LC LI LK LR LS LT LU LV LY MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR MS MT " +
+	"MU MV MW MX MY MZ NA NC NE NF NG NI NL NO NP NR NU NZ OM PA PE PF PG PH PK PL PM PN PR PS PT PW PY QA RE RO RS RU RW " +
+	"SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS ST SV SX SY SZ TC TD TF TG TH TJ TK TL TM TN TO TR TT TV TW TZ UA UG " +
+	"UM US UY UZ VA VC VE VG VI VN VU WF WS YE YT ZA ZM ZW"
+
+// IsCountryCode reports whether code is an ISO 3166-1 alpha-2 country code such as "NG"
+func IsCountryCode(code string) bool {
+	return len(code) == 2 && strings.Contains(isoCountries, code)
+}
+
+// DateLayout is how dates are written in requests and responses
+const DateLayout = "2006-01-02"
+
+// Licensee is a distributor, broadcaster or platform titles are licensed to
+type Licensee struct {
+	Model
+	Name        string `gorm:"size:255;uniqueIndex" json:"name"`
+	ContactName string `gorm:"size:255" json:"contact_name,omitempty"`
+	Email       string `gorm:"size:255" json:"email,omitempty"`
+	Telephone   string `gorm:"size:50" json:"telephone,omitempty"`
+}
+
+type LicenseeRequest struct {
+	Name        string `json:"name" binding:"required"`
+	ContactName string `json:"contact_name"`
+	Email       string `json:"email" binding:"omitempty,email"`
+	Telephone   string `json:"telephone"`
+}
+
+// RightsGrant licenses a title to a licensee in some territories and media from StartDate to EndDate,
+// both included. A grant without an end date runs for good.
+type RightsGrant struct {
+	Model
+	TitleType  TitleType      `gorm:"size:20;index:idx_rights_grants_title" json:"title_type"`
+	TitleID    uint           `gorm:"index:idx_rights_grants_title" json:"title_id"`
+	LicenseeID uint           `gorm:"index" json:"licensee_id"`
+	Licensee   *Licensee      `gorm:"foreignKey:LicenseeID" json:"licensee,omitempty"`
+	Exclusive  bool           `json:"exclusive"`
+	StartDate  time.Time      `gorm:"type:date" json:"start_date"`
+	EndDate    *time.Time     `gorm:"type:date;index" json:"end_date"`
+	Notes      string         `gorm:"type:text" json:"notes,omitempty"`
+	Windows    []RightsWindow `gorm:"foreignKey:GrantID" json:"windows"`
+	// When the rights team and the licensee were told the grant is about to end
+	ExpiryAlertedAt   *time.Time `json:"expiry_alerted_at,omitempty"`
+	LicenseeAlertedAt *time.Time `json:"licensee_alerted_at,omitempty"`
+}
+
+// RightsWindow is one territory and medium covered by a grant
+type RightsWindow struct {
+	ID        uint       `gorm:"primaryKey" json:"-"`
+	GrantID   uint       `gorm:"uniqueIndex:idx_rights_windows_grant" json:"-"`
+	Territory string     `gorm:"size:2;uniqueIndex:idx_rights_windows_grant;index:idx_rights_windows_lookup" json:"territory"`
+	Media     MediaRight `gorm:"size:20;uniqueIndex:idx_rights_windows_grant;index:idx_rights_windows_lookup" json:"media"`
+}
+
+// RightsGrantRequest grants every listed medium in every listed territory. Dates are written as 2006-01-02.
+type RightsGrantRequest struct {
+	LicenseeID  uint         `json:"licensee_id" binding:"required"`
+	Territories []string     `json:"territories" binding:"required"`
+	Media       []MediaRight `json:"media" binding:"required"`
+	Exclusive   bool         `json:"exclusive"`
+	StartDate   string       `json:"start_date" binding:"required"`
+	EndDate     string       `json:"end_date"`
+	Notes       string       `json:"notes"`
+}
+
+// RightsConflict is an existing grant standing in the way of a new one
+type RightsConflict struct {
+	GrantID      uint       `json:"grant_id"`
+	LicenseeID   uint       `json:"licensee_id"`
+	LicenseeName string     `json:"licensee_name"`
+	Territory    string     `json:"territory"`
+	Media        MediaRight `json:"media"`
+	Exclusive    bool       `json:"exclusive"`
+	StartDate    time.Time  `json:"start_date"`
+	EndDate      *time.Time `json:"end_date"`
+}
+
+// AvailabilityQuery asks which titles can be licensed in a territory for a medium between From and To
+type AvailabilityQuery struct {
+	Territory string
+	Media     MediaRight
+	From      time.Time
+	To        time.Time
+	Page      int
+	Limit     int
+}
+
+// TitleAvailability is a title free to license for an availability query. Titles already licensed
+// non-exclusively can only be licensed non-exclusively again.
+type TitleAvailability struct {
+	TitleType          TitleType   `json:"title_type"`
+	ID                 uint        `json:"id"`
+	Title              string      `json:"title"`
+	Status             MovieStatus `json:"status"`
+	ExclusiveAvailable bool        `json:"exclusive_available"`
+}
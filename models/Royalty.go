@@ -0,0 +1,97 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// RevenueReport is a revenue report a licensee sent in, ingested from CSV, covering PeriodStart to PeriodEnd.
+// Its revenue only counts towards the contracts of that licensee.
+type RevenueReport struct {
+	Model
+	LicenseeID  uint          `gorm:"index" json:"licensee_id"`
+	PeriodStart time.Time     `gorm:"type:date;index:idx_revenue_reports_period" json:"period_start"`
+	PeriodEnd   time.Time     `gorm:"type:date;index:idx_revenue_reports_period" json:"period_end"`
+	Source      string        `gorm:"size:255" json:"source,omitempty"`
+	Filename    string        `gorm:"size:255" json:"filename"`
+	UploadedBy  uint          `json:"uploaded_by"`
+	Lines       []RevenueLine `gorm:"foreignKey:ReportID" json:"lines,omitempty"`
+}
+
+// RevenueLine is the revenue a title made in a territory and medium over the period of its report
+type RevenueLine struct {
+	ID           uint            `gorm:"primaryKey" json:"id"`
+	ReportID     uint            `gorm:"index" json:"report_id"`
+	TitleType    TitleType       `gorm:"size:20;index:idx_revenue_lines_title" json:"title_type"`
+	TitleID      uint            `gorm:"index:idx_revenue_lines_title" json:"title_id"`
+	Territory    string          `gorm:"size:2" json:"territory,omitempty"`
+	Media        MediaRight      `gorm:"size:20" json:"media,omitempty"`
+	GrossRevenue decimal.Decimal `gorm:"type:numeric(18,2)" json:"gross_revenue"`
+	Currency     string          `gorm:"size:3" json:"currency"`
+}
+
+// RevenueReportColumns are the columns a revenue CSV has to start with, territory and media may be left empty
+var RevenueReportColumns = []string{"title_type", "title_id", "territory", "media", "gross_revenue", "currency"}
+
+// LineError is what is wrong with a line of an ingested file, lines count from 1 with the header
+type LineError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// RevenueReportRequest describes the CSV file of a revenue report
+type RevenueReportRequest struct {
+	LicenseeID  string
+	PeriodStart string
+	PeriodEnd   string
+	Source      string
+	Filename    string
+}
+
+// RoyaltyStatement is what a contract owes for a period. The revenue share builds up across statements
+// and only what exceeds the minimum guarantee is due.
+type RoyaltyStatement struct {
+	Model
+	ContractID          uint                   `gorm:"uniqueIndex:idx_royalty_statements_period" json:"contract_id"`
+	Contract            *Contract              `gorm:"foreignKey:ContractID" json:"contract,omitempty"`
+	PeriodStart         time.Time              `gorm:"type:date;uniqueIndex:idx_royalty_statements_period" json:"period_start"`
+	PeriodEnd           time.Time              `gorm:"type:date;uniqueIndex:idx_royalty_statements_period" json:"period_end"`
+	Currency            string                 `gorm:"size:3" json:"currency"`
+	GrossRevenue        decimal.Decimal        `gorm:"type:numeric(18,2)" json:"gross_revenue"`
+	RevenueSharePercent decimal.Decimal        `gorm:"type:numeric(7,4)" json:"revenue_share_percent"`
+	RevenueShare        decimal.Decimal        `gorm:"type:numeric(18,2)" json:"revenue_share"`
+	CumulativeShare     decimal.Decimal        `gorm:"type:numeric(18,2)" json:"cumulative_share"`
+	MinimumGuarantee    decimal.Decimal        `gorm:"type:numeric(18,2)" json:"minimum_guarantee"`
+	Recouped            decimal.Decimal        `gorm:"type:numeric(18,2)" json:"recouped"` // of the minimum guarantee, as of the end of the period
+	AmountDue           decimal.Decimal        `gorm:"type:numeric(18,2)" json:"amount_due"`
+	ConvertedLines      int                    `json:"converted_lines"` // revenue in another currency, converted at the end of its report
+	GeneratedBy         uint                   `json:"generated_by"`
+	Lines               []RoyaltyStatementLine `gorm:"foreignKey:StatementID" json:"lines"`
+}
+
+// RoyaltyStatementLine is the revenue and share of one title of a statement
+type RoyaltyStatementLine struct {
+	ID           uint            `gorm:"primaryKey" json:"-"`
+	StatementID  uint            `gorm:"index" json:"-"`
+	TitleType    TitleType       `gorm:"size:20" json:"title_type"`
+	TitleID      uint            `json:"title_id"`
+	Title        string          `gorm:"size:255" json:"title"`
+	GrossRevenue decimal.Decimal `gorm:"type:numeric(18,2)" json:"gross_revenue"`
+	RevenueShare decimal.Decimal `gorm:"type:numeric(18,2)" json:"revenue_share"`
+}
+
+// TitleRevenue is the revenue of a title over a period, in one currency
+type TitleRevenue struct {
+	TitleType    TitleType
+	TitleID      uint
+	Currency     string
+	ReportedOn   time.Time // end of the period of the reports summed
+	GrossRevenue decimal.Decimal
+	Lines        int
+}
+
+type StatementRequest struct {
+	PeriodStart string `json:"period_start" binding:"required"`
+	PeriodEnd   string `json:"period_end" binding:"required"`
+}
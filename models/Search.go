@@ -0,0 +1,61 @@
+package models
+
+// SearchQuery is a catalogue search. Status, Genre and Year narrow the titles down to one facet value.
+type SearchQuery struct {
+	Text   string
+	Status MovieStatus
+	Genre  string // genre slug
+	Year   string
+	Page   int
+	Limit  int
+}
+
+// TitleHit is a trailer or full-length film matching a search. The snippets wrap matched words in <mark> tags.
+type TitleHit struct {
+	TitleType    TitleType   `json:"title_type"`
+	ID           uint        `json:"id"`
+	Title        string      `json:"title"`
+	Status       MovieStatus `json:"status"`
+	Year         string      `json:"year"`
+	Rank         float64     `json:"rank"`
+	TitleSnippet string      `json:"title_snippet"`
+	Snippet      string      `json:"snippet"`
+}
+
+type PersonHit struct {
+	ID       uint    `json:"id"`
+	Name     string  `json:"name"`
+	Aliases  string  `json:"aliases"`
+	PhotoURL string  `json:"photo_url"`
+	Rank     float64 `json:"rank"`
+	Snippet  string  `json:"snippet"`
+}
+
+type TermHit struct {
+	ID   uint         `json:"id"`
+	Kind TaxonomyKind `json:"kind"`
+	Name string       `json:"name"`
+	Slug string       `json:"slug"`
+	Rank float64      `json:"rank"`
+}
+
+// FacetCount is the number of matching titles sharing a facet value
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count int64  `json:"count"`
+}
+
+type SearchFacets struct {
+	Status []FacetCount `json:"status"`
+	Genre  []FacetCount `json:"genre"`
+	Year   []FacetCount `json:"year"`
+}
+
+type SearchResult struct {
+	Titles []TitleHit   `json:"titles"`
+	Total  int64        `json:"total"`
+	People []PersonHit  `json:"people"`
+	Terms  []TermHit    `json:"terms"`
+	Facets SearchFacets `json:"facets"`
+}
@@ -0,0 +1,8 @@
+package models
+
+// Sequence hands out the numbers of a series of documents one after the other, e.g. the invoices of a year.
+// Last is the number handed out last.
+type Sequence struct {
+	Name string `gorm:"primaryKey;size:50"`
+	Last int    `gorm:"not null;default:0"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+// languageTag matches the BCP 47 tags we accept, a language optionally followed by a script or region, e.g. "en", "pt-BR" or "zh-Hant"
+var languageTag = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z]{4})?(-([A-Z]{2}|[0-9]{3}))?$`)
+
+// IsLanguageTag reports whether s is a language tag such as "fr" or "en-GB"
+func IsLanguageTag(s string) bool {
+	return languageTag.MatchString(s)
+}
+
+// LanguageFromFilename reads the language tag from subtitle file names such as "trailer.fr.srt", it returns "" when there is none
+func LanguageFromFilename(filename string) string {
+	parts := strings.Split(filename, ".")
+	if len(parts) < 3 {
+		return ""
+	}
+	if language := parts[len(parts)-2]; IsLanguageTag(language) {
+		return language
+	}
+	return ""
+}
+
+// Subtitle is the subtitle track of a title in one language, the file itself is a media asset
+type Subtitle struct {
+	Model
+	TitleType TitleType   `gorm:"size:20;uniqueIndex:idx_subtitles_title_language" json:"title_type"`
+	TitleID   uint        `gorm:"uniqueIndex:idx_subtitles_title_language" json:"title_id"`
+	Language  string      `gorm:"size:35;uniqueIndex:idx_subtitles_title_language" json:"language"`
+	Label     string      `gorm:"size:100" json:"label,omitempty"`
+	Format    string      `gorm:"size:10" json:"format"`
+	Cues      int         `json:"cues"`
+	AssetID   uint        `json:"asset_id"`
+	Asset     *MediaAsset `gorm:"foreignKey:AssetID" json:"asset,omitempty"`
+}
+
+// SubtitleShiftRequest moves every cue of a subtitle, a negative offset makes them show earlier
+type SubtitleShiftRequest struct {
+	Offset string `json:"offset" binding:"required"` // a Go duration such as "1.5s" or "-250ms"
+}
@@ -0,0 +1,18 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+// TaxRate is a tax charged on invoice lines, e.g. Nigerian VAT at 7.5%. Invoice lines keep the name and
+// percent they were billed with, so changing a rate leaves issued invoices as they are.
+type TaxRate struct {
+	Model
+	Name        string          `gorm:"size:100;uniqueIndex" json:"name"`
+	Percent     decimal.Decimal `gorm:"type:numeric(7,4);not null;default:0" json:"percent"`
+	Description string          `gorm:"size:255" json:"description,omitempty"`
+}
+
+type TaxRateRequest struct {
+	Name        string          `json:"name" binding:"required"`
+	Percent     decimal.Decimal `json:"percent"`
+	Description string          `json:"description"`
+}
@@ -0,0 +1,78 @@
+package models
+
+// TaxonomyKind is one of the lookup lists titles are categorised by
+type TaxonomyKind string
+
+const (
+	TaxonomyGenre         TaxonomyKind = "genre"
+	TaxonomySubGenre      TaxonomyKind = "sub_genre"
+	TaxonomyLanguage      TaxonomyKind = "language"
+	TaxonomyCountry       TaxonomyKind = "country"
+	TaxonomyContentRating TaxonomyKind = "content_rating"
+	TaxonomyTag           TaxonomyKind = "tag"
+)
+
+// TaxonomyKinds lists every kind, in the order they are shown
+var TaxonomyKinds = []TaxonomyKind{
+	TaxonomyGenre,
+	TaxonomySubGenre,
+	TaxonomyLanguage,
+	TaxonomyCountry,
+	TaxonomyContentRating,
+	TaxonomyTag,
+}
+
+// IsValid reports whether k is a known kind
+func (k TaxonomyKind) IsValid() bool {
+	for _, kind := range TaxonomyKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// TaxonomyTerm is an entry of one of the lookup lists, e.g. the genre "Drama" or the language "Yoruba"
+type TaxonomyTerm struct {
+	Model
+	Kind     TaxonomyKind `gorm:"size:20;uniqueIndex:idx_taxonomy_terms_slug" json:"kind"`
+	Name     string       `gorm:"size:255" json:"name"`
+	Slug     string       `gorm:"size:255;uniqueIndex:idx_taxonomy_terms_slug" json:"slug"`
+	Code     string       `gorm:"size:10" json:"code,omitempty"`    // ISO code of languages and countries
+	ParentID *uint        `gorm:"index" json:"parent_id,omitempty"` // the genre a sub-genre belongs to
+}
+
+// TitleTerm links a trailer or a full-length film to a taxonomy term
+type TitleTerm struct {
+	TitleType TitleType `gorm:"primaryKey;size:20" json:"title_type"`
+	TitleID   uint      `gorm:"primaryKey" json:"title_id"`
+	TermID    uint      `gorm:"primaryKey;index" json:"term_id"`
+}
+
+type TaxonomyTermRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Code     string `json:"code"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+type TitleTermsRequest struct {
+	TermIDs []uint `json:"term_ids"`
+}
+
+type MergeTermsRequest struct {
+	IntoID uint `json:"into_id" binding:"required"`
+}
+
+// TitleFilter narrows a catalogue listing down to titles carrying the given term slugs. Titles
+// have to match every kind that is set, and any one of the slugs given for a kind.
+type TitleFilter struct {
+	Terms map[TaxonomyKind][]string
+	Page  int
+	Limit int
+}
+
+// TitleSummary is a line of a catalogue listing
+type TitleSummary struct {
+	MovieBase
+	TitleType TitleType `json:"title_type"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// TrashUser is the trash type of user accounts, titles use their TitleType
+const TrashUser = "user"
+
+// TrashItem is a deleted record waiting to be restored or purged
+type TrashItem struct {
+	Type      string    `json:"type"`
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"` // the title, or the full name of a user
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
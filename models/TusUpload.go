@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// TusUpload keeps the state of a resumable upload between requests
+type TusUpload struct {
+	ID           string     `gorm:"primaryKey;size:36" json:"id"`
+	UserID       uint       `json:"user_id"`
+	Length       int64      `json:"length"`
+	Offset       int64      `json:"offset"`
+	Metadata     string     `gorm:"type:text" json:"metadata"` // raw Upload-Metadata header
+	Filename     string     `gorm:"size:255" json:"filename"`
+	ContentType  string     `gorm:"size:100" json:"content_type"`
+	Kind         MediaKind  `gorm:"size:20" json:"kind"`
+	TitleType    TitleType  `gorm:"size:20" json:"title_type"`
+	TitleID      uint       `json:"title_id"`
+	StorageKey   string     `gorm:"type:text" json:"storage_key"`
+	MultipartID  string     `gorm:"type:text" json:"-"`
+	PendingKey   string     `gorm:"type:text" json:"-"` // where the bytes held back are parked, a new key on every write
+	PendingSize  int64      `json:"-"`                  // bytes held back until they fill a whole part
+	HashState    []byte     `json:"-"`                  // SHA-256 of the bytes received so far, carried between requests
+	Completed    bool       `json:"completed"`
+	MediaAssetID *uint      `json:"media_asset_id"`
+	LeaseToken   string     `gorm:"size:36" json:"-"` // the request writing to the upload, while LeasedUntil has not passed
+	LeasedUntil  *time.Time `json:"-"`
+	ExpiresAt    time.Time  `gorm:"index" json:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// TusUploadPart is a part of a resumable upload already sent to storage
+type TusUploadPart struct {
+	ID             uint   `gorm:"primaryKey"`
+	UploadID       string `gorm:"size:36;index"`
+	PartNumber     int32
+	ETag           string `gorm:"size:255"`
+	ChecksumSHA256 string `gorm:"size:64"`
+	Size           int64
+}
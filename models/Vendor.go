@@ -0,0 +1,27 @@
+package models
+
+// Vendor is a company or person productions buy services and equipment from
+type Vendor struct {
+	Model
+	Name        string `gorm:"size:255;uniqueIndex" json:"name"`
+	Email       string `gorm:"size:255" json:"email,omitempty"`
+	Telephone   string `gorm:"size:50" json:"telephone,omitempty"`
+	TaxID       string `gorm:"size:50" json:"tax_id,omitempty"`
+	Address     string `gorm:"type:text" json:"address,omitempty"`
+	ContactName string `gorm:"size:255" json:"contact_name,omitempty"` // who to deal with at the vendor
+	Currency    string `gorm:"size:3" json:"currency,omitempty"`       // purchase orders are in it unless told otherwise
+	PaymentDays int    `json:"payment_days"`                           // bills are due this many days after they are dated
+	Archived    bool   `json:"archived"`                               // archived vendors take no new purchase orders
+}
+
+type VendorRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Email       string `json:"email" binding:"omitempty,email"`
+	Telephone   string `json:"telephone"`
+	TaxID       string `json:"tax_id"`
+	Address     string `json:"address"`
+	ContactName string `json:"contact_name"`
+	Currency    string `json:"currency" binding:"omitempty,len=3"`
+	PaymentDays int    `json:"payment_days"`
+	Archived    bool   `json:"archived"`
+}
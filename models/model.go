@@ -1,8 +1,14 @@
 package models
 
+import "gorm.io/gorm"
+
+// AnyVersion is the version a write is based on when it applies on top of whatever version is current,
+// as asked by If-Match: *
+const AnyVersion = 0
+
 type Model struct {
-	ID        uint  `gorm:"primaryKey"`
-	CreatedAt int64 `json:"created_at"`
-	UpdatedAt int64 `json:"updated_at"`
-	DeletedAt int64 `json:"deleted_at"`
+	ID        uint           `gorm:"primaryKey"`
+	CreatedAt int64          `json:"created_at"`
+	UpdatedAt int64          `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
@@ -1,21 +1,40 @@
 package models
 
+import "time"
+
 type Trailer struct {
     MovieBase
     TrailerID    uint   `gorm:"primaryKey" json:"trailer_id"`
     LogLine      string `gorm:"type:text"`
     ProductYear  string `gorm:"size:4"`
-    Star1        string `gorm:"type:text;not null"`
-    Star2        string `gorm:"type:text;not null"`
-    Star3        string `gorm:"type:text;not null"`
     VideoURLs    string `gorm:"type:text"` 
     PictureURLs  string `gorm:"type:text"` 
 	UserID       uint     `json:"user_id"`
 	User         User     `gorm:"foreignKey:UserID" json:"user"`
 }
 
+type UploadStatus string
+
+const (
+	UploadPending   UploadStatus = "pending"
+	UploadUploading UploadStatus = "uploading"
+	UploadCompleted UploadStatus = "completed"
+	UploadFailed    UploadStatus = "failed"
+)
+
+// UploadProgress is the byte level progress of an upload session
 type UploadProgress struct {
-	TotalFiles      int     `json:"total_files"`
-	UploadedFiles   int     `json:"uploaded_files"`
-	Percentage      float64 `json:"percentage"`
+	SessionID  string       `gorm:"primaryKey;size:36" json:"session_id"`
+	UserID     uint         `json:"user_id"`
+	BytesRead  int64        `json:"bytes_read"`
+	TotalBytes int64        `json:"total_bytes"`
+	Percentage float64      `json:"percentage"`
+	Status     UploadStatus `gorm:"size:20" json:"status"`
+	Error      string       `gorm:"type:text" json:"error,omitempty"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+// IsFinished reports whether the upload will not make any more progress
+func (p *UploadProgress) IsFinished() bool {
+	return p.Status == UploadCompleted || p.Status == UploadFailed
 }
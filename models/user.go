@@ -41,6 +41,7 @@ type User struct {
 	ThumbNailURL   string         `json:"thumbnail_url,omitempty"`
 	RoleID      uuid.UUID `gorm:"type:uuid" json:"role_id"`
 	Role        Role      `gorm:"foreignKey:RoleID" json:"role"`
+	Version     int       `gorm:"not null;default:1" json:"version"` // bumped on every profile edit
 }
 
 type Admin struct {
@@ -0,0 +1,119 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/techagentng/telair-erp/models"
+)
+
+// posterWidth is how wide the poster is printed on a fact sheet, in mm
+const posterWidth = 55
+
+// FactSheet writes the one page summary of a press kit. poster is the key art printed next to the details,
+// a JPEG or PNG file, nil leaves it out.
+func FactSheet(w io.Writer, kit *models.PressKit, poster []byte, posterType string) error {
+	d := newDocument(kit.Title)
+	top := d.GetY()
+	_, _, right, _ := d.GetMargins()
+	bottom := top
+
+	if imageType := gofpdfImageType(posterType); poster != nil && imageType != "" {
+		options := gofpdf.ImageOptions{ImageType: imageType, ReadDpi: true}
+		info := d.RegisterImageOptionsReader("poster", options, bytes.NewReader(poster))
+		if d.Ok() {
+			pageWidth, _ := d.GetPageSize()
+			d.ImageOptions("poster", pageWidth-right-posterWidth, top, posterWidth, 0, false, options, 0, "")
+			d.SetRightMargin(right + posterWidth + 6)
+			bottom = top + info.Height()*posterWidth/info.Width()
+		} else {
+			// a poster we cannot read is not worth failing the fact sheet for
+			d.ClearError()
+		}
+	}
+
+	if kit.Year != "" {
+		d.field("Year", kit.Year)
+	}
+	if kit.DurationMinutes > 0 {
+		d.field("Running time", fmt.Sprintf("%d min", kit.DurationMinutes))
+	}
+	for _, kind := range models.TaxonomyKinds {
+		if names := kit.Terms[string(kind)]; len(names) > 0 {
+			d.field(capitalize(strings.ReplaceAll(string(kind), "_", " ")), strings.Join(names, ", "))
+		}
+	}
+	for _, role := range []models.CreditRole{models.CreditDirector, models.CreditProducer} {
+		var names []string
+		for _, credit := range kit.Credits {
+			if credit.Role == role {
+				names = append(names, credit.Name)
+			}
+		}
+		if len(names) > 0 {
+			d.field(capitalize(string(role)), strings.Join(names, ", "))
+		}
+	}
+	if kit.LogLine != "" {
+		d.heading("Log line")
+		d.paragraph(kit.LogLine)
+	}
+	if kit.Synopsis != "" {
+		d.heading("Synopsis")
+		d.paragraph(kit.Synopsis)
+	}
+	// the rest goes full width, clear of the poster
+	d.SetRightMargin(right)
+	if d.GetY() < bottom {
+		d.SetY(bottom + 2)
+	}
+
+	if len(kit.Credits) > 0 {
+		d.heading("Cast and crew")
+		rows := make([][]string, 0, len(kit.Credits))
+		for _, credit := range kit.Credits {
+			rows = append(rows, []string{credit.Name, creditLabel(credit.Role), credit.CharacterName})
+		}
+		d.table([]string{"Name", "Role", "Character"}, []float64{70, 44, 60}, []string{"L", "L", "L"}, rows)
+	}
+	if len(kit.Trailers) > 0 {
+		d.heading("Trailers")
+		for _, trailer := range kit.Trailers {
+			d.SetFont("Helvetica", "", 10)
+			d.SetTextColor(0, 0, 180)
+			d.CellFormat(0, 6, d.text(trailer.Title), "", 1, "L", false, 0, trailer.URL)
+			d.SetTextColor(0, 0, 0)
+		}
+		d.SetFont("Helvetica", "I", 8)
+		d.CellFormat(0, 5, "Trailer links expire on "+kit.Trailers[0].ExpiresAt.Format(dateFormat)+".", "", 1, "L", false, 0, "")
+	}
+	return d.write(w)
+}
+
+func gofpdfImageType(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return "JPG"
+	case "image/png":
+		return "PNG"
+	}
+	return ""
+}
+
+func creditLabel(role models.CreditRole) string {
+	if role == models.CreditDP {
+		return "Director of photography"
+	}
+	return capitalize(string(role))
+}
+
+// capitalize upper cases the first letter of s
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,152 @@
+package pdf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/techagentng/telair-erp/models"
+)
+
+// Issuer is who invoices are from, as printed at their top
+type Issuer struct {
+	Name    string
+	Address string
+	TaxID   string
+}
+
+// Invoice writes an invoice, its Organization has to be loaded and its Contact when it has one
+func Invoice(w io.Writer, invoice *models.Invoice, issuer Issuer) error {
+	organization := invoice.Organization
+	if organization == nil {
+		return fmt.Errorf("invoice %d is missing its organization", invoice.ID)
+	}
+
+	title := "Invoice"
+	switch invoice.Status {
+	case models.InvoiceDraft:
+		title = "Draft invoice"
+	case models.InvoiceVoid:
+		title = "Invoice (void)"
+	}
+	d := newDocument(title)
+	if issuer.Name != "" {
+		d.SetFont("Helvetica", "B", 10)
+		d.CellFormat(0, 5, d.text(issuer.Name), "", 1, "L", false, 0, "")
+	}
+	if issuer.Address != "" {
+		d.SetFont("Helvetica", "", 9)
+		d.MultiCell(0, 4.5, d.text(issuer.Address), "", "L", false)
+	}
+	if issuer.TaxID != "" {
+		d.SetFont("Helvetica", "", 9)
+		d.CellFormat(0, 4.5, d.text("TIN: "+issuer.TaxID), "", 1, "L", false, 0, "")
+	}
+	d.Ln(4)
+
+	if invoice.Number != "" {
+		d.field("Invoice number", invoice.Number)
+	}
+	if invoice.IssueDate != nil {
+		d.field("Issue date", invoice.IssueDate.Format(dateFormat))
+	}
+	if invoice.DueDate != nil {
+		d.field("Due date", invoice.DueDate.Format(dateFormat))
+	}
+	d.field("Currency", invoice.Currency)
+
+	d.heading("Bill to")
+	d.paragraph(strings.Join(billTo(invoice), "\n"))
+	d.Ln(4)
+
+	rows := make([][]string, 0, len(invoice.Lines))
+	for _, line := range invoice.Lines {
+		discount := ""
+		if line.DiscountPercent.IsPositive() {
+			discount = line.DiscountPercent.String() + "%"
+		}
+		tax := ""
+		if line.TaxName != "" {
+			tax = fmt.Sprintf("%s %s%%", line.TaxName, line.TaxPercent.String())
+		}
+		rows = append(rows, []string{line.Description, line.Quantity.String(), line.UnitPrice.StringFixed(2), discount, tax, line.Amount.StringFixed(2)})
+	}
+	d.table([]string{"Description", "Qty", "Unit price", "Discount", "Tax", "Amount"}, []float64{64, 16, 26, 18, 22, 28}, []string{"L", "R", "R", "R", "L", "R"}, rows)
+	d.Ln(4)
+
+	d.total("Subtotal", invoice.Subtotal.StringFixed(2), false)
+	if invoice.DiscountTotal.IsPositive() {
+		d.total("Discounts", "-"+invoice.DiscountTotal.StringFixed(2), false)
+	}
+	for _, tax := range taxTotals(invoice.Lines) {
+		d.total(tax.label, tax.amount.StringFixed(2), false)
+	}
+	d.total("Total ("+invoice.Currency+")", invoice.Total.StringFixed(2), true)
+	if invoice.AmountPaid.IsPositive() {
+		d.total("Paid", invoice.AmountPaid.StringFixed(2), false)
+		d.total("Balance due ("+invoice.Currency+")", invoice.Balance().StringFixed(2), true)
+	}
+
+	if invoice.Status == models.InvoiceVoid && invoice.VoidReason != "" {
+		d.heading("Void")
+		d.paragraph(invoice.VoidReason)
+	}
+	if invoice.Notes != "" {
+		d.heading("Notes")
+		d.paragraph(invoice.Notes)
+	}
+	return d.write(w)
+}
+
+// billTo is the organization an invoice is for, its contact there, primary address and tax ID, one per line
+func billTo(invoice *models.Invoice) []string {
+	organization := invoice.Organization
+	lines := []string{organization.Name}
+	if invoice.Contact != nil {
+		lines = append(lines, "Attn: "+invoice.Contact.Name())
+	}
+	if len(organization.Addresses) > 0 {
+		address := organization.Addresses[0]
+		for _, candidate := range organization.Addresses {
+			if candidate.IsPrimary {
+				address = candidate
+				break
+			}
+		}
+		for _, line := range []string{address.Line1, address.Line2, strings.TrimSpace(address.City + " " + address.PostalCode), address.State, address.Country} {
+			if line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	if organization.TaxID != "" {
+		lines = append(lines, "TIN: "+organization.TaxID)
+	}
+	return lines
+}
+
+type taxTotal struct {
+	label  string
+	amount decimal.Decimal
+}
+
+// taxTotals adds up the tax of the lines by tax and percent, in the order the taxes first appear
+func taxTotals(lines []models.InvoiceLine) []taxTotal {
+	var totals []taxTotal
+	index := map[string]int{}
+	for _, line := range lines {
+		if line.TaxName == "" {
+			continue
+		}
+		label := fmt.Sprintf("%s at %s%%", line.TaxName, line.TaxPercent.String())
+		i, ok := index[label]
+		if !ok {
+			i = len(totals)
+			index[label] = i
+			totals = append(totals, taxTotal{label: label, amount: decimal.Zero})
+		}
+		totals[i].amount = totals[i].amount.Add(line.Tax)
+	}
+	return totals
+}
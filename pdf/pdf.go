@@ -0,0 +1,93 @@
+// Package pdf lays out the documents we send to partners, such as royalty statements and press kit fact sheets
+package pdf
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// document wraps gofpdf with the layout shared by our documents: an A4 page with a title block
+type document struct {
+	*gofpdf.Fpdf
+	// text converts UTF-8 to the code page of the core fonts
+	text func(string) string
+}
+
+func newDocument(title string) *document {
+	f := gofpdf.New("P", "mm", "A4", "")
+	f.SetTitle(title, true)
+	f.SetMargins(18, 18, 18)
+	f.SetAutoPageBreak(true, 18)
+	f.AliasNbPages("")
+	d := &document{Fpdf: f, text: f.UnicodeTranslatorFromDescriptor("")}
+	f.SetFooterFunc(func() {
+		f.SetY(-14)
+		f.SetFont("Helvetica", "", 8)
+		f.SetTextColor(120, 120, 120)
+		f.CellFormat(0, 5, fmt.Sprintf("Page %d of {nb}", f.PageNo()), "", 0, "C", false, 0, "")
+	})
+	f.AddPage()
+
+	f.SetFont("Helvetica", "B", 18)
+	f.CellFormat(0, 10, d.text(title), "", 1, "L", false, 0, "")
+	f.Ln(2)
+	return d
+}
+
+// field writes a label and its value on one line
+func (d *document) field(label, value string) {
+	d.SetFont("Helvetica", "B", 10)
+	d.CellFormat(45, 6, d.text(label), "", 0, "L", false, 0, "")
+	d.SetFont("Helvetica", "", 10)
+	d.CellFormat(0, 6, d.text(value), "", 1, "L", false, 0, "")
+}
+
+// heading starts a section of the document
+func (d *document) heading(text string) {
+	d.Ln(3)
+	d.SetFont("Helvetica", "B", 12)
+	d.CellFormat(0, 8, d.text(text), "", 1, "L", false, 0, "")
+}
+
+// paragraph writes text wrapped to the width of the page
+func (d *document) paragraph(text string) {
+	d.SetFont("Helvetica", "", 10)
+	d.MultiCell(0, 5, d.text(text), "", "L", false)
+}
+
+// table writes rows under a shaded header, columns are widths in mm and aligns one of L, C or R per column
+func (d *document) table(header []string, columns []float64, aligns []string, rows [][]string) {
+	d.SetFont("Helvetica", "B", 9)
+	d.SetFillColor(230, 230, 230)
+	for i, name := range header {
+		d.CellFormat(columns[i], 7, d.text(name), "1", 0, aligns[i], true, 0, "")
+	}
+	d.Ln(-1)
+
+	d.SetFont("Helvetica", "", 9)
+	for _, row := range rows {
+		for i, value := range row {
+			d.CellFormat(columns[i], 6, d.text(value), "1", 0, aligns[i], false, 0, "")
+		}
+		d.Ln(-1)
+	}
+}
+
+// total writes a right aligned label and amount, bold ones stand out as the bottom line
+func (d *document) total(label, amount string, bold bool) {
+	style := ""
+	if bold {
+		style = "B"
+	}
+	d.SetFont("Helvetica", style, 10)
+	width, _ := d.GetPageSize()
+	left, _, right, _ := d.GetMargins()
+	d.CellFormat(width-left-right-40, 6, d.text(label), "", 0, "R", false, 0, "")
+	d.CellFormat(40, 6, amount, "", 1, "R", false, 0, "")
+}
+
+func (d *document) write(w io.Writer) error {
+	return d.Output(w)
+}
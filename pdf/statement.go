@@ -0,0 +1,51 @@
+package pdf
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/techagentng/telair-erp/models"
+)
+
+const dateFormat = "2 Jan 2006"
+
+// RoyaltyStatement writes a royalty statement, its Contract and the contract's Licensee have to be loaded
+func RoyaltyStatement(w io.Writer, statement *models.RoyaltyStatement) error {
+	contract := statement.Contract
+	if contract == nil || contract.Licensee == nil {
+		return fmt.Errorf("statement %d is missing its contract", statement.ID)
+	}
+
+	d := newDocument("Royalty statement")
+	d.field("Contract", contract.Reference)
+	d.field("Licensee", contract.Licensee.Name)
+	d.field("Period", fmt.Sprintf("%s to %s", statement.PeriodStart.Format(dateFormat), statement.PeriodEnd.Format(dateFormat)))
+	d.field("Currency", statement.Currency)
+	d.field("Revenue share", statement.RevenueSharePercent.String()+"%")
+	d.field("Issued", time.Unix(statement.CreatedAt, 0).Format(dateFormat))
+	d.Ln(4)
+
+	rows := make([][]string, 0, len(statement.Lines))
+	for _, line := range statement.Lines {
+		rows = append(rows, []string{line.Title, string(line.TitleType), line.GrossRevenue.StringFixed(2), line.RevenueShare.StringFixed(2)})
+	}
+	d.table([]string{"Title", "Type", "Gross revenue", "Revenue share"}, []float64{84, 26, 32, 32}, []string{"L", "L", "R", "R"}, rows)
+	d.Ln(4)
+
+	d.total("Gross revenue", statement.GrossRevenue.StringFixed(2), false)
+	d.total("Revenue share for the period", statement.RevenueShare.StringFixed(2), false)
+	d.total("Revenue share to date", statement.CumulativeShare.StringFixed(2), false)
+	if statement.MinimumGuarantee.IsPositive() {
+		d.total("Minimum guarantee", statement.MinimumGuarantee.StringFixed(2), false)
+		d.total("Recouped against the guarantee", statement.Recouped.StringFixed(2), false)
+	}
+	d.total("Amount due ("+statement.Currency+")", statement.AmountDue.StringFixed(2), true)
+
+	if statement.ConvertedLines > 0 {
+		d.Ln(4)
+		d.SetFont("Helvetica", "I", 8)
+		d.MultiCell(0, 4, fmt.Sprintf("%d revenue lines reported in another currency were converted to %s at the rate on the last day of their report.", statement.ConvertedLines, statement.Currency), "", "L", false)
+	}
+	return d.write(w)
+}
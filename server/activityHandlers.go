@@ -0,0 +1,258 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+// defaultTaskDays is how far ahead the tasks of the signed in user are listed unless days is given
+const defaultTaskDays = 7
+
+// activitySubject reads the subject of activities from the path, its type and ID
+type activitySubject func(c *gin.Context) (string, uint, *errs.Error)
+
+// pathSubject reads subjects of one type by their ID, e.g. /contacts/7
+func pathSubject(subjectType string) activitySubject {
+	return func(c *gin.Context) (string, uint, *errs.Error) {
+		id, apiErr := paramID(c, "id")
+		return subjectType, id, apiErr
+	}
+}
+
+// titleSubject reads a title by its type and ID, e.g. /titles/trailer/42
+func titleSubject(c *gin.Context) (string, uint, *errs.Error) {
+	titleType := models.TitleType(c.Param("type"))
+	if !titleType.IsValid() {
+		return "", 0, errs.New("title type must be trailer or full_length", http.StatusBadRequest)
+	}
+	id, apiErr := paramID(c, "id")
+	return titleType.TableName(), id, apiErr
+}
+
+// isAdmin reports whether the signed in user is an admin
+func isAdmin(c *gin.Context) bool {
+	return c.GetString("user_role") == models.RoleAdmin
+}
+
+func (s *Server) handleCreateActivity(subject activitySubject) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		subjectType, subjectID, apiErr := subject(c)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.ActivityRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		activity, apiErr := s.ActivityService.CreateActivity(subjectType, subjectID, &request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Activity created successfully", http.StatusCreated, activity, nil)
+	}
+}
+
+// handleListActivities lists the activities of a record, e.g. GET /deals/3/activities?kind=call
+func (s *Server) handleListActivities(subject activitySubject) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subjectType, subjectID, apiErr := subject(c)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		page, limit := pagination(c)
+		filter := &models.ActivityFilter{
+			SubjectType: subjectType,
+			SubjectID:   subjectID,
+			Kind:        models.ActivityKind(c.Query("kind")),
+			Page:        page,
+			Limit:       limit,
+		}
+
+		activities, total, apiErr := s.ActivityService.ListActivities(filter)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Activities retrieved successfully", http.StatusOK, gin.H{
+			"activities": activities,
+			"total":      total,
+			"page":       page,
+			"limit":      limit,
+		}, nil)
+	}
+}
+
+// handleTimeline returns a page of what happened to a record, its activities merged with its history
+func (s *Server) handleTimeline(subject activitySubject) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subjectType, subjectID, apiErr := subject(c)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		page, limit := pagination(c)
+
+		entries, apiErr := s.ActivityService.Timeline(subjectType, subjectID, page, limit)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Timeline retrieved successfully", http.StatusOK, gin.H{
+			"entries": entries,
+			"page":    page,
+			"limit":   limit,
+		}, nil)
+	}
+}
+
+func (s *Server) handleGetActivity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		activity, apiErr := s.ActivityService.GetActivity(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Activity retrieved successfully", http.StatusOK, activity, nil)
+	}
+}
+
+func (s *Server) handleUpdateActivity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.ActivityRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		activity, apiErr := s.ActivityService.UpdateActivity(id, &request, userID, isAdmin(c))
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Activity updated successfully", http.StatusOK, activity, nil)
+	}
+}
+
+func (s *Server) handleDeleteActivity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.ActivityService.DeleteActivity(id, userID, isAdmin(c)); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Activity deleted successfully", http.StatusOK, nil, nil)
+	}
+}
+
+func (s *Server) handleCompleteTask() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		task, apiErr := s.ActivityService.CompleteTask(id, userID, isAdmin(c))
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Task completed successfully", http.StatusOK, task, nil)
+	}
+}
+
+func (s *Server) handleReopenTask() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		task, apiErr := s.ActivityService.ReopenTask(id, userID, isAdmin(c))
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Task reopened successfully", http.StatusOK, task, nil)
+	}
+}
+
+// handleMyTasks lists the open tasks of the signed in user that are overdue or due within days, e.g.
+// GET /profile/tasks?days=14
+func (s *Server) handleMyTasks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		days := defaultTaskDays
+		if value := c.Query("days"); value != "" {
+			var err error
+			if days, err = strconv.Atoi(value); err != nil {
+				apiErr := errs.New("days must be a number", http.StatusBadRequest)
+				response.JSON(c, "", apiErr.Status, nil, apiErr)
+				return
+			}
+		}
+
+		tasks, apiErr := s.ActivityService.MyTasks(userID, days)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Tasks retrieved successfully", http.StatusOK, tasks, nil)
+	}
+}
@@ -1,7 +1,6 @@
 package server
 
 import (
-	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/base64"
@@ -12,9 +11,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -22,71 +19,31 @@ import (
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/gin-gonic/gin"
 	"github.com/techagentng/telair-erp/errors"
 	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/media"
 	"github.com/techagentng/telair-erp/models"
 	"github.com/techagentng/telair-erp/server/response"
+	"github.com/techagentng/telair-erp/storage"
 	jwtPackage "github.com/techagentng/telair-erp/services/jwt"
 )
 
-func createS3Client() (*s3.Client, error) {
-    cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(os.Getenv("AWS_REGION")))
-    if err != nil {
-        return nil, fmt.Errorf("unable to load SDK config, %v", err)
-    }
-    return s3.NewFromConfig(cfg), nil
-}
-
-// A map to hold content types based on file extensions
-var contentTypes = map[string]string{
-	".jpg":  "image/jpeg",
-	".jpeg": "image/jpeg",
-	".png":  "image/png",
-	".mp4":  "video/mp4",
-	".avi":  "video/x-msvideo",
-	// Add more as needed
-}
-
-func uploadFileToS3(client *s3.Client, file multipart.File, bucketName, key string) (string, error) {
-    defer file.Close()
-
-    // Read the file content
-    fileContent, err := io.ReadAll(file)
-    if err != nil {
-        return "", fmt.Errorf("failed to read file content: %v", err)
-    }
-
-		// Determine file extension and content type
-		extension := filepath.Ext(key)
-		contentType, exists := contentTypes[extension]
-		if !exists {
-			contentType = "application/octet-stream" // Default content type
-		}
-		
-			// Remove whitespace from the file content
-	trimmedContent := strings.TrimSpace(string(fileContent))
-	fileContent = []byte(trimmedContent)
-	key = strings.ReplaceAll(key, " ", "_") 
-    // Upload the file to S3
-    _, err = client.PutObject(context.TODO(), &s3.PutObjectInput{
-        Bucket: aws.String(bucketName),
-        Key:    aws.String(key),
-        Body:   bytes.NewReader(fileContent),
-        ContentType: aws.String(contentType), 
-		ACL:    types.ObjectCannedACLPublicRead,
-    })
-    if err != nil {
-        return "", fmt.Errorf("failed to upload file to S3: %v", err)
-    }
-
-    // Return the S3 URL of the uploaded file
-    fileURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucketName, os.Getenv("AWS_REGION"), key)
-    return fileURL, nil
+// sniffImage identifies an uploaded image from its first bytes and rewinds the file
+func sniffImage(file multipart.File) (string, error) {
+	header := make([]byte, media.SniffLen)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", errors.New("unable to read image", http.StatusBadRequest)
+	}
+	contentType, err := media.Sniff(header[:n])
+	if err != nil || !media.IsImage(contentType) {
+		return "", errors.New("profile image must be a JPEG or PNG", http.StatusUnsupportedMediaType)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return contentType, nil
 }
 
 func (s *Server) handleUpdateUserImageUrl() gin.HandlerFunc {
@@ -123,20 +80,19 @@ func (s *Server) handleUpdateUserImageUrl() gin.HandlerFunc {
             return
         }
 
-        // Create S3 client
-        s3Client, err := createS3Client()
-        if err != nil {
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create S3 client"})
-            return
-        }
-
         userIDString := strconv.FormatUint(uint64(userID), 10)
 
         // Generate unique filename
-        filename := userIDString + "_" + fileHeader.Filename
+        filename := storage.NewKey("avatars/"+userIDString, fileHeader.Filename)
 
-        // Upload file to S3
-        _, err = uploadFileToS3(s3Client, file, "your-s3-bucket-name", filename)
+        // Stream the file to storage
+        defer file.Close()
+        contentType, err := sniffImage(file)
+        if err != nil {
+            c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+            return
+        }
+        _, err = s.Storage.Upload(c.Request.Context(), filename, file, storage.UploadOptions{ContentType: contentType, Public: true})
         if err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file to S3"})
             return
@@ -182,23 +138,22 @@ func (s *Server) handleSignup() gin.HandlerFunc {
         if err == nil {
             defer file.Close()
 
-            // Create S3 client
-            s3Client, err := createS3Client()
+            // Generate unique filename, the user does not have an ID yet
+            filename := storage.NewKey("avatars", handler.Filename)
+
+            contentType, err := sniffImage(file)
             if err != nil {
-                response.JSON(c, "", http.StatusInternalServerError, nil, err)
+                response.JSON(c, "", http.StatusUnsupportedMediaType, nil, err)
                 return
             }
 
-            // Generate unique filename
-            userID := c.PostForm("user_id") 
-            filename := fmt.Sprintf("%s_%s", userID, handler.Filename)
-
-            // Upload file to S3
-            filePath, err = uploadFileToS3(s3Client, file, os.Getenv("AWS_BUCKET"), filename)
+            // Stream the file to storage
+            object, err := s.Storage.Upload(c.Request.Context(), filename, file, storage.UploadOptions{ContentType: contentType, Public: true})
             if err != nil {
                 response.JSON(c, "", http.StatusInternalServerError, nil, err)
                 return
             }
+            filePath = object.URL
         } else if err == http.ErrMissingFile {
             filePath = "uploads/default-profile.png" // Adjust this to a default S3 URL if necessary
         } else {
@@ -378,7 +333,7 @@ type AuthPayload struct {
 
 // AuthRequest represents the authentication request structure.
 type AuthRequest struct {
-	email string `json:"email"`
+	Email string `json:"email"`
 }
 
 // AccessTokenDuration represents the default duration for access tokens.
@@ -487,7 +442,7 @@ func (s *Server) SocialAuthenticate(authRequest *AuthRequest, authPayloadOption
     }
 
     // Get email from authRequest
-    email := authRequest.email
+    email := authRequest.Email
 
     // Fetch the role from the repository based on userID
     userRole, err := s.AuthRepository.GetUserRoleByUserID(userIDUint)
@@ -647,6 +602,13 @@ func (s *Server) handleEditUserProfile() gin.HandlerFunc {
 			return
 		}
 
+		// The ETag of the profile the edit was made on
+		version, apiErr := ifMatch(c)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
 		// Parse request body into userDetails
 		var userDetails models.EditProfileResponse
 		if err := c.ShouldBindJSON(&userDetails); err != nil {
@@ -655,11 +617,17 @@ func (s *Server) handleEditUserProfile() gin.HandlerFunc {
 		}
 
 		// Call service method to update user details
-		if err := s.AuthService.EditUserProfile(userID, &userDetails); err != nil {
+		version, err = s.AuthService.EditUserProfile(userID, version, &userDetails)
+		if err != nil {
+			if conflict, ok := err.(*errs.ConflictError); ok {
+				respondWriteError(c, errs.New(conflict.Error(), http.StatusPreconditionFailed), s.currentProfile(userID))
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user details"})
 			return
 		}
 
+		setETag(c, version)
 		response.JSON(c, "User details updated successfully", http.StatusOK, nil, nil)
 	}
 }
@@ -691,13 +659,30 @@ func (s *Server) handleShowProfile() gin.HandlerFunc {
 			return
 		}
 
-		// Prepare response data
-		responseData := gin.H{
-			"email":        user.Email,
-		}
-
 		// Return the response
-		response.JSON(c, "User profile retrieved successfully", http.StatusOK, responseData, nil)
+		setETag(c, user.Version)
+		response.JSON(c, "User profile retrieved successfully", http.StatusOK, profileData(user), nil)
+	}
+}
+
+func profileData(user *models.User) gin.H {
+	return gin.H{
+		"email":     user.Email,
+		"fullname":  user.Fullname,
+		"username":  user.Username,
+		"telephone": user.Telephone,
+		"version":   user.Version,
+	}
+}
+
+// currentProfile reads the profile a stale edit was aimed at
+func (s *Server) currentProfile(userID uint) func() (interface{}, int, *errs.Error) {
+	return func() (interface{}, int, *errs.Error) {
+		user, err := s.AuthRepository.FindUserByID(userID)
+		if err != nil {
+			return nil, 0, errs.ErrNotFound
+		}
+		return profileData(user), user.Version, nil
 	}
 }
 
@@ -0,0 +1,343 @@
+package server
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+// maxReceiptSize is the largest receipt file accepted
+const maxReceiptSize = 10 << 20
+
+// handleCreateBudget starts the draft budget of a title, e.g. POST /titles/full_length/7/budget
+func (s *Server) handleCreateBudget() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.BudgetRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		budget, apiErr := s.BudgetService.CreateBudget(models.TitleType(c.Param("type")), titleID, &request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Budget created successfully", http.StatusCreated, budget, nil)
+	}
+}
+
+func (s *Server) handleGetTitleBudget() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		budget, apiErr := s.BudgetService.GetTitleBudget(models.TitleType(c.Param("type")), titleID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Budget retrieved successfully", http.StatusOK, budget, nil)
+	}
+}
+
+func (s *Server) handleGetBudget() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		budget, apiErr := s.BudgetService.GetBudget(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Budget retrieved successfully", http.StatusOK, budget, nil)
+	}
+}
+
+// handleUpdateBudget changes a draft budget, line items are matched on their id and the ones left out removed
+func (s *Server) handleUpdateBudget() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.BudgetRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		budget, apiErr := s.BudgetService.UpdateBudget(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Budget updated successfully", http.StatusOK, budget, nil)
+	}
+}
+
+func (s *Server) handleApproveBudget() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		budget, apiErr := s.BudgetService.ApproveBudget(id, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Budget approved successfully", http.StatusOK, budget, nil)
+	}
+}
+
+func (s *Server) handleReopenBudget() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		budget, apiErr := s.BudgetService.ReopenBudget(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Budget reopened successfully", http.StatusOK, budget, nil)
+	}
+}
+
+// handleBudgetVariance compares budgeted and actual costs by category and line item
+func (s *Server) handleBudgetVariance() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		report, apiErr := s.BudgetService.Variance(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Budget variance retrieved successfully", http.StatusOK, report, nil)
+	}
+}
+
+// handleRecordCost books an actual cost against a budget line, in any currency with a stored exchange rate
+func (s *Server) handleRecordCost() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.CostRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		cost, apiErr := s.BudgetService.RecordCost(id, &request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Cost recorded successfully", http.StatusCreated, cost, nil)
+	}
+}
+
+// handleListCosts lists the costs of a budget, e.g. GET /budgets/3/costs?status=pending&category=production
+func (s *Server) handleListCosts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		page, limit := pagination(c)
+		filter := &models.CostFilter{
+			BudgetID: id,
+			Status:   models.CostStatus(c.Query("status")),
+			Category: models.BudgetCategory(c.Query("category")),
+			Page:     page,
+			Limit:    limit,
+		}
+		if filter.Status != "" && !filter.Status.IsValid() {
+			apiErr := errs.New("status must be pending, approved or rejected", http.StatusBadRequest)
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		if filter.Category != "" && !filter.Category.IsValid() {
+			apiErr := errs.New("category must be above_the_line, production, post_production or marketing", http.StatusBadRequest)
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		costs, total, apiErr := s.BudgetService.ListCosts(filter)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Costs retrieved successfully", http.StatusOK, gin.H{
+			"costs": costs,
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		}, nil)
+	}
+}
+
+func (s *Server) handleGetCost() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		cost, apiErr := s.BudgetService.GetCost(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Cost retrieved successfully", http.StatusOK, cost, nil)
+	}
+}
+
+// handleReviewCost approves or rejects a cost waiting for an admin, with an optional note
+func (s *Server) handleReviewCost(status models.CostStatus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.CostReviewRequest
+		if c.Request.ContentLength != 0 {
+			if err := decode(c, &request); err != nil {
+				response.JSON(c, "", http.StatusBadRequest, nil, err)
+				return
+			}
+		}
+
+		cost, apiErr := s.BudgetService.ReviewCost(id, status, userID, request.Note)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Cost "+string(status)+" successfully", http.StatusOK, cost, nil)
+	}
+}
+
+func (s *Server) handleDeleteCost() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.BudgetService.DeleteCost(id, userID); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Cost deleted successfully", http.StatusOK, nil, nil)
+	}
+}
+
+// handleUploadReceipt attaches the receipt of a cost, sent as the "receipt" file of a multipart form
+func (s *Server) handleUploadReceipt() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxReceiptSize+maxFieldSize)
+		file, _, err := c.Request.FormFile("receipt")
+		if err != nil {
+			apiErr := errs.New("receipt file is required, of at most 10MB", http.StatusBadRequest)
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(io.LimitReader(file, maxReceiptSize+1))
+		if err != nil || len(data) > maxReceiptSize {
+			apiErr := errs.New("receipt file is required, of at most 10MB", http.StatusBadRequest)
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		cost, apiErr := s.BudgetService.AttachReceipt(c.Request.Context(), id, data)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Receipt uploaded successfully", http.StatusOK, cost, nil)
+	}
+}
+
+// handleReceiptURL returns a short-lived link to the receipt of a cost
+func (s *Server) handleReceiptURL() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		presigned, apiErr := s.BudgetService.ReceiptURL(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Download link created successfully", http.StatusOK, presigned, nil)
+	}
+}
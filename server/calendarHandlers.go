@@ -0,0 +1,145 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/techagentng/telair-erp/ical"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+// handleCalendar lists release milestones and festival dates, e.g. GET /calendar?from=2025-01-01&to=2025-03-31
+// narrowed down with mine=true, team=marketing or title_type=full_length&title_id=7. It covers the next
+// 90 days by default.
+func (s *Server) handleCalendar() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		from, apiErr := queryDate(c, "from", today)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		to, apiErr := queryDate(c, "to", from.AddDate(0, 0, 90))
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		filter := &models.CalendarFilter{From: from, To: to, Team: strings.ToLower(c.Query("team"))}
+		if c.Query("mine") == "true" {
+			filter.OwnerID = userID
+		}
+		filter.TitleType, filter.TitleID, apiErr = queryTitle(c)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		events, apiErr := s.ReleaseService.Calendar(filter)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Calendar retrieved successfully", http.StatusOK, events, nil)
+	}
+}
+
+// feedURL is where calendar apps subscribe to a feed
+func feedURL(c *gin.Context, token string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/api/v1/calendar/ics/%s.ics", scheme, c.Request.Host, token)
+}
+
+// handleCreateCalendarFeed opens an iCalendar feed of the user's own items, or of a team's with {"team":"marketing"}.
+// Its URL is a secret, anyone holding it can read the feed until it is revoked.
+func (s *Server) handleCreateCalendarFeed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		var request models.CalendarFeedRequest
+		if c.Request.ContentLength != 0 {
+			if err := decode(c, &request); err != nil {
+				response.JSON(c, "", http.StatusBadRequest, nil, err)
+				return
+			}
+		}
+
+		feed, apiErr := s.ReleaseService.CreateFeed(userID, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		feed.URL = feedURL(c, feed.Token)
+		response.JSON(c, "Calendar feed created successfully", http.StatusCreated, feed, nil)
+	}
+}
+
+func (s *Server) handleListCalendarFeeds() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+
+		feeds, apiErr := s.ReleaseService.ListFeeds(userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		for i := range feeds {
+			feeds[i].URL = feedURL(c, feeds[i].Token)
+		}
+		response.JSON(c, "Calendar feeds retrieved successfully", http.StatusOK, feeds, nil)
+	}
+}
+
+func (s *Server) handleDeleteCalendarFeed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.ReleaseService.DeleteFeed(id, userID); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Calendar feed revoked successfully", http.StatusOK, nil, nil)
+	}
+}
+
+// handleCalendarFeed serves the iCalendar document of a feed to calendar apps, the token in the URL is the
+// only credential
+func (s *Server) handleCalendarFeed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimSuffix(c.Param("token"), ".ics")
+		_, data, apiErr := s.ReleaseService.RenderFeed(token)
+		if apiErr != nil {
+			c.String(apiErr.Status, apiErr.Message)
+			return
+		}
+		c.Header("Cache-Control", "private, max-age=900")
+		c.Data(http.StatusOK, ical.ContentType, data)
+	}
+}
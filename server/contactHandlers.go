@@ -0,0 +1,220 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+// queryID reads an optional numeric ID from the query string, zero when it is not given
+func queryID(c *gin.Context, name string) (uint, *errs.Error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, errs.New(name+" must be a number", http.StatusBadRequest)
+	}
+	return uint(id), nil
+}
+
+func (s *Server) handleCreateContact() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		var request models.ContactRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		contact, apiErr := s.CRMService.CreateContact(&request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Contact created successfully", http.StatusCreated, contact, nil)
+	}
+}
+
+// handleUpdateContact replaces a contact, along with their emails, phones and addresses
+func (s *Server) handleUpdateContact() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.ContactRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		contact, apiErr := s.CRMService.UpdateContact(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Contact updated successfully", http.StatusOK, contact, nil)
+	}
+}
+
+func (s *Server) handleDeleteContact() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.CRMService.DeleteContact(id); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Contact deleted successfully", http.StatusOK, nil, nil)
+	}
+}
+
+func (s *Server) handleGetContact() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		contact, apiErr := s.CRMService.GetContact(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Contact retrieved successfully", http.StatusOK, contact, nil)
+	}
+}
+
+// handleListContacts searches contacts by name, job title, email or phone, e.g. GET /contacts?q=0803123&organization_id=4
+func (s *Server) handleListContacts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit := pagination(c)
+		filter := &models.ContactFilter{
+			Query: c.Query("q"),
+			Page:  page,
+			Limit: limit,
+		}
+		var apiErr *errs.Error
+		if filter.OwnerID, apiErr = queryID(c, "owner_id"); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		if filter.OrganizationID, apiErr = queryID(c, "organization_id"); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		contacts, total, apiErr := s.CRMService.ListContacts(filter)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Contacts retrieved successfully", http.StatusOK, gin.H{
+			"contacts": contacts,
+			"total":    total,
+			"page":     page,
+			"limit":    limit,
+		}, nil)
+	}
+}
+
+// handleContactDuplicates lists the groups of contacts sharing an email or phone number
+func (s *Server) handleContactDuplicates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groups, apiErr := s.CRMService.ContactDuplicates()
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Duplicate contacts retrieved successfully", http.StatusOK, groups, nil)
+	}
+}
+
+// handleMergeContacts folds the contact in the path into the one named in the body
+func (s *Server) handleMergeContacts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.MergeRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		contact, apiErr := s.CRMService.MergeContacts(id, request.IntoID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Contacts merged successfully", http.StatusOK, contact, nil)
+	}
+}
+
+// handleLinkContact links a contact to an organization with their role there, e.g.
+// PUT /contacts/3/organizations/4 {"role": "head of acquisitions"}
+func (s *Server) handleLinkContact() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		organizationID, apiErr := paramID(c, "organizationID")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.ContactOrganizationRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		contact, apiErr := s.CRMService.LinkContact(id, organizationID, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Contact linked successfully", http.StatusOK, contact, nil)
+	}
+}
+
+func (s *Server) handleUnlinkContact() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		organizationID, apiErr := paramID(c, "organizationID")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.CRMService.UnlinkContact(id, organizationID); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Contact unlinked successfully", http.StatusOK, nil, nil)
+	}
+}
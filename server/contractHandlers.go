@@ -0,0 +1,150 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+func (s *Server) handleCreateContract() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.ContractRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		contract, apiErr := s.ContractService.CreateContract(&request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Contract created successfully", http.StatusCreated, contract, nil)
+	}
+}
+
+// handleUpdateContract replaces the terms of a draft contract, titles and payment schedule included
+func (s *Server) handleUpdateContract() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.ContractRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		contract, apiErr := s.ContractService.UpdateContract(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Contract updated successfully", http.StatusOK, contract, nil)
+	}
+}
+
+func (s *Server) handleGetContract() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		contract, apiErr := s.ContractService.GetContract(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Contract retrieved successfully", http.StatusOK, contract, nil)
+	}
+}
+
+// handleListContracts lists contracts, e.g. GET /contracts?status=active&licensee_id=3&page=2
+func (s *Server) handleListContracts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit := pagination(c)
+		filter := &models.ContractFilter{
+			Status: models.ContractStatus(c.Query("status")),
+			Page:   page,
+			Limit:  limit,
+		}
+		if filter.Status != "" && !filter.Status.IsValid() {
+			apiErr := errs.New("status must be draft, active, expired or terminated", http.StatusBadRequest)
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		if value := c.Query("licensee_id"); value != "" {
+			licenseeID, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				apiErr := errs.New("licensee_id must be a number", http.StatusBadRequest)
+				response.JSON(c, "", apiErr.Status, nil, apiErr)
+				return
+			}
+			filter.LicenseeID = uint(licenseeID)
+		}
+
+		contracts, total, apiErr := s.ContractService.ListContracts(filter)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Contracts retrieved successfully", http.StatusOK, gin.H{
+			"contracts": contracts,
+			"total":     total,
+			"page":      page,
+			"limit":     limit,
+		}, nil)
+	}
+}
+
+func (s *Server) handleChangeContractStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.ContractStatusRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		contract, apiErr := s.ContractService.ChangeStatus(id, request.Status)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Contract status updated successfully", http.StatusOK, contract, nil)
+	}
+}
+
+func (s *Server) handleMarkContractPaymentPaid() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		paymentID, apiErr := paramID(c, "paymentID")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		contract, apiErr := s.ContractService.MarkPaymentPaid(id, paymentID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Payment marked as paid", http.StatusOK, contract, nil)
+	}
+}
@@ -0,0 +1,393 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+func (s *Server) handleCreatePipeline() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.PipelineRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		pipeline, apiErr := s.DealService.CreatePipeline(&request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Pipeline created successfully", http.StatusCreated, pipeline, nil)
+	}
+}
+
+// handleUpdatePipeline renames, reorders, adds and removes the stages of a pipeline
+func (s *Server) handleUpdatePipeline() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.PipelineRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		pipeline, apiErr := s.DealService.UpdatePipeline(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Pipeline updated successfully", http.StatusOK, pipeline, nil)
+	}
+}
+
+func (s *Server) handleDeletePipeline() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.DealService.DeletePipeline(id); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Pipeline deleted successfully", http.StatusOK, nil, nil)
+	}
+}
+
+func (s *Server) handleGetPipeline() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		pipeline, apiErr := s.DealService.GetPipeline(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Pipeline retrieved successfully", http.StatusOK, pipeline, nil)
+	}
+}
+
+func (s *Server) handleListPipelines() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pipelines, apiErr := s.DealService.ListPipelines()
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Pipelines retrieved successfully", http.StatusOK, pipelines, nil)
+	}
+}
+
+func (s *Server) handleCreateDeal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		var request models.DealRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		deal, apiErr := s.DealService.CreateDeal(&request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Deal created successfully", http.StatusCreated, deal, nil)
+	}
+}
+
+func (s *Server) handleUpdateDeal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.DealRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		deal, apiErr := s.DealService.UpdateDeal(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Deal updated successfully", http.StatusOK, deal, nil)
+	}
+}
+
+func (s *Server) handleDeleteDeal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.DealService.DeleteDeal(id); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Deal deleted successfully", http.StatusOK, nil, nil)
+	}
+}
+
+// handleGetDeal returns a deal with its stage history
+func (s *Server) handleGetDeal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		deal, apiErr := s.DealService.GetDeal(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Deal retrieved successfully", http.StatusOK, deal, nil)
+	}
+}
+
+// handleListDeals lists deals, e.g. GET /deals?pipeline_id=1&status=open&title_type=full_length&title_id=7
+func (s *Server) handleListDeals() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit := pagination(c)
+		filter := &models.DealFilter{
+			Query:  c.Query("q"),
+			Status: models.DealStatus(c.Query("status")),
+			Page:   page,
+			Limit:  limit,
+		}
+		if filter.Status != "" && !filter.Status.IsValid() {
+			apiErr := errs.New("status must be open, won or lost", http.StatusBadRequest)
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		params := []struct {
+			name string
+			id   *uint
+		}{
+			{"pipeline_id", &filter.PipelineID},
+			{"stage_id", &filter.StageID},
+			{"owner_id", &filter.OwnerID},
+			{"contact_id", &filter.ContactID},
+			{"organization_id", &filter.OrganizationID},
+		}
+		for _, param := range params {
+			value, apiErr := queryID(c, param.name)
+			if apiErr != nil {
+				response.JSON(c, "", apiErr.Status, nil, apiErr)
+				return
+			}
+			*param.id = value
+		}
+		titleType, titleID, apiErr := queryTitle(c)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		filter.TitleType, filter.TitleID = titleType, titleID
+
+		deals, total, apiErr := s.DealService.ListDeals(filter)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Deals retrieved successfully", http.StatusOK, gin.H{
+			"deals": deals,
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		}, nil)
+	}
+}
+
+func (s *Server) handleMoveDeal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.DealStageRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		deal, apiErr := s.DealService.MoveDeal(id, &request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Deal moved successfully", http.StatusOK, deal, nil)
+	}
+}
+
+// handleCloseDeal marks a deal won or lost, e.g. POST /deals/3/close {"status": "lost", "reason": "price"}
+func (s *Server) handleCloseDeal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.DealCloseRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		deal, apiErr := s.DealService.CloseDeal(id, &request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Deal closed successfully", http.StatusOK, deal, nil)
+	}
+}
+
+func (s *Server) handleReopenDeal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.DealReopenRequest
+		if c.Request.ContentLength != 0 {
+			if err := decode(c, &request); err != nil {
+				response.JSON(c, "", http.StatusBadRequest, nil, err)
+				return
+			}
+		}
+
+		deal, apiErr := s.DealService.ReopenDeal(id, &request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Deal reopened successfully", http.StatusOK, deal, nil)
+	}
+}
+
+// dealReportFilter reads the pipeline of a report from the path, and the owner and the period the deals
+// were created in from the query string
+func dealReportFilter(c *gin.Context) (*models.DealReportFilter, *errs.Error) {
+	id, apiErr := paramID(c, "id")
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	filter := &models.DealReportFilter{PipelineID: id}
+	if filter.OwnerID, apiErr = queryID(c, "owner_id"); apiErr != nil {
+		return nil, apiErr
+	}
+	if c.Query("from") != "" {
+		from, apiErr := queryDate(c, "from", time.Time{})
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		filter.From = &from
+	}
+	if c.Query("to") != "" {
+		to, apiErr := queryDate(c, "to", time.Time{})
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		filter.To = &to
+	}
+	return filter, nil
+}
+
+// handlePipelineValue reports the open deals of a pipeline by stage, e.g. GET /pipelines/1/reports/value?currency=USD
+func (s *Server) handlePipelineValue() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, apiErr := dealReportFilter(c)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		report, apiErr := s.DealService.ValueReport(filter, c.Query("currency"))
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Pipeline value retrieved successfully", http.StatusOK, report, nil)
+	}
+}
+
+// handlePipelineConversion reports how deals created in a period went from stage to stage,
+// e.g. GET /pipelines/1/reports/conversion?from=2025-01-01&to=2025-06-30
+func (s *Server) handlePipelineConversion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, apiErr := dealReportFilter(c)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		report, apiErr := s.DealService.ConversionReport(filter)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Pipeline conversion retrieved successfully", http.StatusOK, report, nil)
+	}
+}
+
+func (s *Server) handlePipelineStageTime() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, apiErr := dealReportFilter(c)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		report, apiErr := s.DealService.StageTimeReport(filter)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Time in stage retrieved successfully", http.StatusOK, report, nil)
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+// setETag tags the response with the version of the resource it represents
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", fmt.Sprintf("%q", strconv.Itoa(version)))
+}
+
+// etagMatches tells whether the client already holds the representation tagged etag, per If-None-Match
+func etagMatches(c *gin.Context, etag string) bool {
+	for _, value := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+		if value == "*" || value == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatch reads the version a write is based on from the If-Match header. Writes without one are
+// refused, so that nobody overwrites changes they have not seen. If-Match: * matches whatever version
+// is current and gives models.AnyVersion.
+func ifMatch(c *gin.Context) (int, *errs.Error) {
+	value := strings.TrimSpace(c.GetHeader("If-Match"))
+	if value == "" {
+		return 0, errs.New("If-Match header is required, it takes the ETag of the resource", http.StatusPreconditionRequired)
+	}
+	if value == "*" {
+		return models.AnyVersion, nil
+	}
+	tag := strings.TrimPrefix(value, "W/")
+	if len(tag) >= 2 && strings.HasPrefix(tag, `"`) && strings.HasSuffix(tag, `"`) {
+		tag = tag[1 : len(tag)-1]
+	}
+	version, err := strconv.Atoi(tag)
+	if err != nil || version < 1 {
+		return 0, errs.New("If-Match does not match the current ETag", http.StatusPreconditionFailed)
+	}
+	return version, nil
+}
+
+// respondWriteError answers a failed write. Stale writes get the current representation of the
+// resource, read through current, along with its ETag.
+func respondWriteError(c *gin.Context, apiErr *errs.Error, current func() (interface{}, int, *errs.Error)) {
+	if apiErr.Status == http.StatusPreconditionFailed {
+		if data, version, err := current(); err == nil {
+			setETag(c, version)
+			response.JSON(c, "", apiErr.Status, data, apiErr)
+			return
+		}
+	}
+	response.JSON(c, "", apiErr.Status, nil, apiErr)
+}
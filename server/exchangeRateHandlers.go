@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+// handleSaveExchangeRate stores a rate, e.g. {"base":"USD","quote":"NGN","effective_on":"2025-01-31","rate":"1550.25"}
+// is what one dollar is worth in naira from that day on. A rate of the same pair and day is replaced.
+func (s *Server) handleSaveExchangeRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		var request models.ExchangeRateRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		rate, apiErr := s.ExchangeRateService.SaveRate(&request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Exchange rate saved successfully", http.StatusCreated, rate, nil)
+	}
+}
+
+// handleListExchangeRates lists the stored rates latest first, e.g. GET /exchange-rates?base=USD&quote=NGN
+func (s *Server) handleListExchangeRates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit := pagination(c)
+		rates, total, apiErr := s.ExchangeRateService.ListRates(c.Query("base"), c.Query("quote"), page, limit)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Exchange rates retrieved successfully", http.StatusOK, gin.H{
+			"rates": rates,
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		}, nil)
+	}
+}
@@ -0,0 +1,352 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+func (s *Server) handleListTaxRates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rates, apiErr := s.InvoiceService.ListTaxRates()
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Tax rates retrieved successfully", http.StatusOK, rates, nil)
+	}
+}
+
+func (s *Server) handleCreateTaxRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.TaxRateRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		rate, apiErr := s.InvoiceService.CreateTaxRate(&request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Tax rate created successfully", http.StatusCreated, rate, nil)
+	}
+}
+
+func (s *Server) handleUpdateTaxRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.TaxRateRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		rate, apiErr := s.InvoiceService.UpdateTaxRate(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Tax rate updated successfully", http.StatusOK, rate, nil)
+	}
+}
+
+func (s *Server) handleDeleteTaxRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.InvoiceService.DeleteTaxRate(id); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Tax rate deleted successfully", http.StatusOK, nil, nil)
+	}
+}
+
+func (s *Server) handleCreateInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		var request models.InvoiceRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		invoice, apiErr := s.InvoiceService.CreateInvoice(&request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Invoice created successfully", http.StatusCreated, invoice, nil)
+	}
+}
+
+func (s *Server) handleUpdateInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.InvoiceRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		invoice, apiErr := s.InvoiceService.UpdateInvoice(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Invoice updated successfully", http.StatusOK, invoice, nil)
+	}
+}
+
+func (s *Server) handleDeleteInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.InvoiceService.DeleteInvoice(id); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Invoice deleted successfully", http.StatusOK, nil, nil)
+	}
+}
+
+func (s *Server) handleGetInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		invoice, apiErr := s.InvoiceService.GetInvoice(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Invoice retrieved successfully", http.StatusOK, invoice, nil)
+	}
+}
+
+// handleListInvoices lists invoices, e.g. GET /invoices?organization_id=4&overdue=true
+func (s *Server) handleListInvoices() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit := pagination(c)
+		filter := &models.InvoiceFilter{
+			Status:  models.InvoiceStatus(c.Query("status")),
+			Overdue: c.Query("overdue") == "true",
+			Page:    page,
+			Limit:   limit,
+		}
+		params := []struct {
+			name string
+			id   *uint
+		}{
+			{"organization_id", &filter.OrganizationID},
+			{"deal_id", &filter.DealID},
+			{"contract_id", &filter.ContractID},
+		}
+		for _, param := range params {
+			value, apiErr := queryID(c, param.name)
+			if apiErr != nil {
+				response.JSON(c, "", apiErr.Status, nil, apiErr)
+				return
+			}
+			*param.id = value
+		}
+
+		invoices, total, apiErr := s.InvoiceService.ListInvoices(filter)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Invoices retrieved successfully", http.StatusOK, gin.H{
+			"invoices": invoices,
+			"total":    total,
+			"page":     page,
+			"limit":    limit,
+		}, nil)
+	}
+}
+
+func (s *Server) handleInvoicePDF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		invoice, data, apiErr := s.InvoiceService.InvoicePDF(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		filename := fmt.Sprintf("invoice-%s.pdf", invoice.Number)
+		if invoice.Number == "" {
+			filename = fmt.Sprintf("draft-invoice-%d.pdf", invoice.ID)
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Data(http.StatusOK, "application/pdf", data)
+	}
+}
+
+// handleSendInvoice emails an invoice, issuing it first when it is a draft
+func (s *Server) handleSendInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.InvoiceSendRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		invoice, apiErr := s.InvoiceService.SendInvoice(id, &request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Invoice sent successfully", http.StatusOK, invoice, nil)
+	}
+}
+
+func (s *Server) handleVoidInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.InvoiceVoidRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		invoice, apiErr := s.InvoiceService.VoidInvoice(id, &request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Invoice voided successfully", http.StatusOK, invoice, nil)
+	}
+}
+
+func (s *Server) handleRecordInvoicePayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.InvoicePaymentRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		invoice, apiErr := s.InvoiceService.RecordPayment(id, &request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Payment recorded successfully", http.StatusCreated, invoice, nil)
+	}
+}
+
+func (s *Server) handleDeleteInvoicePayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		paymentID, apiErr := paramID(c, "paymentID")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		invoice, apiErr := s.InvoiceService.DeletePayment(id, paymentID, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Payment deleted successfully", http.StatusOK, invoice, nil)
+	}
+}
+
+// handleInvoiceAging reports what organizations owe by how long it is overdue, e.g.
+// GET /invoices/reports/aging?as_of=2025-06-30&currency=USD
+func (s *Server) handleInvoiceAging() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		today, _ := time.Parse(models.DateLayout, time.Now().UTC().Format(models.DateLayout))
+		asOf, apiErr := queryDate(c, "as_of", today)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		if asOf.After(today) {
+			apiErr := errs.New("as_of cannot be in the future", http.StatusBadRequest)
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		report, apiErr := s.InvoiceService.AgingReport(asOf, c.Query("currency"))
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Aging report retrieved successfully", http.StatusOK, report, nil)
+	}
+}
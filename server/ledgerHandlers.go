@@ -0,0 +1,375 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+func (s *Server) handleListAccounts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accounts, apiErr := s.LedgerService.ListAccounts()
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Accounts retrieved successfully", http.StatusOK, accounts, nil)
+	}
+}
+
+func (s *Server) handleCreateAccount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.AccountRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		account, apiErr := s.LedgerService.CreateAccount(&request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Account created successfully", http.StatusCreated, account, nil)
+	}
+}
+
+func (s *Server) handleUpdateAccount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.AccountRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		account, apiErr := s.LedgerService.UpdateAccount(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Account updated successfully", http.StatusOK, account, nil)
+	}
+}
+
+func (s *Server) handleDeleteAccount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.LedgerService.DeleteAccount(id); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Account deleted successfully", http.StatusOK, nil, nil)
+	}
+}
+
+func (s *Server) handleCreateJournalEntry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		var request models.JournalEntryRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		entry, apiErr := s.LedgerService.CreateEntry(&request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Journal entry created successfully", http.StatusCreated, entry, nil)
+	}
+}
+
+func (s *Server) handleUpdateJournalEntry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.JournalEntryRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		entry, apiErr := s.LedgerService.UpdateEntry(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Journal entry updated successfully", http.StatusOK, entry, nil)
+	}
+}
+
+func (s *Server) handleDeleteJournalEntry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.LedgerService.DeleteEntry(id); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Journal entry deleted successfully", http.StatusOK, nil, nil)
+	}
+}
+
+func (s *Server) handleGetJournalEntry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		entry, apiErr := s.LedgerService.GetEntry(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Journal entry retrieved successfully", http.StatusOK, entry, nil)
+	}
+}
+
+// handleListJournalEntries lists journal entries, e.g. GET /ledger/entries?account_id=2&from=2025-06-01&to=2025-06-30
+func (s *Server) handleListJournalEntries() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit := pagination(c)
+		accountID, apiErr := queryID(c, "account_id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		filter := &models.JournalFilter{
+			Status:    models.EntryStatus(c.Query("status")),
+			Source:    models.EntrySource(c.Query("source")),
+			AccountID: accountID,
+			Page:      page,
+			Limit:     limit,
+		}
+		from, apiErr := queryDate(c, "from", time.Time{})
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		to, apiErr := queryDate(c, "to", time.Time{})
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		if !from.IsZero() {
+			filter.From = &from
+		}
+		if !to.IsZero() {
+			filter.To = &to
+		}
+
+		entries, total, apiErr := s.LedgerService.ListEntries(filter)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Journal entries retrieved successfully", http.StatusOK, gin.H{
+			"entries": entries,
+			"total":   total,
+			"page":    page,
+			"limit":   limit,
+		}, nil)
+	}
+}
+
+func (s *Server) handlePostJournalEntry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		entry, apiErr := s.LedgerService.PostEntry(id, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Journal entry posted successfully", http.StatusOK, entry, nil)
+	}
+}
+
+// handleReverseJournalEntry posts the reversal of a manual entry, today unless the body gives a date
+func (s *Server) handleReverseJournalEntry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.JournalReverseRequest
+		if c.Request.ContentLength != 0 {
+			if err := decode(c, &request); err != nil {
+				response.JSON(c, "", http.StatusBadRequest, nil, err)
+				return
+			}
+		}
+
+		reversal, apiErr := s.LedgerService.ReverseEntry(id, &request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Journal entry reversed successfully", http.StatusCreated, reversal, nil)
+	}
+}
+
+func (s *Server) handleListPeriods() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		periods, apiErr := s.LedgerService.ListPeriods()
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Accounting periods retrieved successfully", http.StatusOK, periods, nil)
+	}
+}
+
+func (s *Server) handleCreatePeriod() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.AccountingPeriodRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		period, apiErr := s.LedgerService.CreatePeriod(&request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Accounting period created successfully", http.StatusCreated, period, nil)
+	}
+}
+
+// handleSetPeriodStatus closes, reopens or locks an accounting period
+func (s *Server) handleSetPeriodStatus(status models.PeriodStatus, done string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		period, apiErr := s.LedgerService.SetPeriodStatus(id, status, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Accounting period "+done+" successfully", http.StatusOK, period, nil)
+	}
+}
+
+// today is the current day in UTC, the fallback of report dates
+func today() time.Time {
+	day, _ := time.Parse(models.DateLayout, time.Now().UTC().Format(models.DateLayout))
+	return day
+}
+
+// handleTrialBalance reports the balance of every account on a day, e.g. GET /ledger/reports/trial-balance?as_of=2025-06-30
+func (s *Server) handleTrialBalance() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		asOf, apiErr := queryDate(c, "as_of", today())
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		report, apiErr := s.LedgerService.TrialBalance(asOf)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Trial balance retrieved successfully", http.StatusOK, report, nil)
+	}
+}
+
+// handleProfitAndLoss reports income and expenses over a span of days, the current year to date by default,
+// e.g. GET /ledger/reports/profit-and-loss?from=2025-01-01&to=2025-06-30
+func (s *Server) handleProfitAndLoss() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		to, apiErr := queryDate(c, "to", today())
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		from, apiErr := queryDate(c, "from", time.Date(to.Year(), time.January, 1, 0, 0, 0, 0, time.UTC))
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		if to.Before(from) {
+			apiErr := errs.New("to cannot be before from", http.StatusBadRequest)
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		report, apiErr := s.LedgerService.ProfitAndLoss(from, to)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Profit and loss retrieved successfully", http.StatusOK, report, nil)
+	}
+}
+
+// handleBalanceSheet reports assets, liabilities and equity on a day, e.g. GET /ledger/reports/balance-sheet?as_of=2025-06-30
+func (s *Server) handleBalanceSheet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		asOf, apiErr := queryDate(c, "as_of", today())
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		report, apiErr := s.LedgerService.BalanceSheet(asOf)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Balance sheet retrieved successfully", http.StatusOK, report, nil)
+	}
+}
@@ -142,6 +142,16 @@ func getTokenFromHeader(c *gin.Context) string {
 	return ""
 }
 
+// getUserIDFromContext returns the ID of the user set by the Authorize middleware
+func getUserIDFromContext(c *gin.Context) (uint, bool) {
+	value, ok := c.Get("userID")
+	if !ok {
+		return 0, false
+	}
+	userID, ok := value.(uint)
+	return userID, ok
+}
+
 // Function to check if a string exists in a slice of strings
 func containsString(s []string, str string) bool {
 	for _, v := range s {
@@ -151,3 +161,14 @@ func containsString(s []string, str string) bool {
 	}
 	return false
 }
+
+// RequireRole lets the request through only when the role set by Authorize is one of roles
+func (s *Server) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !containsString(roles, c.GetString("user_role")) {
+			respondAndAbort(c, "", http.StatusForbidden, nil, errs.New("you are not allowed to perform this action", http.StatusForbidden))
+			return
+		}
+		c.Next()
+	}
+}
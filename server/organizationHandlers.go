@@ -0,0 +1,159 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+func (s *Server) handleCreateOrganization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		var request models.OrganizationRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		organization, apiErr := s.CRMService.CreateOrganization(&request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Organization created successfully", http.StatusCreated, organization, nil)
+	}
+}
+
+// handleUpdateOrganization replaces an organization, along with its emails, phones and addresses
+func (s *Server) handleUpdateOrganization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.OrganizationRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		organization, apiErr := s.CRMService.UpdateOrganization(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Organization updated successfully", http.StatusOK, organization, nil)
+	}
+}
+
+func (s *Server) handleDeleteOrganization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.CRMService.DeleteOrganization(id); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Organization deleted successfully", http.StatusOK, nil, nil)
+	}
+}
+
+// handleGetOrganization returns an organization with the contacts linked to it
+func (s *Server) handleGetOrganization() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		organization, apiErr := s.CRMService.GetOrganization(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Organization retrieved successfully", http.StatusOK, organization, nil)
+	}
+}
+
+// handleListOrganizations searches organizations by name, email or phone, e.g. GET /organizations?q=film&kind=distributor
+func (s *Server) handleListOrganizations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit := pagination(c)
+		filter := &models.OrganizationFilter{
+			Query: c.Query("q"),
+			Kind:  models.OrganizationKind(c.Query("kind")),
+			Page:  page,
+			Limit: limit,
+		}
+		if filter.Kind != "" && !filter.Kind.IsValid() {
+			apiErr := errs.New("kind must be distributor, festival, vendor, talent_agency, broadcaster or other", http.StatusBadRequest)
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var apiErr *errs.Error
+		if filter.OwnerID, apiErr = queryID(c, "owner_id"); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		organizations, total, apiErr := s.CRMService.ListOrganizations(filter)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Organizations retrieved successfully", http.StatusOK, gin.H{
+			"organizations": organizations,
+			"total":         total,
+			"page":          page,
+			"limit":         limit,
+		}, nil)
+	}
+}
+
+// handleOrganizationDuplicates lists the groups of organizations sharing a name, an email or a phone number
+func (s *Server) handleOrganizationDuplicates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groups, apiErr := s.CRMService.OrganizationDuplicates()
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Duplicate organizations retrieved successfully", http.StatusOK, groups, nil)
+	}
+}
+
+// handleMergeOrganizations folds the organization in the path into the one named in the body
+func (s *Server) handleMergeOrganizations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.MergeRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		organization, apiErr := s.CRMService.MergeOrganizations(id, request.IntoID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Organizations merged successfully", http.StatusOK, organization, nil)
+	}
+}
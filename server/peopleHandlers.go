@@ -0,0 +1,188 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// paramID reads a numeric ID from the path
+func paramID(c *gin.Context, name string) (uint, *errs.Error) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		return 0, errs.New("invalid "+name, http.StatusBadRequest)
+	}
+	return uint(id), nil
+}
+
+// pagination reads the page and limit query parameters
+func pagination(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	return page, limit
+}
+
+func (s *Server) handleCreatePerson() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.PersonRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		person, apiErr := s.PeopleService.CreatePerson(&request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Person created successfully", http.StatusCreated, person, nil)
+	}
+}
+
+func (s *Server) handleUpdatePerson() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.PersonRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		person, apiErr := s.PeopleService.UpdatePerson(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Person updated successfully", http.StatusOK, person, nil)
+	}
+}
+
+func (s *Server) handleGetPerson() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		person, apiErr := s.PeopleService.GetPerson(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Person retrieved successfully", http.StatusOK, person, nil)
+	}
+}
+
+// handleSearchPeople looks people up by name or alias, e.g. GET /people?q=ade&page=2
+func (s *Server) handleSearchPeople() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit := pagination(c)
+		people, total, apiErr := s.PeopleService.SearchPeople(c.Query("q"), page, limit)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "People retrieved successfully", http.StatusOK, gin.H{
+			"people": people,
+			"total":  total,
+			"page":   page,
+			"limit":  limit,
+		}, nil)
+	}
+}
+
+func (s *Server) handleGetFilmography() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		entries, apiErr := s.PeopleService.Filmography(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Filmography retrieved successfully", http.StatusOK, entries, nil)
+	}
+}
+
+// handleAddCredit credits a person on a title, e.g. POST /titles/trailer/42/credits
+func (s *Server) handleAddCredit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.CreditRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		credit, apiErr := s.PeopleService.AddCredit(models.TitleType(c.Param("type")), titleID, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Credit added successfully", http.StatusCreated, credit, nil)
+	}
+}
+
+func (s *Server) handleGetTitleCredits() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		credits, apiErr := s.PeopleService.GetTitleCredits(models.TitleType(c.Param("type")), titleID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Credits retrieved successfully", http.StatusOK, credits, nil)
+	}
+}
+
+func (s *Server) handleRemoveCredit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.PeopleService.RemoveCredit(id); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Credit removed successfully", http.StatusOK, nil, nil)
+	}
+}
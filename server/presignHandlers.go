@@ -0,0 +1,117 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+// handleCreateDirectUpload issues a presigned PUT, or starts a multipart upload whose parts are signed one by one
+func (s *Server) handleCreateDirectUpload() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+
+		var request models.DirectUploadRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+		if !allowedFileTypes[request.ContentType] {
+			response.JSON(c, "", http.StatusUnsupportedMediaType, nil, errs.New(fmt.Sprintf("invalid file type: %s", request.ContentType), http.StatusUnsupportedMediaType))
+			return
+		}
+		if request.Size > maxFileSize[request.ContentType] {
+			response.JSON(c, "", http.StatusRequestEntityTooLarge, nil, errs.New(fmt.Sprintf("file exceeds the maximum allowed size of %d bytes", maxFileSize[request.ContentType]), http.StatusRequestEntityTooLarge))
+			return
+		}
+		if request.Kind == "" {
+			request.Kind = mediaKindFor(request.ContentType)
+		}
+		if request.Kind == models.MediaSubtitle || mediaKindFor(request.ContentType) == models.MediaSubtitle {
+			response.JSON(c, "", http.StatusBadRequest, nil, errs.New("subtitles are uploaded to /titles/:type/:id/subtitles", http.StatusBadRequest))
+			return
+		}
+
+		upload, presigned, apiErr := s.DirectUploadService.CreateUpload(userID, c.GetString("user_role"), &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		response.JSON(c, "Upload created successfully", http.StatusCreated, gin.H{
+			"upload":  upload,
+			"request": presigned,
+		}, nil)
+	}
+}
+
+func (s *Server) handlePresignUploadPart() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := getUserIDFromContext(c)
+
+		var request models.PresignPartRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		presigned, apiErr := s.DirectUploadService.PresignPart(c.Param("id"), userID, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Part signed successfully", http.StatusOK, presigned, nil)
+	}
+}
+
+// handleCompleteDirectUpload is called by the client once the file is in storage
+func (s *Server) handleCompleteDirectUpload() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := getUserIDFromContext(c)
+
+		var request models.CompleteDirectUploadRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		asset, apiErr := s.DirectUploadService.CompleteUpload(c.Param("id"), userID, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Upload completed successfully", http.StatusOK, asset, nil)
+	}
+}
+
+// handleMediaDownloadURL returns a short-lived link to a private media asset
+func (s *Server) handleMediaDownloadURL() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		assetID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, errs.New("invalid media asset id", http.StatusBadRequest))
+			return
+		}
+
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		presigned, apiErr := s.DirectUploadService.DownloadURL(uint(assetID), userID, c.GetString("user_role"))
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Download link created successfully", http.StatusOK, presigned, nil)
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+// pressKitHeaders tags a press kit with its fingerprint. Clients and proxies have to revalidate, the
+// fingerprint only changes with the title, so most of the time they get a 304.
+func pressKitHeaders(c *gin.Context, kit *models.PressKit) string {
+	etag := fmt.Sprintf("%q", kit.Fingerprint)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	return etag
+}
+
+// handleDownloadPressKit streams the press kit of a title as a ZIP, e.g. GET /titles/full_length/7/press-kit
+func (s *Server) handleDownloadPressKit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		kit, apiErr := s.PressKitService.BuildPressKit(models.TitleType(c.Param("type")), titleID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		if etagMatches(c, pressKitHeaders(c, kit)) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", kit.Filename))
+		c.Status(http.StatusOK)
+		if err := s.PressKitService.WriteBundle(c.Request.Context(), kit, c.Writer); err != nil {
+			// the status is gone already, all that is left is to cut the download short
+			log.Printf("press kit of %s %d: %v", kit.TitleType, kit.TitleID, err)
+			c.Abort()
+		}
+	}
+}
+
+// handleGetPressKitManifest returns what the press kit of a title holds, without building the ZIP
+func (s *Server) handleGetPressKitManifest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		kit, apiErr := s.PressKitService.BuildPressKit(models.TitleType(c.Param("type")), titleID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		if etagMatches(c, pressKitHeaders(c, kit)) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+		response.JSON(c, "Press kit retrieved successfully", http.StatusOK, kit, nil)
+	}
+}
+
+func (s *Server) handleListPressKitAssets() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		assets, apiErr := s.PressKitService.ListAssets(models.TitleType(c.Param("type")), titleID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Press kit assets retrieved successfully", http.StatusOK, assets, nil)
+	}
+}
+
+// handleSetPressKitAssets picks the stills, posters and trailers of the press kit of a title, in order
+func (s *Server) handleSetPressKitAssets() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.PressKitAssetsRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		assets, apiErr := s.PressKitService.SetAssets(models.TitleType(c.Param("type")), titleID, request.AssetIDs)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Press kit assets updated successfully", http.StatusOK, assets, nil)
+	}
+}
@@ -0,0 +1,426 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+func (s *Server) handleListApprovalLevels() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		levels, apiErr := s.PurchaseService.ListApprovalLevels()
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Approval levels retrieved successfully", http.StatusOK, levels, nil)
+	}
+}
+
+// handleSetApprovalLevels replaces every level of approval, e.g. PUT /purchase-orders/approval-levels
+// with [{"min_amount": "0", "role": "User"}, {"min_amount": "500000", "role": "Admin"}]
+func (s *Server) handleSetApprovalLevels() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var requests []models.PurchaseApprovalLevelRequest
+		if err := decode(c, &requests); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		levels, apiErr := s.PurchaseService.SetApprovalLevels(requests)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Approval levels updated successfully", http.StatusOK, levels, nil)
+	}
+}
+
+func (s *Server) handleCreatePurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		var request models.PurchaseOrderRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		order, apiErr := s.PurchaseService.CreateOrder(&request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Purchase order created successfully", http.StatusCreated, order, nil)
+	}
+}
+
+func (s *Server) handleUpdatePurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.PurchaseOrderRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		order, apiErr := s.PurchaseService.UpdateOrder(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Purchase order updated successfully", http.StatusOK, order, nil)
+	}
+}
+
+func (s *Server) handleDeletePurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.PurchaseService.DeleteOrder(id); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Purchase order deleted successfully", http.StatusOK, nil, nil)
+	}
+}
+
+func (s *Server) handleGetPurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		order, apiErr := s.PurchaseService.GetOrder(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Purchase order retrieved successfully", http.StatusOK, order, nil)
+	}
+}
+
+// handleListPurchaseOrders lists purchase orders, e.g. GET /purchase-orders?vendor_id=3&status=ordered
+func (s *Server) handleListPurchaseOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit := pagination(c)
+		filter := &models.PurchaseOrderFilter{
+			Status: models.PurchaseOrderStatus(c.Query("status")),
+			Page:   page,
+			Limit:  limit,
+		}
+		params := []struct {
+			name string
+			id   *uint
+		}{
+			{"vendor_id", &filter.VendorID},
+			{"budget_id", &filter.BudgetID},
+		}
+		for _, param := range params {
+			value, apiErr := queryID(c, param.name)
+			if apiErr != nil {
+				response.JSON(c, "", apiErr.Status, nil, apiErr)
+				return
+			}
+			*param.id = value
+		}
+
+		orders, total, apiErr := s.PurchaseService.ListOrders(filter)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Purchase orders retrieved successfully", http.StatusOK, gin.H{
+			"purchase_orders": orders,
+			"total":           total,
+			"page":            page,
+			"limit":           limit,
+		}, nil)
+	}
+}
+
+// handleListAwaitingApproval lists the purchase orders waiting for the approval of the signed in user
+func (s *Server) handleListAwaitingApproval() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+
+		orders, apiErr := s.PurchaseService.ListAwaitingApproval(userID, c.GetString("user_role"))
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Purchase orders retrieved successfully", http.StatusOK, orders, nil)
+	}
+}
+
+func (s *Server) handleSubmitPurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		order, apiErr := s.PurchaseService.SubmitOrder(id, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Purchase order submitted successfully", http.StatusOK, order, nil)
+	}
+}
+
+func (s *Server) handleWithdrawPurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		order, apiErr := s.PurchaseService.WithdrawOrder(id, userID, c.GetString("user_role"))
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Purchase order withdrawn successfully", http.StatusOK, order, nil)
+	}
+}
+
+// handleDecidePurchaseOrder approves or rejects the pending level of approval of a purchase order
+func (s *Server) handleDecidePurchaseOrder(decision models.ApprovalDecision) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.PurchaseDecisionRequest
+		if c.Request.ContentLength != 0 {
+			if err := decode(c, &request); err != nil {
+				response.JSON(c, "", http.StatusBadRequest, nil, err)
+				return
+			}
+		}
+
+		order, apiErr := s.PurchaseService.DecideOrder(id, decision, &request, userID, c.GetString("user_role"))
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Purchase order "+string(decision)+" successfully", http.StatusOK, order, nil)
+	}
+}
+
+func (s *Server) handlePlacePurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		order, apiErr := s.PurchaseService.PlaceOrder(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Purchase order ordered successfully", http.StatusOK, order, nil)
+	}
+}
+
+func (s *Server) handleClosePurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.PurchaseOrderCloseRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		order, apiErr := s.PurchaseService.CloseOrder(id, &request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Purchase order closed successfully", http.StatusOK, order, nil)
+	}
+}
+
+func (s *Server) handleReceiveGoods() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.GoodsReceiptRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		receipt, apiErr := s.PurchaseService.ReceiveGoods(id, &request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Goods received successfully", http.StatusCreated, receipt, nil)
+	}
+}
+
+func (s *Server) handleListGoodsReceipts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		receipts, apiErr := s.PurchaseService.ListReceipts(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Goods receipts retrieved successfully", http.StatusOK, receipts, nil)
+	}
+}
+
+func (s *Server) handleRecordVendorBill() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.VendorBillRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		bill, apiErr := s.PurchaseService.RecordBill(id, &request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Bill recorded successfully", http.StatusCreated, bill, nil)
+	}
+}
+
+func (s *Server) handleListVendorBills() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		bills, apiErr := s.PurchaseService.ListBills(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Bills retrieved successfully", http.StatusOK, bills, nil)
+	}
+}
+
+func (s *Server) handleGetVendorBill() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		bill, apiErr := s.PurchaseService.GetBill(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Bill retrieved successfully", http.StatusOK, bill, nil)
+	}
+}
+
+// handleReviewVendorBill approves or rejects a bill held as an exception
+func (s *Server) handleReviewVendorBill(status models.VendorBillStatus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.VendorBillReviewRequest
+		if c.Request.ContentLength != 0 {
+			if err := decode(c, &request); err != nil {
+				response.JSON(c, "", http.StatusBadRequest, nil, err)
+				return
+			}
+		}
+
+		bill, apiErr := s.PurchaseService.ReviewBill(id, status, &request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Bill "+string(status)+" successfully", http.StatusOK, bill, nil)
+	}
+}
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+// handleCreateReleasePlan schedules the release of a title, e.g. POST /titles/full_length/7/release-plans
+func (s *Server) handleCreateReleasePlan() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.ReleasePlanRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		plan, apiErr := s.ReleaseService.CreatePlan(models.TitleType(c.Param("type")), titleID, &request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Release plan created successfully", http.StatusCreated, plan, nil)
+	}
+}
+
+func (s *Server) handleListReleasePlans() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		plans, apiErr := s.ReleaseService.ListTitlePlans(models.TitleType(c.Param("type")), titleID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Release plans retrieved successfully", http.StatusOK, plans, nil)
+	}
+}
+
+func (s *Server) handleGetReleasePlan() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		plan, apiErr := s.ReleaseService.GetPlan(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Release plan retrieved successfully", http.StatusOK, plan, nil)
+	}
+}
+
+// handleUpdateReleasePlan replaces a release plan and its milestones
+func (s *Server) handleUpdateReleasePlan() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.ReleasePlanRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		plan, apiErr := s.ReleaseService.UpdatePlan(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Release plan updated successfully", http.StatusOK, plan, nil)
+	}
+}
+
+func (s *Server) handleDeleteReleasePlan() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.ReleaseService.DeletePlan(id); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Release plan deleted successfully", http.StatusOK, nil, nil)
+	}
+}
+
+func (s *Server) handleCreateFestival() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.FestivalRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		festival, apiErr := s.ReleaseService.CreateFestival(&request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Festival created successfully", http.StatusCreated, festival, nil)
+	}
+}
+
+func (s *Server) handleUpdateFestival() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.FestivalRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		festival, apiErr := s.ReleaseService.UpdateFestival(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Festival updated successfully", http.StatusOK, festival, nil)
+	}
+}
+
+func (s *Server) handleGetFestival() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		festival, apiErr := s.ReleaseService.GetFestival(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Festival retrieved successfully", http.StatusOK, festival, nil)
+	}
+}
+
+// handleListFestivals looks festivals up by name and edition, e.g. GET /festivals?q=cannes&edition=2025
+func (s *Server) handleListFestivals() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit := pagination(c)
+		edition, _ := strconv.Atoi(c.Query("edition"))
+		festivals, total, apiErr := s.ReleaseService.ListFestivals(c.Query("q"), edition, page, limit)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Festivals retrieved successfully", http.StatusOK, gin.H{
+			"festivals": festivals,
+			"total":     total,
+			"page":      page,
+			"limit":     limit,
+		}, nil)
+	}
+}
+
+// handleCreateSubmission plans the entry of a title in a festival
+func (s *Server) handleCreateSubmission() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		var request models.FestivalSubmissionRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		submission, apiErr := s.ReleaseService.CreateSubmission(&request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Festival submission created successfully", http.StatusCreated, submission, nil)
+	}
+}
+
+// handleChangeSubmissionStatus records a submission being sent, accepted, rejected or withdrawn
+func (s *Server) handleChangeSubmissionStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.SubmissionStatusRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		submission, apiErr := s.ReleaseService.ChangeSubmissionStatus(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Festival submission updated successfully", http.StatusOK, submission, nil)
+	}
+}
+
+func (s *Server) handleGetSubmission() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		submission, apiErr := s.ReleaseService.GetSubmission(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Festival submission retrieved successfully", http.StatusOK, submission, nil)
+	}
+}
+
+// handleListSubmissions lists festival submissions, e.g. GET /festival-submissions?festival_id=4&status=submitted
+// or ?title_type=full_length&title_id=7
+func (s *Server) handleListSubmissions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit := pagination(c)
+		filter := &models.SubmissionFilter{
+			Status: models.SubmissionStatus(c.Query("status")),
+			Page:   page,
+			Limit:  limit,
+		}
+		if filter.Status != "" && !filter.Status.IsValid() {
+			apiErr := errs.New("status must be planned, submitted, accepted, rejected or withdrawn", http.StatusBadRequest)
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		if value := c.Query("festival_id"); value != "" {
+			festivalID, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				apiErr := errs.New("festival_id must be a number", http.StatusBadRequest)
+				response.JSON(c, "", apiErr.Status, nil, apiErr)
+				return
+			}
+			filter.FestivalID = uint(festivalID)
+		}
+		titleType, titleID, apiErr := queryTitle(c)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		filter.TitleType, filter.TitleID = titleType, titleID
+
+		submissions, total, apiErr := s.ReleaseService.ListSubmissions(filter)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Festival submissions retrieved successfully", http.StatusOK, gin.H{
+			"submissions": submissions,
+			"total":       total,
+			"page":        page,
+			"limit":       limit,
+		}, nil)
+	}
+}
+
+// queryTitle reads the optional title_type and title_id query parameters, which go together
+func queryTitle(c *gin.Context) (models.TitleType, uint, *errs.Error) {
+	titleType := models.TitleType(c.Query("title_type"))
+	if titleType == "" && c.Query("title_id") == "" {
+		return "", 0, nil
+	}
+	if !titleType.IsValid() {
+		return "", 0, errs.New("title_type must be trailer or full_length", http.StatusBadRequest)
+	}
+	titleID, err := strconv.ParseUint(c.Query("title_id"), 10, 64)
+	if err != nil {
+		return "", 0, errs.New("title_id must be a number", http.StatusBadRequest)
+	}
+	return titleType, uint(titleID), nil
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+	"github.com/techagentng/telair-erp/services"
+)
+
+func (s *Server) handleGetTitle() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		record, apiErr := s.RevisionService.GetTitle(models.TitleType(c.Param("type")), titleID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		setETag(c, record.Version)
+		response.JSON(c, "Title retrieved successfully", http.StatusOK, record, nil)
+	}
+}
+
+// currentTitle reads the title a stale write was aimed at
+func (s *Server) currentTitle(titleType models.TitleType, titleID uint) func() (interface{}, int, *errs.Error) {
+	return func() (interface{}, int, *errs.Error) {
+		record, apiErr := s.RevisionService.GetTitle(titleType, titleID)
+		if apiErr != nil {
+			return nil, 0, apiErr
+		}
+		return record, record.Version, nil
+	}
+}
+
+// handleUpdateTitle edits the metadata of a title, e.g. PATCH /titles/trailer/42 {"log_line": "..."}
+// with the ETag of the title in If-Match
+func (s *Server) handleUpdateTitle() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		version, apiErr := ifMatch(c)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.TitleUpdateRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+		userID, _ := getUserIDFromContext(c)
+		titleType := models.TitleType(c.Param("type"))
+
+		revision, version, apiErr := s.RevisionService.UpdateTitle(titleType, titleID, userID, version, &request)
+		if apiErr != nil {
+			respondWriteError(c, apiErr, s.currentTitle(titleType, titleID))
+			return
+		}
+		if revision == nil {
+			setETag(c, version)
+			response.JSON(c, "Nothing to update", http.StatusOK, nil, nil)
+			return
+		}
+		setETag(c, version)
+		response.JSON(c, "Title updated successfully", http.StatusOK, revision, nil)
+	}
+}
+
+func (s *Server) handleListRevisions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		revisions, apiErr := s.RevisionService.ListRevisions(models.TitleType(c.Param("type")), titleID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Revisions retrieved successfully", http.StatusOK, revisions, nil)
+	}
+}
+
+// handleDiffRevisions compares two revisions, e.g. GET /titles/trailer/42/revisions/diff?from=2&to=5
+func (s *Server) handleDiffRevisions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		from, errFrom := strconv.Atoi(c.Query("from"))
+		to, errTo := strconv.Atoi(c.Query("to"))
+		if errFrom != nil || errTo != nil {
+			apiErr := errs.New("from and to must be revision numbers", http.StatusBadRequest)
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		changes, apiErr := s.RevisionService.Diff(models.TitleType(c.Param("type")), titleID, from, to)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Diff retrieved successfully", http.StatusOK, gin.H{
+			"from":    from,
+			"to":      to,
+			"changes": changes,
+		}, nil)
+	}
+}
+
+// handleRevertRevision brings back the metadata of a revision, with the ETag of the title in If-Match
+func (s *Server) handleRevertRevision() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		number, apiErr := paramID(c, "number")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		version, apiErr := ifMatch(c)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		userID, _ := getUserIDFromContext(c)
+		titleType := models.TitleType(c.Param("type"))
+
+		revision, version, apiErr := s.RevisionService.Revert(titleType, titleID, int(number), userID, version)
+		if apiErr != nil {
+			respondWriteError(c, apiErr, s.currentTitle(titleType, titleID))
+			return
+		}
+		if revision.Status == models.RevisionPending {
+			setETag(c, version)
+			response.JSON(c, "Revert is waiting for approval", http.StatusAccepted, revision, nil)
+			return
+		}
+		setETag(c, version)
+		response.JSON(c, "Title reverted successfully", http.StatusOK, revision, nil)
+	}
+}
+
+// revisionAction handles the reviews of a revision waiting for approval
+func (s *Server) revisionAction(message string, action func(services.RevisionService, models.TitleType, uint, int, uint) (*models.Revision, *errs.Error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		number, apiErr := paramID(c, "number")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		userID, _ := getUserIDFromContext(c)
+
+		revision, apiErr := action(s.RevisionService, models.TitleType(c.Param("type")), titleID, int(number), userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, message, http.StatusOK, revision, nil)
+	}
+}
+
+func (s *Server) handleApproveRevision() gin.HandlerFunc {
+	return s.revisionAction("Revision approved successfully", services.RevisionService.Approve)
+}
+
+func (s *Server) handleRejectRevision() gin.HandlerFunc {
+	return s.revisionAction("Revision rejected", services.RevisionService.Reject)
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+// nextQuarter returns the first and last day of the calendar quarter after the one t falls in
+func nextQuarter(t time.Time) (time.Time, time.Time) {
+	firstMonth := time.Month((int(t.Month())-1)/3*3 + 1)
+	from := time.Date(t.Year(), firstMonth, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 3, 0)
+	return from, from.AddDate(0, 3, -1)
+}
+
+// queryDate reads a date query parameter, returning fallback when it is missing
+func queryDate(c *gin.Context, name string, fallback time.Time) (time.Time, *errs.Error) {
+	value := c.Query(name)
+	if value == "" {
+		return fallback, nil
+	}
+	date, err := time.Parse(models.DateLayout, value)
+	if err != nil {
+		return time.Time{}, errs.New(name+" must be a date such as 2025-01-31", http.StatusBadRequest)
+	}
+	return date, nil
+}
+
+func (s *Server) handleCreateLicensee() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.LicenseeRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		licensee, apiErr := s.RightsService.CreateLicensee(&request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Licensee created successfully", http.StatusCreated, licensee, nil)
+	}
+}
+
+func (s *Server) handleUpdateLicensee() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.LicenseeRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		licensee, apiErr := s.RightsService.UpdateLicensee(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Licensee updated successfully", http.StatusOK, licensee, nil)
+	}
+}
+
+func (s *Server) handleGetLicensee() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		licensee, apiErr := s.RightsService.GetLicensee(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Licensee retrieved successfully", http.StatusOK, licensee, nil)
+	}
+}
+
+// handleListLicensees looks licensees up by name, e.g. GET /licensees?q=net&page=2
+func (s *Server) handleListLicensees() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit := pagination(c)
+		licensees, total, apiErr := s.RightsService.ListLicensees(c.Query("q"), page, limit)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Licensees retrieved successfully", http.StatusOK, gin.H{
+			"licensees": licensees,
+			"total":     total,
+			"page":      page,
+			"limit":     limit,
+		}, nil)
+	}
+}
+
+// respondGrant answers a grant being saved, listing the grants in the way when it clashed with them
+func respondGrant(c *gin.Context, message string, status int, grant *models.RightsGrant, conflicts []models.RightsConflict, apiErr *errs.Error) {
+	if len(conflicts) > 0 {
+		response.JSON(c, "", apiErr.Status, gin.H{"conflicts": conflicts}, apiErr)
+		return
+	}
+	if apiErr != nil {
+		response.JSON(c, "", apiErr.Status, nil, apiErr)
+		return
+	}
+	response.JSON(c, message, status, grant, nil)
+}
+
+// handleGrantRights licenses a title, e.g. POST /titles/full_length/7/rights
+// {"licensee_id": 3, "territories": ["NG", "GH"], "media": ["svod"], "exclusive": true, "start_date": "2025-01-01", "end_date": "2026-12-31"}
+func (s *Server) handleGrantRights() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.RightsGrantRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		grant, conflicts, apiErr := s.RightsService.GrantRights(models.TitleType(c.Param("type")), titleID, &request)
+		respondGrant(c, "Rights granted successfully", http.StatusCreated, grant, conflicts, apiErr)
+	}
+}
+
+func (s *Server) handleUpdateGrant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.RightsGrantRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		grant, conflicts, apiErr := s.RightsService.UpdateGrant(id, &request)
+		respondGrant(c, "Grant updated successfully", http.StatusOK, grant, conflicts, apiErr)
+	}
+}
+
+func (s *Server) handleGetGrant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		grant, apiErr := s.RightsService.GetGrant(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Grant retrieved successfully", http.StatusOK, grant, nil)
+	}
+}
+
+func (s *Server) handleListTitleGrants() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		grants, apiErr := s.RightsService.ListTitleGrants(models.TitleType(c.Param("type")), titleID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Grants retrieved successfully", http.StatusOK, grants, nil)
+	}
+}
+
+func (s *Server) handleRevokeGrant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.RightsService.RevokeGrant(id); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Grant revoked successfully", http.StatusOK, nil, nil)
+	}
+}
+
+// handleRightsAvailability lists the titles that can still be licensed, e.g. what is free in NG for SVOD
+// next quarter: GET /rights/availability?territory=NG&media=svod. The period defaults to the next quarter,
+// from and to narrow it down to other dates.
+func (s *Server) handleRightsAvailability() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		from, to := nextQuarter(time.Now())
+		var apiErr *errs.Error
+		if from, apiErr = queryDate(c, "from", from); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		if to, apiErr = queryDate(c, "to", to); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		page, limit := pagination(c)
+		query := &models.AvailabilityQuery{
+			Territory: c.Query("territory"),
+			Media:     models.MediaRight(c.Query("media")),
+			From:      from,
+			To:        to,
+			Page:      page,
+			Limit:     limit,
+		}
+		titles, total, apiErr := s.RightsService.Availability(query)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Availability retrieved successfully", http.StatusOK, gin.H{
+			"titles":    titles,
+			"territory": query.Territory,
+			"media":     query.Media,
+			"from":      from.Format(models.DateLayout),
+			"to":        to.Format(models.DateLayout),
+			"total":     total,
+			"page":      page,
+			"limit":     limit,
+		}, nil)
+	}
+}
+
+// handleExpiringGrants lists the grants ending soon, e.g. GET /rights/expiring?days=90
+func (s *Server) handleExpiringGrants() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		days, _ := strconv.Atoi(c.Query("days"))
+		grants, apiErr := s.RightsService.ExpiringGrants(days)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Expiring grants retrieved successfully", http.StatusOK, grants, nil)
+	}
+}
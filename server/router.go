@@ -12,6 +12,7 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/techagentng/telair-erp/models"
 )
 
 func (s *Server) setupRouter() *gin.Engine {
@@ -64,8 +65,9 @@ func (s *Server) setupRouter() *gin.Engine {
 	// Use CORS middleware with appropriate configuration
 	r.Use(cors.New(cors.Config{
 		AllowAllOrigins:     true, 
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "HEAD", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type", "Tus-Resumable", "Upload-Length", "Upload-Metadata", "Upload-Offset", "Upload-Session-ID", "If-Match", "If-None-Match"},
+		ExposeHeaders:    []string{"Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Upload-Offset", "Upload-Length", "Upload-Expires", "Upload-Session-ID", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -83,13 +85,297 @@ func (s *Server) defineRoutes(router *gin.Engine) {
     apirouter.POST("/auth/signup", s.handleSignup())
     apirouter.POST("/auth/login", s.handleLogin())
 
+    // Calendar apps cannot log in, the secret token of the feed stands for it
+    apirouter.GET("/calendar/ics/:token", s.handleCalendarFeed())
+
     // Define the authorized group and apply the Authorize middleware
     authorized := apirouter.Group("/")
     authorized.Use(s.Authorize()) 
 
     // Define routes within the authorized group
     authorized.POST("/upload-trailer", s.handleUploadTrailer())
+    authorized.POST("/upload/sessions", s.handleCreateUploadSession())
     authorized.GET("/upload/progress/:sessionID", s.getUploadProgress())
+    authorized.GET("/upload/progress/:sessionID/events", s.handleUploadProgressEvents())
+
+    // Direct to storage uploads through presigned requests
+    authorized.POST("/uploads/presign", s.handleCreateDirectUpload())
+    authorized.POST("/uploads/presign/:id/parts", s.handlePresignUploadPart())
+    authorized.POST("/uploads/presign/:id/complete", s.handleCompleteDirectUpload())
+    authorized.GET("/media/:id/download-url", s.handleMediaDownloadURL())
+
+    // Profile of the signed in user
+    authorized.GET("/profile", s.handleShowProfile())
+    authorized.PUT("/profile", s.handleEditUserProfile())
+    authorized.GET("/profile/tasks", s.handleMyTasks())
+
+    // Cast and crew
+    authorized.GET("/people", s.handleSearchPeople())
+    authorized.POST("/people", s.handleCreatePerson())
+    authorized.GET("/people/:id", s.handleGetPerson())
+    authorized.PUT("/people/:id", s.handleUpdatePerson())
+    authorized.GET("/people/:id/filmography", s.handleGetFilmography())
+    authorized.GET("/titles/:type/:id/credits", s.handleGetTitleCredits())
+    authorized.POST("/titles/:type/:id/credits", s.handleAddCredit())
+    authorized.DELETE("/credits/:id", s.handleRemoveCredit())
+
+    // Title metadata, every edit is kept as a revision
+    authorized.GET("/titles/:type/:id", s.handleGetTitle())
+    authorized.PATCH("/titles/:type/:id", s.handleUpdateTitle())
+    authorized.GET("/titles/:type/:id/revisions", s.handleListRevisions())
+    authorized.GET("/titles/:type/:id/revisions/diff", s.handleDiffRevisions())
+    authorized.POST("/titles/:type/:id/revisions/:number/revert", s.handleRevertRevision())
+
+    // Subtitles, converted and retimed on the way out
+    authorized.GET("/titles/:type/:id/subtitles", s.handleListSubtitles())
+    authorized.POST("/titles/:type/:id/subtitles", s.handleUploadSubtitle())
+    authorized.GET("/subtitles/:id/download", s.handleDownloadSubtitle())
+    authorized.POST("/subtitles/:id/shift", s.handleShiftSubtitle())
+    authorized.DELETE("/subtitles/:id", s.handleDeleteSubtitle())
+
+    // Distribution rights, granted by admins
+    authorized.GET("/licensees", s.handleListLicensees())
+    authorized.GET("/licensees/:id", s.handleGetLicensee())
+    authorized.GET("/titles/:type/:id/rights", s.handleListTitleGrants())
+    authorized.GET("/rights/grants/:id", s.handleGetGrant())
+    authorized.GET("/rights/availability", s.handleRightsAvailability())
+    authorized.GET("/rights/expiring", s.handleExpiringGrants())
+
+    // Licensing contracts and their royalty statements
+    authorized.GET("/contracts", s.handleListContracts())
+    authorized.GET("/contracts/:id", s.handleGetContract())
+    authorized.GET("/contracts/:id/statements", s.handleListStatements())
+    authorized.GET("/statements/:id", s.handleGetStatement())
+    authorized.GET("/statements/:id/pdf", s.handleStatementPDF())
+    authorized.GET("/revenue-reports", s.handleListRevenueReports())
+    authorized.GET("/revenue-reports/:id", s.handleGetRevenueReport())
+
+    // Production budgets and the actual costs booked against them
+    authorized.GET("/titles/:type/:id/budget", s.handleGetTitleBudget())
+    authorized.POST("/titles/:type/:id/budget", s.handleCreateBudget())
+    authorized.GET("/budgets/:id", s.handleGetBudget())
+    authorized.PUT("/budgets/:id", s.handleUpdateBudget())
+    authorized.GET("/budgets/:id/variance", s.handleBudgetVariance())
+    authorized.GET("/budgets/:id/costs", s.handleListCosts())
+    authorized.POST("/budgets/:id/costs", s.handleRecordCost())
+    authorized.GET("/costs/:id", s.handleGetCost())
+    authorized.POST("/costs/:id/receipt", s.handleUploadReceipt())
+    authorized.GET("/costs/:id/receipt-url", s.handleReceiptURL())
+    authorized.GET("/vendors", s.handleListVendors())
+    authorized.GET("/vendors/:id", s.handleGetVendor())
+    authorized.GET("/exchange-rates", s.handleListExchangeRates())
+
+    // Release plans, festival submissions and the calendar they make up
+    authorized.GET("/titles/:type/:id/release-plans", s.handleListReleasePlans())
+    authorized.POST("/titles/:type/:id/release-plans", s.handleCreateReleasePlan())
+    authorized.GET("/release-plans/:id", s.handleGetReleasePlan())
+    authorized.PUT("/release-plans/:id", s.handleUpdateReleasePlan())
+    authorized.DELETE("/release-plans/:id", s.handleDeleteReleasePlan())
+    authorized.GET("/festivals", s.handleListFestivals())
+    authorized.GET("/festivals/:id", s.handleGetFestival())
+    authorized.POST("/festivals", s.handleCreateFestival())
+    authorized.PUT("/festivals/:id", s.handleUpdateFestival())
+    authorized.GET("/festival-submissions", s.handleListSubmissions())
+    authorized.POST("/festival-submissions", s.handleCreateSubmission())
+    authorized.GET("/festival-submissions/:id", s.handleGetSubmission())
+    authorized.POST("/festival-submissions/:id/status", s.handleChangeSubmissionStatus())
+    authorized.GET("/calendar", s.handleCalendar())
+    authorized.GET("/calendar/feeds", s.handleListCalendarFeeds())
+    authorized.POST("/calendar/feeds", s.handleCreateCalendarFeed())
+    authorized.DELETE("/calendar/feeds/:id", s.handleDeleteCalendarFeed())
+
+    // Electronic press kits
+    authorized.GET("/titles/:type/:id/press-kit", s.handleDownloadPressKit())
+    authorized.GET("/titles/:type/:id/press-kit/manifest", s.handleGetPressKitManifest())
+    authorized.GET("/titles/:type/:id/press-kit/assets", s.handleListPressKitAssets())
+    authorized.PUT("/titles/:type/:id/press-kit/assets", s.handleSetPressKitAssets())
+
+    // CRM contacts and organizations, deleting and merging is left to admins
+    authorized.GET("/contacts", s.handleListContacts())
+    authorized.POST("/contacts", s.handleCreateContact())
+    authorized.GET("/contacts/duplicates", s.handleContactDuplicates())
+    authorized.GET("/contacts/:id", s.handleGetContact())
+    authorized.PUT("/contacts/:id", s.handleUpdateContact())
+    authorized.PUT("/contacts/:id/organizations/:organizationID", s.handleLinkContact())
+    authorized.DELETE("/contacts/:id/organizations/:organizationID", s.handleUnlinkContact())
+    authorized.GET("/organizations", s.handleListOrganizations())
+    authorized.POST("/organizations", s.handleCreateOrganization())
+    authorized.GET("/organizations/duplicates", s.handleOrganizationDuplicates())
+    authorized.GET("/organizations/:id", s.handleGetOrganization())
+    authorized.PUT("/organizations/:id", s.handleUpdateOrganization())
+
+    // Sales pipelines and deals, pipelines are set up by admins
+    authorized.GET("/pipelines", s.handleListPipelines())
+    authorized.GET("/pipelines/:id", s.handleGetPipeline())
+    authorized.GET("/pipelines/:id/reports/value", s.handlePipelineValue())
+    authorized.GET("/pipelines/:id/reports/conversion", s.handlePipelineConversion())
+    authorized.GET("/pipelines/:id/reports/stage-time", s.handlePipelineStageTime())
+    authorized.GET("/deals", s.handleListDeals())
+    authorized.POST("/deals", s.handleCreateDeal())
+    authorized.GET("/deals/:id", s.handleGetDeal())
+    authorized.PUT("/deals/:id", s.handleUpdateDeal())
+    authorized.POST("/deals/:id/stage", s.handleMoveDeal())
+    authorized.POST("/deals/:id/close", s.handleCloseDeal())
+    authorized.POST("/deals/:id/reopen", s.handleReopenDeal())
+
+    // Notes, calls, meetings, emails and tasks, and the timelines they make up with the history of the record
+    authorized.GET("/contacts/:id/activities", s.handleListActivities(pathSubject(models.PartyContact)))
+    authorized.POST("/contacts/:id/activities", s.handleCreateActivity(pathSubject(models.PartyContact)))
+    authorized.GET("/contacts/:id/timeline", s.handleTimeline(pathSubject(models.PartyContact)))
+    authorized.GET("/organizations/:id/activities", s.handleListActivities(pathSubject(models.PartyOrganization)))
+    authorized.POST("/organizations/:id/activities", s.handleCreateActivity(pathSubject(models.PartyOrganization)))
+    authorized.GET("/organizations/:id/timeline", s.handleTimeline(pathSubject(models.PartyOrganization)))
+    authorized.GET("/deals/:id/activities", s.handleListActivities(pathSubject(models.SubjectDeal)))
+    authorized.POST("/deals/:id/activities", s.handleCreateActivity(pathSubject(models.SubjectDeal)))
+    authorized.GET("/deals/:id/timeline", s.handleTimeline(pathSubject(models.SubjectDeal)))
+    authorized.GET("/titles/:type/:id/activities", s.handleListActivities(titleSubject))
+    authorized.POST("/titles/:type/:id/activities", s.handleCreateActivity(titleSubject))
+    authorized.GET("/titles/:type/:id/timeline", s.handleTimeline(titleSubject))
+    authorized.GET("/activities/:id", s.handleGetActivity())
+    authorized.PUT("/activities/:id", s.handleUpdateActivity())
+    authorized.DELETE("/activities/:id", s.handleDeleteActivity())
+    authorized.POST("/activities/:id/complete", s.handleCompleteTask())
+    authorized.POST("/activities/:id/reopen", s.handleReopenTask())
+
+    // Invoices and the payments received against them
+    authorized.GET("/tax-rates", s.handleListTaxRates())
+    authorized.GET("/invoices", s.handleListInvoices())
+    authorized.POST("/invoices", s.handleCreateInvoice())
+    authorized.GET("/invoices/reports/aging", s.handleInvoiceAging())
+    authorized.GET("/invoices/:id", s.handleGetInvoice())
+    authorized.PUT("/invoices/:id", s.handleUpdateInvoice())
+    authorized.DELETE("/invoices/:id", s.handleDeleteInvoice())
+    authorized.GET("/invoices/:id/pdf", s.handleInvoicePDF())
+    authorized.POST("/invoices/:id/send", s.handleSendInvoice())
+    authorized.POST("/invoices/:id/payments", s.handleRecordInvoicePayment())
+
+    // General ledger, books and reports
+    authorized.GET("/ledger/accounts", s.handleListAccounts())
+    authorized.GET("/ledger/entries", s.handleListJournalEntries())
+    authorized.GET("/ledger/entries/:id", s.handleGetJournalEntry())
+    authorized.GET("/ledger/periods", s.handleListPeriods())
+    authorized.GET("/ledger/reports/trial-balance", s.handleTrialBalance())
+    authorized.GET("/ledger/reports/profit-and-loss", s.handleProfitAndLoss())
+    authorized.GET("/ledger/reports/balance-sheet", s.handleBalanceSheet())
+
+    // Purchase orders, their approvals, the goods received against them and the vendor bills matched to both
+    authorized.GET("/purchase-orders", s.handleListPurchaseOrders())
+    authorized.POST("/purchase-orders", s.handleCreatePurchaseOrder())
+    authorized.GET("/purchase-orders/approval-levels", s.handleListApprovalLevels())
+    authorized.GET("/purchase-orders/awaiting-approval", s.handleListAwaitingApproval())
+    authorized.GET("/purchase-orders/:id", s.handleGetPurchaseOrder())
+    authorized.PUT("/purchase-orders/:id", s.handleUpdatePurchaseOrder())
+    authorized.DELETE("/purchase-orders/:id", s.handleDeletePurchaseOrder())
+    authorized.POST("/purchase-orders/:id/submit", s.handleSubmitPurchaseOrder())
+    authorized.POST("/purchase-orders/:id/withdraw", s.handleWithdrawPurchaseOrder())
+    authorized.POST("/purchase-orders/:id/approve", s.handleDecidePurchaseOrder(models.ApprovalApproved))
+    authorized.POST("/purchase-orders/:id/reject", s.handleDecidePurchaseOrder(models.ApprovalRejected))
+    authorized.POST("/purchase-orders/:id/order", s.handlePlacePurchaseOrder())
+    authorized.GET("/purchase-orders/:id/receipts", s.handleListGoodsReceipts())
+    authorized.POST("/purchase-orders/:id/receipts", s.handleReceiveGoods())
+    authorized.GET("/purchase-orders/:id/bills", s.handleListVendorBills())
+    authorized.POST("/purchase-orders/:id/bills", s.handleRecordVendorBill())
+    authorized.GET("/vendor-bills/:id", s.handleGetVendorBill())
+
+    // Catalogue search
+    authorized.GET("/search", s.handleSearch())
+
+    // Catalogue taxonomy, the lookup lists are managed by admins
+    authorized.GET("/titles/:type", s.handleListTitles())
+    authorized.GET("/titles/:type/:id/terms", s.handleGetTitleTerms())
+    authorized.PUT("/titles/:type/:id/terms", s.handleSetTitleTerms())
+    authorized.GET("/taxonomy/:kind", s.handleListTerms())
+    admin := authorized.Group("/")
+    admin.Use(s.RequireRole(models.RoleAdmin))
+    admin.POST("/taxonomy/:kind", s.handleCreateTerm())
+    admin.PUT("/taxonomy/terms/:id", s.handleUpdateTerm())
+    admin.DELETE("/taxonomy/terms/:id", s.handleDeleteTerm())
+    admin.POST("/taxonomy/terms/:id/merge", s.handleMergeTerms())
+
+    // Reverts of approved titles wait for an admin
+    admin.POST("/titles/:type/:id/revisions/:number/approve", s.handleApproveRevision())
+    admin.POST("/titles/:type/:id/revisions/:number/reject", s.handleRejectRevision())
+
+    // Trash, deleted records can be restored until they are purged
+    admin.DELETE("/titles/:type/:id", s.handleDeleteTitle())
+    admin.DELETE("/users/:id", s.handleDeleteUser())
+    admin.GET("/trash/:type", s.handleListTrash())
+    admin.POST("/trash/:type/:id/restore", s.handleRestore())
+
+    // Distribution rights
+    admin.POST("/licensees", s.handleCreateLicensee())
+    admin.PUT("/licensees/:id", s.handleUpdateLicensee())
+    admin.POST("/titles/:type/:id/rights", s.handleGrantRights())
+    admin.PUT("/rights/grants/:id", s.handleUpdateGrant())
+    admin.DELETE("/rights/grants/:id", s.handleRevokeGrant())
+
+    // Licensing contracts, revenue reports and royalty statements
+    admin.POST("/contracts", s.handleCreateContract())
+    admin.PUT("/contracts/:id", s.handleUpdateContract())
+    admin.POST("/contracts/:id/status", s.handleChangeContractStatus())
+    admin.POST("/contracts/:id/payments/:paymentID/paid", s.handleMarkContractPaymentPaid())
+    admin.POST("/contracts/:id/statements", s.handleGenerateStatement())
+    admin.POST("/revenue-reports", s.handleUploadRevenueReport())
+
+    // Budget approvals, vendors and exchange rates
+    admin.POST("/budgets/:id/approve", s.handleApproveBudget())
+    admin.POST("/budgets/:id/reopen", s.handleReopenBudget())
+    admin.POST("/costs/:id/approve", s.handleReviewCost(models.CostApproved))
+    admin.POST("/costs/:id/reject", s.handleReviewCost(models.CostRejected))
+    admin.DELETE("/costs/:id", s.handleDeleteCost())
+    admin.POST("/vendors", s.handleCreateVendor())
+    admin.PUT("/vendors/:id", s.handleUpdateVendor())
+    admin.POST("/exchange-rates", s.handleSaveExchangeRate())
+
+    // CRM clean-up
+    admin.DELETE("/contacts/:id", s.handleDeleteContact())
+    admin.POST("/contacts/:id/merge", s.handleMergeContacts())
+    admin.DELETE("/organizations/:id", s.handleDeleteOrganization())
+    admin.POST("/organizations/:id/merge", s.handleMergeOrganizations())
+
+    // Sales pipeline set-up
+    admin.POST("/pipelines", s.handleCreatePipeline())
+    admin.PUT("/pipelines/:id", s.handleUpdatePipeline())
+    admin.DELETE("/pipelines/:id", s.handleDeletePipeline())
+    admin.DELETE("/deals/:id", s.handleDeleteDeal())
+
+    // Invoicing set-up and corrections
+    admin.POST("/tax-rates", s.handleCreateTaxRate())
+    admin.PUT("/tax-rates/:id", s.handleUpdateTaxRate())
+    admin.DELETE("/tax-rates/:id", s.handleDeleteTaxRate())
+    admin.POST("/invoices/:id/void", s.handleVoidInvoice())
+    admin.DELETE("/invoices/:id/payments/:paymentID", s.handleDeleteInvoicePayment())
+
+    // Chart of accounts, manual journal entries and period close
+    admin.POST("/ledger/accounts", s.handleCreateAccount())
+    admin.PUT("/ledger/accounts/:id", s.handleUpdateAccount())
+    admin.DELETE("/ledger/accounts/:id", s.handleDeleteAccount())
+    admin.POST("/ledger/entries", s.handleCreateJournalEntry())
+    admin.PUT("/ledger/entries/:id", s.handleUpdateJournalEntry())
+    admin.DELETE("/ledger/entries/:id", s.handleDeleteJournalEntry())
+    admin.POST("/ledger/entries/:id/post", s.handlePostJournalEntry())
+    admin.POST("/ledger/entries/:id/reverse", s.handleReverseJournalEntry())
+    admin.POST("/ledger/periods", s.handleCreatePeriod())
+    admin.POST("/ledger/periods/:id/close", s.handleSetPeriodStatus(models.PeriodClosed, "closed"))
+    admin.POST("/ledger/periods/:id/reopen", s.handleSetPeriodStatus(models.PeriodOpen, "reopened"))
+    admin.POST("/ledger/periods/:id/lock", s.handleSetPeriodStatus(models.PeriodLocked, "locked"))
+
+    // Purchasing approval levels, short closes and vendor bills held as exceptions
+    admin.PUT("/purchase-orders/approval-levels", s.handleSetApprovalLevels())
+    admin.POST("/purchase-orders/:id/close", s.handleClosePurchaseOrder())
+    admin.POST("/vendor-bills/:id/approve", s.handleReviewVendorBill(models.BillApproved))
+    admin.POST("/vendor-bills/:id/reject", s.handleReviewVendorBill(models.BillRejected))
+
+    // Resumable uploads (tus 1.0) for large files
+    tus := apirouter.Group("/uploads/tus")
+    tus.Use(s.TusResumable())
+    tus.OPTIONS("", s.handleTusOptions())
+    tusAuthorized := tus.Group("")
+    tusAuthorized.Use(s.Authorize())
+    tusAuthorized.POST("", s.handleTusCreate())
+    tusAuthorized.HEAD("/:id", s.handleTusHead())
+    tusAuthorized.PATCH("/:id", s.handleTusPatch())
+    tusAuthorized.DELETE("/:id", s.handleTusDelete())
 
 }
 
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+// maxRevenueReportSize is the largest revenue CSV accepted
+const maxRevenueReportSize = 20 << 20
+
+// handleUploadRevenueReport ingests the revenue of titles over a period, e.g. POST /revenue-reports with the
+// licensee_id, period_start, period_end and source fields followed by a "report" CSV file. A report with invalid lines is
+// rejected as a whole, listing them.
+func (s *Server) handleUploadRevenueReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		reader, err := c.Request.MultipartReader()
+		if err != nil {
+			apiErr := errs.New(fmt.Sprintf("failed to read multipart body: %v", err), http.StatusBadRequest)
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		fields := map[string]string{}
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				apiErr := errs.New("report file is required", http.StatusBadRequest)
+				response.JSON(c, "", apiErr.Status, nil, apiErr)
+				return
+			}
+			if err != nil {
+				apiErr := errs.New(fmt.Sprintf("failed to read multipart body: %v", err), http.StatusBadRequest)
+				response.JSON(c, "", apiErr.Status, nil, apiErr)
+				return
+			}
+
+			if part.FileName() == "" {
+				value, apiErr := readField(part)
+				part.Close()
+				if apiErr != nil {
+					response.JSON(c, "", apiErr.Status, nil, apiErr)
+					return
+				}
+				fields[part.FormName()] = value
+				continue
+			}
+			defer part.Close()
+
+			if part.FormName() != "report" {
+				apiErr := errs.New(fmt.Sprintf("unexpected file field: %s", part.FormName()), http.StatusBadRequest)
+				response.JSON(c, "", apiErr.Status, nil, apiErr)
+				return
+			}
+			if ext := filepath.Ext(part.FileName()); ext != ".csv" && ext != ".CSV" {
+				apiErr := errs.New("the revenue report must be a CSV file", http.StatusUnsupportedMediaType)
+				response.JSON(c, "", apiErr.Status, nil, apiErr)
+				return
+			}
+			data, err := io.ReadAll(io.LimitReader(part, maxRevenueReportSize+1))
+			if err != nil {
+				apiErr := errs.New(fmt.Sprintf("failed to read multipart body: %v", err), http.StatusBadRequest)
+				response.JSON(c, "", apiErr.Status, nil, apiErr)
+				return
+			}
+			if len(data) > maxRevenueReportSize {
+				apiErr := errs.New("the revenue report exceeds the maximum size of 20MB", http.StatusRequestEntityTooLarge)
+				response.JSON(c, "", apiErr.Status, nil, apiErr)
+				return
+			}
+
+			request := &models.RevenueReportRequest{
+				LicenseeID:  fields["licensee_id"],
+				PeriodStart: fields["period_start"],
+				PeriodEnd:   fields["period_end"],
+				Source:      fields["source"],
+				Filename:    filepath.Base(part.FileName()),
+			}
+			report, lineErrs, apiErr := s.RoyaltyService.IngestRevenueReport(request, userID, bytes.NewReader(data))
+			if len(lineErrs) > 0 {
+				response.JSON(c, "Revenue report was rejected", apiErr.Status, gin.H{"lines": lineErrs}, apiErr)
+				return
+			}
+			if apiErr != nil {
+				response.JSON(c, "", apiErr.Status, nil, apiErr)
+				return
+			}
+			response.JSON(c, "Revenue report ingested successfully", http.StatusCreated, gin.H{
+				"id":           report.ID,
+				"licensee_id":  report.LicenseeID,
+				"period_start": report.PeriodStart,
+				"period_end":   report.PeriodEnd,
+				"source":       report.Source,
+				"filename":     report.Filename,
+				"lines":        len(report.Lines),
+			}, nil)
+			return
+		}
+	}
+}
+
+func (s *Server) handleGetRevenueReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		report, apiErr := s.RoyaltyService.GetRevenueReport(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Revenue report retrieved successfully", http.StatusOK, report, nil)
+	}
+}
+
+func (s *Server) handleListRevenueReports() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit := pagination(c)
+		reports, total, apiErr := s.RoyaltyService.ListRevenueReports(page, limit)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Revenue reports retrieved successfully", http.StatusOK, gin.H{
+			"reports": reports,
+			"total":   total,
+			"page":    page,
+			"limit":   limit,
+		}, nil)
+	}
+}
+
+// handleGenerateStatement works out the royalties of a contract for a period, generating it again replaces it
+func (s *Server) handleGenerateStatement() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.StatementRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		statement, apiErr := s.RoyaltyService.GenerateStatement(id, &request, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Royalty statement generated successfully", http.StatusCreated, statement, nil)
+	}
+}
+
+func (s *Server) handleListStatements() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		statements, apiErr := s.RoyaltyService.ListStatements(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Royalty statements retrieved successfully", http.StatusOK, statements, nil)
+	}
+}
+
+func (s *Server) handleGetStatement() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		statement, apiErr := s.RoyaltyService.GetStatement(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Royalty statement retrieved successfully", http.StatusOK, statement, nil)
+	}
+}
+
+func (s *Server) handleStatementPDF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		statement, data, apiErr := s.RoyaltyService.StatementPDF(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		filename := fmt.Sprintf("royalty-statement-%s-%s.pdf", statement.Contract.Reference, statement.PeriodEnd.Format(models.DateLayout))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Data(http.StatusOK, "application/pdf", data)
+	}
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+// handleSearch searches titles, people and taxonomy terms, e.g.
+// GET /search?q=king+of+boys&genre=drama&year=2018&page=2
+func (s *Server) handleSearch() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit := pagination(c)
+		result, apiErr := s.SearchService.Search(&models.SearchQuery{
+			Text:   c.Query("q"),
+			Status: models.MovieStatus(c.Query("status")),
+			Genre:  c.Query("genre"),
+			Year:   c.Query("year"),
+			Page:   page,
+			Limit:  limit,
+		})
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Search results retrieved successfully", http.StatusOK, gin.H{
+			"titles": result.Titles,
+			"total":  result.Total,
+			"page":   page,
+			"limit":  limit,
+			"people": result.People,
+			"terms":  result.Terms,
+			"facets": result.Facets,
+		}, nil)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/techagentng/telair-erp/db"
 	"github.com/techagentng/telair-erp/mailingservice"
 	"github.com/techagentng/telair-erp/services"
+	"github.com/techagentng/telair-erp/storage"
 	"log"
 	"net/http"
 	"os"
@@ -19,8 +20,33 @@ type Server struct {
 	Config                   *config.Config
 	AuthRepository           db.AuthRepository
 	AuthService              services.AuthService
+	TusService               services.TusService
+	DirectUploadService      services.DirectUploadService
+	ProgressService          services.ProgressService
+	MediaService             services.MediaService
+	PeopleService            services.PeopleService
+	TaxonomyService          services.TaxonomyService
+	SearchService            services.SearchService
+	TrashService             services.TrashService
+	RevisionService          services.RevisionService
+	SubtitleService          services.SubtitleService
+	RightsService            services.RightsService
+	ContractService          services.ContractService
+	RoyaltyService           services.RoyaltyService
+	BudgetService            services.BudgetService
+	VendorService            services.VendorService
+	ExchangeRateService      services.ExchangeRateService
+	ReleaseService           services.ReleaseService
+	PressKitService          services.PressKitService
+	CRMService               services.CRMService
+	DealService              services.DealService
+	ActivityService          services.ActivityService
+	InvoiceService           services.InvoiceService
+	LedgerService            services.LedgerService
+	PurchaseService          services.PurchaseService
 	Mail                     mailingservices.Mailer
 	MovieRepository          db.MovieRepository
+	Storage                  storage.Storage
 	DB                       db.GormDB
 }
 
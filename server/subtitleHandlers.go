@@ -0,0 +1,227 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+	"github.com/techagentng/telair-erp/subtitle"
+)
+
+// parseOffset reads a timing offset such as "1.5s" or "-250ms", an empty one is no offset
+func parseOffset(value string) (time.Duration, *errs.Error) {
+	if value == "" {
+		return 0, nil
+	}
+	offset, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errs.New("offset must be a duration such as 1.5s or -250ms", http.StatusBadRequest)
+	}
+	return offset, nil
+}
+
+// handleUploadSubtitle adds the subtitles of a title in one language, e.g. POST /titles/trailer/42/subtitles
+// with the language and label fields followed by a "subtitle" file. Without a language field it is read from
+// the file name, e.g. "trailer.fr.srt". A new file for a language replaces the previous one.
+func (s *Server) handleUploadSubtitle() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		titleType := models.TitleType(c.Param("type"))
+		if !titleType.IsValid() {
+			apiErr := errs.New("title type must be trailer or full_length", http.StatusBadRequest)
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		file, fields, err := s.uploadSubtitleFile(c, titleType, titleID)
+		if fileErr, ok := err.(*errs.FileError); ok {
+			response.JSON(c, "Subtitle file was rejected", http.StatusUnprocessableEntity, gin.H{"files": errs.FileErrors{fileErr}}, fileErr)
+			return
+		}
+		if apiErr, ok := err.(*errs.Error); ok {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		if err != nil {
+			log.Printf("Subtitle Upload Error: %v", err)
+			response.JSON(c, "", http.StatusInternalServerError, nil, errs.ErrInternalServerError)
+			return
+		}
+
+		sub := &models.Subtitle{
+			TitleType: titleType,
+			TitleID:   titleID,
+			Language:  fields["language"],
+			Label:     fields["label"],
+			Format:    subtitleFormat(file.ContentType),
+			Cues:      file.Info.Cues,
+			Asset:     titleMediaAssets(titleType, titleID, userID, []*uploadedFile{file})[0],
+		}
+		sub, apiErr = s.SubtitleService.AttachSubtitle(c.Request.Context(), sub)
+		if apiErr != nil {
+			s.deleteFiles([]*uploadedFile{file})
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Subtitle uploaded successfully", http.StatusCreated, sub, nil)
+	}
+}
+
+// uploadSubtitleFile streams the subtitle file of the form to storage through the same checks as trailer
+// media, it returns the stored file and the form fields sent before it
+func (s *Server) uploadSubtitleFile(c *gin.Context, titleType models.TitleType, titleID uint) (*uploadedFile, map[string]string, error) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, nil, errs.New(fmt.Sprintf("failed to read multipart body: %v", err), http.StatusBadRequest)
+	}
+
+	fields := map[string]string{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, nil, errs.New("subtitle file is required", http.StatusBadRequest)
+		}
+		if err != nil {
+			return nil, nil, errs.New(fmt.Sprintf("failed to read multipart body: %v", err), http.StatusBadRequest)
+		}
+
+		if part.FileName() == "" {
+			value, apiErr := readField(part)
+			part.Close()
+			if apiErr != nil {
+				return nil, nil, apiErr
+			}
+			fields[part.FormName()] = value
+			continue
+		}
+		defer part.Close()
+
+		if part.FormName() != "subtitle" {
+			return nil, nil, errs.New(fmt.Sprintf("unexpected file field: %s", part.FormName()), http.StatusBadRequest)
+		}
+		if fields["language"] == "" {
+			fields["language"] = models.LanguageFromFilename(part.FileName())
+		}
+		if !models.IsLanguageTag(fields["language"]) {
+			return nil, nil, errs.New("language must be a language tag such as en or pt-BR", http.StatusBadRequest)
+		}
+
+		file, err := s.uploadPart(c.Request.Context(), part, titleType.StoragePrefix(titleID), models.MediaSubtitle)
+		if err != nil {
+			return nil, nil, err
+		}
+		file.Language = fields["language"]
+		return file, fields, nil
+	}
+}
+
+func (s *Server) handleListSubtitles() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		subtitles, apiErr := s.SubtitleService.ListSubtitles(models.TitleType(c.Param("type")), titleID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Subtitles retrieved successfully", http.StatusOK, subtitles, nil)
+	}
+}
+
+// handleDownloadSubtitle serves a subtitle file converted and retimed on the fly,
+// e.g. GET /subtitles/7/download?format=vtt&offset=-1.5s
+func (s *Server) handleDownloadSubtitle() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		offset, apiErr := parseOffset(c.Query("offset"))
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		format := subtitle.Format(c.Query("format"))
+		sub, data, apiErr := s.SubtitleService.RenderSubtitle(c.Request.Context(), id, format, offset)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		if format == "" {
+			format = subtitle.Format(sub.Format)
+		}
+		filename := fmt.Sprintf("%s-%d.%s.%s", sub.TitleType, sub.TitleID, sub.Language, format)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Data(http.StatusOK, format.ContentType()+"; charset=utf-8", data)
+	}
+}
+
+// handleShiftSubtitle moves every cue of a subtitle for good, e.g. POST /subtitles/7/shift {"offset": "2s"}
+func (s *Server) handleShiftSubtitle() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.SubtitleShiftRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+		offset, apiErr := parseOffset(request.Offset)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		sub, apiErr := s.SubtitleService.ShiftSubtitle(c.Request.Context(), id, userID, offset)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Subtitle shifted successfully", http.StatusOK, sub, nil)
+	}
+}
+
+func (s *Server) handleDeleteSubtitle() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.SubtitleService.DeleteSubtitle(id); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Subtitle deleted successfully", http.StatusOK, nil, nil)
+	}
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+func (s *Server) handleListTerms() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		terms, apiErr := s.TaxonomyService.ListTerms(models.TaxonomyKind(c.Param("kind")))
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Terms retrieved successfully", http.StatusOK, terms, nil)
+	}
+}
+
+func (s *Server) handleCreateTerm() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.TaxonomyTermRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		term, apiErr := s.TaxonomyService.CreateTerm(models.TaxonomyKind(c.Param("kind")), &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Term created successfully", http.StatusCreated, term, nil)
+	}
+}
+
+func (s *Server) handleUpdateTerm() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.TaxonomyTermRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		term, apiErr := s.TaxonomyService.UpdateTerm(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Term updated successfully", http.StatusOK, term, nil)
+	}
+}
+
+func (s *Server) handleDeleteTerm() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.TaxonomyService.DeleteTerm(id); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Term deleted successfully", http.StatusOK, nil, nil)
+	}
+}
+
+// handleMergeTerms folds the term in the path into the one named in the body
+func (s *Server) handleMergeTerms() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.MergeTermsRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		term, apiErr := s.TaxonomyService.MergeTerms(id, request.IntoID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Terms merged successfully", http.StatusOK, term, nil)
+	}
+}
+
+func (s *Server) handleSetTitleTerms() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.TitleTermsRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		terms, apiErr := s.TaxonomyService.SetTitleTerms(models.TitleType(c.Param("type")), titleID, request.TermIDs)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Terms updated successfully", http.StatusOK, terms, nil)
+	}
+}
+
+func (s *Server) handleGetTitleTerms() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		titleID, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		terms, apiErr := s.TaxonomyService.GetTitleTerms(models.TitleType(c.Param("type")), titleID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Terms retrieved successfully", http.StatusOK, terms, nil)
+	}
+}
+
+// handleListTitles lists the titles of a type, filtered by taxonomy slugs, e.g.
+// GET /titles/trailer?genre=drama,comedy&language=yoruba returns Yoruba dramas and comedies
+func (s *Server) handleListTitles() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit := pagination(c)
+		filter := &models.TitleFilter{Terms: map[models.TaxonomyKind][]string{}, Page: page, Limit: limit}
+		for _, kind := range models.TaxonomyKinds {
+			for _, value := range c.QueryArray(string(kind)) {
+				for _, slug := range strings.Split(value, ",") {
+					if slug = strings.TrimSpace(slug); slug != "" {
+						filter.Terms[kind] = append(filter.Terms[kind], slug)
+					}
+				}
+			}
+		}
+
+		titles, total, apiErr := s.TaxonomyService.ListTitles(models.TitleType(c.Param("type")), filter)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Titles retrieved successfully", http.StatusOK, gin.H{
+			"titles": titles,
+			"total":  total,
+			"page":   page,
+			"limit":  limit,
+		}, nil)
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+// handleDeleteTitle moves a title to the trash, e.g. DELETE /titles/trailer/42
+func (s *Server) handleDeleteTitle() gin.HandlerFunc {
+	return s.handleDelete(func(c *gin.Context) string { return c.Param("type") })
+}
+
+func (s *Server) handleDeleteUser() gin.HandlerFunc {
+	return s.handleDelete(func(c *gin.Context) string { return models.TrashUser })
+}
+
+func (s *Server) handleDelete(trashType func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		userID, _ := getUserIDFromContext(c)
+
+		if apiErr := s.TrashService.Delete(trashType(c), id, userID); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Moved to the trash", http.StatusOK, nil, nil)
+	}
+}
+
+// handleListTrash lists what is in the trash for a type, e.g. GET /trash/user?page=2
+func (s *Server) handleListTrash() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit := pagination(c)
+		items, total, apiErr := s.TrashService.ListTrash(c.Param("type"), page, limit)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Trash retrieved successfully", http.StatusOK, gin.H{
+			"items": items,
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		}, nil)
+	}
+}
+
+func (s *Server) handleRestore() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		if apiErr := s.TrashService.Restore(c.Param("type"), id); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Restored successfully", http.StatusOK, nil, nil)
+	}
+}
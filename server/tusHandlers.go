@@ -0,0 +1,194 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	errs "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,expiration"
+)
+
+// TusResumable checks the protocol version of tus requests and tags every response with it
+func (s *Server) TusResumable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Resumable", tusVersion)
+		if c.Request.Method != http.MethodOptions && c.GetHeader("Tus-Resumable") != tusVersion {
+			c.Header("Tus-Version", tusVersion)
+			respondAndAbort(c, "", http.StatusPreconditionFailed, nil, errs.New("unsupported tus version", http.StatusPreconditionFailed))
+			return
+		}
+		c.Next()
+	}
+}
+
+// parseTusMetadata decodes the Upload-Metadata header, a comma separated list of "key base64(value)" pairs
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		fields := strings.SplitN(pair, " ", 2)
+		value := ""
+		if len(fields) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid metadata value for %s", fields[0])
+			}
+			value = string(decoded)
+		}
+		metadata[fields[0]] = value
+	}
+	return metadata, nil
+}
+
+// setTusUploadHeaders writes the offset and expiry of an upload to the response
+func setTusUploadHeaders(c *gin.Context, upload *models.TusUpload) {
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if !upload.Completed {
+		c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+func (s *Server) handleTusOptions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Version", tusVersion)
+		c.Header("Tus-Extension", tusExtensions)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// handleTusCreate implements the tus creation extension
+func (s *Server) handleTusCreate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getUserIDFromContext(c)
+		if !ok {
+			response.Unauthorized(c, "User ID not found in context")
+			return
+		}
+
+		length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+		if err != nil || length <= 0 {
+			response.JSON(c, "", http.StatusBadRequest, nil, errs.New("Upload-Length must be a positive integer", http.StatusBadRequest))
+			return
+		}
+
+		metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+		if err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		fileType := metadata["filetype"]
+		if !allowedFileTypes[fileType] {
+			response.JSON(c, "", http.StatusUnsupportedMediaType, nil, errs.New(fmt.Sprintf("invalid file type: %s", fileType), http.StatusUnsupportedMediaType))
+			return
+		}
+		if length > maxFileSize[fileType] {
+			response.JSON(c, "", http.StatusRequestEntityTooLarge, nil, errs.New(fmt.Sprintf("file exceeds the maximum allowed size of %d bytes", maxFileSize[fileType]), http.StatusRequestEntityTooLarge))
+			return
+		}
+
+		titleID, err := strconv.ParseUint(metadata["title_id"], 10, 64)
+		if err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, errs.New("title_id metadata is required", http.StatusBadRequest))
+			return
+		}
+
+		kind := models.MediaKind(metadata["kind"])
+		if kind == "" {
+			kind = mediaKindFor(fileType)
+		}
+		if kind == models.MediaSubtitle || mediaKindFor(fileType) == models.MediaSubtitle {
+			response.JSON(c, "", http.StatusBadRequest, nil, errs.New("subtitles are uploaded to /titles/:type/:id/subtitles", http.StatusBadRequest))
+			return
+		}
+
+		upload, apiErr := s.TusService.CreateUpload(&models.TusUpload{
+			UserID:      userID,
+			Length:      length,
+			Metadata:    c.GetHeader("Upload-Metadata"),
+			Filename:    metadata["filename"],
+			ContentType: fileType,
+			Kind:        kind,
+			TitleType:   models.TitleType(metadata["title_type"]),
+			TitleID:     uint(titleID),
+		}, c.GetString("user_role"))
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+upload.ID)
+		setTusUploadHeaders(c, upload)
+		c.Status(http.StatusCreated)
+	}
+}
+
+// handleTusHead lets a client find out where to resume an upload
+func (s *Server) handleTusHead() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := getUserIDFromContext(c)
+		upload, apiErr := s.TusService.GetUpload(c.Param("id"), userID)
+		if apiErr != nil {
+			c.Status(apiErr.Status)
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+		setTusUploadHeaders(c, upload)
+		c.Status(http.StatusOK)
+	}
+}
+
+func (s *Server) handleTusPatch() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.ContentType() != "application/offset+octet-stream" {
+			response.JSON(c, "", http.StatusUnsupportedMediaType, nil, errs.New("Content-Type must be application/offset+octet-stream", http.StatusUnsupportedMediaType))
+			return
+		}
+
+		offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+		if err != nil || offset < 0 {
+			response.JSON(c, "", http.StatusBadRequest, nil, errs.New("Upload-Offset must be a non-negative integer", http.StatusBadRequest))
+			return
+		}
+
+		userID, _ := getUserIDFromContext(c)
+		// Storage writes must outlive the request, otherwise the bytes received
+		// before a dropped connection would be thrown away
+		upload, apiErr := s.TusService.WriteChunk(context.Background(), c.Param("id"), userID, offset, c.Request.Body)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		setTusUploadHeaders(c, upload)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// handleTusDelete implements the tus termination extension
+func (s *Server) handleTusDelete() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := getUserIDFromContext(c)
+		if apiErr := s.TusService.TerminateUpload(c.Request.Context(), c.Param("id"), userID); apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
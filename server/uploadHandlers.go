@@ -1,45 +1,133 @@
 package server
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"mime/multipart"
 	"net/http"
-	"os"
+	"net/url"
 	"strconv"
 	"strings"
-	"sync"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/media"
 	"github.com/techagentng/telair-erp/models"
 	"github.com/techagentng/telair-erp/server/response"
+	"github.com/techagentng/telair-erp/storage"
+	"github.com/techagentng/telair-erp/subtitle"
 	jwtPackage "github.com/techagentng/telair-erp/services/jwt"
 )
 
-// Define allowed file types and maximum size
+// Define allowed file types and the maximum size of each type
 var (
 	allowedFileTypes = map[string]bool{
-		"video/mp4":  true,
-		"video/avi":  true,
-		"image/jpeg": true,
-		"image/png":  true,
+		media.TypeMP4:  true,
+		media.TypeMOV:  true,
+		media.TypeAVI:  true,
+		media.TypeJPEG: true,
+		media.TypePNG:  true,
+		media.TypeSRT:  true,
+		media.TypeVTT:  true,
 		// Add more allowed types as needed
 	}
-	maxFileSize int64 = 50 << 20 // 50 MB
+	maxFileSize = map[string]int64{
+		media.TypeMP4:  5 << 30,  // 5 GB
+		media.TypeMOV:  5 << 30,  // 5 GB
+		media.TypeAVI:  5 << 30,  // 5 GB
+		media.TypeJPEG: 20 << 20, // 20 MB
+		media.TypePNG:  20 << 20, // 20 MB
+		media.TypeSRT:  2 << 20,  // 2 MB
+		media.TypeVTT:  2 << 20,  // 2 MB
+	}
+	maxFieldSize int64 = 1 << 20 // 1 MB for plain form fields
 )
 
-// Function to validate file types and sizes
-func validateFile(fileHeader *multipart.FileHeader) error {
-	if _, ok := allowedFileTypes[fileHeader.Header.Get("Content-Type")]; !ok {
-		return fmt.Errorf("invalid file type: %s", fileHeader.Filename)
+// fileFields maps the file fields of the trailer form to the kind of media they take
+var fileFields = map[string]models.MediaKind{
+	"videos":    models.MediaVideo,
+	"pictures":  models.MediaPicture,
+	"posters":   models.MediaPoster,
+	"subtitles": models.MediaSubtitle,
+}
+
+// validateFile identifies a file from its first bytes instead of trusting the Content-Type
+// sent by the client, it returns the detected content type and the size limit for the file
+func validateFile(header []byte, filename, field string, kind models.MediaKind) (string, int64, *errors.FileError) {
+	contentType, err := media.Sniff(header)
+	if err != nil || !allowedFileTypes[contentType] {
+		return "", 0, &errors.FileError{Filename: filename, Field: field, Code: errors.FileUnsupportedType, Message: "file type is not supported"}
+	}
+	detected := mediaKindFor(contentType)
+	switch {
+	case kind == models.MediaVideo && detected != models.MediaVideo:
+		return "", 0, &errors.FileError{Filename: filename, Field: field, Code: errors.FileUnsupportedType, Message: fmt.Sprintf("expected a video, got %s", contentType)}
+	case kind == models.MediaSubtitle && detected != models.MediaSubtitle:
+		return "", 0, &errors.FileError{Filename: filename, Field: field, Code: errors.FileUnsupportedType, Message: fmt.Sprintf("expected a subtitle file, got %s", contentType)}
+	case kind != models.MediaVideo && kind != models.MediaSubtitle && detected != models.MediaPicture:
+		return "", 0, &errors.FileError{Filename: filename, Field: field, Code: errors.FileUnsupportedType, Message: fmt.Sprintf("expected an image, got %s", contentType)}
+	}
+	return contentType, maxFileSize[contentType], nil
+}
+
+// mediaKindFor tells videos, subtitles and pictures apart by their content type
+func mediaKindFor(contentType string) models.MediaKind {
+	if media.IsVideo(contentType) {
+		return models.MediaVideo
 	}
-	if fileHeader.Size > maxFileSize {
-		return fmt.Errorf("file %s exceeds the maximum allowed size of %d bytes", fileHeader.Filename, maxFileSize)
+	if media.IsSubtitle(contentType) {
+		return models.MediaSubtitle
 	}
-	return nil
+	return models.MediaPicture
+}
+
+// sizeLimitedReader fails the read as soon as more than limit bytes went through it,
+// so oversized files are rejected while streaming instead of after the upload
+type sizeLimitedReader struct {
+	r        io.Reader
+	filename string
+	field    string
+	limit    int64
+	n        int64
+}
+
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.exceeded() {
+		return n, l.err()
+	}
+	return n, err
+}
+
+func (l *sizeLimitedReader) exceeded() bool {
+	return l.n > l.limit
+}
+
+func (l *sizeLimitedReader) err() *errors.FileError {
+	return &errors.FileError{
+		Filename: l.filename,
+		Field:    l.field,
+		Code:     errors.FileTooLarge,
+		Message:  fmt.Sprintf("file exceeds the maximum allowed size of %d bytes", l.limit),
+	}
+}
+
+// readField reads a plain form field, fields over maxFieldSize are refused rather than cut short
+func readField(part *multipart.Part) (string, *errors.Error) {
+	value, err := io.ReadAll(io.LimitReader(part, maxFieldSize+1))
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("failed to read form field %s: %v", part.FormName(), err), http.StatusBadRequest)
+	}
+	if int64(len(value)) > maxFieldSize {
+		return "", errors.New(fmt.Sprintf("form field %s exceeds the maximum allowed size of %d bytes", part.FormName(), maxFieldSize), http.StatusRequestEntityTooLarge)
+	}
+	return string(value), nil
 }
 
 // Gin handler for uploading a trailer
@@ -90,54 +178,37 @@ func (s *Server) handleUploadTrailer() gin.HandlerFunc {
         // Log the converted userID
         log.Println("Converted UserID:", userID)
 
-        // Parse multipart form data
-        if err := c.Request.ParseMultipartForm(50 << 20); err != nil {
-            log.Println("Form Parsing Error:", err)
-            logErrorAndRespond(c, "Failed to parse form data", err, http.StatusBadRequest)
+        // Use the upload session the client asked for, or start one
+        progress, apiErr := s.uploadSession(c, userID)
+        if apiErr != nil {
+            logErrorAndRespond(c, "Invalid upload session", apiErr, apiErr.Status)
             return
         }
-
-        // Proceed with S3 upload logic
-        s3Client, err := createS3Client()
-        if err != nil {
-            log.Println("S3 Client Creation Error:", err)
-            logErrorAndRespond(c, "Failed to create S3 client", err, http.StatusInternalServerError)
-            return
+        sessionID := progress.SessionID
+        c.Header("Upload-Session-ID", sessionID)
+        c.Request.Body = readCloser{
+            Reader: s.ProgressService.Track(progress, c.Request.ContentLength, c.Request.Body),
+            Closer: c.Request.Body,
         }
 
-        // Log S3 client creation success
-        log.Println("S3 Client Created Successfully")
-        sessionID := uuid.New().String()
-        // Upload files and get URLs
-        videoURLs, pictureURLs, err := uploadTrailerFiles(c, s3Client, "videos")
-        if err != nil {
-            log.Println("File Upload Error:", err)
-            logErrorAndRespond(c, "Failed to upload files", err, http.StatusInternalServerError)
+        // Save the trailer as a draft and stream its files to storage, all or nothing
+        trailer, err := s.uploadTrailer(c, userID)
+        s.ProgressService.Finish(progress, err)
+        if fileErrs, ok := err.(errors.FileErrors); ok {
+            log.Println("Rejected Files:", fileErrs)
+            response.JSON(c, "Some files were rejected", http.StatusUnprocessableEntity, gin.H{"files": fileErrs}, fileErrs)
             return
         }
-
-        // Log the uploaded URLs
-        log.Println("Video URLs:", videoURLs)
-        log.Println("Picture URLs:", pictureURLs)
-
-        videoURLsStr := strings.Join(videoURLs, ",")
-        pictureURLsStr := strings.Join(pictureURLs, ",")
-
-        // Process form fields
-        trailer, err := createTrailerFromForm(c, userID, videoURLsStr, pictureURLsStr)
         if err != nil {
-            log.Println("Trailer Processing Error:", err)
-            logErrorAndRespond(c, "Failed to process trailer data", err, http.StatusBadRequest)
-            return
-        }
-
-        // Log the created trailer
-        log.Println("Trailer Created:", trailer)
-
-        // Save trailer to the database
-        if err := s.MovieRepository.CreateTrailer(&trailer); err != nil {
-            log.Println("Database Insertion Error:", err)
-            logErrorAndRespond(c, "Failed to create trailer", err, http.StatusInternalServerError)
+            log.Println("Trailer Upload Error:", err)
+            status := http.StatusInternalServerError
+            switch e := err.(type) {
+            case *errors.Error:
+                status = e.Status
+            case errors.ValidationError:
+                status = http.StatusBadRequest
+            }
+            logErrorAndRespond(c, "Failed to upload trailer", err, status)
             return
         }
 
@@ -151,116 +222,403 @@ func (s *Server) handleUploadTrailer() gin.HandlerFunc {
     }
 }
 
-var (
-	// To store progress of uploads, map sessionID to progress
-	uploadProgressStore = make(map[string]int)
-	mu                  sync.Mutex // Mutex to ensure thread-safe access to the map
-)
+// uploadedFile is a file of the trailer form once it is in storage
+type uploadedFile struct {
+    *storage.Object
+    Filename string
+    Kind     models.MediaKind
+    Info     *media.Info
+    // Language of a subtitle file, taken from its name
+    Language string
+    // Shared files are an existing copy of the same bytes, they belong to other assets too
+    Shared bool
+}
 
-// Function to track upload progress
-func trackProgress(sessionID string, completed, total int) {
-	mu.Lock()
-	defer mu.Unlock()
+// uploadTrailer creates a trailer and its media as one unit of work. The form fields have to
+// come before the files: they are validated and saved as a draft, then every file is streamed
+// under the draft's storage prefix. The first failure cancels the upload, the files already
+// stored and the draft are removed, and the trailer is only published once everything is in.
+// Subtitle files name their language before the extension, e.g. "trailer.fr.srt".
+func (s *Server) uploadTrailer(c *gin.Context, userID uint) (*models.Trailer, error) {
+    reader, err := c.Request.MultipartReader()
+    if err != nil {
+        return nil, errors.New(fmt.Sprintf("failed to read multipart body: %v", err), http.StatusBadRequest)
+    }
+
+    ctx, cancel := context.WithCancel(c.Request.Context())
+    defer cancel()
+
+    fields := url.Values{}
+    var trailer *models.Trailer
+    var files []*uploadedFile
+    var rejected errors.FileErrors
+    languages := map[string]bool{}
+
+    // rollback undoes everything stored so far
+    rollback := func() {
+        cancel()
+        s.deleteFiles(files)
+        if trailer != nil {
+            if err := s.MovieRepository.DeleteTrailer(trailer.ID); err != nil {
+                log.Printf("failed to delete draft trailer %d: %v", trailer.ID, err)
+            }
+        }
+    }
+
+    for {
+        part, err := reader.NextPart()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            rollback()
+            return nil, errors.New(fmt.Sprintf("failed to read multipart body: %v", err), http.StatusBadRequest)
+        }
+
+        // Plain form fields are small, keep them in memory
+        if part.FileName() == "" {
+            if trailer != nil {
+                part.Close()
+                rollback()
+                return nil, errors.New("form fields must be sent before the files", http.StatusBadRequest)
+            }
+            value, apiErr := readField(part)
+            part.Close()
+            if apiErr != nil {
+                return nil, apiErr
+            }
+            fields.Add(part.FormName(), value)
+            continue
+        }
+
+        fieldName := part.FormName()
+        kind, ok := fileFields[fieldName]
+        if !ok {
+            part.Close()
+            rollback()
+            return nil, errors.New(fmt.Sprintf("unexpected file field: %s", fieldName), http.StatusBadRequest)
+        }
+
+        // The metadata is complete once the first file shows up
+        if trailer == nil {
+            if trailer, err = s.createDraftTrailer(fields, userID); err != nil {
+                part.Close()
+                return nil, err
+            }
+        }
+
+        var language string
+        if kind == models.MediaSubtitle {
+            if language = models.LanguageFromFilename(part.FileName()); language == "" || languages[language] {
+                message := "subtitle file names must end with a language tag and the extension, e.g. trailer.fr.srt"
+                if language != "" {
+                    message = fmt.Sprintf("subtitles in %s were already sent", language)
+                }
+                rejected = append(rejected, &errors.FileError{Filename: part.FileName(), Field: fieldName, Code: errors.FileInvalidMedia, Message: message})
+                cancel()
+                io.Copy(io.Discard, part)
+                part.Close()
+                continue
+            }
+            languages[language] = true
+        }
+
+        if len(rejected) > 0 {
+            // The upload already failed, the remaining files are only checked so they can be reported too
+            if _, _, _, fileErr := sniffPart(part, kind); fileErr != nil {
+                rejected = append(rejected, fileErr)
+            }
+            io.Copy(io.Discard, part)
+            part.Close()
+            continue
+        }
+
+        file, err := s.uploadPart(ctx, part, models.TitleTrailer.StoragePrefix(trailer.ID), kind)
+        if fileErr, ok := err.(*errors.FileError); ok {
+            // Skip the rest of the file and stop uploading
+            io.Copy(io.Discard, part)
+            part.Close()
+            rejected = append(rejected, fileErr)
+            cancel()
+            continue
+        }
+        part.Close()
+        if err != nil {
+            rollback()
+            return nil, err
+        }
+        file.Language = language
+        files = append(files, file)
+    }
+
+    if trailer == nil {
+        // A trailer without files
+        if trailer, err = s.createDraftTrailer(fields, userID); err != nil {
+            return nil, err
+        }
+    }
+    if len(rejected) > 0 {
+        rollback()
+        return nil, rejected
+    }
+
+    trailer.VideoURLs = strings.Join(fileURLs(files, models.MediaVideo), ",")
+    trailer.PictureURLs = strings.Join(fileURLs(files, models.MediaPicture), ",")
+    // The running time of the video wins over the one typed in the form
+    if minutes := durationMinutes(files); minutes > 0 {
+        trailer.Duration = minutes
+    }
+    trailer.Status = models.Pending
 
-	// Calculate the progress percentage
-	progress := (completed * 100) / total
+    // Attach the files to the trailer, they are private and served through presigned links
+    assets := titleMediaAssets(models.TitleTrailer, trailer.ID, userID, files)
+    if err := s.MovieRepository.PublishTrailer(trailer, assets, trailerSubtitles(files, assets), starCredits(fields)); err != nil {
+        rollback()
+        return nil, err
+    }
+    return trailer, nil
+}
 
-	// Update the progress map
-	uploadProgressStore[sessionID] = progress
+// starCredits turns the star1, star2 and star3 form fields into actor credits, in billing order
+func starCredits(fields url.Values) []*models.Credit {
+    var credits []*models.Credit
+    for i, field := range []string{"star1", "star2", "star3"} {
+        name := strings.TrimSpace(fields.Get(field))
+        if name == "" {
+            continue
+        }
+        credits = append(credits, &models.Credit{
+            Person:       &models.Person{Name: name},
+            Role:         models.CreditActor,
+            BillingOrder: i + 1,
+        })
+    }
+    return credits
 }
 
-func uploadTrailerFiles(c *gin.Context, s3Client *s3.Client, folder string) (videoURLs []string, pictureURLs []string, err error) {
-    sessionID := uuid.New().String()
-    // Handle video file upload concurrently
-    videoFilePaths, err := uploadFilesConcurrently(c.Request.MultipartForm.File["videos"], folder+"/videos", s3Client, sessionID)
+// createDraftTrailer validates the form fields and saves them as a draft trailer
+func (s *Server) createDraftTrailer(fields url.Values, userID uint) (*models.Trailer, error) {
+    trailer, err := createTrailerFromForm(fields, userID)
     if err != nil {
-        return nil, nil, err
+        return nil, err
     }
-    videoURLs = videoFilePaths
+    trailer.Status = models.Draft
+    if err := s.MovieRepository.CreateTrailer(&trailer); err != nil {
+        return nil, err
+    }
+    return &trailer, nil
+}
 
-    // Handle picture file upload concurrently
-    pictureFilePaths, err := uploadFilesConcurrently(c.Request.MultipartForm.File["pictures"], folder+"/pictures", s3Client, sessionID)
-    if err != nil {
-        return nil, nil, err
+// deleteFiles removes files that were stored for an upload that did not go through
+func (s *Server) deleteFiles(files []*uploadedFile) {
+    for _, file := range files {
+        if file.Shared {
+            continue
+        }
+        if err := s.Storage.Delete(context.Background(), file.Key); err != nil {
+            log.Printf("failed to delete %s: %v", file.Key, err)
+        }
     }
-    pictureURLs = pictureFilePaths
+}
 
-    return videoURLs, pictureURLs, nil
+func fileURLs(files []*uploadedFile, kind models.MediaKind) []string {
+    var urls []string
+    for _, file := range files {
+        if file.Kind == kind {
+            urls = append(urls, file.URL)
+        }
+    }
+    return urls
 }
 
-// Function to upload files concurrently
-func uploadFilesConcurrently(files []*multipart.FileHeader, folder string, s3Client *s3.Client, sessionID string) ([]string, error) {
-    var filePaths []string
-    var wg sync.WaitGroup
-    var uploadErr error
-    var mu sync.Mutex
+// durationMinutes is the running time of the first video, rounded up to the minute
+func durationMinutes(files []*uploadedFile) int {
+    for _, file := range files {
+        if file.Kind == models.MediaVideo && file.Info != nil && file.Info.DurationSeconds > 0 {
+            return int(math.Ceil(file.Info.DurationSeconds / 60))
+        }
+    }
+    return 0
+}
 
-    totalFiles := len(files)
+// titleMediaAssets describes the uploaded files as media assets of a title
+func titleMediaAssets(titleType models.TitleType, titleID, userID uint, files []*uploadedFile) []*models.MediaAsset {
+    assets := make([]*models.MediaAsset, 0, len(files))
+    for _, file := range files {
+        asset := &models.MediaAsset{
+            TitleType:   titleType,
+            TitleID:     titleID,
+            Kind:        file.Kind,
+            StorageKey:  file.Key,
+            URL:         file.URL,
+            Filename:    file.Filename,
+            ContentType: file.ContentType,
+            Size:        file.Size,
+            SHA256:      file.SHA256,
+            UploadedBy:  userID,
+        }
+        if file.Info != nil {
+            asset.Width = file.Info.Width
+            asset.Height = file.Info.Height
+            asset.DurationSeconds = file.Info.DurationSeconds
+            asset.VideoCodec = file.Info.VideoCodec
+            asset.AudioCodec = file.Info.AudioCodec
+        }
+        assets = append(assets, asset)
+    }
+    return assets
+}
 
+// trailerSubtitles describes the uploaded subtitle files as subtitles of the trailer, assets holds the asset of each file
+func trailerSubtitles(files []*uploadedFile, assets []*models.MediaAsset) []*models.Subtitle {
+    var subtitles []*models.Subtitle
     for i, file := range files {
-        wg.Add(1)
-        go func(file *multipart.FileHeader, index int) {
-            defer wg.Done()
-
-            // Open the file
-            fileReader, err := file.Open()
-            if err != nil {
-                mu.Lock() // Protect shared variable
-                uploadErr = err
-                mu.Unlock()
-                return
-            }
-            defer fileReader.Close()
-
-            // Construct the filename for S3
-            filename := fmt.Sprintf("%s/%s", folder, file.Filename)
-            filePath, err := uploadFileToS3(s3Client, fileReader, os.Getenv("AWS_BUCKET"), filename)
-            if err != nil {
-                mu.Lock() // Protect shared variable
-                uploadErr = err
-                mu.Unlock()
-                return
-            }
+        if file.Kind != models.MediaSubtitle {
+            continue
+        }
+        subtitle := &models.Subtitle{Language: file.Language, Format: subtitleFormat(file.ContentType), Asset: assets[i]}
+        if file.Info != nil {
+            subtitle.Cues = file.Info.Cues
+        }
+        subtitles = append(subtitles, subtitle)
+    }
+    return subtitles
+}
+
+// subtitleFormat names the subtitle format of a content type
+func subtitleFormat(contentType string) string {
+    if contentType == media.TypeVTT {
+        return string(subtitle.VTT)
+    }
+    return string(subtitle.SRT)
+}
 
-            // Store the uploaded file path
-            mu.Lock() // Protect shared variable
-            filePaths = append(filePaths, filePath)
-            mu.Unlock()
+// uploadSession returns the session named by the Upload-Session-ID header or session_id query,
+// and creates a new one when the client did not ask for one
+func (s *Server) uploadSession(c *gin.Context, userID uint) (*models.UploadProgress, *errors.Error) {
+    sessionID := c.GetHeader("Upload-Session-ID")
+    if sessionID == "" {
+        sessionID = c.Query("session_id")
+    }
+    if sessionID == "" {
+        progress, err := s.ProgressService.CreateSession(userID)
+        if err != nil {
+            return nil, errors.ErrInternalServerError
+        }
+        return progress, nil
+    }
+    return s.ProgressService.GetSession(sessionID, userID)
+}
+
+type readCloser struct {
+    io.Reader
+    io.Closer
+}
 
-            // Track progress
-            trackProgress(sessionID, index+1, totalFiles)
-        }(file, i)
+// uploadPart streams a single file part to storage. The part is sniffed before anything
+// is stored, then inspected, hashed and held to its size limit while it streams.
+func (s *Server) uploadPart(ctx context.Context, part *multipart.Part, prefix string, kind models.MediaKind) (*uploadedFile, error) {
+    filename, field := part.FileName(), part.FormName()
+    buffered, contentType, limit, fileErr := sniffPart(part, kind)
+    if fileErr != nil {
+        return nil, fileErr
     }
 
-    // Wait for all goroutines to finish
-    wg.Wait()
+    // Construct the object key
+    key := storage.NewKey(prefix+"/"+kind.StorageFolder(), filename)
+    inspection := media.NewInspection(contentType)
+    body := &sizeLimitedReader{r: io.TeeReader(buffered, inspection), filename: filename, field: field, limit: limit}
 
-    // Return the uploaded file paths and any error that occurred
-    return filePaths, uploadErr
+    object, err := s.Storage.Upload(ctx, key, body, storage.UploadOptions{ContentType: contentType})
+    if err != nil {
+        inspection.Abort(err)
+        if body.exceeded() {
+            return nil, body.err()
+        }
+        return nil, err
+    }
+
+    file := &uploadedFile{Object: object, Filename: filename, Kind: kind}
+    file.Info, err = inspection.Close()
+    if err == nil {
+        fileErr = s.checkResolution(file, filename, field)
+    } else {
+        fileErr = &errors.FileError{Filename: filename, Field: field, Code: errors.FileInvalidMedia, Message: err.Error()}
+    }
+    if fileErr != nil {
+        s.deleteFiles([]*uploadedFile{file})
+        return nil, fileErr
+    }
+
+    // Reuse the stored copy when the same file was uploaded before
+    if file.Object, file.Shared, err = s.MediaService.Deduplicate(ctx, object); err != nil {
+        s.deleteFiles([]*uploadedFile{file})
+        return nil, err
+    }
+    return file, nil
+}
+
+// sniffPart validates a file part from its first bytes, the returned reader still yields the whole part
+func sniffPart(part *multipart.Part, kind models.MediaKind) (*bufio.Reader, string, int64, *errors.FileError) {
+    buffered := bufio.NewReaderSize(part, media.SniffLen)
+    header, err := buffered.Peek(media.SniffLen)
+    if err != nil && err != io.EOF {
+        return nil, "", 0, &errors.FileError{Filename: part.FileName(), Field: part.FormName(), Code: errors.FileInvalidMedia, Message: "unable to read file"}
+    }
+    contentType, limit, fileErr := validateFile(header, part.FileName(), part.FormName(), kind)
+    if fileErr != nil {
+        return nil, "", 0, fileErr
+    }
+    return buffered, contentType, limit, nil
+}
+
+// checkResolution holds posters to the minimum resolution from the config
+func (s *Server) checkResolution(file *uploadedFile, filename, field string) *errors.FileError {
+    if file.Kind != models.MediaPoster {
+        return nil
+    }
+    if file.Info.Width < s.Config.PosterMinWidth || file.Info.Height < s.Config.PosterMinHeight {
+        return &errors.FileError{
+            Filename: filename,
+            Field:    field,
+            Code:     errors.FileResolutionTooLow,
+            Message:  fmt.Sprintf("posters must be at least %dx%d, got %dx%d", s.Config.PosterMinWidth, s.Config.PosterMinHeight, file.Info.Width, file.Info.Height),
+        }
+    }
+    return nil
 }
 
 // Helper function to create trailer from form data
-func createTrailerFromForm(c *gin.Context, userID uint, videoURLs, pictureURLs string) (models.Trailer, error) {
-    durationStr := c.PostForm("duration")
-    duration, err := strconv.Atoi(durationStr)
-    if err != nil {
-        log.Printf("Invalid duration value: %v", err)
-        duration = 0
+func createTrailerFromForm(fields url.Values, userID uint) (models.Trailer, error) {
+    title := strings.TrimSpace(fields.Get("title"))
+    if title == "" {
+        return models.Trailer{}, errors.ValidationError{Field: "title", Message: "is required"}
+    }
+
+    // The duration is optional, it is taken from the video when there is one
+    duration := 0
+    if durationStr := fields.Get("duration"); durationStr != "" {
+        var err error
+        if duration, err = strconv.Atoi(durationStr); err != nil || duration < 0 {
+            return models.Trailer{}, errors.ValidationError{Field: "duration", Message: "must be a whole number of minutes"}
+        }
+    }
+
+    productYear := fields.Get("product_year")
+    if productYear != "" {
+        if _, err := strconv.Atoi(productYear); err != nil || len(productYear) != 4 {
+            return models.Trailer{}, errors.ValidationError{Field: "product_year", Message: "must be a four digit year"}
+        }
     }
 
     trailer := models.Trailer{
         MovieBase: models.MovieBase{
-            Title:       c.PostForm("title"),
-            Description: c.PostForm("description"),
+            Title:       title,
+            Description: fields.Get("description"),
             Duration:    duration,
         },
-        LogLine:     c.PostForm("log_line"),
-        ProductYear: c.PostForm("product_year"),
-        Star1:       c.PostForm("star1"),
-        Star2:       c.PostForm("star2"),
-        Star3:       c.PostForm("star3"),
-        VideoURLs:   videoURLs,
-        PictureURLs: pictureURLs,
+        LogLine:     fields.Get("log_line"),
+        ProductYear: productYear,
         UserID:      userID,
     }
 
@@ -273,29 +631,76 @@ func logErrorAndRespond(c *gin.Context, message string, err error, statusCode in
     response.JSON(c, "", statusCode, nil, fmt.Errorf("%s: %w", message, err))
 }
 
-func (s *Server) getUploadProgress() gin.HandlerFunc {
+// handleCreateUploadSession hands out a session ID the client sends along with its upload
+func (s *Server) handleCreateUploadSession() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get session ID from URL parameters instead of query
-		sessionID := c.Param("sessionID")
-		if sessionID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing sessionID"})
+		userID, _ := getUserIDFromContext(c)
+		progress, err := s.ProgressService.CreateSession(userID)
+		if err != nil {
+			logErrorAndRespond(c, "Failed to create upload session", err, http.StatusInternalServerError)
 			return
 		}
+		response.JSON(c, "Upload session created successfully", http.StatusCreated, progress, nil)
+	}
+}
 
-		// Lock the mutex before accessing the map
-		mu.Lock()
-		progress, exists := uploadProgressStore[sessionID]
-		mu.Unlock() // Unlock after access
-
-		if !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "No progress found for sessionID"})
+func (s *Server) getUploadProgress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := getUserIDFromContext(c)
+		progress, apiErr := s.ProgressService.GetSession(c.Param("sessionID"), userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
 			return
 		}
 
-		// Return progress as a JSON object with progress key
-		c.JSON(http.StatusOK, gin.H{"progress": progress})
+		response.JSON(c, "Upload progress retrieved successfully", http.StatusOK, progress, nil)
 	}
 }
 
+// handleUploadProgressEvents pushes progress updates as server-sent events until the upload finishes
+func (s *Server) handleUploadProgressEvents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := getUserIDFromContext(c)
+		sessionID := c.Param("sessionID")
+		progress, apiErr := s.ProgressService.GetSession(sessionID, userID)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
 
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+
+		// The store is polled rather than subscribed to, so updates written by another instance show up too
+		ticker := time.NewTicker(progressPollInterval)
+		defer ticker.Stop()
+		var lastUpdate time.Time
+		c.Stream(func(w io.Writer) bool {
+			if !progress.UpdatedAt.Equal(lastUpdate) {
+				c.SSEvent("progress", progress)
+				lastUpdate = progress.UpdatedAt
+			}
+			if progress.IsFinished() {
+				return false
+			}
+
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case <-ticker.C:
+			}
+
+			latest, apiErr := s.ProgressService.GetSession(sessionID, userID)
+			if apiErr != nil {
+				c.SSEvent("error", apiErr.Message)
+				return false
+			}
+			progress = latest
+			return true
+		})
+	}
+}
 
+// progressPollInterval is how often the event stream looks for new progress
+const progressPollInterval = 500 * time.Millisecond
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/server/response"
+)
+
+func (s *Server) handleCreateVendor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request models.VendorRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		vendor, apiErr := s.VendorService.CreateVendor(&request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Vendor created successfully", http.StatusCreated, vendor, nil)
+	}
+}
+
+func (s *Server) handleUpdateVendor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		var request models.VendorRequest
+		if err := decode(c, &request); err != nil {
+			response.JSON(c, "", http.StatusBadRequest, nil, err)
+			return
+		}
+
+		vendor, apiErr := s.VendorService.UpdateVendor(id, &request)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Vendor updated successfully", http.StatusOK, vendor, nil)
+	}
+}
+
+func (s *Server) handleGetVendor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, apiErr := paramID(c, "id")
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+
+		vendor, apiErr := s.VendorService.GetVendor(id)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Vendor retrieved successfully", http.StatusOK, vendor, nil)
+	}
+}
+
+// handleListVendors looks vendors up by name, e.g. GET /vendors?q=light&page=2
+func (s *Server) handleListVendors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, limit := pagination(c)
+		vendors, total, apiErr := s.VendorService.ListVendors(c.Query("q"), page, limit)
+		if apiErr != nil {
+			response.JSON(c, "", apiErr.Status, nil, apiErr)
+			return
+		}
+		response.JSON(c, "Vendors retrieved successfully", http.StatusOK, gin.H{
+			"vendors": vendors,
+			"total":   total,
+			"page":    page,
+			"limit":   limit,
+		}, nil)
+	}
+}
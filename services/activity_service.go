@@ -0,0 +1,477 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+// ActivityService records the notes, calls, meetings, emails and tasks about contacts, organizations,
+// deals and titles, and puts together their timelines
+type ActivityService interface {
+	CreateActivity(subjectType string, subjectID uint, request *models.ActivityRequest, userID uint) (*models.Activity, *apiError.Error)
+	UpdateActivity(id uint, request *models.ActivityRequest, userID uint, isAdmin bool) (*models.Activity, *apiError.Error)
+	DeleteActivity(id, userID uint, isAdmin bool) *apiError.Error
+	GetActivity(id uint) (*models.Activity, *apiError.Error)
+	ListActivities(filter *models.ActivityFilter) ([]models.Activity, int64, *apiError.Error)
+	CompleteTask(id, userID uint, isAdmin bool) (*models.Activity, *apiError.Error)
+	ReopenTask(id, userID uint, isAdmin bool) (*models.Activity, *apiError.Error)
+	Timeline(subjectType string, subjectID uint, page, limit int) ([]models.TimelineEntry, *apiError.Error)
+	MyTasks(userID uint, days int) (*models.MyTasks, *apiError.Error)
+}
+
+type activityService struct {
+	activityRepo db.ActivityRepository
+	crmRepo      db.CRMRepository
+	dealRepo     db.DealRepository
+	movieRepo    db.MovieRepository
+	revisionRepo db.RevisionRepository
+	authRepo     db.AuthRepository
+}
+
+// NewActivityService instantiate an activityService
+func NewActivityService(activityRepo db.ActivityRepository, crmRepo db.CRMRepository, dealRepo db.DealRepository, movieRepo db.MovieRepository, revisionRepo db.RevisionRepository, authRepo db.AuthRepository) ActivityService {
+	return &activityService{
+		activityRepo: activityRepo,
+		crmRepo:      crmRepo,
+		dealRepo:     dealRepo,
+		movieRepo:    movieRepo,
+		revisionRepo: revisionRepo,
+		authRepo:     authRepo,
+	}
+}
+
+// subjectTitleType returns the type of the titles kept in the table subjectType, if it is one
+func subjectTitleType(subjectType string) models.TitleType {
+	for _, titleType := range []models.TitleType{models.TitleTrailer, models.TitleFullLength} {
+		if titleType.TableName() == subjectType {
+			return titleType
+		}
+	}
+	return ""
+}
+
+// findSubject checks the subject of activities exists and returns when it was created
+func (s *activityService) findSubject(subjectType string, subjectID uint) (time.Time, *apiError.Error) {
+	var createdAt time.Time
+	var name string
+	var err error
+	switch subjectType {
+	case models.PartyContact:
+		name = "contact"
+		var contact *models.Contact
+		if contact, err = s.crmRepo.FindContact(subjectID); err == nil {
+			createdAt = time.Unix(contact.CreatedAt, 0)
+		}
+	case models.PartyOrganization:
+		name = "organization"
+		var organization *models.Organization
+		if organization, err = s.crmRepo.FindOrganization(subjectID); err == nil {
+			createdAt = time.Unix(organization.CreatedAt, 0)
+		}
+	case models.SubjectDeal:
+		name = "deal"
+		var deal *models.Deal
+		if deal, err = s.dealRepo.FindDeal(subjectID); err == nil {
+			createdAt = time.Unix(deal.CreatedAt, 0)
+		}
+	default:
+		titleType := subjectTitleType(subjectType)
+		if titleType == "" {
+			return time.Time{}, apiError.New("title type must be trailer or full_length", http.StatusBadRequest)
+		}
+		name = "title"
+		var title *models.MovieBase
+		if title, err = s.movieRepo.FindTitle(titleType, subjectID); err == nil {
+			createdAt = title.UploadedAt
+		}
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return time.Time{}, apiError.New(name+" not found", http.StatusNotFound)
+		}
+		log.Printf("findSubject error: %v", err)
+		return time.Time{}, apiError.ErrInternalServerError
+	}
+	return createdAt, nil
+}
+
+func (s *activityService) CreateActivity(subjectType string, subjectID uint, request *models.ActivityRequest, userID uint) (*models.Activity, *apiError.Error) {
+	if _, apiErr := s.findSubject(subjectType, subjectID); apiErr != nil {
+		return nil, apiErr
+	}
+	activity := &models.Activity{SubjectType: subjectType, SubjectID: subjectID, AuthorID: userID}
+	if apiErr := s.applyActivityRequest(activity, request, userID); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.activityRepo.CreateActivity(activity); err != nil {
+		log.Printf("CreateActivity error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return activity, nil
+}
+
+// applyActivityRequest copies a request onto an activity. A task keeps its assignee when none is named,
+// new tasks go to userID.
+func (s *activityService) applyActivityRequest(activity *models.Activity, request *models.ActivityRequest, userID uint) *apiError.Error {
+	if !request.Kind.IsValid() {
+		return apiError.New("kind must be note, call, meeting, email or task", http.StatusBadRequest)
+	}
+	summary := strings.TrimSpace(request.Summary)
+	if summary == "" {
+		return apiError.New("summary is required", http.StatusBadRequest)
+	}
+	if request.DurationMinutes < 0 {
+		return apiError.New("duration_minutes cannot be negative", http.StatusBadRequest)
+	}
+
+	occurredAt := time.Now()
+	if request.OccurredAt != "" {
+		parsed, err := time.Parse(time.RFC3339, request.OccurredAt)
+		if err != nil {
+			return apiError.New("occurred_at must be a time such as 2025-01-31T14:00:00Z", http.StatusBadRequest)
+		}
+		occurredAt = parsed
+	} else if !activity.OccurredAt.IsZero() {
+		occurredAt = activity.OccurredAt
+	}
+
+	activity.DueDate, activity.AssigneeID = nil, nil
+	if request.Kind == models.ActivityTask {
+		if request.DueDate == "" {
+			return apiError.New("due_date is required for a task", http.StatusBadRequest)
+		}
+		dueDate, apiErr := parseDate("due_date", request.DueDate)
+		if apiErr != nil {
+			return apiErr
+		}
+		assigneeID := request.AssigneeID
+		if assigneeID == 0 && activity.Kind == models.ActivityTask && activity.AssigneeID != nil {
+			assigneeID = *activity.AssigneeID
+		}
+		assigneeID, apiErr = checkAssignee(s.authRepo, assigneeID, userID)
+		if apiErr != nil {
+			return apiErr
+		}
+		activity.DueDate, activity.AssigneeID = &dueDate, &assigneeID
+	} else {
+		activity.CompletedAt, activity.CompletedBy = nil, nil
+	}
+
+	activity.Kind = request.Kind
+	activity.Summary = summary
+	activity.Body = request.Body
+	activity.OccurredAt = occurredAt
+	activity.DurationMinutes = request.DurationMinutes
+	return nil
+}
+
+// checkAssignee returns who a task is assigned to, the user creating it unless someone else is named
+func checkAssignee(authRepo db.AuthRepository, assigneeID, userID uint) (uint, *apiError.Error) {
+	if assigneeID == 0 || assigneeID == userID {
+		return userID, nil
+	}
+	if _, err := authRepo.FindUserByID(assigneeID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, apiError.New("assignee not found", http.StatusNotFound)
+		}
+		return 0, apiError.ErrInternalServerError
+	}
+	return assigneeID, nil
+}
+
+// canEdit reports whether a user may change an activity, which only its author and admins can
+func canEdit(activity *models.Activity, userID uint, isAdmin bool) bool {
+	return isAdmin || activity.AuthorID == userID
+}
+
+func (s *activityService) UpdateActivity(id uint, request *models.ActivityRequest, userID uint, isAdmin bool) (*models.Activity, *apiError.Error) {
+	activity, apiErr := s.GetActivity(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if !canEdit(activity, userID, isAdmin) {
+		return nil, apiError.New("only the author of an activity can change it", http.StatusForbidden)
+	}
+	if apiErr := s.applyActivityRequest(activity, request, userID); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.activityRepo.UpdateActivity(activity); err != nil {
+		log.Printf("UpdateActivity error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return activity, nil
+}
+
+func (s *activityService) DeleteActivity(id, userID uint, isAdmin bool) *apiError.Error {
+	activity, apiErr := s.GetActivity(id)
+	if apiErr != nil {
+		return apiErr
+	}
+	if !canEdit(activity, userID, isAdmin) {
+		return apiError.New("only the author of an activity can delete it", http.StatusForbidden)
+	}
+	if err := s.activityRepo.DeleteActivity(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.ErrNotFound
+		}
+		log.Printf("DeleteActivity error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+func (s *activityService) GetActivity(id uint) (*models.Activity, *apiError.Error) {
+	activity, err := s.activityRepo.FindActivity(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return activity, nil
+}
+
+func (s *activityService) ListActivities(filter *models.ActivityFilter) ([]models.Activity, int64, *apiError.Error) {
+	if filter.Kind != "" && !filter.Kind.IsValid() {
+		return nil, 0, apiError.New("kind must be note, call, meeting, email or task", http.StatusBadRequest)
+	}
+	if _, apiErr := s.findSubject(filter.SubjectType, filter.SubjectID); apiErr != nil {
+		return nil, 0, apiErr
+	}
+	activities, total, err := s.activityRepo.ListActivities(filter)
+	if err != nil {
+		log.Printf("ListActivities error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	return activities, total, nil
+}
+
+// findTask returns a task its author, its assignee or an admin is completing or reopening
+func (s *activityService) findTask(id, userID uint, isAdmin bool) (*models.Activity, *apiError.Error) {
+	task, apiErr := s.GetActivity(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if task.Kind != models.ActivityTask {
+		return nil, apiError.New("only tasks can be completed", http.StatusBadRequest)
+	}
+	if !canEdit(task, userID, isAdmin) && (task.AssigneeID == nil || *task.AssigneeID != userID) {
+		return nil, apiError.New("only the author or the assignee of a task can complete it", http.StatusForbidden)
+	}
+	return task, nil
+}
+
+func (s *activityService) CompleteTask(id, userID uint, isAdmin bool) (*models.Activity, *apiError.Error) {
+	task, apiErr := s.findTask(id, userID, isAdmin)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if task.CompletedAt != nil {
+		return nil, apiError.New("task is already completed", http.StatusConflict)
+	}
+	now := time.Now()
+	task.CompletedAt, task.CompletedBy = &now, &userID
+	if err := s.activityRepo.UpdateActivity(task); err != nil {
+		log.Printf("CompleteTask error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return task, nil
+}
+
+func (s *activityService) ReopenTask(id, userID uint, isAdmin bool) (*models.Activity, *apiError.Error) {
+	task, apiErr := s.findTask(id, userID, isAdmin)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if task.CompletedAt == nil {
+		return nil, apiError.New("task is not completed", http.StatusConflict)
+	}
+	task.CompletedAt, task.CompletedBy = nil, nil
+	if err := s.activityRepo.UpdateActivity(task); err != nil {
+		log.Printf("ReopenTask error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return task, nil
+}
+
+// Timeline returns a page of what happened to a subject, most recent first: its activities, the tasks
+// about it being completed, the edits of a title, the stage changes of a deal or of the deals of a contact
+// or organization, and the subject being created. Each source gives its latest entries up to the end of
+// the page, which are merged and cut down to the page.
+func (s *activityService) Timeline(subjectType string, subjectID uint, page, limit int) ([]models.TimelineEntry, *apiError.Error) {
+	createdAt, apiErr := s.findSubject(subjectType, subjectID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	latest := page * limit
+
+	activities, _, err := s.activityRepo.ListActivities(&models.ActivityFilter{SubjectType: subjectType, SubjectID: subjectID, Page: 1, Limit: latest})
+	if err != nil {
+		log.Printf("Timeline error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	entries := []models.TimelineEntry{}
+	for i, activity := range activities {
+		entries = append(entries, models.TimelineEntry{
+			Kind:     models.TimelineActivity,
+			At:       activity.OccurredAt,
+			UserID:   activity.AuthorID,
+			Summary:  activitySummary(&activity),
+			Activity: &activities[i],
+		})
+	}
+
+	tasks, err := s.activityRepo.FindCompletedTasks(subjectType, subjectID, latest)
+	if err != nil {
+		log.Printf("Timeline error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	for i, task := range tasks {
+		entries = append(entries, models.TimelineEntry{
+			Kind:     models.TimelineTaskCompleted,
+			At:       *task.CompletedAt,
+			UserID:   *task.CompletedBy,
+			Summary:  "Completed task: " + task.Summary,
+			Activity: &tasks[i],
+		})
+	}
+
+	if titleType := subjectTitleType(subjectType); titleType != "" {
+		revisions, err := s.revisionRepo.FindRevisions(titleType, subjectID)
+		if err != nil {
+			log.Printf("Timeline error: %v", err)
+			return nil, apiError.ErrInternalServerError
+		}
+		for i, revision := range revisions {
+			// revision 1 is the title as it was before the first edit, not an edit itself
+			if revision.Number == 1 {
+				continue
+			}
+			entries = append(entries, models.TimelineEntry{
+				Kind:     models.TimelineRevision,
+				At:       time.Unix(revision.CreatedAt, 0),
+				UserID:   revision.AuthorID,
+				Summary:  revisionSummary(&revision),
+				Revision: &revisions[i],
+			})
+		}
+	} else {
+		events, err := s.dealRepo.FindDealEvents(subjectType, subjectID, latest)
+		if err != nil {
+			log.Printf("Timeline error: %v", err)
+			return nil, apiError.ErrInternalServerError
+		}
+		for i, event := range events {
+			entries = append(entries, models.TimelineEntry{
+				Kind:        models.TimelineStageChange,
+				At:          event.ChangedAt,
+				UserID:      event.ChangedBy,
+				Summary:     dealEventSummary(&event, subjectType != models.SubjectDeal),
+				StageChange: &events[i],
+			})
+		}
+	}
+
+	// the first stage change of a deal is it being created
+	if subjectType != models.SubjectDeal {
+		entries = append(entries, models.TimelineEntry{
+			Kind:    models.TimelineCreated,
+			At:      createdAt,
+			Summary: "Created",
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].At.After(entries[j].At)
+	})
+	start, end := (page-1)*limit, latest
+	if start > len(entries) {
+		start = len(entries)
+	}
+	if end > len(entries) {
+		end = len(entries)
+	}
+	return entries[start:end], nil
+}
+
+// activitySummary is an activity as a timeline shows it, e.g. "Call: contract terms"
+func activitySummary(activity *models.Activity) string {
+	kind := string(activity.Kind)
+	return strings.ToUpper(kind[:1]) + kind[1:] + ": " + activity.Summary
+}
+
+// revisionSummary is an edit of a title as a timeline shows it, e.g. "Edited title, description"
+func revisionSummary(revision *models.Revision) string {
+	var summary string
+	if revision.RevertOf != 0 {
+		summary = fmt.Sprintf("Reverted to revision %d", revision.RevertOf)
+	} else {
+		fields := make([]string, len(revision.Changes))
+		for i, change := range revision.Changes {
+			fields[i] = change.Field
+		}
+		summary = "Edited " + strings.Join(fields, ", ")
+	}
+	switch revision.Status {
+	case models.RevisionPending:
+		summary += " (waiting for approval)"
+	case models.RevisionRejected:
+		summary += " (rejected)"
+	}
+	return summary
+}
+
+// dealEventSummary is a stage change as a timeline shows it, e.g. "Moved from Offer to Negotiation",
+// prefixed with the deal name on the timeline of a contact or organization
+func dealEventSummary(event *models.DealEvent, withDeal bool) string {
+	var summary string
+	switch {
+	case event.Status == models.DealWon:
+		summary = "Won in " + event.ToStage
+	case event.Status == models.DealLost:
+		summary = "Lost in " + event.ToStage
+	case event.FromStageID == nil:
+		summary = "Created in " + event.ToStage
+	case *event.FromStageID == event.ToStageID:
+		summary = "Reopened in " + event.ToStage
+	default:
+		summary = fmt.Sprintf("Moved from %s to %s", event.FromStage, event.ToStage)
+	}
+	if withDeal {
+		summary = fmt.Sprintf("Deal %s: %s", event.DealName, strings.ToLower(summary[:1])+summary[1:])
+	}
+	return summary
+}
+
+// MyTasks returns the open tasks assigned to a user that are overdue or due within the next days
+func (s *activityService) MyTasks(userID uint, days int) (*models.MyTasks, *apiError.Error) {
+	if days < 0 {
+		return nil, apiError.New("days cannot be negative", http.StatusBadRequest)
+	}
+	today, _ := time.Parse(models.DateLayout, time.Now().UTC().Format(models.DateLayout))
+	tasks, err := s.activityRepo.FindOpenTasks(userID, today.AddDate(0, 0, days))
+	if err != nil {
+		log.Printf("MyTasks error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+
+	mine := &models.MyTasks{Overdue: []models.Activity{}, Due: []models.Activity{}}
+	for _, task := range tasks {
+		if task.DueDate.Before(today) {
+			mine.Overdue = append(mine.Overdue, task)
+		} else {
+			mine.Due = append(mine.Due, task)
+		}
+	}
+	return mine, nil
+}
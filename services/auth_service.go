@@ -31,7 +31,7 @@ type AuthService interface {
 	SignupUser(request *models.User) (*models.User, error)
 	// UpdateUserImageUrl(imagePath string) *apiError.Error
 	GetUserProfile(userID uint) (*models.User, error)
-	EditUserProfile(userID uint, userDetails *models.EditProfileResponse) error
+	EditUserProfile(userID uint, version int, userDetails *models.EditProfileResponse) (int, error)
 	// FacebookSignInUser(token string) (*string, *apiError.Error)
 	// VerifyEmail(token string) error
 	SendEmailForPasswordReset(user *models.ForgotPassword) *apiError.Error
@@ -224,12 +224,12 @@ func (a *authService) GetUserProfile(userID uint) (*models.User, error) {
 	return user, nil
 }
 
-func (a *authService) EditUserProfile(userID uint, userDetail *models.EditProfileResponse) error {
+func (a *authService) EditUserProfile(userID uint, version int, userDetail *models.EditProfileResponse) (int, error) {
 	// Implement your business logic here, if needed
 	// For example, you might want to perform validation on the user details before updating
 
 	// Call the repository method to update user profile
-	return a.authRepo.EditUserProfile(userID, userDetail)
+	return a.authRepo.EditUserProfile(userID, version, userDetail)
 }
 
 func (a *authService) SendEmailForPasswordReset(user *models.ForgotPassword) *apiError.Error {
@@ -0,0 +1,531 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/techagentng/telair-erp/config"
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/storage"
+	"gorm.io/gorm"
+)
+
+// receiptTypes maps the content types accepted for receipts to the extension they are stored with
+var receiptTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+}
+
+// BudgetService manages the production budgets of titles and the actual costs booked against them
+type BudgetService interface {
+	CreateBudget(titleType models.TitleType, titleID uint, request *models.BudgetRequest, userID uint) (*models.Budget, *apiError.Error)
+	UpdateBudget(id uint, request *models.BudgetRequest) (*models.Budget, *apiError.Error)
+	GetBudget(id uint) (*models.Budget, *apiError.Error)
+	GetTitleBudget(titleType models.TitleType, titleID uint) (*models.Budget, *apiError.Error)
+	ApproveBudget(id, userID uint) (*models.Budget, *apiError.Error)
+	ReopenBudget(id uint) (*models.Budget, *apiError.Error)
+	RecordCost(budgetID uint, request *models.CostRequest, userID uint) (*models.CostEntry, *apiError.Error)
+	GetCost(id uint) (*models.CostEntry, *apiError.Error)
+	ListCosts(filter *models.CostFilter) ([]models.CostEntry, int64, *apiError.Error)
+	ReviewCost(id uint, status models.CostStatus, userID uint, note string) (*models.CostEntry, *apiError.Error)
+	DeleteCost(id, userID uint) *apiError.Error
+	AttachReceipt(ctx context.Context, costID uint, data []byte) (*models.CostEntry, *apiError.Error)
+	ReceiptURL(costID uint) (*storage.PresignedRequest, *apiError.Error)
+	Variance(budgetID uint) (*models.BudgetVariance, *apiError.Error)
+}
+
+type budgetService struct {
+	Config              *config.Config
+	budgetRepo          db.BudgetRepository
+	vendorRepo          db.VendorRepository
+	movieRepo           db.MovieRepository
+	exchangeRateService ExchangeRateService
+	postingService      PostingService
+	storage             storage.Storage
+}
+
+// NewBudgetService instantiate a budgetService
+func NewBudgetService(budgetRepo db.BudgetRepository, vendorRepo db.VendorRepository, movieRepo db.MovieRepository, exchangeRateService ExchangeRateService, postingService PostingService, store storage.Storage, conf *config.Config) BudgetService {
+	return &budgetService{
+		Config:              conf,
+		budgetRepo:          budgetRepo,
+		vendorRepo:          vendorRepo,
+		movieRepo:           movieRepo,
+		exchangeRateService: exchangeRateService,
+		postingService:      postingService,
+		storage:             store,
+	}
+}
+
+// CreateBudget starts the draft budget of a title, a title has a single budget
+func (s *budgetService) CreateBudget(titleType models.TitleType, titleID uint, request *models.BudgetRequest, userID uint) (*models.Budget, *apiError.Error) {
+	if apiErr := checkTitle(s.movieRepo, titleType, titleID); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, err := s.budgetRepo.FindTitleBudget(titleType, titleID); err == nil {
+		return nil, apiError.New("the title already has a budget", http.StatusConflict)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apiError.ErrInternalServerError
+	}
+
+	budget := &models.Budget{
+		TitleType: titleType,
+		TitleID:   titleID,
+		Status:    models.BudgetDraft,
+		CreatedBy: userID,
+	}
+	if _, apiErr := s.applyBudgetRequest(budget, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.budgetRepo.CreateBudget(budget); err != nil {
+		log.Printf("CreateBudget error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return budget, nil
+}
+
+// UpdateBudget changes a draft budget. Line items sent with their ID are changed, the ones left out are
+// removed, which they cannot be once costs were booked against them.
+func (s *budgetService) UpdateBudget(id uint, request *models.BudgetRequest) (*models.Budget, *apiError.Error) {
+	budget, apiErr := s.GetBudget(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if budget.Status != models.BudgetDraft {
+		return nil, apiError.New("only draft budgets can be changed, reopen it first", http.StatusConflict)
+	}
+	if budget.Currency != strings.ToUpper(request.Currency) {
+		costs, err := s.budgetRepo.CountLineCosts(lineIDs(budget.Lines))
+		if err != nil {
+			return nil, apiError.ErrInternalServerError
+		}
+		if costs > 0 {
+			return nil, apiError.New("the currency of a budget with costs cannot change", http.StatusConflict)
+		}
+	}
+
+	removed, apiErr := s.applyBudgetRequest(budget, request)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	costs, err := s.budgetRepo.CountLineCosts(removed)
+	if err != nil {
+		return nil, apiError.ErrInternalServerError
+	}
+	if costs > 0 {
+		return nil, apiError.New("line items with costs cannot be removed", http.StatusConflict)
+	}
+
+	if err := s.budgetRepo.UpdateBudget(budget, removed); err != nil {
+		log.Printf("UpdateBudget error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetBudget(id)
+}
+
+func lineIDs(lines []models.BudgetLine) []uint {
+	ids := make([]uint, 0, len(lines))
+	for _, line := range lines {
+		ids = append(ids, line.ID)
+	}
+	return ids
+}
+
+// applyBudgetRequest checks a request and copies it onto a budget, it returns the IDs of the lines left out
+func (s *budgetService) applyBudgetRequest(budget *models.Budget, request *models.BudgetRequest) ([]uint, *apiError.Error) {
+	currency := strings.ToUpper(request.Currency)
+	if !isCurrencyCode(currency) {
+		return nil, apiError.New("currency must be an ISO 4217 code such as NGN", http.StatusBadRequest)
+	}
+	if request.ApprovalThreshold.IsNegative() {
+		return nil, apiError.New("approval_threshold cannot be negative", http.StatusBadRequest)
+	}
+
+	existing := map[uint]bool{}
+	for _, line := range budget.Lines {
+		existing[line.ID] = true
+	}
+	kept := map[uint]bool{}
+	lines := make([]models.BudgetLine, 0, len(request.Lines))
+	total := decimal.Zero
+	for _, line := range request.Lines {
+		if line.ID != 0 && (!existing[line.ID] || kept[line.ID]) {
+			return nil, apiError.New(fmt.Sprintf("line item %d is not part of the budget", line.ID), http.StatusBadRequest)
+		}
+		if !line.Category.IsValid() {
+			return nil, apiError.New("category must be above_the_line, production, post_production or marketing", http.StatusBadRequest)
+		}
+		if line.Amount.IsNegative() {
+			return nil, apiError.New("line item amounts cannot be negative", http.StatusBadRequest)
+		}
+		kept[line.ID] = true
+		lines = append(lines, models.BudgetLine{
+			ID:          line.ID,
+			BudgetID:    budget.ID,
+			Category:    line.Category,
+			Code:        strings.TrimSpace(line.Code),
+			Description: strings.TrimSpace(line.Description),
+			Amount:      line.Amount.Round(2),
+		})
+		total = total.Add(line.Amount.Round(2))
+	}
+
+	var removed []uint
+	for _, line := range budget.Lines {
+		if !kept[line.ID] {
+			removed = append(removed, line.ID)
+		}
+	}
+
+	budget.Currency = currency
+	budget.ApprovalThreshold = request.ApprovalThreshold.Round(2)
+	budget.Notes = request.Notes
+	budget.Lines = lines
+	budget.Total = total
+	return removed, nil
+}
+
+func (s *budgetService) GetBudget(id uint) (*models.Budget, *apiError.Error) {
+	budget, err := s.budgetRepo.FindBudget(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return budget, nil
+}
+
+func (s *budgetService) GetTitleBudget(titleType models.TitleType, titleID uint) (*models.Budget, *apiError.Error) {
+	if !titleType.IsValid() {
+		return nil, apiError.New("title type must be trailer or full_length", http.StatusBadRequest)
+	}
+	budget, err := s.budgetRepo.FindTitleBudget(titleType, titleID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return budget, nil
+}
+
+// ApproveBudget locks the line items of a draft budget, costs can be booked against it from then on
+func (s *budgetService) ApproveBudget(id, userID uint) (*models.Budget, *apiError.Error) {
+	budget, apiErr := s.GetBudget(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if budget.Status != models.BudgetDraft {
+		return nil, apiError.New("the budget is already approved", http.StatusConflict)
+	}
+	if len(budget.Lines) == 0 {
+		return nil, apiError.New("a budget needs line items before it is approved", http.StatusBadRequest)
+	}
+	if err := s.budgetRepo.ApproveBudget(id, userID, time.Now()); err != nil {
+		log.Printf("ApproveBudget error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetBudget(id)
+}
+
+// ReopenBudget takes an approved budget back to draft so its line items can be revised
+func (s *budgetService) ReopenBudget(id uint) (*models.Budget, *apiError.Error) {
+	budget, apiErr := s.GetBudget(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if budget.Status != models.BudgetApproved {
+		return nil, apiError.New("only approved budgets can be reopened", http.StatusConflict)
+	}
+	if err := s.budgetRepo.ReopenBudget(id); err != nil {
+		log.Printf("ReopenBudget error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetBudget(id)
+}
+
+// RecordCost books an actual cost against a line of an approved budget, converting it to the budget currency.
+// Costs above the approval threshold of the budget, or taking their line over budget, wait for an admin,
+// the others are approved and booked in the ledger at once.
+func (s *budgetService) RecordCost(budgetID uint, request *models.CostRequest, userID uint) (*models.CostEntry, *apiError.Error) {
+	budget, apiErr := s.GetBudget(budgetID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if budget.Status != models.BudgetApproved {
+		return nil, apiError.New("costs can only be booked against an approved budget", http.StatusConflict)
+	}
+	var line *models.BudgetLine
+	for i := range budget.Lines {
+		if budget.Lines[i].ID == request.BudgetLineID {
+			line = &budget.Lines[i]
+		}
+	}
+	if line == nil {
+		return nil, apiError.New("line item not found in the budget", http.StatusNotFound)
+	}
+	if request.VendorID != nil {
+		if _, err := s.vendorRepo.FindVendor(*request.VendorID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apiError.New("vendor not found", http.StatusNotFound)
+			}
+			return nil, apiError.ErrInternalServerError
+		}
+	}
+
+	incurredOn, apiErr := parseDate("incurred_on", request.IncurredOn)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	currency := strings.ToUpper(request.Currency)
+	if !isCurrencyCode(currency) {
+		return nil, apiError.New("currency must be an ISO 4217 code such as NGN", http.StatusBadRequest)
+	}
+	if !request.Amount.IsPositive() {
+		return nil, apiError.New("amount must be positive", http.StatusBadRequest)
+	}
+	amount := request.Amount.Round(2)
+	baseAmount, rate, apiErr := s.exchangeRateService.Convert(amount, currency, budget.Currency, incurredOn)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	// The line is checked for room when the cost is recorded, a cost taking it over budget waits too
+	status := models.CostApproved
+	if baseAmount.GreaterThan(budget.ApprovalThreshold) {
+		status = models.CostPending
+	}
+
+	cost := &models.CostEntry{
+		BudgetID:     budget.ID,
+		BudgetLineID: line.ID,
+		VendorID:     request.VendorID,
+		Description:  strings.TrimSpace(request.Description),
+		Reference:    strings.TrimSpace(request.Reference),
+		IncurredOn:   incurredOn,
+		Currency:     currency,
+		Amount:       amount,
+		ExchangeRate: rate,
+		BaseAmount:   baseAmount,
+		Status:       status,
+		CreatedBy:    userID,
+	}
+	var entry *models.JournalEntry
+	if status == models.CostApproved {
+		if entry, apiErr = s.postingService.CostApproved(cost, line.Category, userID); apiErr != nil {
+			return nil, apiErr
+		}
+	}
+	if err := s.budgetRepo.CreateCost(cost, entry); err != nil {
+		if apiErr := ledgerError(err); apiErr != nil {
+			return nil, apiErr
+		}
+		log.Printf("RecordCost error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetCost(cost.ID)
+}
+
+func (s *budgetService) GetCost(id uint) (*models.CostEntry, *apiError.Error) {
+	cost, err := s.budgetRepo.FindCost(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	cost.HasReceipt = cost.ReceiptKey != ""
+	return cost, nil
+}
+
+func (s *budgetService) ListCosts(filter *models.CostFilter) ([]models.CostEntry, int64, *apiError.Error) {
+	if _, apiErr := s.GetBudget(filter.BudgetID); apiErr != nil {
+		return nil, 0, apiErr
+	}
+	costs, total, err := s.budgetRepo.ListCosts(filter)
+	if err != nil {
+		log.Printf("ListCosts error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	for i := range costs {
+		costs[i].HasReceipt = costs[i].ReceiptKey != ""
+	}
+	return costs, total, nil
+}
+
+// ReviewCost approves or rejects a cost waiting for an admin, rejected costs do not count as spent and
+// approved ones are booked in the ledger
+func (s *budgetService) ReviewCost(id uint, status models.CostStatus, userID uint, note string) (*models.CostEntry, *apiError.Error) {
+	cost, apiErr := s.GetCost(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	var entry *models.JournalEntry
+	if status == models.CostApproved && cost.Status == models.CostPending {
+		budget, apiErr := s.GetBudget(cost.BudgetID)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		for _, line := range budget.Lines {
+			if line.ID == cost.BudgetLineID {
+				if entry, apiErr = s.postingService.CostApproved(cost, line.Category, userID); apiErr != nil {
+					return nil, apiErr
+				}
+			}
+		}
+	}
+	if err := s.budgetRepo.ReviewCost(id, status, userID, note, time.Now(), entry); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.New("the cost is not waiting for approval", http.StatusConflict)
+		}
+		if apiErr := ledgerError(err); apiErr != nil {
+			return nil, apiErr
+		}
+		log.Printf("ReviewCost error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetCost(id)
+}
+
+// DeleteCost removes a cost booked by mistake, the ledger reverses its booking if it was approved
+func (s *budgetService) DeleteCost(id, userID uint) *apiError.Error {
+	if err := s.budgetRepo.DeleteCost(id, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.ErrNotFound
+		}
+		if apiErr := ledgerError(err); apiErr != nil {
+			return apiErr
+		}
+		log.Printf("DeleteCost error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+// AttachReceipt stores the scanned receipt of a cost, replacing the previous one. Receipts are PDF, JPEG,
+// PNG or WebP files, told apart by their content rather than their name.
+func (s *budgetService) AttachReceipt(ctx context.Context, costID uint, data []byte) (*models.CostEntry, *apiError.Error) {
+	cost, apiErr := s.GetCost(costID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := receiptTypes[contentType]
+	if !ok {
+		return nil, apiError.New(fmt.Sprintf("receipts must be PDF, JPEG, PNG or WebP files, got %s", contentType), http.StatusUnsupportedMediaType)
+	}
+
+	key := storage.NewKey(fmt.Sprintf("budgets/%d/receipts", cost.BudgetID), "receipt"+ext)
+	if _, err := s.storage.Upload(ctx, key, bytes.NewReader(data), storage.UploadOptions{ContentType: contentType}); err != nil {
+		log.Printf("AttachReceipt error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	if err := s.budgetRepo.SetCostReceipt(cost.ID, key); err != nil {
+		log.Printf("AttachReceipt error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	if cost.ReceiptKey != "" {
+		if err := s.storage.Delete(ctx, cost.ReceiptKey); err != nil {
+			log.Printf("AttachReceipt error: failed to delete previous receipt %s: %v", cost.ReceiptKey, err)
+		}
+	}
+	return s.GetCost(cost.ID)
+}
+
+// ReceiptURL signs a short-lived link to the receipt of a cost
+func (s *budgetService) ReceiptURL(costID uint) (*storage.PresignedRequest, *apiError.Error) {
+	cost, apiErr := s.GetCost(costID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if cost.ReceiptKey == "" {
+		return nil, apiError.New("the cost has no receipt", http.StatusNotFound)
+	}
+	presigned, err := s.storage.PresignGet(context.Background(), cost.ReceiptKey, time.Duration(s.Config.PresignExpiryMinutes)*time.Minute)
+	if err != nil {
+		log.Printf("ReceiptURL error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return presigned, nil
+}
+
+// Variance compares each line item and category of a budget with the costs booked against it
+func (s *budgetService) Variance(budgetID uint) (*models.BudgetVariance, *apiError.Error) {
+	budget, apiErr := s.GetBudget(budgetID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	totals, err := s.budgetRepo.SumLineCosts(budget.ID)
+	if err != nil {
+		log.Printf("Variance error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	actual := map[uint]decimal.Decimal{}
+	pending := map[uint]decimal.Decimal{}
+	for _, total := range totals {
+		switch total.Status {
+		case models.CostApproved:
+			actual[total.BudgetLineID] = total.Total
+		case models.CostPending:
+			pending[total.BudgetLineID] = total.Total
+		}
+	}
+
+	report := &models.BudgetVariance{
+		BudgetID:  budget.ID,
+		TitleType: budget.TitleType,
+		TitleID:   budget.TitleID,
+		Currency:  budget.Currency,
+	}
+	var budgeted, spent, waiting decimal.Decimal
+	for _, category := range models.BudgetCategories {
+		section := models.CategoryVariance{Category: category, Lines: []models.LineVariance{}}
+		var sectionBudgeted, sectionSpent, sectionWaiting decimal.Decimal
+		for _, line := range budget.Lines {
+			if line.Category != category {
+				continue
+			}
+			section.Lines = append(section.Lines, models.LineVariance{
+				BudgetLine: line,
+				Variance:   newVariance(line.Amount, actual[line.ID], pending[line.ID]),
+			})
+			sectionBudgeted = sectionBudgeted.Add(line.Amount)
+			sectionSpent = sectionSpent.Add(actual[line.ID])
+			sectionWaiting = sectionWaiting.Add(pending[line.ID])
+		}
+		section.Variance = newVariance(sectionBudgeted, sectionSpent, sectionWaiting)
+		report.Categories = append(report.Categories, section)
+		budgeted = budgeted.Add(sectionBudgeted)
+		spent = spent.Add(sectionSpent)
+		waiting = waiting.Add(sectionWaiting)
+	}
+	report.Total = newVariance(budgeted, spent, waiting)
+	return report, nil
+}
+
+func newVariance(budgeted, actual, pending decimal.Decimal) models.Variance {
+	variance := models.Variance{
+		Budgeted:   budgeted,
+		Actual:     actual,
+		Pending:    pending,
+		Variance:   budgeted.Sub(actual),
+		OverBudget: actual.GreaterThan(budgeted),
+	}
+	if budgeted.IsPositive() {
+		percent := variance.Variance.Mul(hundred).DivRound(budgeted, 2)
+		variance.VariancePercent = &percent
+	}
+	return variance
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+// ContractService manages licensing contracts, their titles, fee structure and payment schedule
+type ContractService interface {
+	CreateContract(request *models.ContractRequest) (*models.Contract, *apiError.Error)
+	UpdateContract(id uint, request *models.ContractRequest) (*models.Contract, *apiError.Error)
+	GetContract(id uint) (*models.Contract, *apiError.Error)
+	ListContracts(filter *models.ContractFilter) ([]models.Contract, int64, *apiError.Error)
+	ChangeStatus(id uint, status models.ContractStatus) (*models.Contract, *apiError.Error)
+	MarkPaymentPaid(contractID, paymentID uint) (*models.Contract, *apiError.Error)
+}
+
+type contractService struct {
+	contractRepo db.ContractRepository
+	rightsRepo   db.RightsRepository
+	movieRepo    db.MovieRepository
+}
+
+// NewContractService instantiate a contractService
+func NewContractService(contractRepo db.ContractRepository, rightsRepo db.RightsRepository, movieRepo db.MovieRepository) ContractService {
+	return &contractService{
+		contractRepo: contractRepo,
+		rightsRepo:   rightsRepo,
+		movieRepo:    movieRepo,
+	}
+}
+
+var hundred = decimal.NewFromInt(100)
+
+// parseDate reads a date written as 2006-01-02, field names the value in the error
+func parseDate(field, value string) (time.Time, *apiError.Error) {
+	date, err := time.Parse(models.DateLayout, value)
+	if err != nil {
+		return time.Time{}, apiError.New(field+" must be a date such as 2025-01-31", http.StatusBadRequest)
+	}
+	return date, nil
+}
+
+// isCurrencyCode reports whether code looks like an ISO 4217 currency code such as NGN
+func isCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *contractService) CreateContract(request *models.ContractRequest) (*models.Contract, *apiError.Error) {
+	contract := &models.Contract{Status: models.ContractDraft}
+	if apiErr := s.applyContractRequest(contract, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.contractRepo.CreateContract(contract); err != nil {
+		log.Printf("CreateContract error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetContract(contract.ID)
+}
+
+// UpdateContract replaces the terms of a contract, only drafts can be changed
+func (s *contractService) UpdateContract(id uint, request *models.ContractRequest) (*models.Contract, *apiError.Error) {
+	contract, apiErr := s.GetContract(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if contract.Status != models.ContractDraft {
+		return nil, apiError.New(fmt.Sprintf("contract is %s, only drafts can be changed", contract.Status), http.StatusConflict)
+	}
+	if apiErr := s.applyContractRequest(contract, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.contractRepo.UpdateContract(contract); err != nil {
+		log.Printf("UpdateContract error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetContract(contract.ID)
+}
+
+// applyContractRequest checks a request and copies it onto a contract
+func (s *contractService) applyContractRequest(contract *models.Contract, request *models.ContractRequest) *apiError.Error {
+	reference := strings.TrimSpace(request.Reference)
+	existing, err := s.contractRepo.FindContractByReference(reference)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return apiError.ErrInternalServerError
+	}
+	if err == nil && existing.ID != contract.ID {
+		return apiError.New(fmt.Sprintf("contract %s already exists", reference), http.StatusConflict)
+	}
+	if _, err := s.rightsRepo.FindLicensee(request.LicenseeID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.New("licensee not found", http.StatusNotFound)
+		}
+		return apiError.ErrInternalServerError
+	}
+
+	currency := strings.ToUpper(request.Currency)
+	if !isCurrencyCode(currency) {
+		return apiError.New("currency must be an ISO 4217 code such as NGN", http.StatusBadRequest)
+	}
+	if request.FlatFee.IsNegative() || request.MinimumGuarantee.IsNegative() {
+		return apiError.New("fees cannot be negative", http.StatusBadRequest)
+	}
+	if request.RevenueSharePercent.IsNegative() || request.RevenueSharePercent.GreaterThan(hundred) {
+		return apiError.New("revenue_share_percent must be between 0 and 100", http.StatusBadRequest)
+	}
+
+	start, apiErr := parseDate("start_date", request.StartDate)
+	if apiErr != nil {
+		return apiErr
+	}
+	var end *time.Time
+	if request.EndDate != "" {
+		date, apiErr := parseDate("end_date", request.EndDate)
+		if apiErr != nil {
+			return apiErr
+		}
+		if date.Before(start) {
+			return apiError.New("end_date cannot be before start_date", http.StatusBadRequest)
+		}
+		end = &date
+	}
+
+	var titles []models.ContractTitle
+	seen := map[models.ContractTitle]bool{}
+	for _, title := range request.Titles {
+		title = models.ContractTitle{TitleType: title.TitleType, TitleID: title.TitleID}
+		if seen[title] {
+			continue
+		}
+		if apiErr := checkTitle(s.movieRepo, title.TitleType, title.TitleID); apiErr != nil {
+			return apiErr
+		}
+		seen[title] = true
+		titles = append(titles, title)
+	}
+
+	var payments []models.ContractPayment
+	for _, payment := range request.Payments {
+		dueDate, apiErr := parseDate("due_date", payment.DueDate)
+		if apiErr != nil {
+			return apiErr
+		}
+		if !payment.Amount.IsPositive() {
+			return apiError.New("scheduled payments must be for a positive amount", http.StatusBadRequest)
+		}
+		payments = append(payments, models.ContractPayment{DueDate: dueDate, Amount: payment.Amount.Round(2), Description: payment.Description})
+	}
+
+	contract.Reference = reference
+	contract.LicenseeID = request.LicenseeID
+	contract.Currency = currency
+	contract.FlatFee = request.FlatFee.Round(2)
+	contract.MinimumGuarantee = request.MinimumGuarantee.Round(2)
+	contract.RevenueSharePercent = request.RevenueSharePercent.Round(4)
+	contract.StartDate = start
+	contract.EndDate = end
+	contract.Notes = request.Notes
+	contract.Titles = titles
+	contract.Payments = payments
+	return nil
+}
+
+func (s *contractService) GetContract(id uint) (*models.Contract, *apiError.Error) {
+	contract, err := s.contractRepo.FindContract(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return contract, nil
+}
+
+func (s *contractService) ListContracts(filter *models.ContractFilter) ([]models.Contract, int64, *apiError.Error) {
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, 0, apiError.New("status must be draft, active, expired or terminated", http.StatusBadRequest)
+	}
+	contracts, total, err := s.contractRepo.ListContracts(filter)
+	if err != nil {
+		log.Printf("ListContracts error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	return contracts, total, nil
+}
+
+// ChangeStatus moves a contract along its life cycle, see ContractStatus.CanBecome
+func (s *contractService) ChangeStatus(id uint, status models.ContractStatus) (*models.Contract, *apiError.Error) {
+	contract, apiErr := s.GetContract(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if !status.IsValid() {
+		return nil, apiError.New("status must be draft, active, expired or terminated", http.StatusBadRequest)
+	}
+	if !contract.Status.CanBecome(status) {
+		return nil, apiError.New(fmt.Sprintf("a %s contract cannot become %s", contract.Status, status), http.StatusConflict)
+	}
+	if status == models.ContractActive && len(contract.Titles) == 0 {
+		return nil, apiError.New("a contract needs titles before it becomes active", http.StatusBadRequest)
+	}
+
+	if err := s.contractRepo.UpdateContractStatus(id, status); err != nil {
+		log.Printf("ChangeStatus error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	contract.Status = status
+	return contract, nil
+}
+
+func (s *contractService) MarkPaymentPaid(contractID, paymentID uint) (*models.Contract, *apiError.Error) {
+	if err := s.contractRepo.MarkPaymentPaid(contractID, paymentID, time.Now()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.New("unpaid payment not found", http.StatusNotFound)
+		}
+		log.Printf("MarkPaymentPaid error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetContract(contractID)
+}
@@ -0,0 +1,558 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/techagentng/telair-erp/config"
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+const (
+	maxCustomFields      = 50
+	maxCustomFieldKey    = 64
+	maxCustomFieldLength = 1000
+)
+
+// CRMService manages the contacts and organizations we deal with, keeping duplicates out or merging them
+type CRMService interface {
+	CreateContact(request *models.ContactRequest, userID uint) (*models.Contact, *apiError.Error)
+	UpdateContact(id uint, request *models.ContactRequest) (*models.Contact, *apiError.Error)
+	DeleteContact(id uint) *apiError.Error
+	GetContact(id uint) (*models.Contact, *apiError.Error)
+	ListContacts(filter *models.ContactFilter) ([]models.Contact, int64, *apiError.Error)
+	ContactDuplicates() ([]models.ContactDuplicates, *apiError.Error)
+	MergeContacts(sourceID, targetID uint) (*models.Contact, *apiError.Error)
+	LinkContact(contactID, organizationID uint, request *models.ContactOrganizationRequest) (*models.Contact, *apiError.Error)
+	UnlinkContact(contactID, organizationID uint) *apiError.Error
+	CreateOrganization(request *models.OrganizationRequest, userID uint) (*models.Organization, *apiError.Error)
+	UpdateOrganization(id uint, request *models.OrganizationRequest) (*models.Organization, *apiError.Error)
+	DeleteOrganization(id uint) *apiError.Error
+	GetOrganization(id uint) (*models.Organization, *apiError.Error)
+	ListOrganizations(filter *models.OrganizationFilter) ([]models.Organization, int64, *apiError.Error)
+	OrganizationDuplicates() ([]models.OrganizationDuplicates, *apiError.Error)
+	MergeOrganizations(sourceID, targetID uint) (*models.Organization, *apiError.Error)
+}
+
+type crmService struct {
+	Config   *config.Config
+	crmRepo  db.CRMRepository
+	authRepo db.AuthRepository
+}
+
+// NewCRMService instantiate a crmService
+func NewCRMService(crmRepo db.CRMRepository, authRepo db.AuthRepository, conf *config.Config) CRMService {
+	return &crmService{
+		Config:   conf,
+		crmRepo:  crmRepo,
+		authRepo: authRepo,
+	}
+}
+
+// normalizeEmail is the form duplicates are found by
+func normalizeEmail(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// normalizePhone keeps the digits of a phone number with its calling code, e.g. 0803 123 4567 becomes
+// 2348031234567 with callingCode 234. Numbers starting with + or 00 carry their own.
+func normalizePhone(number, callingCode string) string {
+	number = strings.TrimSpace(number)
+	var digits strings.Builder
+	for _, r := range number {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	normalized := digits.String()
+	switch {
+	case strings.HasPrefix(number, "+"):
+	case strings.HasPrefix(normalized, "00"):
+		normalized = normalized[2:]
+	case strings.HasPrefix(normalized, "0"):
+		normalized = callingCode + normalized[1:]
+	}
+	return normalized
+}
+
+// channels turns the emails, phones and addresses of a request into those of a party. There is at most one
+// primary of each, the first one unless another is flagged.
+func (s *crmService) channels(emailRequests []models.EmailAddressRequest, phoneRequests []models.PhoneNumberRequest, addressRequests []models.PostalAddressRequest) ([]models.EmailAddress, []models.PhoneNumber, []models.PostalAddress, *apiError.Error) {
+	emails := []models.EmailAddress{}
+	seen := map[string]bool{}
+	primary := -1
+	for _, request := range emailRequests {
+		normalized := normalizeEmail(request.Address)
+		if normalized == "" || seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		if request.IsPrimary && primary < 0 {
+			primary = len(emails)
+		}
+		emails = append(emails, models.EmailAddress{Label: strings.TrimSpace(request.Label), Address: strings.TrimSpace(request.Address), Normalized: normalized})
+	}
+	if len(emails) > 0 {
+		if primary < 0 {
+			primary = 0
+		}
+		emails[primary].IsPrimary = true
+	}
+
+	phones := []models.PhoneNumber{}
+	seen = map[string]bool{}
+	primary = -1
+	for _, request := range phoneRequests {
+		normalized := normalizePhone(request.Number, s.Config.DefaultCallingCode)
+		if len(normalized) < 7 || len(normalized) > 15 {
+			return nil, nil, nil, apiError.New(fmt.Sprintf("%s is not a phone number", request.Number), http.StatusBadRequest)
+		}
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		if request.IsPrimary && primary < 0 {
+			primary = len(phones)
+		}
+		phones = append(phones, models.PhoneNumber{Label: strings.TrimSpace(request.Label), Number: strings.TrimSpace(request.Number), Normalized: normalized})
+	}
+	if len(phones) > 0 {
+		if primary < 0 {
+			primary = 0
+		}
+		phones[primary].IsPrimary = true
+	}
+
+	addresses := []models.PostalAddress{}
+	primary = -1
+	for _, request := range addressRequests {
+		country := strings.ToUpper(strings.TrimSpace(request.Country))
+		if country != "" && !models.IsCountryCode(country) {
+			return nil, nil, nil, apiError.New("country must be an ISO 3166-1 code such as NG", http.StatusBadRequest)
+		}
+		if request.IsPrimary && primary < 0 {
+			primary = len(addresses)
+		}
+		addresses = append(addresses, models.PostalAddress{
+			Label:      strings.TrimSpace(request.Label),
+			Line1:      strings.TrimSpace(request.Line1),
+			Line2:      strings.TrimSpace(request.Line2),
+			City:       strings.TrimSpace(request.City),
+			State:      strings.TrimSpace(request.State),
+			PostalCode: strings.TrimSpace(request.PostalCode),
+			Country:    country,
+		})
+	}
+	if len(addresses) > 0 {
+		if primary < 0 {
+			primary = 0
+		}
+		addresses[primary].IsPrimary = true
+	}
+	return emails, phones, addresses, nil
+}
+
+// customFields checks the free-form fields of a contact or organization, blank values are dropped
+func customFields(fields map[string]string) (map[string]string, *apiError.Error) {
+	if len(fields) > maxCustomFields {
+		return nil, apiError.New(fmt.Sprintf("at most %d custom fields are allowed", maxCustomFields), http.StatusBadRequest)
+	}
+	cleaned := map[string]string{}
+	for key, value := range fields {
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "" || len(key) > maxCustomFieldKey {
+			return nil, apiError.New(fmt.Sprintf("custom field names must be 1 to %d characters long", maxCustomFieldKey), http.StatusBadRequest)
+		}
+		if len(value) > maxCustomFieldLength {
+			return nil, apiError.New(fmt.Sprintf("custom field %s is longer than %d characters", key, maxCustomFieldLength), http.StatusBadRequest)
+		}
+		if value != "" {
+			cleaned[key] = value
+		}
+	}
+	return cleaned, nil
+}
+
+// mergeNotes keeps the notes of both parties of a merge
+func mergeNotes(target, source string) string {
+	if source == "" || source == target {
+		return target
+	}
+	if target == "" {
+		return source
+	}
+	return target + "\n\n" + source
+}
+
+// mergeCustomFields adds the custom fields of source that target does not have
+func mergeCustomFields(target, source map[string]string) map[string]string {
+	merged := map[string]string{}
+	for key, value := range source {
+		merged[key] = value
+	}
+	for key, value := range target {
+		merged[key] = value
+	}
+	return merged
+}
+
+// checkMatches refuses a party sharing an email or phone with another one of the same table
+func (s *crmService) checkMatches(partyType string, partyID uint, emails []models.EmailAddress, phones []models.PhoneNumber) *apiError.Error {
+	var normalizedEmails, normalizedPhones []string
+	for _, email := range emails {
+		normalizedEmails = append(normalizedEmails, email.Normalized)
+	}
+	for _, phone := range phones {
+		normalizedPhones = append(normalizedPhones, phone.Normalized)
+	}
+	matches, err := s.crmRepo.FindMatches(partyType, normalizedEmails, normalizedPhones, partyID)
+	if err != nil {
+		log.Printf("checkMatches error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	match := matches[0]
+	name := strings.TrimSuffix(partyType, "s")
+	return apiError.New(fmt.Sprintf("%s %d has %s %s already, set allow_duplicate to save a duplicate or merge them",
+		name, match.PartyIDs[0], match.Field, match.Value), http.StatusConflict)
+}
+
+func (s *crmService) CreateContact(request *models.ContactRequest, userID uint) (*models.Contact, *apiError.Error) {
+	contact := &models.Contact{OwnerID: userID}
+	if apiErr := s.applyContactRequest(contact, request, userID); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.crmRepo.CreateContact(contact); err != nil {
+		log.Printf("CreateContact error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetContact(contact.ID)
+}
+
+func (s *crmService) UpdateContact(id uint, request *models.ContactRequest) (*models.Contact, *apiError.Error) {
+	contact, apiErr := s.GetContact(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := s.applyContactRequest(contact, request, contact.OwnerID); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.crmRepo.UpdateContact(contact); err != nil {
+		log.Printf("UpdateContact error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetContact(id)
+}
+
+func (s *crmService) applyContactRequest(contact *models.Contact, request *models.ContactRequest, userID uint) *apiError.Error {
+	firstName := strings.TrimSpace(request.FirstName)
+	if firstName == "" {
+		return apiError.New("first_name is required", http.StatusBadRequest)
+	}
+	ownerID, apiErr := checkOwner(s.authRepo, request.OwnerID, userID)
+	if apiErr != nil {
+		return apiErr
+	}
+	fields, apiErr := customFields(request.CustomFields)
+	if apiErr != nil {
+		return apiErr
+	}
+	emails, phones, addresses, apiErr := s.channels(request.Emails, request.Phones, request.Addresses)
+	if apiErr != nil {
+		return apiErr
+	}
+	if !request.AllowDuplicate {
+		if apiErr := s.checkMatches(models.PartyContact, contact.ID, emails, phones); apiErr != nil {
+			return apiErr
+		}
+	}
+
+	contact.FirstName = firstName
+	contact.LastName = strings.TrimSpace(request.LastName)
+	contact.JobTitle = strings.TrimSpace(request.JobTitle)
+	contact.Notes = request.Notes
+	contact.OwnerID = ownerID
+	contact.CustomFields = fields
+	contact.Emails = emails
+	contact.Phones = phones
+	contact.Addresses = addresses
+	return nil
+}
+
+// DeleteContact archives a contact
+func (s *crmService) DeleteContact(id uint) *apiError.Error {
+	if err := s.crmRepo.DeleteContact(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.ErrNotFound
+		}
+		log.Printf("DeleteContact error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+func (s *crmService) GetContact(id uint) (*models.Contact, *apiError.Error) {
+	contact, err := s.crmRepo.FindContact(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return contact, nil
+}
+
+func (s *crmService) ListContacts(filter *models.ContactFilter) ([]models.Contact, int64, *apiError.Error) {
+	contacts, total, err := s.crmRepo.ListContacts(filter)
+	if err != nil {
+		log.Printf("ListContacts error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	return contacts, total, nil
+}
+
+// ContactDuplicates groups the contacts sharing an email or phone number, for someone to merge them
+func (s *crmService) ContactDuplicates() ([]models.ContactDuplicates, *apiError.Error) {
+	matches, err := s.crmRepo.FindDuplicates(models.PartyContact)
+	if err != nil {
+		log.Printf("ContactDuplicates error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	groups := []models.ContactDuplicates{}
+	for _, match := range matches {
+		contacts, err := s.crmRepo.FindContacts(match.PartyIDs)
+		if err != nil {
+			log.Printf("ContactDuplicates error: %v", err)
+			return nil, apiError.ErrInternalServerError
+		}
+		groups = append(groups, models.ContactDuplicates{DuplicateMatch: match, Contacts: contacts})
+	}
+	return groups, nil
+}
+
+// MergeContacts folds a duplicate contact into another one. The details target lacks are taken from
+// the duplicate, which is archived once its emails, phones, addresses, organizations and deals moved over.
+func (s *crmService) MergeContacts(sourceID, targetID uint) (*models.Contact, *apiError.Error) {
+	if sourceID == targetID {
+		return nil, apiError.New("a contact cannot be merged into itself", http.StatusBadRequest)
+	}
+	source, apiErr := s.GetContact(sourceID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	target, apiErr := s.GetContact(targetID)
+	if apiErr != nil {
+		if apiErr == apiError.ErrNotFound {
+			return nil, apiError.New("contact to merge into not found", http.StatusNotFound)
+		}
+		return nil, apiErr
+	}
+
+	if target.LastName == "" {
+		target.LastName = source.LastName
+	}
+	if target.JobTitle == "" {
+		target.JobTitle = source.JobTitle
+	}
+	target.Notes = mergeNotes(target.Notes, source.Notes)
+	target.CustomFields = mergeCustomFields(target.CustomFields, source.CustomFields)
+	if err := s.crmRepo.MergeContacts(target, source.ID); err != nil {
+		log.Printf("MergeContacts error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetContact(target.ID)
+}
+
+// LinkContact links a contact to an organization, or changes their role there
+func (s *crmService) LinkContact(contactID, organizationID uint, request *models.ContactOrganizationRequest) (*models.Contact, *apiError.Error) {
+	if _, apiErr := s.GetContact(contactID); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, apiErr := s.GetOrganization(organizationID); apiErr != nil {
+		if apiErr == apiError.ErrNotFound {
+			return nil, apiError.New("organization not found", http.StatusNotFound)
+		}
+		return nil, apiErr
+	}
+	link := &models.ContactOrganization{ContactID: contactID, OrganizationID: organizationID, Role: strings.TrimSpace(request.Role)}
+	if err := s.crmRepo.LinkContact(link); err != nil {
+		log.Printf("LinkContact error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetContact(contactID)
+}
+
+func (s *crmService) UnlinkContact(contactID, organizationID uint) *apiError.Error {
+	if err := s.crmRepo.UnlinkContact(contactID, organizationID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.New("contact is not linked to this organization", http.StatusNotFound)
+		}
+		log.Printf("UnlinkContact error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+func (s *crmService) CreateOrganization(request *models.OrganizationRequest, userID uint) (*models.Organization, *apiError.Error) {
+	organization := &models.Organization{OwnerID: userID}
+	if apiErr := s.applyOrganizationRequest(organization, request, userID); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.crmRepo.CreateOrganization(organization); err != nil {
+		log.Printf("CreateOrganization error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetOrganization(organization.ID)
+}
+
+func (s *crmService) UpdateOrganization(id uint, request *models.OrganizationRequest) (*models.Organization, *apiError.Error) {
+	organization, apiErr := s.GetOrganization(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := s.applyOrganizationRequest(organization, request, organization.OwnerID); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.crmRepo.UpdateOrganization(organization); err != nil {
+		log.Printf("UpdateOrganization error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetOrganization(id)
+}
+
+func (s *crmService) applyOrganizationRequest(organization *models.Organization, request *models.OrganizationRequest, userID uint) *apiError.Error {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return apiError.New("name is required", http.StatusBadRequest)
+	}
+	if !request.Kind.IsValid() {
+		return apiError.New("kind must be distributor, festival, vendor, talent_agency, broadcaster or other", http.StatusBadRequest)
+	}
+	ownerID, apiErr := checkOwner(s.authRepo, request.OwnerID, userID)
+	if apiErr != nil {
+		return apiErr
+	}
+	fields, apiErr := customFields(request.CustomFields)
+	if apiErr != nil {
+		return apiErr
+	}
+	emails, phones, addresses, apiErr := s.channels(request.Emails, request.Phones, request.Addresses)
+	if apiErr != nil {
+		return apiErr
+	}
+	if !request.AllowDuplicate {
+		existing, err := s.crmRepo.FindOrganizationByName(name)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.ErrInternalServerError
+		}
+		if err == nil && existing.ID != organization.ID {
+			return apiError.New(fmt.Sprintf("organization %d is called %s already, set allow_duplicate to save a duplicate or merge them", existing.ID, existing.Name), http.StatusConflict)
+		}
+		if apiErr := s.checkMatches(models.PartyOrganization, organization.ID, emails, phones); apiErr != nil {
+			return apiErr
+		}
+	}
+
+	organization.Name = name
+	organization.Kind = request.Kind
+	organization.Website = strings.TrimSpace(request.Website)
+	organization.TaxID = strings.TrimSpace(request.TaxID)
+	organization.Notes = request.Notes
+	organization.OwnerID = ownerID
+	organization.CustomFields = fields
+	organization.Emails = emails
+	organization.Phones = phones
+	organization.Addresses = addresses
+	return nil
+}
+
+// DeleteOrganization archives an organization
+func (s *crmService) DeleteOrganization(id uint) *apiError.Error {
+	if err := s.crmRepo.DeleteOrganization(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.ErrNotFound
+		}
+		log.Printf("DeleteOrganization error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+func (s *crmService) GetOrganization(id uint) (*models.Organization, *apiError.Error) {
+	organization, err := s.crmRepo.FindOrganization(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return organization, nil
+}
+
+func (s *crmService) ListOrganizations(filter *models.OrganizationFilter) ([]models.Organization, int64, *apiError.Error) {
+	organizations, total, err := s.crmRepo.ListOrganizations(filter)
+	if err != nil {
+		log.Printf("ListOrganizations error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	return organizations, total, nil
+}
+
+// OrganizationDuplicates groups the organizations sharing a name, an email or a phone number
+func (s *crmService) OrganizationDuplicates() ([]models.OrganizationDuplicates, *apiError.Error) {
+	matches, err := s.crmRepo.FindDuplicates(models.PartyOrganization)
+	if err != nil {
+		log.Printf("OrganizationDuplicates error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	groups := []models.OrganizationDuplicates{}
+	for _, match := range matches {
+		organizations, err := s.crmRepo.FindOrganizations(match.PartyIDs)
+		if err != nil {
+			log.Printf("OrganizationDuplicates error: %v", err)
+			return nil, apiError.ErrInternalServerError
+		}
+		groups = append(groups, models.OrganizationDuplicates{DuplicateMatch: match, Organizations: organizations})
+	}
+	return groups, nil
+}
+
+// MergeOrganizations folds a duplicate organization into another one, the same way MergeContacts does
+func (s *crmService) MergeOrganizations(sourceID, targetID uint) (*models.Organization, *apiError.Error) {
+	if sourceID == targetID {
+		return nil, apiError.New("an organization cannot be merged into itself", http.StatusBadRequest)
+	}
+	source, apiErr := s.GetOrganization(sourceID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	target, apiErr := s.GetOrganization(targetID)
+	if apiErr != nil {
+		if apiErr == apiError.ErrNotFound {
+			return nil, apiError.New("organization to merge into not found", http.StatusNotFound)
+		}
+		return nil, apiErr
+	}
+
+	if target.Website == "" {
+		target.Website = source.Website
+	}
+	if target.TaxID == "" {
+		target.TaxID = source.TaxID
+	}
+	target.Notes = mergeNotes(target.Notes, source.Notes)
+	target.CustomFields = mergeCustomFields(target.CustomFields, source.CustomFields)
+	if err := s.crmRepo.MergeOrganizations(target, source.ID); err != nil {
+		log.Printf("MergeOrganizations error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetOrganization(target.ID)
+}
@@ -0,0 +1,629 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/techagentng/telair-erp/config"
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+// DealService manages the sales pipelines and the deals going through them, and reports on them
+type DealService interface {
+	CreatePipeline(request *models.PipelineRequest) (*models.Pipeline, *apiError.Error)
+	UpdatePipeline(id uint, request *models.PipelineRequest) (*models.Pipeline, *apiError.Error)
+	DeletePipeline(id uint) *apiError.Error
+	GetPipeline(id uint) (*models.Pipeline, *apiError.Error)
+	ListPipelines() ([]models.Pipeline, *apiError.Error)
+	CreateDeal(request *models.DealRequest, userID uint) (*models.Deal, *apiError.Error)
+	UpdateDeal(id uint, request *models.DealRequest) (*models.Deal, *apiError.Error)
+	DeleteDeal(id uint) *apiError.Error
+	GetDeal(id uint) (*models.Deal, *apiError.Error)
+	ListDeals(filter *models.DealFilter) ([]models.Deal, int64, *apiError.Error)
+	MoveDeal(id uint, request *models.DealStageRequest, userID uint) (*models.Deal, *apiError.Error)
+	CloseDeal(id uint, request *models.DealCloseRequest, userID uint) (*models.Deal, *apiError.Error)
+	ReopenDeal(id uint, request *models.DealReopenRequest, userID uint) (*models.Deal, *apiError.Error)
+	ValueReport(filter *models.DealReportFilter, currency string) (*models.PipelineValueReport, *apiError.Error)
+	ConversionReport(filter *models.DealReportFilter) (*models.ConversionReport, *apiError.Error)
+	StageTimeReport(filter *models.DealReportFilter) (*models.StageTimeReport, *apiError.Error)
+}
+
+type dealService struct {
+	Config              *config.Config
+	dealRepo            db.DealRepository
+	crmRepo             db.CRMRepository
+	movieRepo           db.MovieRepository
+	authRepo            db.AuthRepository
+	exchangeRateService ExchangeRateService
+}
+
+// NewDealService instantiate a dealService
+func NewDealService(dealRepo db.DealRepository, crmRepo db.CRMRepository, movieRepo db.MovieRepository, authRepo db.AuthRepository, exchangeRateService ExchangeRateService, conf *config.Config) DealService {
+	return &dealService{
+		Config:              conf,
+		dealRepo:            dealRepo,
+		crmRepo:             crmRepo,
+		movieRepo:           movieRepo,
+		authRepo:            authRepo,
+		exchangeRateService: exchangeRateService,
+	}
+}
+
+// percent is part of whole in percent, rounded to two places
+func percent(part, whole int64) decimal.Decimal {
+	if whole == 0 {
+		return decimal.Zero
+	}
+	return decimal.NewFromInt(part).Mul(hundred).DivRound(decimal.NewFromInt(whole), 2)
+}
+
+func (s *dealService) CreatePipeline(request *models.PipelineRequest) (*models.Pipeline, *apiError.Error) {
+	pipeline := &models.Pipeline{}
+	if _, apiErr := s.applyPipelineRequest(pipeline, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.dealRepo.CreatePipeline(pipeline); err != nil {
+		log.Printf("CreatePipeline error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return pipeline, nil
+}
+
+func (s *dealService) UpdatePipeline(id uint, request *models.PipelineRequest) (*models.Pipeline, *apiError.Error) {
+	pipeline, apiErr := s.GetPipeline(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	removed, apiErr := s.applyPipelineRequest(pipeline, request)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.dealRepo.UpdatePipeline(pipeline, removed); err != nil {
+		log.Printf("UpdatePipeline error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetPipeline(id)
+}
+
+// applyPipelineRequest copies a request onto a pipeline and returns the stages it no longer has. Stages
+// holding open deals cannot be removed, the deals have to move first.
+func (s *dealService) applyPipelineRequest(pipeline *models.Pipeline, request *models.PipelineRequest) ([]uint, *apiError.Error) {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return nil, apiError.New("name is required", http.StatusBadRequest)
+	}
+	existing, err := s.dealRepo.FindPipelineByName(name)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apiError.ErrInternalServerError
+	}
+	if err == nil && existing.ID != pipeline.ID {
+		return nil, apiError.New(fmt.Sprintf("pipeline %s already exists", existing.Name), http.StatusConflict)
+	}
+	if len(request.Stages) == 0 {
+		return nil, apiError.New("a pipeline needs at least one stage", http.StatusBadRequest)
+	}
+
+	current := map[uint]models.PipelineStage{}
+	for _, stage := range pipeline.Stages {
+		current[stage.ID] = stage
+	}
+	var stages []models.PipelineStage
+	names := map[string]bool{}
+	for i, request := range request.Stages {
+		stageName := strings.TrimSpace(request.Name)
+		if stageName == "" {
+			return nil, apiError.New("stages need a name", http.StatusBadRequest)
+		}
+		if names[strings.ToLower(stageName)] {
+			return nil, apiError.New(fmt.Sprintf("stage %s is listed twice", stageName), http.StatusBadRequest)
+		}
+		names[strings.ToLower(stageName)] = true
+		if request.Probability < 0 || request.Probability > 100 {
+			return nil, apiError.New("probability must be between 0 and 100", http.StatusBadRequest)
+		}
+
+		stage := models.PipelineStage{PipelineID: pipeline.ID}
+		if request.ID != 0 {
+			var ok bool
+			if stage, ok = current[request.ID]; !ok {
+				return nil, apiError.New(fmt.Sprintf("stage %d is not a stage of this pipeline", request.ID), http.StatusBadRequest)
+			}
+			delete(current, request.ID)
+		}
+		stage.Name = stageName
+		stage.Position = i + 1
+		stage.Probability = request.Probability
+		stages = append(stages, stage)
+	}
+
+	var removed []uint
+	for id, stage := range current {
+		open, err := s.dealRepo.CountDeals(pipeline.ID, id, models.DealOpen)
+		if err != nil {
+			return nil, apiError.ErrInternalServerError
+		}
+		if open > 0 {
+			return nil, apiError.New(fmt.Sprintf("stage %s has %d open deals, move them before removing it", stage.Name, open), http.StatusConflict)
+		}
+		removed = append(removed, id)
+	}
+
+	pipeline.Name = name
+	pipeline.Description = request.Description
+	pipeline.Stages = stages
+	return removed, nil
+}
+
+// DeletePipeline removes a pipeline no deal ever went through
+func (s *dealService) DeletePipeline(id uint) *apiError.Error {
+	if _, apiErr := s.GetPipeline(id); apiErr != nil {
+		return apiErr
+	}
+	deals, err := s.dealRepo.CountDeals(id, 0, "")
+	if err != nil {
+		return apiError.ErrInternalServerError
+	}
+	if deals > 0 {
+		return apiError.New(fmt.Sprintf("pipeline has %d deals and cannot be deleted", deals), http.StatusConflict)
+	}
+	if err := s.dealRepo.DeletePipeline(id); err != nil {
+		log.Printf("DeletePipeline error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+func (s *dealService) GetPipeline(id uint) (*models.Pipeline, *apiError.Error) {
+	pipeline, err := s.dealRepo.FindPipeline(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return pipeline, nil
+}
+
+func (s *dealService) ListPipelines() ([]models.Pipeline, *apiError.Error) {
+	pipelines, err := s.dealRepo.ListPipelines()
+	if err != nil {
+		log.Printf("ListPipelines error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return pipelines, nil
+}
+
+// findStage returns a stage a deal can go to, one of a pipeline that still exists
+func (s *dealService) findStage(id uint) (*models.PipelineStage, *apiError.Error) {
+	stage, err := s.dealRepo.FindStage(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.New("stage not found", http.StatusNotFound)
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return stage, nil
+}
+
+func (s *dealService) CreateDeal(request *models.DealRequest, userID uint) (*models.Deal, *apiError.Error) {
+	pipeline, apiErr := s.GetPipeline(request.PipelineID)
+	if apiErr != nil {
+		if apiErr == apiError.ErrNotFound {
+			return nil, apiError.New("pipeline not found", http.StatusNotFound)
+		}
+		return nil, apiErr
+	}
+	if len(pipeline.Stages) == 0 {
+		return nil, apiError.New("pipeline has no stages", http.StatusUnprocessableEntity)
+	}
+	stage := pipeline.Stages[0]
+	if request.StageID != 0 {
+		found := false
+		for _, candidate := range pipeline.Stages {
+			if candidate.ID == request.StageID {
+				stage, found = candidate, true
+			}
+		}
+		if !found {
+			return nil, apiError.New(fmt.Sprintf("stage %d is not a stage of this pipeline", request.StageID), http.StatusBadRequest)
+		}
+	}
+
+	now := time.Now().UTC()
+	deal := &models.Deal{
+		PipelineID:     pipeline.ID,
+		StageID:        stage.ID,
+		Status:         models.DealOpen,
+		StageEnteredAt: now,
+	}
+	if apiErr := s.applyDealRequest(deal, request, userID); apiErr != nil {
+		return nil, apiErr
+	}
+	change := &models.DealStageChange{ToStageID: stage.ID, Status: models.DealOpen, ChangedBy: userID, ChangedAt: now}
+	if err := s.dealRepo.CreateDeal(deal, change); err != nil {
+		log.Printf("CreateDeal error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetDeal(deal.ID)
+}
+
+func (s *dealService) UpdateDeal(id uint, request *models.DealRequest) (*models.Deal, *apiError.Error) {
+	deal, apiErr := s.GetDeal(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := s.applyDealRequest(deal, request, deal.OwnerID); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.dealRepo.UpdateDeal(deal); err != nil {
+		log.Printf("UpdateDeal error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetDeal(id)
+}
+
+// applyDealRequest checks a request and copies it onto a deal, which is with a contact, an organization or both
+func (s *dealService) applyDealRequest(deal *models.Deal, request *models.DealRequest, userID uint) *apiError.Error {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return apiError.New("name is required", http.StatusBadRequest)
+	}
+	currency := strings.ToUpper(request.Currency)
+	if !isCurrencyCode(currency) {
+		return apiError.New("currency must be an ISO 4217 code such as NGN", http.StatusBadRequest)
+	}
+	if request.Value.IsNegative() {
+		return apiError.New("value cannot be negative", http.StatusBadRequest)
+	}
+	var expectedClose *time.Time
+	if request.ExpectedCloseDate != "" {
+		date, apiErr := parseDate("expected_close_date", request.ExpectedCloseDate)
+		if apiErr != nil {
+			return apiErr
+		}
+		expectedClose = &date
+	}
+
+	if request.ContactID == 0 && request.OrganizationID == 0 {
+		return apiError.New("a deal needs a contact_id, an organization_id or both", http.StatusBadRequest)
+	}
+	var contactID, organizationID *uint
+	if request.ContactID != 0 {
+		if _, err := s.crmRepo.FindContact(request.ContactID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apiError.New("contact not found", http.StatusNotFound)
+			}
+			return apiError.ErrInternalServerError
+		}
+		contactID = &request.ContactID
+	}
+	if request.OrganizationID != 0 {
+		if _, err := s.crmRepo.FindOrganization(request.OrganizationID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apiError.New("organization not found", http.StatusNotFound)
+			}
+			return apiError.ErrInternalServerError
+		}
+		organizationID = &request.OrganizationID
+	}
+	ownerID, apiErr := checkOwner(s.authRepo, request.OwnerID, userID)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	titles := []models.DealTitle{}
+	seen := map[models.DealTitle]bool{}
+	for _, title := range request.Titles {
+		title = models.DealTitle{TitleType: title.TitleType, TitleID: title.TitleID}
+		if seen[title] {
+			continue
+		}
+		if apiErr := checkTitle(s.movieRepo, title.TitleType, title.TitleID); apiErr != nil {
+			return apiErr
+		}
+		seen[title] = true
+		titles = append(titles, title)
+	}
+
+	deal.Name = name
+	deal.Value = request.Value.Round(2)
+	deal.Currency = currency
+	deal.ExpectedCloseDate = expectedClose
+	deal.ContactID = contactID
+	deal.OrganizationID = organizationID
+	deal.OwnerID = ownerID
+	deal.Titles = titles
+	deal.Notes = request.Notes
+	return nil
+}
+
+func (s *dealService) DeleteDeal(id uint) *apiError.Error {
+	if err := s.dealRepo.DeleteDeal(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.ErrNotFound
+		}
+		log.Printf("DeleteDeal error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+func (s *dealService) GetDeal(id uint) (*models.Deal, *apiError.Error) {
+	deal, err := s.dealRepo.FindDeal(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return deal, nil
+}
+
+func (s *dealService) ListDeals(filter *models.DealFilter) ([]models.Deal, int64, *apiError.Error) {
+	deals, total, err := s.dealRepo.ListDeals(filter)
+	if err != nil {
+		log.Printf("ListDeals error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	return deals, total, nil
+}
+
+// changeDeal records a deal entering a stage in a status, the change keeps the stage it came from
+func (s *dealService) changeDeal(deal *models.Deal, stage *models.PipelineStage, status models.DealStatus, userID uint) *apiError.Error {
+	now := time.Now().UTC()
+	from := deal.StageID
+	change := &models.DealStageChange{FromStageID: &from, ToStageID: stage.ID, Status: status, ChangedBy: userID, ChangedAt: now}
+
+	// a reopened deal starts a new stay in its stage
+	if stage.ID != deal.StageID || deal.Status != models.DealOpen {
+		deal.StageEnteredAt = now
+	}
+	deal.PipelineID = stage.PipelineID
+	deal.StageID = stage.ID
+	deal.Status = status
+	if err := s.dealRepo.SaveDealChange(deal, change); err != nil {
+		log.Printf("changeDeal error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+// MoveDeal moves an open deal to another stage, of its pipeline or of another one
+func (s *dealService) MoveDeal(id uint, request *models.DealStageRequest, userID uint) (*models.Deal, *apiError.Error) {
+	deal, apiErr := s.GetDeal(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if deal.Status != models.DealOpen {
+		return nil, apiError.New(fmt.Sprintf("deal is %s, reopen it to move it", deal.Status), http.StatusConflict)
+	}
+	if request.StageID == deal.StageID {
+		return deal, nil
+	}
+	stage, apiErr := s.findStage(request.StageID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := s.changeDeal(deal, stage, models.DealOpen, userID); apiErr != nil {
+		return nil, apiErr
+	}
+	return s.GetDeal(id)
+}
+
+// CloseDeal marks an open deal won or lost in the stage it is in
+func (s *dealService) CloseDeal(id uint, request *models.DealCloseRequest, userID uint) (*models.Deal, *apiError.Error) {
+	if request.Status != models.DealWon && request.Status != models.DealLost {
+		return nil, apiError.New("status must be won or lost", http.StatusBadRequest)
+	}
+	reason := strings.TrimSpace(request.Reason)
+	if request.Status == models.DealLost && reason == "" {
+		return nil, apiError.New("reason is required for lost deals", http.StatusBadRequest)
+	}
+	deal, apiErr := s.GetDeal(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if deal.Status != models.DealOpen {
+		return nil, apiError.New(fmt.Sprintf("deal is %s already", deal.Status), http.StatusConflict)
+	}
+
+	now := time.Now().UTC()
+	deal.CloseReason = reason
+	deal.ClosedAt = &now
+	stage := &models.PipelineStage{Model: models.Model{ID: deal.StageID}, PipelineID: deal.PipelineID}
+	if apiErr := s.changeDeal(deal, stage, request.Status, userID); apiErr != nil {
+		return nil, apiErr
+	}
+	return s.GetDeal(id)
+}
+
+// ReopenDeal opens a won or lost deal again, in the stage it was closed in or the one asked for
+func (s *dealService) ReopenDeal(id uint, request *models.DealReopenRequest, userID uint) (*models.Deal, *apiError.Error) {
+	deal, apiErr := s.GetDeal(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if deal.Status == models.DealOpen {
+		return nil, apiError.New("deal is open already", http.StatusConflict)
+	}
+	stageID := request.StageID
+	if stageID == 0 {
+		stageID = deal.StageID
+	}
+	stage, apiErr := s.findStage(stageID)
+	if apiErr != nil {
+		if apiErr.Status == http.StatusNotFound && request.StageID == 0 {
+			return nil, apiError.New("the stage the deal was closed in was removed, give a stage_id", http.StatusUnprocessableEntity)
+		}
+		return nil, apiErr
+	}
+
+	deal.CloseReason = ""
+	deal.ClosedAt = nil
+	if apiErr := s.changeDeal(deal, stage, models.DealOpen, userID); apiErr != nil {
+		return nil, apiErr
+	}
+	return s.GetDeal(id)
+}
+
+// reportPipeline returns the pipeline a report is about
+func (s *dealService) reportPipeline(filter *models.DealReportFilter) (*models.Pipeline, *apiError.Error) {
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		return nil, apiError.New("to cannot be before from", http.StatusBadRequest)
+	}
+	return s.GetPipeline(filter.PipelineID)
+}
+
+// ValueReport adds up the open deals of a pipeline by stage, converted to one currency at today's rates
+func (s *dealService) ValueReport(filter *models.DealReportFilter, currency string) (*models.PipelineValueReport, *apiError.Error) {
+	if currency == "" {
+		currency = s.Config.ReportingCurrency
+	}
+	currency = strings.ToUpper(currency)
+	if !isCurrencyCode(currency) {
+		return nil, apiError.New("currency must be an ISO 4217 code such as NGN", http.StatusBadRequest)
+	}
+	pipeline, apiErr := s.reportPipeline(filter)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	totals, err := s.dealRepo.FindStageTotals(filter)
+	if err != nil {
+		log.Printf("ValueReport error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+
+	today := time.Now().UTC()
+	report := &models.PipelineValueReport{
+		PipelineID:    pipeline.ID,
+		Currency:      currency,
+		AsOf:          today.Format(models.DateLayout),
+		Stages:        []models.StageValue{},
+		Value:         decimal.Zero,
+		WeightedValue: decimal.Zero,
+	}
+	index := map[uint]int{}
+	for i, stage := range pipeline.Stages {
+		index[stage.ID] = i
+		report.Stages = append(report.Stages, models.StageValue{
+			StageID:       stage.ID,
+			Name:          stage.Name,
+			Probability:   stage.Probability,
+			Value:         decimal.Zero,
+			WeightedValue: decimal.Zero,
+		})
+	}
+	for _, total := range totals {
+		i, ok := index[total.StageID]
+		if !ok {
+			continue
+		}
+		value, _, apiErr := s.exchangeRateService.Convert(total.Total, total.Currency, currency, today)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		stage := &report.Stages[i]
+		stage.Deals += total.Deals
+		stage.Value = stage.Value.Add(value)
+	}
+	for i := range report.Stages {
+		stage := &report.Stages[i]
+		stage.WeightedValue = stage.Value.Mul(decimal.NewFromInt(int64(stage.Probability))).DivRound(hundred, 2)
+		report.Deals += stage.Deals
+		report.Value = report.Value.Add(stage.Value)
+		report.WeightedValue = report.WeightedValue.Add(stage.WeightedValue)
+	}
+	return report, nil
+}
+
+// ConversionReport counts, for every stage of a pipeline, the deals that reached it and those that went
+// on to a later stage. Deals skipping stages count as having gone through them, and won deals as having
+// gone through all of them.
+func (s *dealService) ConversionReport(filter *models.DealReportFilter) (*models.ConversionReport, *apiError.Error) {
+	pipeline, apiErr := s.reportPipeline(filter)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	progress, err := s.dealRepo.FindDealProgress(filter)
+	if err != nil {
+		log.Printf("ConversionReport error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+
+	report := &models.ConversionReport{PipelineID: pipeline.ID, Stages: []models.StageConversion{}}
+	for _, deal := range progress {
+		report.Deals++
+		switch deal.Status {
+		case models.DealWon:
+			report.Won++
+		case models.DealLost:
+			report.Lost++
+		}
+	}
+	for i, stage := range pipeline.Stages {
+		conversion := models.StageConversion{StageID: stage.ID, Name: stage.Name}
+		for _, deal := range progress {
+			if deal.Status != models.DealWon && deal.Position < stage.Position {
+				continue
+			}
+			conversion.Reached++
+			last := i == len(pipeline.Stages)-1
+			if deal.Status == models.DealWon || (!last && deal.Position >= pipeline.Stages[i+1].Position) {
+				conversion.Advanced++
+			}
+		}
+		conversion.Rate = percent(conversion.Advanced, conversion.Reached)
+		report.Stages = append(report.Stages, conversion)
+	}
+	report.WinRate = percent(report.Won, report.Won+report.Lost)
+	return report, nil
+}
+
+// StageTimeReport works out from the stage changes of the deals of a pipeline how long they stay in each
+// stage. A stay ends when the deal moves on or is closed, open deals are still in their stage now.
+func (s *dealService) StageTimeReport(filter *models.DealReportFilter) (*models.StageTimeReport, *apiError.Error) {
+	pipeline, apiErr := s.reportPipeline(filter)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	changes, err := s.dealRepo.FindStageChanges(filter)
+	if err != nil {
+		log.Printf("StageTimeReport error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+
+	stays := map[uint]int64{}
+	spent := map[uint]time.Duration{}
+	now := time.Now().UTC()
+	for i, change := range changes {
+		if change.Status != models.DealOpen {
+			continue
+		}
+		end := now
+		if i+1 < len(changes) && changes[i+1].DealID == change.DealID {
+			end = changes[i+1].ChangedAt
+		}
+		stays[change.ToStageID]++
+		spent[change.ToStageID] += end.Sub(change.ChangedAt)
+	}
+
+	report := &models.StageTimeReport{PipelineID: pipeline.ID, Stages: []models.StageDuration{}}
+	day := decimal.NewFromInt(int64(24 * time.Hour))
+	for _, stage := range pipeline.Stages {
+		duration := models.StageDuration{StageID: stage.ID, Name: stage.Name, Stays: stays[stage.ID], AverageDays: decimal.Zero}
+		if duration.Stays > 0 {
+			average := decimal.NewFromInt(int64(spent[stage.ID])).Div(decimal.NewFromInt(duration.Stays))
+			duration.AverageDays = average.DivRound(day, 2)
+		}
+		report.Stages = append(report.Stages, duration)
+	}
+	return report, nil
+}
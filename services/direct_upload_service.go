@@ -0,0 +1,367 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/techagentng/telair-erp/config"
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/media"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/storage"
+	"gorm.io/gorm"
+)
+
+// DirectUploadService hands out presigned requests so files go straight to storage
+type DirectUploadService interface {
+	CreateUpload(userID uint, role string, request *models.DirectUploadRequest) (*models.DirectUpload, *storage.PresignedRequest, *apiError.Error)
+	PresignPart(id string, userID uint, request *models.PresignPartRequest) (*storage.PresignedRequest, *apiError.Error)
+	CompleteUpload(id string, userID uint, request *models.CompleteDirectUploadRequest) (*models.MediaAsset, *apiError.Error)
+	DownloadURL(assetID, userID uint, role string) (*storage.PresignedRequest, *apiError.Error)
+	CleanupExpired(ctx context.Context) error
+}
+
+type directUploadService struct {
+	Config       *config.Config
+	uploadRepo   db.UploadRepository
+	movieRepo    db.MovieRepository
+	mediaService MediaService
+	storage      storage.Storage
+}
+
+// NewDirectUploadService instantiate a directUploadService
+func NewDirectUploadService(uploadRepo db.UploadRepository, movieRepo db.MovieRepository, mediaService MediaService, store storage.Storage, conf *config.Config) DirectUploadService {
+	return &directUploadService{
+		Config:       conf,
+		uploadRepo:   uploadRepo,
+		movieRepo:    movieRepo,
+		mediaService: mediaService,
+		storage:      store,
+	}
+}
+
+func (d *directUploadService) expiry() time.Duration {
+	return time.Duration(d.Config.PresignExpiryMinutes) * time.Minute
+}
+
+// CreateUpload starts an upload of media for a title, which only admins and the owner of the title can
+func (d *directUploadService) CreateUpload(userID uint, role string, request *models.DirectUploadRequest) (*models.DirectUpload, *storage.PresignedRequest, *apiError.Error) {
+	if !request.Multipart && request.ChecksumSHA256 == "" {
+		return nil, nil, apiError.New("checksum_sha256 is required for single part uploads", http.StatusBadRequest)
+	}
+	if apiErr := checkTitleUpload(d.movieRepo, request.TitleType, request.TitleID, userID, role); apiErr != nil {
+		return nil, nil, apiErr
+	}
+
+	upload := &models.DirectUpload{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		TitleType:   request.TitleType,
+		TitleID:     request.TitleID,
+		Kind:        request.Kind,
+		Filename:    request.Filename,
+		ContentType: request.ContentType,
+		Size:        request.Size,
+		ExpiresAt:   time.Now().Add(d.expiry()),
+	}
+	upload.StorageKey = storage.NewKey(upload.TitleType.StoragePrefix(upload.TitleID)+"/"+upload.Kind.StorageFolder(), upload.Filename)
+
+	ctx := context.Background()
+	opts := storage.UploadOptions{ContentType: upload.ContentType, VerifyParts: true}
+	var presigned *storage.PresignedRequest
+	if request.Multipart {
+		multipartID, err := d.storage.CreateMultipart(ctx, upload.StorageKey, opts)
+		if err != nil {
+			log.Printf("CreateUpload error: %v", err)
+			return nil, nil, apiError.ErrInternalServerError
+		}
+		upload.MultipartID = multipartID
+		// Parts are signed one by one, multipart uploads get the tus expiry since they take longer
+		upload.ExpiresAt = time.Now().Add(time.Duration(d.Config.TusUploadExpiryHours) * time.Hour)
+	} else {
+		upload.ChecksumSHA256 = request.ChecksumSHA256
+		var err error
+		presigned, err = d.storage.PresignPut(ctx, upload.StorageKey, upload.Size, upload.ChecksumSHA256, opts, d.expiry())
+		if err != nil {
+			log.Printf("CreateUpload error: %v", err)
+			return nil, nil, apiError.ErrInternalServerError
+		}
+	}
+
+	if err := d.uploadRepo.CreateDirectUpload(upload); err != nil {
+		log.Printf("CreateUpload error: %v", err)
+		return nil, nil, apiError.ErrInternalServerError
+	}
+	return upload, presigned, nil
+}
+
+func (d *directUploadService) findUpload(id string, userID uint) (*models.DirectUpload, *apiError.Error) {
+	upload, err := d.uploadRepo.FindDirectUpload(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	if upload.UserID != userID {
+		return nil, apiError.ErrNotFound
+	}
+	if upload.Completed {
+		return nil, apiError.New("upload already completed", http.StatusConflict)
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		return nil, apiError.New("upload expired", http.StatusGone)
+	}
+	return upload, nil
+}
+
+// PresignPart signs one part of a multipart upload, the client has to send the part with the given checksum
+func (d *directUploadService) PresignPart(id string, userID uint, request *models.PresignPartRequest) (*storage.PresignedRequest, *apiError.Error) {
+	upload, apiErr := d.findUpload(id, userID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if !upload.IsMultipart() {
+		return nil, apiError.New("upload is not a multipart upload", http.StatusBadRequest)
+	}
+
+	part := &models.DirectUploadPart{
+		UploadID:       upload.ID,
+		PartNumber:     request.PartNumber,
+		Size:           request.Size,
+		ChecksumSHA256: request.ChecksumSHA256,
+	}
+	if err := d.uploadRepo.SaveDirectUploadPart(part); err != nil {
+		log.Printf("PresignPart error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+
+	presigned, err := d.storage.PresignUploadPart(context.Background(), upload.StorageKey, upload.MultipartID, part.PartNumber, part.Size, part.ChecksumSHA256, d.expiry())
+	if err != nil {
+		log.Printf("PresignPart error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return presigned, nil
+}
+
+// CompleteUpload checks that the object the client sent has the announced size and checksum
+// before attaching it to its title. Objects that fail the check are removed.
+func (d *directUploadService) CompleteUpload(id string, userID uint, request *models.CompleteDirectUploadRequest) (*models.MediaAsset, *apiError.Error) {
+	upload, apiErr := d.findUpload(id, userID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	ctx := context.Background()
+	expectedChecksum := upload.ChecksumSHA256
+	if upload.IsMultipart() {
+		checksum, apiErr := d.completeMultipart(ctx, upload, request.Parts)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		expectedChecksum = checksum
+	}
+
+	info, err := d.storage.Head(ctx, upload.StorageKey)
+	if err != nil {
+		log.Printf("CompleteUpload error: %v", err)
+		return nil, apiError.New("uploaded object not found in storage", http.StatusBadRequest)
+	}
+	if info.Size != upload.Size || info.ChecksumSHA256 != expectedChecksum {
+		if err := d.storage.Delete(ctx, upload.StorageKey); err != nil {
+			log.Printf("CompleteUpload error removing %s: %v", upload.StorageKey, err)
+		}
+		return nil, apiError.New("uploaded object does not match the announced size and checksum", http.StatusBadRequest)
+	}
+	if apiErr := d.checkObjectType(ctx, upload); apiErr != nil {
+		if err := d.storage.Delete(ctx, upload.StorageKey); err != nil {
+			log.Printf("CompleteUpload error removing %s: %v", upload.StorageKey, err)
+		}
+		return nil, apiErr
+	}
+
+	asset := &models.MediaAsset{
+		TitleType:   upload.TitleType,
+		TitleID:     upload.TitleID,
+		Kind:        upload.Kind,
+		StorageKey:  upload.StorageKey,
+		URL:         d.storage.URL(upload.StorageKey),
+		Filename:    upload.Filename,
+		ContentType: upload.ContentType,
+		Size:        upload.Size,
+		UploadedBy:  upload.UserID,
+	}
+	if !upload.IsMultipart() {
+		// Single part checksums cover the whole file, keep the digest with the asset. Multipart
+		// objects only have a checksum of their parts so they are never deduplicated.
+		if sum, err := base64.StdEncoding.DecodeString(upload.ChecksumSHA256); err == nil {
+			asset.SHA256 = hex.EncodeToString(sum)
+		}
+	}
+	asset, err = d.mediaService.AttachAsset(ctx, asset)
+	if err != nil {
+		log.Printf("CompleteUpload error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+
+	upload.Completed = true
+	upload.MediaAssetID = &asset.ID
+	if err := d.uploadRepo.UpdateDirectUpload(upload); err != nil {
+		log.Printf("CompleteUpload error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return asset, nil
+}
+
+// checkObjectType sniffs the first bytes of the stored object, the file never went through us
+func (d *directUploadService) checkObjectType(ctx context.Context, upload *models.DirectUpload) *apiError.Error {
+	object, err := d.storage.Open(ctx, upload.StorageKey)
+	if err != nil {
+		log.Printf("CompleteUpload error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	defer object.Close()
+
+	header := make([]byte, media.SniffLen)
+	n, err := io.ReadFull(object, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		log.Printf("CompleteUpload error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	contentType, apiErr := checkFileType(upload.ContentType, header[:n])
+	if apiErr != nil {
+		return apiErr
+	}
+	upload.ContentType = contentType
+	return nil
+}
+
+// completeMultipart assembles the parts and returns the composite checksum S3 should report for them
+func (d *directUploadService) completeMultipart(ctx context.Context, upload *models.DirectUpload, completed []models.CompletedUploadPart) (string, *apiError.Error) {
+	signed, err := d.uploadRepo.FindDirectUploadParts(upload.ID)
+	if err != nil {
+		return "", apiError.ErrInternalServerError
+	}
+	signedByNumber := map[int32]models.DirectUploadPart{}
+	for _, part := range signed {
+		signedByNumber[part.PartNumber] = part
+	}
+
+	var size int64
+	parts := make([]storage.Part, 0, len(completed))
+	checksums := make([]string, 0, len(completed))
+	for i, part := range completed {
+		record, ok := signedByNumber[part.PartNumber]
+		if !ok {
+			return "", apiError.New(fmt.Sprintf("part %d was never signed", part.PartNumber), http.StatusBadRequest)
+		}
+		if i > 0 && part.PartNumber <= completed[i-1].PartNumber {
+			return "", apiError.New("parts must be listed in ascending order", http.StatusBadRequest)
+		}
+		size += record.Size
+		parts = append(parts, storage.Part{Number: part.PartNumber, ETag: part.ETag, ChecksumSHA256: record.ChecksumSHA256, Size: record.Size})
+		checksums = append(checksums, record.ChecksumSHA256)
+	}
+	if size != upload.Size {
+		return "", apiError.New("parts do not add up to the announced size", http.StatusBadRequest)
+	}
+
+	if err := d.storage.CompleteMultipart(ctx, upload.StorageKey, upload.MultipartID, parts); err != nil {
+		log.Printf("CompleteUpload error: %v", err)
+		return "", apiError.New("unable to complete multipart upload", http.StatusBadRequest)
+	}
+
+	checksum, err := storage.CompositeChecksum(checksums)
+	if err != nil {
+		return "", apiError.New(err.Error(), http.StatusBadRequest)
+	}
+	return checksum, nil
+}
+
+// checkTitleUpload makes sure a title exists and the user can upload media for it, being an admin or
+// the owner of the title
+func checkTitleUpload(movieRepo db.MovieRepository, titleType models.TitleType, titleID, userID uint, role string) *apiError.Error {
+	if !titleType.IsValid() {
+		return apiError.New(fmt.Sprintf("unknown title type: %s", titleType), http.StatusBadRequest)
+	}
+	if _, err := movieRepo.FindTitle(titleType, titleID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.New("title not found", http.StatusNotFound)
+		}
+		log.Printf("checkTitleUpload error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	if role == models.RoleAdmin {
+		return nil
+	}
+	owner, err := movieRepo.FindTitleOwner(titleType, titleID)
+	if err != nil {
+		log.Printf("checkTitleUpload error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	if owner != userID {
+		return apiError.New("you are not allowed to upload media for this title", http.StatusForbidden)
+	}
+	return nil
+}
+
+// DownloadURL signs a short-lived link to a private media asset, for admins, whoever uploaded the
+// asset and the owner of its title
+func (d *directUploadService) DownloadURL(assetID, userID uint, role string) (*storage.PresignedRequest, *apiError.Error) {
+	asset, err := d.movieRepo.FindMediaAsset(assetID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	if role != models.RoleAdmin && asset.UploadedBy != userID {
+		owner, err := d.movieRepo.FindTitleOwner(asset.TitleType, asset.TitleID)
+		if err != nil {
+			log.Printf("DownloadURL error: %v", err)
+			return nil, apiError.ErrInternalServerError
+		}
+		if owner != userID {
+			return nil, apiError.New("you are not allowed to download this media asset", http.StatusForbidden)
+		}
+	}
+
+	presigned, err := d.storage.PresignGet(context.Background(), asset.StorageKey, d.expiry())
+	if err != nil {
+		log.Printf("DownloadURL error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return presigned, nil
+}
+
+// CleanupExpired throws away direct uploads the client never completed
+func (d *directUploadService) CleanupExpired(ctx context.Context) error {
+	uploads, err := d.uploadRepo.FindExpiredDirectUploads(time.Now())
+	if err != nil {
+		return fmt.Errorf("error finding expired direct uploads: %w", err)
+	}
+	for _, upload := range uploads {
+		if upload.IsMultipart() {
+			err = d.storage.AbortMultipart(ctx, upload.StorageKey, upload.MultipartID)
+		} else {
+			err = d.storage.Delete(ctx, upload.StorageKey)
+		}
+		if err != nil {
+			log.Printf("CleanupExpired error for direct upload %s: %v", upload.ID, err)
+			continue
+		}
+		if err := d.uploadRepo.DeleteDirectUpload(upload.ID); err != nil {
+			log.Printf("CleanupExpired error for direct upload %s: %v", upload.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+// ExchangeRateService keeps the exchange rates amounts in other currencies are converted with
+type ExchangeRateService interface {
+	SaveRate(request *models.ExchangeRateRequest, userID uint) (*models.ExchangeRate, *apiError.Error)
+	ListRates(base, quote string, page, limit int) ([]models.ExchangeRate, int64, *apiError.Error)
+	Convert(amount decimal.Decimal, from, to string, on time.Time) (decimal.Decimal, decimal.Decimal, *apiError.Error)
+}
+
+type exchangeRateService struct {
+	exchangeRateRepo db.ExchangeRateRepository
+}
+
+// NewExchangeRateService instantiate an exchangeRateService
+func NewExchangeRateService(exchangeRateRepo db.ExchangeRateRepository) ExchangeRateService {
+	return &exchangeRateService{
+		exchangeRateRepo: exchangeRateRepo,
+	}
+}
+
+// SaveRate stores what one unit of the base currency is worth in the quote currency from a day on
+func (s *exchangeRateService) SaveRate(request *models.ExchangeRateRequest, userID uint) (*models.ExchangeRate, *apiError.Error) {
+	base := strings.ToUpper(request.Base)
+	quote := strings.ToUpper(request.Quote)
+	if !isCurrencyCode(base) || !isCurrencyCode(quote) {
+		return nil, apiError.New("currencies must be ISO 4217 codes such as NGN", http.StatusBadRequest)
+	}
+	if base == quote {
+		return nil, apiError.New("base and quote must be different currencies", http.StatusBadRequest)
+	}
+	if !request.Rate.IsPositive() {
+		return nil, apiError.New("rate must be positive", http.StatusBadRequest)
+	}
+	effectiveOn, apiErr := parseDate("effective_on", request.EffectiveOn)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	rate := &models.ExchangeRate{
+		Base:        base,
+		Quote:       quote,
+		EffectiveOn: effectiveOn,
+		Rate:        request.Rate.Round(8),
+		CreatedBy:   userID,
+		CreatedAt:   time.Now().Unix(),
+	}
+	if err := s.exchangeRateRepo.SaveRate(rate); err != nil {
+		log.Printf("SaveRate error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return rate, nil
+}
+
+func (s *exchangeRateService) ListRates(base, quote string, page, limit int) ([]models.ExchangeRate, int64, *apiError.Error) {
+	rates, total, err := s.exchangeRateRepo.ListRates(strings.ToUpper(base), strings.ToUpper(quote), limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("ListRates error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	return rates, total, nil
+}
+
+// Convert turns an amount in one currency into another at the latest rate effective on a day, a rate stored
+// the other way round is inverted. It returns the converted amount, rounded to the cent, and the rate used.
+func (s *exchangeRateService) Convert(amount decimal.Decimal, from, to string, on time.Time) (decimal.Decimal, decimal.Decimal, *apiError.Error) {
+	if from == to {
+		return amount, decimal.NewFromInt(1), nil
+	}
+	rate, err := s.exchangeRateRepo.FindRate(from, to, on)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return decimal.Zero, decimal.Zero, apiError.New(fmt.Sprintf("no exchange rate from %s to %s on or before %s",
+				from, to, on.Format(models.DateLayout)), http.StatusUnprocessableEntity)
+		}
+		return decimal.Zero, decimal.Zero, apiError.ErrInternalServerError
+	}
+
+	factor := rate.Rate
+	if rate.Base != from {
+		factor = decimal.NewFromInt(1).DivRound(rate.Rate, 8)
+	}
+	return amount.Mul(factor).Round(2), factor, nil
+}
@@ -0,0 +1,662 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/techagentng/telair-erp/config"
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	mailingservices "github.com/techagentng/telair-erp/mailingservice"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/pdf"
+	"gorm.io/gorm"
+)
+
+// InvoiceService bills organizations: it manages tax rates, invoices and the payments received against
+// them, sends invoices out as PDF documents and reports on what is owed
+type InvoiceService interface {
+	CreateTaxRate(request *models.TaxRateRequest) (*models.TaxRate, *apiError.Error)
+	UpdateTaxRate(id uint, request *models.TaxRateRequest) (*models.TaxRate, *apiError.Error)
+	DeleteTaxRate(id uint) *apiError.Error
+	ListTaxRates() ([]models.TaxRate, *apiError.Error)
+	CreateInvoice(request *models.InvoiceRequest, userID uint) (*models.Invoice, *apiError.Error)
+	UpdateInvoice(id uint, request *models.InvoiceRequest) (*models.Invoice, *apiError.Error)
+	DeleteInvoice(id uint) *apiError.Error
+	GetInvoice(id uint) (*models.Invoice, *apiError.Error)
+	ListInvoices(filter *models.InvoiceFilter) ([]models.Invoice, int64, *apiError.Error)
+	InvoicePDF(id uint) (*models.Invoice, []byte, *apiError.Error)
+	SendInvoice(id uint, request *models.InvoiceSendRequest, userID uint) (*models.Invoice, *apiError.Error)
+	VoidInvoice(id uint, request *models.InvoiceVoidRequest, userID uint) (*models.Invoice, *apiError.Error)
+	RecordPayment(id uint, request *models.InvoicePaymentRequest, userID uint) (*models.Invoice, *apiError.Error)
+	DeletePayment(invoiceID, paymentID, userID uint) (*models.Invoice, *apiError.Error)
+	AgingReport(asOf time.Time, currency string) (*models.AgingReport, *apiError.Error)
+}
+
+type invoiceService struct {
+	Config              *config.Config
+	invoiceRepo         db.InvoiceRepository
+	crmRepo             db.CRMRepository
+	dealRepo            db.DealRepository
+	contractRepo        db.ContractRepository
+	exchangeRateService ExchangeRateService
+	postingService      PostingService
+	mailer              mailingservices.Mailer
+}
+
+// NewInvoiceService instantiate an invoiceService
+func NewInvoiceService(invoiceRepo db.InvoiceRepository, crmRepo db.CRMRepository, dealRepo db.DealRepository, contractRepo db.ContractRepository, exchangeRateService ExchangeRateService, postingService PostingService, mailer mailingservices.Mailer, conf *config.Config) InvoiceService {
+	return &invoiceService{
+		Config:              conf,
+		invoiceRepo:         invoiceRepo,
+		crmRepo:             crmRepo,
+		dealRepo:            dealRepo,
+		contractRepo:        contractRepo,
+		exchangeRateService: exchangeRateService,
+		postingService:      postingService,
+		mailer:              mailer,
+	}
+}
+
+func (s *invoiceService) CreateTaxRate(request *models.TaxRateRequest) (*models.TaxRate, *apiError.Error) {
+	rate := &models.TaxRate{}
+	if apiErr := s.applyTaxRateRequest(rate, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.invoiceRepo.CreateTaxRate(rate); err != nil {
+		log.Printf("CreateTaxRate error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return rate, nil
+}
+
+// UpdateTaxRate changes a tax rate for the invoices billed from now on, invoice lines keep the rate they
+// were billed at
+func (s *invoiceService) UpdateTaxRate(id uint, request *models.TaxRateRequest) (*models.TaxRate, *apiError.Error) {
+	rate, err := s.invoiceRepo.FindTaxRate(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	if apiErr := s.applyTaxRateRequest(rate, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.invoiceRepo.UpdateTaxRate(rate); err != nil {
+		log.Printf("UpdateTaxRate error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return rate, nil
+}
+
+func (s *invoiceService) applyTaxRateRequest(rate *models.TaxRate, request *models.TaxRateRequest) *apiError.Error {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return apiError.New("name is required", http.StatusBadRequest)
+	}
+	existing, err := s.invoiceRepo.FindTaxRateByName(name)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return apiError.ErrInternalServerError
+	}
+	if err == nil && existing.ID != rate.ID {
+		return apiError.New(fmt.Sprintf("tax rate %s already exists", existing.Name), http.StatusConflict)
+	}
+	if request.Percent.IsNegative() || request.Percent.GreaterThan(hundred) {
+		return apiError.New("percent must be between 0 and 100", http.StatusBadRequest)
+	}
+
+	rate.Name = name
+	rate.Percent = request.Percent.Round(4)
+	rate.Description = request.Description
+	return nil
+}
+
+func (s *invoiceService) DeleteTaxRate(id uint) *apiError.Error {
+	if err := s.invoiceRepo.DeleteTaxRate(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.ErrNotFound
+		}
+		log.Printf("DeleteTaxRate error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+func (s *invoiceService) ListTaxRates() ([]models.TaxRate, *apiError.Error) {
+	rates, err := s.invoiceRepo.ListTaxRates()
+	if err != nil {
+		log.Printf("ListTaxRates error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return rates, nil
+}
+
+func (s *invoiceService) CreateInvoice(request *models.InvoiceRequest, userID uint) (*models.Invoice, *apiError.Error) {
+	invoice := &models.Invoice{Status: models.InvoiceDraft, CreatedBy: userID}
+	if apiErr := s.applyInvoiceRequest(invoice, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.invoiceRepo.CreateInvoice(invoice); err != nil {
+		log.Printf("CreateInvoice error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetInvoice(invoice.ID)
+}
+
+// UpdateInvoice replaces a draft, issued invoices cannot change and have to be voided instead
+func (s *invoiceService) UpdateInvoice(id uint, request *models.InvoiceRequest) (*models.Invoice, *apiError.Error) {
+	invoice, apiErr := s.GetInvoice(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if invoice.Status != models.InvoiceDraft {
+		return nil, apiError.New("only draft invoices can be changed, void the invoice and issue a new one", http.StatusConflict)
+	}
+	if apiErr := s.applyInvoiceRequest(invoice, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.invoiceRepo.UpdateInvoice(invoice); err != nil {
+		log.Printf("UpdateInvoice error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetInvoice(id)
+}
+
+// applyInvoiceRequest copies a request onto an invoice and works out its lines and totals
+func (s *invoiceService) applyInvoiceRequest(invoice *models.Invoice, request *models.InvoiceRequest) *apiError.Error {
+	if _, err := s.crmRepo.FindOrganization(request.OrganizationID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.New("organization not found", http.StatusNotFound)
+		}
+		return apiError.ErrInternalServerError
+	}
+	var contactID, dealID, contractID *uint
+	if request.ContactID != 0 {
+		if _, err := s.crmRepo.FindContact(request.ContactID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apiError.New("contact not found", http.StatusNotFound)
+			}
+			return apiError.ErrInternalServerError
+		}
+		contactID = &request.ContactID
+	}
+	if request.DealID != 0 {
+		deal, err := s.dealRepo.FindDeal(request.DealID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apiError.New("deal not found", http.StatusNotFound)
+			}
+			return apiError.ErrInternalServerError
+		}
+		if deal.OrganizationID != nil && *deal.OrganizationID != request.OrganizationID {
+			return apiError.New("the deal is with another organization", http.StatusBadRequest)
+		}
+		dealID = &request.DealID
+	}
+	if request.ContractID != 0 {
+		if _, err := s.contractRepo.FindContract(request.ContractID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apiError.New("contract not found", http.StatusNotFound)
+			}
+			return apiError.ErrInternalServerError
+		}
+		contractID = &request.ContractID
+	}
+
+	currency := strings.ToUpper(request.Currency)
+	if !isCurrencyCode(currency) {
+		return apiError.New("currency must be an ISO 4217 code such as NGN", http.StatusBadRequest)
+	}
+	if request.PaymentDays < 0 {
+		return apiError.New("payment_days cannot be negative", http.StatusBadRequest)
+	}
+	paymentDays := request.PaymentDays
+	if paymentDays == 0 {
+		paymentDays = s.Config.InvoicePaymentDays
+	}
+	var dueDate *time.Time
+	if request.DueDate != "" {
+		date, apiErr := parseDate("due_date", request.DueDate)
+		if apiErr != nil {
+			return apiErr
+		}
+		dueDate = &date
+	}
+
+	if len(request.Lines) == 0 {
+		return apiError.New("an invoice needs at least one line", http.StatusBadRequest)
+	}
+	rates := map[uint]*models.TaxRate{}
+	lines := make([]models.InvoiceLine, 0, len(request.Lines))
+	for i, request := range request.Lines {
+		description := strings.TrimSpace(request.Description)
+		if description == "" {
+			return apiError.New("every line needs a description", http.StatusBadRequest)
+		}
+		quantity := request.Quantity
+		if quantity.IsZero() {
+			quantity = decimal.NewFromInt(1)
+		}
+		if quantity.IsNegative() {
+			return apiError.New("quantities cannot be negative", http.StatusBadRequest)
+		}
+		if request.UnitPrice.IsNegative() {
+			return apiError.New("unit prices cannot be negative", http.StatusBadRequest)
+		}
+		if request.DiscountPercent.IsNegative() || request.DiscountPercent.GreaterThan(hundred) {
+			return apiError.New("discount_percent must be between 0 and 100", http.StatusBadRequest)
+		}
+		line := models.InvoiceLine{
+			Position:        i + 1,
+			Description:     description,
+			Quantity:        quantity.Round(4),
+			UnitPrice:       request.UnitPrice.Round(2),
+			DiscountPercent: request.DiscountPercent.Round(4),
+		}
+		if request.TaxRateID != 0 {
+			rate, ok := rates[request.TaxRateID]
+			if !ok {
+				var err error
+				if rate, err = s.invoiceRepo.FindTaxRate(request.TaxRateID); err != nil {
+					if errors.Is(err, gorm.ErrRecordNotFound) {
+						return apiError.New(fmt.Sprintf("tax rate %d not found", request.TaxRateID), http.StatusNotFound)
+					}
+					return apiError.ErrInternalServerError
+				}
+				rates[rate.ID] = rate
+			}
+			line.TaxRateID = &rate.ID
+			line.TaxName = rate.Name
+			line.TaxPercent = rate.Percent
+		}
+		lines = append(lines, line)
+	}
+
+	invoice.OrganizationID = request.OrganizationID
+	invoice.ContactID = contactID
+	invoice.DealID = dealID
+	invoice.ContractID = contractID
+	invoice.Currency = currency
+	invoice.DueDate = dueDate
+	invoice.PaymentDays = paymentDays
+	invoice.Notes = request.Notes
+	invoice.Lines = lines
+	priceInvoice(invoice)
+	return nil
+}
+
+// priceInvoice works out the amounts of the lines of an invoice and its totals. A line is discounted off
+// its gross amount and taxed on what is left, each amount rounded to two places.
+func priceInvoice(invoice *models.Invoice) {
+	invoice.Subtotal, invoice.DiscountTotal, invoice.TaxTotal = decimal.Zero, decimal.Zero, decimal.Zero
+	for i := range invoice.Lines {
+		line := &invoice.Lines[i]
+		gross := line.Quantity.Mul(line.UnitPrice).Round(2)
+		line.Discount = gross.Mul(line.DiscountPercent).DivRound(hundred, 2)
+		line.Amount = gross.Sub(line.Discount)
+		line.Tax = line.Amount.Mul(line.TaxPercent).DivRound(hundred, 2)
+
+		invoice.Subtotal = invoice.Subtotal.Add(gross)
+		invoice.DiscountTotal = invoice.DiscountTotal.Add(line.Discount)
+		invoice.TaxTotal = invoice.TaxTotal.Add(line.Tax)
+	}
+	invoice.Total = invoice.Subtotal.Sub(invoice.DiscountTotal).Add(invoice.TaxTotal)
+}
+
+// DeleteInvoice deletes a draft, issued invoices keep their number and have to be voided instead
+func (s *invoiceService) DeleteInvoice(id uint) *apiError.Error {
+	invoice, apiErr := s.GetInvoice(id)
+	if apiErr != nil {
+		return apiErr
+	}
+	if invoice.Status != models.InvoiceDraft {
+		return apiError.New("only draft invoices can be deleted, void the invoice instead", http.StatusConflict)
+	}
+	if err := s.invoiceRepo.DeleteInvoice(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.ErrNotFound
+		}
+		log.Printf("DeleteInvoice error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+func (s *invoiceService) GetInvoice(id uint) (*models.Invoice, *apiError.Error) {
+	invoice, err := s.invoiceRepo.FindInvoice(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return invoice, nil
+}
+
+func (s *invoiceService) ListInvoices(filter *models.InvoiceFilter) ([]models.Invoice, int64, *apiError.Error) {
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, 0, apiError.New("status must be draft, sent, partially_paid, paid or void", http.StatusBadRequest)
+	}
+	invoices, total, err := s.invoiceRepo.ListInvoices(filter)
+	if err != nil {
+		log.Printf("ListInvoices error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	return invoices, total, nil
+}
+
+// InvoicePDF lays an invoice out as a PDF document
+func (s *invoiceService) InvoicePDF(id uint) (*models.Invoice, []byte, *apiError.Error) {
+	invoice, apiErr := s.GetInvoice(id)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	data, apiErr := s.renderInvoice(invoice)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	return invoice, data, nil
+}
+
+func (s *invoiceService) renderInvoice(invoice *models.Invoice) ([]byte, *apiError.Error) {
+	issuer := pdf.Issuer{Name: s.Config.CompanyName, Address: s.Config.CompanyAddress, TaxID: s.Config.CompanyTaxID}
+	var buf bytes.Buffer
+	if err := pdf.Invoice(&buf, invoice, issuer); err != nil {
+		log.Printf("InvoicePDF error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return buf.Bytes(), nil
+}
+
+// primaryEmail returns the primary address among emails, if any
+func primaryEmail(emails []models.EmailAddress) string {
+	for _, email := range emails {
+		if email.IsPrimary {
+			return email.Address
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].Address
+	}
+	return ""
+}
+
+// SendInvoice emails an invoice as a PDF document. A draft is issued first: it is dated today, numbered
+// and falls due after its payment days unless it has a due date, and it is booked in the ledger. An invoice
+// issued but not emailed stays issued and can be sent again.
+func (s *invoiceService) SendInvoice(id uint, request *models.InvoiceSendRequest, userID uint) (*models.Invoice, *apiError.Error) {
+	invoice, apiErr := s.GetInvoice(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if invoice.Status == models.InvoiceVoid {
+		return nil, apiError.New("void invoices cannot be sent", http.StatusConflict)
+	}
+	recipient := request.Email
+	if recipient == "" && invoice.Contact != nil {
+		recipient = primaryEmail(invoice.Contact.Emails)
+	}
+	if recipient == "" && invoice.Organization != nil {
+		recipient = primaryEmail(invoice.Organization.Emails)
+	}
+	if recipient == "" {
+		return nil, apiError.New("neither the contact nor the organization has an email address, give one", http.StatusBadRequest)
+	}
+
+	if invoice.Status == models.InvoiceDraft {
+		today, _ := time.Parse(models.DateLayout, time.Now().UTC().Format(models.DateLayout))
+		dueDate := today.AddDate(0, 0, invoice.PaymentDays)
+		if invoice.DueDate != nil {
+			if invoice.DueDate.Before(today) {
+				return nil, apiError.New("the due date of the invoice has passed, change it before sending the invoice", http.StatusBadRequest)
+			}
+			dueDate = *invoice.DueDate
+		}
+		invoice.IssueDate, invoice.DueDate = &today, &dueDate
+		invoice.Status = models.InvoiceSent
+		entry, apiErr := s.postingService.InvoiceIssued(invoice, userID)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		if err := s.invoiceRepo.IssueInvoice(invoice, s.Config.InvoicePrefix, entry); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apiError.New("the invoice changed meanwhile, reload it", http.StatusConflict)
+			}
+			if apiErr := ledgerError(err); apiErr != nil {
+				return nil, apiErr
+			}
+			log.Printf("SendInvoice error: %v", err)
+			return nil, apiError.ErrInternalServerError
+		}
+	}
+
+	data, apiErr := s.renderInvoice(invoice)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	subject := "Invoice " + invoice.Number
+	if s.Config.CompanyName != "" {
+		subject += " from " + s.Config.CompanyName
+	}
+	body := fmt.Sprintf("Please find attached invoice %s for %s %s, due on %s.",
+		invoice.Number, invoice.Currency, invoice.Balance().StringFixed(2), invoice.DueDate.Format(models.DateLayout))
+	if message := strings.TrimSpace(request.Message); message != "" {
+		body = message + "\n\n" + body
+	}
+	if _, err := s.mailer.SendMessageWithAttachment(recipient, subject, body, "invoice-"+invoice.Number+".pdf", data); err != nil {
+		log.Printf("SendInvoice error mailing invoice %d to %s: %v", invoice.ID, recipient, err)
+		return nil, apiError.New(fmt.Sprintf("invoice %s was issued but could not be emailed, try sending it again", invoice.Number), http.StatusBadGateway)
+	}
+
+	if err := s.invoiceRepo.MarkInvoiceSent(id, recipient, time.Now()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.New(fmt.Sprintf("invoice %s was emailed but voided meanwhile", invoice.Number), http.StatusConflict)
+		}
+		log.Printf("SendInvoice error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetInvoice(id)
+}
+
+// VoidInvoice cancels an invoice nothing was paid on, it keeps its number so that numbering has no gaps and
+// the ledger reverses its booking
+func (s *invoiceService) VoidInvoice(id uint, request *models.InvoiceVoidRequest, userID uint) (*models.Invoice, *apiError.Error) {
+	invoice, apiErr := s.GetInvoice(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if invoice.Status == models.InvoiceVoid {
+		return nil, apiError.New("invoice is void already", http.StatusConflict)
+	}
+	if invoice.AmountPaid.IsPositive() {
+		return nil, apiError.New("payments were recorded against the invoice, delete them before voiding it", http.StatusConflict)
+	}
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" {
+		return nil, apiError.New("reason is required", http.StatusBadRequest)
+	}
+
+	if err := s.invoiceRepo.VoidInvoice(id, reason, time.Now(), userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.New("the invoice changed meanwhile, reload it", http.StatusConflict)
+		}
+		if apiErr := ledgerError(err); apiErr != nil {
+			return nil, apiErr
+		}
+		log.Printf("VoidInvoice error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetInvoice(id)
+}
+
+// RecordPayment records money received against an issued invoice, marking it partially paid or paid.
+// Payments cannot exceed what is left to pay.
+func (s *invoiceService) RecordPayment(id uint, request *models.InvoicePaymentRequest, userID uint) (*models.Invoice, *apiError.Error) {
+	if !request.Amount.IsPositive() {
+		return nil, apiError.New("amount must be positive", http.StatusBadRequest)
+	}
+	paidOn, apiErr := parseDate("paid_on", request.PaidOn)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if paidOn.After(time.Now()) {
+		return nil, apiError.New("paid_on cannot be in the future", http.StatusBadRequest)
+	}
+	invoice, apiErr := s.GetInvoice(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if !invoice.Status.IsOpen() || request.Amount.Round(2).GreaterThan(invoice.Balance()) {
+		return nil, paymentRefused(invoice)
+	}
+
+	payment := &models.InvoicePayment{
+		InvoiceID:  id,
+		Amount:     request.Amount.Round(2),
+		PaidOn:     paidOn,
+		Method:     strings.TrimSpace(request.Method),
+		Reference:  strings.TrimSpace(request.Reference),
+		RecordedBy: userID,
+	}
+	entry, apiErr := s.postingService.PaymentReceived(invoice, payment, userID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	invoice, err := s.invoiceRepo.RecordPayment(payment, entry)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		if apiErr := ledgerError(err); apiErr != nil {
+			return nil, apiErr
+		}
+		log.Printf("RecordPayment error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	if payment.ID == 0 {
+		return nil, paymentRefused(invoice)
+	}
+	return s.GetInvoice(id)
+}
+
+// paymentRefused explains why a payment cannot be recorded against an invoice
+func paymentRefused(invoice *models.Invoice) *apiError.Error {
+	switch invoice.Status {
+	case models.InvoiceDraft:
+		return apiError.New("the invoice has not been issued yet", http.StatusConflict)
+	case models.InvoiceVoid:
+		return apiError.New("the invoice is void", http.StatusConflict)
+	case models.InvoicePaid:
+		return apiError.New("the invoice is paid already", http.StatusConflict)
+	}
+	return apiError.New(fmt.Sprintf("only %s %s is left to pay on the invoice", invoice.Currency, invoice.Balance().StringFixed(2)), http.StatusBadRequest)
+}
+
+// DeletePayment removes a payment recorded by mistake, the invoice goes back to partially paid or sent and
+// the ledger reverses the booking of the payment
+func (s *invoiceService) DeletePayment(invoiceID, paymentID, userID uint) (*models.Invoice, *apiError.Error) {
+	if err := s.invoiceRepo.DeletePayment(invoiceID, paymentID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		if apiErr := ledgerError(err); apiErr != nil {
+			return nil, apiErr
+		}
+		log.Printf("DeletePayment error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetInvoice(invoiceID)
+}
+
+// AgingReport adds up what organizations owed on a date by how long it was overdue then, converted to
+// one currency at the rates of that date
+func (s *invoiceService) AgingReport(asOf time.Time, currency string) (*models.AgingReport, *apiError.Error) {
+	if currency == "" {
+		currency = s.Config.ReportingCurrency
+	}
+	currency = strings.ToUpper(currency)
+	if !isCurrencyCode(currency) {
+		return nil, apiError.New("currency must be an ISO 4217 code such as NGN", http.StatusBadRequest)
+	}
+	balances, err := s.invoiceRepo.FindInvoiceBalances(asOf)
+	if err != nil {
+		log.Printf("AgingReport error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+
+	report := &models.AgingReport{
+		AsOf:          asOf.Format(models.DateLayout),
+		Currency:      currency,
+		Organizations: []models.OrganizationAging{},
+		Totals:        zeroBuckets(),
+	}
+	index := map[uint]int{}
+	var organizationIDs []uint
+	for _, balance := range balances {
+		amount, _, apiErr := s.exchangeRateService.Convert(balance.Balance, balance.Currency, currency, asOf)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		i, ok := index[balance.OrganizationID]
+		if !ok {
+			i = len(report.Organizations)
+			index[balance.OrganizationID] = i
+			organizationIDs = append(organizationIDs, balance.OrganizationID)
+			report.Organizations = append(report.Organizations, models.OrganizationAging{
+				OrganizationID: balance.OrganizationID,
+				AgingBuckets:   zeroBuckets(),
+			})
+		}
+		days := int(asOf.Sub(balance.DueDate).Hours() / 24)
+		report.Organizations[i].Invoices++
+		addToBucket(&report.Organizations[i].AgingBuckets, days, amount)
+		addToBucket(&report.Totals, days, amount)
+	}
+
+	if len(organizationIDs) > 0 {
+		organizations, err := s.crmRepo.FindOrganizations(organizationIDs)
+		if err != nil {
+			log.Printf("AgingReport error: %v", err)
+			return nil, apiError.ErrInternalServerError
+		}
+		for _, organization := range organizations {
+			report.Organizations[index[organization.ID]].Name = organization.Name
+		}
+	}
+	return report, nil
+}
+
+func zeroBuckets() models.AgingBuckets {
+	return models.AgingBuckets{
+		Current:    decimal.Zero,
+		Days1To30:  decimal.Zero,
+		Days31To60: decimal.Zero,
+		Days61To90: decimal.Zero,
+		Over90:     decimal.Zero,
+		Total:      decimal.Zero,
+	}
+}
+
+// addToBucket adds an amount overdue by some days, not due yet when zero or less
+func addToBucket(buckets *models.AgingBuckets, days int, amount decimal.Decimal) {
+	switch {
+	case days <= 0:
+		buckets.Current = buckets.Current.Add(amount)
+	case days <= 30:
+		buckets.Days1To30 = buckets.Days1To30.Add(amount)
+	case days <= 60:
+		buckets.Days31To60 = buckets.Days31To60.Add(amount)
+	case days <= 90:
+		buckets.Days61To90 = buckets.Days61To90.Add(amount)
+	default:
+		buckets.Over90 = buckets.Over90.Add(amount)
+	}
+	buckets.Total = buckets.Total.Add(amount)
+}
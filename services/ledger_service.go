@@ -0,0 +1,567 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/techagentng/telair-erp/config"
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+// LedgerService keeps the general ledger: the chart of accounts, manual journal entries, accounting periods
+// and the trial balance, profit and loss and balance sheet reports
+type LedgerService interface {
+	CreateAccount(request *models.AccountRequest) (*models.Account, *apiError.Error)
+	UpdateAccount(id uint, request *models.AccountRequest) (*models.Account, *apiError.Error)
+	DeleteAccount(id uint) *apiError.Error
+	ListAccounts() ([]models.Account, *apiError.Error)
+	CreateEntry(request *models.JournalEntryRequest, userID uint) (*models.JournalEntry, *apiError.Error)
+	UpdateEntry(id uint, request *models.JournalEntryRequest) (*models.JournalEntry, *apiError.Error)
+	DeleteEntry(id uint) *apiError.Error
+	GetEntry(id uint) (*models.JournalEntry, *apiError.Error)
+	ListEntries(filter *models.JournalFilter) ([]models.JournalEntry, int64, *apiError.Error)
+	PostEntry(id, userID uint) (*models.JournalEntry, *apiError.Error)
+	ReverseEntry(id uint, request *models.JournalReverseRequest, userID uint) (*models.JournalEntry, *apiError.Error)
+	CreatePeriod(request *models.AccountingPeriodRequest) (*models.AccountingPeriod, *apiError.Error)
+	ListPeriods() ([]models.AccountingPeriod, *apiError.Error)
+	SetPeriodStatus(id uint, status models.PeriodStatus, userID uint) (*models.AccountingPeriod, *apiError.Error)
+	TrialBalance(asOf time.Time) (*models.TrialBalance, *apiError.Error)
+	ProfitAndLoss(from, to time.Time) (*models.ProfitAndLoss, *apiError.Error)
+	BalanceSheet(asOf time.Time) (*models.BalanceSheet, *apiError.Error)
+}
+
+type ledgerService struct {
+	Config     *config.Config
+	ledgerRepo db.LedgerRepository
+}
+
+// NewLedgerService instantiate a ledgerService
+func NewLedgerService(ledgerRepo db.LedgerRepository, conf *config.Config) LedgerService {
+	return &ledgerService{
+		Config:     conf,
+		ledgerRepo: ledgerRepo,
+	}
+}
+
+func (s *ledgerService) CreateAccount(request *models.AccountRequest) (*models.Account, *apiError.Error) {
+	account := &models.Account{}
+	if apiErr := s.applyAccountRequest(account, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.ledgerRepo.CreateAccount(account); err != nil {
+		log.Printf("CreateAccount error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return account, nil
+}
+
+// UpdateAccount renames an account or archives it. The type of an account cannot change once something
+// was entered in it, nor can accounts the ledger posts to change type or be archived.
+func (s *ledgerService) UpdateAccount(id uint, request *models.AccountRequest) (*models.Account, *apiError.Error) {
+	account, err := s.ledgerRepo.FindAccount(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	if account.Purpose != "" && (request.Type != account.Type || request.Archived) {
+		return nil, apiError.New(fmt.Sprintf("the ledger posts to account %s, it cannot change type or be archived", account.Code), http.StatusConflict)
+	}
+	if request.Type != account.Type {
+		lines, err := s.ledgerRepo.CountAccountLines(account.ID)
+		if err != nil {
+			return nil, apiError.ErrInternalServerError
+		}
+		if lines > 0 {
+			return nil, apiError.New("entries were made in the account, its type cannot change", http.StatusConflict)
+		}
+	}
+	if apiErr := s.applyAccountRequest(account, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.ledgerRepo.UpdateAccount(account); err != nil {
+		log.Printf("UpdateAccount error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return account, nil
+}
+
+func (s *ledgerService) applyAccountRequest(account *models.Account, request *models.AccountRequest) *apiError.Error {
+	code := strings.TrimSpace(request.Code)
+	name := strings.TrimSpace(request.Name)
+	if code == "" || name == "" {
+		return apiError.New("code and name are required", http.StatusBadRequest)
+	}
+	if !request.Type.IsValid() {
+		return apiError.New("type must be asset, liability, equity, income or expense", http.StatusBadRequest)
+	}
+	existing, err := s.ledgerRepo.FindAccountByCode(code)
+	if err == nil && existing.ID != account.ID {
+		return apiError.New(fmt.Sprintf("account %s is %s already", code, existing.Name), http.StatusConflict)
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return apiError.ErrInternalServerError
+	}
+
+	account.Code = code
+	account.Name = name
+	account.Type = request.Type
+	account.Description = strings.TrimSpace(request.Description)
+	account.Archived = request.Archived
+	return nil
+}
+
+// DeleteAccount removes an account set up by mistake, accounts with entries are archived instead
+func (s *ledgerService) DeleteAccount(id uint) *apiError.Error {
+	account, err := s.ledgerRepo.FindAccount(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.ErrNotFound
+		}
+		return apiError.ErrInternalServerError
+	}
+	if account.Purpose != "" {
+		return apiError.New(fmt.Sprintf("the ledger posts to account %s, it cannot be deleted", account.Code), http.StatusConflict)
+	}
+	lines, err := s.ledgerRepo.CountAccountLines(id)
+	if err != nil {
+		return apiError.ErrInternalServerError
+	}
+	if lines > 0 {
+		return apiError.New("entries were made in the account, archive it instead", http.StatusConflict)
+	}
+	if err := s.ledgerRepo.DeleteAccount(id); err != nil {
+		log.Printf("DeleteAccount error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+func (s *ledgerService) ListAccounts() ([]models.Account, *apiError.Error) {
+	accounts, err := s.ledgerRepo.ListAccounts()
+	if err != nil {
+		log.Printf("ListAccounts error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return accounts, nil
+}
+
+// CreateEntry starts a draft manual entry, its lines have to balance already
+func (s *ledgerService) CreateEntry(request *models.JournalEntryRequest, userID uint) (*models.JournalEntry, *apiError.Error) {
+	entry := &models.JournalEntry{
+		Status:    models.EntryDraft,
+		Source:    models.SourceManual,
+		CreatedBy: userID,
+	}
+	if apiErr := s.applyEntryRequest(entry, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.ledgerRepo.CreateEntry(entry); err != nil {
+		log.Printf("CreateEntry error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetEntry(entry.ID)
+}
+
+// UpdateEntry replaces a draft entry, posted entries never change
+func (s *ledgerService) UpdateEntry(id uint, request *models.JournalEntryRequest) (*models.JournalEntry, *apiError.Error) {
+	entry, apiErr := s.GetEntry(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if entry.Status != models.EntryDraft {
+		return nil, apiError.New("posted entries cannot change, reverse the entry instead", http.StatusConflict)
+	}
+	if apiErr := s.applyEntryRequest(entry, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.ledgerRepo.UpdateEntry(entry); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.New("posted entries cannot change, reverse the entry instead", http.StatusConflict)
+		}
+		log.Printf("UpdateEntry error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetEntry(id)
+}
+
+// applyEntryRequest checks that every line debits or credits an account in use, not both, and that the
+// debits and credits add up to the same amount
+func (s *ledgerService) applyEntryRequest(entry *models.JournalEntry, request *models.JournalEntryRequest) *apiError.Error {
+	date, apiErr := parseDate("date", request.Date)
+	if apiErr != nil {
+		return apiErr
+	}
+	memo := strings.TrimSpace(request.Memo)
+	if memo == "" {
+		return apiError.New("memo is required", http.StatusBadRequest)
+	}
+	if len(request.Lines) < 2 {
+		return apiError.New("an entry needs at least two lines", http.StatusBadRequest)
+	}
+
+	ids := make([]uint, 0, len(request.Lines))
+	for _, line := range request.Lines {
+		ids = append(ids, line.AccountID)
+	}
+	accounts, err := s.ledgerRepo.FindAccounts(ids)
+	if err != nil {
+		return apiError.ErrInternalServerError
+	}
+	known := map[uint]models.Account{}
+	for _, account := range accounts {
+		known[account.ID] = account
+	}
+
+	lines := make([]models.JournalLine, 0, len(request.Lines))
+	debits, credits := decimal.Zero, decimal.Zero
+	for i, line := range request.Lines {
+		account, ok := known[line.AccountID]
+		if !ok {
+			return apiError.New(fmt.Sprintf("line %d: account %d not found", i+1, line.AccountID), http.StatusNotFound)
+		}
+		if account.Archived {
+			return apiError.New(fmt.Sprintf("line %d: account %s is archived", i+1, account.Code), http.StatusBadRequest)
+		}
+		debit, credit := line.Debit.Round(2), line.Credit.Round(2)
+		if debit.IsNegative() || credit.IsNegative() || debit.IsPositive() == credit.IsPositive() {
+			return apiError.New(fmt.Sprintf("line %d must either debit or credit a positive amount", i+1), http.StatusBadRequest)
+		}
+		debits, credits = debits.Add(debit), credits.Add(credit)
+		lines = append(lines, models.JournalLine{
+			AccountID:   account.ID,
+			Debit:       debit,
+			Credit:      credit,
+			Description: strings.TrimSpace(line.Description),
+		})
+	}
+	if !debits.Equal(credits) {
+		return apiError.New(fmt.Sprintf("the entry does not balance: debits are %s and credits %s", debits.StringFixed(2), credits.StringFixed(2)), http.StatusBadRequest)
+	}
+
+	entry.Date = date
+	entry.Memo = memo
+	entry.Reference = strings.TrimSpace(request.Reference)
+	entry.Lines = lines
+	return nil
+}
+
+func (s *ledgerService) DeleteEntry(id uint) *apiError.Error {
+	if err := s.ledgerRepo.DeleteEntry(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if _, apiErr := s.GetEntry(id); apiErr != nil {
+				return apiErr
+			}
+			return apiError.New("posted entries cannot be deleted, reverse the entry instead", http.StatusConflict)
+		}
+		log.Printf("DeleteEntry error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+func (s *ledgerService) GetEntry(id uint) (*models.JournalEntry, *apiError.Error) {
+	entry, err := s.ledgerRepo.FindEntry(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return entry, nil
+}
+
+func (s *ledgerService) ListEntries(filter *models.JournalFilter) ([]models.JournalEntry, int64, *apiError.Error) {
+	entries, total, err := s.ledgerRepo.ListEntries(filter)
+	if err != nil {
+		log.Printf("ListEntries error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	return entries, total, nil
+}
+
+// PostEntry posts a draft entry, numbering it. It cannot be posted in a closed period.
+func (s *ledgerService) PostEntry(id, userID uint) (*models.JournalEntry, *apiError.Error) {
+	if err := s.ledgerRepo.PostEntry(id, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if _, apiErr := s.GetEntry(id); apiErr != nil {
+				return nil, apiErr
+			}
+			return nil, apiError.New("the entry is posted already", http.StatusConflict)
+		}
+		if apiErr := ledgerError(err); apiErr != nil {
+			return nil, apiErr
+		}
+		log.Printf("PostEntry error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetEntry(id)
+}
+
+// ReverseEntry undoes a posted manual entry with one swapping its debits and credits. Entries booking
+// invoices, payments and costs are reversed by voiding or deleting those.
+func (s *ledgerService) ReverseEntry(id uint, request *models.JournalReverseRequest, userID uint) (*models.JournalEntry, *apiError.Error) {
+	entry, apiErr := s.GetEntry(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	switch {
+	case entry.Status != models.EntryPosted:
+		return nil, apiError.New("draft entries are deleted rather than reversed", http.StatusConflict)
+	case entry.Source != models.SourceManual:
+		return nil, apiError.New(fmt.Sprintf("the entry books %s %d, which the ledger reverses when it is voided or deleted", entry.Source, entry.SourceID), http.StatusConflict)
+	case entry.ReversalOfID != nil:
+		return nil, apiError.New("the entry is a reversal, post a new entry instead", http.StatusConflict)
+	case entry.ReversedByID != nil:
+		return nil, apiError.New("the entry is reversed already", http.StatusConflict)
+	}
+	on, _ := time.Parse(models.DateLayout, time.Now().UTC().Format(models.DateLayout))
+	if request.Date != "" {
+		if on, apiErr = parseDate("date", request.Date); apiErr != nil {
+			return nil, apiErr
+		}
+	}
+	if on.Before(entry.Date) {
+		return nil, apiError.New("an entry cannot be reversed before its date", http.StatusBadRequest)
+	}
+
+	reversal, err := s.ledgerRepo.ReverseEntry(id, on, strings.TrimSpace(request.Memo), userID)
+	if err != nil {
+		if apiErr := ledgerError(err); apiErr != nil {
+			return nil, apiErr
+		}
+		log.Printf("ReverseEntry error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetEntry(reversal.ID)
+}
+
+// CreatePeriod opens an accounting period, periods cannot overlap
+func (s *ledgerService) CreatePeriod(request *models.AccountingPeriodRequest) (*models.AccountingPeriod, *apiError.Error) {
+	start, apiErr := parseDate("start_date", request.StartDate)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	end, apiErr := parseDate("end_date", request.EndDate)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if end.Before(start) {
+		return nil, apiError.New("end_date cannot be before start_date", http.StatusBadRequest)
+	}
+	overlapping, err := s.ledgerRepo.CountOverlappingPeriods(start, end)
+	if err != nil {
+		return nil, apiError.ErrInternalServerError
+	}
+	if overlapping > 0 {
+		return nil, apiError.New("the period overlaps another one", http.StatusConflict)
+	}
+
+	period := &models.AccountingPeriod{
+		Name:      strings.TrimSpace(request.Name),
+		StartDate: start,
+		EndDate:   end,
+		Status:    models.PeriodOpen,
+	}
+	if period.Name == "" {
+		period.Name = start.Format("2006-01")
+	}
+	if err := s.ledgerRepo.CreatePeriod(period); err != nil {
+		log.Printf("CreatePeriod error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return period, nil
+}
+
+func (s *ledgerService) ListPeriods() ([]models.AccountingPeriod, *apiError.Error) {
+	periods, err := s.ledgerRepo.ListPeriods()
+	if err != nil {
+		log.Printf("ListPeriods error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return periods, nil
+}
+
+// periodTransitions lists the statuses a period can move to a status from
+var periodTransitions = map[models.PeriodStatus][]models.PeriodStatus{
+	models.PeriodOpen:   {models.PeriodClosed},
+	models.PeriodClosed: {models.PeriodOpen},
+	models.PeriodLocked: {models.PeriodOpen, models.PeriodClosed},
+}
+
+// SetPeriodStatus closes, reopens or locks a period. A period is closed or locked once no draft entry is
+// dated in it, locked periods are never reopened.
+func (s *ledgerService) SetPeriodStatus(id uint, status models.PeriodStatus, userID uint) (*models.AccountingPeriod, *apiError.Error) {
+	period, err := s.ledgerRepo.FindPeriod(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	if status != models.PeriodOpen {
+		drafts, err := s.ledgerRepo.CountDraftEntries(period.StartDate, period.EndDate)
+		if err != nil {
+			return nil, apiError.ErrInternalServerError
+		}
+		if drafts > 0 {
+			return nil, apiError.New(fmt.Sprintf("%d draft entries are dated in the period, post or delete them first", drafts), http.StatusConflict)
+		}
+	}
+
+	if err := s.ledgerRepo.SetPeriodStatus(id, periodTransitions[status], status, userID, time.Now()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.New(fmt.Sprintf("the period is %s, it cannot be %s", period.Status, periodVerbs[status]), http.StatusConflict)
+		}
+		log.Printf("SetPeriodStatus error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	period, err = s.ledgerRepo.FindPeriod(id)
+	if err != nil {
+		return nil, apiError.ErrInternalServerError
+	}
+	return period, nil
+}
+
+// periodVerbs says what moving a period to a status does
+var periodVerbs = map[models.PeriodStatus]string{
+	models.PeriodOpen:   "reopened",
+	models.PeriodClosed: "closed",
+	models.PeriodLocked: "locked",
+}
+
+// TrialBalance lists the balance of every account with posted entries up to a day in its debit or credit
+// column, both columns add up to the same total
+func (s *ledgerService) TrialBalance(asOf time.Time) (*models.TrialBalance, *apiError.Error) {
+	totals, err := s.ledgerRepo.SumAccounts(nil, asOf)
+	if err != nil {
+		log.Printf("TrialBalance error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+
+	report := &models.TrialBalance{
+		AsOf:        asOf.Format(models.DateLayout),
+		Currency:    s.Config.ReportingCurrency,
+		Accounts:    []models.AccountBalance{},
+		TotalDebit:  decimal.Zero,
+		TotalCredit: decimal.Zero,
+	}
+	for _, total := range totals {
+		balance := accountBalance(total)
+		net := total.Debit.Sub(total.Credit)
+		balance.Debit, balance.Credit = decimal.Zero, decimal.Zero
+		switch {
+		case net.IsPositive():
+			balance.Debit = net
+		case net.IsNegative():
+			balance.Credit = net.Neg()
+		default:
+			continue
+		}
+		report.Accounts = append(report.Accounts, balance)
+		report.TotalDebit = report.TotalDebit.Add(balance.Debit)
+		report.TotalCredit = report.TotalCredit.Add(balance.Credit)
+	}
+	report.Balanced = report.TotalDebit.Equal(report.TotalCredit)
+	return report, nil
+}
+
+// ProfitAndLoss adds up the income and expense accounts over the days from from to to
+func (s *ledgerService) ProfitAndLoss(from, to time.Time) (*models.ProfitAndLoss, *apiError.Error) {
+	totals, err := s.ledgerRepo.SumAccounts(&from, to)
+	if err != nil {
+		log.Printf("ProfitAndLoss error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+
+	report := &models.ProfitAndLoss{
+		From:          from.Format(models.DateLayout),
+		To:            to.Format(models.DateLayout),
+		Currency:      s.Config.ReportingCurrency,
+		Income:        []models.AccountBalance{},
+		Expenses:      []models.AccountBalance{},
+		TotalIncome:   decimal.Zero,
+		TotalExpenses: decimal.Zero,
+	}
+	for _, total := range totals {
+		balance := accountBalance(total)
+		switch total.Type {
+		case models.AccountIncome:
+			report.Income = append(report.Income, balance)
+			report.TotalIncome = report.TotalIncome.Add(balance.Balance)
+		case models.AccountExpense:
+			report.Expenses = append(report.Expenses, balance)
+			report.TotalExpenses = report.TotalExpenses.Add(balance.Balance)
+		}
+	}
+	report.NetIncome = report.TotalIncome.Sub(report.TotalExpenses)
+	return report, nil
+}
+
+// BalanceSheet lists the asset, liability and equity accounts on a day, with the net income of every day up
+// to then as current earnings
+func (s *ledgerService) BalanceSheet(asOf time.Time) (*models.BalanceSheet, *apiError.Error) {
+	totals, err := s.ledgerRepo.SumAccounts(nil, asOf)
+	if err != nil {
+		log.Printf("BalanceSheet error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+
+	report := &models.BalanceSheet{
+		AsOf:             asOf.Format(models.DateLayout),
+		Currency:         s.Config.ReportingCurrency,
+		Assets:           []models.AccountBalance{},
+		Liabilities:      []models.AccountBalance{},
+		Equity:           []models.AccountBalance{},
+		CurrentEarnings:  decimal.Zero,
+		TotalAssets:      decimal.Zero,
+		TotalLiabilities: decimal.Zero,
+		TotalEquity:      decimal.Zero,
+	}
+	for _, total := range totals {
+		balance := accountBalance(total)
+		switch total.Type {
+		case models.AccountAsset:
+			report.Assets = append(report.Assets, balance)
+			report.TotalAssets = report.TotalAssets.Add(balance.Balance)
+		case models.AccountLiability:
+			report.Liabilities = append(report.Liabilities, balance)
+			report.TotalLiabilities = report.TotalLiabilities.Add(balance.Balance)
+		case models.AccountEquity:
+			report.Equity = append(report.Equity, balance)
+			report.TotalEquity = report.TotalEquity.Add(balance.Balance)
+		case models.AccountIncome:
+			report.CurrentEarnings = report.CurrentEarnings.Add(balance.Balance)
+		case models.AccountExpense:
+			report.CurrentEarnings = report.CurrentEarnings.Sub(balance.Balance)
+		}
+	}
+	report.TotalEquity = report.TotalEquity.Add(report.CurrentEarnings)
+	report.Balanced = report.TotalAssets.Equal(report.TotalLiabilities.Add(report.TotalEquity))
+	return report, nil
+}
+
+// accountBalance is the balance of an account from its totals, positive on its normal side
+func accountBalance(total models.AccountTotal) models.AccountBalance {
+	balance := total.Credit.Sub(total.Debit)
+	if total.Type.DebitNormal() {
+		balance = balance.Neg()
+	}
+	return models.AccountBalance{
+		AccountID: total.AccountID,
+		Code:      total.Code,
+		Name:      total.Name,
+		Type:      total.Type,
+		Debit:     total.Debit,
+		Credit:    total.Credit,
+		Balance:   balance,
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/techagentng/telair-erp/config"
+	"github.com/techagentng/telair-erp/db"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/storage"
+	"gorm.io/gorm"
+)
+
+// mediaPrefixes are the storage folders holding title media, every object in them
+// should belong to a media asset or an upload in progress
+var mediaPrefixes = []string{
+	models.TitleTrailer.TableName() + "/",
+	models.TitleFullLength.TableName() + "/",
+}
+
+// MediaService keeps media assets and the objects in storage in step
+type MediaService interface {
+	Deduplicate(ctx context.Context, object *storage.Object) (*storage.Object, bool, error)
+	AttachAsset(ctx context.Context, asset *models.MediaAsset) (*models.MediaAsset, error)
+	ReconcileOrphans(ctx context.Context) error
+}
+
+type mediaService struct {
+	Config     *config.Config
+	movieRepo  db.MovieRepository
+	uploadRepo db.UploadRepository
+	storage    storage.Storage
+}
+
+// NewMediaService instantiate a mediaService
+func NewMediaService(movieRepo db.MovieRepository, uploadRepo db.UploadRepository, store storage.Storage, conf *config.Config) MediaService {
+	return &mediaService{
+		Config:     conf,
+		movieRepo:  movieRepo,
+		uploadRepo: uploadRepo,
+		storage:    store,
+	}
+}
+
+// Deduplicate looks for an asset with the same content as a freshly uploaded object. When there
+// is one the new object is deleted and the stored copy is returned instead, along with true.
+func (m *mediaService) Deduplicate(ctx context.Context, object *storage.Object) (*storage.Object, bool, error) {
+	if object.SHA256 == "" {
+		return object, false, nil
+	}
+	existing, err := m.movieRepo.FindMediaAssetByChecksum(object.SHA256, object.Size)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return object, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if existing.StorageKey == object.Key {
+		return object, false, nil
+	}
+
+	if err := m.storage.Delete(ctx, object.Key); err != nil {
+		log.Printf("Deduplicate error removing %s: %v", object.Key, err)
+	}
+	return &storage.Object{
+		Key:         existing.StorageKey,
+		URL:         existing.URL,
+		Size:        existing.Size,
+		ContentType: existing.ContentType,
+		SHA256:      existing.SHA256,
+	}, true, nil
+}
+
+// AttachAsset saves an asset, pointing it at an existing copy of the same bytes when there is
+// one. Uploading the same file to the same title twice returns the asset already there.
+func (m *mediaService) AttachAsset(ctx context.Context, asset *models.MediaAsset) (*models.MediaAsset, error) {
+	if asset.SHA256 != "" {
+		existing, err := m.movieRepo.FindMediaAssetByChecksum(asset.SHA256, asset.Size)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if err == nil && existing.StorageKey != asset.StorageKey {
+			if err := m.storage.Delete(ctx, asset.StorageKey); err != nil {
+				log.Printf("AttachAsset error removing %s: %v", asset.StorageKey, err)
+			}
+			asset.StorageKey = existing.StorageKey
+			asset.URL = existing.URL
+		}
+		if err == nil && existing.TitleType == asset.TitleType && existing.TitleID == asset.TitleID && existing.Kind == asset.Kind {
+			return existing, nil
+		}
+	}
+
+	if err := m.movieRepo.CreateMediaAsset(asset); err != nil {
+		return nil, err
+	}
+	return asset, nil
+}
+
+// ReconcileOrphans walks the media folders in storage looking for objects no database row
+// references. Recent objects are left alone since their upload may still be going on.
+func (m *mediaService) ReconcileOrphans(ctx context.Context) error {
+	cutoff := time.Now().Add(-time.Duration(m.Config.OrphanGraceHours) * time.Hour)
+	var orphans, total int
+	for _, prefix := range mediaPrefixes {
+		var batch []storage.ObjectSummary
+		flush := func() error {
+			found, err := m.reconcileBatch(ctx, batch)
+			orphans += found
+			batch = batch[:0]
+			return err
+		}
+
+		err := m.storage.List(ctx, prefix, func(object storage.ObjectSummary) error {
+			total++
+			if object.LastModified.After(cutoff) {
+				return nil
+			}
+			batch = append(batch, object)
+			if len(batch) == 1000 {
+				return flush()
+			}
+			return nil
+		})
+		if err == nil {
+			err = flush()
+		}
+		if err != nil {
+			return fmt.Errorf("error reconciling %s: %w", prefix, err)
+		}
+	}
+
+	log.Printf("ReconcileOrphans checked %d objects, %d orphaned", total, orphans)
+	return nil
+}
+
+func (m *mediaService) reconcileBatch(ctx context.Context, batch []storage.ObjectSummary) (int, error) {
+	keys := make([]string, 0, len(batch))
+	for _, object := range batch {
+		keys = append(keys, object.Key)
+	}
+	referenced, err := m.uploadRepo.FindReferencedKeys(keys)
+	if err != nil {
+		return 0, err
+	}
+
+	orphans := 0
+	for _, object := range batch {
+		if referenced[object.Key] {
+			continue
+		}
+		orphans++
+		if !m.Config.DeleteOrphanedObjects {
+			log.Printf("ReconcileOrphans found orphaned object %s (%d bytes)", object.Key, object.Size)
+			continue
+		}
+		if err := m.storage.Delete(ctx, object.Key); err != nil {
+			log.Printf("ReconcileOrphans error removing %s: %v", object.Key, err)
+			continue
+		}
+		log.Printf("ReconcileOrphans removed orphaned object %s (%d bytes)", object.Key, object.Size)
+	}
+	return orphans, nil
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+// PeopleService manages the people directory and the credits tying people to titles
+type PeopleService interface {
+	CreatePerson(request *models.PersonRequest) (*models.Person, *apiError.Error)
+	UpdatePerson(id uint, request *models.PersonRequest) (*models.Person, *apiError.Error)
+	GetPerson(id uint) (*models.Person, *apiError.Error)
+	SearchPeople(query string, page, limit int) ([]models.Person, int64, *apiError.Error)
+	Filmography(personID uint) ([]models.FilmographyEntry, *apiError.Error)
+	AddCredit(titleType models.TitleType, titleID uint, request *models.CreditRequest) (*models.Credit, *apiError.Error)
+	GetTitleCredits(titleType models.TitleType, titleID uint) ([]models.Credit, *apiError.Error)
+	RemoveCredit(id uint) *apiError.Error
+}
+
+type peopleService struct {
+	peopleRepo db.PeopleRepository
+	movieRepo  db.MovieRepository
+}
+
+// NewPeopleService instantiate a peopleService
+func NewPeopleService(peopleRepo db.PeopleRepository, movieRepo db.MovieRepository) PeopleService {
+	return &peopleService{
+		peopleRepo: peopleRepo,
+		movieRepo:  movieRepo,
+	}
+}
+
+func (p *peopleService) CreatePerson(request *models.PersonRequest) (*models.Person, *apiError.Error) {
+	person := &models.Person{}
+	applyPersonRequest(person, request)
+	if err := p.peopleRepo.CreatePerson(person); err != nil {
+		log.Printf("CreatePerson error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return person, nil
+}
+
+func (p *peopleService) UpdatePerson(id uint, request *models.PersonRequest) (*models.Person, *apiError.Error) {
+	person, apiErr := p.GetPerson(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	applyPersonRequest(person, request)
+	if err := p.peopleRepo.UpdatePerson(person); err != nil {
+		log.Printf("UpdatePerson error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return person, nil
+}
+
+func applyPersonRequest(person *models.Person, request *models.PersonRequest) {
+	person.Name = request.Name
+	person.Aliases = request.Aliases
+	person.PhotoURL = request.PhotoURL
+	person.Bio = request.Bio
+	person.Email = request.Email
+	person.Telephone = request.Telephone
+}
+
+func (p *peopleService) GetPerson(id uint) (*models.Person, *apiError.Error) {
+	person, err := p.peopleRepo.FindPerson(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return person, nil
+}
+
+// SearchPeople returns a page of the people whose name or aliases match query
+func (p *peopleService) SearchPeople(query string, page, limit int) ([]models.Person, int64, *apiError.Error) {
+	people, total, err := p.peopleRepo.SearchPeople(query, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("SearchPeople error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	return people, total, nil
+}
+
+// Filmography lists everything a person is credited on across trailers and full-length films
+func (p *peopleService) Filmography(personID uint) ([]models.FilmographyEntry, *apiError.Error) {
+	if _, apiErr := p.GetPerson(personID); apiErr != nil {
+		return nil, apiErr
+	}
+	entries, err := p.peopleRepo.FindFilmography(personID)
+	if err != nil {
+		log.Printf("Filmography error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return entries, nil
+}
+
+func (p *peopleService) AddCredit(titleType models.TitleType, titleID uint, request *models.CreditRequest) (*models.Credit, *apiError.Error) {
+	if !request.Role.IsValid() {
+		return nil, apiError.New("role must be one of actor, director, producer, dp, editor or composer", http.StatusBadRequest)
+	}
+	if apiErr := checkTitle(p.movieRepo, titleType, titleID); apiErr != nil {
+		return nil, apiErr
+	}
+	person, apiErr := p.GetPerson(request.PersonID)
+	if apiErr != nil {
+		if apiErr == apiError.ErrNotFound {
+			return nil, apiError.New("person not found", http.StatusNotFound)
+		}
+		return nil, apiErr
+	}
+
+	credit := &models.Credit{
+		PersonID:      person.ID,
+		TitleType:     titleType,
+		TitleID:       titleID,
+		Role:          request.Role,
+		CharacterName: request.CharacterName,
+		BillingOrder:  request.BillingOrder,
+	}
+	if err := p.peopleRepo.CreateCredit(credit); err != nil {
+		log.Printf("AddCredit error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	credit.Person = person
+	return credit, nil
+}
+
+func (p *peopleService) GetTitleCredits(titleType models.TitleType, titleID uint) ([]models.Credit, *apiError.Error) {
+	if apiErr := checkTitle(p.movieRepo, titleType, titleID); apiErr != nil {
+		return nil, apiErr
+	}
+	credits, err := p.peopleRepo.FindTitleCredits(titleType, titleID)
+	if err != nil {
+		log.Printf("GetTitleCredits error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return credits, nil
+}
+
+func (p *peopleService) RemoveCredit(id uint) *apiError.Error {
+	if _, err := p.peopleRepo.FindCredit(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.ErrNotFound
+		}
+		return apiError.ErrInternalServerError
+	}
+	if err := p.peopleRepo.DeleteCredit(id); err != nil {
+		log.Printf("RemoveCredit error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+// checkTitle makes sure a title exists before anything is read or written for it
+func checkTitle(movieRepo db.MovieRepository, titleType models.TitleType, titleID uint) *apiError.Error {
+	if !titleType.IsValid() {
+		return apiError.New("title type must be trailer or full_length", http.StatusBadRequest)
+	}
+	if _, err := movieRepo.FindTitle(titleType, titleID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.New("title not found", http.StatusNotFound)
+		}
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/shopspring/decimal"
+	"github.com/techagentng/telair-erp/config"
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+// PostingService books invoices, the payments received against them and approved costs in the general
+// ledger, in the reporting currency. It builds their journal entries, the repositories post them in the
+// transaction of the change they book so that the books never miss one.
+type PostingService interface {
+	InvoiceIssued(invoice *models.Invoice, userID uint) (*models.JournalEntry, *apiError.Error)
+	PaymentReceived(invoice *models.Invoice, payment *models.InvoicePayment, userID uint) (*models.JournalEntry, *apiError.Error)
+	CostApproved(cost *models.CostEntry, category models.BudgetCategory, userID uint) (*models.JournalEntry, *apiError.Error)
+}
+
+type postingService struct {
+	Config              *config.Config
+	ledgerRepo          db.LedgerRepository
+	exchangeRateService ExchangeRateService
+}
+
+// NewPostingService instantiate a postingService
+func NewPostingService(ledgerRepo db.LedgerRepository, exchangeRateService ExchangeRateService, conf *config.Config) PostingService {
+	return &postingService{
+		Config:              conf,
+		ledgerRepo:          ledgerRepo,
+		exchangeRateService: exchangeRateService,
+	}
+}
+
+// InvoiceIssued debits receivables with the total of an invoice and credits revenue and the taxes charged,
+// at the exchange rate of its issue date, which it sets on the invoice. An invoice of nothing books nothing.
+func (s *postingService) InvoiceIssued(invoice *models.Invoice, userID uint) (*models.JournalEntry, *apiError.Error) {
+	total, rate, apiErr := s.exchangeRateService.Convert(invoice.Total, invoice.Currency, s.Config.ReportingCurrency, *invoice.IssueDate)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	invoice.ExchangeRate = rate
+	if !total.IsPositive() {
+		return nil, nil
+	}
+	tax := invoice.TaxTotal.Mul(rate).Round(2)
+
+	accounts, apiErr := s.accounts(models.PurposeReceivable, models.PurposeRevenue, models.PurposeTaxPayable)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	entry := &models.JournalEntry{
+		Date:      *invoice.IssueDate,
+		Memo:      "Invoice to " + invoice.Organization.Name,
+		Source:    models.SourceInvoice,
+		SourceID:  invoice.ID,
+		CreatedBy: userID,
+		Lines: []models.JournalLine{
+			debit(accounts[models.PurposeReceivable], total),
+			credit(accounts[models.PurposeRevenue], total.Sub(tax)),
+		},
+	}
+	if tax.IsPositive() {
+		entry.Lines = append(entry.Lines, credit(accounts[models.PurposeTaxPayable], tax))
+	}
+	return entry, nil
+}
+
+// PaymentReceived debits the bank with a payment at the exchange rate of the day it was paid and clears
+// receivables at the rate the invoice was booked at, the difference is an exchange gain or loss. Invoices
+// issued before the ledger was kept were never booked, their receivables are cleared at the rate of the day.
+func (s *postingService) PaymentReceived(invoice *models.Invoice, payment *models.InvoicePayment, userID uint) (*models.JournalEntry, *apiError.Error) {
+	received, rate, apiErr := s.exchangeRateService.Convert(payment.Amount, invoice.Currency, s.Config.ReportingCurrency, payment.PaidOn)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if invoice.ExchangeRate.IsPositive() {
+		rate = invoice.ExchangeRate
+	}
+	cleared := payment.Amount.Mul(rate).Round(2)
+
+	accounts, apiErr := s.accounts(models.PurposeBank, models.PurposeReceivable, models.PurposeExchange)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	entry := &models.JournalEntry{
+		Date:      payment.PaidOn,
+		Memo:      "Payment received from " + invoice.Organization.Name,
+		Reference: invoice.Number,
+		Source:    models.SourceInvoicePayment,
+		CreatedBy: userID,
+		Lines: []models.JournalLine{
+			debit(accounts[models.PurposeBank], received),
+			credit(accounts[models.PurposeReceivable], cleared),
+		},
+	}
+	switch difference := received.Sub(cleared); {
+	case difference.IsPositive():
+		entry.Lines = append(entry.Lines, credit(accounts[models.PurposeExchange], difference))
+	case difference.IsNegative():
+		entry.Lines = append(entry.Lines, debit(accounts[models.PurposeExchange], difference.Neg()))
+	}
+	return entry, nil
+}
+
+// CostApproved debits the cost account of the budget category of an approved cost and credits the bank it
+// was paid from, at the exchange rate of the day it was incurred
+func (s *postingService) CostApproved(cost *models.CostEntry, category models.BudgetCategory, userID uint) (*models.JournalEntry, *apiError.Error) {
+	amount, _, apiErr := s.exchangeRateService.Convert(cost.Amount, cost.Currency, s.Config.ReportingCurrency, cost.IncurredOn)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if !amount.IsPositive() {
+		return nil, nil
+	}
+
+	purpose := models.CostPurpose(category)
+	accounts, apiErr := s.accounts(purpose, models.PurposeBank)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return &models.JournalEntry{
+		Date:      cost.IncurredOn,
+		Memo:      cost.Description,
+		Reference: cost.Reference,
+		Source:    models.SourceCost,
+		SourceID:  cost.ID,
+		CreatedBy: userID,
+		Lines: []models.JournalLine{
+			debit(accounts[purpose], amount),
+			credit(accounts[models.PurposeBank], amount),
+		},
+	}, nil
+}
+
+// accounts finds the accounts postings go to by purpose
+func (s *postingService) accounts(purposes ...models.AccountPurpose) (map[models.AccountPurpose]uint, *apiError.Error) {
+	accounts := map[models.AccountPurpose]uint{}
+	for _, purpose := range purposes {
+		account, err := s.ledgerRepo.FindAccountByPurpose(purpose)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apiError.New(fmt.Sprintf("the chart of accounts has no %s account", purpose), http.StatusConflict)
+			}
+			log.Printf("Posting error: %v", err)
+			return nil, apiError.ErrInternalServerError
+		}
+		accounts[purpose] = account.ID
+	}
+	return accounts, nil
+}
+
+func debit(accountID uint, amount decimal.Decimal) models.JournalLine {
+	return models.JournalLine{AccountID: accountID, Debit: amount, Credit: decimal.Zero}
+}
+
+func credit(accountID uint, amount decimal.Decimal) models.JournalLine {
+	return models.JournalLine{AccountID: accountID, Debit: decimal.Zero, Credit: amount}
+}
+
+// ledgerError turns the error of a change that posts to the ledger into a response when the ledger refused
+// it, an entry dated in a closed period being a conflict. It returns nil for any other error.
+func ledgerError(err error) *apiError.Error {
+	var closed *apiError.PeriodClosedError
+	if errors.As(err, &closed) {
+		return apiError.New(closed.Error(), http.StatusConflict)
+	}
+	return nil
+}
@@ -0,0 +1,372 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/techagentng/telair-erp/config"
+	"github.com/techagentng/telair-erp/db"
+	"github.com/techagentng/telair-erp/db/dbtest"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/services"
+	"gorm.io/gorm"
+)
+
+const postingUser uint = 1
+
+// ledgerFixture is a migrated database with the starting chart of accounts and USD rates to NGN, the
+// reporting currency
+type ledgerFixture struct {
+	conn     *gorm.DB
+	ledger   db.LedgerRepository
+	posting  services.PostingService
+	accounts map[models.AccountPurpose]uint
+}
+
+func newLedgerFixture(t *testing.T, rates map[string]string) *ledgerFixture {
+	t.Helper()
+	gdb := dbtest.Open(t)
+	if err := db.SeedAccounts(gdb.DB); err != nil {
+		t.Fatalf("SeedAccounts error: %v", err)
+	}
+
+	rateRepo := db.NewExchangeRateRepo(gdb)
+	for day, rate := range rates {
+		err := rateRepo.SaveRate(&models.ExchangeRate{Base: "USD", Quote: "NGN", EffectiveOn: date(t, day), Rate: decimal.RequireFromString(rate)})
+		if err != nil {
+			t.Fatalf("SaveRate error: %v", err)
+		}
+	}
+
+	ledger := db.NewLedgerRepo(gdb)
+	accounts, err := ledger.ListAccounts()
+	if err != nil {
+		t.Fatalf("ListAccounts error: %v", err)
+	}
+	byPurpose := map[models.AccountPurpose]uint{}
+	for _, account := range accounts {
+		byPurpose[account.Purpose] = account.ID
+	}
+
+	conf := &config.Config{ReportingCurrency: "NGN"}
+	return &ledgerFixture{
+		conn:     gdb.DB,
+		ledger:   ledger,
+		posting:  services.NewPostingService(ledger, services.NewExchangeRateService(rateRepo), conf),
+		accounts: byPurpose,
+	}
+}
+
+func date(t *testing.T, value string) time.Time {
+	t.Helper()
+	day, err := time.Parse(models.DateLayout, value)
+	if err != nil {
+		t.Fatalf("invalid date %q: %v", value, err)
+	}
+	return day
+}
+
+func amount(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+// post saves an entry built by the posting service as a draft and posts it, the way the repositories
+// post entries within the change they book
+func (f *ledgerFixture) post(t *testing.T, entry *models.JournalEntry) *models.JournalEntry {
+	t.Helper()
+	if entry == nil {
+		t.Fatal("no journal entry was built")
+	}
+	assertBalanced(t, entry)
+	entry.Status = models.EntryDraft
+	if err := f.ledger.CreateEntry(entry); err != nil {
+		t.Fatalf("CreateEntry error: %v", err)
+	}
+	if err := f.ledger.PostEntry(entry.ID, postingUser); err != nil {
+		t.Fatalf("PostEntry error: %v", err)
+	}
+	posted, err := f.ledger.FindEntry(entry.ID)
+	if err != nil {
+		t.Fatalf("FindEntry error: %v", err)
+	}
+	if posted.Status != models.EntryPosted || posted.Number == "" {
+		t.Fatalf("entry %d is %s with number %q, want it posted and numbered", posted.ID, posted.Status, posted.Number)
+	}
+	assertBalanced(t, posted)
+	return posted
+}
+
+func assertBalanced(t *testing.T, entry *models.JournalEntry) {
+	t.Helper()
+	debit, credit := decimal.Zero, decimal.Zero
+	for _, line := range entry.Lines {
+		if line.Debit.IsNegative() || line.Credit.IsNegative() || (line.Debit.IsPositive() && line.Credit.IsPositive()) {
+			t.Errorf("line on account %d debits %s and credits %s", line.AccountID, line.Debit, line.Credit)
+		}
+		debit = debit.Add(line.Debit)
+		credit = credit.Add(line.Credit)
+	}
+	if !debit.IsPositive() || !debit.Equal(credit) {
+		t.Fatalf("entry does not balance: debits %s, credits %s", debit, credit)
+	}
+}
+
+// line returns the amount an entry debits, positive, or credits, negative, to an account
+func (f *ledgerFixture) line(entry *models.JournalEntry, purpose models.AccountPurpose) decimal.Decimal {
+	total := decimal.Zero
+	for _, line := range entry.Lines {
+		if line.AccountID == f.accounts[purpose] {
+			total = total.Add(line.Debit).Sub(line.Credit)
+		}
+	}
+	return total
+}
+
+func assertAmount(t *testing.T, what string, got, want decimal.Decimal) {
+	t.Helper()
+	if !got.Equal(want) {
+		t.Errorf("%s = %s, want %s", what, got, want)
+	}
+}
+
+func invoice(t *testing.T, id uint, issued, total, tax string) *models.Invoice {
+	t.Helper()
+	issueDate := date(t, issued)
+	return &models.Invoice{
+		Model:        models.Model{ID: id},
+		Number:       "INV-TEST",
+		Currency:     "USD",
+		IssueDate:    &issueDate,
+		TaxTotal:     amount(tax),
+		Total:        amount(total),
+		Organization: &models.Organization{Name: "Lagos Cinemas"},
+	}
+}
+
+func TestInvoiceIssuedBalances(t *testing.T) {
+	f := newLedgerFixture(t, map[string]string{"2025-03-01": "1534.56789"})
+	rate := amount("1534.56789")
+
+	t.Run("without tax", func(t *testing.T) {
+		inv := invoice(t, 1, "2025-03-03", "1000.00", "0")
+		entry, apiErr := f.posting.InvoiceIssued(inv, postingUser)
+		if apiErr != nil {
+			t.Fatalf("InvoiceIssued error: %v", apiErr)
+		}
+		assertAmount(t, "invoice exchange rate", inv.ExchangeRate, rate)
+
+		posted := f.post(t, entry)
+		receivable := amount("1000.00").Mul(rate).Round(2)
+		assertAmount(t, "receivables", f.line(posted, models.PurposeReceivable), receivable)
+		assertAmount(t, "revenue", f.line(posted, models.PurposeRevenue), receivable.Neg())
+		if len(posted.Lines) != 2 {
+			t.Errorf("entry has %d lines, want no tax line", len(posted.Lines))
+		}
+	})
+
+	t.Run("with tax rounded in the reporting currency", func(t *testing.T) {
+		// 75.33 USD of tax and 1000.00 of net are each a fraction of a kobo off once converted,
+		// revenue takes what is left of the rounded total so that the entry still balances
+		inv := invoice(t, 2, "2025-03-04", "1075.33", "75.33")
+		entry, apiErr := f.posting.InvoiceIssued(inv, postingUser)
+		if apiErr != nil {
+			t.Fatalf("InvoiceIssued error: %v", apiErr)
+		}
+
+		posted := f.post(t, entry)
+		receivable := amount("1075.33").Mul(rate).Round(2)
+		tax := amount("75.33").Mul(rate).Round(2)
+		assertAmount(t, "receivables", f.line(posted, models.PurposeReceivable), receivable)
+		assertAmount(t, "tax payable", f.line(posted, models.PurposeTaxPayable), tax.Neg())
+		assertAmount(t, "revenue", f.line(posted, models.PurposeRevenue), receivable.Sub(tax).Neg())
+		assertAmount(t, "revenue plus tax", f.line(posted, models.PurposeRevenue).Add(f.line(posted, models.PurposeTaxPayable)), receivable.Neg())
+	})
+}
+
+func TestPaymentReceivedBooksExchangeDifferences(t *testing.T) {
+	f := newLedgerFixture(t, map[string]string{
+		"2025-03-01": "1534.56789",
+		"2025-04-01": "1601.25",
+		"2025-05-01": "1490.1",
+	})
+	inv := invoice(t, 1, "2025-03-03", "1000.00", "0")
+	entry, apiErr := f.posting.InvoiceIssued(inv, postingUser)
+	if apiErr != nil {
+		t.Fatalf("InvoiceIssued error: %v", apiErr)
+	}
+	f.post(t, entry)
+	booked := amount("1534.56789")
+
+	cases := []struct {
+		name   string
+		id     uint
+		paidOn string
+		amount string
+		rate   string
+	}{
+		{"gain when the currency rose", 1, "2025-04-10", "500.00", "1601.25"},
+		{"loss when the currency fell", 2, "2025-05-20", "200.00", "1490.1"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			payment := &models.InvoicePayment{InvoiceID: inv.ID, Amount: amount(c.amount), PaidOn: date(t, c.paidOn)}
+			entry, apiErr := f.posting.PaymentReceived(inv, payment, postingUser)
+			if apiErr != nil {
+				t.Fatalf("PaymentReceived error: %v", apiErr)
+			}
+			entry.SourceID = c.id
+
+			posted := f.post(t, entry)
+			received := amount(c.amount).Mul(amount(c.rate)).Round(2)
+			cleared := amount(c.amount).Mul(booked).Round(2)
+			assertAmount(t, "bank", f.line(posted, models.PurposeBank), received)
+			assertAmount(t, "receivables", f.line(posted, models.PurposeReceivable), cleared.Neg())
+			// a gain is credited and a loss debited, either way it is what the bank and receivables miss
+			assertAmount(t, "exchange differences", f.line(posted, models.PurposeExchange), cleared.Sub(received))
+		})
+	}
+}
+
+func TestCostApprovedBalances(t *testing.T) {
+	f := newLedgerFixture(t, map[string]string{"2025-03-01": "1534.56789"})
+	cost := &models.CostEntry{
+		Model:       models.Model{ID: 7},
+		Description: "Camera rental",
+		IncurredOn:  date(t, "2025-03-15"),
+		Currency:    "USD",
+		Amount:      amount("250.55"),
+	}
+	entry, apiErr := f.posting.CostApproved(cost, models.Production, postingUser)
+	if apiErr != nil {
+		t.Fatalf("CostApproved error: %v", apiErr)
+	}
+
+	posted := f.post(t, entry)
+	base := amount("250.55").Mul(amount("1534.56789")).Round(2)
+	assertAmount(t, "production costs", f.line(posted, models.CostPurpose(models.Production)), base)
+	assertAmount(t, "bank", f.line(posted, models.PurposeBank), base.Neg())
+	if posted.Source != models.SourceCost || posted.SourceID != cost.ID {
+		t.Errorf("entry books %s %d, want %s %d", posted.Source, posted.SourceID, models.SourceCost, cost.ID)
+	}
+}
+
+func TestReverseEntry(t *testing.T) {
+	f := newLedgerFixture(t, nil)
+	cost := &models.CostEntry{Model: models.Model{ID: 3}, Description: "Sound mix", IncurredOn: date(t, "2025-06-02"), Currency: "NGN", Amount: amount("480000.00")}
+	entry, apiErr := f.posting.CostApproved(cost, models.PostProduction, postingUser)
+	if apiErr != nil {
+		t.Fatalf("CostApproved error: %v", apiErr)
+	}
+	original := f.post(t, entry)
+
+	reversal, err := f.ledger.ReverseEntry(original.ID, date(t, "2025-06-30"), "", postingUser)
+	if err != nil {
+		t.Fatalf("ReverseEntry error: %v", err)
+	}
+	reversal, err = f.ledger.FindEntry(reversal.ID)
+	if err != nil {
+		t.Fatalf("FindEntry error: %v", err)
+	}
+	if reversal.Status != models.EntryPosted || reversal.ReversalOfID == nil || *reversal.ReversalOfID != original.ID {
+		t.Fatalf("reversal is %s reversing %v, want a posted reversal of %d", reversal.Status, reversal.ReversalOfID, original.ID)
+	}
+	assertBalanced(t, reversal)
+	for _, purpose := range []models.AccountPurpose{models.CostPurpose(models.PostProduction), models.PurposeBank} {
+		assertAmount(t, string(purpose)+" after the reversal", f.line(original, purpose).Add(f.line(reversal, purpose)), decimal.Zero)
+	}
+
+	original, err = f.ledger.FindEntry(original.ID)
+	if err != nil {
+		t.Fatalf("FindEntry error: %v", err)
+	}
+	if original.ReversedByID == nil || *original.ReversedByID != reversal.ID {
+		t.Errorf("original is reversed by %v, want %d", original.ReversedByID, reversal.ID)
+	}
+	if _, err := f.ledger.ReverseEntry(original.ID, date(t, "2025-06-30"), "", postingUser); err == nil {
+		t.Error("an entry was reversed twice")
+	}
+}
+
+func TestLedgerTriggers(t *testing.T) {
+	f := newLedgerFixture(t, nil)
+	bank, revenue := f.accounts[models.PurposeBank], f.accounts[models.PurposeRevenue]
+	manual := func(debit, credit string) *models.JournalEntry {
+		return &models.JournalEntry{
+			Date:      date(t, "2025-07-01"),
+			Memo:      "Manual entry",
+			Status:    models.EntryDraft,
+			Source:    models.SourceManual,
+			CreatedBy: postingUser,
+			Lines: []models.JournalLine{
+				{AccountID: bank, Debit: amount(debit), Credit: decimal.Zero},
+				{AccountID: revenue, Debit: decimal.Zero, Credit: amount(credit)},
+			},
+		}
+	}
+
+	t.Run("entries are created as drafts", func(t *testing.T) {
+		entry := manual("100.00", "100.00")
+		entry.Status = models.EntryPosted
+		if err := f.ledger.CreateEntry(entry); err == nil {
+			t.Error("an entry was created posted")
+		}
+	})
+
+	t.Run("unbalanced entries are not posted", func(t *testing.T) {
+		entry := manual("100.00", "90.00")
+		if err := f.ledger.CreateEntry(entry); err != nil {
+			t.Fatalf("CreateEntry error: %v", err)
+		}
+		// Straight to the database, past the check posting makes first
+		err := f.conn.Exec("UPDATE journal_entries SET status = ? WHERE id = ?", models.EntryPosted, entry.ID).Error
+		if err == nil {
+			t.Error("the database posted an entry that does not balance")
+		}
+		if err := f.ledger.PostEntry(entry.ID, postingUser); err == nil {
+			t.Error("PostEntry posted an entry that does not balance")
+		}
+	})
+
+	t.Run("posted entries never change", func(t *testing.T) {
+		entry := manual("100.00", "100.00")
+		if err := f.ledger.CreateEntry(entry); err != nil {
+			t.Fatalf("CreateEntry error: %v", err)
+		}
+		if err := f.ledger.PostEntry(entry.ID, postingUser); err != nil {
+			t.Fatalf("PostEntry error: %v", err)
+		}
+
+		edits := map[string]func() error{
+			"memo edited": func() error {
+				return f.conn.Exec("UPDATE journal_entries SET memo = 'changed' WHERE id = ?", entry.ID).Error
+			},
+			"entry deleted": func() error {
+				return f.conn.Exec("DELETE FROM journal_entries WHERE id = ?", entry.ID).Error
+			},
+			"line edited": func() error {
+				return f.conn.Exec("UPDATE journal_lines SET debit = 50, credit = 0 WHERE entry_id = ? AND account_id = ?", entry.ID, bank).Error
+			},
+			"line deleted": func() error {
+				return f.conn.Exec("DELETE FROM journal_lines WHERE entry_id = ?", entry.ID).Error
+			},
+			"line added": func() error {
+				return f.conn.Create(&models.JournalLine{EntryID: entry.ID, AccountID: bank, Debit: amount("1.00"), Credit: decimal.Zero}).Error
+			},
+		}
+		for name, edit := range edits {
+			if err := edit(); err == nil {
+				t.Errorf("%s: the database let a posted entry change", name)
+			}
+		}
+
+		posted, err := f.ledger.FindEntry(entry.ID)
+		if err != nil {
+			t.Fatalf("FindEntry error: %v", err)
+		}
+		if posted.Memo != "Manual entry" || len(posted.Lines) != 2 {
+			t.Errorf("posted entry changed: memo %q, %d lines", posted.Memo, len(posted.Lines))
+		}
+		assertBalanced(t, posted)
+	})
+}
@@ -0,0 +1,427 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/techagentng/telair-erp/config"
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/pdf"
+	"github.com/techagentng/telair-erp/storage"
+	"gorm.io/gorm"
+)
+
+// maxPosterBytes bounds the poster read into memory for the PDF fact sheet
+const maxPosterBytes = 20 << 20
+
+// imageExtensions maps the content types of stills and posters to the extension they get in the bundle
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+	"image/tiff": ".tif",
+}
+
+// PressKitService assembles the electronic press kit of a title: its details, credits, stills, posters and
+// trailer links, along with a fact sheet, bundled in a ZIP
+type PressKitService interface {
+	ListAssets(titleType models.TitleType, titleID uint) ([]models.PressKitAsset, *apiError.Error)
+	SetAssets(titleType models.TitleType, titleID uint, assetIDs []uint) ([]models.PressKitAsset, *apiError.Error)
+	BuildPressKit(titleType models.TitleType, titleID uint) (*models.PressKit, *apiError.Error)
+	WriteBundle(ctx context.Context, kit *models.PressKit, w io.Writer) error
+}
+
+type pressKitService struct {
+	Config       *config.Config
+	pressKitRepo db.PressKitRepository
+	movieRepo    db.MovieRepository
+	peopleRepo   db.PeopleRepository
+	taxonomyRepo db.TaxonomyRepository
+	storage      storage.Storage
+}
+
+// NewPressKitService instantiate a pressKitService
+func NewPressKitService(pressKitRepo db.PressKitRepository, movieRepo db.MovieRepository, peopleRepo db.PeopleRepository, taxonomyRepo db.TaxonomyRepository, store storage.Storage, conf *config.Config) PressKitService {
+	return &pressKitService{
+		Config:       conf,
+		pressKitRepo: pressKitRepo,
+		movieRepo:    movieRepo,
+		peopleRepo:   peopleRepo,
+		taxonomyRepo: taxonomyRepo,
+		storage:      store,
+	}
+}
+
+// ListAssets returns what goes in the press kit of a title: the assets picked for it, or when none were, every
+// still and poster of the title and, for trailers, their videos
+func (s *pressKitService) ListAssets(titleType models.TitleType, titleID uint) ([]models.PressKitAsset, *apiError.Error) {
+	if apiErr := checkTitle(s.movieRepo, titleType, titleID); apiErr != nil {
+		return nil, apiErr
+	}
+	picked, err := s.pressKitRepo.FindPressKitAssets(titleType, titleID)
+	if err != nil {
+		log.Printf("ListAssets error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	if len(picked) > 0 {
+		return picked, nil
+	}
+
+	kinds := []models.MediaKind{models.MediaPoster, models.MediaPicture}
+	if titleType == models.TitleTrailer {
+		kinds = append(kinds, models.MediaVideo)
+	}
+	assets, err := s.pressKitRepo.FindTitleAssets(titleType, titleID, kinds)
+	if err != nil {
+		log.Printf("ListAssets error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	picked = make([]models.PressKitAsset, len(assets))
+	for i := range assets {
+		picked[i] = models.PressKitAsset{TitleType: titleType, TitleID: titleID, AssetID: assets[i].ID, Asset: &assets[i], Position: i}
+	}
+	return picked, nil
+}
+
+// SetAssets picks the assets of the press kit of a title, in order. Stills and posters have to be the title's
+// own, videos have to be trailers so that a film's press kit never links to the film itself. An empty list
+// goes back to the default of ListAssets.
+func (s *pressKitService) SetAssets(titleType models.TitleType, titleID uint, assetIDs []uint) ([]models.PressKitAsset, *apiError.Error) {
+	if apiErr := checkTitle(s.movieRepo, titleType, titleID); apiErr != nil {
+		return nil, apiErr
+	}
+	assets, err := s.pressKitRepo.FindAssets(assetIDs)
+	if err != nil {
+		return nil, apiError.ErrInternalServerError
+	}
+	found := map[uint]models.MediaAsset{}
+	for _, asset := range assets {
+		found[asset.ID] = asset
+	}
+
+	var ids []uint
+	seen := map[uint]bool{}
+	for _, id := range assetIDs {
+		asset, ok := found[id]
+		if !ok {
+			return nil, apiError.New(fmt.Sprintf("media asset %d not found", id), http.StatusNotFound)
+		}
+		switch asset.Kind {
+		case models.MediaPoster, models.MediaPicture:
+			if asset.TitleType != titleType || asset.TitleID != titleID {
+				return nil, apiError.New(fmt.Sprintf("media asset %d belongs to another title", id), http.StatusBadRequest)
+			}
+		case models.MediaVideo:
+			if asset.TitleType != models.TitleTrailer {
+				return nil, apiError.New(fmt.Sprintf("media asset %d is not a trailer", id), http.StatusBadRequest)
+			}
+		default:
+			return nil, apiError.New(fmt.Sprintf("media asset %d is a %s, press kits take stills, posters and trailers", id, asset.Kind), http.StatusBadRequest)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if err := s.pressKitRepo.SetPressKitAssets(titleType, titleID, ids); err != nil {
+		log.Printf("SetAssets error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.ListAssets(titleType, titleID)
+}
+
+// linkExpiry is how long trailer links in a press kit work
+func (s *pressKitService) linkExpiry() time.Duration {
+	hours := s.Config.PressKitLinkExpiryHours
+	if hours <= 0 || hours > 168 {
+		hours = 168
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// BuildPressKit gathers the manifest of the press kit of a title. Its fingerprint covers everything in the
+// bundle except the trailer links, which are signed anew every time. So that a cached bundle never outlives
+// its links, the fingerprint also changes every half of the link expiry when there are trailers.
+func (s *pressKitService) BuildPressKit(titleType models.TitleType, titleID uint) (*models.PressKit, *apiError.Error) {
+	if apiErr := checkTitle(s.movieRepo, titleType, titleID); apiErr != nil {
+		return nil, apiErr
+	}
+	title, err := s.movieRepo.FindTitle(titleType, titleID)
+	if err != nil {
+		return nil, apiError.ErrInternalServerError
+	}
+
+	kit := &models.PressKit{
+		TitleType:       titleType,
+		TitleID:         titleID,
+		Title:           title.Title,
+		Synopsis:        title.Description,
+		DurationMinutes: title.Duration,
+		Terms:           map[string][]string{},
+		Credits:         []models.PressKitCredit{},
+		Files:           []models.PressKitFile{},
+		Trailers:        []models.PressKitTrailer{},
+		Filename:        slugify(title.Title) + "-press-kit.zip",
+	}
+	if kit.Filename == "-press-kit.zip" {
+		kit.Filename = fmt.Sprintf("%s-%d-press-kit.zip", titleType, titleID)
+	}
+	if titleType == models.TitleTrailer {
+		trailer, err := s.pressKitRepo.FindTrailer(titleID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrInternalServerError
+		}
+		if trailer != nil {
+			kit.LogLine = trailer.LogLine
+			kit.Year = trailer.ProductYear
+		}
+	}
+
+	terms, err := s.taxonomyRepo.FindTitleTerms(titleType, titleID)
+	if err != nil {
+		log.Printf("BuildPressKit error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	for _, term := range terms {
+		kit.Terms[string(term.Kind)] = append(kit.Terms[string(term.Kind)], term.Name)
+	}
+	credits, err := s.peopleRepo.FindTitleCredits(titleType, titleID)
+	if err != nil {
+		log.Printf("BuildPressKit error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	for _, credit := range credits {
+		if credit.Person == nil {
+			continue
+		}
+		kit.Credits = append(kit.Credits, models.PressKitCredit{Name: credit.Person.Name, Role: credit.Role, CharacterName: credit.CharacterName})
+	}
+
+	picked, apiErr := s.ListAssets(titleType, titleID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	var trailers []models.MediaAsset
+	counts := map[models.MediaKind]int{}
+	for _, kind := range []models.MediaKind{models.MediaPoster, models.MediaPicture} {
+		for _, p := range picked {
+			if p.Asset.Kind != kind {
+				continue
+			}
+			counts[kind]++
+			kit.Files = append(kit.Files, pressKitFile(p.Asset, counts[kind]))
+		}
+	}
+	for _, p := range picked {
+		if p.Asset.Kind == models.MediaVideo {
+			trailers = append(trailers, *p.Asset)
+		}
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d\n", title.Version)
+	json.NewEncoder(hash).Encode(kit)
+	now := time.Now().UTC()
+	if len(trailers) > 0 {
+		fmt.Fprintf(hash, "%d\n", now.Unix()/int64(s.linkExpiry()/2/time.Second))
+	}
+	for _, asset := range trailers {
+		fmt.Fprintf(hash, "%s\n", asset.SHA256)
+		presigned, err := s.storage.PresignGet(context.Background(), asset.StorageKey, s.linkExpiry())
+		if err != nil {
+			log.Printf("BuildPressKit error: %v", err)
+			return nil, apiError.ErrInternalServerError
+		}
+		name := kit.Title
+		if asset.TitleID != titleID || asset.TitleType != titleType {
+			if trailer, err := s.movieRepo.FindTitle(asset.TitleType, asset.TitleID); err == nil {
+				name = trailer.Title
+			}
+		}
+		kit.Trailers = append(kit.Trailers, models.PressKitTrailer{
+			Title:           name,
+			URL:             presigned.URL,
+			ExpiresAt:       now.Add(s.linkExpiry()),
+			DurationSeconds: asset.DurationSeconds,
+			Width:           asset.Width,
+			Height:          asset.Height,
+			SHA256:          asset.SHA256,
+		})
+	}
+	kit.Fingerprint = hex.EncodeToString(hash.Sum(nil))[:32]
+	kit.GeneratedAt = now
+	return kit, nil
+}
+
+// pressKitFile places a still or poster in the bundle, e.g. the second still goes to stills/still-02.jpg
+func pressKitFile(asset *models.MediaAsset, number int) models.PressKitFile {
+	folder, name := "stills", "still"
+	if asset.Kind == models.MediaPoster {
+		folder, name = "posters", "poster"
+	}
+	ext, ok := imageExtensions[asset.ContentType]
+	if !ok {
+		ext = strings.ToLower(path.Ext(asset.Filename))
+	}
+	return models.PressKitFile{
+		Path:        fmt.Sprintf("%s/%s-%02d%s", folder, name, number, ext),
+		Kind:        asset.Kind,
+		ContentType: asset.ContentType,
+		Size:        asset.Size,
+		SHA256:      asset.SHA256,
+		Width:       asset.Width,
+		Height:      asset.Height,
+		StorageKey:  asset.StorageKey,
+	}
+}
+
+// WriteBundle streams the ZIP of a press kit to w: the JSON manifest, the fact sheet in HTML and PDF, then the
+// stills and posters copied straight from storage. Once it started writing, an error leaves w with a broken ZIP.
+func (s *pressKitService) WriteBundle(ctx context.Context, kit *models.PressKit, w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	var manifest bytes.Buffer
+	encoder := json.NewEncoder(&manifest)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(kit); err != nil {
+		return err
+	}
+	if err := s.writeEntry(zw, "manifest.json", zip.Deflate, kit.GeneratedAt, &manifest); err != nil {
+		return err
+	}
+
+	var html bytes.Buffer
+	if err := factSheetTemplate.Execute(&html, kit); err != nil {
+		return fmt.Errorf("error rendering the fact sheet: %w", err)
+	}
+	if err := s.writeEntry(zw, "fact-sheet.html", zip.Deflate, kit.GeneratedAt, &html); err != nil {
+		return err
+	}
+
+	poster, posterType := s.readPoster(ctx, kit)
+	var sheet bytes.Buffer
+	if err := pdf.FactSheet(&sheet, kit, poster, posterType); err != nil {
+		return fmt.Errorf("error rendering the fact sheet: %w", err)
+	}
+	if err := s.writeEntry(zw, "fact-sheet.pdf", zip.Deflate, kit.GeneratedAt, &sheet); err != nil {
+		return err
+	}
+
+	for _, file := range kit.Files {
+		body, err := s.storage.Open(ctx, file.StorageKey)
+		if err != nil {
+			return fmt.Errorf("error opening %s: %w", file.StorageKey, err)
+		}
+		// images are compressed already
+		err = s.writeEntry(zw, file.Path, zip.Store, kit.GeneratedAt, body)
+		body.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (s *pressKitService) writeEntry(zw *zip.Writer, name string, method uint16, modified time.Time, body io.Reader) error {
+	entry, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: modified})
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(entry, body); err != nil {
+		return fmt.Errorf("error writing %s: %w", name, err)
+	}
+	return nil
+}
+
+// readPoster loads the first poster of a press kit for the PDF fact sheet, which goes without one it cannot read
+func (s *pressKitService) readPoster(ctx context.Context, kit *models.PressKit) ([]byte, string) {
+	for _, file := range kit.Files {
+		if file.Kind != models.MediaPoster || file.Size > maxPosterBytes {
+			continue
+		}
+		if file.ContentType != "image/jpeg" && file.ContentType != "image/png" {
+			continue
+		}
+		body, err := s.storage.Open(ctx, file.StorageKey)
+		if err != nil {
+			log.Printf("WriteBundle error: unable to read poster %s: %v", file.StorageKey, err)
+			return nil, ""
+		}
+		defer body.Close()
+		data, err := io.ReadAll(io.LimitReader(body, maxPosterBytes))
+		if err != nil {
+			log.Printf("WriteBundle error: unable to read poster %s: %v", file.StorageKey, err)
+			return nil, ""
+		}
+		return data, file.ContentType
+	}
+	return nil, ""
+}
+
+var factSheetTemplate = template.Must(template.New("fact-sheet").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} - Press kit</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; max-width: 960px; margin: 2em auto; padding: 0 1em; color: #222; }
+header { display: flex; gap: 2em; }
+header img { width: 260px; height: auto; }
+dl { display: grid; grid-template-columns: max-content auto; gap: .3em 1em; }
+dt { font-weight: bold; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: .3em .6em; border-bottom: 1px solid #ddd; }
+.stills { display: flex; flex-wrap: wrap; gap: .5em; }
+.stills img { height: 160px; width: auto; }
+</style>
+</head>
+<body>
+<header>
+{{range .Files}}{{if eq .Kind "poster"}}<img src="{{.Path}}" alt="Poster">{{break}}{{end}}{{end}}
+<div>
+<h1>{{.Title}}</h1>
+{{with .LogLine}}<p><em>{{.}}</em></p>{{end}}
+<dl>
+{{with .Year}}<dt>Year</dt><dd>{{.}}</dd>{{end}}
+{{with .DurationMinutes}}<dt>Running time</dt><dd>{{.}} min</dd>{{end}}
+{{range $kind, $names := .Terms}}<dt>{{$kind}}</dt><dd>{{range $i, $name := $names}}{{if $i}}, {{end}}{{$name}}{{end}}</dd>
+{{end}}
+</dl>
+</div>
+</header>
+{{with .Synopsis}}<h2>Synopsis</h2>
+<p>{{.}}</p>{{end}}
+{{with .Credits}}<h2>Cast and crew</h2>
+<table>
+<tr><th>Name</th><th>Role</th><th>Character</th></tr>
+{{range .}}<tr><td>{{.Name}}</td><td>{{.Role}}</td><td>{{.CharacterName}}</td></tr>
+{{end}}</table>{{end}}
+{{with .Trailers}}<h2>Trailers</h2>
+<ul>
+{{range .}}<li><a href="{{.URL}}">{{.Title}}</a>, available until {{.ExpiresAt.Format "2 Jan 2006"}}</li>
+{{end}}</ul>{{end}}
+{{if .Files}}<h2>Stills and posters</h2>
+<div class="stills">
+{{range .Files}}<a href="{{.Path}}"><img src="{{.Path}}" alt="{{.Kind}}"></a>
+{{end}}</div>{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+// progressSaveInterval throttles how often a running upload writes its progress
+const progressSaveInterval = 500 * time.Millisecond
+
+// ProgressStore is where upload progress lives. db.ProgressRepository keeps it in Postgres,
+// NewMemoryProgressStore keeps it in the current process.
+type ProgressStore interface {
+	SaveProgress(progress *models.UploadProgress) error
+	GetProgress(sessionID string) (*models.UploadProgress, error)
+}
+
+// ProgressService tracks the byte level progress of uploads
+type ProgressService interface {
+	CreateSession(userID uint) (*models.UploadProgress, error)
+	GetSession(sessionID string, userID uint) (*models.UploadProgress, *apiError.Error)
+	Track(progress *models.UploadProgress, totalBytes int64, r io.Reader) io.Reader
+	Finish(progress *models.UploadProgress, uploadErr error)
+}
+
+type progressService struct {
+	store ProgressStore
+}
+
+// NewProgressService instantiate a progressService
+func NewProgressService(store ProgressStore) ProgressService {
+	return &progressService{store: store}
+}
+
+func (p *progressService) CreateSession(userID uint) (*models.UploadProgress, error) {
+	progress := &models.UploadProgress{
+		SessionID: uuid.New().String(),
+		UserID:    userID,
+		Status:    models.UploadPending,
+		UpdatedAt: time.Now(),
+	}
+	if err := p.store.SaveProgress(progress); err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+func (p *progressService) GetSession(sessionID string, userID uint) (*models.UploadProgress, *apiError.Error) {
+	progress, err := p.store.GetProgress(sessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.New("No progress found for sessionID", http.StatusNotFound)
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	if progress.UserID != userID {
+		return nil, apiError.New("No progress found for sessionID", http.StatusNotFound)
+	}
+	return progress, nil
+}
+
+// Track returns a reader that reports every byte read from r against the session
+func (p *progressService) Track(progress *models.UploadProgress, totalBytes int64, r io.Reader) io.Reader {
+	progress.TotalBytes = totalBytes
+	progress.BytesRead = 0
+	progress.Status = models.UploadUploading
+	p.save(progress)
+	return &progressReader{r: r, progress: progress, service: p, lastSave: time.Now()}
+}
+
+// Finish marks the session as completed, or failed when uploadErr is set
+func (p *progressService) Finish(progress *models.UploadProgress, uploadErr error) {
+	progress.Status = models.UploadCompleted
+	if uploadErr != nil {
+		progress.Status = models.UploadFailed
+		progress.Error = uploadErr.Error()
+	} else if progress.TotalBytes > 0 {
+		progress.BytesRead = progress.TotalBytes
+		progress.Percentage = 100
+	}
+	p.save(progress)
+}
+
+func (p *progressService) save(progress *models.UploadProgress) {
+	if progress.TotalBytes > 0 {
+		progress.Percentage = float64(progress.BytesRead) * 100 / float64(progress.TotalBytes)
+	}
+	progress.UpdatedAt = time.Now()
+	if err := p.store.SaveProgress(progress); err != nil {
+		log.Printf("error saving progress of session %s: %v", progress.SessionID, err)
+	}
+}
+
+type progressReader struct {
+	r        io.Reader
+	progress *models.UploadProgress
+	service  *progressService
+	lastSave time.Time
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	pr.progress.BytesRead += int64(n)
+	if time.Since(pr.lastSave) >= progressSaveInterval {
+		pr.service.save(pr.progress)
+		pr.lastSave = time.Now()
+	}
+	return n, err
+}
+
+type memoryProgressStore struct {
+	mu       sync.RWMutex
+	sessions map[string]models.UploadProgress
+}
+
+// NewMemoryProgressStore keeps progress in memory, it is lost on restart and not shared between instances
+func NewMemoryProgressStore() ProgressStore {
+	return &memoryProgressStore{sessions: map[string]models.UploadProgress{}}
+}
+
+func (m *memoryProgressStore) SaveProgress(progress *models.UploadProgress) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[progress.SessionID] = *progress
+	return nil
+}
+
+func (m *memoryProgressStore) GetProgress(sessionID string) (*models.UploadProgress, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	progress, ok := m.sessions[sessionID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &progress, nil
+}
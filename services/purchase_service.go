@@ -0,0 +1,648 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/techagentng/telair-erp/config"
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+// PurchaseService runs purchasing: purchase orders and their approvals, the goods received against them
+// and the vendor bills matched against both
+type PurchaseService interface {
+	ListApprovalLevels() ([]models.PurchaseApprovalLevel, *apiError.Error)
+	SetApprovalLevels(requests []models.PurchaseApprovalLevelRequest) ([]models.PurchaseApprovalLevel, *apiError.Error)
+	CreateOrder(request *models.PurchaseOrderRequest, userID uint) (*models.PurchaseOrder, *apiError.Error)
+	UpdateOrder(id uint, request *models.PurchaseOrderRequest) (*models.PurchaseOrder, *apiError.Error)
+	DeleteOrder(id uint) *apiError.Error
+	GetOrder(id uint) (*models.PurchaseOrder, *apiError.Error)
+	ListOrders(filter *models.PurchaseOrderFilter) ([]models.PurchaseOrder, int64, *apiError.Error)
+	ListAwaitingApproval(userID uint, role string) ([]models.PurchaseOrder, *apiError.Error)
+	SubmitOrder(id, userID uint) (*models.PurchaseOrder, *apiError.Error)
+	WithdrawOrder(id, userID uint, role string) (*models.PurchaseOrder, *apiError.Error)
+	DecideOrder(id uint, decision models.ApprovalDecision, request *models.PurchaseDecisionRequest, userID uint, role string) (*models.PurchaseOrder, *apiError.Error)
+	PlaceOrder(id uint) (*models.PurchaseOrder, *apiError.Error)
+	CloseOrder(id uint, request *models.PurchaseOrderCloseRequest, userID uint) (*models.PurchaseOrder, *apiError.Error)
+	ReceiveGoods(id uint, request *models.GoodsReceiptRequest, userID uint) (*models.GoodsReceipt, *apiError.Error)
+	ListReceipts(id uint) ([]models.GoodsReceipt, *apiError.Error)
+	RecordBill(id uint, request *models.VendorBillRequest, userID uint) (*models.VendorBill, *apiError.Error)
+	GetBill(id uint) (*models.VendorBill, *apiError.Error)
+	ListBills(id uint) ([]models.VendorBill, *apiError.Error)
+	ReviewBill(id uint, status models.VendorBillStatus, request *models.VendorBillReviewRequest, userID uint) (*models.VendorBill, *apiError.Error)
+}
+
+type purchaseService struct {
+	Config              *config.Config
+	purchaseRepo        db.PurchaseRepository
+	vendorRepo          db.VendorRepository
+	budgetRepo          db.BudgetRepository
+	exchangeRateService ExchangeRateService
+}
+
+// NewPurchaseService instantiate a purchaseService
+func NewPurchaseService(purchaseRepo db.PurchaseRepository, vendorRepo db.VendorRepository, budgetRepo db.BudgetRepository, exchangeRateService ExchangeRateService, conf *config.Config) PurchaseService {
+	return &purchaseService{
+		Config:              conf,
+		purchaseRepo:        purchaseRepo,
+		vendorRepo:          vendorRepo,
+		budgetRepo:          budgetRepo,
+		exchangeRateService: exchangeRateService,
+	}
+}
+
+func (s *purchaseService) ListApprovalLevels() ([]models.PurchaseApprovalLevel, *apiError.Error) {
+	levels, err := s.purchaseRepo.ListApprovalLevels()
+	if err != nil {
+		log.Printf("ListApprovalLevels error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return levels, nil
+}
+
+// SetApprovalLevels replaces the levels of approval of purchase orders, numbering them by amount. Two levels
+// of the same amount make orders of that amount need two approvers. Without levels orders are approved as
+// soon as they are submitted.
+func (s *purchaseService) SetApprovalLevels(requests []models.PurchaseApprovalLevelRequest) ([]models.PurchaseApprovalLevel, *apiError.Error) {
+	levels := make([]models.PurchaseApprovalLevel, 0, len(requests))
+	for _, request := range requests {
+		if request.Role != models.RoleUser && request.Role != models.RoleAdmin {
+			return nil, apiError.New(fmt.Sprintf("role must be %s or %s", models.RoleUser, models.RoleAdmin), http.StatusBadRequest)
+		}
+		if request.MinAmount.IsNegative() {
+			return nil, apiError.New("min_amount cannot be negative", http.StatusBadRequest)
+		}
+		levels = append(levels, models.PurchaseApprovalLevel{MinAmount: request.MinAmount.Round(2), Role: request.Role})
+	}
+	sort.SliceStable(levels, func(i, j int) bool {
+		return levels[i].MinAmount.LessThan(levels[j].MinAmount)
+	})
+	for i := range levels {
+		levels[i].Level = i + 1
+	}
+
+	if err := s.purchaseRepo.ReplaceApprovalLevels(levels); err != nil {
+		log.Printf("SetApprovalLevels error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.ListApprovalLevels()
+}
+
+func (s *purchaseService) CreateOrder(request *models.PurchaseOrderRequest, userID uint) (*models.PurchaseOrder, *apiError.Error) {
+	order := &models.PurchaseOrder{Status: models.PurchaseOrderDraft, CreatedBy: userID}
+	if apiErr := s.applyOrderRequest(order, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.purchaseRepo.CreateOrder(order); err != nil {
+		log.Printf("CreateOrder error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetOrder(order.ID)
+}
+
+// UpdateOrder replaces a draft, submitted purchase orders have to be withdrawn or rejected first
+func (s *purchaseService) UpdateOrder(id uint, request *models.PurchaseOrderRequest) (*models.PurchaseOrder, *apiError.Error) {
+	order, apiErr := s.GetOrder(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if order.Status != models.PurchaseOrderDraft {
+		return nil, apiError.New("only draft purchase orders can be changed", http.StatusConflict)
+	}
+	if apiErr := s.applyOrderRequest(order, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.purchaseRepo.UpdateOrder(order); err != nil {
+		log.Printf("UpdateOrder error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetOrder(id)
+}
+
+// applyOrderRequest copies a request onto a purchase order and works out its lines and total
+func (s *purchaseService) applyOrderRequest(order *models.PurchaseOrder, request *models.PurchaseOrderRequest) *apiError.Error {
+	vendor, err := s.vendorRepo.FindVendor(request.VendorID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.New("vendor not found", http.StatusNotFound)
+		}
+		return apiError.ErrInternalServerError
+	}
+	if vendor.Archived {
+		return apiError.New(fmt.Sprintf("vendor %s is archived", vendor.Name), http.StatusConflict)
+	}
+	var budgetID *uint
+	if request.BudgetID != 0 {
+		if _, err := s.budgetRepo.FindBudget(request.BudgetID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apiError.New("budget not found", http.StatusNotFound)
+			}
+			return apiError.ErrInternalServerError
+		}
+		budgetID = &request.BudgetID
+	}
+
+	currency := strings.ToUpper(request.Currency)
+	if currency == "" {
+		currency = vendor.Currency
+	}
+	if currency == "" {
+		currency = s.Config.ReportingCurrency
+	}
+	if !isCurrencyCode(currency) {
+		return apiError.New("currency must be an ISO 4217 code such as NGN", http.StatusBadRequest)
+	}
+	var deliveryDate *time.Time
+	if request.DeliveryDate != "" {
+		date, apiErr := parseDate("delivery_date", request.DeliveryDate)
+		if apiErr != nil {
+			return apiErr
+		}
+		deliveryDate = &date
+	}
+
+	if len(request.Lines) == 0 {
+		return apiError.New("a purchase order needs at least one line", http.StatusBadRequest)
+	}
+	total := decimal.Zero
+	lines := make([]models.PurchaseOrderLine, 0, len(request.Lines))
+	for i, request := range request.Lines {
+		description := strings.TrimSpace(request.Description)
+		if description == "" {
+			return apiError.New("every line needs a description", http.StatusBadRequest)
+		}
+		quantity := request.Quantity
+		if quantity.IsZero() {
+			quantity = decimal.NewFromInt(1)
+		}
+		if quantity.IsNegative() {
+			return apiError.New("quantities cannot be negative", http.StatusBadRequest)
+		}
+		if request.UnitPrice.IsNegative() {
+			return apiError.New("unit prices cannot be negative", http.StatusBadRequest)
+		}
+		line := models.PurchaseOrderLine{
+			Position:    i + 1,
+			Description: description,
+			Quantity:    quantity.Round(4),
+			UnitPrice:   request.UnitPrice.Round(2),
+		}
+		line.Amount = line.Quantity.Mul(line.UnitPrice).Round(2)
+		total = total.Add(line.Amount)
+		lines = append(lines, line)
+	}
+
+	order.VendorID = vendor.ID
+	order.BudgetID = budgetID
+	order.Currency = currency
+	order.DeliveryDate = deliveryDate
+	order.DeliveryAddress = request.DeliveryAddress
+	order.Notes = request.Notes
+	order.Lines = lines
+	order.Total = total
+	return nil
+}
+
+// DeleteOrder deletes a draft that was never submitted, numbered purchase orders are closed instead so
+// that their numbers stay in sequence
+func (s *purchaseService) DeleteOrder(id uint) *apiError.Error {
+	order, apiErr := s.GetOrder(id)
+	if apiErr != nil {
+		return apiErr
+	}
+	if order.Status != models.PurchaseOrderDraft || order.Number != "" {
+		return apiError.New("only drafts never submitted can be deleted, close the purchase order instead", http.StatusConflict)
+	}
+	if err := s.purchaseRepo.DeleteOrder(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.ErrNotFound
+		}
+		log.Printf("DeleteOrder error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+func (s *purchaseService) GetOrder(id uint) (*models.PurchaseOrder, *apiError.Error) {
+	order, err := s.purchaseRepo.FindOrder(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return order, nil
+}
+
+func (s *purchaseService) ListOrders(filter *models.PurchaseOrderFilter) ([]models.PurchaseOrder, int64, *apiError.Error) {
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return nil, 0, apiError.New("unknown purchase order status", http.StatusBadRequest)
+	}
+	orders, total, err := s.purchaseRepo.ListOrders(filter)
+	if err != nil {
+		log.Printf("ListOrders error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	return orders, total, nil
+}
+
+// ListAwaitingApproval lists the submitted purchase orders whose pending level of approval a user can decide
+func (s *purchaseService) ListAwaitingApproval(userID uint, role string) ([]models.PurchaseOrder, *apiError.Error) {
+	orders, err := s.purchaseRepo.ListAwaitingApproval(userID)
+	if err != nil {
+		log.Printf("ListAwaitingApproval error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	awaiting := []models.PurchaseOrder{}
+	for _, order := range orders {
+		if pending := order.PendingApproval(); pending != nil && models.CanApprove(role, pending.Role) {
+			awaiting = append(awaiting, order)
+		}
+	}
+	return awaiting, nil
+}
+
+// SubmitOrder submits a draft for approval. Its total is converted to the reporting currency at the rate of
+// the day, and it needs the approval of every level that amount reaches.
+func (s *purchaseService) SubmitOrder(id, userID uint) (*models.PurchaseOrder, *apiError.Error) {
+	order, apiErr := s.GetOrder(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if order.Status != models.PurchaseOrderDraft {
+		return nil, apiError.New("only draft purchase orders can be submitted", http.StatusConflict)
+	}
+	if order.Vendor == nil || order.Vendor.Archived || order.Vendor.DeletedAt.Valid {
+		return nil, apiError.New("the vendor of the purchase order is archived", http.StatusConflict)
+	}
+
+	now := time.Now().UTC()
+	baseTotal, rate, apiErr := s.exchangeRateService.Convert(order.Total, order.Currency, s.Config.ReportingCurrency, now)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	levels, apiErr := s.ListApprovalLevels()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	approvals := []models.PurchaseOrderApproval{}
+	for _, level := range levels {
+		if baseTotal.GreaterThanOrEqual(level.MinAmount) {
+			approvals = append(approvals, models.PurchaseOrderApproval{Level: level.Level, Role: level.Role, Decision: models.ApprovalPending})
+		}
+	}
+
+	order.Status = models.PurchaseOrderSubmitted
+	order.ExchangeRate = rate
+	order.BaseTotal = baseTotal
+	order.SubmittedBy = &userID
+	order.SubmittedAt = &now
+	order.RejectionNote = ""
+	order.Approvals = approvals
+	if len(approvals) == 0 {
+		order.Status = models.PurchaseOrderApproved
+		order.ApprovedAt = &now
+	}
+	if err := s.purchaseRepo.SubmitOrder(order, s.Config.PurchaseOrderPrefix); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.New("the purchase order changed meanwhile, reload it", http.StatusConflict)
+		}
+		log.Printf("SubmitOrder error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetOrder(id)
+}
+
+// WithdrawOrder takes a submitted purchase order back to draft, e.g. to change it, dropping the approvals it
+// had. Only who raised or submitted it, or an admin, can.
+func (s *purchaseService) WithdrawOrder(id, userID uint, role string) (*models.PurchaseOrder, *apiError.Error) {
+	order, apiErr := s.GetOrder(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if order.Status != models.PurchaseOrderSubmitted {
+		return nil, apiError.New("only submitted purchase orders can be withdrawn", http.StatusConflict)
+	}
+	raisedBy := order.CreatedBy == userID || (order.SubmittedBy != nil && *order.SubmittedBy == userID)
+	if !raisedBy && role != models.RoleAdmin {
+		return nil, apiError.New("only who raised the purchase order or an admin can withdraw it", http.StatusForbidden)
+	}
+
+	if err := s.purchaseRepo.WithdrawOrder(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.New("the purchase order changed meanwhile, reload it", http.StatusConflict)
+		}
+		log.Printf("WithdrawOrder error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetOrder(id)
+}
+
+// DecideOrder approves or rejects the pending level of approval of a submitted purchase order. The level
+// needs a user with its role or an admin, who neither raised nor submitted the order nor decided another
+// of its levels. A rejection, which needs a note, sends the order back to draft.
+func (s *purchaseService) DecideOrder(id uint, decision models.ApprovalDecision, request *models.PurchaseDecisionRequest, userID uint, role string) (*models.PurchaseOrder, *apiError.Error) {
+	order, apiErr := s.GetOrder(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if order.Status != models.PurchaseOrderSubmitted {
+		return nil, apiError.New("only submitted purchase orders can be approved or rejected", http.StatusConflict)
+	}
+	if order.CreatedBy == userID || (order.SubmittedBy != nil && *order.SubmittedBy == userID) {
+		return nil, apiError.New("you cannot approve or reject a purchase order you raised or submitted", http.StatusForbidden)
+	}
+	for _, approval := range order.Approvals {
+		if approval.DecidedBy != nil && *approval.DecidedBy == userID {
+			return nil, apiError.New(fmt.Sprintf("you decided level %d of this purchase order already", approval.Level), http.StatusForbidden)
+		}
+	}
+	pending := order.PendingApproval()
+	if pending == nil {
+		return nil, apiError.New("the purchase order has no approval pending", http.StatusConflict)
+	}
+	if !models.CanApprove(role, pending.Role) {
+		return nil, apiError.New(fmt.Sprintf("level %d of approval needs a user with the %s role", pending.Level, pending.Role), http.StatusForbidden)
+	}
+	note := strings.TrimSpace(request.Note)
+	if decision == models.ApprovalRejected && note == "" {
+		return nil, apiError.New("a note is needed to reject a purchase order", http.StatusBadRequest)
+	}
+
+	err := s.purchaseRepo.DecideApproval(id, pending.Level, decision, userID, note, time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.New("the purchase order was decided on meanwhile, reload it", http.StatusConflict)
+		}
+		log.Printf("DecideOrder error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetOrder(id)
+}
+
+// PlaceOrder marks an approved purchase order as ordered from the vendor, goods are received against it from then
+func (s *purchaseService) PlaceOrder(id uint) (*models.PurchaseOrder, *apiError.Error) {
+	order, apiErr := s.GetOrder(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if order.Status != models.PurchaseOrderApproved {
+		return nil, apiError.New("only approved purchase orders can be ordered", http.StatusConflict)
+	}
+
+	if err := s.purchaseRepo.PlaceOrder(id, time.Now().UTC()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.New("the purchase order changed meanwhile, reload it", http.StatusConflict)
+		}
+		log.Printf("PlaceOrder error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetOrder(id)
+}
+
+// CloseOrder closes a purchase order short, e.g. when it is cancelled or the rest will never be delivered.
+// Drafts never submitted are deleted instead.
+func (s *purchaseService) CloseOrder(id uint, request *models.PurchaseOrderCloseRequest, userID uint) (*models.PurchaseOrder, *apiError.Error) {
+	order, apiErr := s.GetOrder(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	switch {
+	case order.Status == models.PurchaseOrderClosed:
+		return nil, apiError.New("the purchase order is closed already", http.StatusConflict)
+	case order.Number == "":
+		return nil, apiError.New("drafts never submitted are deleted rather than closed", http.StatusConflict)
+	}
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" {
+		return nil, apiError.New("reason is required", http.StatusBadRequest)
+	}
+
+	if err := s.purchaseRepo.CloseOrder(id, order.Status, userID, reason, time.Now().UTC()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.New("the purchase order changed meanwhile, reload it", http.StatusConflict)
+		}
+		log.Printf("CloseOrder error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetOrder(id)
+}
+
+// ReceiveGoods records a goods received note against an ordered purchase order, on the day it is made unless
+// a date is given. A note can receive part of a line, but no more than is left to receive on it.
+func (s *purchaseService) ReceiveGoods(id uint, request *models.GoodsReceiptRequest, userID uint) (*models.GoodsReceipt, *apiError.Error) {
+	order, apiErr := s.GetOrder(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if order.Status != models.PurchaseOrderOrdered {
+		return nil, apiError.New("goods can only be received on ordered purchase orders", http.StatusConflict)
+	}
+	today, _ := time.Parse(models.DateLayout, time.Now().UTC().Format(models.DateLayout))
+	receivedOn := today
+	if request.ReceivedOn != "" {
+		if receivedOn, apiErr = parseDate("received_on", request.ReceivedOn); apiErr != nil {
+			return nil, apiErr
+		}
+		if receivedOn.After(today) {
+			return nil, apiError.New("received_on cannot be in the future", http.StatusBadRequest)
+		}
+	}
+
+	lines := map[uint]models.PurchaseOrderLine{}
+	for _, line := range order.Lines {
+		lines[line.ID] = line
+	}
+	received := map[uint]decimal.Decimal{}
+	receipt := &models.GoodsReceipt{
+		PurchaseOrderID: id,
+		ReceivedOn:      receivedOn,
+		DeliveryNote:    request.DeliveryNote,
+		Notes:           request.Notes,
+		ReceivedBy:      userID,
+	}
+	for _, request := range request.Lines {
+		line, ok := lines[request.PurchaseOrderLineID]
+		if !ok {
+			return nil, apiError.New(fmt.Sprintf("line %d is not on the purchase order", request.PurchaseOrderLineID), http.StatusBadRequest)
+		}
+		if !request.Quantity.IsPositive() {
+			return nil, apiError.New("quantities received must be positive", http.StatusBadRequest)
+		}
+		received[line.ID] = received[line.ID].Add(request.Quantity)
+		if received[line.ID].GreaterThan(line.Outstanding()) {
+			return nil, apiError.New(fmt.Sprintf("only %s of %s is left to receive", line.Outstanding(), line.Description), http.StatusConflict)
+		}
+		receipt.Lines = append(receipt.Lines, models.GoodsReceiptLine{PurchaseOrderLineID: line.ID, Quantity: request.Quantity.Round(4)})
+	}
+
+	if _, err := s.purchaseRepo.ReceiveGoods(receipt); err != nil {
+		log.Printf("ReceiveGoods error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	if receipt.ID == 0 {
+		return nil, apiError.New("the purchase order changed meanwhile, reload it", http.StatusConflict)
+	}
+	return receipt, nil
+}
+
+func (s *purchaseService) ListReceipts(id uint) ([]models.GoodsReceipt, *apiError.Error) {
+	if _, apiErr := s.GetOrder(id); apiErr != nil {
+		return nil, apiErr
+	}
+	receipts, err := s.purchaseRepo.ListReceipts(id)
+	if err != nil {
+		log.Printf("ListReceipts error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return receipts, nil
+}
+
+// RecordBill records a bill of the vendor of an ordered or received purchase order, in the currency of the
+// order, and matches it against the order and what was received on it. A bill that does not match is held
+// as an exception until an admin approves or rejects it.
+func (s *purchaseService) RecordBill(id uint, request *models.VendorBillRequest, userID uint) (*models.VendorBill, *apiError.Error) {
+	order, apiErr := s.GetOrder(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if order.Status != models.PurchaseOrderOrdered && order.Status != models.PurchaseOrderReceived {
+		return nil, apiError.New("bills can only be recorded on ordered or received purchase orders", http.StatusConflict)
+	}
+	number := strings.TrimSpace(request.Number)
+	if number == "" {
+		return nil, apiError.New("number is required", http.StatusBadRequest)
+	}
+	existing, err := s.purchaseRepo.FindBillByNumber(order.VendorID, number)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apiError.ErrInternalServerError
+	}
+	if err == nil {
+		return nil, apiError.New(fmt.Sprintf("bill %s of the vendor was recorded already, on purchase order %d", number, existing.PurchaseOrderID), http.StatusConflict)
+	}
+	billDate, apiErr := parseDate("bill_date", request.BillDate)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	dueDate := billDate
+	if order.Vendor != nil {
+		dueDate = billDate.AddDate(0, 0, order.Vendor.PaymentDays)
+	}
+	if request.DueDate != "" {
+		if dueDate, apiErr = parseDate("due_date", request.DueDate); apiErr != nil {
+			return nil, apiErr
+		}
+		if dueDate.Before(billDate) {
+			return nil, apiError.New("due_date cannot be before bill_date", http.StatusBadRequest)
+		}
+	}
+	if request.TaxTotal.IsNegative() {
+		return nil, apiError.New("tax_total cannot be negative", http.StatusBadRequest)
+	}
+
+	lines := map[uint]bool{}
+	for _, line := range order.Lines {
+		lines[line.ID] = true
+	}
+	bill := &models.VendorBill{
+		PurchaseOrderID: id,
+		VendorID:        order.VendorID,
+		Number:          number,
+		BillDate:        billDate,
+		DueDate:         &dueDate,
+		Currency:        order.Currency,
+		Subtotal:        decimal.Zero,
+		TaxTotal:        request.TaxTotal.Round(2),
+		CreatedBy:       userID,
+	}
+	for _, request := range request.Lines {
+		if !lines[request.PurchaseOrderLineID] {
+			return nil, apiError.New(fmt.Sprintf("line %d is not on the purchase order", request.PurchaseOrderLineID), http.StatusBadRequest)
+		}
+		if !request.Quantity.IsPositive() {
+			return nil, apiError.New("quantities billed must be positive", http.StatusBadRequest)
+		}
+		if request.UnitPrice.IsNegative() {
+			return nil, apiError.New("unit prices cannot be negative", http.StatusBadRequest)
+		}
+		line := models.VendorBillLine{
+			PurchaseOrderLineID: request.PurchaseOrderLineID,
+			Quantity:            request.Quantity.Round(4),
+			UnitPrice:           request.UnitPrice.Round(2),
+		}
+		line.Amount = line.Quantity.Mul(line.UnitPrice).Round(2)
+		bill.Subtotal = bill.Subtotal.Add(line.Amount)
+		bill.Lines = append(bill.Lines, line)
+	}
+	bill.Total = bill.Subtotal.Add(bill.TaxTotal)
+
+	tolerance := models.MatchTolerance{
+		Price:    decimal.NewFromInt(int64(s.Config.PurchasePriceTolerance)),
+		Quantity: decimal.NewFromInt(int64(s.Config.PurchaseQuantityTolerance)),
+	}
+	if _, err := s.purchaseRepo.CreateBill(bill, tolerance); err != nil {
+		log.Printf("RecordBill error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	if bill.ID == 0 {
+		return nil, apiError.New("the purchase order changed meanwhile, reload it", http.StatusConflict)
+	}
+	return bill, nil
+}
+
+func (s *purchaseService) GetBill(id uint) (*models.VendorBill, *apiError.Error) {
+	bill, err := s.purchaseRepo.FindBill(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return bill, nil
+}
+
+func (s *purchaseService) ListBills(id uint) ([]models.VendorBill, *apiError.Error) {
+	if _, apiErr := s.GetOrder(id); apiErr != nil {
+		return nil, apiErr
+	}
+	bills, err := s.purchaseRepo.ListBills(id)
+	if err != nil {
+		log.Printf("ListBills error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return bills, nil
+}
+
+// ReviewBill approves or rejects a bill held as an exception, a rejection needs a note. The vendor can send
+// a rejected bill again under the same number.
+func (s *purchaseService) ReviewBill(id uint, status models.VendorBillStatus, request *models.VendorBillReviewRequest, userID uint) (*models.VendorBill, *apiError.Error) {
+	bill, apiErr := s.GetBill(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if bill.Status != models.BillException {
+		return nil, apiError.New("only bills held as exceptions can be approved or rejected", http.StatusConflict)
+	}
+	note := strings.TrimSpace(request.Note)
+	if status == models.BillRejected && note == "" {
+		return nil, apiError.New("a note is needed to reject a bill", http.StatusBadRequest)
+	}
+
+	if err := s.purchaseRepo.ReviewBill(id, status, userID, note, time.Now().UTC()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.New("the bill was reviewed meanwhile, reload it", http.StatusConflict)
+		}
+		log.Printf("ReviewBill error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetBill(id)
+}
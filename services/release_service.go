@@ -0,0 +1,601 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/ical"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+// feedPast and feedAhead bound the events of a calendar feed around today
+const (
+	feedPast  = 90 * 24 * time.Hour
+	feedAhead = 365 * 24 * time.Hour
+)
+
+// ReleaseService schedules title releases and festival submissions, and lays them out as a calendar
+type ReleaseService interface {
+	CreatePlan(titleType models.TitleType, titleID uint, request *models.ReleasePlanRequest, userID uint) (*models.ReleasePlan, *apiError.Error)
+	UpdatePlan(id uint, request *models.ReleasePlanRequest) (*models.ReleasePlan, *apiError.Error)
+	DeletePlan(id uint) *apiError.Error
+	GetPlan(id uint) (*models.ReleasePlan, *apiError.Error)
+	ListTitlePlans(titleType models.TitleType, titleID uint) ([]models.ReleasePlan, *apiError.Error)
+	CreateFestival(request *models.FestivalRequest) (*models.Festival, *apiError.Error)
+	UpdateFestival(id uint, request *models.FestivalRequest) (*models.Festival, *apiError.Error)
+	GetFestival(id uint) (*models.Festival, *apiError.Error)
+	ListFestivals(query string, edition, page, limit int) ([]models.Festival, int64, *apiError.Error)
+	CreateSubmission(request *models.FestivalSubmissionRequest, userID uint) (*models.FestivalSubmission, *apiError.Error)
+	ChangeSubmissionStatus(id uint, request *models.SubmissionStatusRequest) (*models.FestivalSubmission, *apiError.Error)
+	GetSubmission(id uint) (*models.FestivalSubmission, *apiError.Error)
+	ListSubmissions(filter *models.SubmissionFilter) ([]models.FestivalSubmission, int64, *apiError.Error)
+	Calendar(filter *models.CalendarFilter) ([]models.CalendarEvent, *apiError.Error)
+	CreateFeed(userID uint, request *models.CalendarFeedRequest) (*models.CalendarFeed, *apiError.Error)
+	ListFeeds(userID uint) ([]models.CalendarFeed, *apiError.Error)
+	DeleteFeed(id, userID uint) *apiError.Error
+	RenderFeed(token string) (*models.CalendarFeed, []byte, *apiError.Error)
+}
+
+type releaseService struct {
+	releaseRepo db.ReleaseRepository
+	movieRepo   db.MovieRepository
+	authRepo    db.AuthRepository
+}
+
+// NewReleaseService instantiate a releaseService
+func NewReleaseService(releaseRepo db.ReleaseRepository, movieRepo db.MovieRepository, authRepo db.AuthRepository) ReleaseService {
+	return &releaseService{
+		releaseRepo: releaseRepo,
+		movieRepo:   movieRepo,
+		authRepo:    authRepo,
+	}
+}
+
+// team normalises the name of a team, teams are free text matched regardless of case
+func team(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// checkOwner returns who owns an item, the user creating it unless someone else is named
+func checkOwner(authRepo db.AuthRepository, ownerID, userID uint) (uint, *apiError.Error) {
+	if ownerID == 0 || ownerID == userID {
+		return userID, nil
+	}
+	if _, err := authRepo.FindUserByID(ownerID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, apiError.New("owner not found", http.StatusNotFound)
+		}
+		return 0, apiError.ErrInternalServerError
+	}
+	return ownerID, nil
+}
+
+func (s *releaseService) CreatePlan(titleType models.TitleType, titleID uint, request *models.ReleasePlanRequest, userID uint) (*models.ReleasePlan, *apiError.Error) {
+	if apiErr := checkTitle(s.movieRepo, titleType, titleID); apiErr != nil {
+		return nil, apiErr
+	}
+	plan := &models.ReleasePlan{TitleType: titleType, TitleID: titleID, OwnerID: userID}
+	if apiErr := s.applyPlanRequest(plan, request, userID); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.releaseRepo.CreatePlan(plan); err != nil {
+		log.Printf("CreatePlan error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetPlan(plan.ID)
+}
+
+// UpdatePlan replaces a release plan, milestones included
+func (s *releaseService) UpdatePlan(id uint, request *models.ReleasePlanRequest) (*models.ReleasePlan, *apiError.Error) {
+	plan, apiErr := s.GetPlan(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := s.applyPlanRequest(plan, request, plan.OwnerID); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.releaseRepo.UpdatePlan(plan); err != nil {
+		log.Printf("UpdatePlan error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetPlan(id)
+}
+
+// applyPlanRequest checks a request and copies it onto a plan, userID owns the plan unless the request names an owner
+func (s *releaseService) applyPlanRequest(plan *models.ReleasePlan, request *models.ReleasePlanRequest, userID uint) *apiError.Error {
+	territory := strings.ToUpper(strings.TrimSpace(request.Territory))
+	if territory != "" && !models.IsCountryCode(territory) {
+		return apiError.New("territory must be an ISO 3166 country code", http.StatusBadRequest)
+	}
+	ownerID, apiErr := checkOwner(s.authRepo, request.OwnerID, userID)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	milestones := make([]models.ReleaseMilestone, 0, len(request.Milestones))
+	for _, milestone := range request.Milestones {
+		if !milestone.Kind.IsValid() {
+			return apiError.New("milestone kind must be trailer_drop, premiere, theatrical, streaming or other", http.StatusBadRequest)
+		}
+		date, apiErr := parseDate("date", milestone.Date)
+		if apiErr != nil {
+			return apiErr
+		}
+		milestones = append(milestones, models.ReleaseMilestone{
+			PlanID: plan.ID,
+			Kind:   milestone.Kind,
+			Date:   date,
+			Label:  strings.TrimSpace(milestone.Label),
+			Notes:  milestone.Notes,
+			Done:   milestone.Done,
+		})
+	}
+
+	plan.Name = strings.TrimSpace(request.Name)
+	plan.Territory = territory
+	plan.Team = team(request.Team)
+	plan.OwnerID = ownerID
+	plan.Notes = request.Notes
+	plan.Milestones = milestones
+	return nil
+}
+
+func (s *releaseService) DeletePlan(id uint) *apiError.Error {
+	if err := s.releaseRepo.DeletePlan(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.ErrNotFound
+		}
+		log.Printf("DeletePlan error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+func (s *releaseService) GetPlan(id uint) (*models.ReleasePlan, *apiError.Error) {
+	plan, err := s.releaseRepo.FindPlan(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return plan, nil
+}
+
+func (s *releaseService) ListTitlePlans(titleType models.TitleType, titleID uint) ([]models.ReleasePlan, *apiError.Error) {
+	if apiErr := checkTitle(s.movieRepo, titleType, titleID); apiErr != nil {
+		return nil, apiErr
+	}
+	plans, err := s.releaseRepo.FindTitlePlans(titleType, titleID)
+	if err != nil {
+		log.Printf("ListTitlePlans error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return plans, nil
+}
+
+func (s *releaseService) CreateFestival(request *models.FestivalRequest) (*models.Festival, *apiError.Error) {
+	festival := &models.Festival{}
+	if apiErr := s.applyFestivalRequest(festival, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.releaseRepo.CreateFestival(festival); err != nil {
+		log.Printf("CreateFestival error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return festival, nil
+}
+
+func (s *releaseService) UpdateFestival(id uint, request *models.FestivalRequest) (*models.Festival, *apiError.Error) {
+	festival, apiErr := s.GetFestival(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := s.applyFestivalRequest(festival, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.releaseRepo.UpdateFestival(festival); err != nil {
+		log.Printf("UpdateFestival error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return festival, nil
+}
+
+// applyFestivalRequest checks a request and copies it onto a festival, an edition of a festival is recorded once
+func (s *releaseService) applyFestivalRequest(festival *models.Festival, request *models.FestivalRequest) *apiError.Error {
+	name := strings.TrimSpace(request.Name)
+	existing, err := s.releaseRepo.FindFestivalEdition(name, request.Edition)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return apiError.ErrInternalServerError
+	}
+	if err == nil && existing.ID != festival.ID {
+		return apiError.New(fmt.Sprintf("the %d edition of %s already exists", existing.Edition, existing.Name), http.StatusConflict)
+	}
+
+	country := strings.ToUpper(strings.TrimSpace(request.Country))
+	if country != "" && !models.IsCountryCode(country) {
+		return apiError.New("country must be an ISO 3166 country code", http.StatusBadRequest)
+	}
+	currency := strings.ToUpper(request.Currency)
+	if currency != "" && !isCurrencyCode(currency) {
+		return apiError.New("currency must be an ISO 4217 code such as NGN", http.StatusBadRequest)
+	}
+	if request.Fee.IsNegative() || request.LateFee.IsNegative() {
+		return apiError.New("fees cannot be negative", http.StatusBadRequest)
+	}
+	if currency == "" && (request.Fee.IsPositive() || request.LateFee.IsPositive()) {
+		return apiError.New("currency is required with fees", http.StatusBadRequest)
+	}
+
+	startsOn, apiErr := parseDate("starts_on", request.StartsOn)
+	if apiErr != nil {
+		return apiErr
+	}
+	endsOn, apiErr := parseDate("ends_on", request.EndsOn)
+	if apiErr != nil {
+		return apiErr
+	}
+	if endsOn.Before(startsOn) {
+		return apiError.New("ends_on cannot be before starts_on", http.StatusBadRequest)
+	}
+	deadline, apiErr := parseDate("submission_deadline", request.SubmissionDeadline)
+	if apiErr != nil {
+		return apiErr
+	}
+	var lateDeadline *time.Time
+	if request.LateDeadline != "" {
+		date, apiErr := parseDate("late_deadline", request.LateDeadline)
+		if apiErr != nil {
+			return apiErr
+		}
+		if date.Before(deadline) {
+			return apiError.New("late_deadline cannot be before submission_deadline", http.StatusBadRequest)
+		}
+		lateDeadline = &date
+	}
+
+	festival.Name = name
+	festival.Edition = request.Edition
+	festival.City = strings.TrimSpace(request.City)
+	festival.Country = country
+	festival.Website = strings.TrimSpace(request.Website)
+	festival.StartsOn = startsOn
+	festival.EndsOn = endsOn
+	festival.SubmissionDeadline = deadline
+	festival.LateDeadline = lateDeadline
+	festival.Currency = currency
+	festival.Fee = request.Fee.Round(2)
+	festival.LateFee = request.LateFee.Round(2)
+	return nil
+}
+
+func (s *releaseService) GetFestival(id uint) (*models.Festival, *apiError.Error) {
+	festival, err := s.releaseRepo.FindFestival(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return festival, nil
+}
+
+func (s *releaseService) ListFestivals(query string, edition, page, limit int) ([]models.Festival, int64, *apiError.Error) {
+	festivals, total, err := s.releaseRepo.ListFestivals(query, edition, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("ListFestivals error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	return festivals, total, nil
+}
+
+// CreateSubmission plans the entry of a title in a festival, once per category
+func (s *releaseService) CreateSubmission(request *models.FestivalSubmissionRequest, userID uint) (*models.FestivalSubmission, *apiError.Error) {
+	if _, apiErr := s.GetFestival(request.FestivalID); apiErr != nil {
+		if apiErr == apiError.ErrNotFound {
+			return nil, apiError.New("festival not found", http.StatusNotFound)
+		}
+		return nil, apiErr
+	}
+	if apiErr := checkTitle(s.movieRepo, request.TitleType, request.TitleID); apiErr != nil {
+		return nil, apiErr
+	}
+	category := strings.TrimSpace(request.Category)
+	if _, err := s.releaseRepo.FindSubmissionEntry(request.FestivalID, request.TitleType, request.TitleID, category); err == nil {
+		return nil, apiError.New("the title is already entered in this category of the festival", http.StatusConflict)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apiError.ErrInternalServerError
+	}
+	ownerID, apiErr := checkOwner(s.authRepo, request.OwnerID, userID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	submission := &models.FestivalSubmission{
+		FestivalID: request.FestivalID,
+		TitleType:  request.TitleType,
+		TitleID:    request.TitleID,
+		Category:   category,
+		Status:     models.SubmissionPlanned,
+		Team:       team(request.Team),
+		OwnerID:    ownerID,
+		Notes:      request.Notes,
+	}
+	if err := s.releaseRepo.CreateSubmission(submission); err != nil {
+		log.Printf("CreateSubmission error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetSubmission(submission.ID)
+}
+
+// ChangeSubmissionStatus moves a submission along, see SubmissionStatus.CanBecome. Sending it records the day
+// and the fee paid, accepting it records the outcome.
+func (s *releaseService) ChangeSubmissionStatus(id uint, request *models.SubmissionStatusRequest) (*models.FestivalSubmission, *apiError.Error) {
+	submission, apiErr := s.GetSubmission(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if !request.Status.IsValid() {
+		return nil, apiError.New("status must be planned, submitted, accepted, rejected or withdrawn", http.StatusBadRequest)
+	}
+	if !submission.Status.CanBecome(request.Status) {
+		return nil, apiError.New(fmt.Sprintf("a %s submission cannot become %s", submission.Status, request.Status), http.StatusConflict)
+	}
+
+	switch request.Status {
+	case models.SubmissionSubmitted:
+		submittedOn := time.Now().UTC().Truncate(24 * time.Hour)
+		if request.SubmittedOn != "" {
+			date, apiErr := parseDate("submitted_on", request.SubmittedOn)
+			if apiErr != nil {
+				return nil, apiErr
+			}
+			submittedOn = date
+		}
+		if request.FeePaid.IsNegative() {
+			return nil, apiError.New("fee_paid cannot be negative", http.StatusBadRequest)
+		}
+		submission.SubmittedOn = &submittedOn
+		submission.FeePaid = request.FeePaid.Round(2)
+	case models.SubmissionAccepted:
+		outcome := request.Outcome
+		if outcome == "" {
+			outcome = models.OutcomeSelected
+		}
+		if !outcome.IsValid() {
+			return nil, apiError.New("outcome must be selected, nominated or awarded", http.StatusBadRequest)
+		}
+		if outcome == models.OutcomeAwarded && strings.TrimSpace(request.Award) == "" {
+			return nil, apiError.New("award is required when the title was awarded", http.StatusBadRequest)
+		}
+		submission.Outcome = outcome
+		submission.Award = strings.TrimSpace(request.Award)
+	}
+	submission.Status = request.Status
+
+	if err := s.releaseRepo.UpdateSubmission(submission); err != nil {
+		log.Printf("ChangeSubmissionStatus error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return submission, nil
+}
+
+func (s *releaseService) GetSubmission(id uint) (*models.FestivalSubmission, *apiError.Error) {
+	submission, err := s.releaseRepo.FindSubmission(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return submission, nil
+}
+
+func (s *releaseService) ListSubmissions(filter *models.SubmissionFilter) ([]models.FestivalSubmission, int64, *apiError.Error) {
+	submissions, total, err := s.releaseRepo.ListSubmissions(filter)
+	if err != nil {
+		log.Printf("ListSubmissions error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	return submissions, total, nil
+}
+
+// Calendar lists the release milestones, festival deadlines of planned submissions and festival days of
+// accepted ones within the range of the filter, in date order
+func (s *releaseService) Calendar(filter *models.CalendarFilter) ([]models.CalendarEvent, *apiError.Error) {
+	if filter.To.Before(filter.From) {
+		return nil, apiError.New("to cannot be before from", http.StatusBadRequest)
+	}
+	milestones, err := s.releaseRepo.FindCalendarMilestones(filter)
+	if err != nil {
+		log.Printf("Calendar error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	submissions, err := s.releaseRepo.FindCalendarSubmissions(filter)
+	if err != nil {
+		log.Printf("Calendar error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+
+	titles := map[models.ContractTitle]string{}
+	titleName := func(titleType models.TitleType, titleID uint) string {
+		key := models.ContractTitle{TitleType: titleType, TitleID: titleID}
+		name, ok := titles[key]
+		if !ok {
+			name = fmt.Sprintf("%s %d", titleType, titleID)
+			if title, err := s.movieRepo.FindTitle(titleType, titleID); err == nil {
+				name = title.Title
+			}
+			titles[key] = name
+		}
+		return name
+	}
+
+	events := []models.CalendarEvent{}
+	for _, milestone := range milestones {
+		summary := milestone.Kind.Label()
+		if milestone.Label != "" {
+			summary = milestone.Label
+		}
+		events = append(events, models.CalendarEvent{
+			UID:       fmt.Sprintf("milestone-%d", milestone.ID),
+			Kind:      models.EventMilestone,
+			SourceID:  milestone.ID,
+			Date:      milestone.Date,
+			EndDate:   milestone.Date,
+			Summary:   fmt.Sprintf("%s: %s", summary, titleName(milestone.Plan.TitleType, milestone.Plan.TitleID)),
+			Notes:     milestone.Notes,
+			TitleType: milestone.Plan.TitleType,
+			TitleID:   milestone.Plan.TitleID,
+			Team:      milestone.Plan.Team,
+			OwnerID:   milestone.Plan.OwnerID,
+		})
+	}
+	for _, submission := range submissions {
+		festival := submission.Festival
+		event := models.CalendarEvent{
+			SourceID:  submission.ID,
+			Notes:     submission.Notes,
+			TitleType: submission.TitleType,
+			TitleID:   submission.TitleID,
+			Team:      submission.Team,
+			OwnerID:   submission.OwnerID,
+		}
+		name := titleName(submission.TitleType, submission.TitleID)
+		if submission.Status == models.SubmissionAccepted {
+			event.UID = fmt.Sprintf("festival-%d", submission.ID)
+			event.Kind = models.EventFestival
+			event.Date = festival.StartsOn
+			event.EndDate = festival.EndsOn
+			event.Summary = fmt.Sprintf("%s %d: %s", festival.Name, festival.Edition, name)
+			events = append(events, event)
+			continue
+		}
+
+		event.Kind = models.EventFestivalDeadline
+		deadlines := []time.Time{festival.SubmissionDeadline}
+		if festival.LateDeadline != nil {
+			deadlines = append(deadlines, *festival.LateDeadline)
+		}
+		for i, deadline := range deadlines {
+			if deadline.Before(filter.From) || deadline.After(filter.To) {
+				continue
+			}
+			event.UID = fmt.Sprintf("deadline-%d", submission.ID)
+			label := "Submission deadline"
+			if i > 0 {
+				event.UID = fmt.Sprintf("late-deadline-%d", submission.ID)
+				label = "Late submission deadline"
+			}
+			event.Date = deadline
+			event.EndDate = deadline
+			event.Summary = fmt.Sprintf("%s, %s %d: %s", label, festival.Name, festival.Edition, name)
+			events = append(events, event)
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Date.Before(events[j].Date)
+	})
+	return events, nil
+}
+
+// CreateFeed opens a calendar feed of the user's own items, or of a team's items when one is named
+func (s *releaseService) CreateFeed(userID uint, request *models.CalendarFeedRequest) (*models.CalendarFeed, *apiError.Error) {
+	token := make([]byte, 24)
+	if _, err := rand.Read(token); err != nil {
+		log.Printf("CreateFeed error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	feed := &models.CalendarFeed{
+		Token:  hex.EncodeToString(token),
+		UserID: userID,
+		Name:   strings.TrimSpace(request.Name),
+		Team:   team(request.Team),
+	}
+	if feed.Name == "" {
+		feed.Name = "My releases"
+		if feed.Team != "" {
+			feed.Name = "Releases of the " + feed.Team + " team"
+		}
+	}
+	if err := s.releaseRepo.CreateFeed(feed); err != nil {
+		log.Printf("CreateFeed error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return feed, nil
+}
+
+func (s *releaseService) ListFeeds(userID uint) ([]models.CalendarFeed, *apiError.Error) {
+	feeds, err := s.releaseRepo.FindUserFeeds(userID)
+	if err != nil {
+		log.Printf("ListFeeds error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return feeds, nil
+}
+
+func (s *releaseService) DeleteFeed(id, userID uint) *apiError.Error {
+	if err := s.releaseRepo.DeleteFeed(id, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.ErrNotFound
+		}
+		log.Printf("DeleteFeed error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+// RenderFeed writes the iCalendar document of a feed, with the events from three months back to a year ahead
+func (s *releaseService) RenderFeed(token string) (*models.CalendarFeed, []byte, *apiError.Error) {
+	feed, err := s.releaseRepo.FindFeedByToken(token)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, apiError.ErrNotFound
+		}
+		return nil, nil, apiError.ErrInternalServerError
+	}
+
+	now := time.Now().UTC()
+	filter := &models.CalendarFilter{From: now.Add(-feedPast), To: now.Add(feedAhead), Team: feed.Team}
+	if feed.Team == "" {
+		filter.OwnerID = feed.UserID
+	}
+	events, apiErr := s.Calendar(filter)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+
+	calendar := &ical.Calendar{
+		Name:    feed.Name,
+		ProdID:  "-//Telair//Telair ERP//EN",
+		Updated: now,
+	}
+	for _, event := range events {
+		calendar.Events = append(calendar.Events, ical.Event{
+			UID:         event.UID + "@telair-erp",
+			Summary:     event.Summary,
+			Description: event.Notes,
+			Start:       event.Date,
+			End:         event.EndDate,
+			Categories:  []string{string(event.Kind)},
+		})
+	}
+	var buf bytes.Buffer
+	if err := calendar.Write(&buf); err != nil {
+		log.Printf("RenderFeed error: %v", err)
+		return nil, nil, apiError.ErrInternalServerError
+	}
+	return feed, buf.Bytes(), nil
+}
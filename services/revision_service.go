@@ -0,0 +1,262 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+// RevisionService edits title metadata, keeping every version so that it can be compared and reverted to
+type RevisionService interface {
+	GetTitle(titleType models.TitleType, titleID uint) (*models.TitleRecord, *apiError.Error)
+	UpdateTitle(titleType models.TitleType, titleID, userID uint, version int, request *models.TitleUpdateRequest) (*models.Revision, int, *apiError.Error)
+	ListRevisions(titleType models.TitleType, titleID uint) ([]models.Revision, *apiError.Error)
+	Diff(titleType models.TitleType, titleID uint, from, to int) ([]models.FieldChange, *apiError.Error)
+	Revert(titleType models.TitleType, titleID uint, number int, userID uint, version int) (*models.Revision, int, *apiError.Error)
+	Approve(titleType models.TitleType, titleID uint, number int, reviewerID uint) (*models.Revision, *apiError.Error)
+	Reject(titleType models.TitleType, titleID uint, number int, reviewerID uint) (*models.Revision, *apiError.Error)
+}
+
+type revisionService struct {
+	revisionRepo db.RevisionRepository
+}
+
+// NewRevisionService instantiate a revisionService
+func NewRevisionService(revisionRepo db.RevisionRepository) RevisionService {
+	return &revisionService{
+		revisionRepo: revisionRepo,
+	}
+}
+
+// GetTitle returns the editable view of a title, its version is the ETag edits have to match
+func (r *revisionService) GetTitle(titleType models.TitleType, titleID uint) (*models.TitleRecord, *apiError.Error) {
+	if !titleType.IsValid() {
+		return nil, apiError.New("title type must be trailer or full_length", http.StatusBadRequest)
+	}
+	record, err := r.revisionRepo.FindTitleRecord(titleType, titleID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.New("title not found", http.StatusNotFound)
+		}
+		log.Printf("GetTitle error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return record, nil
+}
+
+// UpdateTitle applies an edit made on top of the given version and records it as a revision, it returns
+// the version of the title after the edit. Nothing is recorded, and no revision returned, when the edit
+// does not change anything.
+func (r *revisionService) UpdateTitle(titleType models.TitleType, titleID, userID uint, version int, request *models.TitleUpdateRequest) (*models.Revision, int, *apiError.Error) {
+	record, apiErr := r.GetTitle(titleType, titleID)
+	if apiErr != nil {
+		return nil, 0, apiErr
+	}
+	if version == models.AnyVersion {
+		version = record.Version
+	}
+	if record.Version != version {
+		return nil, 0, staleVersion(titleType, titleID, version)
+	}
+	updated, apiErr := applyTitleUpdate(record.TitleMetadata, titleType, request)
+	if apiErr != nil {
+		return nil, 0, apiErr
+	}
+	if len(models.DiffMetadata(record.TitleMetadata, updated)) == 0 {
+		return nil, version, nil
+	}
+
+	revision := &models.Revision{
+		TitleType: titleType,
+		TitleID:   titleID,
+		AuthorID:  userID,
+		Metadata:  updated,
+		Status:    models.RevisionApplied,
+	}
+	return r.createRevision(revision, version)
+}
+
+// staleVersion is the answer to writes based on a version of a title that is not the current one
+func staleVersion(titleType models.TitleType, titleID uint, version int) *apiError.Error {
+	conflict := &apiError.ConflictError{Resource: string(titleType), ID: titleID, Version: version}
+	return apiError.New(conflict.Error(), http.StatusPreconditionFailed)
+}
+
+// applyTitleUpdate returns the metadata with the fields of the request applied, once they are checked
+func applyTitleUpdate(metadata models.TitleMetadata, titleType models.TitleType, request *models.TitleUpdateRequest) (models.TitleMetadata, *apiError.Error) {
+	if titleType != models.TitleTrailer && (request.LogLine != nil || request.ProductYear != nil) {
+		return metadata, apiError.New("only trailers have a log line and a production year", http.StatusBadRequest)
+	}
+	if request.Title != nil {
+		if strings.TrimSpace(*request.Title) == "" {
+			return metadata, apiError.New("title cannot be empty", http.StatusBadRequest)
+		}
+		metadata.Title = strings.TrimSpace(*request.Title)
+	}
+	if request.LogLine != nil {
+		metadata.LogLine = *request.LogLine
+	}
+	if request.Description != nil {
+		metadata.Description = *request.Description
+	}
+	if request.Duration != nil {
+		if *request.Duration < 0 {
+			return metadata, apiError.New("duration must be a whole number of minutes", http.StatusBadRequest)
+		}
+		metadata.Duration = *request.Duration
+	}
+	if request.ProductYear != nil {
+		if year := *request.ProductYear; year != "" {
+			if _, err := strconv.Atoi(year); err != nil || len(year) != 4 {
+				return metadata, apiError.New("product year must be a four digit year", http.StatusBadRequest)
+			}
+		}
+		metadata.ProductYear = *request.ProductYear
+	}
+	return metadata, nil
+}
+
+// createRevision records a revision made on top of the given version of its title and returns the version
+// of the title then, which moves on only when the revision is applied
+func (r *revisionService) createRevision(revision *models.Revision, version int) (*models.Revision, int, *apiError.Error) {
+	if err := r.revisionRepo.CreateRevision(revision, version); err != nil {
+		var conflict *apiError.ConflictError
+		if errors.As(err, &conflict) {
+			return nil, 0, apiError.New(conflict.Error(), http.StatusPreconditionFailed)
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, apiError.New("title not found", http.StatusNotFound)
+		}
+		log.Printf("CreateRevision error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	if revision.Status == models.RevisionApplied {
+		version++
+	}
+	return revision, version, nil
+}
+
+func (r *revisionService) ListRevisions(titleType models.TitleType, titleID uint) ([]models.Revision, *apiError.Error) {
+	if _, apiErr := r.GetTitle(titleType, titleID); apiErr != nil {
+		return nil, apiErr
+	}
+	revisions, err := r.revisionRepo.FindRevisions(titleType, titleID)
+	if err != nil {
+		log.Printf("ListRevisions error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return revisions, nil
+}
+
+func (r *revisionService) findRevision(titleType models.TitleType, titleID uint, number int) (*models.Revision, *apiError.Error) {
+	if _, apiErr := r.GetTitle(titleType, titleID); apiErr != nil {
+		return nil, apiErr
+	}
+	revision, err := r.revisionRepo.FindRevision(titleType, titleID, number)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.New(fmt.Sprintf("revision %d not found", number), http.StatusNotFound)
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return revision, nil
+}
+
+// Diff compares the metadata of any two revisions of a title
+func (r *revisionService) Diff(titleType models.TitleType, titleID uint, from, to int) ([]models.FieldChange, *apiError.Error) {
+	fromRevision, apiErr := r.findRevision(titleType, titleID, from)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	toRevision, apiErr := r.findRevision(titleType, titleID, to)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return models.DiffMetadata(fromRevision.Metadata, toRevision.Metadata), nil
+}
+
+// Revert brings the metadata of a revision back as a new revision, on top of the given version of
+// the title, and returns the version of the title after it. Approved titles only get a pending
+// revision, an admin has to approve it before the title changes.
+func (r *revisionService) Revert(titleType models.TitleType, titleID uint, number int, userID uint, version int) (*models.Revision, int, *apiError.Error) {
+	target, apiErr := r.findRevision(titleType, titleID, number)
+	if apiErr != nil {
+		return nil, 0, apiErr
+	}
+	record, apiErr := r.GetTitle(titleType, titleID)
+	if apiErr != nil {
+		return nil, 0, apiErr
+	}
+	if version == models.AnyVersion {
+		version = record.Version
+	}
+	if record.Version != version {
+		return nil, 0, staleVersion(titleType, titleID, version)
+	}
+	if len(models.DiffMetadata(record.TitleMetadata, target.Metadata)) == 0 {
+		return nil, 0, apiError.New(fmt.Sprintf("the title already matches revision %d", number), http.StatusBadRequest)
+	}
+
+	revision := &models.Revision{
+		TitleType: titleType,
+		TitleID:   titleID,
+		AuthorID:  userID,
+		Metadata:  target.Metadata,
+		Status:    models.RevisionApplied,
+		RevertOf:  target.Number,
+	}
+	if record.Status == models.Approved {
+		revision.Status = models.RevisionPending
+	}
+	return r.createRevision(revision, version)
+}
+
+func (r *revisionService) findPending(titleType models.TitleType, titleID uint, number int) (*models.Revision, *apiError.Error) {
+	revision, apiErr := r.findRevision(titleType, titleID, number)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if revision.Status != models.RevisionPending {
+		return nil, apiError.New(fmt.Sprintf("revision %d is not waiting for approval", number), http.StatusConflict)
+	}
+	return revision, nil
+}
+
+func (r *revisionService) Approve(titleType models.TitleType, titleID uint, number int, reviewerID uint) (*models.Revision, *apiError.Error) {
+	revision, apiErr := r.findPending(titleType, titleID, number)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if err := r.revisionRepo.ApplyRevision(revision, reviewerID); err != nil {
+		var conflict *apiError.ConflictError
+		if errors.As(err, &conflict) {
+			return nil, staleVersion(titleType, titleID, revision.BaseVersion)
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.New(fmt.Sprintf("revision %d was reviewed meanwhile, reload it", number), http.StatusConflict)
+		}
+		log.Printf("Approve error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return revision, nil
+}
+
+func (r *revisionService) Reject(titleType models.TitleType, titleID uint, number int, reviewerID uint) (*models.Revision, *apiError.Error) {
+	revision, apiErr := r.findPending(titleType, titleID, number)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if err := r.revisionRepo.RejectRevision(revision, reviewerID); err != nil {
+		log.Printf("Reject error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return revision, nil
+}
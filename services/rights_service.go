@@ -0,0 +1,377 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/techagentng/telair-erp/config"
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	mailingservices "github.com/techagentng/telair-erp/mailingservice"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+// RightsService manages licensees and the rights granted to them, keeping exclusive grants from overlapping
+type RightsService interface {
+	CreateLicensee(request *models.LicenseeRequest) (*models.Licensee, *apiError.Error)
+	UpdateLicensee(id uint, request *models.LicenseeRequest) (*models.Licensee, *apiError.Error)
+	GetLicensee(id uint) (*models.Licensee, *apiError.Error)
+	ListLicensees(query string, page, limit int) ([]models.Licensee, int64, *apiError.Error)
+	GrantRights(titleType models.TitleType, titleID uint, request *models.RightsGrantRequest) (*models.RightsGrant, []models.RightsConflict, *apiError.Error)
+	UpdateGrant(id uint, request *models.RightsGrantRequest) (*models.RightsGrant, []models.RightsConflict, *apiError.Error)
+	GetGrant(id uint) (*models.RightsGrant, *apiError.Error)
+	ListTitleGrants(titleType models.TitleType, titleID uint) ([]models.RightsGrant, *apiError.Error)
+	RevokeGrant(id uint) *apiError.Error
+	Availability(query *models.AvailabilityQuery) ([]models.TitleAvailability, int64, *apiError.Error)
+	ExpiringGrants(days int) ([]models.RightsGrant, *apiError.Error)
+	SendExpiryAlerts(ctx context.Context) error
+}
+
+type rightsService struct {
+	Config     *config.Config
+	rightsRepo db.RightsRepository
+	movieRepo  db.MovieRepository
+	mailer     mailingservices.Mailer
+}
+
+// NewRightsService instantiate a rightsService
+func NewRightsService(rightsRepo db.RightsRepository, movieRepo db.MovieRepository, mailer mailingservices.Mailer, conf *config.Config) RightsService {
+	return &rightsService{
+		Config:     conf,
+		rightsRepo: rightsRepo,
+		movieRepo:  movieRepo,
+		mailer:     mailer,
+	}
+}
+
+// errRightsConflict is returned along with the grants standing in the way of a new one
+var errRightsConflict = apiError.New("the grant overlaps exclusive rights already granted", http.StatusConflict)
+
+func (r *rightsService) CreateLicensee(request *models.LicenseeRequest) (*models.Licensee, *apiError.Error) {
+	licensee := &models.Licensee{}
+	if apiErr := r.applyLicenseeRequest(licensee, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := r.rightsRepo.CreateLicensee(licensee); err != nil {
+		log.Printf("CreateLicensee error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return licensee, nil
+}
+
+func (r *rightsService) UpdateLicensee(id uint, request *models.LicenseeRequest) (*models.Licensee, *apiError.Error) {
+	licensee, apiErr := r.GetLicensee(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := r.applyLicenseeRequest(licensee, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := r.rightsRepo.UpdateLicensee(licensee); err != nil {
+		log.Printf("UpdateLicensee error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return licensee, nil
+}
+
+// applyLicenseeRequest copies a request onto a licensee, names have to be unique
+func (r *rightsService) applyLicenseeRequest(licensee *models.Licensee, request *models.LicenseeRequest) *apiError.Error {
+	name := strings.TrimSpace(request.Name)
+	existing, err := r.rightsRepo.FindLicenseeByName(name)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return apiError.ErrInternalServerError
+	}
+	if err == nil && existing.ID != licensee.ID {
+		return apiError.New(fmt.Sprintf("licensee %q already exists", existing.Name), http.StatusConflict)
+	}
+
+	licensee.Name = name
+	licensee.ContactName = request.ContactName
+	licensee.Email = request.Email
+	licensee.Telephone = request.Telephone
+	return nil
+}
+
+func (r *rightsService) GetLicensee(id uint) (*models.Licensee, *apiError.Error) {
+	licensee, err := r.rightsRepo.FindLicensee(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return licensee, nil
+}
+
+func (r *rightsService) ListLicensees(query string, page, limit int) ([]models.Licensee, int64, *apiError.Error) {
+	licensees, total, err := r.rightsRepo.ListLicensees(strings.TrimSpace(query), limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("ListLicensees error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	return licensees, total, nil
+}
+
+// GrantRights licenses a title. When the grant clashes with exclusive rights, or is exclusive and clashes
+// with any rights, nothing is saved and the clashing grants are returned with a 409.
+func (r *rightsService) GrantRights(titleType models.TitleType, titleID uint, request *models.RightsGrantRequest) (*models.RightsGrant, []models.RightsConflict, *apiError.Error) {
+	if apiErr := checkTitle(r.movieRepo, titleType, titleID); apiErr != nil {
+		return nil, nil, apiErr
+	}
+	grant := &models.RightsGrant{TitleType: titleType, TitleID: titleID}
+	if apiErr := r.applyGrantRequest(grant, request); apiErr != nil {
+		return nil, nil, apiErr
+	}
+
+	conflicts, err := r.rightsRepo.CreateGrant(grant)
+	if err != nil {
+		log.Printf("GrantRights error: %v", err)
+		return nil, nil, apiError.ErrInternalServerError
+	}
+	if len(conflicts) > 0 {
+		return nil, conflicts, errRightsConflict
+	}
+	saved, apiErr := r.GetGrant(grant.ID)
+	return saved, nil, apiErr
+}
+
+// UpdateGrant changes the terms of a grant, checking them against the other grants of the title
+func (r *rightsService) UpdateGrant(id uint, request *models.RightsGrantRequest) (*models.RightsGrant, []models.RightsConflict, *apiError.Error) {
+	grant, apiErr := r.GetGrant(id)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	endDate := grant.EndDate
+	if apiErr := r.applyGrantRequest(grant, request); apiErr != nil {
+		return nil, nil, apiErr
+	}
+	// A grant that got a new end date is due a new expiry alert
+	if !sameDate(endDate, grant.EndDate) {
+		grant.ExpiryAlertedAt = nil
+		grant.LicenseeAlertedAt = nil
+	}
+
+	conflicts, err := r.rightsRepo.UpdateGrant(grant)
+	if err != nil {
+		log.Printf("UpdateGrant error: %v", err)
+		return nil, nil, apiError.ErrInternalServerError
+	}
+	if len(conflicts) > 0 {
+		return nil, conflicts, errRightsConflict
+	}
+	saved, apiErr := r.GetGrant(grant.ID)
+	return saved, nil, apiErr
+}
+
+func sameDate(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// applyGrantRequest checks a request and copies it onto a grant, with a window for every territory and medium
+func (r *rightsService) applyGrantRequest(grant *models.RightsGrant, request *models.RightsGrantRequest) *apiError.Error {
+	licensee, apiErr := r.GetLicensee(request.LicenseeID)
+	if apiErr != nil {
+		if apiErr == apiError.ErrNotFound {
+			return apiError.New("licensee not found", http.StatusNotFound)
+		}
+		return apiErr
+	}
+
+	start, err := time.Parse(models.DateLayout, request.StartDate)
+	if err != nil {
+		return apiError.New("start_date must be a date such as 2025-01-31", http.StatusBadRequest)
+	}
+	var end *time.Time
+	if request.EndDate != "" {
+		date, err := time.Parse(models.DateLayout, request.EndDate)
+		if err != nil {
+			return apiError.New("end_date must be a date such as 2025-12-31", http.StatusBadRequest)
+		}
+		if date.Before(start) {
+			return apiError.New("end_date cannot be before start_date", http.StatusBadRequest)
+		}
+		end = &date
+	}
+
+	var territories []string
+	seenTerritories := map[string]bool{}
+	for _, territory := range request.Territories {
+		territory = strings.ToUpper(strings.TrimSpace(territory))
+		if !models.IsCountryCode(territory) {
+			return apiError.New(fmt.Sprintf("%q is not an ISO 3166 country code", territory), http.StatusBadRequest)
+		}
+		if !seenTerritories[territory] {
+			seenTerritories[territory] = true
+			territories = append(territories, territory)
+		}
+	}
+	var media []models.MediaRight
+	seenMedia := map[models.MediaRight]bool{}
+	for _, right := range request.Media {
+		if !right.IsValid() {
+			return apiError.New("media must be theatrical, svod, tv or airline", http.StatusBadRequest)
+		}
+		if !seenMedia[right] {
+			seenMedia[right] = true
+			media = append(media, right)
+		}
+	}
+	if len(territories) == 0 || len(media) == 0 {
+		return apiError.New("a grant needs at least one territory and one medium", http.StatusBadRequest)
+	}
+
+	grant.Windows = make([]models.RightsWindow, 0, len(territories)*len(media))
+	for _, territory := range territories {
+		for _, right := range media {
+			grant.Windows = append(grant.Windows, models.RightsWindow{Territory: territory, Media: right})
+		}
+	}
+	grant.LicenseeID = licensee.ID
+	grant.Licensee = licensee
+	grant.Exclusive = request.Exclusive
+	grant.StartDate = start
+	grant.EndDate = end
+	grant.Notes = request.Notes
+	return nil
+}
+
+func (r *rightsService) GetGrant(id uint) (*models.RightsGrant, *apiError.Error) {
+	grant, err := r.rightsRepo.FindGrant(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return grant, nil
+}
+
+func (r *rightsService) ListTitleGrants(titleType models.TitleType, titleID uint) ([]models.RightsGrant, *apiError.Error) {
+	if apiErr := checkTitle(r.movieRepo, titleType, titleID); apiErr != nil {
+		return nil, apiErr
+	}
+	grants, err := r.rightsRepo.FindTitleGrants(titleType, titleID)
+	if err != nil {
+		log.Printf("ListTitleGrants error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return grants, nil
+}
+
+func (r *rightsService) RevokeGrant(id uint) *apiError.Error {
+	if err := r.rightsRepo.DeleteGrant(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.ErrNotFound
+		}
+		log.Printf("RevokeGrant error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+// Availability lists the titles that can still be licensed in a territory for a medium over a period,
+// e.g. what is free in NG for SVOD next quarter
+func (r *rightsService) Availability(query *models.AvailabilityQuery) ([]models.TitleAvailability, int64, *apiError.Error) {
+	query.Territory = strings.ToUpper(query.Territory)
+	if !models.IsCountryCode(query.Territory) {
+		return nil, 0, apiError.New("territory must be an ISO 3166 country code such as NG", http.StatusBadRequest)
+	}
+	if !query.Media.IsValid() {
+		return nil, 0, apiError.New("media must be theatrical, svod, tv or airline", http.StatusBadRequest)
+	}
+	if query.To.Before(query.From) {
+		return nil, 0, apiError.New("to cannot be before from", http.StatusBadRequest)
+	}
+
+	titles, total, err := r.rightsRepo.FindAvailability(query)
+	if err != nil {
+		log.Printf("Availability error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	return titles, total, nil
+}
+
+// ExpiringGrants lists the grants ending within the given number of days
+func (r *rightsService) ExpiringGrants(days int) ([]models.RightsGrant, *apiError.Error) {
+	if days < 1 {
+		days = r.Config.RightsExpiryAlertDays
+	}
+	grants, err := r.rightsRepo.FindExpiringGrants(time.Now().AddDate(0, 0, days), false)
+	if err != nil {
+		log.Printf("ExpiringGrants error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return grants, nil
+}
+
+// SendExpiryAlerts emails the rights team and the licensee about every grant about to end, once each per
+// grant. A delivery that failed is retried on the next run without repeating the one that went out.
+func (r *rightsService) SendExpiryAlerts(ctx context.Context) error {
+	grants, err := r.rightsRepo.FindExpiringGrants(time.Now().AddDate(0, 0, r.Config.RightsExpiryAlertDays), true)
+	if err != nil {
+		return fmt.Errorf("error finding expiring grants: %w", err)
+	}
+
+	var teamAlerted, licenseeAlerted []uint
+	for _, grant := range grants {
+		if ctx.Err() != nil {
+			break
+		}
+		subject, body := r.expiryAlert(&grant)
+		if grant.ExpiryAlertedAt == nil && r.sendExpiryAlert(r.Config.RightsAlertEmail, grant.ID, subject, body) {
+			teamAlerted = append(teamAlerted, grant.ID)
+		}
+		if grant.LicenseeAlertedAt == nil && r.sendExpiryAlert(grant.Licensee.Email, grant.ID, subject, body) {
+			licenseeAlerted = append(licenseeAlerted, grant.ID)
+		}
+	}
+
+	if err := r.rightsRepo.MarkExpiryAlerted(teamAlerted, licenseeAlerted, time.Now()); err != nil {
+		return fmt.Errorf("error marking expiry alerts: %w", err)
+	}
+	log.Printf("SendExpiryAlerts alerted the team about %d and licensees about %d of %d expiring grants",
+		len(teamAlerted), len(licenseeAlerted), len(grants))
+	return nil
+}
+
+// sendExpiryAlert mails one expiry alert and reports whether it is done with, there is nothing to send
+// when the recipient has no email
+func (r *rightsService) sendExpiryAlert(recipient string, grantID uint, subject, body string) bool {
+	if recipient == "" {
+		return true
+	}
+	if _, err := r.mailer.SendSimpleMessage(recipient, subject, body); err != nil {
+		log.Printf("SendExpiryAlerts error mailing %s about grant %d: %v", recipient, grantID, err)
+		return false
+	}
+	return true
+}
+
+func (r *rightsService) expiryAlert(grant *models.RightsGrant) (string, string) {
+	title := fmt.Sprintf("%s %d", grant.TitleType, grant.TitleID)
+	if found, err := r.movieRepo.FindTitle(grant.TitleType, grant.TitleID); err == nil {
+		title = found.Title
+	}
+	var windows []string
+	for _, window := range grant.Windows {
+		windows = append(windows, fmt.Sprintf("%s %s", window.Territory, window.Media))
+	}
+	kind := "Non-exclusive"
+	if grant.Exclusive {
+		kind = "Exclusive"
+	}
+
+	subject := fmt.Sprintf("Rights to %s end on %s", title, grant.EndDate.Format(models.DateLayout))
+	body := fmt.Sprintf("%s rights to %s granted to %s end on %s.\n\nTerritories and media: %s\n",
+		kind, title, grant.Licensee.Name, grant.EndDate.Format(models.DateLayout), strings.Join(windows, ", "))
+	return subject, body
+}
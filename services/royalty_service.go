@@ -0,0 +1,404 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/pdf"
+	"gorm.io/gorm"
+)
+
+// maxLineErrors stops a badly broken revenue report from producing an endless list of errors
+const maxLineErrors = 100
+
+// RoyaltyService ingests revenue reports and works out what each contract owes for a period
+type RoyaltyService interface {
+	IngestRevenueReport(request *models.RevenueReportRequest, userID uint, body io.Reader) (*models.RevenueReport, []models.LineError, *apiError.Error)
+	GetRevenueReport(id uint) (*models.RevenueReport, *apiError.Error)
+	ListRevenueReports(page, limit int) ([]models.RevenueReport, int64, *apiError.Error)
+	GenerateStatement(contractID uint, request *models.StatementRequest, userID uint) (*models.RoyaltyStatement, *apiError.Error)
+	GetStatement(id uint) (*models.RoyaltyStatement, *apiError.Error)
+	ListStatements(contractID uint) ([]models.RoyaltyStatement, *apiError.Error)
+	StatementPDF(id uint) (*models.RoyaltyStatement, []byte, *apiError.Error)
+}
+
+type royaltyService struct {
+	royaltyRepo  db.RoyaltyRepository
+	contractRepo db.ContractRepository
+	rightsRepo   db.RightsRepository
+	movieRepo    db.MovieRepository
+
+	exchangeRateService ExchangeRateService
+}
+
+// NewRoyaltyService instantiate a royaltyService
+func NewRoyaltyService(royaltyRepo db.RoyaltyRepository, contractRepo db.ContractRepository, rightsRepo db.RightsRepository, movieRepo db.MovieRepository, exchangeRateService ExchangeRateService) RoyaltyService {
+	return &royaltyService{
+		royaltyRepo:         royaltyRepo,
+		contractRepo:        contractRepo,
+		rightsRepo:          rightsRepo,
+		movieRepo:           movieRepo,
+		exchangeRateService: exchangeRateService,
+	}
+}
+
+// IngestRevenueReport reads a revenue CSV whose header is models.RevenueReportColumns. Every line is
+// checked before anything is saved, a report with bad lines is rejected along with what is wrong with them.
+func (s *royaltyService) IngestRevenueReport(request *models.RevenueReportRequest, userID uint, body io.Reader) (*models.RevenueReport, []models.LineError, *apiError.Error) {
+	licenseeID, err := strconv.ParseUint(strings.TrimSpace(request.LicenseeID), 10, 64)
+	if err != nil || licenseeID == 0 {
+		return nil, nil, apiError.New("licensee_id is required, it is the licensee who reported the revenue", http.StatusBadRequest)
+	}
+	if _, err := s.rightsRepo.FindLicensee(uint(licenseeID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, apiError.New("licensee not found", http.StatusNotFound)
+		}
+		return nil, nil, apiError.ErrInternalServerError
+	}
+	start, apiErr := parseDate("period_start", request.PeriodStart)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	end, apiErr := parseDate("period_end", request.PeriodEnd)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	if end.Before(start) {
+		return nil, nil, apiError.New("period_end cannot be before period_start", http.StatusBadRequest)
+	}
+
+	lines, lineErrs, err := s.parseRevenueCSV(body)
+	if err != nil {
+		return nil, nil, apiError.New(fmt.Sprintf("unable to read the revenue report: %v", err), http.StatusBadRequest)
+	}
+	if len(lineErrs) == 0 && len(lines) == 0 {
+		return nil, nil, apiError.New("the revenue report has no lines", http.StatusBadRequest)
+	}
+	if len(lineErrs) > 0 {
+		return nil, lineErrs, apiError.New("the revenue report has invalid lines", http.StatusUnprocessableEntity)
+	}
+
+	report := &models.RevenueReport{
+		LicenseeID:  uint(licenseeID),
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Source:      request.Source,
+		Filename:    request.Filename,
+		UploadedBy:  userID,
+		Lines:       lines,
+	}
+	if err := s.royaltyRepo.CreateRevenueReport(report); err != nil {
+		log.Printf("IngestRevenueReport error: %v", err)
+		return nil, nil, apiError.ErrInternalServerError
+	}
+	return report, nil, nil
+}
+
+// parseRevenueCSV reads the lines of a revenue report, collecting what is wrong with them
+func (s *royaltyService) parseRevenueCSV(body io.Reader) ([]models.RevenueLine, []models.LineError, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	if len(header) < len(models.RevenueReportColumns) {
+		return nil, nil, fmt.Errorf("the header must be %s", strings.Join(models.RevenueReportColumns, ","))
+	}
+	for i, column := range models.RevenueReportColumns {
+		if strings.ToLower(strings.TrimSpace(header[i])) != column {
+			return nil, nil, fmt.Errorf("the header must be %s", strings.Join(models.RevenueReportColumns, ","))
+		}
+	}
+
+	var lines []models.RevenueLine
+	var lineErrs []models.LineError
+	fail := func(line int, format string, args ...interface{}) {
+		if len(lineErrs) < maxLineErrors {
+			lineErrs = append(lineErrs, models.LineError{Line: line, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+	titles := map[models.ContractTitle]*apiError.Error{}
+
+	for number := 2; ; number++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(record) < len(models.RevenueReportColumns) {
+			fail(number, "expected %d columns, got %d", len(models.RevenueReportColumns), len(record))
+			continue
+		}
+
+		line := models.RevenueLine{
+			TitleType: models.TitleType(strings.TrimSpace(record[0])),
+			Territory: strings.ToUpper(strings.TrimSpace(record[2])),
+			Media:     models.MediaRight(strings.ToLower(strings.TrimSpace(record[3]))),
+			Currency:  strings.ToUpper(strings.TrimSpace(record[5])),
+		}
+		id, err := strconv.ParseUint(strings.TrimSpace(record[1]), 10, 64)
+		if err != nil || id == 0 {
+			fail(number, "invalid title_id %q", record[1])
+			continue
+		}
+		line.TitleID = uint(id)
+
+		title := models.ContractTitle{TitleType: line.TitleType, TitleID: line.TitleID}
+		apiErr, checked := titles[title]
+		if !checked {
+			apiErr = checkTitle(s.movieRepo, line.TitleType, line.TitleID)
+			titles[title] = apiErr
+		}
+		if apiErr != nil {
+			fail(number, "%s %d: %s", line.TitleType, line.TitleID, apiErr.Message)
+			continue
+		}
+		if line.Territory != "" && !models.IsCountryCode(line.Territory) {
+			fail(number, "%q is not an ISO 3166 country code", line.Territory)
+			continue
+		}
+		if line.Media != "" && !line.Media.IsValid() {
+			fail(number, "media must be theatrical, svod, tv or airline")
+			continue
+		}
+		if line.GrossRevenue, err = decimal.NewFromString(strings.TrimSpace(record[4])); err != nil || line.GrossRevenue.IsNegative() {
+			fail(number, "invalid gross_revenue %q", record[4])
+			continue
+		}
+		if !isCurrencyCode(line.Currency) {
+			fail(number, "currency must be an ISO 4217 code such as NGN")
+			continue
+		}
+		line.GrossRevenue = line.GrossRevenue.Round(2)
+		lines = append(lines, line)
+	}
+	return lines, lineErrs, nil
+}
+
+func (s *royaltyService) GetRevenueReport(id uint) (*models.RevenueReport, *apiError.Error) {
+	report, err := s.royaltyRepo.FindRevenueReport(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return report, nil
+}
+
+func (s *royaltyService) ListRevenueReports(page, limit int) ([]models.RevenueReport, int64, *apiError.Error) {
+	reports, total, err := s.royaltyRepo.ListRevenueReports(limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("ListRevenueReports error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	return reports, total, nil
+}
+
+// GenerateStatement works out what a contract owes for a period from the revenue its licensee reported within it.
+// Statements build on each other, so they have to be generated in period order and cannot overlap. The
+// latest one can be generated again, e.g. once a late report came in. A report has to fall within a single
+// statement, a period cutting through one is refused rather than leaving its revenue out.
+func (s *royaltyService) GenerateStatement(contractID uint, request *models.StatementRequest, userID uint) (*models.RoyaltyStatement, *apiError.Error) {
+	contract, err := s.contractRepo.FindContract(contractID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	if contract.Status == models.ContractDraft {
+		return nil, apiError.New("draft contracts have no statements", http.StatusConflict)
+	}
+	start, apiErr := parseDate("period_start", request.PeriodStart)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	end, apiErr := parseDate("period_end", request.PeriodEnd)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if end.Before(start) {
+		return nil, apiError.New("period_end cannot be before period_start", http.StatusBadRequest)
+	}
+
+	overlapping, err := s.royaltyRepo.FindOverlappingStatements(contract.ID, start, end)
+	if err != nil {
+		return nil, apiError.ErrInternalServerError
+	}
+	for _, other := range overlapping {
+		if !other.PeriodStart.Equal(start) || !other.PeriodEnd.Equal(end) {
+			return nil, apiError.New(fmt.Sprintf("the period overlaps the statement for %s to %s",
+				other.PeriodStart.Format(models.DateLayout), other.PeriodEnd.Format(models.DateLayout)), http.StatusConflict)
+		}
+	}
+	later, err := s.royaltyRepo.CountStatementsAfter(contract.ID, end)
+	if err != nil {
+		return nil, apiError.ErrInternalServerError
+	}
+	if later > 0 {
+		return nil, apiError.New("statements have to be generated in period order, later periods already have one", http.StatusConflict)
+	}
+
+	straddling, err := s.royaltyRepo.FindStraddlingReports(contract.LicenseeID, contract.Titles, start, end)
+	if err != nil {
+		log.Printf("GenerateStatement error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	if len(straddling) > 0 {
+		report := straddling[0]
+		return nil, apiError.New(fmt.Sprintf("revenue report %d covers %s to %s, which runs past the period; pick a period covering the whole report",
+			report.ID, report.PeriodStart.Format(models.DateLayout), report.PeriodEnd.Format(models.DateLayout)), http.StatusConflict)
+	}
+
+	revenues, err := s.royaltyRepo.SumTitleRevenue(contract.LicenseeID, contract.Titles, start, end)
+	if err != nil {
+		log.Printf("GenerateStatement error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	converted, apiErr := s.convertRevenue(contract.Currency, revenues)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	shareBefore, err := s.royaltyRepo.SumShareBefore(contract.ID, start)
+	if err != nil {
+		log.Printf("GenerateStatement error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+
+	statement := computeStatement(contract, revenues, shareBefore)
+	statement.ConvertedLines = converted
+	statement.PeriodStart = start
+	statement.PeriodEnd = end
+	statement.GeneratedBy = userID
+	for i, line := range statement.Lines {
+		statement.Lines[i].Title = fmt.Sprintf("%s %d", line.TitleType, line.TitleID)
+		if title, err := s.movieRepo.FindTitle(line.TitleType, line.TitleID); err == nil {
+			statement.Lines[i].Title = title.Title
+		}
+	}
+
+	if err := s.royaltyRepo.SaveStatement(statement); err != nil {
+		log.Printf("GenerateStatement error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return s.GetStatement(statement.ID)
+}
+
+// convertRevenue turns the revenue reported in another currency into the contract's, at the rate on the
+// last day of its reports. It returns how many lines were converted, a missing rate refuses the statement
+// rather than leaving the revenue out.
+func (s *royaltyService) convertRevenue(currency string, revenues []models.TitleRevenue) (int, *apiError.Error) {
+	converted := 0
+	for i, revenue := range revenues {
+		if revenue.Currency == currency {
+			continue
+		}
+		amount, _, apiErr := s.exchangeRateService.Convert(revenue.GrossRevenue, revenue.Currency, currency, revenue.ReportedOn)
+		if apiErr != nil {
+			return 0, apiErr
+		}
+		revenues[i].GrossRevenue = amount
+		revenues[i].Currency = currency
+		converted += revenue.Lines
+	}
+	return converted, nil
+}
+
+// computeStatement applies the terms of a contract to the revenue of its titles. Each title's share is
+// rounded to the cent. The share adds up across statements, shareBefore being the total of the earlier
+// ones, and only the part above the minimum guarantee is due: the guarantee was paid upfront. The revenue
+// has to be in the contract's currency already.
+func computeStatement(contract *models.Contract, revenues []models.TitleRevenue, shareBefore decimal.Decimal) *models.RoyaltyStatement {
+	rate := contract.RevenueSharePercent.Div(hundred)
+	statement := &models.RoyaltyStatement{
+		ContractID:          contract.ID,
+		Currency:            contract.Currency,
+		RevenueSharePercent: contract.RevenueSharePercent,
+		MinimumGuarantee:    contract.MinimumGuarantee,
+	}
+
+	gross := map[models.ContractTitle]decimal.Decimal{}
+	for _, revenue := range revenues {
+		title := models.ContractTitle{TitleType: revenue.TitleType, TitleID: revenue.TitleID}
+		gross[title] = gross[title].Add(revenue.GrossRevenue)
+	}
+	for _, title := range contract.Titles {
+		revenue := gross[models.ContractTitle{TitleType: title.TitleType, TitleID: title.TitleID}]
+		share := revenue.Mul(rate).Round(2)
+		statement.Lines = append(statement.Lines, models.RoyaltyStatementLine{
+			TitleType:    title.TitleType,
+			TitleID:      title.TitleID,
+			GrossRevenue: revenue,
+			RevenueShare: share,
+		})
+		statement.GrossRevenue = statement.GrossRevenue.Add(revenue)
+		statement.RevenueShare = statement.RevenueShare.Add(share)
+	}
+
+	statement.CumulativeShare = shareBefore.Add(statement.RevenueShare)
+	statement.Recouped = decimal.Min(statement.CumulativeShare, contract.MinimumGuarantee)
+	dueBefore := decimal.Max(decimal.Zero, shareBefore.Sub(contract.MinimumGuarantee))
+	dueNow := decimal.Max(decimal.Zero, statement.CumulativeShare.Sub(contract.MinimumGuarantee))
+	statement.AmountDue = dueNow.Sub(dueBefore)
+	return statement
+}
+
+func (s *royaltyService) GetStatement(id uint) (*models.RoyaltyStatement, *apiError.Error) {
+	statement, err := s.royaltyRepo.FindStatement(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return statement, nil
+}
+
+func (s *royaltyService) ListStatements(contractID uint) ([]models.RoyaltyStatement, *apiError.Error) {
+	if _, err := s.contractRepo.FindContract(contractID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	statements, err := s.royaltyRepo.FindContractStatements(contractID)
+	if err != nil {
+		log.Printf("ListStatements error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return statements, nil
+}
+
+// StatementPDF lays a statement out as a PDF document
+func (s *royaltyService) StatementPDF(id uint) (*models.RoyaltyStatement, []byte, *apiError.Error) {
+	statement, apiErr := s.GetStatement(id)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	var buf bytes.Buffer
+	if err := pdf.RoyaltyStatement(&buf, statement); err != nil {
+		log.Printf("StatementPDF error: %v", err)
+		return nil, nil, apiError.ErrInternalServerError
+	}
+	return statement, buf.Bytes(), nil
+}
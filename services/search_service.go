@@ -0,0 +1,72 @@
+package services
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+)
+
+// searchSideResults caps the people and taxonomy terms returned next to the titles
+const searchSideResults = 10
+
+// SearchService searches the catalogue, the people directory and the taxonomies at once
+type SearchService interface {
+	Search(query *models.SearchQuery) (*models.SearchResult, *apiError.Error)
+}
+
+type searchService struct {
+	searchRepo db.SearchRepository
+}
+
+// NewSearchService instantiate a searchService
+func NewSearchService(searchRepo db.SearchRepository) SearchService {
+	return &searchService{
+		searchRepo: searchRepo,
+	}
+}
+
+func (s *searchService) Search(query *models.SearchQuery) (*models.SearchResult, *apiError.Error) {
+	query.Text = strings.TrimSpace(query.Text)
+	if query.Text == "" {
+		return nil, apiError.New("search text is required", http.StatusBadRequest)
+	}
+	if query.Year != "" {
+		if _, err := strconv.Atoi(query.Year); err != nil || len(query.Year) != 4 {
+			return nil, apiError.New("year must be a four digit year", http.StatusBadRequest)
+		}
+	}
+
+	titles, total, err := s.searchRepo.SearchTitles(query)
+	if err != nil {
+		log.Printf("Search error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	facets, err := s.searchRepo.TitleFacets(query)
+	if err != nil {
+		log.Printf("Search error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	people, err := s.searchRepo.SearchPeople(query.Text, searchSideResults)
+	if err != nil {
+		log.Printf("Search error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	terms, err := s.searchRepo.SearchTerms(query.Text, searchSideResults)
+	if err != nil {
+		log.Printf("Search error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+
+	return &models.SearchResult{
+		Titles: titles,
+		Total:  total,
+		People: people,
+		Terms:  terms,
+		Facets: *facets,
+	}, nil
+}
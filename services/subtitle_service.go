@@ -0,0 +1,206 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/storage"
+	"github.com/techagentng/telair-erp/subtitle"
+	"gorm.io/gorm"
+)
+
+// SubtitleService manages the subtitle tracks of titles, converting and retiming them on the way out
+type SubtitleService interface {
+	AttachSubtitle(ctx context.Context, sub *models.Subtitle) (*models.Subtitle, *apiError.Error)
+	ListSubtitles(titleType models.TitleType, titleID uint) ([]models.Subtitle, *apiError.Error)
+	GetSubtitle(id uint) (*models.Subtitle, *apiError.Error)
+	RenderSubtitle(ctx context.Context, id uint, format subtitle.Format, offset time.Duration) (*models.Subtitle, []byte, *apiError.Error)
+	ShiftSubtitle(ctx context.Context, id, userID uint, offset time.Duration) (*models.Subtitle, *apiError.Error)
+	DeleteSubtitle(id uint) *apiError.Error
+}
+
+type subtitleService struct {
+	subtitleRepo db.SubtitleRepository
+	movieRepo    db.MovieRepository
+	mediaService MediaService
+	storage      storage.Storage
+}
+
+// NewSubtitleService instantiate a subtitleService
+func NewSubtitleService(subtitleRepo db.SubtitleRepository, movieRepo db.MovieRepository, mediaService MediaService, store storage.Storage) SubtitleService {
+	return &subtitleService{
+		subtitleRepo: subtitleRepo,
+		movieRepo:    movieRepo,
+		mediaService: mediaService,
+		storage:      store,
+	}
+}
+
+// AttachSubtitle saves an uploaded subtitle file as the title's track in its language, replacing the previous one
+func (s *subtitleService) AttachSubtitle(ctx context.Context, sub *models.Subtitle) (*models.Subtitle, *apiError.Error) {
+	if !models.IsLanguageTag(sub.Language) {
+		return nil, apiError.New("language must be a language tag such as en or pt-BR", http.StatusBadRequest)
+	}
+	if apiErr := checkTitle(s.movieRepo, sub.TitleType, sub.TitleID); apiErr != nil {
+		return nil, apiErr
+	}
+
+	asset, err := s.mediaService.AttachAsset(ctx, sub.Asset)
+	if err != nil {
+		log.Printf("AttachSubtitle error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	sub.Asset = asset
+	if err := s.subtitleRepo.SaveSubtitle(sub); err != nil {
+		log.Printf("AttachSubtitle error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return sub, nil
+}
+
+func (s *subtitleService) ListSubtitles(titleType models.TitleType, titleID uint) ([]models.Subtitle, *apiError.Error) {
+	if apiErr := checkTitle(s.movieRepo, titleType, titleID); apiErr != nil {
+		return nil, apiErr
+	}
+	subtitles, err := s.subtitleRepo.FindTitleSubtitles(titleType, titleID)
+	if err != nil {
+		log.Printf("ListSubtitles error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return subtitles, nil
+}
+
+func (s *subtitleService) GetSubtitle(id uint) (*models.Subtitle, *apiError.Error) {
+	sub, err := s.subtitleRepo.FindSubtitle(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	if sub.Asset == nil {
+		log.Printf("GetSubtitle error: subtitle %d has no asset", id)
+		return nil, apiError.ErrInternalServerError
+	}
+	return sub, nil
+}
+
+// load reads and parses the stored file of a subtitle
+func (s *subtitleService) load(ctx context.Context, id uint) (*models.Subtitle, *subtitle.Document, *apiError.Error) {
+	sub, apiErr := s.GetSubtitle(id)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	body, err := s.storage.Open(ctx, sub.Asset.StorageKey)
+	if err != nil {
+		log.Printf("RenderSubtitle error opening %s: %v", sub.Asset.StorageKey, err)
+		return nil, nil, apiError.ErrInternalServerError
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		log.Printf("RenderSubtitle error reading %s: %v", sub.Asset.StorageKey, err)
+		return nil, nil, apiError.ErrInternalServerError
+	}
+	doc, err := subtitle.Parse(data)
+	if err != nil {
+		log.Printf("RenderSubtitle error parsing %s: %v", sub.Asset.StorageKey, err)
+		return nil, nil, apiError.ErrInternalServerError
+	}
+	return sub, doc, nil
+}
+
+// RenderSubtitle returns a subtitle in the requested format, its own when format is empty, with its cues moved by
+// offset. The stored file is left as it is.
+func (s *subtitleService) RenderSubtitle(ctx context.Context, id uint, format subtitle.Format, offset time.Duration) (*models.Subtitle, []byte, *apiError.Error) {
+	sub, doc, apiErr := s.load(ctx, id)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	if format == "" {
+		format = doc.Format
+	}
+	if !format.IsValid() {
+		return nil, nil, apiError.New("format must be srt or vtt", http.StatusBadRequest)
+	}
+
+	doc.Shift(offset)
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, format); err != nil {
+		log.Printf("RenderSubtitle error: %v", err)
+		return nil, nil, apiError.ErrInternalServerError
+	}
+	return sub, buf.Bytes(), nil
+}
+
+// ShiftSubtitle moves every cue of a subtitle for good. The retimed file is stored as a new asset,
+// cues pushed before the start of the title are dropped.
+func (s *subtitleService) ShiftSubtitle(ctx context.Context, id, userID uint, offset time.Duration) (*models.Subtitle, *apiError.Error) {
+	if offset == 0 {
+		return nil, apiError.New("offset cannot be zero", http.StatusBadRequest)
+	}
+	sub, doc, apiErr := s.load(ctx, id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	doc.Shift(offset)
+	if len(doc.Cues) == 0 {
+		return nil, apiError.New("the offset moves every cue before the start of the title", http.StatusBadRequest)
+	}
+
+	var buf bytes.Buffer
+	if err := doc.Write(&buf, doc.Format); err != nil {
+		log.Printf("ShiftSubtitle error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	old := sub.Asset
+	key := storage.NewKey(sub.TitleType.StoragePrefix(sub.TitleID)+"/"+models.MediaSubtitle.StorageFolder(), old.Filename)
+	object, err := s.storage.Upload(ctx, key, &buf, storage.UploadOptions{ContentType: old.ContentType})
+	if err != nil {
+		log.Printf("ShiftSubtitle error storing %s: %v", key, err)
+		return nil, apiError.ErrInternalServerError
+	}
+
+	asset, err := s.mediaService.AttachAsset(ctx, &models.MediaAsset{
+		TitleType:       sub.TitleType,
+		TitleID:         sub.TitleID,
+		Kind:            models.MediaSubtitle,
+		StorageKey:      object.Key,
+		URL:             object.URL,
+		Filename:        old.Filename,
+		ContentType:     old.ContentType,
+		Size:            object.Size,
+		SHA256:          object.SHA256,
+		UploadedBy:      userID,
+		DurationSeconds: doc.Duration().Seconds(),
+	})
+	if err != nil {
+		log.Printf("ShiftSubtitle error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	sub.Cues = len(doc.Cues)
+	if err := s.subtitleRepo.ReplaceSubtitleAsset(sub, asset); err != nil {
+		log.Printf("ShiftSubtitle error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return sub, nil
+}
+
+func (s *subtitleService) DeleteSubtitle(id uint) *apiError.Error {
+	if err := s.subtitleRepo.DeleteSubtitle(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apiError.ErrNotFound
+		}
+		log.Printf("DeleteSubtitle error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+// TaxonomyService manages the catalogue lookup lists and the terms attached to titles
+type TaxonomyService interface {
+	ListTerms(kind models.TaxonomyKind) ([]models.TaxonomyTerm, *apiError.Error)
+	CreateTerm(kind models.TaxonomyKind, request *models.TaxonomyTermRequest) (*models.TaxonomyTerm, *apiError.Error)
+	UpdateTerm(id uint, request *models.TaxonomyTermRequest) (*models.TaxonomyTerm, *apiError.Error)
+	DeleteTerm(id uint) *apiError.Error
+	MergeTerms(sourceID, targetID uint) (*models.TaxonomyTerm, *apiError.Error)
+	SetTitleTerms(titleType models.TitleType, titleID uint, termIDs []uint) ([]models.TaxonomyTerm, *apiError.Error)
+	GetTitleTerms(titleType models.TitleType, titleID uint) ([]models.TaxonomyTerm, *apiError.Error)
+	ListTitles(titleType models.TitleType, filter *models.TitleFilter) ([]models.TitleSummary, int64, *apiError.Error)
+}
+
+type taxonomyService struct {
+	taxonomyRepo db.TaxonomyRepository
+	movieRepo    db.MovieRepository
+}
+
+// NewTaxonomyService instantiate a taxonomyService
+func NewTaxonomyService(taxonomyRepo db.TaxonomyRepository, movieRepo db.MovieRepository) TaxonomyService {
+	return &taxonomyService{
+		taxonomyRepo: taxonomyRepo,
+		movieRepo:    movieRepo,
+	}
+}
+
+// slugify turns a term name into the form used in URLs and filters, e.g. "Sci-Fi & Fantasy" becomes "sci-fi-fantasy"
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+func (t *taxonomyService) ListTerms(kind models.TaxonomyKind) ([]models.TaxonomyTerm, *apiError.Error) {
+	if !kind.IsValid() {
+		return nil, apiError.New(fmt.Sprintf("unknown taxonomy: %s", kind), http.StatusNotFound)
+	}
+	terms, err := t.taxonomyRepo.ListTerms(kind)
+	if err != nil {
+		log.Printf("ListTerms error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return terms, nil
+}
+
+func (t *taxonomyService) CreateTerm(kind models.TaxonomyKind, request *models.TaxonomyTermRequest) (*models.TaxonomyTerm, *apiError.Error) {
+	if !kind.IsValid() {
+		return nil, apiError.New(fmt.Sprintf("unknown taxonomy: %s", kind), http.StatusNotFound)
+	}
+	term := &models.TaxonomyTerm{Kind: kind}
+	if apiErr := t.applyTermRequest(term, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := t.taxonomyRepo.CreateTerm(term); err != nil {
+		log.Printf("CreateTerm error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return term, nil
+}
+
+func (t *taxonomyService) UpdateTerm(id uint, request *models.TaxonomyTermRequest) (*models.TaxonomyTerm, *apiError.Error) {
+	term, apiErr := t.findTerm(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := t.applyTermRequest(term, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := t.taxonomyRepo.UpdateTerm(term); err != nil {
+		log.Printf("UpdateTerm error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return term, nil
+}
+
+// applyTermRequest copies a request onto a term, checking the slug is free and the parent makes sense
+func (t *taxonomyService) applyTermRequest(term *models.TaxonomyTerm, request *models.TaxonomyTermRequest) *apiError.Error {
+	slug := slugify(request.Name)
+	if slug == "" {
+		return apiError.New("name must contain letters or digits", http.StatusBadRequest)
+	}
+	existing, err := t.taxonomyRepo.FindTermBySlug(term.Kind, slug)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return apiError.ErrInternalServerError
+	}
+	if err == nil && existing.ID != term.ID {
+		return apiError.New(fmt.Sprintf("%s %q already exists", term.Kind, existing.Name), http.StatusConflict)
+	}
+
+	if request.ParentID != nil {
+		if term.Kind != models.TaxonomySubGenre {
+			return apiError.New("only sub-genres have a parent", http.StatusBadRequest)
+		}
+		parent, apiErr := t.findTerm(*request.ParentID)
+		if apiErr != nil || parent.Kind != models.TaxonomyGenre {
+			return apiError.New("parent must be a genre", http.StatusBadRequest)
+		}
+	}
+
+	term.Name = strings.TrimSpace(request.Name)
+	term.Slug = slug
+	term.Code = strings.ToUpper(request.Code)
+	term.ParentID = request.ParentID
+	return nil
+}
+
+func (t *taxonomyService) findTerm(id uint) (*models.TaxonomyTerm, *apiError.Error) {
+	term, err := t.taxonomyRepo.FindTerm(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return term, nil
+}
+
+func (t *taxonomyService) DeleteTerm(id uint) *apiError.Error {
+	if _, apiErr := t.findTerm(id); apiErr != nil {
+		return apiErr
+	}
+	if err := t.taxonomyRepo.DeleteTerm(id); err != nil {
+		log.Printf("DeleteTerm error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+// MergeTerms folds a duplicate term into another one of the same kind
+func (t *taxonomyService) MergeTerms(sourceID, targetID uint) (*models.TaxonomyTerm, *apiError.Error) {
+	if sourceID == targetID {
+		return nil, apiError.New("a term cannot be merged into itself", http.StatusBadRequest)
+	}
+	source, apiErr := t.findTerm(sourceID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	target, apiErr := t.findTerm(targetID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if source.Kind != target.Kind {
+		return nil, apiError.New(fmt.Sprintf("cannot merge a %s into a %s", source.Kind, target.Kind), http.StatusBadRequest)
+	}
+
+	if err := t.taxonomyRepo.MergeTerms(source.ID, target.ID); err != nil {
+		log.Printf("MergeTerms error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return target, nil
+}
+
+func (t *taxonomyService) SetTitleTerms(titleType models.TitleType, titleID uint, termIDs []uint) ([]models.TaxonomyTerm, *apiError.Error) {
+	if apiErr := checkTitle(t.movieRepo, titleType, titleID); apiErr != nil {
+		return nil, apiErr
+	}
+	terms, err := t.taxonomyRepo.FindTerms(termIDs)
+	if err != nil {
+		return nil, apiError.ErrInternalServerError
+	}
+	found := map[uint]bool{}
+	for _, term := range terms {
+		found[term.ID] = true
+	}
+	for _, id := range termIDs {
+		if !found[id] {
+			return nil, apiError.New(fmt.Sprintf("term %d not found", id), http.StatusBadRequest)
+		}
+	}
+
+	if err := t.taxonomyRepo.SetTitleTerms(titleType, titleID, termIDs); err != nil {
+		log.Printf("SetTitleTerms error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return t.GetTitleTerms(titleType, titleID)
+}
+
+func (t *taxonomyService) GetTitleTerms(titleType models.TitleType, titleID uint) ([]models.TaxonomyTerm, *apiError.Error) {
+	if apiErr := checkTitle(t.movieRepo, titleType, titleID); apiErr != nil {
+		return nil, apiErr
+	}
+	terms, err := t.taxonomyRepo.FindTitleTerms(titleType, titleID)
+	if err != nil {
+		log.Printf("GetTitleTerms error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return terms, nil
+}
+
+func (t *taxonomyService) ListTitles(titleType models.TitleType, filter *models.TitleFilter) ([]models.TitleSummary, int64, *apiError.Error) {
+	if !titleType.IsValid() {
+		return nil, 0, apiError.New("title type must be trailer or full_length", http.StatusBadRequest)
+	}
+	titles, total, err := t.taxonomyRepo.ListTitles(titleType, filter)
+	if err != nil {
+		log.Printf("ListTitles error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	return titles, total, nil
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/techagentng/telair-erp/config"
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/storage"
+	"gorm.io/gorm"
+)
+
+// TrashService soft deletes users and titles, restores them and purges them once they have
+// been in the trash for longer than the retention period
+type TrashService interface {
+	Delete(trashType string, id, userID uint) *apiError.Error
+	Restore(trashType string, id uint) *apiError.Error
+	ListTrash(trashType string, page, limit int) ([]models.TrashItem, int64, *apiError.Error)
+	PurgeExpired(ctx context.Context) error
+}
+
+type trashService struct {
+	Config     *config.Config
+	trashRepo  db.TrashRepository
+	uploadRepo db.UploadRepository
+	storage    storage.Storage
+}
+
+// NewTrashService instantiate a trashService
+func NewTrashService(trashRepo db.TrashRepository, uploadRepo db.UploadRepository, store storage.Storage, conf *config.Config) TrashService {
+	return &trashService{
+		Config:     conf,
+		trashRepo:  trashRepo,
+		uploadRepo: uploadRepo,
+		storage:    store,
+	}
+}
+
+func (t *trashService) retention() time.Duration {
+	return time.Duration(t.Config.TrashRetentionDays) * 24 * time.Hour
+}
+
+func checkTrashType(trashType string) *apiError.Error {
+	if trashType != models.TrashUser && !models.TitleType(trashType).IsValid() {
+		return apiError.New("type must be user, trailer or full_length", http.StatusBadRequest)
+	}
+	return nil
+}
+
+// Delete moves a user or a title to the trash, users cannot delete their own account
+func (t *trashService) Delete(trashType string, id, userID uint) *apiError.Error {
+	if apiErr := checkTrashType(trashType); apiErr != nil {
+		return apiErr
+	}
+
+	var err error
+	if trashType == models.TrashUser {
+		if id == userID {
+			return apiError.New("you cannot delete your own account", http.StatusBadRequest)
+		}
+		err = t.trashRepo.DeleteUser(id)
+	} else {
+		err = t.trashRepo.DeleteTitle(models.TitleType(trashType), id)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apiError.ErrNotFound
+	}
+	if err != nil {
+		log.Printf("Delete error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+func (t *trashService) Restore(trashType string, id uint) *apiError.Error {
+	if apiErr := checkTrashType(trashType); apiErr != nil {
+		return apiErr
+	}
+
+	var err error
+	if trashType == models.TrashUser {
+		err = t.trashRepo.RestoreUser(id)
+	} else {
+		err = t.trashRepo.RestoreTitle(models.TitleType(trashType), id)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apiError.New("not found in the trash", http.StatusNotFound)
+	}
+	if err != nil {
+		log.Printf("Restore error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+func (t *trashService) ListTrash(trashType string, page, limit int) ([]models.TrashItem, int64, *apiError.Error) {
+	if apiErr := checkTrashType(trashType); apiErr != nil {
+		return nil, 0, apiErr
+	}
+	items, total, err := t.trashRepo.FindTrash(trashType, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("ListTrash error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	for i := range items {
+		items[i].PurgeAt = items[i].DeletedAt.Add(t.retention())
+	}
+	return items, total, nil
+}
+
+// PurgeExpired removes for good the titles and users that have been in the trash longer than the
+// retention period, along with the storage objects only they were using
+func (t *trashService) PurgeExpired(ctx context.Context) error {
+	before := time.Now().Add(-t.retention())
+
+	for _, titleType := range []models.TitleType{models.TitleTrailer, models.TitleFullLength} {
+		ids, err := t.trashRepo.FindDeletedTitles(titleType, before)
+		if err != nil {
+			return fmt.Errorf("error finding deleted %s titles: %w", titleType, err)
+		}
+		for _, id := range ids {
+			keys, err := t.trashRepo.PurgeTitle(titleType, id)
+			if err != nil {
+				log.Printf("PurgeExpired error purging %s %d: %v", titleType, id, err)
+				continue
+			}
+			// Leftovers of unfinished uploads live under the title's prefix too
+			err = t.storage.List(ctx, titleType.StoragePrefix(id)+"/", func(object storage.ObjectSummary) error {
+				keys = append(keys, object.Key)
+				return nil
+			})
+			if err != nil {
+				log.Printf("PurgeExpired error listing objects of %s %d: %v", titleType, id, err)
+			}
+			t.deleteUnreferenced(ctx, keys)
+			log.Printf("PurgeExpired purged %s %d", titleType, id)
+		}
+	}
+
+	users, err := t.trashRepo.FindDeletedUsers(before)
+	if err != nil {
+		return fmt.Errorf("error finding deleted users: %w", err)
+	}
+	for _, user := range users {
+		if err := t.trashRepo.PurgeUser(user.ID); err != nil {
+			log.Printf("PurgeExpired error purging user %d: %v", user.ID, err)
+			continue
+		}
+		var keys []string
+		if key := strings.TrimPrefix(user.ThumbNailURL, t.storage.URL("")); key != user.ThumbNailURL {
+			keys = append(keys, key)
+		}
+		err := t.storage.List(ctx, fmt.Sprintf("avatars/%d/", user.ID), func(object storage.ObjectSummary) error {
+			keys = append(keys, object.Key)
+			return nil
+		})
+		if err != nil {
+			log.Printf("PurgeExpired error listing avatars of user %d: %v", user.ID, err)
+		}
+		for _, key := range keys {
+			if err := t.storage.Delete(ctx, key); err != nil {
+				log.Printf("PurgeExpired error removing %s: %v", key, err)
+			}
+		}
+		log.Printf("PurgeExpired purged user %d", user.ID)
+	}
+	return nil
+}
+
+// deleteUnreferenced deletes the objects no media asset or upload uses any more. Deduplicated
+// assets share objects, so an object can outlive the title it was uploaded for.
+func (t *trashService) deleteUnreferenced(ctx context.Context, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	referenced, err := t.uploadRepo.FindReferencedKeys(keys)
+	if err != nil {
+		log.Printf("PurgeExpired error looking up storage keys: %v", err)
+		return
+	}
+
+	deleted := map[string]bool{}
+	for _, key := range keys {
+		if referenced[key] || deleted[key] {
+			continue
+		}
+		deleted[key] = true
+		if err := t.storage.Delete(ctx, key); err != nil {
+			log.Printf("PurgeExpired error removing %s: %v", key, err)
+		}
+	}
+}
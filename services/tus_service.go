@@ -0,0 +1,436 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/techagentng/telair-erp/config"
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/media"
+	"github.com/techagentng/telair-erp/models"
+	"github.com/techagentng/telair-erp/storage"
+	"gorm.io/gorm"
+)
+
+// TusService implements the storage side of the tus 1.0 resumable upload protocol
+type TusService interface {
+	CreateUpload(upload *models.TusUpload, role string) (*models.TusUpload, *apiError.Error)
+	GetUpload(id string, userID uint) (*models.TusUpload, *apiError.Error)
+	WriteChunk(ctx context.Context, id string, userID uint, offset int64, body io.Reader) (*models.TusUpload, *apiError.Error)
+	TerminateUpload(ctx context.Context, id string, userID uint) *apiError.Error
+	CleanupExpired(ctx context.Context) error
+}
+
+type tusService struct {
+	Config       *config.Config
+	uploadRepo   db.UploadRepository
+	movieRepo    db.MovieRepository
+	mediaService MediaService
+	storage      storage.Storage
+	partSize     int64
+}
+
+// NewTusService instantiate a tusService
+func NewTusService(uploadRepo db.UploadRepository, movieRepo db.MovieRepository, mediaService MediaService, store storage.Storage, conf *config.Config) TusService {
+	partSize := conf.UploadPartSizeMB << 20
+	if partSize < storage.MinPartSize {
+		partSize = storage.MinPartSize
+	}
+	return &tusService{
+		Config:       conf,
+		uploadRepo:   uploadRepo,
+		movieRepo:    movieRepo,
+		mediaService: mediaService,
+		storage:      store,
+		partSize:     partSize,
+	}
+}
+
+// tusLease is how long a PATCH request holds an upload without renewing its lease, it is renewed every
+// third of it while the body is read
+const tusLease = time.Minute
+
+// pendingKey is where bytes that do not fill a whole part yet are parked between PATCH requests. Each
+// write gets a key of its own, named after the offset it reaches, so the bytes the recorded offset
+// relies on are never overwritten before the new offset is recorded.
+func pendingKey(upload *models.TusUpload, offset int64) string {
+	return fmt.Sprintf("%s.%d.part", upload.StorageKey, offset)
+}
+
+// CreateUpload starts a resumable upload of media for a title, which only admins and the owner of the
+// title can
+func (t *tusService) CreateUpload(upload *models.TusUpload, role string) (*models.TusUpload, *apiError.Error) {
+	if apiErr := checkTitleUpload(t.movieRepo, upload.TitleType, upload.TitleID, upload.UserID, role); apiErr != nil {
+		return nil, apiErr
+	}
+
+	upload.ID = uuid.New().String()
+	upload.StorageKey = storage.NewKey(upload.TitleType.StoragePrefix(upload.TitleID)+"/"+upload.Kind.StorageFolder(), upload.Filename)
+	upload.ExpiresAt = time.Now().Add(time.Duration(t.Config.TusUploadExpiryHours) * time.Hour)
+
+	multipartID, err := t.storage.CreateMultipart(context.Background(), upload.StorageKey, storage.UploadOptions{ContentType: upload.ContentType, VerifyParts: true})
+	if err != nil {
+		log.Printf("CreateUpload error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	upload.MultipartID = multipartID
+
+	if err := t.uploadRepo.CreateTusUpload(upload); err != nil {
+		log.Printf("CreateUpload error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return upload, nil
+}
+
+func (t *tusService) GetUpload(id string, userID uint) (*models.TusUpload, *apiError.Error) {
+	upload, err := t.uploadRepo.FindTusUpload(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	if upload.UserID != userID {
+		return nil, apiError.ErrNotFound
+	}
+	if !upload.Completed && time.Now().After(upload.ExpiresAt) {
+		return nil, apiError.New("upload expired", http.StatusGone)
+	}
+	return upload, nil
+}
+
+// WriteChunk appends the body of a PATCH request at offset. Bytes are cut into storage parts;
+// whatever does not fill a whole part is parked in storage so an interrupted request loses nothing.
+// The request holds a lease on the upload while the body is written, a second PATCH on the same
+// upload is refused meanwhile.
+func (t *tusService) WriteChunk(ctx context.Context, id string, userID uint, offset int64, body io.Reader) (*models.TusUpload, *apiError.Error) {
+	if _, apiErr := t.GetUpload(id, userID); apiErr != nil {
+		return nil, apiErr
+	}
+	upload, token, apiErr := t.lease(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		t.renewLease(ctx, cancel, id, token)
+	}()
+	defer func() {
+		cancel()
+		<-renewed
+		if err := t.uploadRepo.ReleaseTusUpload(id, token); err != nil {
+			log.Printf("WriteChunk error releasing upload %s: %v", id, err)
+		}
+	}()
+
+	if apiErr := t.writeChunk(ctx, upload, token, offset, body); apiErr != nil {
+		return nil, apiErr
+	}
+	return upload, nil
+}
+
+// lease takes the lease on an upload for the request about to change it, under a token of its own
+func (t *tusService) lease(id string) (*models.TusUpload, string, *apiError.Error) {
+	token := uuid.New().String()
+	upload, err := t.uploadRepo.AcquireTusUpload(id, token, time.Now().Add(tusLease))
+	if err != nil {
+		if errors.Is(err, db.ErrUploadLocked) {
+			return nil, "", apiError.New("upload is being written by another request", http.StatusLocked)
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", apiError.ErrNotFound
+		}
+		log.Printf("lease upload error: %v", err)
+		return nil, "", apiError.ErrInternalServerError
+	}
+	return upload, token, nil
+}
+
+// renewLease keeps the lease on an upload until ctx is done, it cancels ctx if the lease is lost so that
+// no more is written to storage for the upload
+func (t *tusService) renewLease(ctx context.Context, cancel context.CancelFunc, id, token string) {
+	ticker := time.NewTicker(tusLease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.uploadRepo.RenewTusUpload(id, token, time.Now().Add(tusLease)); err != nil {
+				log.Printf("WriteChunk error renewing the lease on upload %s: %v", id, err)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// writeChunk does the work of WriteChunk on the leased upload. Each part sent to storage and each
+// write of the bytes held back is recorded with the offset it reaches as soon as it is done.
+func (t *tusService) writeChunk(ctx context.Context, upload *models.TusUpload, token string, offset int64, body io.Reader) *apiError.Error {
+	if upload.Offset != offset {
+		return apiError.New("Upload-Offset does not match the current offset", http.StatusConflict)
+	}
+	if upload.Completed {
+		return nil
+	}
+
+	if upload.Offset == 0 {
+		// The first chunk carries the file header, check it against the announced type
+		buffered := bufio.NewReaderSize(body, media.SniffLen)
+		header, err := buffered.Peek(media.SniffLen)
+		if err != nil && err != io.EOF {
+			log.Printf("WriteChunk read error for upload %s: %v", upload.ID, err)
+			return apiError.New("unable to read upload body", http.StatusBadRequest)
+		}
+		contentType, apiErr := checkFileType(upload.ContentType, header)
+		if apiErr != nil {
+			return apiErr
+		}
+		upload.ContentType = contentType
+		body = buffered
+	}
+
+	parts, err := t.uploadRepo.FindTusUploadParts(upload.ID)
+	if err != nil {
+		return apiError.ErrInternalServerError
+	}
+
+	digest, err := resumeHash(upload.HashState)
+	if err != nil {
+		log.Printf("WriteChunk error restoring hash of upload %s: %v", upload.ID, err)
+		return apiError.ErrInternalServerError
+	}
+	start := upload.Offset
+	received := &countingReader{r: io.TeeReader(io.LimitReader(body, upload.Length-upload.Offset), digest)}
+	var src io.Reader = received
+	if upload.PendingSize > 0 {
+		pending, err := t.storage.Open(ctx, upload.PendingKey)
+		if err != nil {
+			log.Printf("WriteChunk error opening pending part: %v", err)
+			return apiError.ErrInternalServerError
+		}
+		defer pending.Close()
+		src = io.MultiReader(io.LimitReader(pending, upload.PendingSize), received)
+	}
+
+	buf := make([]byte, t.partSize)
+	for {
+		n, readErr := io.ReadFull(src, buf)
+		if n > 0 {
+			end := start + received.n
+			done := end == upload.Length
+			if done {
+				// tus does not allow a body to run past Upload-Length, refuse it rather than drop the rest
+				if extra, _ := io.ReadFull(body, make([]byte, 1)); extra > 0 {
+					return apiError.New("request body is longer than the rest of the upload", http.StatusRequestEntityTooLarge)
+				}
+			}
+			stale := upload.PendingKey
+			var record *models.TusUploadPart
+			if int64(n) == t.partSize || done {
+				part, err := t.storage.UploadPart(ctx, upload.StorageKey, upload.MultipartID, int32(len(parts)+1), buf[:n])
+				if err != nil {
+					log.Printf("WriteChunk error: %v", err)
+					return apiError.ErrInternalServerError
+				}
+				record = &models.TusUploadPart{UploadID: upload.ID, PartNumber: part.Number, ETag: part.ETag, ChecksumSHA256: part.ChecksumSHA256, Size: part.Size}
+				upload.PendingKey, upload.PendingSize = "", 0
+			} else if key := pendingKey(upload, end); key != upload.PendingKey {
+				if _, err := t.storage.Upload(ctx, key, bytes.NewReader(buf[:n]), storage.UploadOptions{ContentType: "application/octet-stream"}); err != nil {
+					log.Printf("WriteChunk error storing pending part: %v", err)
+					return apiError.ErrInternalServerError
+				}
+				upload.PendingKey, upload.PendingSize = key, int64(n)
+			}
+
+			upload.Offset = end
+			if upload.HashState, err = digest.(encoding.BinaryMarshaler).MarshalBinary(); err != nil {
+				return apiError.ErrInternalServerError
+			}
+			if apiErr := t.saveProgress(upload, token, record); apiErr != nil {
+				return apiErr
+			}
+			if record != nil {
+				parts = append(parts, *record)
+			}
+			if stale != "" && stale != upload.PendingKey {
+				// Only a left over once the new offset is recorded, the reconciliation removes it if this fails
+				if err := t.storage.Delete(ctx, stale); err != nil {
+					log.Printf("WriteChunk error removing pending part %s: %v", stale, err)
+				}
+			}
+		}
+
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			// The client went away, what was stored is recorded already so it can resume
+			log.Printf("WriteChunk read error for upload %s: %v", upload.ID, readErr)
+			break
+		}
+	}
+
+	if upload.Offset == upload.Length {
+		if apiErr := t.finish(ctx, upload, parts, hex.EncodeToString(digest.Sum(nil))); apiErr != nil {
+			return apiErr
+		}
+		return t.saveProgress(upload, token, nil)
+	}
+	return nil
+}
+
+// saveProgress records the offset an upload reached and the part that took it there, if any
+func (t *tusService) saveProgress(upload *models.TusUpload, token string, part *models.TusUploadPart) *apiError.Error {
+	if err := t.uploadRepo.SaveTusProgress(upload, token, part); err != nil {
+		if errors.Is(err, db.ErrUploadLocked) {
+			return apiError.New("upload was taken over by another request", http.StatusLocked)
+		}
+		log.Printf("WriteChunk error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+// checkFileType sniffs the first bytes of a file and makes sure it is the same kind of media the
+// client announced, it returns the detected content type
+func checkFileType(announced string, header []byte) (string, *apiError.Error) {
+	contentType, err := media.Sniff(header)
+	if err != nil {
+		return "", apiError.New("file type is not supported", http.StatusUnsupportedMediaType)
+	}
+	if media.IsVideo(contentType) != media.IsVideo(announced) {
+		return "", apiError.New(fmt.Sprintf("file was announced as %s but is %s", announced, contentType), http.StatusUnsupportedMediaType)
+	}
+	return contentType, nil
+}
+
+// resumeHash picks up the SHA-256 of an upload where the previous request left it
+func resumeHash(state []byte) (hash.Hash, error) {
+	h := sha256.New()
+	if len(state) > 0 {
+		if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// finish completes the multipart upload and attaches the file to its title as a media asset
+func (t *tusService) finish(ctx context.Context, upload *models.TusUpload, records []models.TusUploadPart, sum string) *apiError.Error {
+	parts := make([]storage.Part, 0, len(records))
+	for _, record := range records {
+		parts = append(parts, storage.Part{Number: record.PartNumber, ETag: record.ETag, ChecksumSHA256: record.ChecksumSHA256, Size: record.Size})
+	}
+	if err := t.storage.CompleteMultipart(ctx, upload.StorageKey, upload.MultipartID, parts); err != nil {
+		log.Printf("finish upload error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+
+	asset := &models.MediaAsset{
+		TitleType:   upload.TitleType,
+		TitleID:     upload.TitleID,
+		Kind:        upload.Kind,
+		StorageKey:  upload.StorageKey,
+		URL:         t.storage.URL(upload.StorageKey),
+		Filename:    upload.Filename,
+		ContentType: upload.ContentType,
+		Size:        upload.Length,
+		SHA256:      sum,
+		UploadedBy:  upload.UserID,
+	}
+	asset, err := t.mediaService.AttachAsset(ctx, asset)
+	if err != nil {
+		log.Printf("finish upload error: %v", err)
+		return apiError.ErrInternalServerError
+	}
+
+	upload.Completed = true
+	upload.MediaAssetID = &asset.ID
+	return nil
+}
+
+func (t *tusService) TerminateUpload(ctx context.Context, id string, userID uint) *apiError.Error {
+	upload, apiErr := t.GetUpload(id, userID)
+	if apiErr != nil {
+		return apiErr
+	}
+	if upload.Completed {
+		return apiError.New("upload already completed", http.StatusBadRequest)
+	}
+	// A PATCH still writing holds the lease, the upload is not pulled from under it
+	upload, token, apiErr := t.lease(id)
+	if apiErr != nil {
+		return apiErr
+	}
+	if err := t.discard(ctx, upload); err != nil {
+		log.Printf("TerminateUpload error: %v", err)
+		if err := t.uploadRepo.ReleaseTusUpload(id, token); err != nil {
+			log.Printf("TerminateUpload error releasing upload %s: %v", id, err)
+		}
+		return apiError.ErrInternalServerError
+	}
+	return nil
+}
+
+// CleanupExpired discards unfinished uploads that went past their expiry date
+func (t *tusService) CleanupExpired(ctx context.Context) error {
+	uploads, err := t.uploadRepo.FindExpiredTusUploads(time.Now())
+	if err != nil {
+		return fmt.Errorf("error finding expired uploads: %w", err)
+	}
+	for _, expired := range uploads {
+		upload, token, apiErr := t.lease(expired.ID)
+		if apiErr != nil {
+			log.Printf("CleanupExpired skipped upload %s: %v", expired.ID, apiErr)
+			continue
+		}
+		if err := t.discard(ctx, upload); err != nil {
+			log.Printf("CleanupExpired error for upload %s: %v", upload.ID, err)
+			if err := t.uploadRepo.ReleaseTusUpload(upload.ID, token); err != nil {
+				log.Printf("CleanupExpired error releasing upload %s: %v", upload.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (t *tusService) discard(ctx context.Context, upload *models.TusUpload) error {
+	if err := t.storage.AbortMultipart(ctx, upload.StorageKey, upload.MultipartID); err != nil {
+		return err
+	}
+	if upload.PendingKey != "" {
+		if err := t.storage.Delete(ctx, upload.PendingKey); err != nil {
+			return err
+		}
+	}
+	return t.uploadRepo.DeleteTusUpload(upload.ID)
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
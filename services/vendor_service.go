@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/techagentng/telair-erp/db"
+	apiError "github.com/techagentng/telair-erp/errors"
+	"github.com/techagentng/telair-erp/models"
+	"gorm.io/gorm"
+)
+
+// VendorService manages the vendors productions buy from
+type VendorService interface {
+	CreateVendor(request *models.VendorRequest) (*models.Vendor, *apiError.Error)
+	UpdateVendor(id uint, request *models.VendorRequest) (*models.Vendor, *apiError.Error)
+	GetVendor(id uint) (*models.Vendor, *apiError.Error)
+	ListVendors(query string, page, limit int) ([]models.Vendor, int64, *apiError.Error)
+}
+
+type vendorService struct {
+	vendorRepo db.VendorRepository
+}
+
+// NewVendorService instantiate a vendorService
+func NewVendorService(vendorRepo db.VendorRepository) VendorService {
+	return &vendorService{
+		vendorRepo: vendorRepo,
+	}
+}
+
+func (s *vendorService) CreateVendor(request *models.VendorRequest) (*models.Vendor, *apiError.Error) {
+	vendor := &models.Vendor{}
+	if apiErr := s.applyVendorRequest(vendor, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.vendorRepo.CreateVendor(vendor); err != nil {
+		log.Printf("CreateVendor error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return vendor, nil
+}
+
+func (s *vendorService) UpdateVendor(id uint, request *models.VendorRequest) (*models.Vendor, *apiError.Error) {
+	vendor, apiErr := s.GetVendor(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := s.applyVendorRequest(vendor, request); apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.vendorRepo.UpdateVendor(vendor); err != nil {
+		log.Printf("UpdateVendor error: %v", err)
+		return nil, apiError.ErrInternalServerError
+	}
+	return vendor, nil
+}
+
+// applyVendorRequest copies a request onto a vendor, names are unique regardless of case
+func (s *vendorService) applyVendorRequest(vendor *models.Vendor, request *models.VendorRequest) *apiError.Error {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return apiError.New("name is required", http.StatusBadRequest)
+	}
+	existing, err := s.vendorRepo.FindVendorByName(name)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return apiError.ErrInternalServerError
+	}
+	if err == nil && existing.ID != vendor.ID {
+		return apiError.New(fmt.Sprintf("vendor %s already exists", existing.Name), http.StatusConflict)
+	}
+	currency := strings.ToUpper(request.Currency)
+	if currency != "" && !isCurrencyCode(currency) {
+		return apiError.New("currency must be an ISO 4217 code such as NGN", http.StatusBadRequest)
+	}
+	if request.PaymentDays < 0 {
+		return apiError.New("payment_days cannot be negative", http.StatusBadRequest)
+	}
+
+	vendor.Name = name
+	vendor.Email = request.Email
+	vendor.Telephone = request.Telephone
+	vendor.TaxID = request.TaxID
+	vendor.Address = request.Address
+	vendor.ContactName = request.ContactName
+	vendor.Currency = currency
+	vendor.PaymentDays = request.PaymentDays
+	vendor.Archived = request.Archived
+	return nil
+}
+
+func (s *vendorService) GetVendor(id uint) (*models.Vendor, *apiError.Error) {
+	vendor, err := s.vendorRepo.FindVendor(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError.ErrNotFound
+		}
+		return nil, apiError.ErrInternalServerError
+	}
+	return vendor, nil
+}
+
+func (s *vendorService) ListVendors(query string, page, limit int) ([]models.Vendor, int64, *apiError.Error) {
+	vendors, total, err := s.vendorRepo.ListVendors(query, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("ListVendors error: %v", err)
+		return nil, 0, apiError.ErrInternalServerError
+	}
+	return vendors, total, nil
+}
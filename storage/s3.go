@@ -0,0 +1,320 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/techagentng/telair-erp/config"
+)
+
+// S3Storage stores files in an S3 bucket using multipart uploads for large objects
+type S3Storage struct {
+	Client   *s3.Client
+	Bucket   string
+	Region   string
+	uploader *manager.Uploader
+	presign  *s3.PresignClient
+}
+
+// NewS3Storage creates an S3 backed storage from the app config
+func NewS3Storage(c *config.Config) (*S3Storage, error) {
+	cfg, err := awsConfig.LoadDefaultConfig(context.TODO(), awsConfig.WithRegion(c.AWSRegion))
+	if err != nil {
+		return nil, fmt.Errorf("unable to load SDK config, %v", err)
+	}
+	client := s3.NewFromConfig(cfg)
+
+	partSize := c.UploadPartSizeMB << 20
+	if partSize < manager.MinUploadPartSize {
+		partSize = manager.MinUploadPartSize
+	}
+	concurrency := c.UploadConcurrency
+	if concurrency <= 0 {
+		concurrency = manager.DefaultUploadConcurrency
+	}
+
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		// Objects smaller than PartSize go up in a single PutObject,
+		// anything bigger is sent as a multipart upload
+		u.PartSize = partSize
+		u.Concurrency = concurrency
+	})
+
+	return &S3Storage{
+		Client:   client,
+		Bucket:   c.AWSBucket,
+		Region:   c.AWSRegion,
+		uploader: uploader,
+		presign:  s3.NewPresignClient(client),
+	}, nil
+}
+
+// Upload streams body to S3. S3 verifies the SHA-256 checksum of every part on
+// receipt, and for single part uploads we also compare the full object digest.
+func (s *S3Storage) Upload(ctx context.Context, key string, body io.Reader, opts UploadOptions) (*Object, error) {
+	hash := sha256.New()
+	counter := &countingWriter{}
+	reader := io.TeeReader(body, io.MultiWriter(hash, counter))
+
+	out, err := s.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:            aws.String(s.Bucket),
+		Key:               aws.String(key),
+		Body:              reader,
+		ContentType:       aws.String(opts.ContentType),
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+		ACL:               objectACL(opts),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload file to S3: %w", err)
+	}
+
+	sum := hash.Sum(nil)
+	if out.UploadID == "" && out.ChecksumSHA256 != nil {
+		if *out.ChecksumSHA256 != base64.StdEncoding.EncodeToString(sum) {
+			if err := s.Delete(ctx, key); err != nil {
+				log.Printf("failed to remove corrupted object %s: %v", key, err)
+			}
+			return nil, ErrChecksumMismatch
+		}
+	}
+
+	return &Object{
+		Key:         key,
+		URL:         s.URL(key),
+		Size:        counter.n,
+		ContentType: opts.ContentType,
+		SHA256:      hex.EncodeToString(sum),
+	}, nil
+}
+
+// Open returns a reader over the object stored under key
+func (s *S3Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s from S3: %w", key, err)
+	}
+	return out.Body, nil
+}
+
+// Head returns the size, content type and checksum of the object stored under key
+func (s *S3Storage) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	out, err := s.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(s.Bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %s in S3: %w", key, err)
+	}
+	return &ObjectInfo{
+		Size:           aws.ToInt64(out.ContentLength),
+		ContentType:    aws.ToString(out.ContentType),
+		ChecksumSHA256: aws.ToString(out.ChecksumSHA256),
+	}, nil
+}
+
+// Delete removes the object stored under key
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	_, err := s.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s from S3: %w", key, err)
+	}
+	return nil
+}
+
+// URL returns the URL of the object stored under key, only public objects can be read through it
+func (s *S3Storage) URL(key string) string {
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, s.Region, strings.TrimPrefix(key, "/"))
+}
+
+// CreateMultipart starts a multipart upload and returns its upload ID
+func (s *S3Storage) CreateMultipart(ctx context.Context, key string, opts UploadOptions) (string, error) {
+	input := &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(opts.ContentType),
+		ACL:         objectACL(opts),
+	}
+	if opts.VerifyParts {
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+	}
+	out, err := s.Client.CreateMultipartUpload(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to create multipart upload for %s: %w", key, err)
+	}
+	return aws.ToString(out.UploadId), nil
+}
+
+// UploadPart sends one part of a multipart upload, S3 verifies its SHA-256 checksum
+func (s *S3Storage) UploadPart(ctx context.Context, key, uploadID string, number int32, data []byte) (*Part, error) {
+	sum := sha256.Sum256(data)
+	checksum := base64.StdEncoding.EncodeToString(sum[:])
+	out, err := s.Client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:         aws.String(s.Bucket),
+		Key:            aws.String(key),
+		UploadId:       aws.String(uploadID),
+		PartNumber:     aws.Int32(number),
+		Body:           bytes.NewReader(data),
+		ContentLength:  aws.Int64(int64(len(data))),
+		ChecksumSHA256: aws.String(checksum),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload part %d of %s: %w", number, key, err)
+	}
+	return &Part{Number: number, ETag: aws.ToString(out.ETag), ChecksumSHA256: checksum, Size: int64(len(data))}, nil
+}
+
+// CompleteMultipart assembles the uploaded parts into the final object
+func (s *S3Storage) CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error {
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completedPart := types.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int32(part.Number),
+		}
+		if part.ChecksumSHA256 != "" {
+			completedPart.ChecksumSHA256 = aws.String(part.ChecksumSHA256)
+		}
+		completed = append(completed, completedPart)
+	}
+	_, err := s.Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.Bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload of %s: %w", key, err)
+	}
+	return nil
+}
+
+// AbortMultipart discards a multipart upload and the parts already sent
+func (s *S3Storage) AbortMultipart(ctx context.Context, key, uploadID string) error {
+	_, err := s.Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.Bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to abort multipart upload of %s: %w", key, err)
+	}
+	return nil
+}
+
+// PresignPut signs a PutObject request for exactly size bytes matching the base64 SHA-256 checksum
+func (s *S3Storage) PresignPut(ctx context.Context, key string, size int64, checksum string, opts UploadOptions, expires time.Duration) (*PresignedRequest, error) {
+	req, err := s.presign.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:         aws.String(s.Bucket),
+		Key:            aws.String(key),
+		ContentType:    aws.String(opts.ContentType),
+		ContentLength:  aws.Int64(size),
+		ChecksumSHA256: aws.String(checksum),
+		ACL:            objectACL(opts),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign upload of %s: %w", key, err)
+	}
+	return toPresignedRequest(req), nil
+}
+
+// PresignUploadPart signs the upload of one part of a multipart upload
+func (s *S3Storage) PresignUploadPart(ctx context.Context, key, uploadID string, number int32, size int64, checksum string, expires time.Duration) (*PresignedRequest, error) {
+	req, err := s.presign.PresignUploadPart(ctx, &s3.UploadPartInput{
+		Bucket:         aws.String(s.Bucket),
+		Key:            aws.String(key),
+		UploadId:       aws.String(uploadID),
+		PartNumber:     aws.Int32(number),
+		ContentLength:  aws.Int64(size),
+		ChecksumSHA256: aws.String(checksum),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign part %d of %s: %w", number, key, err)
+	}
+	return toPresignedRequest(req), nil
+}
+
+// PresignGet signs a download link for a private object
+func (s *S3Storage) PresignGet(ctx context.Context, key string, expires time.Duration) (*PresignedRequest, error) {
+	req, err := s.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign download of %s: %w", key, err)
+	}
+	return toPresignedRequest(req), nil
+}
+
+// List pages through the objects under prefix
+func (s *S3Storage) List(ctx context.Context, prefix string, fn func(ObjectSummary) error) error {
+	paginator := s3.NewListObjectsV2Paginator(s.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list %s in S3: %w", prefix, err)
+		}
+		for _, object := range page.Contents {
+			summary := ObjectSummary{
+				Key:          aws.ToString(object.Key),
+				Size:         aws.ToInt64(object.Size),
+				LastModified: aws.ToTime(object.LastModified),
+			}
+			if err := fn(summary); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// toPresignedRequest keeps the headers the client has to send along with the signed URL
+func toPresignedRequest(req *v4.PresignedHTTPRequest) *PresignedRequest {
+	headers := map[string]string{}
+	for name, values := range req.SignedHeader {
+		if strings.EqualFold(name, "host") || len(values) == 0 {
+			continue
+		}
+		headers[name] = values[0]
+	}
+	return &PresignedRequest{URL: req.URL, Method: req.Method, Headers: headers}
+}
+
+func objectACL(opts UploadOptions) types.ObjectCannedACL {
+	if opts.Public {
+		return types.ObjectCannedACLPublicRead
+	}
+	return types.ObjectCannedACLPrivate
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
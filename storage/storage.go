@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrChecksumMismatch is returned when the stored object does not match the bytes we sent
+var ErrChecksumMismatch = errors.New("checksum mismatch between uploaded data and stored object")
+
+// MinPartSize is the smallest part S3 accepts in a multipart upload, except for the last one
+const MinPartSize int64 = 5 << 20
+
+// Object describes a file written to the storage backend
+type Object struct {
+	Key         string `json:"key"`
+	URL         string `json:"url"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	SHA256      string `json:"sha256"` // hex encoded digest of the uploaded bytes
+}
+
+// ObjectInfo is what the backend knows about a stored object
+type ObjectInfo struct {
+	Size           int64
+	ContentType    string
+	ChecksumSHA256 string // base64 encoded, composite checksums of multipart objects end in "-<parts>"
+}
+
+// UploadOptions controls how an object is written
+type UploadOptions struct {
+	ContentType string
+	// Public objects can be read by anyone through URL, everything else needs a presigned link
+	Public bool
+	// VerifyParts makes every part of a multipart upload carry a SHA-256 checksum that S3 verifies
+	VerifyParts bool
+}
+
+// Part is a finished part of a multipart upload
+type Part struct {
+	Number         int32
+	ETag           string
+	ChecksumSHA256 string // base64 encoded, S3 wants it back when completing the upload
+	Size           int64
+}
+
+// ObjectSummary is an entry of a bucket listing
+type ObjectSummary struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// PresignedRequest is a short-lived request the client can send to the storage backend directly
+type PresignedRequest struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+}
+
+// Storage is the interface every file storage backend has to satisfy
+type Storage interface {
+	// Upload streams body to key without buffering the whole file in memory
+	Upload(ctx context.Context, key string, body io.Reader, opts UploadOptions) (*Object, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Head(ctx context.Context, key string) (*ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+
+	// Multipart uploads that span several requests, used by resumable and direct uploads
+	CreateMultipart(ctx context.Context, key string, opts UploadOptions) (string, error)
+	UploadPart(ctx context.Context, key, uploadID string, number int32, data []byte) (*Part, error)
+	CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error
+	AbortMultipart(ctx context.Context, key, uploadID string) error
+
+	// Presigned requests let clients talk to the backend without going through our server
+	PresignPut(ctx context.Context, key string, size int64, checksum string, opts UploadOptions, expires time.Duration) (*PresignedRequest, error)
+	PresignUploadPart(ctx context.Context, key, uploadID string, number int32, size int64, checksum string, expires time.Duration) (*PresignedRequest, error)
+	PresignGet(ctx context.Context, key string, expires time.Duration) (*PresignedRequest, error)
+
+	// List calls fn for every object whose key starts with prefix
+	List(ctx context.Context, prefix string, fn func(ObjectSummary) error) error
+}
+
+// NewKey returns a fresh key under prefix. Keys never contain the client's file name,
+// only its extension, so two uploads of the same name cannot overwrite each other.
+func NewKey(prefix, filename string) string {
+	key := uuid.New().String() + strings.ToLower(path.Ext(filename))
+	if prefix == "" {
+		return key
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + key
+}
+
+// CompositeChecksum computes the checksum S3 reports for a multipart object from the
+// base64 encoded SHA-256 checksums of its parts, in part order
+func CompositeChecksum(partChecksums []string) (string, error) {
+	hash := sha256.New()
+	for _, checksum := range partChecksums {
+		sum, err := base64.StdEncoding.DecodeString(checksum)
+		if err != nil {
+			return "", fmt.Errorf("invalid part checksum %q: %w", checksum, err)
+		}
+		hash.Write(sum)
+	}
+	return fmt.Sprintf("%s-%d", base64.StdEncoding.EncodeToString(hash.Sum(nil)), len(partChecksums)), nil
+}
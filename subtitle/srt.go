@@ -0,0 +1,34 @@
+package subtitle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// parseSRT reads the cues of a SubRip file, each block is a cue number, a timing line and the text
+func (p *parser) parseSRT(blocks []block) []Cue {
+	var cues []Cue
+	for _, b := range blocks {
+		i := 0
+		if isNumber(strings.TrimSpace(b.lines[0])) {
+			i++
+		} else if !strings.Contains(b.lines[0], "-->") {
+			p.add(b.line, "expected a cue number")
+			continue
+		}
+		if cue, ok := p.parseCue(b, i, SRT); ok {
+			cues = append(cues, cue)
+		}
+	}
+	return cues
+}
+
+func writeSRT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	for i, cue := range cues {
+		fmt.Fprintf(bw, "%d\n%s --> %s\n%s\n\n", i+1, formatTimestamp(cue.Start, ','), formatTimestamp(cue.End, ','), cue.Text)
+	}
+	return bw.Flush()
+}
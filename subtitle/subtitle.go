@@ -0,0 +1,361 @@
+// Package subtitle reads, checks and writes SubRip (SRT) and WebVTT subtitle files
+package subtitle
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type Format string
+
+const (
+	SRT Format = "srt"
+	VTT Format = "vtt"
+)
+
+// IsValid reports whether f is a format we read and write
+func (f Format) IsValid() bool {
+	return f == SRT || f == VTT
+}
+
+// ContentType is the MIME type files of the format are served with
+func (f Format) ContentType() string {
+	if f == VTT {
+		return "text/vtt"
+	}
+	return "application/x-subrip"
+}
+
+// Cue is one subtitle shown between Start and End
+type Cue struct {
+	ID       string // WebVTT cue identifier, SRT numbers its cues instead
+	Start    time.Duration
+	End      time.Duration
+	Settings string // WebVTT cue settings, e.g. "line:0 align:start"
+	Text     string
+
+	line int // where the cue starts in the file it was read from
+}
+
+// Document is a parsed subtitle file
+type Document struct {
+	Format Format
+	Cues   []Cue
+}
+
+// Duration is when the last cue ends
+func (d *Document) Duration() time.Duration {
+	var end time.Duration
+	for _, cue := range d.Cues {
+		if cue.End > end {
+			end = cue.End
+		}
+	}
+	return end
+}
+
+// Shift moves every cue by offset. Cues pushed entirely before the start are dropped and
+// the ones straddling it are cut at zero.
+func (d *Document) Shift(offset time.Duration) {
+	cues := d.Cues[:0]
+	for _, cue := range d.Cues {
+		cue.Start += offset
+		cue.End += offset
+		if cue.End <= 0 {
+			continue
+		}
+		if cue.Start < 0 {
+			cue.Start = 0
+		}
+		cues = append(cues, cue)
+	}
+	d.Cues = cues
+}
+
+// Problem is something wrong with a subtitle file, lines count from 1
+type Problem struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// ParseError lists what is wrong with a subtitle file
+type ParseError struct {
+	Problems []Problem
+}
+
+func (e *ParseError) Error() string {
+	messages := make([]string, 0, 3)
+	for i, problem := range e.Problems {
+		if i == 3 {
+			messages = append(messages, fmt.Sprintf("and %d more problems", len(e.Problems)-i))
+			break
+		}
+		messages = append(messages, fmt.Sprintf("line %d: %s", problem.Line, problem.Message))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// maxProblems stops a badly broken file from producing an endless report
+const maxProblems = 100
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// Sniff tells the format of a subtitle file from its first bytes, it returns "" for anything else.
+// UTF-16 files are recognised too so that Parse can reject them with a helpful message.
+func Sniff(header []byte) Format {
+	text := decodeUTF16(header)
+	text = bytes.TrimPrefix(text, utf8BOM)
+	if bytes.HasPrefix(text, []byte("WEBVTT")) {
+		return VTT
+	}
+
+	// An SRT file opens with a cue number followed by a timing line
+	lines := strings.SplitN(strings.TrimLeft(normalizeNewlines(string(text)), "\n"), "\n", 3)
+	if len(lines) >= 2 && isNumber(strings.TrimSpace(lines[0])) && strings.Contains(lines[1], "-->") {
+		return SRT
+	}
+	return ""
+}
+
+// decodeUTF16 turns text starting with a UTF-16 byte order mark into UTF-8, other text is returned as is
+func decodeUTF16(data []byte) []byte {
+	var order func(b []byte) uint16
+	switch {
+	case bytes.HasPrefix(data, utf16LEBOM):
+		order = func(b []byte) uint16 { return uint16(b[0]) | uint16(b[1])<<8 }
+	case bytes.HasPrefix(data, utf16BEBOM):
+		order = func(b []byte) uint16 { return uint16(b[1]) | uint16(b[0])<<8 }
+	default:
+		return data
+	}
+	units := make([]uint16, 0, len(data)/2)
+	for i := 2; i+1 < len(data); i += 2 {
+		units = append(units, order(data[i:i+2]))
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+func normalizeNewlines(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\r", "\n")
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse reads an SRT or WebVTT file. Files have to be UTF-8, every cue needs a valid timing and
+// text, and cues have to come in order without overlapping. All the problems found are returned
+// together in a *ParseError.
+func Parse(data []byte) (*Document, error) {
+	if bytes.HasPrefix(data, utf16LEBOM) || bytes.HasPrefix(data, utf16BEBOM) {
+		return nil, problem(1, "file is UTF-16 encoded, subtitles have to be UTF-8")
+	}
+	data = bytes.TrimPrefix(data, utf8BOM)
+	if !utf8.Valid(data) {
+		valid := data
+		for len(valid) > 0 {
+			r, size := utf8.DecodeRune(valid)
+			if r == utf8.RuneError && size <= 1 {
+				break
+			}
+			valid = valid[size:]
+		}
+		line := 1 + bytes.Count(data[:len(data)-len(valid)], []byte("\n"))
+		return nil, problem(line, "file is not valid UTF-8, subtitles have to be UTF-8 encoded")
+	}
+
+	p := &parser{}
+	doc := &Document{Format: Sniff(data)}
+	blocks := splitBlocks(strings.Split(normalizeNewlines(string(data)), "\n"))
+	switch doc.Format {
+	case SRT:
+		doc.Cues = p.parseSRT(blocks)
+	case VTT:
+		doc.Cues = p.parseVTT(blocks)
+	default:
+		return nil, problem(1, "not an SRT or WebVTT file")
+	}
+	p.check(doc.Cues)
+
+	if len(p.problems) == 0 && len(doc.Cues) == 0 {
+		p.add(1, "file has no cues")
+	}
+	if len(p.problems) > 0 {
+		sort.SliceStable(p.problems, func(i, j int) bool { return p.problems[i].Line < p.problems[j].Line })
+		return nil, &ParseError{Problems: p.problems}
+	}
+	return doc, nil
+}
+
+func problem(line int, message string) *ParseError {
+	return &ParseError{Problems: []Problem{{Line: line, Message: message}}}
+}
+
+// block is a run of non blank lines, line is the number of the first one
+type block struct {
+	line  int
+	lines []string
+}
+
+func splitBlocks(lines []string) []block {
+	var blocks []block
+	var current *block
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			current = nil
+			continue
+		}
+		if current == nil {
+			blocks = append(blocks, block{line: i + 1})
+			current = &blocks[len(blocks)-1]
+		}
+		current.lines = append(current.lines, line)
+	}
+	return blocks
+}
+
+type parser struct {
+	problems []Problem
+}
+
+func (p *parser) add(line int, format string, args ...interface{}) {
+	if len(p.problems) < maxProblems {
+		p.problems = append(p.problems, Problem{Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+// parseCue reads the timing line at lines[i] and the text after it
+func (p *parser) parseCue(b block, i int, format Format) (Cue, bool) {
+	cue := Cue{line: b.line + i}
+	if i >= len(b.lines) || !strings.Contains(b.lines[i], "-->") {
+		p.add(b.line+i, "expected a timing line such as %s", timingExample(format))
+		return cue, false
+	}
+
+	parts := strings.SplitN(b.lines[i], "-->", 2)
+	end := strings.Fields(parts[1])
+	var ok bool
+	if cue.Start, ok = parseTimestamp(strings.TrimSpace(parts[0]), format); !ok {
+		p.add(b.line+i, "invalid start time %q, expected a time such as %s", strings.TrimSpace(parts[0]), timestampExample(format))
+		return cue, false
+	}
+	if len(end) == 0 {
+		p.add(b.line+i, "missing end time")
+		return cue, false
+	}
+	if cue.End, ok = parseTimestamp(end[0], format); !ok {
+		p.add(b.line+i, "invalid end time %q, expected a time such as %s", end[0], timestampExample(format))
+		return cue, false
+	}
+	if format == VTT {
+		cue.Settings = strings.Join(end[1:], " ")
+	}
+
+	cue.Text = strings.Join(b.lines[i+1:], "\n")
+	if strings.TrimSpace(cue.Text) == "" {
+		p.add(b.line+i, "cue has no text")
+		return cue, false
+	}
+	return cue, true
+}
+
+// check holds the cues to their timings: each has to end after it starts, and start once the previous one ended
+func (p *parser) check(cues []Cue) {
+	for i, cue := range cues {
+		if cue.End <= cue.Start {
+			p.add(cue.line, "cue ends at %s, not after it starts at %s", formatTimestamp(cue.End, '.'), formatTimestamp(cue.Start, '.'))
+		}
+		if i == 0 {
+			continue
+		}
+		previous := cues[i-1]
+		if cue.Start < previous.Start {
+			p.add(cue.line, "cue starts at %s, before the previous cue", formatTimestamp(cue.Start, '.'))
+		} else if cue.Start < previous.End {
+			p.add(cue.line, "cue starts at %s and overlaps the previous cue, which ends at %s", formatTimestamp(cue.Start, '.'), formatTimestamp(previous.End, '.'))
+		}
+	}
+}
+
+// parseTimestamp reads an SRT time such as 01:02:03,456 or a WebVTT one such as 01:02:03.456,
+// where WebVTT may leave the hours out. SRT files written with a dot are accepted too.
+func parseTimestamp(s string, format Format) (time.Duration, bool) {
+	separator := strings.LastIndexAny(s, ",.")
+	if separator < 0 || (format == VTT && s[separator] != '.') {
+		return 0, false
+	}
+	millis := s[separator+1:]
+	units := strings.Split(s[:separator], ":")
+	if len(millis) != 3 || !isNumber(millis) || len(units) > 3 || len(units) < 2 || (format == SRT && len(units) != 3) {
+		return 0, false
+	}
+
+	var total time.Duration
+	for i, unit := range units {
+		value := 0
+		if !isNumber(unit) || len(unit) < 2 {
+			return 0, false
+		}
+		fmt.Sscan(unit, &value)
+		// Everything but the hours has to stay below 60 and use two digits
+		if i > 0 || len(units) == 2 {
+			if len(unit) != 2 || value > 59 {
+				return 0, false
+			}
+		}
+		total = total*60 + time.Duration(value)
+	}
+	ms := 0
+	fmt.Sscan(millis, &ms)
+	return total*time.Second + time.Duration(ms)*time.Millisecond, true
+}
+
+// formatTimestamp writes a time as HH:MM:SS followed by the separator and the milliseconds
+func formatTimestamp(d time.Duration, separator byte) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, separator, ms%1000)
+}
+
+func timestampExample(format Format) string {
+	if format == VTT {
+		return "00:01:02.500"
+	}
+	return "00:01:02,500"
+}
+
+func timingExample(format Format) string {
+	return timestampExample(format) + " --> " + timestampExample(format)
+}
+
+// Write writes the document in the given format, converting it when needed. Cue identifiers
+// and settings only exist in WebVTT and are lost when writing SRT.
+func (d *Document) Write(w io.Writer, format Format) error {
+	switch format {
+	case SRT:
+		return writeSRT(w, d.Cues)
+	case VTT:
+		return writeVTT(w, d.Cues)
+	}
+	return fmt.Errorf("unknown subtitle format: %s", format)
+}
@@ -0,0 +1,65 @@
+package subtitle
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// parseVTT reads the cues of a WebVTT file. The header block and the NOTE, STYLE and REGION blocks
+// are skipped, a cue may have an identifier line before its timing line.
+func (p *parser) parseVTT(blocks []block) []Cue {
+	var cues []Cue
+	for n, b := range blocks {
+		first := b.lines[0]
+		if n == 0 {
+			if first != "WEBVTT" && !strings.HasPrefix(first, "WEBVTT ") && !strings.HasPrefix(first, "WEBVTT\t") {
+				p.add(b.line, "a WebVTT file has to start with a WEBVTT line")
+			}
+			continue
+		}
+		if isVTTKeyword(first, "NOTE") || isVTTKeyword(first, "STYLE") || isVTTKeyword(first, "REGION") {
+			continue
+		}
+
+		i := 0
+		if !strings.Contains(first, "-->") {
+			i++
+		}
+		cue, ok := p.parseCue(b, i, VTT)
+		if !ok {
+			continue
+		}
+		if i == 1 {
+			cue.ID = first
+		}
+		if strings.Contains(cue.Text, "-->") {
+			p.add(b.line+i+1, "cue text cannot contain -->")
+			continue
+		}
+		cues = append(cues, cue)
+	}
+	return cues
+}
+
+func isVTTKeyword(line, keyword string) bool {
+	return line == keyword || strings.HasPrefix(line, keyword+" ") || strings.HasPrefix(line, keyword+"\t")
+}
+
+func writeVTT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT\n\n")
+	for _, cue := range cues {
+		if cue.ID != "" {
+			fmt.Fprintf(bw, "%s\n", cue.ID)
+		}
+		fmt.Fprintf(bw, "%s --> %s", formatTimestamp(cue.Start, '.'), formatTimestamp(cue.End, '.'))
+		if cue.Settings != "" {
+			fmt.Fprintf(bw, " %s", cue.Settings)
+		}
+		// An arrow in SRT text would end a WebVTT cue early
+		fmt.Fprintf(bw, "\n%s\n\n", strings.ReplaceAll(cue.Text, "-->", "->"))
+	}
+	return bw.Flush()
+}